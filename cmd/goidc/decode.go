@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/luikyv/go-oidc/pkg/rp"
+)
+
+// decodeLeeway is the clock skew tolerated when validating the claims.
+const decodeLeeway = time.Minute
+
+var sigAlgs = []jose.SignatureAlgorithm{
+	jose.RS256, jose.RS384, jose.RS512,
+	jose.PS256, jose.PS384, jose.PS512,
+	jose.ES256, jose.ES384, jose.ES512,
+	jose.EdDSA, jose.HS256, jose.HS384, jose.HS512,
+}
+
+type decodedToken struct {
+	Header   map[string]any `json:"header"`
+	Claims   map[string]any `json:"claims"`
+	Verified bool           `json:"verified"`
+}
+
+func runDecode(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("decode", flag.ContinueOnError)
+	issuer := flags.String("issuer", "", "issuer of the provider, whose JWKS is used to verify the token")
+	jwksFile := flags.String("jwks", "", "JWKS used to verify the token instead of the issuer's")
+	audience := flags.String("audience", "", "audience expected in the token")
+	insecure := flags.Bool("insecure", false, "skip the verification of the provider certificate")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return errors.New("exactly one token must be informed")
+	}
+
+	parsedToken, err := jwt.ParseSigned(flags.Arg(0), sigAlgs)
+	if err != nil {
+		return fmt.Errorf("invalid token: %w", err)
+	}
+
+	if len(parsedToken.Headers) != 1 {
+		return errors.New("invalid token header")
+	}
+
+	decoded := decodedToken{Header: headerClaims(parsedToken.Headers[0])}
+	if *issuer == "" && *jwksFile == "" {
+		if err := parsedToken.UnsafeClaimsWithoutVerification(&decoded.Claims); err != nil {
+			return fmt.Errorf("invalid claims: %w", err)
+		}
+		return printJSON(decoded)
+	}
+
+	jwks, err := verificationJWKS(ctx, newHTTPClient(*insecure), *issuer, *jwksFile)
+	if err != nil {
+		return err
+	}
+
+	keys := jwks.Key(parsedToken.Headers[0].KeyID)
+	if len(keys) == 0 {
+		return errors.New("the token signing key was not found")
+	}
+
+	var claims jwt.Claims
+	if err := parsedToken.Claims(keys[0].Key, &claims, &decoded.Claims); err != nil {
+		return fmt.Errorf("invalid signature: %w", err)
+	}
+
+	expected := jwt.Expected{Issuer: *issuer}
+	if *audience != "" {
+		expected.AnyAudience = []string{*audience}
+	}
+	if err := claims.ValidateWithLeeway(expected, decodeLeeway); err != nil {
+		return fmt.Errorf("invalid claims: %w", err)
+	}
+
+	decoded.Verified = true
+	return printJSON(decoded)
+}
+
+// verificationJWKS returns the keys in the file if one is informed. Otherwise,
+// the keys published by the issuer are fetched.
+func verificationJWKS(
+	ctx context.Context,
+	httpClient *http.Client,
+	issuer string,
+	file string,
+) (
+	jose.JSONWebKeySet,
+	error,
+) {
+	if file != "" {
+		return readJWKS(file)
+	}
+
+	metadata, err := rp.Discover(ctx, httpClient, issuer)
+	if err != nil {
+		return jose.JSONWebKeySet{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, metadata.JWKSURI, nil)
+	if err != nil {
+		return jose.JSONWebKeySet{}, err
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return jose.JSONWebKeySet{}, fmt.Errorf("could not fetch the provider jwks: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return jose.JSONWebKeySet{}, fmt.Errorf("%s returned status %d", metadata.JWKSURI, resp.StatusCode)
+	}
+
+	var jwks jose.JSONWebKeySet
+	if err := json.NewDecoder(resp.Body).Decode(&jwks); err != nil {
+		return jose.JSONWebKeySet{}, fmt.Errorf("invalid provider jwks: %w", err)
+	}
+	return jwks, nil
+}
+
+func headerClaims(header jose.Header) map[string]any {
+	claims := map[string]any{"alg": header.Algorithm}
+	if header.KeyID != "" {
+		claims["kid"] = header.KeyID
+	}
+	for name, value := range header.ExtraHeaders {
+		claims[string(name)] = value
+	}
+	return claims
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+const rsaKeyBits = 2048
+
+func runJWKS(_ context.Context, args []string) error {
+	flags := flag.NewFlagSet("jwks", flag.ContinueOnError)
+	alg := flags.String("alg", string(jose.PS256), "algorithm of the key, e.g. PS256, ES256, EdDSA or RSA-OAEP-256")
+	kid := flags.String("kid", "", "key ID, defaults to the key thumbprint")
+	publicFile := flags.String("public", "", "file where the public JWKS is written")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	jwk, err := generateJWK(*alg, *kid)
+	if err != nil {
+		return err
+	}
+
+	if *publicFile != "" {
+		publicJWKS, err := json.MarshalIndent(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{jwk.Public()}}, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(*publicFile, append(publicJWKS, '\n'), 0o644); err != nil {
+			return fmt.Errorf("could not write the public jwks: %w", err)
+		}
+	}
+
+	return printJSON(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{jwk}})
+}
+
+// generateJWK creates a private key for the algorithm informed. Signature
+// algorithms generate signing keys and key management algorithms generate
+// encryption keys.
+func generateJWK(alg, kid string) (jose.JSONWebKey, error) {
+	key, usage, err := generateKey(alg)
+	if err != nil {
+		return jose.JSONWebKey{}, err
+	}
+
+	jwk := jose.JSONWebKey{
+		Key:       key,
+		KeyID:     kid,
+		Algorithm: alg,
+		Use:       string(usage),
+	}
+
+	if jwk.KeyID == "" {
+		thumbprint, err := jwk.Thumbprint(crypto.SHA256)
+		if err != nil {
+			return jose.JSONWebKey{}, err
+		}
+		jwk.KeyID = base64.RawURLEncoding.EncodeToString(thumbprint)
+	}
+
+	return jwk, nil
+}
+
+func generateKey(alg string) (crypto.PrivateKey, goidc.KeyUsage, error) {
+	switch alg {
+	case string(jose.RS256), string(jose.RS384), string(jose.RS512),
+		string(jose.PS256), string(jose.PS384), string(jose.PS512):
+		key, err := rsa.GenerateKey(rand.Reader, rsaKeyBits)
+		return key, goidc.KeyUsageSignature, err
+	case string(jose.ES256):
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		return key, goidc.KeyUsageSignature, err
+	case string(jose.ES384):
+		key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+		return key, goidc.KeyUsageSignature, err
+	case string(jose.ES512):
+		key, err := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+		return key, goidc.KeyUsageSignature, err
+	case string(jose.EdDSA):
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		return key, goidc.KeyUsageSignature, err
+	case string(jose.RSA_OAEP), string(jose.RSA_OAEP_256):
+		key, err := rsa.GenerateKey(rand.Reader, rsaKeyBits)
+		return key, goidc.KeyUsageEncryption, err
+	default:
+		return nil, "", errors.New("unsupported algorithm " + alg)
+	}
+}
@@ -0,0 +1,82 @@
+// Command goidc helps operating and testing providers built with go-oidc.
+//
+// Usage:
+//
+//	go run ./cmd/goidc <command> [flags]
+//
+// Commands:
+//
+//	jwks         Generates a private JWKS in the format expected by
+//	             provider.New, optionally writing its public counterpart.
+//	hash-secret  Hashes a client secret for goidc.Client.HashedSecret. If no
+//	             secret is informed, a random one is generated.
+//	token        Mints an access token against a running provider using the
+//	             client credentials grant.
+//	decode       Prints the header and the claims of a JWT. When the issuer or
+//	             a JWKS is informed, the signature and the claims are verified.
+//
+// Run "goidc <command> -h" to see the flags of a command.
+//
+// Example:
+//
+//	go run ./cmd/goidc jwks -alg PS256 -public server_pub.jwks > server.jwks
+//	go run ./cmd/goidc token -issuer https://auth.localhost \
+//	-client_id client_one -client_secret secret -scope "openid email"
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+type command struct {
+	name string
+	run  func(ctx context.Context, args []string) error
+}
+
+var commands = []command{
+	{"jwks", runJWKS},
+	{"hash-secret", runHashSecret},
+	{"token", runToken},
+	{"decode", runDecode},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	for _, cmd := range commands {
+		if cmd.name != os.Args[1] {
+			continue
+		}
+
+		if err := cmd.run(context.Background(), os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "goidc %s: %v\n", cmd.name, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "goidc: unknown command %q\n", os.Args[1])
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: goidc <command> [flags]")
+	fmt.Fprintln(os.Stderr, "commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "\t%s\n", cmd.name)
+	}
+}
+
+// printJSON writes v indented to the standard output.
+func printJSON(v any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
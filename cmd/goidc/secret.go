@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/luikyv/go-oidc/internal/strutil"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const generatedSecretLength = 32
+
+func runHashSecret(_ context.Context, args []string) error {
+	flags := flag.NewFlagSet("hash-secret", flag.ContinueOnError)
+	secret := flags.String("secret", "", "client secret to hash, a random one is generated if empty")
+	cost := flags.Int("cost", bcrypt.DefaultCost, "bcrypt cost")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	generated := *secret == ""
+	if generated {
+		*secret = strutil.Random(generatedSecretLength)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(*secret), *cost)
+	if err != nil {
+		return fmt.Errorf("could not hash the secret: %w", err)
+	}
+
+	if generated {
+		fmt.Printf("secret: %s\n", *secret)
+	}
+	fmt.Printf("hashed_secret: %s\n", hash)
+	return nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/luikyv/go-oidc/pkg/rp"
+)
+
+func runToken(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("token", flag.ContinueOnError)
+	issuer := flags.String("issuer", "", "issuer of the provider")
+	clientID := flags.String("client_id", "", "ID of the client")
+	clientSecret := flags.String("client_secret", "", "secret of the client, sent with client_secret_post")
+	jwksFile := flags.String("jwks", "", "private JWKS of the client, used for private_key_jwt when no secret is informed")
+	scope := flags.String("scope", "", "space separated scopes requested")
+	insecure := flags.Bool("insecure", false, "skip the verification of the provider certificate")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *issuer == "" || *clientID == "" {
+		return errors.New("the issuer and the client_id are required")
+	}
+
+	httpClient := newHTTPClient(*insecure)
+	metadata, err := rp.Discover(ctx, httpClient, *issuer)
+	if err != nil {
+		return err
+	}
+
+	client := rp.Client{
+		ID:         *clientID,
+		Secret:     *clientSecret,
+		Provider:   metadata,
+		HTTPClient: httpClient,
+	}
+
+	if *jwksFile != "" {
+		jwks, err := readJWKS(*jwksFile)
+		if err != nil {
+			return err
+		}
+		if len(jwks.Keys) == 0 {
+			return errors.New("the client jwks has no keys")
+		}
+		client.JWK = &jwks.Keys[0]
+	}
+
+	tokenResp, err := client.ClientCredentials(ctx, strings.Fields(*scope)...)
+	if err != nil {
+		return fmt.Errorf("could not obtain the token: %w", err)
+	}
+
+	return printJSON(tokenResp)
+}
+
+func newHTTPClient(insecure bool) *http.Client {
+	if !insecure {
+		return http.DefaultClient
+	}
+
+	return &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+}
+
+func readJWKS(file string) (jose.JSONWebKeySet, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return jose.JSONWebKeySet{}, fmt.Errorf("could not read the jwks: %w", err)
+	}
+
+	var jwks jose.JSONWebKeySet
+	if err := json.Unmarshal(data, &jwks); err != nil {
+		return jose.JSONWebKeySet{}, fmt.Errorf("invalid jwks: %w", err)
+	}
+	return jwks, nil
+}
@@ -130,8 +130,8 @@ func (a authenticator) loadUser(
 ) {
 
 	// Never do this in production, it's just an example.
-	if as.IDTokenHintClaims != nil {
-		as.Subject = as.IDTokenHintClaims[goidc.ClaimSubject].(string)
+	if sub, ok := as.IDTokenHintSubject(); ok {
+		as.Subject = sub
 		as.StoreParameter(paramAuthTime, as.IDTokenHintClaims[goidc.ClaimAuthTime])
 	}
 
@@ -170,13 +170,12 @@ func (a authenticator) login(
 	// Determine if authentication is required.
 	// Authentication is required if the user's identity is unknown or if the
 	// client explicitly requested a login.
-	mustAuthenticate := as.Subject == "" || as.Prompt == goidc.PromptTypeLogin
+	mustAuthenticate := as.Subject == "" || as.MustReauthenticate()
 	// Additionally, check if the client specified a max age for the session.
 	// If the max age is exceeded or 'auth_time' is unavailable, force re-authentication.
 	if as.MaxAuthnAgeSecs != nil {
-		maxAgeSecs := *as.MaxAuthnAgeSecs
-		authTime := as.Parameter(paramAuthTime)
-		if authTime == nil || timeutil.TimestampNow() > authTime.(int)+maxAgeSecs {
+		authTime, ok := as.Parameter(paramAuthTime).(int)
+		if !ok || as.IsAuthnTooOld(authTime) {
 			mustAuthenticate = true
 		}
 	}
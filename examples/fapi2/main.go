@@ -95,14 +95,10 @@ func main() {
 		Addr:    authutil.Port,
 		Handler: mux,
 		TLSConfig: &tls.Config{
-			ClientCAs:  caPool,
-			ClientAuth: tls.VerifyClientCertIfGiven,
-			CipherSuites: []uint16{
-				tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
-				tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
-				tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
-				tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
-			},
+			ClientCAs:    caPool,
+			ClientAuth:   tls.VerifyClientCertIfGiven,
+			MinVersion:   tls.VersionTLS12,
+			CipherSuites: goidc.FAPIAllowedCipherSuites,
 		},
 	}
 	if err := server.ListenAndServeTLS(serverCertFilePath, serverCertKeyFilePath); err != nil {
@@ -1,12 +1,14 @@
 package authorize
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/luikyv/go-oidc/internal/oidc"
+	"github.com/luikyv/go-oidc/pkg/goidc"
 )
 
-func RegisterHandlers(router *http.ServeMux, config *oidc.Configuration) {
+func RegisterHandlers(router oidc.Router, config *oidc.Configuration) {
 	if config.PARIsEnabled {
 		router.HandleFunc(
 			"POST "+config.EndpointPrefix+config.EndpointPushedAuthorization,
@@ -55,26 +57,26 @@ func handler(ctx oidc.Context) {
 		req = newRequest(ctx.Request)
 	}
 
-	err := initAuth(ctx, req)
-	if err != nil {
-		err = ctx.RenderError(err)
-	}
-
-	if err != nil {
-		ctx.WriteError(err)
+	if err := initAuth(ctx, req); err != nil {
+		renderError(ctx, err)
 	}
 }
 
 func handlerCallback(ctx oidc.Context) {
 	callbackID := ctx.Request.PathValue("callback")
-	err := continueAuth(ctx, callbackID)
-	if err == nil {
-		return
+	if err := continueAuth(ctx, callbackID); err != nil {
+		renderError(ctx, err)
+	}
+}
+
+// renderError shows the error to the user unless it must be written as JSON.
+func renderError(ctx oidc.Context, err error) {
+	var oidcErr goidc.Error
+	if !errors.As(err, &oidcErr) || oidcErr.Handling() != goidc.ErrorHandlingJSON {
+		err = ctx.RenderError(err)
 	}
 
-	err = ctx.RenderError(err)
 	if err != nil {
 		ctx.WriteError(err)
 	}
-
 }
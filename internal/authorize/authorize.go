@@ -1,7 +1,10 @@
 package authorize
 
 import (
+	"crypto/subtle"
 	"errors"
+	"net/http"
+	"slices"
 	"strings"
 
 	"github.com/go-jose/go-jose/v4/jwt"
@@ -35,21 +38,59 @@ func initAuthNoRedirect(ctx oidc.Context, client *goidc.Client, req request) err
 	if err != nil {
 		return err
 	}
+
+	if ctx.CallbackBindingIsEnabled {
+		bindCallback(session)
+	}
+
+	if ctx.UserSessionIsEnabled {
+		loadAccounts(ctx, session)
+	}
+
 	return authenticate(ctx, session)
 }
 
 func continueAuth(ctx oidc.Context, callbackID string) error {
+	session, err := callbackSession(ctx, callbackID)
+	if err != nil {
+		return err
+	}
+
+	if err := validateCallbackBinding(ctx, session); err != nil {
+		return err
+	}
+
+	return resumeAuthnSession(ctx, session)
+}
+
+// ResumeAuth continues the authentication of the session identified by the
+// callback ID from an entry point other than the callback endpoint.
+// Since the caller is responsible for identifying the user agent, the callback
+// binding is not verified.
+func ResumeAuth(ctx oidc.Context, callbackID string) error {
+	session, err := callbackSession(ctx, callbackID)
+	if err != nil {
+		return err
+	}
 
+	return resumeAuthnSession(ctx, session)
+}
+
+func callbackSession(ctx oidc.Context, callbackID string) (*goidc.AuthnSession, error) {
 	// Fetch the session using the callback ID.
 	session, err := ctx.AuthnSessionByCallbackID(callbackID)
 	if err != nil {
-		return goidc.NewError(goidc.ErrorCodeInvalidRequest, "could not load the session")
+		return nil, goidc.NewError(goidc.ErrorCodeInvalidRequest, "could not load the session")
 	}
 
 	if session.IsExpired() {
-		return goidc.NewError(goidc.ErrorCodeInvalidRequest, "session timeout")
+		return nil, goidc.NewError(goidc.ErrorCodeInvalidRequest, "session timeout")
 	}
 
+	return session, nil
+}
+
+func resumeAuthnSession(ctx oidc.Context, session *goidc.AuthnSession) error {
 	if oauthErr := authenticate(ctx, session); oauthErr != nil {
 		client, err := ctx.Client(session.ClientID)
 		if err != nil {
@@ -73,8 +114,13 @@ func initAuthnSession(
 	if err != nil {
 		return nil, err
 	}
+	session.ScopeInfos = ctx.ScopeInfos(session.Scopes)
 
 	policy, ok := ctx.AvailablePolicy(client, session)
+	if !ok && len(session.EssentialACRs()) != 0 {
+		return nil, newRedirectionError(goidc.ErrorCodeUnmetAuthnRequirements,
+			"no policy can achieve the essential acr", session.AuthorizationParameters)
+	}
 	if !ok {
 		return nil, newRedirectionError(goidc.ErrorCodeInvalidRequest,
 			"no policy available", session.AuthorizationParameters)
@@ -83,10 +129,23 @@ func initAuthnSession(
 	if session.Nonce != "" {
 		session.SetIDTokenClaim(goidc.ClaimNonce, session.Nonce)
 	}
+
+	// ID tokens returned from the authorization endpoint are exposed to the
+	// user agent, so their nonces must not be replayed.
+	if session.Nonce != "" && session.ResponseType.Contains(goidc.ResponseTypeIDToken) {
+		if err := ctx.CheckNonce(client.ID, session.Nonce); err != nil {
+			return nil, newRedirectionError(goidc.ErrorCodeInvalidRequest,
+				"the nonce was already used", session.AuthorizationParameters)
+		}
+	}
+
 	session.PolicyID = policy.ID
-	session.CallbackID = callbackID()
+	session.CallbackID = callbackID(ctx)
 	session.ReferenceID = ""
 	session.ExpiresAtTimestamp = timeutil.TimestampNow() + ctx.AuthnSessionTimeoutSecs
+	if ctx.AuthnSessionMaxLifetimeSecs != 0 {
+		session.MaxExpiresAtTimestamp = session.CreatedAtTimestamp + ctx.AuthnSessionMaxLifetimeSecs
+	}
 	if session.IDTokenHint != "" {
 		// The ID token hint was already validated.
 		idToken, _ := jwt.ParseSigned(session.IDTokenHint, ctx.UserSigAlgs)
@@ -122,10 +181,16 @@ func shouldUsePAR(
 	req goidc.AuthorizationParameters,
 	c *goidc.Client,
 ) bool {
+	// A client that requires par cannot fall back to a plain authorization
+	// request, even if par is not enabled.
+	if c.PARIsRequired {
+		return true
+	}
+
 	if !ctx.PARIsEnabled {
 		return false
 	}
-	return ctx.PARIsRequired || c.PARIsRequired || strings.HasPrefix(req.RequestURI, parRequestURIPrefix)
+	return ctx.PARIsRequired || strings.HasPrefix(req.RequestURI, parRequestURIPrefix)
 }
 
 func authnSessionWithPAR(
@@ -197,7 +262,7 @@ func authnSessionWithJAR(
 		return nil, err
 	}
 
-	session := newAuthnSession(jar.AuthorizationParameters, client)
+	session := newAuthnSession(ctx, jar.AuthorizationParameters, client)
 	session.AuthorizationParameters = mergeParams(
 		session.AuthorizationParameters,
 		req.AuthorizationParameters,
@@ -216,40 +281,269 @@ func simpleAuthnSession(
 	if err := validateRequest(ctx, req, client); err != nil {
 		return nil, err
 	}
-	return newAuthnSession(req.AuthorizationParameters, client), nil
+	return newAuthnSession(ctx, req.AuthorizationParameters, client), nil
+}
+
+func authorizationCode(ctx oidc.Context) string {
+	return strutil.Random(ctx.AuthzCodeLength)
+}
+
+func callbackID(ctx oidc.Context) string {
+	return ctx.NewID(goidc.IDTypeCallback, func() string {
+		return strutil.Random(callbackIDLength)
+	})
+}
+
+// bindCallback ties the session to the current user agent with a value that
+// must be presented in a cookie when the callback endpoint is called.
+// The cookie is set by [callbackBindingWriter] while the session is in
+// progress.
+func bindCallback(session *goidc.AuthnSession) {
+	session.CallbackBinding = strutil.Random(callbackBindingLength)
+}
+
+// callbackBindingWriter sets the callback binding cookie right before the
+// response of the policy is written, so the cookie expires with the session
+// even if the policy extended it with ExtendExpiry.
+// The cookie is scoped to the callback path of the session, so concurrent
+// authorization requests from the same user agent don't interfere.
+type callbackBindingWriter struct {
+	http.ResponseWriter
+	ctx     oidc.Context
+	session *goidc.AuthnSession
+	isSet   bool
+}
+
+func (w *callbackBindingWriter) WriteHeader(status int) {
+	w.setCookie()
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *callbackBindingWriter) Write(b []byte) (int, error) {
+	w.setCookie()
+	return w.ResponseWriter.Write(b)
 }
 
-func authorizationCode() string {
-	return strutil.Random(authorizationCodeLength)
+func (w *callbackBindingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
 }
 
-func callbackID() string {
-	return strutil.Random(callbackIDLength)
+func (w *callbackBindingWriter) setCookie() {
+	if w.isSet {
+		return
+	}
+	w.isSet = true
+
+	http.SetCookie(w.ResponseWriter, &http.Cookie{
+		Name:     callbackBindingCookie,
+		Value:    w.session.CallbackBinding,
+		Path:     w.ctx.EndpointPrefix + w.ctx.EndpointAuthorize + "/" + w.session.CallbackID,
+		MaxAge:   max(w.session.ExpiresAtTimestamp-timeutil.TimestampNow(), 1),
+		Secure:   true,
+		HttpOnly: true,
+		// The callback endpoint may be reached by cross site form posts, e.g.
+		// when the user is redirected back from an external identity provider.
+		SameSite: http.SameSiteNoneMode,
+	})
+}
+
+// validateCallbackBinding verifies that the user agent calling the callback
+// endpoint is the same one that started the authentication session.
+func validateCallbackBinding(ctx oidc.Context, session *goidc.AuthnSession) error {
+	if session.CallbackBinding == "" {
+		return nil
+	}
+
+	cookie, err := ctx.Request.Cookie(callbackBindingCookie)
+	if err != nil {
+		return goidc.NewError(goidc.ErrorCodeInvalidRequest,
+			"the session is not bound to this user agent")
+	}
+
+	if subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(session.CallbackBinding)) != 1 {
+		return goidc.NewError(goidc.ErrorCodeInvalidRequest,
+			"the session is not bound to this user agent")
+	}
+
+	return nil
 }
 
 func authenticate(ctx oidc.Context, session *goidc.AuthnSession) error {
 	policy := ctx.Policy(session.PolicyID)
-	switch status, err := policy.Authenticate(ctx.Response, ctx.Request, session); status {
+
+	// When the client requested prompt=none, a step may render a page before
+	// reporting that it needs the user. Its response is held so the error
+	// redirect is the only one sent in that case.
+	var w http.ResponseWriter = ctx.Response
+	var binding *callbackBindingWriter
+	if session.CallbackBinding != "" {
+		binding = &callbackBindingWriter{ResponseWriter: w, ctx: ctx, session: session}
+		w = binding
+	}
+
+	var held *heldResponseWriter
+	if !session.IsInteractionAllowed() {
+		held = &heldResponseWriter{header: http.Header{}}
+		w = held
+	}
+
+	status, err := policy.Authenticate(w, ctx.Request, session)
+	if held != nil && status != goidc.StatusInProgress {
+		if err := held.release(ctx.Response); err != nil {
+			return err
+		}
+	}
+
+	switch status {
 	case goidc.StatusSuccess:
+		if err := validateAuthnAge(session); err != nil {
+			return finishFlowWithFailure(ctx, session, err)
+		}
+		if err := validateEssentialACR(session); err != nil {
+			return finishFlowWithFailure(ctx, session, err)
+		}
 		return finishFlowSuccessfully(ctx, session)
 	case goidc.StatusInProgress:
+		// The policy needs to interact with the user, which is not allowed
+		// when the client requested prompt=none.
+		if held != nil {
+			return finishFlowWithFailure(ctx, session, interactionRequiredError(session))
+		}
+		// The policy may not have written a response yet.
+		if binding != nil {
+			binding.setCookie()
+		}
 		return stopFlowInProgress(ctx, session)
 	default:
 		return finishFlowWithFailure(ctx, session, err)
 	}
 }
 
+// heldResponseWriter keeps the response written by a policy until it is
+// known whether it can be sent.
+type heldResponseWriter struct {
+	header http.Header
+	status int
+	body   []byte
+}
+
+func (w *heldResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *heldResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *heldResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.body = append(w.body, b...)
+	return len(b), nil
+}
+
+// release sends the response held to dst.
+func (w *heldResponseWriter) release(dst http.ResponseWriter) error {
+	for key, values := range w.header {
+		dst.Header()[key] = values
+	}
+
+	if w.status == 0 {
+		return nil
+	}
+	dst.WriteHeader(w.status)
+	_, err := dst.Write(w.body)
+	return err
+}
+
+// interactionRequiredError returns the most specific error describing why the
+// user would need to be prompted.
+func interactionRequiredError(session *goidc.AuthnSession) error {
+	if session.Subject == "" {
+		return goidc.NewError(goidc.ErrorCodeLoginRequired,
+			"the user must be authenticated, but prompt none was requested")
+	}
+
+	if session.GrantedScopes == "" && session.GrantedAuthDetails == nil {
+		return goidc.NewError(goidc.ErrorCodeConsentRequired,
+			"the user must consent, but prompt none was requested")
+	}
+
+	return goidc.NewError(goidc.ErrorCodeInteractionRequired,
+		"user interaction is required, but prompt none was requested")
+}
+
+// validateAuthnAge verifies that the authentication time informed by the
+// policy satisfies the max_age requested by the client.
+func validateAuthnAge(session *goidc.AuthnSession) error {
+	if session.MaxAuthnAgeSecs == nil {
+		return nil
+	}
+
+	var authTime int
+	switch t := session.AdditionalIDTokenClaims[goidc.ClaimAuthTime].(type) {
+	case int:
+		authTime = t
+	case float64:
+		authTime = int(t)
+	default:
+		return goidc.NewError(goidc.ErrorCodeLoginRequired,
+			"the user authentication time is required by max_age but was not informed")
+	}
+
+	if session.IsAuthnTooOld(authTime) {
+		return goidc.NewError(goidc.ErrorCodeLoginRequired,
+			"the user authentication is older than max_age")
+	}
+
+	return nil
+}
+
+// validateEssentialACR verifies that the ACR achieved during authentication
+// satisfies the essential ACRs requested by the client, if any.
+func validateEssentialACR(session *goidc.AuthnSession) error {
+	essentialACRs := session.EssentialACRs()
+	if len(essentialACRs) == 0 {
+		return nil
+	}
+
+	var acr goidc.ACR
+	switch a := session.AdditionalIDTokenClaims[goidc.ClaimACR].(type) {
+	case goidc.ACR:
+		acr = a
+	case string:
+		acr = goidc.ACR(a)
+	}
+
+	if !slices.Contains(essentialACRs, acr) {
+		return goidc.NewError(goidc.ErrorCodeUnmetAuthnRequirements,
+			"the essential acr could not be achieved")
+	}
+
+	return nil
+}
+
 func finishFlowWithFailure(
 	ctx oidc.Context,
 	session *goidc.AuthnSession,
 	err error,
-) error {
+) (flowErr error) {
+	defer func() { ctx.RecordAuthorization(flowErr) }()
+
 	if err := ctx.DeleteAuthnSession(session.ID); err != nil {
 		return redirectionErrorf(goidc.ErrorCodeInternalError,
 			"internal error", session.AuthorizationParameters, err)
 	}
 
 	var oidcErr goidc.Error
+	if errors.As(err, &oidcErr) && oidcErr.Handling() != goidc.ErrorHandlingRedirect {
+		// The error is returned as is, so it is not redirected.
+		return oidcErr
+	}
+
 	if errors.As(err, &oidcErr) {
 		return newRedirectionError(oidcErr.Code,
 			oidcErr.Description, session.AuthorizationParameters)
@@ -278,7 +572,8 @@ func stopFlowInProgress(
 func finishFlowSuccessfully(
 	ctx oidc.Context,
 	session *goidc.AuthnSession,
-) error {
+) (flowErr error) {
+	defer func() { ctx.RecordAuthorization(flowErr) }()
 
 	client, err := ctx.Client(session.ClientID)
 	if err != nil {
@@ -286,10 +581,24 @@ func finishFlowSuccessfully(
 			"could not load the client", session.AuthorizationParameters, err)
 	}
 
+	if ctx.SessionIDClaimIsEnabled {
+		setSessionID(ctx, session)
+	}
+
 	if err := authorizeAuthnSession(ctx, session); err != nil {
 		return err
 	}
 
+	if err := ctx.RecordConsent(session); err != nil {
+		return redirectionErrorf(goidc.ErrorCodeInternalError,
+			"could not record the consent", session.AuthorizationParameters, err)
+	}
+
+	if err := recordUserSession(ctx, session); err != nil {
+		return redirectionErrorf(goidc.ErrorCodeInternalError,
+			"could not record the user session", session.AuthorizationParameters, err)
+	}
+
 	redirectParams := response{
 		authorizationCode: session.AuthorizationCode,
 		state:             session.State,
@@ -300,7 +609,7 @@ func finishFlowSuccessfully(
 			return err
 		}
 
-		token, err := token.Make(ctx, grantInfo)
+		token, err := token.Make(ctx, client, grantInfo)
 		if err != nil {
 			return redirectionErrorf(goidc.ErrorCodeInternalError,
 				"could not generate the access token", session.AuthorizationParameters, err)
@@ -315,9 +624,24 @@ func finishFlowSuccessfully(
 
 	if strutil.ContainsOpenID(session.GrantedScopes) &&
 		session.ResponseType.Contains(goidc.ResponseTypeIDToken) {
+		grantInfo, err := implicitGrantInfo(ctx, session)
+		if err != nil {
+			return err
+		}
+
+		claims, err := token.WithSourceClaims(ctx, grantInfo, session.AdditionalIDTokenClaims)
+		if err != nil {
+			return redirectionErrorf(goidc.ErrorCodeInternalError,
+				"could not load the user claims", session.AuthorizationParameters, err)
+		}
+
+		claims = ctx.FilterIDTokenClaims(grantInfo, claims)
+		claims = ctx.MinimizeIDTokenClaims(grantInfo, claims, redirectParams.accessToken != "")
 		idTokenOptions := token.IDTokenOptions{
 			Subject:                 session.Subject,
-			AdditionalIDTokenClaims: session.AdditionalIDTokenClaims,
+			AdditionalIDTokenClaims: claims,
+			AuthDetails:             ctx.AuthDetailsClaim(client, grantInfo),
+			SessionID:               grantInfo.SessionID,
 			AccessToken:             redirectParams.accessToken,
 			AuthorizationCode:       session.AuthorizationCode,
 			State:                   session.State,
@@ -346,8 +670,8 @@ func authorizeAuthnSession(
 		}
 	}
 
-	session.AuthorizationCode = authorizationCode()
-	session.ExpiresAtTimestamp = timeutil.TimestampNow() + authorizationCodeLifetimeSecs
+	session.AuthorizationCode = authorizationCode(ctx)
+	session.ExpiresAtTimestamp = timeutil.TimestampNow() + ctx.AuthzCodeLifetimeSecs
 	// Make sure the session won't be reached anymore from the callback endpoint.
 	session.CallbackID = ""
 
@@ -364,11 +688,20 @@ func generateImplicitGrantSession(
 	accessToken token.Token,
 ) error {
 
-	grantSession := token.NewGrantSession(grantInfo, accessToken)
-	if err := ctx.SaveGrantSession(grantSession); err != nil {
+	grantSession := token.NewGrantSession(ctx, grantInfo, accessToken)
+	save := ctx.SaveGrantSession
+	if accessToken.Format == goidc.TokenFormatJWT {
+		save = ctx.SaveGrantSessionAsync
+	}
+	if err := save(grantSession); err != nil {
+		return err
+	}
+
+	if err := ctx.BindGrantToConsent(grantSession); err != nil {
 		return err
 	}
 
+	ctx.NotifyTokenEvent(goidc.TokenEventIssuance, grantSession)
 	return nil
 }
 
@@ -391,7 +724,9 @@ func implicitGrantInfo(
 		AdditionalIDTokenClaims:  session.AdditionalIDTokenClaims,
 		AdditionalUserInfoClaims: session.AdditionalUserInfoClaims,
 		AdditionalTokenClaims:    session.AdditionalTokenClaims,
+		Claims:                   session.Claims,
 		JWKThumbprint:            session.DPoPJWKThumbprint,
+		SessionID:                session.SessionID,
 	}
 
 	setPoP(ctx, &grantInfo, session)
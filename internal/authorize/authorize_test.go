@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
 
 	"github.com/go-jose/go-jose/v4"
@@ -16,6 +17,7 @@ import (
 	"github.com/luikyv/go-oidc/internal/jwtutil"
 	"github.com/luikyv/go-oidc/internal/oidc"
 	"github.com/luikyv/go-oidc/internal/oidctest"
+	"github.com/luikyv/go-oidc/internal/storage"
 	"github.com/luikyv/go-oidc/internal/timeutil"
 	"github.com/luikyv/go-oidc/pkg/goidc"
 )
@@ -54,6 +56,7 @@ func TestInitAuth(t *testing.T) {
 	}
 
 	wantedSession := goidc.AuthnSession{
+		ScopeInfos:         []goidc.ScopeInfo{{ID: "scope1"}, {ID: "scope2"}, {ID: "openid"}},
 		ID:                 session.ID,
 		PolicyID:           ctx.Policies[0].ID,
 		ExpiresAtTimestamp: session.ExpiresAtTimestamp,
@@ -117,6 +120,139 @@ func TestInitAuth(t *testing.T) {
 	}
 }
 
+func TestInitAuth_AuthzCodeLengthAndLifetime(t *testing.T) {
+	// Given.
+	ctx, client := setUpAuth(t)
+	ctx.AuthzCodeLength = 40
+	ctx.AuthzCodeLifetimeSecs = 10
+
+	req := request{
+		ClientID: client.ID,
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			RedirectURI:  client.RedirectURIs[0],
+			Scopes:       client.ScopeIDs,
+			ResponseType: goidc.ResponseTypeCode,
+			ResponseMode: goidc.ResponseModeQuery,
+		},
+	}
+
+	// When.
+	err := initAuth(ctx, req)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	sessions := oidctest.AuthnSessions(t, ctx)
+	if len(sessions) != 1 {
+		t.Fatalf("len(sessions) = %d, want 1", len(sessions))
+	}
+
+	session := sessions[0]
+	if len(session.AuthorizationCode) != 40 {
+		t.Errorf("len(AuthorizationCode) = %d, want 40", len(session.AuthorizationCode))
+	}
+
+	if session.ExpiresAtTimestamp > timeutil.TimestampNow()+10 {
+		t.Errorf("ExpiresAtTimestamp = %d, want at most 10 seconds from now", session.ExpiresAtTimestamp)
+	}
+}
+
+func TestInitAuth_StateHash(t *testing.T) {
+	// Given.
+	ctx, client := setUpAuth(t)
+
+	req := request{
+		ClientID: client.ID,
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			RedirectURI:  client.RedirectURIs[0],
+			Scopes:       client.ScopeIDs,
+			ResponseType: goidc.ResponseTypeCodeAndIDToken,
+			ResponseMode: goidc.ResponseModeFragment,
+			Nonce:        "random_nonce",
+			State:        "random_state",
+		},
+	}
+
+	// When.
+	err := initAuth(ctx, req)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	redirectURL, err := url.Parse(ctx.Response.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("could not parse the redirect url: %v", err)
+	}
+	redirectParams, err := url.ParseQuery(redirectURL.Fragment)
+	if err != nil {
+		t.Fatalf("could not parse the redirect params: %v", err)
+	}
+
+	claims, err := oidctest.SafeClaims(redirectParams.Get("id_token"), ctx.PrivateJWKS.Keys[0])
+	if err != nil {
+		t.Fatalf("error parsing claims: %v", err)
+	}
+
+	if claims["s_hash"] != halfHash("random_state") {
+		t.Errorf("s_hash = %v, want %s", claims["s_hash"], halfHash("random_state"))
+	}
+
+	if !goidc.VerifyStateHash(claims, "random_state", jose.PS256) {
+		t.Error("the s_hash claim should be valid for the state")
+	}
+}
+
+func TestInitAuth_RecordConsent(t *testing.T) {
+	// Given.
+	ctx, client := setUpAuth(t)
+	ctx.ConsentIsEnabled = true
+	ctx.ConsentManager = storage.NewConsentManager()
+	ctx.Policies = []goidc.AuthnPolicy{
+		goidc.NewPolicy(
+			"random_policy_id",
+			func(r *http.Request, c *goidc.Client, as *goidc.AuthnSession) bool {
+				return true
+			},
+			func(w http.ResponseWriter, r *http.Request, as *goidc.AuthnSession) (goidc.AuthnStatus, error) {
+				as.SetUserID("random_subject")
+				as.GrantScopes(as.Scopes)
+				return goidc.StatusSuccess, nil
+			},
+		),
+	}
+
+	req := request{
+		ClientID: client.ID,
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			RedirectURI:  client.RedirectURIs[0],
+			Scopes:       client.ScopeIDs,
+			ResponseType: goidc.ResponseTypeCode,
+			ResponseMode: goidc.ResponseModeQuery,
+		},
+	}
+
+	// When.
+	err := initAuth(ctx, req)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	consent, err := ctx.Consent("random_subject", client.ID)
+	if err != nil {
+		t.Fatalf("the consent should be recorded: %v", err)
+	}
+
+	if consent.Scopes != client.ScopeIDs {
+		t.Errorf("Scopes = %s, want %s", consent.Scopes, client.ScopeIDs)
+	}
+}
+
 func TestInitAuth_JAR(t *testing.T) {
 	// Given.
 	ctx, client := setUpAuth(t)
@@ -183,6 +319,7 @@ func TestInitAuth_JAR(t *testing.T) {
 	}
 
 	wantedSession := goidc.AuthnSession{
+		ScopeInfos:         []goidc.ScopeInfo{{ID: "scope1"}, {ID: "scope2"}, {ID: "openid"}},
 		ID:                 session.ID,
 		PolicyID:           ctx.Policies[0].ID,
 		ExpiresAtTimestamp: session.ExpiresAtTimestamp,
@@ -314,6 +451,7 @@ func TestInitAuth_ResourceIndicator(t *testing.T) {
 	}
 
 	wantedSession := goidc.AuthnSession{
+		ScopeInfos:         []goidc.ScopeInfo{{ID: "scope1"}, {ID: "scope2"}, {ID: "openid"}},
 		ID:                 session.ID,
 		PolicyID:           ctx.Policies[0].ID,
 		ExpiresAtTimestamp: session.ExpiresAtTimestamp,
@@ -388,6 +526,7 @@ func TestInitAuth_IDTokenHint(t *testing.T) {
 	}
 
 	wantedSession := goidc.AuthnSession{
+		ScopeInfos:         []goidc.ScopeInfo{{ID: "scope1"}, {ID: "scope2"}, {ID: "openid"}},
 		ID:                 session.ID,
 		PolicyID:           ctx.Policies[0].ID,
 		ExpiresAtTimestamp: session.ExpiresAtTimestamp,
@@ -414,6 +553,58 @@ func TestInitAuth_IDTokenHint(t *testing.T) {
 	}
 }
 
+func TestInitAuth_IDTokenHintIssuedByIssuerAlias(t *testing.T) {
+	ctx, client := setUpAuth(t)
+	ctx.IssuerAliases = []string{"https://old.example.com"}
+
+	key, ok := ctx.UserSigKey()
+	if !ok {
+		t.Fatalf("could not find key to sign the id token: %s", ctx.UserDefaultSigAlg)
+	}
+
+	testCases := []struct {
+		issuer  string
+		wantErr bool
+	}{
+		{ctx.Host, false},
+		{"https://old.example.com", false},
+		{"https://other.example.com", true},
+	}
+
+	for _, testCase := range testCases {
+		idToken, err := jwtutil.Sign(
+			map[string]any{
+				goidc.ClaimIssuer:  testCase.issuer,
+				goidc.ClaimSubject: "random_user",
+			},
+			key,
+			(&jose.SignerOptions{}).WithType("jwt").WithHeader("kid", key.KeyID),
+		)
+		if err != nil {
+			t.Fatalf("could not sign the id token: %v", err)
+		}
+
+		req := request{
+			ClientID: client.ID,
+			AuthorizationParameters: goidc.AuthorizationParameters{
+				RedirectURI:  client.RedirectURIs[0],
+				Scopes:       client.ScopeIDs,
+				ResponseType: goidc.ResponseTypeCode,
+				IDTokenHint:  idToken,
+			},
+		}
+
+		// When.
+		err = initAuth(ctx, req)
+
+		// Then.
+		if (err != nil) != testCase.wantErr {
+			t.Errorf("initAuth() with hint issued by %s = %v, want error %t",
+				testCase.issuer, err, testCase.wantErr)
+		}
+	}
+}
+
 func TestInitAuth_ShouldNotFindClient(t *testing.T) {
 	// Given.
 	ctx := oidctest.NewContext(t)
@@ -535,6 +726,50 @@ func TestInitAuth_InvalidResponseType(t *testing.T) {
 	}
 }
 
+func TestInitAuth_NonceReplay(t *testing.T) {
+	// Given.
+	ctx, client := setUpAuth(t)
+	ctx.CheckNonceFunc = goidc.NewNonceCache().CheckNonce
+
+	req := request{
+		ClientID: client.ID,
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			RedirectURI:  client.RedirectURIs[0],
+			Scopes:       client.ScopeIDs,
+			ResponseType: goidc.ResponseTypeCodeAndIDToken,
+			ResponseMode: goidc.ResponseModeFragment,
+			Nonce:        "random_nonce",
+		},
+	}
+	if err := initAuth(ctx, req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ctx.Response = httptest.NewRecorder()
+
+	// When.
+	err := initAuth(ctx, req)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("the error should be redirected")
+	}
+
+	redirectURL, err := url.Parse(ctx.Response.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("could not parse the redirect url: %v", err)
+	}
+
+	redirectParams, err := url.ParseQuery(redirectURL.Fragment)
+	if err != nil {
+		t.Fatalf("could not parse the redirect params: %v", err)
+	}
+
+	if redirectParams.Get("error") != string(goidc.ErrorCodeInvalidRequest) {
+		t.Errorf("error code = %s, want %s", redirectParams.Get("error"),
+			goidc.ErrorCodeInvalidRequest)
+	}
+}
+
 func TestInitAuth_NoPolicyAvailable(t *testing.T) {
 	// Given.
 	ctx, client := setUpAuth(t)
@@ -616,6 +851,79 @@ func TestInitAuth_AuthnFailed(t *testing.T) {
 	}
 }
 
+func TestInitAuth_AuthnFailedWithRenderedError(t *testing.T) {
+	// Given.
+	ctx, client := setUpAuth(t)
+	policy := goidc.NewPolicy(
+		"policy_id",
+		func(r *http.Request, c *goidc.Client, as *goidc.AuthnSession) bool {
+			return true
+		},
+		func(w http.ResponseWriter, r *http.Request, as *goidc.AuthnSession) (goidc.AuthnStatus, error) {
+			return goidc.StatusFailure, goidc.NewError(goidc.ErrorCodeAccessDenied,
+				"the account is locked").WithHandling(goidc.ErrorHandlingRender)
+		},
+	)
+	ctx.Policies = []goidc.AuthnPolicy{policy}
+
+	req := request{
+		ClientID: client.ID,
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			RedirectURI:  client.RedirectURIs[0],
+			Scopes:       client.ScopeIDs,
+			ResponseType: goidc.ResponseTypeCode,
+			ResponseMode: goidc.ResponseModeQuery,
+		},
+	}
+
+	// When.
+	err := initAuth(ctx, req)
+
+	// Then.
+	var oidcErr goidc.Error
+	if !errors.As(err, &oidcErr) {
+		t.Fatalf("the error should be returned instead of redirected, got %v", err)
+	}
+
+	if oidcErr.Description != "the account is locked" {
+		t.Errorf("Description = %s, want the account is locked", oidcErr.Description)
+	}
+
+	if location := ctx.Response.Header().Get("Location"); location != "" {
+		t.Errorf("the error should not be redirected, got %s", location)
+	}
+
+	sessions := oidctest.AuthnSessions(t, ctx)
+	if len(sessions) != 0 {
+		t.Errorf("len(sessions) = %d, want 0", len(sessions))
+	}
+}
+
+func TestRenderError_JSONHandling(t *testing.T) {
+	// Given.
+	ctx := oidctest.NewContext(t)
+	rendered := false
+	ctx.RenderErrorFunc = func(http.ResponseWriter, *http.Request, error) error {
+		rendered = true
+		return nil
+	}
+	err := goidc.NewError(goidc.ErrorCodeAccessDenied, "access denied").
+		WithHandling(goidc.ErrorHandlingJSON)
+
+	// When.
+	renderError(ctx, err)
+
+	// Then.
+	if rendered {
+		t.Error("the error should not be rendered")
+	}
+
+	resp := ctx.Response.(*httptest.ResponseRecorder)
+	if resp.Code != http.StatusForbidden {
+		t.Errorf("Code = %d, want %d", resp.Code, http.StatusForbidden)
+	}
+}
+
 func TestInitAuth_ShouldEndInProgress(t *testing.T) {
 	// Given.
 	ctx, client := setUpAuth(t)
@@ -664,6 +972,7 @@ func TestInitAuth_ShouldEndInProgress(t *testing.T) {
 	}
 
 	wantedSession := goidc.AuthnSession{
+		ScopeInfos:         []goidc.ScopeInfo{{ID: "scope1"}, {ID: "scope2"}, {ID: "openid"}},
 		ID:                 session.ID,
 		PolicyID:           ctx.Policies[0].ID,
 		CallbackID:         session.CallbackID,
@@ -686,34 +995,28 @@ func TestInitAuth_ShouldEndInProgress(t *testing.T) {
 	}
 }
 
-func TestInitAuth_PAR(t *testing.T) {
+func TestInitAuth_PromptNone(t *testing.T) {
 	// Given.
 	ctx, client := setUpAuth(t)
-	ctx.PARIsEnabled = true
-
-	requestURI := "urn:ietf:params:oauth:request_uri:random_value"
-	_ = ctx.SaveAuthnSession(
-		&goidc.AuthnSession{
-			ID:          uuid.NewString(),
-			ReferenceID: requestURI,
-			AuthorizationParameters: goidc.AuthorizationParameters{
-				RequestURI:   requestURI,
-				Scopes:       client.ScopeIDs,
-				RedirectURI:  client.RedirectURIs[0],
-				ResponseType: goidc.ResponseTypeCode,
-			},
-			ClientID:           client.ID,
-			ExpiresAtTimestamp: timeutil.TimestampNow() + 60,
+	policy := goidc.NewPolicy(
+		"policy_id",
+		func(r *http.Request, c *goidc.Client, as *goidc.AuthnSession) bool {
+			return true
+		},
+		func(w http.ResponseWriter, r *http.Request, as *goidc.AuthnSession) (goidc.AuthnStatus, error) {
+			return goidc.StatusInProgress, nil
 		},
 	)
+	ctx.Policies = []goidc.AuthnPolicy{policy}
 
 	req := request{
 		ClientID: client.ID,
 		AuthorizationParameters: goidc.AuthorizationParameters{
-			RequestURI:   requestURI,
-			ResponseType: goidc.ResponseTypeCode,
+			RedirectURI:  client.RedirectURIs[0],
 			Scopes:       client.ScopeIDs,
-			State:        "random_state",
+			ResponseType: goidc.ResponseTypeCode,
+			ResponseMode: goidc.ResponseModeQuery,
+			Prompt:       goidc.PromptTypeNone,
 		},
 	}
 
@@ -722,20 +1025,283 @@ func TestInitAuth_PAR(t *testing.T) {
 
 	// Then.
 	if err != nil {
-		t.Fatalf("unexpected error: %v", err)
+		t.Fatalf("the error should be redirected")
 	}
 
-	sessions := oidctest.AuthnSessions(t, ctx)
-	if len(sessions) != 1 {
-		t.Fatalf("len(sessions) = %d, want 1", len(sessions))
+	redirectURL, err := url.Parse(ctx.Response.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("could not parse the redirect url: %v", err)
 	}
 
-	session := sessions[0]
-	if session.AuthorizationCode == "" {
-		t.Error("the authorization code should be set in the session")
+	if redirectURL.Query().Get("error") != string(goidc.ErrorCodeLoginRequired) {
+		t.Errorf("error code = %s, want %s", redirectURL.Query().Get("error"),
+			goidc.ErrorCodeLoginRequired)
+	}
+
+	sessions := oidctest.AuthnSessions(t, ctx)
+	if len(sessions) != 0 {
+		t.Errorf("len(sessions) = %d, want 0", len(sessions))
+	}
+}
+
+func TestInitAuth_PromptNone_PageRendered(t *testing.T) {
+	// Given.
+	ctx, client := setUpAuth(t)
+	policy := goidc.NewPolicy(
+		"policy_id",
+		func(r *http.Request, c *goidc.Client, as *goidc.AuthnSession) bool {
+			return true
+		},
+		func(w http.ResponseWriter, r *http.Request, as *goidc.AuthnSession) (goidc.AuthnStatus, error) {
+			w.Header().Set("Content-Type", "text/html")
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write([]byte("<html>random_page</html>"))
+			return goidc.StatusInProgress, nil
+		},
+	)
+	ctx.Policies = []goidc.AuthnPolicy{policy}
+
+	req := request{
+		ClientID: client.ID,
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			RedirectURI:  client.RedirectURIs[0],
+			Scopes:       client.ScopeIDs,
+			ResponseType: goidc.ResponseTypeCode,
+			ResponseMode: goidc.ResponseModeQuery,
+			Prompt:       goidc.PromptTypeNone,
+		},
+	}
+
+	// When.
+	err := initAuth(ctx, req)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("the error should be redirected")
+	}
+
+	resp := ctx.Response.(*httptest.ResponseRecorder)
+	if resp.Code != http.StatusSeeOther {
+		t.Errorf("status = %d, want %d", resp.Code, http.StatusSeeOther)
+	}
+
+	if strings.Contains(resp.Body.String(), "random_page") {
+		t.Errorf("the page rendered by the policy must not be sent: %s", resp.Body.String())
+	}
+
+	redirectURL, err := url.Parse(resp.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("could not parse the redirect url: %v", err)
+	}
+
+	if redirectURL.Query().Get("error") != string(goidc.ErrorCodeLoginRequired) {
+		t.Errorf("error code = %s, want %s", redirectURL.Query().Get("error"),
+			goidc.ErrorCodeLoginRequired)
+	}
+}
+
+func TestInitAuth_EssentialACRNotAchieved(t *testing.T) {
+	// Given.
+	ctx, client := setUpAuth(t)
+	ctx.ClaimsParamIsEnabled = true
+	ctx.Claims = []string{goidc.ClaimACR}
+	policy := goidc.NewPolicy(
+		"policy_id",
+		func(r *http.Request, c *goidc.Client, as *goidc.AuthnSession) bool {
+			return true
+		},
+		func(w http.ResponseWriter, r *http.Request, as *goidc.AuthnSession) (goidc.AuthnStatus, error) {
+			as.SetUserID("random_subject")
+			as.SetIDTokenClaimACR(goidc.ACRMaceIncommonIAPBronze)
+			as.GrantScopes(as.Scopes)
+			return goidc.StatusSuccess, nil
+		},
+	)
+	ctx.Policies = []goidc.AuthnPolicy{policy}
+
+	req := request{
+		ClientID: client.ID,
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			RedirectURI:  client.RedirectURIs[0],
+			Scopes:       client.ScopeIDs,
+			ResponseType: goidc.ResponseTypeCode,
+			ResponseMode: goidc.ResponseModeQuery,
+			Claims: &goidc.ClaimsObject{
+				IDToken: map[string]goidc.ClaimObjectInfo{
+					goidc.ClaimACR: {
+						IsEssential: true,
+						Value:       string(goidc.ACRMaceIncommonIAPSilver),
+					},
+				},
+			},
+		},
+	}
+
+	// When.
+	err := initAuth(ctx, req)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("the error should be redirected")
+	}
+
+	redirectURL, err := url.Parse(ctx.Response.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("could not parse the redirect url: %v", err)
+	}
+
+	if redirectURL.Query().Get("error") != string(goidc.ErrorCodeUnmetAuthnRequirements) {
+		t.Errorf("error code = %s, want %s", redirectURL.Query().Get("error"),
+			goidc.ErrorCodeUnmetAuthnRequirements)
+	}
+}
+
+func TestInitAuth_MaxAgeExceeded(t *testing.T) {
+	// Given.
+	ctx, client := setUpAuth(t)
+	policy := goidc.NewPolicy(
+		"policy_id",
+		func(r *http.Request, c *goidc.Client, as *goidc.AuthnSession) bool {
+			return true
+		},
+		func(w http.ResponseWriter, r *http.Request, as *goidc.AuthnSession) (goidc.AuthnStatus, error) {
+			as.SetUserID("random_subject")
+			as.SetIDTokenClaimAuthTime(timeutil.TimestampNow() - 120)
+			as.GrantScopes(as.Scopes)
+			return goidc.StatusSuccess, nil
+		},
+	)
+	ctx.Policies = []goidc.AuthnPolicy{policy}
+
+	maxAge := 60
+	req := request{
+		ClientID: client.ID,
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			RedirectURI:     client.RedirectURIs[0],
+			Scopes:          client.ScopeIDs,
+			ResponseType:    goidc.ResponseTypeCode,
+			ResponseMode:    goidc.ResponseModeQuery,
+			MaxAuthnAgeSecs: &maxAge,
+		},
+	}
+
+	// When.
+	err := initAuth(ctx, req)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("the error should be redirected")
+	}
+
+	redirectURL, err := url.Parse(ctx.Response.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("could not parse the redirect url: %v", err)
+	}
+
+	if redirectURL.Query().Get("error") != string(goidc.ErrorCodeLoginRequired) {
+		t.Errorf("error code = %s, want %s", redirectURL.Query().Get("error"),
+			goidc.ErrorCodeLoginRequired)
+	}
+}
+
+func TestInitAuth_MaxAgeWithoutAuthTime(t *testing.T) {
+	// Given.
+	ctx, client := setUpAuth(t)
+	policy := goidc.NewPolicy(
+		"policy_id",
+		func(r *http.Request, c *goidc.Client, as *goidc.AuthnSession) bool {
+			return true
+		},
+		func(w http.ResponseWriter, r *http.Request, as *goidc.AuthnSession) (goidc.AuthnStatus, error) {
+			as.SetUserID("random_subject")
+			as.GrantScopes(as.Scopes)
+			return goidc.StatusSuccess, nil
+		},
+	)
+	ctx.Policies = []goidc.AuthnPolicy{policy}
+
+	maxAge := 60
+	req := request{
+		ClientID: client.ID,
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			RedirectURI:     client.RedirectURIs[0],
+			Scopes:          client.ScopeIDs,
+			ResponseType:    goidc.ResponseTypeCode,
+			ResponseMode:    goidc.ResponseModeQuery,
+			MaxAuthnAgeSecs: &maxAge,
+		},
+	}
+
+	// When.
+	err := initAuth(ctx, req)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("the error should be redirected")
+	}
+
+	redirectURL, err := url.Parse(ctx.Response.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("could not parse the redirect url: %v", err)
+	}
+
+	if redirectURL.Query().Get("error") != string(goidc.ErrorCodeLoginRequired) {
+		t.Errorf("error code = %s, want %s", redirectURL.Query().Get("error"),
+			goidc.ErrorCodeLoginRequired)
+	}
+}
+
+func TestInitAuth_PAR(t *testing.T) {
+	// Given.
+	ctx, client := setUpAuth(t)
+	ctx.PARIsEnabled = true
+
+	requestURI := "urn:ietf:params:oauth:request_uri:random_value"
+	_ = ctx.SaveAuthnSession(
+		&goidc.AuthnSession{
+			ID:          uuid.NewString(),
+			ReferenceID: requestURI,
+			AuthorizationParameters: goidc.AuthorizationParameters{
+				RequestURI:   requestURI,
+				Scopes:       client.ScopeIDs,
+				RedirectURI:  client.RedirectURIs[0],
+				ResponseType: goidc.ResponseTypeCode,
+			},
+			ClientID:           client.ID,
+			ExpiresAtTimestamp: timeutil.TimestampNow() + 60,
+		},
+	)
+
+	req := request{
+		ClientID: client.ID,
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			RequestURI:   requestURI,
+			ResponseType: goidc.ResponseTypeCode,
+			Scopes:       client.ScopeIDs,
+			State:        "random_state",
+		},
+	}
+
+	// When.
+	err := initAuth(ctx, req)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	sessions := oidctest.AuthnSessions(t, ctx)
+	if len(sessions) != 1 {
+		t.Fatalf("len(sessions) = %d, want 1", len(sessions))
+	}
+
+	session := sessions[0]
+	if session.AuthorizationCode == "" {
+		t.Error("the authorization code should be set in the session")
 	}
 
 	wantedSession := goidc.AuthnSession{
+		ScopeInfos:         []goidc.ScopeInfo{{ID: "scope1"}, {ID: "scope2"}, {ID: "openid"}},
 		ID:                 session.ID,
 		PolicyID:           ctx.Policies[0].ID,
 		ExpiresAtTimestamp: session.ExpiresAtTimestamp,
@@ -769,6 +1335,55 @@ func TestInitAuth_PAR(t *testing.T) {
 	}
 }
 
+func TestInitAuth_PARRequiredByClient(t *testing.T) {
+	testCases := []struct {
+		name         string
+		parIsEnabled bool
+	}{
+		{"par_enabled", true},
+		{"par_not_enabled", false},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// Given.
+			ctx, client := setUpAuth(t)
+			ctx.PARIsEnabled = testCase.parIsEnabled
+			client.PARIsRequired = true
+
+			req := request{
+				ClientID: client.ID,
+				AuthorizationParameters: goidc.AuthorizationParameters{
+					RedirectURI:  client.RedirectURIs[0],
+					Scopes:       client.ScopeIDs,
+					ResponseType: goidc.ResponseTypeCode,
+				},
+			}
+
+			// When.
+			err := initAuth(ctx, req)
+
+			// Then.
+			if err == nil {
+				t.Fatal("the request must be rejected when par is not used")
+			}
+
+			var oidcErr goidc.Error
+			if !errors.As(err, &oidcErr) {
+				t.Fatalf("invalid error type: %v", err)
+			}
+
+			if oidcErr.Code != goidc.ErrorCodeInvalidRequest {
+				t.Errorf("Code = %s, want %s", oidcErr.Code, goidc.ErrorCodeInvalidRequest)
+			}
+
+			if sessions := oidctest.AuthnSessions(t, ctx); len(sessions) != 0 {
+				t.Errorf("len(sessions) = %d, want 0", len(sessions))
+			}
+		})
+	}
+}
+
 func TestContinueAuthentication(t *testing.T) {
 
 	// Given.
@@ -810,6 +1425,243 @@ func TestContinueAuthentication(t *testing.T) {
 	}
 }
 
+func TestContinueAuthentication_CallbackBinding(t *testing.T) {
+	// Given.
+	ctx, _ := setUpAuth(t)
+	policy := goidc.NewPolicy(
+		"policy_id",
+		func(r *http.Request, c *goidc.Client, as *goidc.AuthnSession) bool {
+			return true
+		},
+		func(w http.ResponseWriter, r *http.Request, as *goidc.AuthnSession) (goidc.AuthnStatus, error) {
+			return goidc.StatusInProgress, nil
+		},
+	)
+	ctx.Policies = []goidc.AuthnPolicy{policy}
+
+	callbackID := "random_callback_id"
+	_ = ctx.SaveAuthnSession(&goidc.AuthnSession{
+		PolicyID:           policy.ID,
+		CallbackID:         callbackID,
+		CallbackBinding:    "random_binding",
+		ExpiresAtTimestamp: timeutil.TimestampNow() + 60,
+	})
+	ctx.Request.AddCookie(&http.Cookie{
+		Name:  callbackBindingCookie,
+		Value: "random_binding",
+	})
+
+	// When.
+	err := continueAuth(ctx, callbackID)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestContinueAuthentication_CallbackBindingMismatch(t *testing.T) {
+	// Given.
+	ctx, _ := setUpAuth(t)
+	callbackID := "random_callback_id"
+	_ = ctx.SaveAuthnSession(&goidc.AuthnSession{
+		PolicyID:           "policy_id",
+		CallbackID:         callbackID,
+		CallbackBinding:    "random_binding",
+		ExpiresAtTimestamp: timeutil.TimestampNow() + 60,
+	})
+	ctx.Request.AddCookie(&http.Cookie{
+		Name:  callbackBindingCookie,
+		Value: "invalid_binding",
+	})
+
+	// When.
+	err := continueAuth(ctx, callbackID)
+
+	// Then.
+	if err == nil {
+		t.Fatal("the callback should be rejected")
+	}
+
+	var oidcErr goidc.Error
+	if !errors.As(err, &oidcErr) {
+		t.Fatal("invalid error type")
+	}
+
+	if oidcErr.Code != goidc.ErrorCodeInvalidRequest {
+		t.Errorf("error code = %s, want %s", oidcErr.Code, goidc.ErrorCodeInvalidRequest)
+	}
+}
+
+func TestResumeAuth(t *testing.T) {
+	// Given.
+	ctx, _ := setUpAuth(t)
+	policy := goidc.NewPolicy(
+		"policy_id",
+		func(r *http.Request, c *goidc.Client, as *goidc.AuthnSession) bool {
+			return true
+		},
+		func(w http.ResponseWriter, r *http.Request, as *goidc.AuthnSession) (goidc.AuthnStatus, error) {
+			as.ExtendExpiry(3600)
+			return goidc.StatusInProgress, nil
+		},
+	)
+	ctx.Policies = []goidc.AuthnPolicy{policy}
+
+	callbackID := "random_callback_id"
+	_ = ctx.SaveAuthnSession(&goidc.AuthnSession{
+		PolicyID:           policy.ID,
+		CallbackID:         callbackID,
+		CallbackBinding:    "random_binding",
+		ExpiresAtTimestamp: timeutil.TimestampNow() + 60,
+	})
+
+	// When.
+	err := ResumeAuth(ctx, callbackID)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	sessions := oidctest.AuthnSessions(t, ctx)
+	if len(sessions) != 1 {
+		t.Fatalf("len(sessions) = %d, want 1", len(sessions))
+	}
+
+	wantExpiry := timeutil.TimestampNow() + 3600
+	if sessions[0].ExpiresAtTimestamp != wantExpiry {
+		t.Errorf("ExpiresAtTimestamp = %d, want %d", sessions[0].ExpiresAtTimestamp, wantExpiry)
+	}
+}
+
+func TestResumeAuth_SessionExpired(t *testing.T) {
+	// Given.
+	ctx, _ := setUpAuth(t)
+	callbackID := "random_callback_id"
+	_ = ctx.SaveAuthnSession(&goidc.AuthnSession{
+		PolicyID:           "policy_id",
+		CallbackID:         callbackID,
+		ExpiresAtTimestamp: timeutil.TimestampNow() - 10,
+	})
+
+	// When.
+	err := ResumeAuth(ctx, callbackID)
+
+	// Then.
+	if err == nil {
+		t.Fatal("the session should not be resumed")
+	}
+
+	var oidcErr goidc.Error
+	if !errors.As(err, &oidcErr) {
+		t.Fatal("invalid error type")
+	}
+
+	if oidcErr.Code != goidc.ErrorCodeInvalidRequest {
+		t.Errorf("error code = %s, want %s", oidcErr.Code, goidc.ErrorCodeInvalidRequest)
+	}
+}
+
+func TestInitAuth_CallbackBinding(t *testing.T) {
+	// Given.
+	ctx, client := setUpAuth(t)
+	ctx.CallbackBindingIsEnabled = true
+	policy := goidc.NewPolicy(
+		"policy_id",
+		func(r *http.Request, c *goidc.Client, as *goidc.AuthnSession) bool {
+			return true
+		},
+		func(w http.ResponseWriter, r *http.Request, as *goidc.AuthnSession) (goidc.AuthnStatus, error) {
+			return goidc.StatusInProgress, nil
+		},
+	)
+	ctx.Policies = []goidc.AuthnPolicy{policy}
+
+	req := request{
+		ClientID: client.ID,
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			RedirectURI:  client.RedirectURIs[0],
+			Scopes:       client.ScopeIDs,
+			ResponseType: goidc.ResponseTypeCode,
+			ResponseMode: goidc.ResponseModeQuery,
+		},
+	}
+
+	// When.
+	err := initAuth(ctx, req)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	sessions := oidctest.AuthnSessions(t, ctx)
+	if len(sessions) != 1 {
+		t.Fatalf("len(sessions) = %d, want 1", len(sessions))
+	}
+	session := sessions[0]
+
+	cookies := ctx.Response.(*httptest.ResponseRecorder).Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("len(cookies) = %d, want 1", len(cookies))
+	}
+
+	if cookies[0].Value != session.CallbackBinding {
+		t.Errorf("cookie value = %s, want %s", cookies[0].Value, session.CallbackBinding)
+	}
+
+	wantPath := ctx.EndpointPrefix + ctx.EndpointAuthorize + "/" + session.CallbackID
+	if cookies[0].Path != wantPath {
+		t.Errorf("cookie path = %s, want %s", cookies[0].Path, wantPath)
+	}
+}
+
+func TestInitAuth_CallbackBindingFollowsSessionExpiry(t *testing.T) {
+	// Given.
+	ctx, client := setUpAuth(t)
+	ctx.CallbackBindingIsEnabled = true
+	policy := goidc.NewPolicy(
+		"policy_id",
+		func(r *http.Request, c *goidc.Client, as *goidc.AuthnSession) bool {
+			return true
+		},
+		func(w http.ResponseWriter, r *http.Request, as *goidc.AuthnSession) (goidc.AuthnStatus, error) {
+			as.ExtendExpiry(3600)
+			_, _ = w.Write([]byte("check your email"))
+			return goidc.StatusInProgress, nil
+		},
+	)
+	ctx.Policies = []goidc.AuthnPolicy{policy}
+
+	req := request{
+		ClientID: client.ID,
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			RedirectURI:  client.RedirectURIs[0],
+			Scopes:       client.ScopeIDs,
+			ResponseType: goidc.ResponseTypeCode,
+			ResponseMode: goidc.ResponseModeQuery,
+		},
+	}
+
+	// When.
+	err := initAuth(ctx, req)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cookies := ctx.Response.(*httptest.ResponseRecorder).Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("len(cookies) = %d, want 1", len(cookies))
+	}
+
+	if cookies[0].MaxAge < 3590 {
+		t.Errorf("cookie max age = %d, want the extended expiry of the session", cookies[0].MaxAge)
+	}
+}
+
 func setUpAuth(t *testing.T) (oidc.Context, *goidc.Client) {
 	t.Helper()
 
@@ -1,13 +1,16 @@
 package authorize
 
 const (
-	protectedParamPrefix          string = "p_"
-	callbackIDLength              int    = 20
-	parRequestURIPrefix           string = "urn:ietf:params:oauth:request_uri:"
-	parRequestURILength           int    = 20
-	authorizationCodeLength       int    = 30
-	authorizationCodeLifetimeSecs int    = 60 // TODO: Make it a config.
-	formPostResponseTemplate      string = `
+	protectedParamPrefix     string = "p_"
+	callbackIDLength         int    = 20
+	callbackBindingLength    int    = 30
+	callbackBindingCookie    string = "goidc_callback_binding"
+	userSessionIDLength      int    = 30
+	userSessionCookie        string = "goidc_user_session"
+	sessionIDLength          int    = 30
+	parRequestURIPrefix      string = "urn:ietf:params:oauth:request_uri:"
+	parRequestURILength      int    = 20
+	formPostResponseTemplate string = `
 	<!-- This HTML document is intended to be used as the response mode "form_post". -->
 	<!-- The parameters that are usually sent to the client via redirect will be sent by posting a form to the client's redirect URI. -->
 	<html>
@@ -20,10 +20,16 @@ func shouldUseJARDuringPAR(
 	req goidc.AuthorizationParameters,
 	c *goidc.Client,
 ) bool {
+	// A client that requires jar cannot fall back to plain parameters, even if
+	// jar is not enabled.
+	if c.JARIsRequired {
+		return true
+	}
+
 	if !ctx.JARIsEnabled {
 		return false
 	}
-	return ctx.JARIsRequired || c.JARIsRequired || req.RequestObject != ""
+	return ctx.JARIsRequired || req.RequestObject != ""
 }
 
 func shouldUseJAR(
@@ -31,13 +37,17 @@ func shouldUseJAR(
 	req goidc.AuthorizationParameters,
 	c *goidc.Client,
 ) bool {
+	if c.JARIsRequired {
+		return true
+	}
+
 	if !ctx.JARIsEnabled {
 		return false
 	}
 
 	// JAR was informed either by value or reference.
 	jarWasInformed := req.RequestObject != "" || (ctx.JARByReferenceIsEnabled && req.RequestURI != "")
-	return ctx.JARIsRequired || c.JARIsRequired || jarWasInformed
+	return ctx.JARIsRequired || jarWasInformed
 }
 
 func jarFromRequestURI(
@@ -87,6 +97,10 @@ func jarFromRequestObject(
 	}
 
 	if jwtutil.IsUnsignedJWT(reqObject) {
+		if c.JARIsRequired {
+			return request{}, goidc.NewError(goidc.ErrorCodeInvalidResquestObject,
+				"the request object must be signed")
+		}
 		return jarFromUnsignedRequestObject(ctx, reqObject, c)
 	}
 
@@ -108,7 +122,7 @@ func signedRequestObjectFromEncrypted(
 	}
 	encryptedReqObject, err := jose.ParseEncrypted(
 		reqObject,
-		ctx.JARKeyEncAlgs,
+		ctx.JARDecryptionKeyAlgs(),
 		contentEncAlgs,
 	)
 	if err != nil {
@@ -122,8 +136,8 @@ func signedRequestObjectFromEncrypted(
 			"invalid jwe key ID")
 	}
 
-	jwk, ok := ctx.PrivateKey(keyID)
-	if !ok || jwk.Use != string(goidc.KeyUsageEncryption) {
+	jwk, ok := ctx.JARDecryptionKey(keyID)
+	if !ok {
 		return "", goidc.NewError(goidc.ErrorCodeInvalidResquestObject,
 			"invalid jwk used for encryption")
 	}
@@ -248,7 +262,7 @@ func validateClaims(
 
 	err := claims.ValidateWithLeeway(jwt.Expected{
 		Issuer:      client.ID,
-		AnyAudience: []string{ctx.Host},
+		AnyAudience: ctx.RequestObjectAudiences(),
 	}, time.Duration(ctx.JARLeewayTimeSecs)*time.Second)
 	if err != nil {
 		return goidc.Errorf(goidc.ErrorCodeInvalidResquestObject,
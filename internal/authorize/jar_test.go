@@ -1,6 +1,7 @@
 package authorize
 
 import (
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -242,3 +243,170 @@ func TestJARFromRequestObject_Unsigned(t *testing.T) {
 		t.Error(diff)
 	}
 }
+
+func TestJARFromRequestObject_UnsignedNotAllowedForClient(t *testing.T) {
+	// Given.
+	ctx := oidc.Context{
+		Configuration: &oidc.Configuration{
+			Host:         "https://server.example.com",
+			JARIsEnabled: true,
+			JARSigAlgs: []jose.SignatureAlgorithm{
+				jose.PS256,
+				goidc.NoneSignatureAlgorithm,
+			},
+			JARLifetimeSecs: 60,
+		},
+		Request: &http.Request{Method: http.MethodPost},
+	}
+
+	client := &goidc.Client{
+		ClientMetaInfo: goidc.ClientMetaInfo{
+			JARIsRequired: true,
+		},
+	}
+
+	requestObject, _ := jwtutil.Unsigned(map[string]any{
+		"client_id":     client.ID,
+		"redirect_uri":  "https://example.com",
+		"response_type": goidc.ResponseTypeCode,
+	})
+
+	// When.
+	_, err := jarFromRequestObject(ctx, requestObject, client)
+
+	// Then.
+	var oidcErr goidc.Error
+	if !errors.As(err, &oidcErr) {
+		t.Fatalf("invalid error type: %v", err)
+	}
+
+	if oidcErr.Code != goidc.ErrorCodeInvalidResquestObject {
+		t.Errorf("Code = %s, want %s", oidcErr.Code, goidc.ErrorCodeInvalidResquestObject)
+	}
+}
+
+func TestJARFromRequestObject_PinnedSigAlg(t *testing.T) {
+	// Given.
+	privateJWK := oidctest.PrivateRS256JWK(t, "client_key_id",
+		goidc.KeyUsageSignature)
+	ctx := oidc.Context{
+		Configuration: &oidc.Configuration{
+			Host:         "https://server.example.com",
+			JARIsEnabled: true,
+			JARSigAlgs: []jose.SignatureAlgorithm{
+				jose.RS256,
+				jose.PS256,
+			},
+			JARLifetimeSecs: 60,
+		},
+		Request: &http.Request{Method: http.MethodPost},
+	}
+
+	client := &goidc.Client{
+		ClientMetaInfo: goidc.ClientMetaInfo{
+			PublicJWKS: oidctest.RawJWKS(privateJWK.Public()),
+			JARSigAlg:  jose.PS256,
+		},
+	}
+
+	now := timeutil.TimestampNow()
+	requestObject, _ := jwtutil.Sign(
+		map[string]any{
+			goidc.ClaimIssuer:   client.ID,
+			goidc.ClaimAudience: ctx.Host,
+			goidc.ClaimIssuedAt: now,
+			goidc.ClaimExpiry:   now + 10,
+			"client_id":         client.ID,
+			"redirect_uri":      "https://example.com",
+			"response_type":     goidc.ResponseTypeCode,
+		},
+		privateJWK,
+		(&jose.SignerOptions{}).WithType("jwt").WithHeader("kid", privateJWK.KeyID),
+	)
+
+	// When.
+	_, err := jarFromRequestObject(ctx, requestObject, client)
+
+	// Then.
+	var oidcErr goidc.Error
+	if !errors.As(err, &oidcErr) {
+		t.Fatalf("invalid error type: %v", err)
+	}
+
+	if oidcErr.Code != goidc.ErrorCodeInvalidResquestObject {
+		t.Errorf("Code = %s, want %s", oidcErr.Code, goidc.ErrorCodeInvalidResquestObject)
+	}
+}
+
+func TestJARFromRequestObject_Encrypted(t *testing.T) {
+	serverKey := oidctest.PrivateRSAOAEPJWK(t, "server_enc_key")
+	retiredKey := oidctest.PrivateRSAOAEPJWK(t, "retired_enc_key")
+	now := timeutil.TimestampNow()
+
+	testCases := []struct {
+		name        string
+		key         jose.JSONWebKey
+		retiredKeys []goidc.RetiredKey
+		wantErr     bool
+	}{
+		{"current key", serverKey, nil, false},
+		{"retired key", retiredKey, []goidc.RetiredKey{{Key: retiredKey, ExpiresAtTimestamp: now + 60}}, false},
+		{"expired retired key", retiredKey, []goidc.RetiredKey{{Key: retiredKey, ExpiresAtTimestamp: now - 1}}, true},
+		{"unknown key", retiredKey, nil, true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// Given.
+			ctx := oidc.Context{
+				Configuration: &oidc.Configuration{
+					Host:              "https://server.example.com",
+					PrivateJWKS:       jose.JSONWebKeySet{Keys: []jose.JSONWebKey{serverKey}},
+					JARIsEnabled:      true,
+					JARSigAlgs:        []jose.SignatureAlgorithm{goidc.NoneSignatureAlgorithm},
+					JARLifetimeSecs:   60,
+					JAREncIsEnabled:   true,
+					JARKeyEncAlgs:     []jose.KeyAlgorithm{jose.RSA_OAEP},
+					JARContentEncAlgs: []jose.ContentEncryption{jose.A128CBC_HS256},
+					JARRetiredEncKeys: testCase.retiredKeys,
+				},
+				Request: &http.Request{Method: http.MethodPost},
+			}
+
+			client := &goidc.Client{
+				ClientMetaInfo: goidc.ClientMetaInfo{
+					JARSigAlg: goidc.NoneSignatureAlgorithm,
+				},
+			}
+
+			requestObject, _ := jwtutil.Unsigned(map[string]any{
+				"client_id":     client.ID,
+				"redirect_uri":  "https://example.com",
+				"response_type": goidc.ResponseTypeCode,
+			})
+			encryptedRequestObject, err := jwtutil.Encrypt(requestObject, testCase.key.Public(), jose.A128CBC_HS256)
+			if err != nil {
+				t.Fatalf("could not encrypt the request object: %v", err)
+			}
+
+			// When.
+			jar, err := jarFromRequestObject(ctx, encryptedRequestObject, client)
+
+			// Then.
+			if testCase.wantErr {
+				if err == nil {
+					t.Fatal("the request object must not be decrypted")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if jar.RedirectURI != "https://example.com" {
+				t.Errorf("RedirectURI = %s, want https://example.com", jar.RedirectURI)
+			}
+		})
+	}
+}
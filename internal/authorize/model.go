@@ -7,6 +7,7 @@ import (
 	"strconv"
 
 	"github.com/google/uuid"
+	"github.com/luikyv/go-oidc/internal/oidc"
 	"github.com/luikyv/go-oidc/internal/timeutil"
 	"github.com/luikyv/go-oidc/pkg/goidc"
 )
@@ -161,11 +162,12 @@ type pushedResponse struct {
 
 // TODO: Should ask the expiry?
 func newAuthnSession(
+	ctx oidc.Context,
 	authParams goidc.AuthorizationParameters,
 	client *goidc.Client,
 ) *goidc.AuthnSession {
 	return &goidc.AuthnSession{
-		ID:                       uuid.NewString(),
+		ID:                       ctx.NewID(goidc.IDTypeAuthnSession, uuid.NewString),
 		ClientID:                 client.ID,
 		AuthorizationParameters:  authParams,
 		CreatedAtTimestamp:       timeutil.TimestampNow(),
@@ -1,6 +1,8 @@
 package authorize
 
 import (
+	"crypto/sha256"
+	"encoding/base64"
 	"strings"
 
 	"github.com/luikyv/go-oidc/internal/clientutil"
@@ -54,10 +56,11 @@ func pushAuthnSession(
 		return nil, err
 	}
 
-	session.ReferenceID = requestURI()
+	session.ReferenceID = requestURI(ctx)
 	session.ExpiresAtTimestamp = timeutil.TimestampNow() + ctx.PARLifetimeSecs
 
 	setDPoP(ctx, session)
+	setClientCert(ctx, session)
 
 	return session, nil
 }
@@ -88,7 +91,7 @@ func simplePushedAuthnSession(
 		return nil, err
 	}
 
-	session := newAuthnSession(req.AuthorizationParameters, client)
+	session := newAuthnSession(ctx, req.AuthorizationParameters, client)
 	session.ProtectedParameters = protectedParams(ctx)
 	return session, nil
 }
@@ -116,7 +119,7 @@ func pushedAuthnSessionWithJAR(
 		return nil, err
 	}
 
-	session := newAuthnSession(jar.AuthorizationParameters, client)
+	session := newAuthnSession(ctx, jar.AuthorizationParameters, client)
 	return session, nil
 }
 
@@ -133,8 +136,10 @@ func protectedParams(ctx oidc.Context) map[string]any {
 	return protectedParams
 }
 
-func requestURI() string {
-	return parRequestURIPrefix + strutil.Random(parRequestURILength)
+func requestURI(ctx oidc.Context) string {
+	return parRequestURIPrefix + ctx.NewID(goidc.IDTypeRequestURI, func() string {
+		return strutil.Random(parRequestURILength)
+	})
 }
 
 // setDPoP adds DPoP for authorization code to the session if available.
@@ -148,3 +153,19 @@ func setDPoP(ctx oidc.Context, session *goidc.AuthnSession) {
 		session.DPoPJWKThumbprint = dpop.JWKThumbprint(dpopJWT, ctx.DPoPSigAlgs)
 	}
 }
+
+// setClientCert binds the session to the client certificate if enabled.
+func setClientCert(ctx oidc.Context, session *goidc.AuthnSession) {
+	if !ctx.PARClientCertBindingIsEnabled {
+		return
+	}
+
+	if cert, err := ctx.ClientCert(); err == nil {
+		session.ClientCertThumbprint = hashBase64URLSHA256(string(cert.Raw))
+	}
+}
+
+func hashBase64URLSHA256(s string) string {
+	hash := sha256.Sum256([]byte(s))
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
@@ -1,8 +1,11 @@
 package authorize
 
 import (
+	"context"
+	"crypto/x509"
 	"encoding/json"
 	"errors"
+	"net/http"
 	"testing"
 
 	"github.com/go-jose/go-jose/v4"
@@ -69,6 +72,80 @@ func TestPushAuth(t *testing.T) {
 	}
 }
 
+func TestPushAuth_IDFunc(t *testing.T) {
+	// Given.
+	ctx, client := setUpPAR(t)
+	ctx.IDFunc = func(_ context.Context, idType goidc.IDType) string {
+		return "custom_" + string(idType)
+	}
+
+	req := request{
+		ClientID: client.ID,
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			RedirectURI:  client.RedirectURIs[0],
+			Scopes:       client.ScopeIDs,
+			ResponseType: goidc.ResponseTypeCode,
+			ResponseMode: goidc.ResponseModeQuery,
+		},
+	}
+
+	// When.
+	resp, err := pushAuth(ctx, req)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if resp.RequestURI != parRequestURIPrefix+"custom_request_uri" {
+		t.Errorf("RequestURI = %s, want %s", resp.RequestURI, parRequestURIPrefix+"custom_request_uri")
+	}
+
+	sessions := oidctest.AuthnSessions(t, ctx)
+	if len(sessions) != 1 {
+		t.Fatalf("len(sessions) = %d, want 1", len(sessions))
+	}
+
+	if sessions[0].ID != "custom_authn_session" {
+		t.Errorf("ID = %s, want custom_authn_session", sessions[0].ID)
+	}
+}
+
+func TestPushAuth_ClientCertBinding(t *testing.T) {
+	// Given.
+	ctx, client := setUpPAR(t)
+	ctx.PARClientCertBindingIsEnabled = true
+	ctx.ClientCertFunc = func(*http.Request) (*x509.Certificate, error) {
+		return &x509.Certificate{Raw: []byte("random_cert")}, nil
+	}
+
+	req := request{
+		ClientID: client.ID,
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			RedirectURI:  client.RedirectURIs[0],
+			Scopes:       client.ScopeIDs,
+			ResponseType: goidc.ResponseTypeCode,
+		},
+	}
+
+	// When.
+	_, err := pushAuth(ctx, req)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	sessions := oidctest.AuthnSessions(t, ctx)
+	if len(sessions) != 1 {
+		t.Fatalf("len(sessions) = %d, want 1", len(sessions))
+	}
+
+	if want := hashBase64URLSHA256("random_cert"); sessions[0].ClientCertThumbprint != want {
+		t.Errorf("ClientCertThumbprint = %s, want %s", sessions[0].ClientCertThumbprint, want)
+	}
+}
+
 func TestPushAuth_WithJAR(t *testing.T) {
 	// Given.
 	ctx, client := setUpPAR(t)
@@ -146,6 +223,51 @@ func TestPushAuth_WithJAR(t *testing.T) {
 	}
 }
 
+func TestPushAuth_JARRequiredByClient(t *testing.T) {
+	testCases := []struct {
+		name         string
+		jarIsEnabled bool
+	}{
+		{"jar_enabled", true},
+		{"jar_not_enabled", false},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// Given.
+			ctx, client := setUpPAR(t)
+			ctx.JARIsEnabled = testCase.jarIsEnabled
+			client.JARIsRequired = true
+
+			req := request{
+				ClientID: client.ID,
+				AuthorizationParameters: goidc.AuthorizationParameters{
+					RedirectURI:  client.RedirectURIs[0],
+					Scopes:       client.ScopeIDs,
+					ResponseType: goidc.ResponseTypeCode,
+				},
+			}
+
+			// When.
+			_, err := pushAuth(ctx, req)
+
+			// Then.
+			var oidcErr goidc.Error
+			if !errors.As(err, &oidcErr) {
+				t.Fatalf("invalid error type: %v", err)
+			}
+
+			if oidcErr.Code != goidc.ErrorCodeInvalidRequest {
+				t.Errorf("Code = %s, want %s", oidcErr.Code, goidc.ErrorCodeInvalidRequest)
+			}
+
+			if sessions := oidctest.AuthnSessions(t, ctx); len(sessions) != 0 {
+				t.Errorf("len(sessions) = %d, want 0", len(sessions))
+			}
+		})
+	}
+}
+
 func TestPushAuth_UnauthenticatedClient(t *testing.T) {
 	// Given.
 	ctx, client := setUpPAR(t)
@@ -163,10 +163,9 @@ func encryptJARMResponse(
 	string,
 	error,
 ) {
-	jwk, err := clientutil.JWKByAlg(ctx, c, string(c.JARMKeyEncAlg))
+	jwk, err := clientutil.EncJWK(ctx, c, c.JARMKeyEncAlg)
 	if err != nil {
-		return "", goidc.Errorf(goidc.ErrorCodeInvalidRequest,
-			"could not fetch the client encryption jwk for jarm", err)
+		return "", err
 	}
 
 	contentEncAlg := c.JARMContentEncAlg
@@ -0,0 +1,113 @@
+package authorize
+
+import (
+	"net/http"
+
+	"github.com/luikyv/go-oidc/internal/oidc"
+	"github.com/luikyv/go-oidc/internal/strutil"
+	"github.com/luikyv/go-oidc/internal/timeutil"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+// loadAccounts makes the accounts authenticated in the user agent available
+// to the authentication policy.
+func loadAccounts(ctx oidc.Context, session *goidc.AuthnSession) {
+	userSession, ok := currentUserSession(ctx)
+	if !ok {
+		return
+	}
+	session.Accounts = userSession.Accounts
+}
+
+// recordUserSession adds the user authenticated in the session to the user
+// session of the user agent, creating it if needed, and extends its lifetime.
+func recordUserSession(ctx oidc.Context, session *goidc.AuthnSession) error {
+	if !ctx.UserSessionIsEnabled || session.Subject == "" {
+		return nil
+	}
+
+	now := timeutil.TimestampNow()
+	userSession, ok := currentUserSession(ctx)
+	if !ok {
+		userSession = &goidc.UserSession{
+			ID: ctx.NewID(goidc.IDTypeUserSession, func() string {
+				return strutil.Random(userSessionIDLength)
+			}),
+			CreatedAtTimestamp: now,
+		}
+	}
+
+	account := goidc.Account{
+		Subject:  session.Subject,
+		AuthTime: now,
+		AMRs:     session.IDTokenClaimAMRs(),
+	}
+	// The claims may be numbers decoded from JSON by the storage.
+	switch authTime := session.AdditionalIDTokenClaims[goidc.ClaimAuthTime].(type) {
+	case int:
+		account.AuthTime = authTime
+	case float64:
+		account.AuthTime = int(authTime)
+	}
+	switch acr := session.AdditionalIDTokenClaims[goidc.ClaimACR].(type) {
+	case goidc.ACR:
+		account.ACR = acr
+	case string:
+		account.ACR = goidc.ACR(acr)
+	}
+
+	userSession.AddAccount(account)
+	if userSession.SessionID == "" {
+		userSession.SessionID = session.SessionID
+	}
+	userSession.ExpiresAtTimestamp = now + ctx.UserSessionLifetimeSecs
+	if err := ctx.SaveUserSession(userSession); err != nil {
+		return err
+	}
+
+	http.SetCookie(ctx.Response, &http.Cookie{
+		Name:     userSessionCookie,
+		Value:    userSession.ID,
+		Path:     ctx.EndpointPrefix + ctx.EndpointAuthorize,
+		MaxAge:   ctx.UserSessionLifetimeSecs,
+		Secure:   true,
+		HttpOnly: true,
+		// The flow may finish at the callback endpoint after a cross site form
+		// post, e.g. when the user comes back from an external identity
+		// provider, so the cookie must be sent in that case as well.
+		SameSite: http.SameSiteNoneMode,
+	})
+	return nil
+}
+
+// setSessionID defines the sid of the session if the policy didn't do it.
+// Flows in the same user session share the same sid.
+func setSessionID(ctx oidc.Context, session *goidc.AuthnSession) {
+	if session.SessionID != "" {
+		return
+	}
+
+	if userSession, ok := currentUserSession(ctx); ok && userSession.SessionID != "" {
+		session.SessionID = userSession.SessionID
+		return
+	}
+
+	session.SessionID = ctx.NewID(goidc.IDTypeSessionID, func() string {
+		return strutil.Random(sessionIDLength)
+	})
+}
+
+// currentUserSession returns the user session identified by the cookie of the
+// user agent, if it is still active.
+func currentUserSession(ctx oidc.Context) (*goidc.UserSession, bool) {
+	cookie, err := ctx.Request.Cookie(userSessionCookie)
+	if err != nil {
+		return nil, false
+	}
+
+	userSession, err := ctx.UserSession(cookie.Value)
+	if err != nil || userSession.IsExpired() {
+		return nil, false
+	}
+	return userSession, true
+}
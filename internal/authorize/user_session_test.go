@@ -0,0 +1,198 @@
+package authorize
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/luikyv/go-oidc/internal/storage"
+	"github.com/luikyv/go-oidc/internal/timeutil"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+func TestRecordUserSession(t *testing.T) {
+	// Given.
+	ctx, _ := setUpAuth(t)
+	ctx.UserSessionIsEnabled = true
+	ctx.UserSessionManager = storage.NewUserSessionManager()
+	ctx.UserSessionLifetimeSecs = 60
+
+	session := &goidc.AuthnSession{Subject: "random_subject"}
+	session.SetIDTokenClaimAuthTime(10)
+	session.SetIDTokenClaimAMR(goidc.AMRPassword)
+
+	// When.
+	err := recordUserSession(ctx, session)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cookies := ctx.Response.(*httptest.ResponseRecorder).Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != userSessionCookie {
+		t.Fatalf("the user session cookie was not set: %v", cookies)
+	}
+
+	userSession, err := ctx.UserSession(cookies[0].Value)
+	if err != nil {
+		t.Fatalf("the user session was not saved: %v", err)
+	}
+
+	account, ok := userSession.Account("random_subject")
+	if !ok {
+		t.Fatal("the account was not recorded")
+	}
+
+	if account.AuthTime != 10 {
+		t.Errorf("AuthTime = %d, want 10", account.AuthTime)
+	}
+
+	if len(account.AMRs) != 1 || account.AMRs[0] != goidc.AMRPassword {
+		t.Errorf("AMRs = %v, want [pwd]", account.AMRs)
+	}
+}
+
+func TestRecordUserSession_SessionID(t *testing.T) {
+	// Given.
+	ctx, _ := setUpAuth(t)
+	ctx.UserSessionIsEnabled = true
+	ctx.UserSessionManager = storage.NewUserSessionManager()
+	ctx.UserSessionLifetimeSecs = 60
+
+	session := &goidc.AuthnSession{Subject: "random_subject", SessionID: "random_sid"}
+
+	// When.
+	err := recordUserSession(ctx, session)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cookies := ctx.Response.(*httptest.ResponseRecorder).Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("the user session cookie was not set: %v", cookies)
+	}
+
+	userSession, err := ctx.UserSession(cookies[0].Value)
+	if err != nil {
+		t.Fatalf("the user session was not saved: %v", err)
+	}
+
+	if userSession.SessionID != "random_sid" {
+		t.Errorf("SessionID = %s, want random_sid", userSession.SessionID)
+	}
+
+	if userSession.SessionID == userSession.ID {
+		t.Error("the sid must not reveal the user session cookie")
+	}
+}
+
+func TestSetSessionID(t *testing.T) {
+	testCases := []struct {
+		name        string
+		session     *goidc.AuthnSession
+		userSession *goidc.UserSession
+		want        string
+	}{
+		{
+			"defined_by_the_policy",
+			&goidc.AuthnSession{SessionID: "policy_sid"},
+			&goidc.UserSession{SessionID: "random_sid"},
+			"policy_sid",
+		},
+		{
+			"shared_by_the_user_session",
+			&goidc.AuthnSession{},
+			&goidc.UserSession{SessionID: "random_sid"},
+			"random_sid",
+		},
+		{
+			"generated",
+			&goidc.AuthnSession{},
+			nil,
+			"",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// Given.
+			ctx, _ := setUpAuth(t)
+			ctx.UserSessionIsEnabled = true
+			ctx.UserSessionManager = storage.NewUserSessionManager()
+			if testCase.userSession != nil {
+				testCase.userSession.ID = "random_user_session"
+				testCase.userSession.ExpiresAtTimestamp = timeutil.TimestampNow() + 60
+				_ = ctx.SaveUserSession(testCase.userSession)
+				ctx.Request.AddCookie(&http.Cookie{
+					Name:  userSessionCookie,
+					Value: "random_user_session",
+				})
+			}
+
+			// When.
+			setSessionID(ctx, testCase.session)
+
+			// Then.
+			if testCase.session.SessionID == "" {
+				t.Fatal("the sid must be set")
+			}
+
+			if testCase.want != "" && testCase.session.SessionID != testCase.want {
+				t.Errorf("SessionID = %s, want %s", testCase.session.SessionID, testCase.want)
+			}
+		})
+	}
+}
+
+func TestLoadAccounts(t *testing.T) {
+	// Given.
+	ctx, _ := setUpAuth(t)
+	ctx.UserSessionIsEnabled = true
+	ctx.UserSessionManager = storage.NewUserSessionManager()
+	_ = ctx.SaveUserSession(&goidc.UserSession{
+		ID:                 "random_user_session",
+		Accounts:           []goidc.Account{{Subject: "random_subject"}},
+		ExpiresAtTimestamp: timeutil.TimestampNow() + 60,
+	})
+	ctx.Request.AddCookie(&http.Cookie{
+		Name:  userSessionCookie,
+		Value: "random_user_session",
+	})
+	session := &goidc.AuthnSession{}
+
+	// When.
+	loadAccounts(ctx, session)
+
+	// Then.
+	if len(session.Accounts) != 1 || session.Accounts[0].Subject != "random_subject" {
+		t.Errorf("Accounts = %v, want [random_subject]", session.Accounts)
+	}
+}
+
+func TestLoadAccounts_ExpiredUserSession(t *testing.T) {
+	// Given.
+	ctx, _ := setUpAuth(t)
+	ctx.UserSessionIsEnabled = true
+	ctx.UserSessionManager = storage.NewUserSessionManager()
+	_ = ctx.SaveUserSession(&goidc.UserSession{
+		ID:                 "random_user_session",
+		Accounts:           []goidc.Account{{Subject: "random_subject"}},
+		ExpiresAtTimestamp: timeutil.TimestampNow() - 1,
+	})
+	ctx.Request.AddCookie(&http.Cookie{
+		Name:  userSessionCookie,
+		Value: "random_user_session",
+	})
+	session := &goidc.AuthnSession{}
+
+	// When.
+	loadAccounts(ctx, session)
+
+	// Then.
+	if len(session.Accounts) != 0 {
+		t.Errorf("Accounts = %v, want none", session.Accounts)
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"net/url"
 	"slices"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/go-jose/go-jose/v4/jwt"
 	"github.com/luikyv/go-oidc/internal/clientutil"
@@ -196,6 +198,14 @@ func validateInWithOutParams(
 		return err
 	}
 
+	// The code cannot be bound to a key other than the one informed during
+	// PAR or inside the request object.
+	if inParams.DPoPJWKThumbprint != "" && outParams.DPoPJWKThumbprint != "" &&
+		inParams.DPoPJWKThumbprint != outParams.DPoPJWKThumbprint {
+		return newRedirectionError(goidc.ErrorCodeInvalidRequest,
+			"invalid dpop_jkt", mergedParams)
+	}
+
 	// Make sure all the outter parameters parameters are valid even if they are
 	// not used.
 	if err := validateParamsAsOptionals(ctx, outParams, c); err != nil {
@@ -288,51 +298,69 @@ func validateParamsAsOptionals(
 		return err
 	}
 
-	if err := validateRequestURIAsOptional(ctx, params, c); err != nil {
-		return err
-	}
-
-	if err := validateScopesAsOptional(ctx, params, c); err != nil {
-		return err
-	}
-
-	if err := validateResponseTypeAsOptional(ctx, params, c); err != nil {
-		return err
-	}
-
-	if err := validateResponseModeAsOptional(ctx, params, c); err != nil {
-		return err
-	}
-
-	if err := validateCodeChallengeMethodAsOptional(ctx, params, c); err != nil {
+	if err := runValidations(ctx, params, c,
+		validateRequestURIAsOptional,
+		validateScopesAsOptional,
+		validateResponseTypeAsOptional,
+		validateResponseModeAsOptional,
+		validateCodeChallengeMethodAsOptional,
+		validateAuthorizationDetailsAsOptional,
+		validateACRValuesAsOptional,
+		validateResourcesAsOptional,
+		validateIDTokenHintAsOptional,
+		validateDisplayValueAsOptional,
+		validateDPoPJWKThumbprintAsOptional,
+	); err != nil {
 		return err
 	}
 
-	if err := validateAuthorizationDetailsAsOptional(ctx, params, c); err != nil {
-		return err
-	}
-
-	if err := validateACRValuesAsOptional(ctx, params, c); err != nil {
-		return err
+	if params.RequestURI != "" && params.RequestObject != "" {
+		return newRedirectionError(goidc.ErrorCodeInvalidRequest,
+			"cannot inform a request object and request_uri at the same time", params)
 	}
 
-	if err := validateResourcesAsOptional(ctx, params, c); err != nil {
-		return err
-	}
+	return nil
+}
 
-	if err := validateIDTokenHintAsOptional(ctx, params, c); err != nil {
-		return err
+// runValidations executes the validations informed and returns the error of
+// the first one failing in the order they were informed.
+// If parallel validation is enabled, the validations run concurrently, so they
+// must not modify the parameters or the client.
+func runValidations(
+	ctx oidc.Context,
+	params goidc.AuthorizationParameters,
+	c *goidc.Client,
+	validations ...func(
+		ctx oidc.Context,
+		params goidc.AuthorizationParameters,
+		c *goidc.Client,
+	) error,
+) error {
+	if !ctx.ParallelValidationIsEnabled {
+		for _, validation := range validations {
+			if err := validation(ctx, params, c); err != nil {
+				return err
+			}
+		}
+		return nil
 	}
 
-	if err := validateDisplayValueAsOptional(ctx, params, c); err != nil {
-		return err
+	errs := make([]error, len(validations))
+	var wg sync.WaitGroup
+	for i, validation := range validations {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = validation(ctx, params, c)
+		}()
 	}
+	wg.Wait()
 
-	if params.RequestURI != "" && params.RequestObject != "" {
-		return newRedirectionError(goidc.ErrorCodeInvalidRequest,
-			"cannot inform a request object and request_uri at the same time", params)
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
 	}
-
 	return nil
 }
 
@@ -389,7 +417,7 @@ func validateCodeChallengeMethodAsOptional(
 		return nil
 	}
 
-	if !slices.Contains(ctx.PKCEChallengeMethods, params.CodeChallengeMethod) {
+	if !slices.Contains(ctx.CodeChallengeMethods(), params.CodeChallengeMethod) {
 		return newRedirectionError(goidc.ErrorCodeInvalidRequest,
 			"invalid code_challenge_method", params)
 	}
@@ -596,10 +624,23 @@ func validateIDTokenHintAsOptional(
 		return goidc.NewError(goidc.ErrorCodeInvalidRequest, "invalid id token hint")
 	}
 
-	if err := parsedIDToken.Claims(publicKey); err != nil {
+	var claims jwt.Claims
+	if err := parsedIDToken.Claims(publicKey.Key, &claims); err != nil {
 		return goidc.Errorf(goidc.ErrorCodeInvalidRequest, "invalid id token hint", err)
 	}
 
+	// Expired ID tokens are still valid hints about the user, so only the
+	// time claims that must be in the past are validated.
+	claims.Expiry = nil
+	if err := claims.ValidateWithLeeway(jwt.Expected{},
+		time.Duration(ctx.IDTokenHintLeewayTimeSecs)*time.Second); err != nil {
+		return goidc.Errorf(goidc.ErrorCodeInvalidRequest, "invalid id token hint", err)
+	}
+
+	if claims.Issuer != "" && !slices.Contains(ctx.Issuers(), claims.Issuer) {
+		return goidc.NewError(goidc.ErrorCodeInvalidRequest, "invalid id token hint issuer")
+	}
+
 	return nil
 }
 
@@ -631,6 +672,25 @@ func isAuthDetailTypeAllowed(c *goidc.Client, authDetailType string) bool {
 	return slices.Contains(c.AuthDetailTypes, authDetailType)
 }
 
+func validateDPoPJWKThumbprintAsOptional(
+	ctx oidc.Context,
+	params goidc.AuthorizationParameters,
+	_ *goidc.Client,
+) error {
+	if params.DPoPJWKThumbprint == "" {
+		return nil
+	}
+
+	// Binding the code to a key that can never be proved would make the code
+	// unusable, so reject it upfront.
+	if !ctx.DPoPIsEnabled {
+		return newRedirectionError(goidc.ErrorCodeInvalidRequest,
+			"dpop_jkt is not supported", params)
+	}
+
+	return nil
+}
+
 func validateCodeBindingDPoP(
 	ctx oidc.Context,
 	params goidc.AuthorizationParameters,
@@ -103,6 +103,44 @@ func TestValidateRequest_InvalidScope(t *testing.T) {
 	}
 }
 
+func TestValidateRequest_ParallelValidation(t *testing.T) {
+	// Given.
+	ctx := oidctest.NewContext(t)
+	ctx.ParallelValidationIsEnabled = true
+	client, _ := oidctest.NewClient(t)
+	client.ResponseTypes = nil
+
+	req := request{
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			RedirectURI:  client.RedirectURIs[0],
+			ResponseType: goidc.ResponseTypeCode,
+			ResponseMode: goidc.ResponseModeQuery,
+			Scopes:       "invalid_scope",
+			State:        "random_state",
+			Nonce:        "random_nonce",
+		},
+	}
+
+	// When.
+	err := validateRequest(ctx, req, client)
+
+	// Then.
+	if err == nil {
+		t.Fatalf("no error for invalid scope and response type")
+	}
+
+	var redirectErr redirectionError
+	if !errors.As(err, &redirectErr) {
+		t.Fatalf("the error should be redirected")
+	}
+
+	// The scopes are validated before the response type, so its error wins
+	// regardless of which validation finishes first.
+	if redirectErr.code != goidc.ErrorCodeInvalidScope {
+		t.Errorf("code = %s, want %s", redirectErr.code, goidc.ErrorCodeInvalidScope)
+	}
+}
+
 func TestValidateRequest_InvalidRedirectURI(t *testing.T) {
 	// Given.
 	ctx := oidctest.NewContext(t)
@@ -226,6 +264,37 @@ func TestValidateRequest_PAR(t *testing.T) {
 	}
 }
 
+func TestValidateRequest_PAR_DPoPJWKThumbprintMismatch(t *testing.T) {
+	// Given.
+	ctx := oidctest.NewContext(t)
+	ctx.DPoPIsEnabled = true
+	client, _ := oidctest.NewClient(t)
+	session := &goidc.AuthnSession{
+		ClientID:           client.ID,
+		ExpiresAtTimestamp: timeutil.TimestampNow() + 10,
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			RedirectURI:       client.RedirectURIs[0],
+			ResponseType:      goidc.ResponseTypeCode,
+			DPoPJWKThumbprint: "random_jkt",
+		},
+	}
+	req := request{
+		ClientID: client.ID,
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			ResponseType:      goidc.ResponseTypeCode,
+			DPoPJWKThumbprint: "other_jkt",
+		},
+	}
+
+	// When.
+	err := validateRequestWithPAR(ctx, req, session, client)
+
+	// Then.
+	if err == nil {
+		t.Fatal("the dpop_jkt informed during PAR cannot be replaced")
+	}
+}
+
 func TestValidateRequest_JAR(t *testing.T) {
 	// Given.
 	ctx := oidctest.NewContext(t)
@@ -309,6 +378,29 @@ func TestValidatePushedRequest(t *testing.T) {
 	}
 }
 
+func TestValidatePushedRequest_DPoPJWKThumbprintWithDPoPDisabled(t *testing.T) {
+	// Given.
+	ctx := oidctest.NewContext(t)
+	client, _ := oidctest.NewClient(t)
+
+	req := request{
+		ClientID: client.ID,
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			RedirectURI:       client.RedirectURIs[0],
+			ResponseType:      goidc.ResponseTypeCode,
+			DPoPJWKThumbprint: "random_jkt",
+		},
+	}
+
+	// When.
+	err := validatePushedRequest(ctx, req, client)
+
+	// Then.
+	if err == nil {
+		t.Fatal("dpop_jkt cannot be informed when dpop is disabled")
+	}
+}
+
 func TestValidatePushedRequest_RedirectURIIsRequiredForFAPI2(t *testing.T) {
 	// Given.
 	ctx := oidctest.NewContext(t)
@@ -8,7 +8,9 @@ import (
 	"crypto/sha1"
 	"crypto/sha256"
 	"crypto/x509"
+	"errors"
 	"fmt"
+	"net/netip"
 	"slices"
 	"time"
 
@@ -43,26 +45,56 @@ func Authenticated(
 	ctx oidc.Context,
 	authnCtx AuthnContext,
 ) (
-	*goidc.Client,
-	error,
+	_ *goidc.Client,
+	err error,
 ) {
+	ctx, span := ctx.StartSpan("client.Authenticate")
+	defer func() {
+		if err != nil {
+			ctx.RecordClientAuthnFailure(err)
+		}
+		oidc.EndSpan(span, err)
+	}()
+
 	id, err := extractID(ctx)
 	if err != nil {
 		return nil, goidc.Errorf(goidc.ErrorCodeInvalidClient,
 			"invalid client", err)
 	}
 
+	if err := ctx.BeforeClientAuthn(id); err != nil {
+		var oidcErr goidc.Error
+		if errors.As(err, &oidcErr) {
+			return nil, oidcErr
+		}
+		return nil, goidc.Errorf(goidc.ErrorCodeInvalidClient,
+			"could not authenticate the client", err)
+	}
 	client, err := ctx.Client(id)
 	if err != nil {
 		return nil, goidc.Errorf(goidc.ErrorCodeInvalidClient,
 			"client not found", err)
 	}
+	// Only the results for registered clients are informed, otherwise any
+	// client ID could be used to fill the failures kept.
+	defer func() { ctx.AfterClientAuthn(id, err) }()
 
+	if authnCtx == TokenAuthnContext {
+		if err := validateSourceIP(ctx, client); err != nil {
+			return nil, err
+		}
+	}
+
+	span.SetAttributes(
+		goidc.Attribute{Key: goidc.AttributeClientID, Value: client.ID},
+		goidc.Attribute{Key: goidc.AttributeClientAuthn, Value: string(authnMethod(client, authnCtx))},
+	)
 	if err := authenticate(ctx, client, authnCtx); err != nil {
 		return nil, goidc.Errorf(goidc.ErrorCodeInvalidClient,
 			"could not authenticate the client", err)
 	}
 
+	ctx.SetAuthenticatedClient(client)
 	return client, nil
 }
 
@@ -88,10 +120,14 @@ func authenticate(
 		return authenticateSelfSignedTLSCert(ctx, client)
 	case goidc.ClientAuthnTLS:
 		return authenticateTLSCert(ctx, client)
-	default:
-		return goidc.NewError(goidc.ErrorCodeInvalidClient,
-			fmt.Sprintf("invalid authentication method %s for %s request", method, authnCtx))
 	}
+
+	if f, ok := ctx.ClientAuthnFunc(method); ok {
+		return f(ctx.Request, client)
+	}
+
+	return goidc.NewError(goidc.ErrorCodeInvalidClient,
+		fmt.Sprintf("invalid authentication method %s for %s request", method, authnCtx))
 }
 
 // authnMethod returns the appropriate client authentication method based on
@@ -327,7 +363,7 @@ func areClaimsValid(
 		Issuer:      client.ID,
 		Subject:     client.ID,
 		AnyAudience: ctx.AssertionAudiences(),
-	}, time.Duration(0))
+	}, time.Duration(ctx.AssertionLeewayTimeSecs)*time.Second)
 	if err != nil {
 		return goidc.Errorf(goidc.ErrorCodeInvalidClient, "invalid assertion", err)
 	}
@@ -400,6 +436,11 @@ func authenticateTLSCert(
 			"invalid client certificate", err)
 	}
 
+	if err := verifyTLSCert(ctx, c, cert); err != nil {
+		return goidc.Errorf(goidc.ErrorCodeInvalidClient,
+			"invalid client certificate", err)
+	}
+
 	switch {
 	case c.TLSSubDistinguishedName != "":
 		if c.TLSSubDistinguishedName != cert.Subject.String() {
@@ -416,6 +457,79 @@ func authenticateTLSCert(
 	return nil
 }
 
+// validateSourceIP makes sure the request comes from one of the networks the
+// client is allowed to use, if any is defined.
+func validateSourceIP(ctx oidc.Context, client *goidc.Client) error {
+	if len(client.AllowedSourceCIDRs) == 0 {
+		return nil
+	}
+
+	ip, err := ctx.ClientIP()
+	if err != nil {
+		return goidc.Errorf(goidc.ErrorCodeInvalidClient,
+			"could not identify the client address", err)
+	}
+
+	for _, cidr := range client.AllowedSourceCIDRs {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err == nil && prefix.Contains(ip) {
+			return nil
+		}
+	}
+
+	return goidc.NewError(goidc.ErrorCodeInvalidClient,
+		"the client is not allowed to make requests from this address")
+}
+
+// verifyTLSCert verifies the chain, the key usage and the revocation status of
+// the client certificate according to the TLS client certificate policy.
+func verifyTLSCert(ctx oidc.Context, c *goidc.Client, cert *x509.Certificate) error {
+	policy := ctx.TLSClientCertPolicy
+	if policy == nil {
+		return nil
+	}
+
+	if cert.KeyUsage != 0 && cert.KeyUsage&x509.KeyUsageDigitalSignature == 0 {
+		return errors.New("the certificate cannot be used for digital signatures")
+	}
+
+	opts := x509.VerifyOptions{
+		Intermediates: x509.NewCertPool(),
+		CurrentTime:   timeutil.Now(),
+		KeyUsages:     policy.ExtKeyUsages,
+	}
+	if len(opts.KeyUsages) == 0 {
+		opts.KeyUsages = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	}
+	if policy.Intermediates != nil {
+		opts.Intermediates = policy.Intermediates.Clone()
+	}
+	if ctx.Request.TLS != nil && len(ctx.Request.TLS.PeerCertificates) > 1 {
+		for _, intermediate := range ctx.Request.TLS.PeerCertificates[1:] {
+			opts.Intermediates.AddCert(intermediate)
+		}
+	}
+
+	if policy.RootCAsFunc != nil {
+		roots, err := policy.RootCAsFunc(ctx, c)
+		if err != nil {
+			return err
+		}
+		opts.Roots = roots
+	}
+
+	chains, err := cert.Verify(opts)
+	if err != nil {
+		return err
+	}
+
+	if policy.CheckRevocationFunc == nil {
+		return nil
+	}
+
+	return policy.CheckRevocationFunc(ctx, chains[0])
+}
+
 // extractID extracts a client ID from the request.
 // It looks to all places where an ID can be informed such as the basic
 // authentication header and the post form field 'client_id'.
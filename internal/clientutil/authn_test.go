@@ -1,11 +1,17 @@
 package clientutil_test
 
 import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"errors"
+	"math/big"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/go-jose/go-jose/v4"
 	"github.com/go-jose/go-jose/v4/jwt"
@@ -72,6 +78,47 @@ func TestAuthenticated_NoneAuthn(t *testing.T) {
 	}
 }
 
+func TestAuthenticated_CustomAuthn(t *testing.T) {
+
+	// Given.
+	ctx := oidctest.NewContext(t)
+	method := goidc.ClientAuthnType("attest_jwt_client_auth")
+	ctx.ClientAuthnFuncs = map[goidc.ClientAuthnType]goidc.ClientAuthnFunc{
+		method: func(r *http.Request, c *goidc.Client) error {
+			if r.Header.Get("OAuth-Client-Attestation") != "valid_attestation" {
+				return errors.New("invalid attestation")
+			}
+			return nil
+		},
+	}
+
+	c := &goidc.Client{
+		ID: "random_client_id",
+		ClientMetaInfo: goidc.ClientMetaInfo{
+			TokenAuthnMethod: method,
+		},
+	}
+	ctx.Request.PostForm = map[string][]string{"client_id": {c.ID}}
+	_ = ctx.SaveClient(c)
+
+	// When.
+	_, err := clientutil.Authenticated(ctx, clientutil.TokenAuthnContext)
+
+	// Then.
+	if err == nil {
+		t.Fatal("The client should not be authenticated without the attestation")
+	}
+
+	// When.
+	ctx.Request.Header.Set("OAuth-Client-Attestation", "valid_attestation")
+	_, err = clientutil.Authenticated(ctx, clientutil.TokenAuthnContext)
+
+	// Then.
+	if err != nil {
+		t.Errorf("The client should be authenticated, but error was found: %v", err)
+	}
+}
+
 func TestAuthenticated_SecretPostAuthn(t *testing.T) {
 
 	// Given.
@@ -174,6 +221,132 @@ func TestAuthenticated_SecretPostAuthn_InvalidID(t *testing.T) {
 	}
 }
 
+func TestAuthenticated_AllowedSourceCIDRs(t *testing.T) {
+	// Given.
+	ctx, client, secret := setUpSecretAuthn(t, goidc.ClientAuthnSecretPost)
+	client.AllowedSourceCIDRs = []string{"192.0.2.0/24"}
+	ctx.Request.RemoteAddr = "192.0.2.10:1234"
+	ctx.Request.PostForm = map[string][]string{
+		"client_id":     {client.ID},
+		"client_secret": {secret},
+	}
+
+	// When.
+	_, err := clientutil.Authenticated(ctx, clientutil.TokenAuthnContext)
+
+	// Then.
+	if err != nil {
+		t.Errorf("The client should be authenticated, but error was found: %v", err)
+	}
+}
+
+func TestAuthenticated_AllowedSourceCIDRs_AddressNotAllowed(t *testing.T) {
+	// Given.
+	ctx, client, secret := setUpSecretAuthn(t, goidc.ClientAuthnSecretPost)
+	client.AllowedSourceCIDRs = []string{"192.0.2.0/24"}
+	ctx.Request.RemoteAddr = "198.51.100.10:1234"
+	ctx.Request.PostForm = map[string][]string{
+		"client_id":     {client.ID},
+		"client_secret": {secret},
+	}
+
+	// When.
+	_, err := clientutil.Authenticated(ctx, clientutil.TokenAuthnContext)
+
+	// Then.
+	var oidcErr goidc.Error
+	if !errors.As(err, &oidcErr) {
+		t.Fatalf("error must be of type goidc.Error, got %v", err)
+	}
+
+	if oidcErr.Code != goidc.ErrorCodeInvalidClient {
+		t.Errorf("error code = %s, want %s", oidcErr.Code, goidc.ErrorCodeInvalidClient)
+	}
+}
+
+func TestAuthenticated_ClientAuthnHooks(t *testing.T) {
+	// Given.
+	ctx, client, _ := setUpSecretAuthn(t, goidc.ClientAuthnSecretPost)
+	ctx.Request.PostForm = map[string][]string{
+		"client_id":     {client.ID},
+		"client_secret": {"invalid_secret"},
+	}
+
+	ctx.ClientAuthnFailures = oidc.NewClientAuthnFailures(time.Hour, 10)
+
+	var afterErr error
+	var afterFailures int
+	ctx.AfterClientAuthnFunc = func(_ *http.Request, id string, failures int, err error) {
+		if id == client.ID {
+			afterErr, afterFailures = err, failures
+		}
+	}
+
+	// When.
+	_, err := clientutil.Authenticated(ctx, clientutil.TokenAuthnContext)
+	_, _ = clientutil.Authenticated(ctx, clientutil.TokenAuthnContext)
+
+	// Then.
+	if err == nil || afterErr == nil {
+		t.Fatalf("the failure must be informed to the hook, err = %v, afterErr = %v", err, afterErr)
+	}
+
+	if afterFailures != 2 {
+		t.Errorf("failures = %d, want 2", afterFailures)
+	}
+
+	// Given the client is locked.
+	var beforeFailures int
+	ctx.BeforeClientAuthnFunc = func(_ *http.Request, _ string, failures int) error {
+		beforeFailures = failures
+		return goidc.NewError(goidc.ErrorCodeSlowDown, "locked")
+	}
+
+	// When.
+	_, err = clientutil.Authenticated(ctx, clientutil.TokenAuthnContext)
+
+	// Then.
+	var oidcErr goidc.Error
+	if !errors.As(err, &oidcErr) {
+		t.Fatalf("error must be of type goidc.Error, got %v", err)
+	}
+
+	if oidcErr.Code != goidc.ErrorCodeSlowDown {
+		t.Errorf("error code = %s, want %s", oidcErr.Code, goidc.ErrorCodeSlowDown)
+	}
+
+	if beforeFailures != 2 {
+		t.Errorf("failures = %d, want 2", beforeFailures)
+	}
+}
+
+func TestAuthenticated_ClientAuthnHooks_ClientNotFound(t *testing.T) {
+	// Given.
+	ctx := oidctest.NewContext(t)
+	ctx.ClientAuthnFailures = oidc.NewClientAuthnFailures(time.Hour, 10)
+	ctx.Request.PostForm = map[string][]string{
+		"client_id":     {"unknown_client_id"},
+		"client_secret": {"invalid_secret"},
+	}
+
+	called := false
+	ctx.AfterClientAuthnFunc = func(*http.Request, string, int, error) {
+		called = true
+	}
+
+	// When.
+	_, err := clientutil.Authenticated(ctx, clientutil.TokenAuthnContext)
+
+	// Then.
+	if err == nil {
+		t.Fatal("the client must not be authenticated")
+	}
+
+	if called {
+		t.Error("the failures of unknown clients must not be informed")
+	}
+}
+
 func TestAuthenticated_BasicSecretAuthn(t *testing.T) {
 
 	// Given.
@@ -267,6 +440,48 @@ func TestAuthenticated_PrivateKeyJWT(t *testing.T) {
 	}
 }
 
+func TestAuthenticated_PrivateKeyJWT_ClockSkew(t *testing.T) {
+
+	// Given the client's clock is ahead of the server's.
+	ctx, client, jwk := setUpPrivateKeyJWTAuthn(t)
+	ctx.AssertionLeewayTimeSecs = 30
+	createdAtTimestamp := timeutil.TimestampNow() + 10
+	claims := map[string]any{
+		goidc.ClaimIssuer:   client.ID,
+		goidc.ClaimSubject:  client.ID,
+		goidc.ClaimAudience: ctx.Host,
+		goidc.ClaimIssuedAt: createdAtTimestamp,
+		goidc.ClaimExpiry:   createdAtTimestamp + ctx.AssertionLifetimeSecs - 20,
+		goidc.ClaimTokenID:  "random_jti",
+	}
+
+	ctx.Request.PostForm = map[string][]string{
+		"client_assertion":      {signAssertion(t, claims, jwk)},
+		"client_assertion_type": {string(goidc.AssertionTypeJWTBearer)},
+	}
+
+	// When.
+	_, err := clientutil.Authenticated(ctx, clientutil.TokenAuthnContext)
+
+	// Then.
+	if err != nil {
+		t.Errorf("The client should be authenticated, but error was found: %v", err)
+	}
+
+	// Given the clock skew is not tolerated.
+	ctx.AssertionLeewayTimeSecs = 0
+	claims[goidc.ClaimTokenID] = "other_random_jti"
+	ctx.Request.PostForm["client_assertion"] = []string{signAssertion(t, claims, jwk)}
+
+	// When.
+	_, err = clientutil.Authenticated(ctx, clientutil.TokenAuthnContext)
+
+	// Then.
+	if err == nil {
+		t.Error("The assertion issued in the future should be rejected")
+	}
+}
+
 // TestAuthenticated_PrivateKeyJWT_ClientInformedSigningAlgorithms tests that a
 // client can sign an assertion with its authentication algorithm.
 func TestAuthenticated_PrivateKeyJWT_ClientInformedSigningAlgorithms(t *testing.T) {
@@ -835,3 +1050,204 @@ func setUpTLSAuthn(t *testing.T) (
 
 	return ctx, client
 }
+
+func TestAuthenticated_TLSAuthn_TrustedChain(t *testing.T) {
+
+	// Given.
+	ctx, client := setUpTLSAuthn(t)
+	client.TLSSubDistinguishedName = "CN=random_client"
+	ca, caKey := tlsCA(t)
+	cert := tlsClientCert(t, ca, caKey, x509.ExtKeyUsageClientAuth)
+	ctx.ClientCertFunc = func(r *http.Request) (*x509.Certificate, error) {
+		return cert, nil
+	}
+	ctx.TLSClientCertPolicy = &goidc.TLSClientCertPolicy{
+		RootCAsFunc: func(_ context.Context, c *goidc.Client) (*x509.CertPool, error) {
+			pool := x509.NewCertPool()
+			pool.AddCert(ca)
+			return pool, nil
+		},
+	}
+	ctx.Request.PostForm = map[string][]string{
+		"client_id": {client.ID},
+	}
+
+	// When.
+	_, err := clientutil.Authenticated(ctx, clientutil.TokenAuthnContext)
+
+	// Then.
+	if err != nil {
+		t.Errorf("The client should be authenticated, but error was found: %v", err)
+	}
+}
+
+func TestAuthenticated_TLSAuthn_UntrustedChain(t *testing.T) {
+
+	// Given.
+	ctx, client := setUpTLSAuthn(t)
+	client.TLSSubDistinguishedName = "CN=random_client"
+	ca, caKey := tlsCA(t)
+	cert := tlsClientCert(t, ca, caKey, x509.ExtKeyUsageClientAuth)
+	ctx.ClientCertFunc = func(r *http.Request) (*x509.Certificate, error) {
+		return cert, nil
+	}
+	otherCA, _ := tlsCA(t)
+	ctx.TLSClientCertPolicy = &goidc.TLSClientCertPolicy{
+		RootCAsFunc: func(_ context.Context, c *goidc.Client) (*x509.CertPool, error) {
+			pool := x509.NewCertPool()
+			pool.AddCert(otherCA)
+			return pool, nil
+		},
+	}
+	ctx.Request.PostForm = map[string][]string{
+		"client_id": {client.ID},
+	}
+
+	// When.
+	_, err := clientutil.Authenticated(ctx, clientutil.TokenAuthnContext)
+
+	// Then.
+	if err == nil {
+		t.Error("The client should not be authenticated with an untrusted certificate")
+	}
+}
+
+func TestAuthenticated_TLSAuthn_InvalidExtKeyUsage(t *testing.T) {
+
+	// Given.
+	ctx, client := setUpTLSAuthn(t)
+	client.TLSSubDistinguishedName = "CN=random_client"
+	ca, caKey := tlsCA(t)
+	cert := tlsClientCert(t, ca, caKey, x509.ExtKeyUsageServerAuth)
+	ctx.ClientCertFunc = func(r *http.Request) (*x509.Certificate, error) {
+		return cert, nil
+	}
+	ctx.TLSClientCertPolicy = &goidc.TLSClientCertPolicy{
+		RootCAsFunc: func(_ context.Context, c *goidc.Client) (*x509.CertPool, error) {
+			pool := x509.NewCertPool()
+			pool.AddCert(ca)
+			return pool, nil
+		},
+	}
+	ctx.Request.PostForm = map[string][]string{
+		"client_id": {client.ID},
+	}
+
+	// When.
+	_, err := clientutil.Authenticated(ctx, clientutil.TokenAuthnContext)
+
+	// Then.
+	if err == nil {
+		t.Error("The client should not be authenticated with a server certificate")
+	}
+}
+
+func TestAuthenticated_TLSAuthn_RevokedCert(t *testing.T) {
+
+	// Given.
+	ctx, client := setUpTLSAuthn(t)
+	client.TLSSubDistinguishedName = "CN=random_client"
+	ca, caKey := tlsCA(t)
+	cert := tlsClientCert(t, ca, caKey, x509.ExtKeyUsageClientAuth)
+	ctx.ClientCertFunc = func(r *http.Request) (*x509.Certificate, error) {
+		return cert, nil
+	}
+
+	crlDER, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number: big.NewInt(1),
+		RevokedCertificateEntries: []x509.RevocationListEntry{
+			{SerialNumber: cert.SerialNumber, RevocationTime: time.Now()},
+		},
+		ThisUpdate: time.Now(),
+		NextUpdate: time.Now().Add(time.Hour),
+	}, ca, caKey)
+	if err != nil {
+		t.Fatalf("could not create the revocation list: %v", err)
+	}
+	crl, err := x509.ParseRevocationList(crlDER)
+	if err != nil {
+		t.Fatalf("could not parse the revocation list: %v", err)
+	}
+
+	ctx.TLSClientCertPolicy = &goidc.TLSClientCertPolicy{
+		RootCAsFunc: func(_ context.Context, c *goidc.Client) (*x509.CertPool, error) {
+			pool := x509.NewCertPool()
+			pool.AddCert(ca)
+			return pool, nil
+		},
+		CheckRevocationFunc: goidc.CRLRevocationCheck(crl),
+	}
+	ctx.Request.PostForm = map[string][]string{
+		"client_id": {client.ID},
+	}
+
+	// When.
+	_, err = clientutil.Authenticated(ctx, clientutil.TokenAuthnContext)
+
+	// Then.
+	if err == nil {
+		t.Error("The client should not be authenticated with a revoked certificate")
+	}
+}
+
+func tlsCA(t *testing.T) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("could not generate the ca key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "random_ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("could not create the ca: %v", err)
+	}
+
+	ca, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("could not parse the ca: %v", err)
+	}
+	return ca, key
+}
+
+func tlsClientCert(
+	t *testing.T,
+	ca *x509.Certificate,
+	caKey *ecdsa.PrivateKey,
+	usage x509.ExtKeyUsage,
+) *x509.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("could not generate the client key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "random_client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("could not create the client certificate: %v", err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("could not parse the client certificate: %v", err)
+	}
+	return cert
+}
@@ -1,6 +1,8 @@
 package clientutil
 
 import (
+	"crypto/ecdsa"
+	"crypto/rsa"
 	"errors"
 	"fmt"
 	"strings"
@@ -76,3 +78,51 @@ func JWKByAlg(ctx oidc.Context, c *goidc.Client, alg string) (jose.JSONWebKey, e
 
 	return jose.JSONWebKey{}, fmt.Errorf("invalid key algorithm: %s", alg)
 }
+
+// EncJWK returns the client JWK to encrypt content with the key management
+// algorithm informed. Only keys meant for encryption are considered, i.e.
+// with "use" set to "enc" or not set. Keys declaring the algorithm are
+// preferred over the ones that don't declare any but whose type supports it.
+// The error returned describes why no key could be resolved, so it can be
+// shown to the client.
+func EncJWK(ctx oidc.Context, c *goidc.Client, alg jose.KeyAlgorithm) (jose.JSONWebKey, error) {
+	jwks, err := ctx.ClientPublicJWKS(c)
+	if err != nil {
+		return jose.JSONWebKey{}, goidc.Errorf(goidc.ErrorCodeInvalidRequest, err.Error(), err)
+	}
+
+	var candidate *jose.JSONWebKey
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != string(goidc.KeyUsageEncryption) {
+			continue
+		}
+
+		if jwk.Algorithm == string(alg) {
+			return jwk, nil
+		}
+
+		if jwk.Algorithm == "" && candidate == nil && keySupportsEncAlg(jwk, alg) {
+			candidate = &jwk
+		}
+	}
+
+	if candidate == nil {
+		return jose.JSONWebKey{}, goidc.NewError(goidc.ErrorCodeInvalidRequest,
+			fmt.Sprintf("the client jwks has no encryption key for the algorithm %s", alg))
+	}
+
+	return *candidate, nil
+}
+
+func keySupportsEncAlg(jwk jose.JSONWebKey, alg jose.KeyAlgorithm) bool {
+	switch alg {
+	case jose.RSA1_5, jose.RSA_OAEP, jose.RSA_OAEP_256:
+		_, ok := jwk.Key.(*rsa.PublicKey)
+		return ok
+	case jose.ECDH_ES, jose.ECDH_ES_A128KW, jose.ECDH_ES_A192KW, jose.ECDH_ES_A256KW:
+		_, ok := jwk.Key.(*ecdsa.PublicKey)
+		return ok
+	default:
+		return false
+	}
+}
@@ -1,10 +1,14 @@
 package clientutil_test
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"testing"
 
+	"github.com/go-jose/go-jose/v4"
 	"github.com/luikyv/go-oidc/internal/clientutil"
+	"github.com/luikyv/go-oidc/internal/oidctest"
 	"github.com/luikyv/go-oidc/pkg/goidc"
 )
 
@@ -47,3 +51,62 @@ func TestAreScopesAllowed(t *testing.T) {
 		)
 	}
 }
+
+func TestEncJWK(t *testing.T) {
+	// Given.
+	rsaOAEPKey := oidctest.PrivateRSAOAEPJWK(t, "rsa_oaep_key")
+	rsaOAEPKey = rsaOAEPKey.Public()
+	noAlgKey := oidctest.PrivateRSAOAEPJWK(t, "no_alg_key")
+	noAlgKey = noAlgKey.Public()
+	noAlgKey.Algorithm = ""
+	sigKey := oidctest.PrivatePS256JWK(t, "sig_key", goidc.KeyUsageSignature)
+	sigKey = sigKey.Public()
+
+	testCases := []struct {
+		name      string
+		keys      []jose.JSONWebKey
+		alg       jose.KeyAlgorithm
+		wantKeyID string
+		wantErr   bool
+	}{
+		{"key with the algorithm", []jose.JSONWebKey{noAlgKey, rsaOAEPKey}, jose.RSA_OAEP, "rsa_oaep_key", false},
+		{"key without algorithm", []jose.JSONWebKey{sigKey, noAlgKey}, jose.RSA_OAEP_256, "no_alg_key", false},
+		{"key type not supported", []jose.JSONWebKey{noAlgKey}, jose.ECDH_ES, "", true},
+		{"signing key only", []jose.JSONWebKey{sigKey}, jose.RSA_OAEP, "", true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			ctx := oidctest.NewContext(t)
+			jwks, _ := json.Marshal(jose.JSONWebKeySet{Keys: testCase.keys})
+			client := &goidc.Client{
+				ClientMetaInfo: goidc.ClientMetaInfo{
+					PublicJWKS: jwks,
+				},
+			}
+
+			// When.
+			jwk, err := clientutil.EncJWK(ctx, client, testCase.alg)
+
+			// Then.
+			if testCase.wantErr {
+				var oidcErr goidc.Error
+				if !errors.As(err, &oidcErr) {
+					t.Fatalf("invalid error type: %v", err)
+				}
+				if oidcErr.Code != goidc.ErrorCodeInvalidRequest {
+					t.Errorf("Code = %s, want %s", oidcErr.Code, goidc.ErrorCodeInvalidRequest)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if jwk.KeyID != testCase.wantKeyID {
+				t.Errorf("KeyID = %s, want %s", jwk.KeyID, testCase.wantKeyID)
+			}
+		})
+	}
+}
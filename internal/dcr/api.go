@@ -1,14 +1,13 @@
 package dcr
 
 import (
-	"encoding/json"
 	"net/http"
 
 	"github.com/luikyv/go-oidc/internal/oidc"
 	"github.com/luikyv/go-oidc/pkg/goidc"
 )
 
-func RegisterHandlers(router *http.ServeMux, config *oidc.Configuration) {
+func RegisterHandlers(router oidc.Router, config *oidc.Configuration) {
 	if config.DCRIsEnabled {
 		router.HandleFunc(
 			"POST "+config.EndpointPrefix+config.EndpointDCR,
@@ -20,6 +19,11 @@ func RegisterHandlers(router *http.ServeMux, config *oidc.Configuration) {
 			oidc.Handler(config, handleUpdate),
 		)
 
+		router.HandleFunc(
+			"PATCH "+config.EndpointPrefix+config.EndpointDCR+"/{client_id}",
+			oidc.Handler(config, handlePatch),
+		)
+
 		router.HandleFunc(
 			"GET "+config.EndpointPrefix+config.EndpointDCR+"/{client_id}",
 			oidc.Handler(config, handleGet),
@@ -33,8 +37,8 @@ func RegisterHandlers(router *http.ServeMux, config *oidc.Configuration) {
 }
 
 func handleCreate(ctx oidc.Context) {
-	var meta goidc.ClientMetaInfo
-	if err := json.NewDecoder(ctx.Request.Body).Decode(&meta); err != nil {
+	meta, err := decodeMetaInfo(ctx.Request.Body)
+	if err != nil {
 		err = goidc.Errorf(goidc.ErrorCodeInvalidRequest,
 			"could not parse the request", err)
 		ctx.WriteError(err)
@@ -54,8 +58,8 @@ func handleCreate(ctx oidc.Context) {
 }
 
 func handleUpdate(ctx oidc.Context) {
-	var meta goidc.ClientMetaInfo
-	if err := json.NewDecoder(ctx.Request.Body).Decode(&meta); err != nil {
+	meta, err := decodeMetaInfo(ctx.Request.Body)
+	if err != nil {
 		err = goidc.Errorf(goidc.ErrorCodeInvalidRequest,
 			"could not parse the request", err)
 		ctx.WriteError(err)
@@ -80,6 +84,25 @@ func handleUpdate(ctx oidc.Context) {
 	}
 }
 
+func handlePatch(ctx oidc.Context) {
+	regToken, ok := ctx.BearerToken()
+	if !ok {
+		ctx.WriteError(goidc.NewError(goidc.ErrorCodeAccessDenied, "no token found"))
+		return
+	}
+
+	id := ctx.Request.PathValue("client_id")
+	resp, err := patch(ctx, id, regToken, ctx.Request.Body)
+	if err != nil {
+		ctx.WriteError(err)
+		return
+	}
+
+	if err := ctx.Write(resp, http.StatusOK); err != nil {
+		ctx.WriteError(err)
+	}
+}
+
 func handleGet(ctx oidc.Context) {
 	token, ok := ctx.BearerToken()
 	if !ok {
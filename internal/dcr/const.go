@@ -9,4 +9,11 @@ const (
 	// requires a key of at least 512 bits (64 characters).
 	secretLength                  int = 64
 	registrationAccessTokenLength int = 50
+	// maxSectorIdentifierDocumentBytes limits the size of the document read
+	// from a sector_identifier_uri.
+	maxSectorIdentifierDocumentBytes int64 = 1 << 20
+	// initialAccessTokenType is the "typ" header of the initial access tokens
+	// issued by the provider, which distinguishes them from other JWTs signed
+	// with the same keys.
+	initialAccessTokenType string = "initial-access-token+jwt"
 )
@@ -0,0 +1,124 @@
+package dcr
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/google/uuid"
+	"github.com/luikyv/go-oidc/internal/jwtutil"
+	"github.com/luikyv/go-oidc/internal/oidc"
+	"github.com/luikyv/go-oidc/internal/strutil"
+	"github.com/luikyv/go-oidc/internal/timeutil"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+	"github.com/luikyv/go-oidc/pkg/token"
+)
+
+// NewInitialAccessToken issues a JWT signed with the default signing key of
+// the provider that allows clients to be registered until it expires.
+func NewInitialAccessToken(ctx oidc.Context, opts goidc.InitialAccessTokenOptions) (string, error) {
+	if opts.LifetimeSecs <= 0 {
+		return "", errors.New("the initial access token lifetime must be positive")
+	}
+
+	jwk, ok := ctx.UserSigKey()
+	if !ok {
+		return "", errors.New("no signing key is available for initial access tokens")
+	}
+
+	now := timeutil.TimestampNow()
+	claims := map[string]any{
+		goidc.ClaimIssuer:   ctx.Host,
+		goidc.ClaimAudience: registrationEndpoint(ctx),
+		goidc.ClaimTokenID:  uuid.NewString(),
+		goidc.ClaimIssuedAt: now,
+		goidc.ClaimExpiry:   now + opts.LifetimeSecs,
+	}
+	if len(opts.ScopeIDs) != 0 {
+		claims[goidc.ClaimScope] = strings.Join(opts.ScopeIDs, " ")
+	}
+
+	return jwtutil.Sign(claims, jwk,
+		(&jose.SignerOptions{}).WithType(jose.ContentType(initialAccessTokenType)).WithHeader("kid", jwk.KeyID))
+}
+
+// initialAccessToken is the information of a valid initial access token
+// issued by the provider.
+type initialAccessToken struct {
+	// scopeIDs, if not empty, are the only scopes clients can register with.
+	scopeIDs []string
+}
+
+// verifyInitialAccessToken validates an initial access token issued by
+// [NewInitialAccessToken].
+func verifyInitialAccessToken(ctx oidc.Context, tkn string) (initialAccessToken, error) {
+	if tkn == "" {
+		return initialAccessToken{}, errors.New("the initial access token is missing")
+	}
+
+	parsedToken, err := jwt.ParseSigned(tkn, ctx.SigAlgs())
+	if err != nil {
+		return initialAccessToken{}, fmt.Errorf("could not parse the initial access token: %w", err)
+	}
+
+	if len(parsedToken.Headers) != 1 ||
+		parsedToken.Headers[0].ExtraHeaders[jose.HeaderType] != initialAccessTokenType {
+		return initialAccessToken{}, errors.New("the token is not an initial access token")
+	}
+
+	verifier := token.Verifier{
+		Issuer:  ctx.Host,
+		JWKS:    ctx.PublicKeys(),
+		SigAlgs: ctx.SigAlgs(),
+	}
+	claims, err := verifier.ValidClaims(tkn)
+	if err != nil {
+		return initialAccessToken{}, err
+	}
+
+	if !audienceContains(claims[goidc.ClaimAudience], registrationEndpoint(ctx)) {
+		return initialAccessToken{}, errors.New("the initial access token has an invalid audience")
+	}
+
+	scopes, _ := claims[goidc.ClaimScope].(string)
+	return initialAccessToken{scopeIDs: strutil.SplitWithSpaces(scopes)}, nil
+}
+
+// validateScopes makes sure the client only has the scopes allowed by the
+// token. If the client doesn't inform any, it is given all of them.
+func (t initialAccessToken) validateScopes(meta *goidc.ClientMetaInfo) error {
+	if len(t.scopeIDs) == 0 {
+		return nil
+	}
+
+	if meta.ScopeIDs == "" {
+		meta.ScopeIDs = strings.Join(t.scopeIDs, " ")
+		return nil
+	}
+
+	for _, scope := range strutil.SplitWithSpaces(meta.ScopeIDs) {
+		if !slices.Contains(t.scopeIDs, scope) {
+			return goidc.NewError(goidc.ErrorCodeInvalidClientMetadata,
+				fmt.Sprintf("the scope %s is not allowed by the initial access token", scope))
+		}
+	}
+	return nil
+}
+
+func audienceContains(aud any, want string) bool {
+	switch aud := aud.(type) {
+	case string:
+		return aud == want
+	case []any:
+		return slices.Contains(aud, any(want))
+	default:
+		return false
+	}
+}
+
+func registrationEndpoint(ctx oidc.Context) string {
+	return ctx.BaseURL() + ctx.EndpointDCR
+}
@@ -0,0 +1,134 @@
+package dcr
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/luikyv/go-oidc/internal/jwtutil"
+	"github.com/luikyv/go-oidc/internal/oidctest"
+	"github.com/luikyv/go-oidc/internal/timeutil"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+func TestCreate_InitialAccessToken(t *testing.T) {
+	// Given.
+	ctx := oidctest.NewContext(t)
+	ctx.DCRInitialAccessTokenIsRequired = true
+	initialToken, err := NewInitialAccessToken(ctx, goidc.InitialAccessTokenOptions{
+		LifetimeSecs: 60,
+		ScopeIDs:     []string{oidctest.Scope1.ID, goidc.ScopeOpenID.ID},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error issuing the initial access token: %v", err)
+	}
+
+	c, _ := oidctest.NewClient(t)
+	c.ScopeIDs = ""
+
+	// When.
+	resp, err := create(ctx, initialToken, &c.ClientMetaInfo)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error creating the client: %v", err)
+	}
+
+	client, err := ctx.Client(resp.ID)
+	if err != nil {
+		t.Fatalf("could not fetch the new client: %v", err)
+	}
+
+	wantScopes := oidctest.Scope1.ID + " " + goidc.ScopeOpenID.ID
+	if client.ScopeIDs != wantScopes {
+		t.Errorf("ScopeIDs = %s, want %s", client.ScopeIDs, wantScopes)
+	}
+}
+
+func TestCreate_InitialAccessTokenScopeNotAllowed(t *testing.T) {
+	// Given.
+	ctx := oidctest.NewContext(t)
+	ctx.DCRInitialAccessTokenIsRequired = true
+	initialToken, err := NewInitialAccessToken(ctx, goidc.InitialAccessTokenOptions{
+		LifetimeSecs: 60,
+		ScopeIDs:     []string{oidctest.Scope1.ID},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error issuing the initial access token: %v", err)
+	}
+
+	c, _ := oidctest.NewClient(t)
+	c.ScopeIDs = oidctest.Scope2.ID
+
+	// When.
+	_, err = create(ctx, initialToken, &c.ClientMetaInfo)
+
+	// Then.
+	var oidcErr goidc.Error
+	if !errors.As(err, &oidcErr) {
+		t.Fatalf("invalid error type: %v", err)
+	}
+
+	if oidcErr.Code != goidc.ErrorCodeInvalidClientMetadata {
+		t.Errorf("Code = %s, want %s", oidcErr.Code, goidc.ErrorCodeInvalidClientMetadata)
+	}
+}
+
+func TestCreate_InvalidInitialAccessToken(t *testing.T) {
+	ctx := oidctest.NewContext(t)
+	ctx.DCRInitialAccessTokenIsRequired = true
+	jwk := ctx.PrivateJWKS.Keys[0]
+	now := timeutil.TimestampNow()
+
+	sign := func(typ jose.ContentType, claims map[string]any) string {
+		token, err := jwtutil.Sign(claims, jwk,
+			(&jose.SignerOptions{}).WithType(typ).WithHeader("kid", jwk.KeyID))
+		if err != nil {
+			t.Fatalf("could not sign the token: %v", err)
+		}
+		return token
+	}
+	validClaims := func() map[string]any {
+		return map[string]any{
+			goidc.ClaimIssuer:   ctx.Host,
+			goidc.ClaimAudience: registrationEndpoint(ctx),
+			goidc.ClaimIssuedAt: now,
+			goidc.ClaimExpiry:   now + 60,
+		}
+	}
+
+	expiredClaims := validClaims()
+	expiredClaims[goidc.ClaimExpiry] = now - 1
+	otherAudClaims := validClaims()
+	otherAudClaims[goidc.ClaimAudience] = "https://other.example.com"
+
+	testCases := []struct {
+		name  string
+		token string
+	}{
+		{"missing token", ""},
+		{"other type", sign("jwt", validClaims())},
+		{"expired", sign(jose.ContentType(initialAccessTokenType), expiredClaims)},
+		{"other audience", sign(jose.ContentType(initialAccessTokenType), otherAudClaims)},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// Given.
+			c, _ := oidctest.NewClient(t)
+
+			// When.
+			_, err := create(ctx, testCase.token, &c.ClientMetaInfo)
+
+			// Then.
+			var oidcErr goidc.Error
+			if !errors.As(err, &oidcErr) {
+				t.Fatalf("invalid error type: %v", err)
+			}
+
+			if oidcErr.Code != goidc.ErrorCodeAccessDenied {
+				t.Errorf("Code = %s, want %s", oidcErr.Code, goidc.ErrorCodeAccessDenied)
+			}
+		})
+	}
+}
@@ -1,7 +1,11 @@
 package dcr
 
 import (
+	"bytes"
 	"encoding/json"
+	"io"
+	"slices"
+	"strings"
 
 	"github.com/luikyv/go-oidc/pkg/goidc"
 )
@@ -34,5 +38,161 @@ func (resp response) MarshalJSON() ([]byte, error) {
 		rawValues[k] = v
 	}
 
+	// Inline the localized attributes.
+	delete(rawValues, "localized_attributes")
+	for k, v := range resp.LocalizedAttributes {
+		rawValues[k] = v
+	}
+
 	return json.Marshal(rawValues)
 }
+
+// localizableAttributes are the client metadata fields that can be informed
+// for multiple languages and scripts.
+var localizableAttributes = []string{
+	"client_name",
+	"client_uri",
+	"logo_uri",
+	"policy_uri",
+	"tos_uri",
+}
+
+// knownAttributes are the json names of the fields defined by
+// [goidc.ClientMetaInfo] that clients can inform.
+var knownAttributes = []string{
+	"client_name",
+	"logo_uri",
+	"client_uri",
+	"policy_uri",
+	"tos_uri",
+	"redirect_uris",
+	"request_uris",
+	"grant_types",
+	"response_types",
+	"jwks_uri",
+	"jwks",
+	"scope",
+	"subject_type",
+	"sector_identifier_uri",
+	"id_token_signed_response_alg",
+	"id_token_encrypted_response_alg",
+	"id_token_encrypted_response_enc",
+	"userinfo_signed_response_alg",
+	"userinfo_encrypted_response_alg",
+	"userinfo_encrypted_response_enc",
+	"require_signed_request_object",
+	"request_object_signing_alg",
+	"request_object_encryption_alg",
+	"request_object_encryption_enc",
+	"authorization_signed_response_alg",
+	"authorization_encrypted_response_alg",
+	"authorization_encrypted_response_enc",
+	"token_endpoint_auth_method",
+	"token_endpoint_auth_signing_alg",
+	"introspection_endpoint_auth_method",
+	"introspection_endpoint_auth_signing_alg",
+	"revocation_endpoint_auth_method",
+	"revocation_endpoint_auth_signing_alg",
+	"dpop_bound_access_tokens",
+	"tls_client_auth_subject_dn",
+	"tls_client_auth_san_dns",
+	"tls_client_auth_san_ip",
+	"tls_client_certificate_bound_access_tokens",
+	"authorization_data_types",
+	"default_max_age",
+	"default_acr_values",
+	"require_pushed_authorization_requests",
+	"allowed_cors_origins",
+	"allowed_source_cidrs",
+	"access_token_format",
+	"access_token_lifetime",
+	"refresh_token_disabled",
+	"refresh_token_lifetime",
+}
+
+// internalAttributes are the json names of the fields of
+// [goidc.ClientMetaInfo] that hold the custom and localized attributes. They
+// are built from the flattened fields, so they cannot be informed directly.
+var internalAttributes = []string{
+	"localized_attributes",
+	"custom_attributes",
+}
+
+// decodeMetaInfo parses the client metadata sent during registration.
+// Fields tagged with a BCP47 language tag, e.g. "client_name#ja-Jpan-JP",
+// are kept as localized attributes and any other unknown field is kept as a
+// custom attribute, so it round-trips through registration and retrieval.
+func decodeMetaInfo(r io.Reader) (goidc.ClientMetaInfo, error) {
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return goidc.ClientMetaInfo{}, err
+	}
+
+	var meta goidc.ClientMetaInfo
+	if err := json.Unmarshal(body, &meta); err != nil {
+		return goidc.ClientMetaInfo{}, err
+	}
+
+	var rawValues map[string]any
+	if err := json.Unmarshal(body, &rawValues); err != nil {
+		return goidc.ClientMetaInfo{}, err
+	}
+
+	// Custom and localized attributes are only accepted flattened.
+	meta.CustomAttributes = nil
+	meta.LocalizedAttributes = nil
+	for k, v := range rawValues {
+		if slices.Contains(knownAttributes, k) || slices.Contains(internalAttributes, k) {
+			continue
+		}
+
+		name, tag, ok := strings.Cut(k, "#")
+		if ok && tag != "" && slices.Contains(localizableAttributes, name) {
+			if value, ok := v.(string); ok {
+				meta.SetLocalizedAttribute(name, tag, value)
+			}
+			continue
+		}
+
+		meta.SetAttribute(k, v)
+	}
+
+	return meta, nil
+}
+
+// patchMetaInfo merges the metadata patch into the current client metadata.
+func patchMetaInfo(meta goidc.ClientMetaInfo, patch io.Reader) (goidc.ClientMetaInfo, error) {
+	var patchValues map[string]any
+	if err := json.NewDecoder(patch).Decode(&patchValues); err != nil {
+		return goidc.ClientMetaInfo{}, err
+	}
+
+	// Use the response representation, so custom and localized attributes are
+	// flattened the same way they are informed by the client.
+	currentBytes, err := json.Marshal(response{ClientMetaInfo: &meta})
+	if err != nil {
+		return goidc.ClientMetaInfo{}, err
+	}
+
+	var values map[string]any
+	if err := json.Unmarshal(currentBytes, &values); err != nil {
+		return goidc.ClientMetaInfo{}, err
+	}
+	delete(values, "client_id")
+	delete(values, "registration_client_uri")
+
+	for k, v := range patchValues {
+		if v == nil {
+			delete(values, k)
+			continue
+		}
+		values[k] = v
+	}
+
+	mergedBytes, err := json.Marshal(values)
+	if err != nil {
+		return goidc.ClientMetaInfo{}, err
+	}
+
+	return decodeMetaInfo(bytes.NewReader(mergedBytes))
+}
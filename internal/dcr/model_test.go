@@ -0,0 +1,146 @@
+package dcr
+
+import (
+	"encoding/json"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+func TestDecodeMetaInfo_LocalizedAttributes(t *testing.T) {
+	// Given.
+	body := `{
+		"client_name": "My App",
+		"client_name#ja-Jpan-JP": "アプリ",
+		"logo_uri#fr": "https://example.com/logo-fr.png",
+		"unknown#fr": "value"
+	}`
+
+	// When.
+	meta, err := decodeMetaInfo(strings.NewReader(body))
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := map[string]string{
+		"client_name#ja-Jpan-JP": "アプリ",
+		"logo_uri#fr":            "https://example.com/logo-fr.png",
+	}
+	if diff := cmp.Diff(meta.LocalizedAttributes, want); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestDecodeMetaInfo_RawInternalAttributes(t *testing.T) {
+	// Given.
+	body := `{
+		"client_name": "My App",
+		"localized_attributes": {"client_name#fr": "Mon App", "logo_uri": "https://example.com/logo.png"},
+		"custom_attributes": {"software_roles": ["DATA"]}
+	}`
+
+	// When.
+	meta, err := decodeMetaInfo(strings.NewReader(body))
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if meta.LocalizedAttributes != nil {
+		t.Errorf("LocalizedAttributes = %v, want nil", meta.LocalizedAttributes)
+	}
+
+	if meta.CustomAttributes != nil {
+		t.Errorf("CustomAttributes = %v, want nil", meta.CustomAttributes)
+	}
+}
+
+func TestKnownAttributes(t *testing.T) {
+	// Given.
+	var names []string
+	metaType := reflect.TypeOf(goidc.ClientMetaInfo{})
+	for i := 0; i < metaType.NumField(); i++ {
+		name, _, _ := strings.Cut(metaType.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" && !slices.Contains(internalAttributes, name) {
+			names = append(names, name)
+		}
+	}
+
+	// Then.
+	if diff := cmp.Diff(knownAttributes, names, cmpopts.SortSlices(func(a, b string) bool { return a < b })); diff != "" {
+		t.Errorf("knownAttributes is out of sync with goidc.ClientMetaInfo: %s", diff)
+	}
+}
+
+func TestResponse_MarshalJSON_LocalizedAttributes(t *testing.T) {
+	// Given.
+	meta := &goidc.ClientMetaInfo{
+		Name: "My App",
+	}
+	meta.SetLocalizedAttribute("client_name", "fr", "Mon App")
+	resp := response{
+		ID:             "random_client_id",
+		ClientMetaInfo: meta,
+	}
+
+	// When.
+	respBytes, err := json.Marshal(resp)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var rawValues map[string]any
+	if err := json.Unmarshal(respBytes, &rawValues); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if rawValues["client_name#fr"] != "Mon App" {
+		t.Errorf("client_name#fr = %v, want Mon App", rawValues["client_name#fr"])
+	}
+
+	if _, ok := rawValues["localized_attributes"]; ok {
+		t.Error("the localized attributes should be flattened")
+	}
+}
+
+func TestDecodeMetaInfo_CustomAttributes(t *testing.T) {
+	// Given.
+	body := `{
+		"client_name": "My App",
+		"software_roles": ["DATA", "PISP"]
+	}`
+
+	// When.
+	meta, err := decodeMetaInfo(strings.NewReader(body))
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := map[string]any{
+		"software_roles": []any{"DATA", "PISP"},
+	}
+	if diff := cmp.Diff(meta.CustomAttributes, want); diff != "" {
+		t.Error(diff)
+	}
+
+	roles, ok := meta.StringsAttribute("software_roles")
+	if !ok {
+		t.Fatal("software_roles should be a list of strings")
+	}
+
+	if diff := cmp.Diff(roles, []string{"DATA", "PISP"}); diff != "" {
+		t.Error(diff)
+	}
+}
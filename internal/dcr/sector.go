@@ -0,0 +1,63 @@
+package dcr
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/luikyv/go-oidc/internal/oidc"
+)
+
+// sectorIdentifierRedirectURIs returns the redirect URIs listed in the JSON
+// array hosted at the sector identifier URI.
+func sectorIdentifierRedirectURIs(
+	ctx oidc.Context,
+	uri string,
+) (
+	[]string,
+	error,
+) {
+	if redirectURIs, ok := ctx.CachedSectorIdentifierRedirectURIs(uri); ok {
+		return redirectURIs, nil
+	}
+
+	redirectURIs, err := fetchSectorIdentifierRedirectURIs(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx.CacheSectorIdentifierRedirectURIs(uri, redirectURIs)
+	return redirectURIs, nil
+}
+
+func fetchSectorIdentifierRedirectURIs(
+	ctx oidc.Context,
+	uri string,
+) (
+	[]string,
+	error,
+) {
+	req, err := http.NewRequestWithContext(ctx.Context(), http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := ctx.HTTPClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching the sector identifier uri resulted in status %d", resp.StatusCode)
+	}
+
+	var redirectURIs []string
+	body := io.LimitReader(resp.Body, maxSectorIdentifierDocumentBytes)
+	if err := json.NewDecoder(body).Decode(&redirectURIs); err != nil {
+		return nil, fmt.Errorf("the sector identifier uri must contain a json array of redirect uris: %w", err)
+	}
+
+	return redirectURIs, nil
+}
@@ -0,0 +1,142 @@
+package dcr
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/luikyv/go-oidc/internal/oidc"
+	"github.com/luikyv/go-oidc/internal/oidctest"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+func TestValidateSectorIdentifierURI(t *testing.T) {
+	// Given.
+	client, _ := oidctest.NewClient(t)
+	server := sectorIdentifierServer(t, client.RedirectURIs)
+
+	ctx := oidctest.NewContext(t)
+	ctx.HTTPClientFunc = func(_ context.Context) *http.Client {
+		return server.Client()
+	}
+	client.SectorIdentifierURI = server.URL + "/sector"
+
+	// When.
+	err := validateSectorIdentifierURI(ctx, &client.ClientMetaInfo)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestValidateSectorIdentifierURI_RedirectURINotListed(t *testing.T) {
+	// Given.
+	client, _ := oidctest.NewClient(t)
+	server := sectorIdentifierServer(t, []string{"https://other.example.com/callback"})
+
+	ctx := oidctest.NewContext(t)
+	ctx.HTTPClientFunc = func(_ context.Context) *http.Client {
+		return server.Client()
+	}
+	client.SectorIdentifierURI = server.URL + "/sector"
+
+	// When.
+	err := validateSectorIdentifierURI(ctx, &client.ClientMetaInfo)
+
+	// Then.
+	var oidcErr goidc.Error
+	if !errors.As(err, &oidcErr) {
+		t.Fatalf("invalid error type")
+	}
+
+	if oidcErr.Code != goidc.ErrorCodeInvalidRedirectURI {
+		t.Errorf("Code = %s, want %s", oidcErr.Code, goidc.ErrorCodeInvalidRedirectURI)
+	}
+}
+
+func TestValidateSectorIdentifierURI_InvalidURI(t *testing.T) {
+	// Given.
+	client, _ := oidctest.NewClient(t)
+	client.SectorIdentifierURI = "http://example.com/sector"
+	ctx := oidctest.NewContext(t)
+
+	// When.
+	err := validateSectorIdentifierURI(ctx, &client.ClientMetaInfo)
+
+	// Then.
+	var oidcErr goidc.Error
+	if !errors.As(err, &oidcErr) {
+		t.Fatalf("invalid error type")
+	}
+
+	if oidcErr.Code != goidc.ErrorCodeInvalidClientMetadata {
+		t.Errorf("Code = %s, want %s", oidcErr.Code, goidc.ErrorCodeInvalidClientMetadata)
+	}
+}
+
+func TestSectorIdentifierRedirectURIs_Cache(t *testing.T) {
+	// Given.
+	requests := 0
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_ = json.NewEncoder(w).Encode([]string{"https://example.com/callback"})
+	}))
+	t.Cleanup(server.Close)
+
+	ctx := oidctest.NewContext(t)
+	ctx.HTTPClientFunc = func(_ context.Context) *http.Client {
+		return server.Client()
+	}
+	ctx.SectorIdentifierCache = oidc.NewSectorIdentifierCache(time.Minute, 10)
+
+	// When.
+	for i := 0; i < 2; i++ {
+		if _, err := sectorIdentifierRedirectURIs(ctx, server.URL+"/cache"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	// Then.
+	if requests != 1 {
+		t.Errorf("requests = %d, want 1", requests)
+	}
+}
+
+func TestSectorIdentifierRedirectURIs_DocumentTooLarge(t *testing.T) {
+	// Given.
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`["https://example.com/callback"`))
+		_, _ = w.Write([]byte(strings.Repeat(` `, int(maxSectorIdentifierDocumentBytes))))
+		_, _ = w.Write([]byte(`]`))
+	}))
+	t.Cleanup(server.Close)
+
+	ctx := oidctest.NewContext(t)
+	ctx.HTTPClientFunc = func(_ context.Context) *http.Client {
+		return server.Client()
+	}
+
+	// When.
+	_, err := sectorIdentifierRedirectURIs(ctx, server.URL+"/large")
+
+	// Then.
+	if err == nil {
+		t.Fatal("the document must be rejected")
+	}
+}
+
+func sectorIdentifierServer(t *testing.T, redirectURIs []string) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(redirectURIs)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
@@ -1,6 +1,7 @@
 package dcr
 
 import (
+	"io"
 	"slices"
 
 	"github.com/luikyv/go-oidc/internal/oidc"
@@ -18,20 +19,31 @@ func create(
 	error,
 ) {
 
-	if err := ctx.ValidateInitalAccessToken(initialToken); err != nil {
-		return response{}, goidc.Errorf(goidc.ErrorCodeAccessDenied,
-			"invalid token", err)
-	}
-
-	if err := validate(ctx, meta); err != nil {
-		return response{}, err
+	var token initialAccessToken
+	if ctx.DCROpenRegistrationIsEnabled {
+		if err := validateOpenRegistration(ctx); err != nil {
+			return response{}, err
+		}
+	} else {
+		var err error
+		token, err = validateInitialAccessToken(ctx, initialToken)
+		if err != nil {
+			return response{}, goidc.Errorf(goidc.ErrorCodeAccessDenied,
+				"invalid token", err)
+		}
 	}
 
+	// The metadata is validated only after the handler runs, so the changes
+	// it makes are validated as well.
 	if err := ctx.HandleDynamicClient(meta); err != nil {
 		return response{}, goidc.Errorf(goidc.ErrorCodeInvalidClientMetadata,
 			"invalid metadata", err)
 	}
 
+	if err := token.validateScopes(meta); err != nil {
+		return response{}, err
+	}
+
 	if err := validate(ctx, meta); err != nil {
 		return response{}, err
 	}
@@ -42,6 +54,50 @@ func create(
 	return modifyAndSaveClient(ctx, client)
 }
 
+// validateInitialAccessToken verifies the token informed during registration.
+// Tokens issued by the provider are verified first if they are required, and
+// then the custom validation, if any, is executed.
+func validateInitialAccessToken(ctx oidc.Context, tkn string) (initialAccessToken, error) {
+	var token initialAccessToken
+	if ctx.DCRInitialAccessTokenIsRequired {
+		var err error
+		token, err = verifyInitialAccessToken(ctx, tkn)
+		if err != nil {
+			return initialAccessToken{}, err
+		}
+	}
+
+	if err := ctx.ValidateInitalAccessToken(tkn); err != nil {
+		return initialAccessToken{}, err
+	}
+
+	return token, nil
+}
+
+// validateOpenRegistration limits the registrations without an initial access
+// token per IP address and runs the registration challenge.
+func validateOpenRegistration(ctx oidc.Context) error {
+	if ctx.DCRRegistrationLimiter != nil {
+		ip, err := ctx.ClientIP()
+		if err != nil {
+			return goidc.Errorf(goidc.ErrorCodeInvalidRequest,
+				"could not identify the address of the request", err)
+		}
+
+		if !ctx.DCRRegistrationLimiter.Allow(ip.String()) {
+			return goidc.NewError(goidc.ErrorCodeSlowDown,
+				"too many registrations, try again later")
+		}
+	}
+
+	if err := ctx.ValidateDCRChallenge(); err != nil {
+		return goidc.Errorf(goidc.ErrorCodeAccessDenied,
+			"the registration challenge failed", err)
+	}
+
+	return nil
+}
+
 func update(
 	ctx oidc.Context,
 	id string,
@@ -56,10 +112,45 @@ func update(
 		return response{}, err
 	}
 
-	if err := validate(ctx, meta); err != nil {
+	return updateClient(ctx, client, meta)
+}
+
+// patch applies a partial update to the client metadata.
+// The fields informed replace the current ones and fields set to null are
+// removed, as in JSON merge patch (RFC 7396).
+func patch(
+	ctx oidc.Context,
+	id string,
+	regToken string,
+	body io.Reader,
+) (
+	response,
+	error,
+) {
+	client, err := protected(ctx, id, regToken)
+	if err != nil {
 		return response{}, err
 	}
 
+	meta, err := patchMetaInfo(client.ClientMetaInfo, body)
+	if err != nil {
+		return response{}, goidc.Errorf(goidc.ErrorCodeInvalidRequest,
+			"could not parse the request", err)
+	}
+
+	return updateClient(ctx, client, &meta)
+}
+
+func updateClient(
+	ctx oidc.Context,
+	client *goidc.Client,
+	meta *goidc.ClientMetaInfo,
+) (
+	response,
+	error,
+) {
+	// The metadata is validated only after the handler runs, so the changes
+	// it makes are validated as well.
 	if err := ctx.HandleDynamicClient(meta); err != nil {
 		return response{}, goidc.Errorf(goidc.ErrorCodeInvalidClientMetadata,
 			"invalid metadata", err)
@@ -122,6 +213,9 @@ func modifyAndSaveClient(
 	id := setID(ctx, client)
 	regToken := setRegistrationToken(ctx, client)
 	secret := setSecret(ctx, client)
+	// The client URIs were just validated, so they are only verified if the
+	// verification is enabled.
+	client.BrandingIsVerified = ctx.DCRClientURIsAreVerified
 
 	if err := ctx.SaveClient(client); err != nil {
 		return response{}, goidc.Errorf(goidc.ErrorCodeInternalError,
@@ -140,9 +234,9 @@ func modifyAndSaveClient(
 // setID assigns a unique ID to the client if it doesn't already have one.
 // If the client already has an ID, it returns the existing ID.
 // Otherwise, it generates a new ID and returns it.
-func setID(_ oidc.Context, client *goidc.Client) string {
+func setID(ctx oidc.Context, client *goidc.Client) string {
 	if client.ID == "" {
-		client.ID = clientID()
+		client.ID = clientID(ctx)
 	}
 	return client.ID
 }
@@ -238,8 +332,10 @@ func protected(
 	return c, nil
 }
 
-func clientID() string {
-	return "dc-" + strutil.Random(idLength)
+func clientID(ctx oidc.Context) string {
+	return ctx.NewID(goidc.IDTypeClient, func() string {
+		return "dc-" + strutil.Random(idLength)
+	})
 }
 
 func clientSecretAndHash() (string, string) {
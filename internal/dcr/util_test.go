@@ -1,8 +1,12 @@
 package dcr
 
 import (
+	"errors"
+	"net/http"
+	"strings"
 	"testing"
 
+	"github.com/google/go-cmp/cmp"
 	"github.com/luikyv/go-oidc/internal/oidc"
 	"github.com/luikyv/go-oidc/internal/oidctest"
 	"github.com/luikyv/go-oidc/pkg/goidc"
@@ -37,6 +41,106 @@ func TestCreate(t *testing.T) {
 	}
 }
 
+func TestCreate_HandlerChangesAreValidated(t *testing.T) {
+	// Given.
+	c, _ := oidctest.NewClient(t)
+	ctx := oidctest.NewContext(t)
+	ctx.HandleDynamicClientFunc = func(_ *http.Request, meta *goidc.ClientMetaInfo) error {
+		meta.RedirectURIs = []string{"http://example.com/callback"}
+		return nil
+	}
+
+	// When.
+	_, err := create(ctx, "", &c.ClientMetaInfo)
+
+	// Then.
+	var oidcErr goidc.Error
+	if !errors.As(err, &oidcErr) {
+		t.Fatalf("invalid error type")
+	}
+
+	if oidcErr.Code != goidc.ErrorCodeInvalidClientMetadata {
+		t.Errorf("Code = %s, want %s", oidcErr.Code, goidc.ErrorCodeInvalidClientMetadata)
+	}
+}
+
+func TestCreate_BrandingVerified(t *testing.T) {
+	// Given.
+	c, _ := oidctest.NewClient(t)
+	ctx := oidctest.NewContext(t)
+	ctx.DCRClientURIsAreVerified = true
+
+	// When.
+	resp, err := create(ctx, "", &c.ClientMetaInfo)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error creating the client: %v", err)
+	}
+
+	client, err := ctx.Client(resp.ID)
+	if err != nil {
+		t.Fatalf("fetching the new client resulted in error: %v", err)
+	}
+
+	if !client.BrandingIsVerified {
+		t.Error("the branding of the client should be verified")
+	}
+}
+
+func TestCreate_OpenRegistration(t *testing.T) {
+	// Given.
+	c, _ := oidctest.NewClient(t)
+	ctx := oidctest.NewContext(t)
+	ctx.DCROpenRegistrationIsEnabled = true
+	ctx.DCRRegistrationLimiter = oidc.NewRateLimiter(1, 60)
+
+	// When.
+	_, err := create(ctx, "", &c.ClientMetaInfo)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error creating the client: %v", err)
+	}
+
+	_, err = create(ctx, "", &c.ClientMetaInfo)
+	var oidcErr goidc.Error
+	if !errors.As(err, &oidcErr) {
+		t.Fatalf("invalid error type: %v", err)
+	}
+
+	if oidcErr.Code != goidc.ErrorCodeSlowDown {
+		t.Errorf("Code = %s, want %s", oidcErr.Code, goidc.ErrorCodeSlowDown)
+	}
+}
+
+func TestCreate_OpenRegistrationChallengeFailed(t *testing.T) {
+	// Given.
+	c, _ := oidctest.NewClient(t)
+	ctx := oidctest.NewContext(t)
+	ctx.DCROpenRegistrationIsEnabled = true
+	ctx.DCRChallengeFunc = func(r *http.Request) error {
+		return errors.New("invalid captcha")
+	}
+
+	// When.
+	_, err := create(ctx, "", &c.ClientMetaInfo)
+
+	// Then.
+	var oidcErr goidc.Error
+	if !errors.As(err, &oidcErr) {
+		t.Fatalf("invalid error type: %v", err)
+	}
+
+	if oidcErr.Code != goidc.ErrorCodeAccessDenied {
+		t.Errorf("Code = %s, want %s", oidcErr.Code, goidc.ErrorCodeAccessDenied)
+	}
+
+	if clients := oidctest.Clients(t, ctx); len(clients) != 0 {
+		t.Errorf("len(clients) = %d, want 0", len(clients))
+	}
+}
+
 func TestUpdate(t *testing.T) {
 	// Given.
 	ctx, client, regToken := setUp(t)
@@ -81,6 +185,64 @@ func TestUpdate_TokenRotation(t *testing.T) {
 	}
 }
 
+func TestPatch(t *testing.T) {
+	// Given.
+	ctx, client, regToken := setUp(t)
+	ctx.DCRTokenRotationIsEnabled = false
+	client.Name = "old_name"
+	client.LogoURI = "https://example.com/logo.png"
+	client.SetAttribute("software_roles", []any{"DATA"})
+	body := strings.NewReader(`{"client_name": "new_name", "logo_uri": null}`)
+
+	// When.
+	resp, err := patch(ctx, client.ID, regToken, body)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error patching the client: %v", err)
+	}
+
+	updatedClient, err := ctx.Client(resp.ID)
+	if err != nil {
+		t.Fatalf("unexpected error fetching the client: %v", err)
+	}
+
+	if updatedClient.Name != "new_name" {
+		t.Errorf("Name = %s, want new_name", updatedClient.Name)
+	}
+
+	if updatedClient.LogoURI != "" {
+		t.Errorf("LogoURI = %s, want empty", updatedClient.LogoURI)
+	}
+
+	if diff := cmp.Diff(updatedClient.RedirectURIs, client.RedirectURIs); diff != "" {
+		t.Error(diff)
+	}
+
+	if roles, _ := updatedClient.StringsAttribute("software_roles"); len(roles) != 1 {
+		t.Errorf("software_roles = %v, want [DATA]", roles)
+	}
+}
+
+func TestPatch_InvalidMetadata(t *testing.T) {
+	// Given.
+	ctx, client, regToken := setUp(t)
+	body := strings.NewReader(`{"redirect_uris": ["http://example.com/callback"]}`)
+
+	// When.
+	_, err := patch(ctx, client.ID, regToken, body)
+
+	// Then.
+	var oidcErr goidc.Error
+	if !errors.As(err, &oidcErr) {
+		t.Fatalf("invalid error type")
+	}
+
+	if oidcErr.Code != goidc.ErrorCodeInvalidClientMetadata {
+		t.Errorf("Code = %s, want %s", oidcErr.Code, goidc.ErrorCodeInvalidClientMetadata)
+	}
+}
+
 func TestFetch(t *testing.T) {
 	// Given.
 	ctx, client, regToken := setUp(t)
@@ -3,8 +3,11 @@ package dcr
 import (
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/netip"
 	"net/url"
 	"slices"
+	"strings"
 
 	"github.com/go-jose/go-jose/v4"
 	"github.com/luikyv/go-oidc/internal/oidc"
@@ -12,6 +15,12 @@ import (
 	"github.com/luikyv/go-oidc/pkg/goidc"
 )
 
+// Validate runs the dynamic client registration validations on the metadata
+// of a client registered by other means, e.g. a static client.
+func Validate(ctx oidc.Context, meta *goidc.ClientMetaInfo) error {
+	return validate(ctx, meta)
+}
+
 func validate(
 	ctx oidc.Context,
 	meta *goidc.ClientMetaInfo,
@@ -30,11 +39,13 @@ func validate(
 		validateClientCredentialsGrantType,
 		validateRedirectURIS,
 		validateRequestURIS,
+		validatePAR,
 		validateResponseTypes,
 		validateImplicitResponseTypes,
 		validateResponseTypeCode,
 		validateOpenIDScopeIfRequired,
 		validateSubjectIdentifierType,
+		validateSectorIdentifierURI,
 		validateIDTokenSigAlg,
 		validateIDTokenEncAlgs,
 		validateUserInfoSigAlg,
@@ -46,6 +57,11 @@ func validate(
 		validatePublicJWKS,
 		validatePublicJWKSURI,
 		validateAuthorizationDetailTypes,
+		validateAccessTokenMetadata,
+		validateRefreshTokenMetadata,
+		validateAllowedCORSOrigins,
+		validateAllowedSourceCIDRs,
+		validateClientURIs,
 	)
 }
 
@@ -125,6 +141,17 @@ func validateRequestURIS(
 	return nil
 }
 
+func validatePAR(
+	ctx oidc.Context,
+	meta *goidc.ClientMetaInfo,
+) error {
+	if meta.PARIsRequired && !ctx.PARIsEnabled {
+		return goidc.NewError(goidc.ErrorCodeInvalidClientMetadata,
+			"require_pushed_authorization_requests not supported")
+	}
+	return nil
+}
+
 func validateResponseTypes(
 	ctx oidc.Context,
 	meta *goidc.ClientMetaInfo,
@@ -254,6 +281,43 @@ func validateSubjectIdentifierType(
 	return nil
 }
 
+// validateSectorIdentifierURI makes sure the document referenced by
+// sector_identifier_uri lists all the redirect URIs registered by the client.
+func validateSectorIdentifierURI(
+	ctx oidc.Context,
+	meta *goidc.ClientMetaInfo,
+) error {
+	if meta.SectorIdentifierURI == "" {
+		return nil
+	}
+
+	parsedURI, err := url.Parse(meta.SectorIdentifierURI)
+	if err != nil || parsedURI.Scheme != "https" || parsedURI.Host == "" {
+		return goidc.NewError(goidc.ErrorCodeInvalidClientMetadata,
+			"invalid sector_identifier_uri")
+	}
+
+	if err := ctx.ValidateURIPolicy(parsedURI); err != nil {
+		return goidc.Errorf(goidc.ErrorCodeInvalidClientMetadata,
+			"sector_identifier_uri not allowed", err)
+	}
+
+	sectorRedirectURIs, err := sectorIdentifierRedirectURIs(ctx, meta.SectorIdentifierURI)
+	if err != nil {
+		return goidc.Errorf(goidc.ErrorCodeInvalidClientMetadata,
+			"could not fetch the sector_identifier_uri", err)
+	}
+
+	for _, ru := range meta.RedirectURIs {
+		if !slices.Contains(sectorRedirectURIs, ru) {
+			return goidc.NewError(goidc.ErrorCodeInvalidRedirectURI,
+				"redirect uri not listed in the sector_identifier_uri")
+		}
+	}
+
+	return nil
+}
+
 func validateIDTokenSigAlg(
 	ctx oidc.Context,
 	meta *goidc.ClientMetaInfo,
@@ -288,7 +352,20 @@ func validateJARSigAlg(
 	ctx oidc.Context,
 	meta *goidc.ClientMetaInfo,
 ) error {
-	if !ctx.JARIsEnabled || meta.JARSigAlg == "" {
+	if !ctx.JARIsEnabled {
+		if meta.JARIsRequired {
+			return goidc.NewError(goidc.ErrorCodeInvalidClientMetadata,
+				"require_signed_request_object not supported")
+		}
+		return nil
+	}
+
+	if meta.JARIsRequired && meta.JARSigAlg == goidc.NoneSignatureAlgorithm {
+		return goidc.NewError(goidc.ErrorCodeInvalidClientMetadata,
+			"request_object_signing_alg must not be none when require_signed_request_object is true")
+	}
+
+	if meta.JARSigAlg == "" {
 		return nil
 	}
 
@@ -544,8 +621,55 @@ func validatePublicJWKS(
 		return nil
 	}
 
+	return validateJWKSContent(meta.PublicJWKS)
+}
+
+func validatePublicJWKSURI(
+	ctx oidc.Context,
+	meta *goidc.ClientMetaInfo,
+) error {
+	if meta.PublicJWKSURI == "" {
+		return nil
+	}
+
+	if meta.PublicJWKS != nil {
+		return goidc.NewError(goidc.ErrorCodeInvalidClientMetadata,
+			"jwks and jwks_uri cannot be informed together")
+	}
+
+	parsedURI, err := url.Parse(meta.PublicJWKSURI)
+	if err != nil || parsedURI.Scheme != "https" || parsedURI.Host == "" {
+		return goidc.NewError(goidc.ErrorCodeInvalidClientMetadata,
+			"invalid jwks_uri")
+	}
+
+	if err := ctx.ValidateURIPolicy(parsedURI); err != nil {
+		return goidc.Errorf(goidc.ErrorCodeInvalidClientMetadata,
+			"jwks_uri not allowed", err)
+	}
+
+	if !ctx.DCRJWKSURIIsFetched {
+		return nil
+	}
+
+	client := &goidc.Client{
+		ClientMetaInfo: goidc.ClientMetaInfo{
+			PublicJWKSURI: meta.PublicJWKSURI,
+		},
+	}
+	if _, err := client.FetchPublicJWKS(ctx.HTTPClient()); err != nil {
+		return goidc.Errorf(goidc.ErrorCodeInvalidClientMetadata,
+			"could not fetch the jwks_uri", err)
+	}
+
+	return validateJWKSContent(client.PublicJWKS)
+}
+
+// validateJWKSContent makes sure the raw jwks is well formed and only contains
+// valid public keys.
+func validateJWKSContent(rawJWKS json.RawMessage) error {
 	var jwks jose.JSONWebKeySet
-	if err := json.Unmarshal(meta.PublicJWKS, &jwks); err != nil {
+	if err := json.Unmarshal(rawJWKS, &jwks); err != nil {
 		return goidc.NewError(goidc.ErrorCodeInvalidClientMetadata, "invalid jwks")
 	}
 
@@ -558,14 +682,6 @@ func validatePublicJWKS(
 	return nil
 }
 
-func validatePublicJWKSURI(
-	ctx oidc.Context,
-	meta *goidc.ClientMetaInfo,
-) error {
-	// TODO: validate the client jwks uri.
-	return nil
-}
-
 func validateAuthorizationDetailTypes(
 	ctx oidc.Context,
 	meta *goidc.ClientMetaInfo,
@@ -606,3 +722,195 @@ func validateScope(ctx oidc.Context, requestedScope string) error {
 	return goidc.NewError(goidc.ErrorCodeInvalidClientMetadata,
 		"scope "+requestedScope+" is not valid")
 }
+
+func validateAccessTokenMetadata(
+	ctx oidc.Context,
+	meta *goidc.ClientMetaInfo,
+) error {
+	if meta.AccessTokenFormat == "" && meta.AccessTokenLifetimeSecs == 0 {
+		return nil
+	}
+
+	if !ctx.ClientTokenMetadataIsEnabled {
+		return goidc.NewError(goidc.ErrorCodeInvalidClientMetadata,
+			"access token metadata is not supported")
+	}
+
+	if _, ok := ctx.TokenIssuer(meta.AccessTokenFormat); !ok &&
+		meta.AccessTokenFormat != "" &&
+		meta.AccessTokenFormat != goidc.TokenFormatJWT &&
+		meta.AccessTokenFormat != goidc.TokenFormatOpaque {
+		return goidc.NewError(goidc.ErrorCodeInvalidClientMetadata,
+			"access_token_format not supported")
+	}
+
+	if meta.AccessTokenLifetimeSecs < 0 ||
+		meta.AccessTokenLifetimeSecs > ctx.ClientTokenMaxLifetimeSecs {
+		return goidc.NewError(goidc.ErrorCodeInvalidClientMetadata,
+			"access_token_lifetime exceeds the limit allowed")
+	}
+
+	return nil
+}
+
+// validateRefreshTokenMetadata makes sure the refresh token lifetime requested
+// by the client doesn't exceed the one defined by the server.
+// Disabling refresh tokens is always allowed, since it only restricts the
+// client.
+func validateRefreshTokenMetadata(
+	ctx oidc.Context,
+	meta *goidc.ClientMetaInfo,
+) error {
+	if meta.RefreshTokenLifetimeSecs == 0 {
+		return nil
+	}
+
+	if !ctx.ClientTokenMetadataIsEnabled {
+		return goidc.NewError(goidc.ErrorCodeInvalidClientMetadata,
+			"refresh token metadata is not supported")
+	}
+
+	if meta.RefreshTokenLifetimeSecs < 0 ||
+		meta.RefreshTokenLifetimeSecs > ctx.RefreshTokenLifetimeSecs {
+		return goidc.NewError(goidc.ErrorCodeInvalidClientMetadata,
+			"refresh_token_lifetime exceeds the limit allowed")
+	}
+
+	return nil
+}
+
+func validateAllowedSourceCIDRs(
+	_ oidc.Context,
+	meta *goidc.ClientMetaInfo,
+) error {
+	for _, cidr := range meta.AllowedSourceCIDRs {
+		if _, err := netip.ParsePrefix(cidr); err != nil {
+			return goidc.Errorf(goidc.ErrorCodeInvalidClientMetadata,
+				"invalid source cidr", err)
+		}
+	}
+	return nil
+}
+
+func validateAllowedCORSOrigins(
+	ctx oidc.Context,
+	meta *goidc.ClientMetaInfo,
+) error {
+	if meta.AllowedCORSOrigins == nil {
+		return nil
+	}
+
+	if !ctx.CORSIsEnabled {
+		return goidc.NewError(goidc.ErrorCodeInvalidClientMetadata,
+			"allowed_cors_origins is not supported")
+	}
+
+	for _, origin := range meta.AllowedCORSOrigins {
+		// An origin is composed only by the scheme, host and optional port.
+		if parsedOrigin, err := url.Parse(origin); err != nil ||
+			(parsedOrigin.Scheme != "https" && parsedOrigin.Scheme != "http") ||
+			parsedOrigin.Host == "" ||
+			parsedOrigin.Path != "" ||
+			parsedOrigin.RawQuery != "" ||
+			parsedOrigin.Fragment != "" ||
+			parsedOrigin.User != nil {
+			return goidc.NewError(goidc.ErrorCodeInvalidClientMetadata,
+				"invalid cors origin")
+		}
+	}
+
+	return nil
+}
+
+// validateClientURIs validates the human readable URIs of the client, including
+// their localized versions.
+func validateClientURIs(
+	ctx oidc.Context,
+	meta *goidc.ClientMetaInfo,
+) error {
+	// The URIs are validated in a fixed order so the same metadata always
+	// results in the same error.
+	uris := []struct{ name, uri string }{
+		{"logo_uri", meta.LogoURI},
+		{"client_uri", meta.ClientURI},
+		{"policy_uri", meta.PolicyURI},
+		{"tos_uri", meta.TermsOfServiceURI},
+	}
+
+	var localizedKeys []string
+	for key := range meta.LocalizedAttributes {
+		localizedKeys = append(localizedKeys, key)
+	}
+	slices.Sort(localizedKeys)
+
+	for _, u := range uris {
+		if u.uri != "" {
+			if err := validateClientURI(ctx, u.name, u.uri); err != nil {
+				return err
+			}
+		}
+
+		for _, key := range localizedKeys {
+			name, _, _ := strings.Cut(key, "#")
+			if name != u.name || meta.LocalizedAttributes[key] == "" {
+				continue
+			}
+
+			if err := validateClientURI(ctx, name, meta.LocalizedAttributes[key]); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func validateClientURI(ctx oidc.Context, name, uri string) error {
+	parsedURI, err := url.Parse(uri)
+	if err != nil || parsedURI.Host == "" ||
+		(parsedURI.Scheme != "https" && parsedURI.Scheme != "http") {
+		return goidc.NewError(goidc.ErrorCodeInvalidClientMetadata,
+			fmt.Sprintf("invalid %s", name))
+	}
+
+	if !ctx.DCRClientURIsAreVerified {
+		return nil
+	}
+
+	// Verified branding is shown to users as trustworthy, so it must be served
+	// over TLS.
+	if parsedURI.Scheme != "https" {
+		return goidc.NewError(goidc.ErrorCodeInvalidClientMetadata,
+			fmt.Sprintf("%s must use https", name))
+	}
+
+	if err := ctx.ValidateURIPolicy(parsedURI); err != nil {
+		return goidc.Errorf(goidc.ErrorCodeInvalidClientMetadata,
+			fmt.Sprintf("%s not allowed", name), err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx.Context(), http.MethodGet, uri, nil)
+	if err != nil {
+		return goidc.Errorf(goidc.ErrorCodeInvalidClientMetadata,
+			fmt.Sprintf("invalid %s", name), err)
+	}
+
+	resp, err := ctx.HTTPClient().Do(req)
+	if err != nil {
+		return goidc.Errorf(goidc.ErrorCodeInvalidClientMetadata,
+			fmt.Sprintf("%s is not reachable", name), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return goidc.NewError(goidc.ErrorCodeInvalidClientMetadata,
+			fmt.Sprintf("%s is not reachable", name))
+	}
+
+	if name == "logo_uri" && !strings.HasPrefix(resp.Header.Get("Content-Type"), "image/") {
+		return goidc.NewError(goidc.ErrorCodeInvalidClientMetadata,
+			"logo_uri must point to an image")
+	}
+
+	return nil
+}
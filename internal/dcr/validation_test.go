@@ -1,9 +1,13 @@
 package dcr
 
 import (
+	"context"
 	"errors"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
+	"github.com/go-jose/go-jose/v4"
 	"github.com/luikyv/go-oidc/internal/oidc"
 	"github.com/luikyv/go-oidc/internal/oidctest"
 	"github.com/luikyv/go-oidc/pkg/goidc"
@@ -219,6 +223,257 @@ func TestValidateRequest(t *testing.T) {
 			},
 			false,
 		},
+		{
+			"valid_access_token_metadata",
+			func(c *goidc.Client) {
+				c.AccessTokenFormat = goidc.TokenFormatOpaque
+				c.AccessTokenLifetimeSecs = 600
+			},
+			func(ctx oidc.Context) {
+				ctx.ClientTokenMetadataIsEnabled = true
+				ctx.ClientTokenMaxLifetimeSecs = 600
+			},
+			true,
+		},
+		{
+			"access_token_metadata_not_enabled",
+			func(c *goidc.Client) {
+				c.AccessTokenLifetimeSecs = 600
+			},
+			func(ctx oidc.Context) {},
+			false,
+		},
+		{
+			"access_token_lifetime_exceeds_limit",
+			func(c *goidc.Client) {
+				c.AccessTokenLifetimeSecs = 601
+			},
+			func(ctx oidc.Context) {
+				ctx.ClientTokenMetadataIsEnabled = true
+				ctx.ClientTokenMaxLifetimeSecs = 600
+			},
+			false,
+		},
+		{
+			"invalid_access_token_format",
+			func(c *goidc.Client) {
+				c.AccessTokenFormat = "invalid"
+			},
+			func(ctx oidc.Context) {
+				ctx.ClientTokenMetadataIsEnabled = true
+				ctx.ClientTokenMaxLifetimeSecs = 600
+			},
+			false,
+		},
+		{
+			"valid_refresh_token_metadata",
+			func(c *goidc.Client) {
+				c.RefreshTokenIsDisabled = true
+				c.RefreshTokenLifetimeSecs = 600
+			},
+			func(ctx oidc.Context) {
+				ctx.ClientTokenMetadataIsEnabled = true
+				ctx.ClientTokenMaxLifetimeSecs = 600
+				ctx.RefreshTokenLifetimeSecs = 600
+			},
+			true,
+		},
+		{
+			"refresh_token_metadata_not_enabled",
+			func(c *goidc.Client) {
+				c.RefreshTokenLifetimeSecs = 600
+			},
+			func(ctx oidc.Context) {
+				ctx.RefreshTokenLifetimeSecs = 600
+			},
+			false,
+		},
+		{
+			"refresh_token_lifetime_exceeds_limit",
+			func(c *goidc.Client) {
+				c.RefreshTokenLifetimeSecs = 601
+			},
+			func(ctx oidc.Context) {
+				ctx.ClientTokenMetadataIsEnabled = true
+				ctx.ClientTokenMaxLifetimeSecs = 600
+				ctx.RefreshTokenLifetimeSecs = 600
+			},
+			false,
+		},
+		{
+			"valid_cors_origins",
+			func(c *goidc.Client) {
+				c.AllowedCORSOrigins = []string{"https://app.example.com", "http://localhost:3000"}
+			},
+			func(ctx oidc.Context) {
+				ctx.CORSIsEnabled = true
+			},
+			true,
+		},
+		{
+			"cors_origin_with_path",
+			func(c *goidc.Client) {
+				c.AllowedCORSOrigins = []string{"https://app.example.com/path"}
+			},
+			func(ctx oidc.Context) {
+				ctx.CORSIsEnabled = true
+			},
+			false,
+		},
+		{
+			"valid_source_cidrs",
+			func(c *goidc.Client) {
+				c.AllowedSourceCIDRs = []string{"203.0.113.0/24", "2001:db8::/32"}
+			},
+			func(ctx oidc.Context) {},
+			true,
+		},
+		{
+			"invalid_source_cidr",
+			func(c *goidc.Client) {
+				c.AllowedSourceCIDRs = []string{"203.0.113.1"}
+			},
+			func(ctx oidc.Context) {},
+			false,
+		},
+		{
+			"cors_not_enabled",
+			func(c *goidc.Client) {
+				c.AllowedCORSOrigins = []string{"https://app.example.com"}
+			},
+			func(ctx oidc.Context) {},
+			false,
+		},
+		{
+			"policy_uri_must_be_https_when_verified",
+			func(c *goidc.Client) {
+				c.PolicyURI = "http://example.com/policy"
+			},
+			func(ctx oidc.Context) {
+				ctx.DCRClientURIsAreVerified = true
+			},
+			false,
+		},
+		{
+			"localized_tos_uri_must_be_https_when_verified",
+			func(c *goidc.Client) {
+				c.SetLocalizedAttribute("tos_uri", "fr", "http://example.com/tos")
+			},
+			func(ctx oidc.Context) {
+				ctx.DCRClientURIsAreVerified = true
+			},
+			false,
+		},
+		{
+			"http_policy_uri_without_verification",
+			func(c *goidc.Client) {
+				c.PolicyURI = "http://example.com/policy"
+			},
+			func(ctx oidc.Context) {},
+			true,
+		},
+		{
+			"invalid_client_uri_scheme",
+			func(c *goidc.Client) {
+				c.ClientURI = "javascript:alert(1)"
+			},
+			func(ctx oidc.Context) {},
+			false,
+		},
+		{
+			"jwks_uri_must_be_https",
+			func(c *goidc.Client) {
+				c.PublicJWKS = nil
+				c.PublicJWKSURI = "http://example.com/jwks"
+			},
+			func(ctx oidc.Context) {},
+			false,
+		},
+		{
+			"jwks_and_jwks_uri_cannot_be_informed_together",
+			func(c *goidc.Client) {
+				c.PublicJWKS = []byte(`{"keys": []}`)
+				c.PublicJWKSURI = "https://example.com/jwks"
+			},
+			func(ctx oidc.Context) {},
+			false,
+		},
+		{
+			"jar_required",
+			func(c *goidc.Client) {
+				c.JARIsRequired = true
+				c.JARSigAlg = jose.PS256
+			},
+			func(ctx oidc.Context) {
+				ctx.JARIsEnabled = true
+				ctx.JARSigAlgs = []jose.SignatureAlgorithm{jose.PS256}
+			},
+			true,
+		},
+		{
+			"jar_required_but_not_enabled",
+			func(c *goidc.Client) {
+				c.JARIsRequired = true
+			},
+			func(ctx oidc.Context) {
+				ctx.JARIsEnabled = false
+			},
+			false,
+		},
+		{
+			"jar_required_with_none_sig_alg",
+			func(c *goidc.Client) {
+				c.JARIsRequired = true
+				c.JARSigAlg = goidc.NoneSignatureAlgorithm
+			},
+			func(ctx oidc.Context) {
+				ctx.JARIsEnabled = true
+				ctx.JARSigAlgs = []jose.SignatureAlgorithm{jose.PS256, goidc.NoneSignatureAlgorithm}
+			},
+			false,
+		},
+		{
+			"jar_sig_alg_not_supported",
+			func(c *goidc.Client) {
+				c.JARSigAlg = jose.RS256
+			},
+			func(ctx oidc.Context) {
+				ctx.JARIsEnabled = true
+				ctx.JARSigAlgs = []jose.SignatureAlgorithm{jose.PS256}
+			},
+			false,
+		},
+		{
+			"par_required",
+			func(c *goidc.Client) {
+				c.PARIsRequired = true
+			},
+			func(ctx oidc.Context) {
+				ctx.PARIsEnabled = true
+			},
+			true,
+		},
+		{
+			"par_required_but_not_enabled",
+			func(c *goidc.Client) {
+				c.PARIsRequired = true
+			},
+			func(ctx oidc.Context) {
+				ctx.PARIsEnabled = false
+			},
+			false,
+		},
+		{
+			"jwks_uri_not_allowed_by_uri_policy",
+			func(c *goidc.Client) {
+				c.PublicJWKS = nil
+				c.PublicJWKSURI = "https://127.0.0.1/jwks"
+			},
+			func(ctx oidc.Context) {
+				ctx.URIPolicyFunc = goidc.DenyPrivateNetworkURIs
+			},
+			false,
+		},
 	}
 
 	for _, testCase := range testCases {
@@ -255,3 +510,152 @@ func TestValidateRequest(t *testing.T) {
 		})
 	}
 }
+
+func TestValidatePublicJWKSURI_Fetch(t *testing.T) {
+	// Given.
+	client, _ := oidctest.NewClient(t)
+	jwk := oidctest.PrivatePS256JWK(t, "client_key", goidc.KeyUsageSignature)
+	jwks := oidctest.RawJWKS(jwk.Public())
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(jwks)
+	}))
+	t.Cleanup(server.Close)
+
+	ctx := oidctest.NewContext(t)
+	ctx.DCRJWKSURIIsFetched = true
+	ctx.HTTPClientFunc = func(_ context.Context) *http.Client {
+		return server.Client()
+	}
+	client.PublicJWKSURI = server.URL + "/jwks"
+
+	// When.
+	err := validatePublicJWKSURI(ctx, &client.ClientMetaInfo)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestValidatePublicJWKSURI_FetchInvalidJWKS(t *testing.T) {
+	// Given.
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"keys": [{"kty": "oct", "k": "c2VjcmV0"}]}`))
+	}))
+	t.Cleanup(server.Close)
+
+	ctx := oidctest.NewContext(t)
+	ctx.DCRJWKSURIIsFetched = true
+	ctx.HTTPClientFunc = func(_ context.Context) *http.Client {
+		return server.Client()
+	}
+	meta := &goidc.ClientMetaInfo{
+		PublicJWKSURI: server.URL + "/jwks",
+	}
+
+	// When.
+	err := validatePublicJWKSURI(ctx, meta)
+
+	// Then.
+	var oidcErr goidc.Error
+	if !errors.As(err, &oidcErr) {
+		t.Fatalf("invalid error type")
+	}
+
+	if oidcErr.Code != goidc.ErrorCodeInvalidClientMetadata {
+		t.Errorf("Code = %s, want %s", oidcErr.Code, goidc.ErrorCodeInvalidClientMetadata)
+	}
+}
+
+func TestValidateClientURIs_Verification(t *testing.T) {
+	// Given.
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/logo.png":
+			w.Header().Set("Content-Type", "image/png")
+		case "/not_an_image":
+			w.Header().Set("Content-Type", "text/html")
+		case "/policy":
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	ctx := oidctest.NewContext(t)
+	ctx.DCRClientURIsAreVerified = true
+	ctx.HTTPClientFunc = func(_ context.Context) *http.Client {
+		return server.Client()
+	}
+
+	testCases := []struct {
+		name          string
+		meta          goidc.ClientMetaInfo
+		shouldBeValid bool
+	}{
+		{
+			"valid_uris",
+			goidc.ClientMetaInfo{
+				LogoURI:   server.URL + "/logo.png",
+				PolicyURI: server.URL + "/policy",
+			},
+			true,
+		},
+		{
+			"logo_is_not_an_image",
+			goidc.ClientMetaInfo{
+				LogoURI: server.URL + "/not_an_image",
+			},
+			false,
+		},
+		{
+			"tos_uri_not_reachable",
+			goidc.ClientMetaInfo{
+				TermsOfServiceURI: server.URL + "/tos",
+			},
+			false,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// When.
+			err := validateClientURIs(ctx, &testCase.meta)
+
+			// Then.
+			isValid := err == nil
+			if isValid != testCase.shouldBeValid {
+				t.Errorf("isValid = %t, want %t", isValid, testCase.shouldBeValid)
+			}
+		})
+	}
+}
+
+func TestValidateClientURIs_Order(t *testing.T) {
+	// Given.
+	ctx := oidctest.NewContext(t)
+	meta := &goidc.ClientMetaInfo{
+		LogoURI:           "invalid",
+		ClientURI:         "invalid",
+		PolicyURI:         "invalid",
+		TermsOfServiceURI: "invalid",
+		LocalizedAttributes: map[string]string{
+			"client_uri#pt-BR": "invalid",
+		},
+	}
+
+	for range 10 {
+		// When.
+		err := validateClientURIs(ctx, meta)
+
+		// Then.
+		var oidcErr goidc.Error
+		if !errors.As(err, &oidcErr) {
+			t.Fatalf("invalid error type")
+		}
+
+		if oidcErr.Description != "invalid logo_uri" {
+			t.Fatalf("Description = %s, want invalid logo_uri", oidcErr.Description)
+		}
+	}
+}
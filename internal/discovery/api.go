@@ -1,12 +1,20 @@
 package discovery
 
 import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/luikyv/go-oidc/internal/oidc"
 )
 
-func RegisterHandlers(router *http.ServeMux, config *oidc.Configuration) {
+func RegisterHandlers(router oidc.Router, config *oidc.Configuration) {
 	router.HandleFunc(
 		"GET "+config.EndpointPrefix+config.EndpointJWKS,
 		oidc.Handler(config, handleJWKS),
@@ -16,17 +24,159 @@ func RegisterHandlers(router *http.ServeMux, config *oidc.Configuration) {
 		"GET "+config.EndpointPrefix+config.EndpointWellKnown,
 		oidc.Handler(config, handleWellKnown),
 	)
+
+	router.HandleFunc(
+		"GET "+config.EndpointPrefix+config.EndpointOAuthServerMetadata,
+		oidc.Handler(config, handleOAuthServerMetadata),
+	)
+
+	if config.WebFingerIsEnabled {
+		router.HandleFunc(
+			"GET "+config.EndpointPrefix+config.EndpointWebFinger,
+			oidc.Handler(config, handleWebFinger),
+		)
+	}
 }
 
 func handleWellKnown(ctx oidc.Context) {
 	openidConfig := oidcConfig(ctx)
-	if err := ctx.Write(openidConfig, http.StatusOK); err != nil {
+	if err := writeCacheable(ctx, openidConfig, ctx.DiscoveryCacheMaxAgeSecs); err != nil {
+		ctx.WriteError(err)
+	}
+}
+
+func handleOAuthServerMetadata(ctx oidc.Context) {
+	if err := writeCacheable(ctx, oauthConfig(ctx), ctx.DiscoveryCacheMaxAgeSecs); err != nil {
+		ctx.WriteError(err)
+	}
+}
+
+func handleWebFinger(ctx oidc.Context) {
+	resp, err := webFinger(ctx, ctx.Request.URL.Query().Get("resource"),
+		ctx.Request.URL.Query()["rel"])
+	if errors.Is(err, errUnknownResource) {
+		ctx.Response.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		ctx.WriteError(err)
+		return
+	}
+
+	ctx.Response.Header().Set("Content-Type", "application/jrd+json")
+	ctx.Response.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(ctx.Response).Encode(resp); err != nil {
 		ctx.WriteError(err)
 	}
 }
 
 func handleJWKS(ctx oidc.Context) {
-	if err := ctx.Write(ctx.PublicKeys(), http.StatusOK); err != nil {
+	if err := writeCacheable(ctx, ctx.PublicKeys(), jwksMaxAgeSecs(ctx)); err != nil {
 		ctx.WriteError(err)
 	}
 }
+
+// writeCacheable writes the object as JSON along with an ETag so clients can
+// revalidate their copies. A 304 response is sent if the client's copy is
+// still current.
+// If maxAgeSecs is zero, clients must revalidate before every use.
+// If compression is enabled, the body is gzip encoded for clients that accept
+// it. Since the encoded and the plain bodies differ, they have distinct ETags.
+func writeCacheable(ctx oidc.Context, obj any, maxAgeSecs int) error {
+	body, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+
+	hash := sha256.Sum256(body)
+	etag := base64.RawURLEncoding.EncodeToString(hash[:])
+
+	header := ctx.Response.Header()
+	gzipped := false
+	if ctx.DiscoveryCompressionIsEnabled {
+		header.Add("Vary", "Accept-Encoding")
+		gzipped = acceptsGzip(ctx.Request.Header.Values("Accept-Encoding"))
+	}
+	if gzipped {
+		etag += "-gzip"
+	}
+	etag = `"` + etag + `"`
+
+	header.Set("ETag", etag)
+	header.Del("Pragma")
+	if maxAgeSecs > 0 {
+		header.Set("Cache-Control", "public, max-age="+strconv.Itoa(maxAgeSecs))
+	} else {
+		header.Set("Cache-Control", "no-cache")
+	}
+
+	if etagMatches(ctx.Request.Header.Get("If-None-Match"), etag) {
+		ctx.Response.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
+	if gzipped {
+		body, err = gzipEncode(body)
+		if err != nil {
+			return err
+		}
+		header.Set("Content-Encoding", "gzip")
+	}
+
+	header.Set("Content-Type", "application/json")
+	ctx.Response.WriteHeader(http.StatusOK)
+	_, err = ctx.Response.Write(body)
+	return err
+}
+
+// acceptsGzip returns whether the Accept-Encoding header values allow gzip
+// encoded responses, as defined in RFC 9110.
+func acceptsGzip(acceptEncoding []string) bool {
+	accepted := false
+	for _, value := range acceptEncoding {
+		for _, coding := range strings.Split(value, ",") {
+			name, params, _ := strings.Cut(coding, ";")
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name != "gzip" && name != "*" {
+				continue
+			}
+
+			q := 1.0
+			for _, param := range strings.Split(params, ";") {
+				key, val, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if strings.EqualFold(key, "q") {
+					q, _ = strconv.ParseFloat(strings.TrimSpace(val), 64)
+				}
+			}
+
+			// An explicit gzip coding takes precedence over the wildcard.
+			if name == "gzip" {
+				return q > 0
+			}
+			accepted = q > 0
+		}
+	}
+	return accepted
+}
+
+func gzipEncode(body []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(body); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
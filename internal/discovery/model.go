@@ -18,6 +18,7 @@ type openIDConfiguration struct {
 	ResponseModes                       []goidc.ResponseMode          `json:"response_modes_supported,omitempty"`
 	GrantTypes                          []goidc.GrantType             `json:"grant_types_supported"`
 	Scopes                              []string                      `json:"scopes_supported"`
+	ScopeDescriptions                   map[string]string             `json:"scope_descriptions,omitempty"`
 	UserClaimsSupported                 []string                      `json:"claims_supported,omitempty"`
 	ClaimTypesSupported                 []goidc.ClaimType             `json:"claim_types_supported,omitempty"`
 	SubIdentifierTypes                  []goidc.SubjectIdentifierType `json:"subject_types_supported,omitempty"`
@@ -58,10 +59,32 @@ type openIDConfiguration struct {
 	CodeChallengeMethods    []goidc.CodeChallengeMethod `json:"code_challenge_methods_supported,omitempty"`
 }
 
+// oauthServerConfiguration is the authorization server metadata defined by
+// RFC 8414. The OpenID Connect specific fields that are always present in
+// openIDConfiguration are shadowed so they are omitted.
+type oauthServerConfiguration struct {
+	openIDConfiguration
+	UserinfoEndpoint     string                    `json:"userinfo_endpoint,omitempty"`
+	IDTokenSigAlgs       []jose.SignatureAlgorithm `json:"id_token_signing_alg_values_supported,omitempty"`
+	UserInfoSigAlgs      []jose.SignatureAlgorithm `json:"userinfo_signing_alg_values_supported,omitempty"`
+	ClaimsParamIsEnabled bool                      `json:"claims_parameter_supported,omitempty"`
+}
+
 type openIDMTLSConfiguration struct {
-	TokenEndpoint              string `json:"token_endpoint"`
+	TokenEndpoint              string `json:"token_endpoint,omitempty"`
 	ParEndpoint                string `json:"pushed_authorization_request_endpoint,omitempty"`
-	UserinfoEndpoint           string `json:"userinfo_endpoint"`
+	UserinfoEndpoint           string `json:"userinfo_endpoint,omitempty"`
 	ClientRegistrationEndpoint string `json:"registration_endpoint,omitempty"`
 	IntrospectionEndpoint      string `json:"introspection_endpoint,omitempty"`
 }
+
+// webFingerResponse is the JSON Resource Descriptor defined by RFC 7033.
+type webFingerResponse struct {
+	Subject string          `json:"subject"`
+	Links   []webFingerLink `json:"links"`
+}
+
+type webFingerLink struct {
+	Rel  string `json:"rel"`
+	Href string `json:"href"`
+}
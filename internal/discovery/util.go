@@ -1,13 +1,34 @@
 package discovery
 
 import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/go-jose/go-jose/v4"
 	"github.com/luikyv/go-oidc/internal/oidc"
+	"github.com/luikyv/go-oidc/internal/timeutil"
+	"github.com/luikyv/go-oidc/pkg/goidc"
 )
 
+const webFingerRelIssuer = "http://openid.net/specs/connect/1.0/issuer"
+
+var errUnknownResource = errors.New("unknown resource")
+
 func oidcConfig(ctx oidc.Context) openIDConfiguration {
 	var scopes []string
+	// The descriptions of the scopes are not standard metadata, but let consent
+	// pages of other parties show them.
+	var scopeDescriptions map[string]string
 	for _, scope := range ctx.Scopes {
 		scopes = append(scopes, scope.ID)
+		if scope.Description == "" {
+			continue
+		}
+		if scopeDescriptions == nil {
+			scopeDescriptions = map[string]string{}
+		}
+		scopeDescriptions[scope.ID] = scope.Description
 	}
 	config := openIDConfiguration{
 		Issuer:                       ctx.Host,
@@ -24,6 +45,7 @@ func oidcConfig(ctx oidc.Context) openIDConfiguration {
 		IDTokenSigAlgs:               ctx.UserSigAlgs,
 		UserInfoSigAlgs:              ctx.UserSigAlgs,
 		Scopes:                       scopes,
+		ScopeDescriptions:            scopeDescriptions,
 		TokenAuthnMethods:            ctx.TokenAuthnMethods,
 		TokenAuthnSigAlgs:            ctx.TokenAuthnSigAlgs(),
 		IssuerResponseParamIsEnabled: ctx.IssuerRespParamIsEnabled,
@@ -50,7 +72,7 @@ func oidcConfig(ctx oidc.Context) openIDConfiguration {
 		config.JARByReferenceIsEnabled = ctx.JARByReferenceIsEnabled
 		config.JARRequestURIRegistrationIsRequired = ctx.JARRequestURIRegistrationIsRequired
 		if ctx.JAREncIsEnabled {
-			config.JARKeyEncAlgs = ctx.JARKeyEncAlgs
+			config.JARKeyEncAlgs = jarKeyEncAlgs(ctx)
 			config.JARContentEncAlgs = ctx.JARContentEncAlgs
 		}
 	}
@@ -82,24 +104,29 @@ func oidcConfig(ctx oidc.Context) openIDConfiguration {
 	if ctx.MTLSIsEnabled {
 		config.TLSBoundTokensIsEnabled = ctx.MTLSTokenBindingIsEnabled
 
-		config.MTLSConfig = &openIDMTLSConfiguration{
-			TokenEndpoint:    ctx.MTLSBaseURL() + ctx.EndpointToken,
-			UserinfoEndpoint: ctx.MTLSBaseURL() + ctx.EndpointUserInfo,
-		}
+		config.MTLSConfig = &openIDMTLSConfiguration{}
+		setMTLSEndpoint(ctx, goidc.EndpointToken, ctx.EndpointToken,
+			&config.TokenEndpoint, &config.MTLSConfig.TokenEndpoint)
+		setMTLSEndpoint(ctx, goidc.EndpointUserInfo, ctx.EndpointUserInfo,
+			&config.UserinfoEndpoint, &config.MTLSConfig.UserinfoEndpoint)
 
 		if ctx.PARIsEnabled {
-			config.MTLSConfig.ParEndpoint = ctx.MTLSBaseURL() + ctx.EndpointPushedAuthorization
+			setMTLSEndpoint(ctx, goidc.EndpointPushedAuthorization, ctx.EndpointPushedAuthorization,
+				&config.PAREndpoint, &config.MTLSConfig.ParEndpoint)
 		}
 
 		if ctx.DCRIsEnabled {
-			config.MTLSConfig.ClientRegistrationEndpoint = ctx.MTLSBaseURL() + ctx.EndpointDCR
+			setMTLSEndpoint(ctx, goidc.EndpointDCR, ctx.EndpointDCR,
+				&config.ClientRegistrationEndpoint, &config.MTLSConfig.ClientRegistrationEndpoint)
 		}
 
-		if ctx.TokenIntrospectionIsEnabled {
+		// The introspection and revocation endpoints are advertised on the
+		// mutual TLS host, unless they are only served on the main one.
+		if ctx.TokenIntrospectionIsEnabled && ctx.MTLSEndpointHost(goidc.EndpointIntrospection) != goidc.EndpointHostMain {
 			config.TokenIntrospectionEndpoint = ctx.MTLSBaseURL() + ctx.EndpointIntrospection
 		}
 
-		if ctx.TokenRevocationIsEnabled {
+		if ctx.TokenRevocationIsEnabled && ctx.MTLSEndpointHost(goidc.EndpointTokenRevocation) != goidc.EndpointHostMain {
 			config.TokenRevocationEndpoint = ctx.MTLSBaseURL() + ctx.EndpointTokenRevocation
 		}
 	}
@@ -112,8 +139,111 @@ func oidcConfig(ctx oidc.Context) openIDConfiguration {
 	}
 
 	if ctx.PKCEIsEnabled {
-		config.CodeChallengeMethods = ctx.PKCEChallengeMethods
+		config.CodeChallengeMethods = ctx.CodeChallengeMethods()
 	}
 
 	return config
 }
+
+// setMTLSEndpoint advertises the endpoint according to the hosts serving it.
+// An endpoint only served on the mutual TLS host replaces the main URL as well,
+// since clients not using mutual TLS cannot reach it elsewhere.
+func setMTLSEndpoint(
+	ctx oidc.Context,
+	endpoint goidc.Endpoint,
+	path string,
+	mainURL *string,
+	aliasURL *string,
+) {
+	switch ctx.MTLSEndpointHost(endpoint) {
+	case goidc.EndpointHostMain:
+		return
+	case goidc.EndpointHostMTLS:
+		*mainURL = ctx.MTLSBaseURL() + path
+	}
+	*aliasURL = ctx.MTLSBaseURL() + path
+}
+
+// oauthConfig returns the RFC 8414 metadata for plain OAuth clients.
+// It shares the fields of the OpenID configuration, except for the ones
+// specific to OpenID Connect.
+func oauthConfig(ctx oidc.Context) oauthServerConfiguration {
+	config := oidcConfig(ctx)
+	config.UserClaimsSupported = nil
+	config.ClaimTypesSupported = nil
+	config.SubIdentifierTypes = nil
+	config.IDTokenKeyEncAlgs = nil
+	config.IDTokenContentEncAlgs = nil
+	config.UserInfoKeyEncAlgs = nil
+	config.UserInfoContentEncAlgs = nil
+	config.ACRs = nil
+	config.DisplayValues = nil
+	return oauthServerConfiguration{openIDConfiguration: config}
+}
+
+// webFinger answers queries for the issuer of the user identified by resource.
+// If rels are informed, only their links are returned as defined by RFC 7033.
+func webFinger(ctx oidc.Context, resource string, rels []string) (webFingerResponse, error) {
+	if resource == "" {
+		return webFingerResponse{}, goidc.NewError(goidc.ErrorCodeInvalidRequest,
+			"the resource parameter is required")
+	}
+
+	issuer := ctx.Host
+	if ctx.ResolveIssuerFunc != nil {
+		var err error
+		issuer, err = ctx.ResolveIssuerFunc(ctx.Request, resource)
+		if err != nil {
+			return webFingerResponse{}, fmt.Errorf("%w: %w", errUnknownResource, err)
+		}
+	}
+
+	resp := webFingerResponse{
+		Subject: resource,
+		Links:   []webFingerLink{},
+	}
+	if len(rels) == 0 || slices.Contains(rels, webFingerRelIssuer) {
+		resp.Links = append(resp.Links, webFingerLink{
+			Rel:  webFingerRelIssuer,
+			Href: issuer,
+		})
+	}
+	return resp, nil
+}
+
+// jwksMaxAgeSecs returns for how long clients can cache the JWKS. The max age
+// is shortened when a key certificate is about to expire, so clients fetch the
+// keys that replace it in time.
+func jwksMaxAgeSecs(ctx oidc.Context) int {
+	maxAgeSecs := ctx.DiscoveryCacheMaxAgeSecs
+	now := timeutil.Now()
+	for _, key := range ctx.PrivateJWKS.Keys {
+		for _, cert := range key.Certificates {
+			secsToExpiry := int(cert.NotAfter.Sub(now).Seconds())
+			if secsToExpiry < maxAgeSecs {
+				maxAgeSecs = max(secsToExpiry, 0)
+			}
+		}
+	}
+	return maxAgeSecs
+}
+
+// jarKeyEncAlgs returns the key management algorithms clients can encrypt
+// request objects with, which are limited to the ones of the keys advertised
+// for request object encryption if any is marked.
+func jarKeyEncAlgs(ctx oidc.Context) []jose.KeyAlgorithm {
+	if len(ctx.JAREncKeyIDs) == 0 {
+		return ctx.JARKeyEncAlgs
+	}
+
+	var algs []jose.KeyAlgorithm
+	for _, alg := range ctx.JARKeyEncAlgs {
+		for _, keyID := range ctx.JAREncKeyIDs {
+			if key, ok := ctx.PrivateKey(keyID); ok && jose.KeyAlgorithm(key.Algorithm) == alg {
+				algs = append(algs, alg)
+				break
+			}
+		}
+	}
+	return algs
+}
@@ -1,12 +1,23 @@
 package discovery
 
 import (
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-jose/go-jose/v4"
 	"github.com/google/go-cmp/cmp"
 	"github.com/luikyv/go-oidc/internal/oidc"
 	"github.com/luikyv/go-oidc/internal/oidctest"
+	"github.com/luikyv/go-oidc/internal/timeutil"
 	"github.com/luikyv/go-oidc/pkg/goidc"
 )
 
@@ -228,3 +239,390 @@ func TestOIDCConfig_WithVariants(t *testing.T) {
 		t.Error(diff)
 	}
 }
+
+func TestOAuthConfig(t *testing.T) {
+	// Given.
+	ctx := oidctest.NewContext(t)
+	ctx.PARIsEnabled = true
+	ctx.PARIsRequired = true
+	ctx.EndpointPushedAuthorization = "/par"
+	ctx.TokenIntrospectionIsEnabled = true
+	ctx.EndpointIntrospection = "/introspect"
+	ctx.TokenRevocationIsEnabled = true
+	ctx.EndpointTokenRevocation = "/revoke"
+
+	// When.
+	config := oauthConfig(ctx)
+
+	// Then.
+	configBytes, err := json.Marshal(config)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var metadata map[string]any
+	if err := json.Unmarshal(configBytes, &metadata); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, field := range []string{
+		"issuer",
+		"authorization_endpoint",
+		"token_endpoint",
+		"pushed_authorization_request_endpoint",
+		"require_pushed_authorization_requests",
+		"introspection_endpoint",
+		"revocation_endpoint",
+	} {
+		if _, ok := metadata[field]; !ok {
+			t.Errorf("the field %s must be present", field)
+		}
+	}
+
+	for _, field := range []string{
+		"userinfo_endpoint",
+		"id_token_signing_alg_values_supported",
+		"userinfo_signing_alg_values_supported",
+		"claims_parameter_supported",
+		"claims_supported",
+	} {
+		if _, ok := metadata[field]; ok {
+			t.Errorf("the openid connect field %s must not be present", field)
+		}
+	}
+}
+
+func TestOIDCConfig_ScopeDescriptions(t *testing.T) {
+	// Given.
+	emailScope := goidc.ScopeEmail
+	emailScope.Description = "Your email address."
+	ctx := oidctest.NewContext(t)
+	ctx.Scopes = []goidc.Scope{goidc.ScopeOpenID, emailScope}
+
+	// When.
+	config := oidcConfig(ctx)
+
+	// Then.
+	want := map[string]string{goidc.ScopeEmail.ID: "Your email address."}
+	if diff := cmp.Diff(config.ScopeDescriptions, want); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestWebFinger(t *testing.T) {
+	// Given.
+	ctx := oidctest.NewContext(t)
+
+	// When.
+	resp, err := webFinger(ctx, "acct:random@example.com",
+		[]string{"http://openid.net/specs/connect/1.0/issuer"})
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := webFingerResponse{
+		Subject: "acct:random@example.com",
+		Links: []webFingerLink{
+			{Rel: "http://openid.net/specs/connect/1.0/issuer", Href: ctx.Host},
+		},
+	}
+	if diff := cmp.Diff(resp, want); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestWebFinger_OtherRel(t *testing.T) {
+	// Given.
+	ctx := oidctest.NewContext(t)
+
+	// When.
+	resp, err := webFinger(ctx, "acct:random@example.com", []string{"random_rel"})
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(resp.Links) != 0 {
+		t.Errorf("no links should be returned, got %v", resp.Links)
+	}
+}
+
+func TestWebFinger_UnknownResource(t *testing.T) {
+	// Given.
+	ctx := oidctest.NewContext(t)
+	ctx.ResolveIssuerFunc = func(r *http.Request, resource string) (string, error) {
+		return "", errors.New("random error")
+	}
+
+	// When.
+	_, err := webFinger(ctx, "acct:random@example.com", nil)
+
+	// Then.
+	if !errors.Is(err, errUnknownResource) {
+		t.Errorf("err = %v, want %v", err, errUnknownResource)
+	}
+}
+
+func TestWebFinger_MissingResource(t *testing.T) {
+	// Given.
+	ctx := oidctest.NewContext(t)
+
+	// When.
+	_, err := webFinger(ctx, "", nil)
+
+	// Then.
+	if err == nil {
+		t.Fatal("the resource must be required")
+	}
+}
+
+func TestWriteCacheable(t *testing.T) {
+	// Given.
+	ctx := oidctest.NewContext(t)
+	obj := map[string]any{"random_claim": "random_value"}
+
+	// When.
+	err := writeCacheable(ctx, obj, 60)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	resp := ctx.Response.(*httptest.ResponseRecorder)
+	if resp.Code != http.StatusOK {
+		t.Errorf("status code = %d, want %d", resp.Code, http.StatusOK)
+	}
+
+	if cacheControl := resp.Header().Get("Cache-Control"); cacheControl != "public, max-age=60" {
+		t.Errorf("Cache-Control = %s, want public, max-age=60", cacheControl)
+	}
+
+	if resp.Header().Get("ETag") == "" {
+		t.Error("the etag must be informed")
+	}
+}
+
+func TestWriteCacheable_NotModified(t *testing.T) {
+	// Given.
+	ctx := oidctest.NewContext(t)
+	obj := map[string]any{"random_claim": "random_value"}
+	if err := writeCacheable(ctx, obj, 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	etag := ctx.Response.Header().Get("ETag")
+
+	ctx = oidctest.NewContext(t)
+	ctx.Request.Header.Set("If-None-Match", `"other_etag", W/`+etag)
+
+	// When.
+	err := writeCacheable(ctx, obj, 0)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	resp := ctx.Response.(*httptest.ResponseRecorder)
+	if resp.Code != http.StatusNotModified {
+		t.Errorf("status code = %d, want %d", resp.Code, http.StatusNotModified)
+	}
+
+	if resp.Body.Len() != 0 {
+		t.Error("the body must be empty when the content was not modified")
+	}
+
+	if cacheControl := resp.Header().Get("Cache-Control"); cacheControl != "no-cache" {
+		t.Errorf("Cache-Control = %s, want no-cache", cacheControl)
+	}
+}
+
+func TestWriteCacheable_Gzip(t *testing.T) {
+	// Given.
+	ctx := oidctest.NewContext(t)
+	ctx.DiscoveryCompressionIsEnabled = true
+	ctx.Request.Header.Set("Accept-Encoding", "deflate, gzip;q=0.8")
+	obj := map[string]any{"random_claim": "random_value"}
+
+	// When.
+	err := writeCacheable(ctx, obj, 0)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	resp := ctx.Response.(*httptest.ResponseRecorder)
+	if resp.Code != http.StatusOK {
+		t.Errorf("status code = %d, want %d", resp.Code, http.StatusOK)
+	}
+
+	if encoding := resp.Header().Get("Content-Encoding"); encoding != "gzip" {
+		t.Errorf("Content-Encoding = %s, want gzip", encoding)
+	}
+
+	if vary := resp.Header().Get("Vary"); vary != "Accept-Encoding" {
+		t.Errorf("Vary = %s, want Accept-Encoding", vary)
+	}
+
+	reader, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatalf("the body must be gzip encoded: %v", err)
+	}
+
+	var got map[string]any
+	if err := json.NewDecoder(reader).Decode(&got); err != nil {
+		t.Fatalf("could not decode the body: %v", err)
+	}
+
+	if diff := cmp.Diff(got, obj); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestWriteCacheable_GzipNotAccepted(t *testing.T) {
+	// Given.
+	ctx := oidctest.NewContext(t)
+	ctx.DiscoveryCompressionIsEnabled = true
+	ctx.Request.Header.Set("Accept-Encoding", "*, gzip;q=0")
+	obj := map[string]any{"random_claim": "random_value"}
+
+	// When.
+	err := writeCacheable(ctx, obj, 0)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	resp := ctx.Response.(*httptest.ResponseRecorder)
+	if encoding := resp.Header().Get("Content-Encoding"); encoding != "" {
+		t.Errorf("Content-Encoding = %s, want none", encoding)
+	}
+
+	if vary := resp.Header().Get("Vary"); vary != "Accept-Encoding" {
+		t.Errorf("Vary = %s, want Accept-Encoding", vary)
+	}
+
+	var got map[string]any
+	if err := json.Unmarshal(resp.Body.Bytes(), &got); err != nil {
+		t.Fatalf("could not decode the body: %v", err)
+	}
+}
+
+func TestAcceptsGzip(t *testing.T) {
+	testCases := []struct {
+		acceptEncoding []string
+		want           bool
+	}{
+		{nil, false},
+		{[]string{"gzip"}, true},
+		{[]string{"br", "GZIP;q=0.5"}, true},
+		{[]string{"deflate, br"}, false},
+		{[]string{"*"}, true},
+		{[]string{"gzip;q=0"}, false},
+		{[]string{"gzip;q=0, *"}, false},
+		{[]string{"identity;q=1, *;q=0"}, false},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(strings.Join(testCase.acceptEncoding, ","), func(t *testing.T) {
+			if got := acceptsGzip(testCase.acceptEncoding); got != testCase.want {
+				t.Errorf("acceptsGzip(%v) = %t, want %t", testCase.acceptEncoding, got, testCase.want)
+			}
+		})
+	}
+}
+
+func TestJWKSMaxAgeSecs(t *testing.T) {
+	// Given.
+	ctx := oidctest.NewContext(t)
+	ctx.DiscoveryCacheMaxAgeSecs = 3600
+
+	key := ctx.PrivateJWKS.Keys[0]
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    timeutil.Now().Add(-time.Hour),
+		NotAfter:     timeutil.Now().Add(10 * time.Minute),
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template,
+		key.Public().Key, key.Key)
+	if err != nil {
+		t.Fatalf("could not create the certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(certDER)
+	if err != nil {
+		t.Fatalf("could not parse the certificate: %v", err)
+	}
+	ctx.PrivateJWKS.Keys[0].Certificates = []*x509.Certificate{cert}
+
+	// When.
+	maxAgeSecs := jwksMaxAgeSecs(ctx)
+
+	// Then.
+	if maxAgeSecs > 600 || maxAgeSecs < 590 {
+		t.Errorf("max age = %d, want approximately 600", maxAgeSecs)
+	}
+}
+
+func TestOIDCConfig_JAREncryptionKeys(t *testing.T) {
+	// Given.
+	advertisedKey := oidctest.PrivateRSAOAEPJWK(t, "advertised_enc_key")
+	stagedKey := oidctest.PrivateRSAOAEPJWK(t, "staged_enc_key")
+	stagedKey.Algorithm = string(jose.RSA_OAEP_256)
+	config := &oidc.Configuration{
+		PrivateJWKS:       jose.JSONWebKeySet{Keys: []jose.JSONWebKey{advertisedKey, stagedKey}},
+		JARIsEnabled:      true,
+		JAREncIsEnabled:   true,
+		JARKeyEncAlgs:     []jose.KeyAlgorithm{jose.RSA_OAEP, jose.RSA_OAEP_256},
+		JARContentEncAlgs: []jose.ContentEncryption{jose.A128CBC_HS256},
+		JAREncKeyIDs:      []string{"advertised_enc_key"},
+	}
+	ctx := oidc.Context{Configuration: config}
+
+	// When.
+	got := oidcConfig(ctx)
+
+	// Then.
+	if diff := cmp.Diff(got.JARKeyEncAlgs, []jose.KeyAlgorithm{jose.RSA_OAEP}); diff != "" {
+		t.Error(diff)
+	}
+
+	jwks := ctx.PublicKeys()
+	if len(jwks.Keys) != 1 || jwks.Keys[0].KeyID != "advertised_enc_key" {
+		t.Errorf("only the advertised encryption key must be published, got %v", jwks.Keys)
+	}
+}
+
+func TestOIDCConfig_MTLSEndpointHosts(t *testing.T) {
+	// Given.
+	ctx := oidctest.NewContext(t)
+	ctx.MTLSIsEnabled = true
+	ctx.MTLSHost = "https://matls.example.com"
+	ctx.MTLSEndpointHosts = map[goidc.Endpoint]goidc.EndpointHost{
+		goidc.EndpointToken:    goidc.EndpointHostMTLS,
+		goidc.EndpointUserInfo: goidc.EndpointHostMain,
+	}
+
+	// When.
+	got := oidcConfig(ctx)
+
+	// Then.
+	if got.TokenEndpoint != ctx.MTLSBaseURL()+ctx.EndpointToken {
+		t.Errorf("TokenEndpoint = %s, want the mutual TLS host", got.TokenEndpoint)
+	}
+
+	if got.UserinfoEndpoint != ctx.BaseURL()+ctx.EndpointUserInfo {
+		t.Errorf("UserinfoEndpoint = %s, want the main host", got.UserinfoEndpoint)
+	}
+
+	want := openIDMTLSConfiguration{
+		TokenEndpoint: ctx.MTLSBaseURL() + ctx.EndpointToken,
+	}
+	if diff := cmp.Diff(*got.MTLSConfig, want); diff != "" {
+		t.Error(diff)
+	}
+}
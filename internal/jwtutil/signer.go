@@ -0,0 +1,104 @@
+package jwtutil
+
+import (
+	"encoding/json"
+	"reflect"
+	"sync"
+
+	"github.com/go-jose/go-jose/v4"
+)
+
+// maxCachedSigners bounds the number of signers kept, since the headers used
+// are defined by the callers.
+const maxCachedSigners = 256
+
+// signerCacheKey identifies a signer by the key ID, the algorithm and the
+// headers it was created with. The key material is not part of it, so a
+// rotated key with the same ID replaces the previous signer.
+type signerCacheKey struct {
+	keyID   string
+	alg     string
+	headers string
+}
+
+type cachedSigner struct {
+	key    any
+	signer jose.Signer
+}
+
+var signers = struct {
+	mu    sync.RWMutex
+	cache map[signerCacheKey]cachedSigner
+}{
+	cache: map[signerCacheKey]cachedSigner{},
+}
+
+// signerFor returns a signer for the JWK and options informed.
+// Creating a signer is relatively expensive, so signers are cached per key ID
+// and reused as long as the key material doesn't change.
+func signerFor(jwk jose.JSONWebKey, opts *jose.SignerOptions) (jose.Signer, error) {
+	cacheKey, ok := newSignerCacheKey(jwk, opts)
+	if !ok {
+		return newSigner(jwk, opts)
+	}
+
+	signers.mu.RLock()
+	cached, ok := signers.cache[cacheKey]
+	signers.mu.RUnlock()
+	if ok && cached.key == jwk.Key {
+		return cached.signer, nil
+	}
+
+	s, err := newSigner(jwk, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	signers.mu.Lock()
+	defer signers.mu.Unlock()
+	if len(signers.cache) >= maxCachedSigners {
+		signers.cache = map[signerCacheKey]cachedSigner{}
+	}
+	signers.cache[cacheKey] = cachedSigner{key: jwk.Key, signer: s}
+	return s, nil
+}
+
+func newSigner(jwk jose.JSONWebKey, opts *jose.SignerOptions) (jose.Signer, error) {
+	return jose.NewSigner(
+		jose.SigningKey{
+			Algorithm: jose.SignatureAlgorithm(jwk.Algorithm),
+			Key:       jwk.Key,
+		},
+		opts,
+	)
+}
+
+// newSignerCacheKey returns the key under which the signer for the JWK is
+// cached. Signers are only cached for keys with an ID whose material is held
+// by a pointer, e.g. *rsa.PrivateKey, so it can be compared cheaply. Headers
+// which embed the JWK or change per call are not cached either.
+func newSignerCacheKey(jwk jose.JSONWebKey, opts *jose.SignerOptions) (signerCacheKey, bool) {
+	if jwk.KeyID == "" || jwk.Key == nil || reflect.TypeOf(jwk.Key).Kind() != reflect.Pointer {
+		return signerCacheKey{}, false
+	}
+
+	cacheKey := signerCacheKey{
+		keyID: jwk.KeyID,
+		alg:   jwk.Algorithm,
+	}
+	if opts == nil {
+		return cacheKey, true
+	}
+
+	if opts.EmbedJWK || opts.NonceSource != nil {
+		return signerCacheKey{}, false
+	}
+
+	// Map keys are sorted when encoded, so equal headers produce the same key.
+	headers, err := json.Marshal(opts.ExtraHeaders)
+	if err != nil {
+		return signerCacheKey{}, false
+	}
+	cacheKey.headers = string(headers)
+	return cacheKey, true
+}
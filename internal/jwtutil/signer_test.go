@@ -0,0 +1,60 @@
+package jwtutil
+
+import (
+	"testing"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/luikyv/go-oidc/internal/oidctest"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+func TestSignerFor(t *testing.T) {
+	// Given.
+	jwk := oidctest.PrivatePS256JWK(t, "cached_key", goidc.KeyUsageSignature)
+	opts := (&jose.SignerOptions{}).WithType("jwt").WithHeader("kid", jwk.KeyID)
+
+	// When.
+	s1, err := signerFor(jwk, opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s2, err := signerFor(jwk, (&jose.SignerOptions{}).WithType("jwt").WithHeader("kid", jwk.KeyID))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s3, err := signerFor(jwk, (&jose.SignerOptions{}).WithType("at+jwt").WithHeader("kid", jwk.KeyID))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Then.
+	if s1 != s2 {
+		t.Error("the signer must be reused for the same key and headers")
+	}
+
+	if s1 == s3 {
+		t.Error("signers with different headers cannot be shared")
+	}
+}
+
+func TestSignerFor_KeyRotated(t *testing.T) {
+	// Given.
+	jwk := oidctest.PrivatePS256JWK(t, "rotated_key", goidc.KeyUsageSignature)
+	s1, err := signerFor(jwk, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	rotatedJWK := oidctest.PrivatePS256JWK(t, "rotated_key", goidc.KeyUsageSignature)
+
+	// When.
+	s2, err := signerFor(rotatedJWK, nil)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if s1 == s2 {
+		t.Error("the signer must be replaced when the key material changes")
+	}
+}
@@ -19,13 +19,7 @@ func Sign(
 	string,
 	error,
 ) {
-	signer, err := jose.NewSigner(
-		jose.SigningKey{
-			Algorithm: jose.SignatureAlgorithm(jwk.Algorithm),
-			Key:       jwk.Key,
-		},
-		opts,
-	)
+	signer, err := signerFor(jwk, opts)
 	if err != nil {
 		return "", err
 	}
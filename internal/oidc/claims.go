@@ -0,0 +1,271 @@
+package oidc
+
+import (
+	"slices"
+	"sync"
+
+	"github.com/luikyv/go-oidc/internal/strutil"
+	"github.com/luikyv/go-oidc/internal/timeutil"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+// ClaimsCache keeps the claims loaded from a [goidc.ClaimsSourceFunc] for as
+// long as the lifetime configured for each claim.
+// The source is only skipped when all the claims it returned previously are
+// still fresh, so claims without a lifetime are always reloaded.
+// The number of entries is bounded and the ones closest to expiring are
+// evicted first.
+type ClaimsCache struct {
+	// LifetimeSecs maps the claim names to how long their values are reused.
+	LifetimeSecs map[string]int
+
+	maxEntries int
+	mu         sync.Mutex
+	entries    map[claimsCacheKey]cachedClaims
+}
+
+type claimsCacheKey struct {
+	clientID string
+	subject  string
+	scopes   string
+}
+
+type cachedClaims struct {
+	claims             map[string]any
+	expiresAtTimestamp int
+}
+
+// NewClaimsCache creates a cache that reuses the claims for the lifetimes
+// informed and holds at most maxEntries.
+func NewClaimsCache(lifetimeSecs map[string]int, maxEntries int) *ClaimsCache {
+	return &ClaimsCache{
+		LifetimeSecs: lifetimeSecs,
+		maxEntries:   maxEntries,
+		entries:      map[claimsCacheKey]cachedClaims{},
+	}
+}
+
+func (c *ClaimsCache) get(key claimsCacheKey) (map[string]any, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	if timeutil.TimestampNow() >= entry.expiresAtTimestamp {
+		delete(c.entries, key)
+		return nil, false
+	}
+
+	return entry.claims, true
+}
+
+func (c *ClaimsCache) set(key claimsCacheKey, claims map[string]any) {
+	if len(claims) == 0 {
+		return
+	}
+
+	// The entry is as fresh as its claim with the shortest lifetime.
+	lifetimeSecs := -1
+	for claim := range claims {
+		secs := c.LifetimeSecs[claim]
+		if lifetimeSecs == -1 || secs < lifetimeSecs {
+			lifetimeSecs = secs
+		}
+	}
+
+	if lifetimeSecs <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := timeutil.TimestampNow()
+	for entryKey, entry := range c.entries {
+		if now >= entry.expiresAtTimestamp {
+			delete(c.entries, entryKey)
+		}
+	}
+
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.maxEntries {
+		var oldestKey claimsCacheKey
+		oldest := -1
+		for entryKey, entry := range c.entries {
+			if oldest == -1 || entry.expiresAtTimestamp < oldest {
+				oldestKey, oldest = entryKey, entry.expiresAtTimestamp
+			}
+		}
+		delete(c.entries, oldestKey)
+	}
+
+	c.entries[key] = cachedClaims{
+		claims:             claims,
+		expiresAtTimestamp: now + lifetimeSecs,
+	}
+}
+
+func newClaimsCacheKey(grantInfo goidc.GrantInfo) claimsCacheKey {
+	return claimsCacheKey{
+		clientID: grantInfo.ClientID,
+		subject:  grantInfo.Subject,
+		scopes:   grantInfo.ActiveScopes,
+	}
+}
+
+// FilterClaims applies the claims filter to the user claims that will be
+// returned to the client, if one is configured.
+func (ctx Context) FilterClaims(client *goidc.Client, claims map[string]any) map[string]any {
+	if ctx.FilterClaimsFunc == nil {
+		return claims
+	}
+
+	// Pass a copy so the filter can't change the claims stored in sessions.
+	claimsCopy := make(map[string]any, len(claims))
+	for k, v := range claims {
+		claimsCopy[k] = v
+	}
+	return ctx.FilterClaimsFunc(ctx, client, claimsCopy)
+}
+
+// FilterIDTokenClaims removes from the ID token claims the ones the grant
+// doesn't give access to according to the claim mappings.
+func (ctx Context) FilterIDTokenClaims(
+	grantInfo goidc.GrantInfo,
+	claims map[string]any,
+) map[string]any {
+	var requested map[string]goidc.ClaimObjectInfo
+	if grantInfo.Claims != nil {
+		requested = grantInfo.Claims.IDToken
+	}
+	return ctx.filterClaims(grantInfo, claims, requested)
+}
+
+// MinimizeIDTokenClaims removes from the ID token claims the ones mapped to
+// scopes that must only be returned by the user info endpoint according to the
+// ID token claims mode.
+// If no claim mappings are configured, [goidc.StandardClaimMappings] is used.
+func (ctx Context) MinimizeIDTokenClaims(
+	grantInfo goidc.GrantInfo,
+	claims map[string]any,
+	accessTokenIsIssued bool,
+) map[string]any {
+	if ctx.IDTokenClaimsMode == goidc.IDTokenClaimsAll ||
+		(ctx.IDTokenClaimsMode == goidc.IDTokenClaimsByResponseType && !accessTokenIsIssued) {
+		return claims
+	}
+
+	mappings := ctx.ClaimMappings
+	if mappings == nil {
+		mappings = goidc.StandardClaimMappings
+	}
+
+	var mappedClaims []string
+	for _, scopeClaims := range mappings {
+		mappedClaims = append(mappedClaims, scopeClaims...)
+	}
+
+	var requested map[string]goidc.ClaimObjectInfo
+	if grantInfo.Claims != nil {
+		requested = grantInfo.Claims.IDToken
+	}
+
+	isAllowed := func(name string) bool {
+		_, ok := requested[name]
+		return ok || !slices.Contains(mappedClaims, name)
+	}
+
+	minimizedClaims := make(map[string]any, len(claims))
+	for name, value := range claims {
+		if isAllowed(name) {
+			minimizedClaims[name] = value
+		}
+	}
+	filterClaimSources(minimizedClaims, isAllowed)
+	return minimizedClaims
+}
+
+// FilterUserInfoClaims removes from the user info claims the ones the grant
+// doesn't give access to according to the claim mappings.
+func (ctx Context) FilterUserInfoClaims(
+	grantInfo goidc.GrantInfo,
+	claims map[string]any,
+) map[string]any {
+	var requested map[string]goidc.ClaimObjectInfo
+	if grantInfo.Claims != nil {
+		requested = grantInfo.Claims.UserInfo
+	}
+	return ctx.filterClaims(grantInfo, claims, requested)
+}
+
+// filterClaims keeps the claims that are not mapped to any scope, the ones
+// mapped to the active scopes and the ones requested.
+func (ctx Context) filterClaims(
+	grantInfo goidc.GrantInfo,
+	claims map[string]any,
+	requested map[string]goidc.ClaimObjectInfo,
+) map[string]any {
+	if ctx.ClaimMappings == nil {
+		return claims
+	}
+
+	var mappedClaims, allowedClaims []string
+	scopes := strutil.SplitWithSpaces(grantInfo.ActiveScopes)
+	for scope, scopeClaims := range ctx.ClaimMappings {
+		mappedClaims = append(mappedClaims, scopeClaims...)
+		if slices.Contains(scopes, scope) {
+			allowedClaims = append(allowedClaims, scopeClaims...)
+		}
+	}
+
+	isAllowed := func(name string) bool {
+		_, ok := requested[name]
+		return ok || !slices.Contains(mappedClaims, name) || slices.Contains(allowedClaims, name)
+	}
+
+	filteredClaims := make(map[string]any, len(claims))
+	for name, value := range claims {
+		if isAllowed(name) {
+			filteredClaims[name] = value
+		}
+	}
+	filterClaimSources(filteredClaims, isAllowed)
+	return filteredClaims
+}
+
+// filterClaimSources removes the aggregated and distributed claims that are
+// not allowed along with the sources no longer referenced.
+func filterClaimSources(claims map[string]any, isAllowed func(string) bool) {
+	names, ok := claims[goidc.ClaimNames].(map[string]any)
+	if !ok {
+		return
+	}
+
+	filteredNames := map[string]any{}
+	referencedSources := map[any]bool{}
+	for name, source := range names {
+		if isAllowed(name) {
+			filteredNames[name] = source
+			referencedSources[source] = true
+		}
+	}
+
+	sources, _ := claims[goidc.ClaimSources].(map[string]any)
+	filteredSources := map[string]any{}
+	for source, info := range sources {
+		if referencedSources[source] {
+			filteredSources[source] = info
+		}
+	}
+
+	if len(filteredNames) == 0 {
+		delete(claims, goidc.ClaimNames)
+		delete(claims, goidc.ClaimSources)
+		return
+	}
+
+	claims[goidc.ClaimNames] = filteredNames
+	claims[goidc.ClaimSources] = filteredSources
+}
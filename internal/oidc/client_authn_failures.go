@@ -0,0 +1,109 @@
+package oidc
+
+import (
+	"sync"
+	"time"
+
+	"github.com/luikyv/go-oidc/internal/timeutil"
+)
+
+// ClientAuthnFailures counts the consecutive authentication failures of each
+// client by source address, so they can be informed to the client
+// authentication hooks.
+// The failures of a source are forgotten after ttl without new ones. Since
+// the number of sources is not bounded, at most maxEntries are kept and the
+// ones closest to expiring are evicted first.
+type ClientAuthnFailures struct {
+	ttl        time.Duration
+	maxEntries int
+	entries    map[clientAuthnSource]clientAuthnFailuresEntry
+	mu         sync.Mutex
+}
+
+// clientAuthnSource identifies a client authenticating from an address.
+type clientAuthnSource struct {
+	clientID string
+	address  string
+}
+
+type clientAuthnFailuresEntry struct {
+	count     int
+	expiresAt time.Time
+}
+
+// NewClientAuthnFailures creates a counter whose entries are kept for ttl after
+// the last failure and that holds at most maxEntries.
+func NewClientAuthnFailures(ttl time.Duration, maxEntries int) *ClientAuthnFailures {
+	return &ClientAuthnFailures{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[clientAuthnSource]clientAuthnFailuresEntry),
+	}
+}
+
+func (f *ClientAuthnFailures) count(source clientAuthnSource) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	entry, ok := f.entries[source]
+	if !ok {
+		return 0
+	}
+
+	if !timeutil.Now().Before(entry.expiresAt) {
+		delete(f.entries, source)
+		return 0
+	}
+
+	return entry.count
+}
+
+// fail records a failure and returns the number of consecutive failures.
+func (f *ClientAuthnFailures) fail(source clientAuthnSource) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := timeutil.Now()
+	entry, ok := f.entries[source]
+	if ok && !now.Before(entry.expiresAt) {
+		entry = clientAuthnFailuresEntry{}
+	}
+
+	if !ok && len(f.entries) >= f.maxEntries {
+		f.makeRoom(now)
+	}
+
+	entry.count++
+	entry.expiresAt = now.Add(f.ttl)
+	f.entries[source] = entry
+	return entry.count
+}
+
+func (f *ClientAuthnFailures) reset(source clientAuthnSource) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delete(f.entries, source)
+}
+
+// makeRoom removes the expired entries or, if there are none, the one closest
+// to expiring.
+func (f *ClientAuthnFailures) makeRoom(now time.Time) {
+	var oldestSource clientAuthnSource
+	var oldest time.Time
+	expired := false
+	for source, entry := range f.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(f.entries, source)
+			expired = true
+			continue
+		}
+		if oldest.IsZero() || entry.expiresAt.Before(oldest) {
+			oldestSource, oldest = source, entry.expiresAt
+		}
+	}
+
+	if !expired {
+		delete(f.entries, oldestSource)
+	}
+}
@@ -9,15 +9,51 @@ type Configuration struct {
 	ClientManager       goidc.ClientManager
 	AuthnSessionManager goidc.AuthnSessionManager
 	GrantSessionManager goidc.GrantSessionManager
+	ConsentManager      goidc.ConsentManager
+	UserSessionManager  goidc.UserSessionManager
+	// IntrospectionCache, if not nil, keeps the grant sessions looked up
+	// during introspection. IntrospectionCacheTTLSecs is for how long the
+	// default in memory cache keeps them.
+	IntrospectionCache        goidc.IntrospectionCache
+	IntrospectionCacheTTLSecs int
+	// ClientJWKSCache, if not nil, keeps the client JWKS fetched from jwks_uri
+	// to resolve the keys responses are encrypted with.
+	// ClientJWKSCacheTTLSecs is for how long they are kept.
+	ClientJWKSCache        *ClientJWKSCache
+	ClientJWKSCacheTTLSecs int
+	// SectorIdentifierCache, if not nil, keeps the redirect URIs fetched from
+	// sector_identifier_uri during registration.
+	SectorIdentifierCache *SectorIdentifierCache
+	// MaxAuthnSessions and MaxGrantSessions limit how many sessions the
+	// default in memory storages keep. Zero means no limit.
+	MaxAuthnSessions int
+	MaxGrantSessions int
+	// MaxAuthnSessionBytes and MaxGrantSessionBytes limit the approximate
+	// memory used by the sessions of the default in memory storages. Zero
+	// means no limit.
+	MaxAuthnSessionBytes int
+	MaxGrantSessionBytes int
+	// AsyncGrantSessionIsEnabled indicates whether the grant sessions of JWT
+	// access tokens are persisted in the background by GrantSessionWriter,
+	// which is created when the provider is built.
+	AsyncGrantSessionIsEnabled bool
+	GrantSessionQueueSize      int
+	GrantSessionSaveErrorFunc  goidc.GrantSessionSaveErrorFunc
+	GrantSessionWriter         *GrantSessionWriter
 
 	Profile goidc.Profile
 	// Host is the domain where the server runs. This value will be used as the
 	// authorization server issuer.
 	Host string
+	// IssuerAliases are former issuers of the server. They are still accepted
+	// as the issuer and the audience of inbound tokens and assertions, but
+	// only Host is used for the tokens and responses issued.
+	IssuerAliases []string
 	// PrivateJWKS contains the server JWKS with private and public information.
 	// When exposing it, the private information is removed.
 	PrivateJWKS             jose.JSONWebKeySet
 	HandleGrantFunc         goidc.HandleGrantFunc
+	TokenResponseHookFunc   goidc.TokenResponseHookFunc
 	TokenOptionsFunc        goidc.TokenOptionsFunc
 	Policies                []goidc.AuthnPolicy
 	Scopes                  []goidc.Scope
@@ -26,15 +62,37 @@ type Configuration struct {
 	ResponseTypes           []goidc.ResponseType
 	ResponseModes           []goidc.ResponseMode
 	AuthnSessionTimeoutSecs int
-	ACRs                    []goidc.ACR
-	DisplayValues           []goidc.DisplayValue
+	// AuthnSessionMaxLifetimeSecs limits for how long after its creation an
+	// authn session can be kept alive with ExtendExpiry.
+	AuthnSessionMaxLifetimeSecs int
+	// AuthzCodeLifetimeSecs is for how long authorization codes can be
+	// exchanged and AuthzCodeLength is how many characters they have.
+	AuthzCodeLifetimeSecs int
+	AuthzCodeLength       int
+	// TokenExpirationHintsIsEnabled indicates that token responses inform the
+	// absolute expiration of the access token, expires_at, and the lifetime of
+	// the refresh token, refresh_expires_in.
+	TokenExpirationHintsIsEnabled bool
+	TokenRenewalHintFunc          goidc.TokenRenewalHintFunc
+	// ParallelValidationIsEnabled indicates whether the independent
+	// validations of authorization and pushed authorization requests run
+	// concurrently.
+	ParallelValidationIsEnabled bool
+	ACRs                        []goidc.ACR
+	DisplayValues               []goidc.DisplayValue
 	// Claims defines the user claims that can be returned in the userinfo
 	// endpoint or in ID tokens.
 	// This will be published in the /.well-known/openid-configuration endpoint.
 	Claims             []string
 	ClaimTypes         []goidc.ClaimType
 	SubIdentifierTypes []goidc.SubjectIdentifierType
-	StaticClients      []*goidc.Client
+	// SubjectIdentifierFunc, if defined, transforms the subject of the user
+	// before it is issued to a client.
+	SubjectIdentifierFunc goidc.SubjectIdentifierFunc
+	StaticClients         []*goidc.Client
+	// StaticClientPaths are the files and directories the static clients are
+	// loaded from when the provider is created.
+	StaticClientPaths []string
 	// IssuerRespParamIsEnabled indicates if the "iss" parameter will be
 	// returned when redirecting the user back to the client application.
 	IssuerRespParamIsEnabled bool
@@ -45,10 +103,78 @@ type Configuration struct {
 	// TokenBindingIsRequired indicates that at least one mechanism of sender
 	// contraining tokens is required, either DPoP or client TLS.
 	TokenBindingIsRequired bool
+	BeforeRequestFunc      goidc.BeforeRequestFunc
+	AfterResponseFunc      goidc.AfterResponseFunc
 	RenderErrorFunc        goidc.RenderErrorFunc
 	NotifyErrorFunc        goidc.NotifyErrorFunc
+	NotifyTokenEventFunc   goidc.NotifyTokenEventFunc
+	// RequestLimits apply to every endpoint, unless overridden for the
+	// endpoint pattern in EndpointRequestLimits.
+	RequestLimits         goidc.RequestLimits
+	EndpointRequestLimits map[string]goidc.RequestLimits
+	// Tracer instruments the endpoints and the internal operations of the
+	// provider. Tracing is disabled if it is nil.
+	Tracer goidc.Tracer
+	// Metrics records the metrics of the provider. Metrics are disabled if it
+	// is nil.
+	Metrics          *Metrics
+	ClaimsSourceFunc goidc.ClaimsSourceFunc
+	// ClaimsCache keeps the claims loaded with ClaimsSourceFunc.
+	ClaimsCache *ClaimsCache
+	// Precomputed holds values derived from this configuration. It is set
+	// when the provider is built.
+	Precomputed *Precomputed
+	// ClaimMappings maps scopes to the user claims they give access to.
+	// When defined, the mapped claims are only returned in ID tokens and user
+	// info responses if their scope was granted or the client requested them
+	// with the claims parameter.
+	ClaimMappings    map[string][]string
+	FilterClaimsFunc goidc.FilterClaimsFunc
+	// IDTokenClaimsMode defines whether the claims mapped to scopes are placed
+	// in ID tokens or only returned by the user info endpoint.
+	IDTokenClaimsMode goidc.IDTokenClaimsMode
+	// WebFingerIsEnabled makes the server answer WebFinger queries for its
+	// issuer as defined by OpenID Connect Discovery.
+	WebFingerIsEnabled bool
+	ResolveIssuerFunc  goidc.ResolveIssuerFunc
+	// DiscoveryCacheMaxAgeSecs is for how long clients can cache the provider
+	// metadata and the JWKS without revalidating them.
+	DiscoveryCacheMaxAgeSecs int
+	// DiscoveryCompressionIsEnabled indicates that the provider metadata and
+	// the JWKS are gzip encoded for clients that accept it.
+	DiscoveryCompressionIsEnabled bool
+	// ConsentIsEnabled indicates that the consents granted by users are
+	// recorded, so they can be reused in later authorization requests.
+	ConsentIsEnabled bool
+	// UserSessionIsEnabled indicates that the accounts authenticated in each
+	// user agent are recorded in a user session identified by a cookie.
+	UserSessionIsEnabled    bool
+	UserSessionLifetimeSecs int
+	// SessionIDClaimIsEnabled indicates that the ID tokens carry the sid claim
+	// identifying the session of the user.
+	SessionIDClaimIsEnabled bool
+	// TokenEncryptionKey is the symmetric key used to encrypt stateless
+	// opaque tokens.
+	TokenEncryptionKey []byte
+	// CORSIsEnabled indicates that the token and user info endpoints answer
+	// cross origin requests coming from the origins allowed by the clients.
+	CORSIsEnabled bool
+	// TokenIssuers maps the custom access token formats registered to the
+	// issuers producing them.
+	TokenIssuers map[goidc.TokenFormat]goidc.TokenIssuer
+	// ClientTokenMetadataIsEnabled indicates that clients can define the
+	// format and lifetime of their access tokens through metadata.
+	ClientTokenMetadataIsEnabled bool
+	// ClientTokenMaxLifetimeSecs is the maximum access token lifetime a client
+	// can request through metadata.
+	ClientTokenMaxLifetimeSecs int
+	// CallbackBindingIsEnabled indicates that authentication sessions in
+	// progress can only be resumed by the user agent that started them.
+	CallbackBindingIsEnabled bool
 
 	EndpointWellKnown           string
+	EndpointOAuthServerMetadata string
+	EndpointWebFinger           string
 	EndpointJWKS                string
 	EndpointToken               string
 	EndpointAuthorize           string
@@ -81,15 +207,66 @@ type Configuration struct {
 	// will expire in the near future during private_key_jwt and
 	// client_secret_jwt.
 	AssertionLifetimeSecs int
+	// AssertionLeewayTimeSecs is the clock skew tolerated when validating the
+	// time claims of client assertions.
+	AssertionLeewayTimeSecs int
+	// CustomAssertionAudiences are accepted as the audience of client
+	// assertions and request objects in addition to the ones derived from the
+	// host and the request.
+	CustomAssertionAudiences []string
+	// AssertionAudiencesReplaceDefaults makes only CustomAssertionAudiences be
+	// accepted, e.g. when the provider runs behind a proxy rewriting paths.
+	AssertionAudiencesReplaceDefaults bool
+	// JWTLeewayTimeSecs is the clock skew tolerated when validating the time
+	// claims of JWTs sent to the provider. It is the default for the leeway
+	// of each specific validation, e.g. JARLeewayTimeSecs.
+	JWTLeewayTimeSecs int
+	// IDTokenHintLeewayTimeSecs is the clock skew tolerated when validating
+	// the time claims of ID token hints.
+	IDTokenHintLeewayTimeSecs int
 
 	DCRIsEnabled                   bool
 	DCRTokenRotationIsEnabled      bool
 	HandleDynamicClientFunc        goidc.HandleDynamicClientFunc
 	ValidateInitialAccessTokenFunc goidc.ValidateInitialAccessTokenFunc
+	// DCRInitialAccessTokenIsRequired indicates that clients can only register
+	// with initial access tokens issued by the provider. They are verified
+	// before ValidateInitialAccessTokenFunc is executed.
+	DCRInitialAccessTokenIsRequired bool
+	// DCROpenRegistrationIsEnabled allows clients to register without an
+	// initial access token. DCRRegistrationLimiter, created when the provider
+	// is built, allows at most DCRMaxRegistrationsPerIP registrations from the
+	// same IP address every DCRRegistrationWindowSecs seconds.
+	DCROpenRegistrationIsEnabled bool
+	DCRMaxRegistrationsPerIP     int
+	DCRRegistrationWindowSecs    int
+	DCRRegistrationLimiter       *RateLimiter
+	// DCRChallengeFunc, if set, is executed before open registrations, e.g. to
+	// verify a captcha or a proof of work.
+	DCRChallengeFunc goidc.DCRChallengeFunc
+	// DCRJWKSURIIsFetched indicates that the jwks_uri informed during
+	// registration is fetched so its content can be validated.
+	DCRJWKSURIIsFetched bool
+	// DCRClientURIsAreVerified indicates that logo_uri, client_uri, policy_uri
+	// and tos_uri informed during registration are fetched to make sure they
+	// are reachable.
+	DCRClientURIsAreVerified bool
+	// URIPolicyFunc decides whether URIs informed by clients can be fetched.
+	URIPolicyFunc goidc.URIPolicyFunc
+	// PrivateNetworkURIsAreDenied indicates that the HTTP clients used to make
+	// requests only connect to public addresses.
+	PrivateNetworkURIsAreDenied bool
 
 	TokenIntrospectionIsEnabled           bool
 	TokenIntrospectionAuthnMethods        []goidc.ClientAuthnType
 	IsClientAllowedTokenIntrospectionFunc goidc.IsClientAllowedFunc
+	// TokenIntrospectionBearerScope is the scope an access token must have to
+	// be accepted as a credential at the introspection endpoint.
+	TokenIntrospectionBearerScope string
+	FilterIntrospectionFunc       goidc.FilterIntrospectionFunc
+	// IsIntrospectionCallerTrustedFunc decides which callers of the
+	// introspection endpoint receive the reason why a token is not active.
+	IsIntrospectionCallerTrustedFunc goidc.IsIntrospectionCallerTrustedFunc
 
 	TokenRevocationIsEnabled           bool
 	TokenRevocationAuthnMethods        []goidc.ClientAuthnType
@@ -121,6 +298,14 @@ type Configuration struct {
 	JAREncIsEnabled   bool
 	JARKeyEncAlgs     []jose.KeyAlgorithm
 	JARContentEncAlgs []jose.ContentEncryption
+	// JAREncKeyIDs are the IDs of the encryption keys in PrivateJWKS
+	// advertised for request object encryption. If informed, the other
+	// encryption keys are not published, but they still decrypt request
+	// objects.
+	JAREncKeyIDs []string
+	// JARRetiredEncKeys are former request object encryption keys that are no
+	// longer published, but still decrypt request objects until they expire.
+	JARRetiredEncKeys []goidc.RetiredKey
 
 	// PARIsEnabled allows client to push authorization requests.
 	PARIsEnabled bool
@@ -131,12 +316,41 @@ type Configuration struct {
 	// PARAllowUnregisteredRedirectURI indicates whether the redirect URIs
 	// informed during PAR must be previously registered or not.
 	PARAllowUnregisteredRedirectURI bool
+	// PARClientCertBindingIsEnabled indicates that pushed authorization
+	// requests are bound to the client certificate used to push them, so the
+	// authorization code issued can only be exchanged with the same
+	// certificate.
+	PARClientCertBindingIsEnabled bool
 
 	MTLSIsEnabled              bool
 	MTLSHost                   string
 	MTLSTokenBindingIsEnabled  bool
 	MTLSTokenBindingIsRequired bool
 	ClientCertFunc             goidc.ClientCertFunc
+	// MTLSIsTerminatedByProxy indicates that the mutual TLS connections are
+	// terminated by a proxy that forwards the requests to the same handler
+	// serving the main host. The requests are then told apart by the client
+	// certificate returned by ClientCertFunc instead of the host.
+	MTLSIsTerminatedByProxy bool
+	// MTLSEndpointHosts defines on which hosts the endpoints are served.
+	// Endpoints not present are served on both the main and mutual TLS hosts.
+	MTLSEndpointHosts map[goidc.Endpoint]goidc.EndpointHost
+	// TLSClientCertPolicy, if defined, verifies the certificates of clients
+	// authenticating with tls_client_auth.
+	TLSClientCertPolicy *goidc.TLSClientCertPolicy
+	// ClientIPFunc returns the address of the client making the request. If
+	// nil, the remote address of the request is used.
+	ClientIPFunc goidc.ClientIPFunc
+	// BeforeClientAuthnFunc and AfterClientAuthnFunc allow throttling clients
+	// that fail to authenticate repeatedly.
+	BeforeClientAuthnFunc goidc.BeforeClientAuthnFunc
+	AfterClientAuthnFunc  goidc.AfterClientAuthnFunc
+	// ClientAuthnFailures, if not nil, counts the failures informed to the
+	// client authentication hooks.
+	ClientAuthnFailures *ClientAuthnFailures
+	// ClientAuthnFuncs maps the custom client authentication methods
+	// registered to the functions validating them.
+	ClientAuthnFuncs map[goidc.ClientAuthnType]goidc.ClientAuthnFunc
 
 	DPoPIsEnabled      bool
 	DPoPIsRequired     bool
@@ -148,10 +362,21 @@ type Configuration struct {
 	PKCEIsRequired             bool
 	PKCEDefaultChallengeMethod goidc.CodeChallengeMethod
 	PKCEChallengeMethods       []goidc.CodeChallengeMethod
+	// PKCEPlainIsDisallowed makes the plain code challenge method rejected
+	// even if it was informed in PKCEChallengeMethods.
+	PKCEPlainIsDisallowed bool
+	// PKCEMinVerifierEntropyBits is the minimum entropy estimated for code
+	// verifiers. If zero, only the verifier length is validated.
+	PKCEMinVerifierEntropyBits int
 
 	AuthDetailsIsEnabled   bool
 	AuthDetailTypes        []string
 	CompareAuthDetailsFunc goidc.CompareAuthDetailsFunc
+	// AuthDetailsClaimIsEnabled indicates whether the authorization details of
+	// the grant are returned in ID tokens and user info responses.
+	// AuthDetailsClaimFunc, if not nil, selects which ones are returned.
+	AuthDetailsClaimIsEnabled bool
+	AuthDetailsClaimFunc      goidc.AuthDetailsClaimFunc
 
 	ResourceIndicatorsIsEnabled bool
 	// ResourceIndicatorsIsRequired indicates that the resource parameter is
@@ -161,7 +386,17 @@ type Configuration struct {
 
 	HTTPClientFunc goidc.HTTPClientFunc
 	CheckJTIFunc   goidc.CheckJTIFunc
+	// IDFunc, if set, generates the IDs of sessions, callbacks, request URIs
+	// and clients.
+	IDFunc goidc.IDFunc
+	// CheckNonceFunc, if set, is used to reject nonces reused by a client
+	// when ID tokens are issued from the authorization endpoint.
+	CheckNonceFunc goidc.CheckNonceFunc
 
 	JWTBearerGrantClientAuthnIsRequired bool
 	HandleJWTBearerGrantAssertionFunc   goidc.HandleJWTBearerGrantAssertionFunc
+
+	// CustomGrantFuncs maps the custom grant types registered to their
+	// handlers.
+	CustomGrantFuncs map[goidc.GrantType]goidc.HandleCustomGrantFunc
 }
@@ -7,14 +7,22 @@ import (
 	"errors"
 	"html/template"
 	"net/http"
+	"net/netip"
+	"net/url"
 	"slices"
 	"strings"
 	"time"
 
 	"github.com/go-jose/go-jose/v4"
+	"github.com/luikyv/go-oidc/internal/strutil"
+	"github.com/luikyv/go-oidc/internal/timeutil"
 	"github.com/luikyv/go-oidc/pkg/goidc"
 )
 
+// defaultOpaqueTokenLength is the length of opaque access tokens issued to
+// clients that choose the opaque format through metadata.
+const defaultOpaqueTokenLength int = 50
+
 type Context struct {
 	Response http.ResponseWriter
 	Request  *http.Request
@@ -34,6 +42,12 @@ func NewContext(
 	}
 }
 
+// Router is where the endpoints of the provider are registered.
+// It is implemented by [http.ServeMux].
+type Router interface {
+	HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request))
+}
+
 func Handler(
 	config *Configuration,
 	exec func(ctx Context),
@@ -44,6 +58,9 @@ func Handler(
 }
 
 func (ctx Context) TokenAuthnSigAlgs() []jose.SignatureAlgorithm {
+	if ctx.Precomputed != nil {
+		return slices.Clip(ctx.Precomputed.tokenAuthnSigAlgs)
+	}
 	return ctx.clientAuthnSigAlgs(ctx.TokenAuthnMethods)
 }
 
@@ -56,6 +73,9 @@ func (ctx Context) IsClientAllowedTokenIntrospection(c *goidc.Client) bool {
 }
 
 func (ctx Context) TokenIntrospectionAuthnSigAlgs() []jose.SignatureAlgorithm {
+	if ctx.Precomputed != nil {
+		return slices.Clip(ctx.Precomputed.tokenIntrospectionAuthnSigAlgs)
+	}
 	return ctx.clientAuthnSigAlgs(ctx.TokenIntrospectionAuthnMethods)
 }
 
@@ -68,11 +88,17 @@ func (ctx Context) IsClientAllowedTokenRevocation(c *goidc.Client) bool {
 }
 
 func (ctx Context) TokenRevocationAuthnSigAlgs() []jose.SignatureAlgorithm {
+	if ctx.Precomputed != nil {
+		return slices.Clip(ctx.Precomputed.tokenRevocationAuthnSigAlgs)
+	}
 	return ctx.clientAuthnSigAlgs(ctx.TokenRevocationAuthnMethods)
 }
 
 func (ctx Context) ClientAuthnSigAlgs() []jose.SignatureAlgorithm {
-	return append(ctx.PrivateKeyJWTSigAlgs, ctx.ClientSecretJWTSigAlgs...)
+	if ctx.Precomputed != nil {
+		return slices.Clip(ctx.Precomputed.clientAuthnSigAlgs)
+	}
+	return slices.Concat(ctx.PrivateKeyJWTSigAlgs, ctx.ClientSecretJWTSigAlgs)
 }
 
 func (ctx Context) clientAuthnSigAlgs(methods []goidc.ClientAuthnType) []jose.SignatureAlgorithm {
@@ -98,6 +124,118 @@ func (ctx Context) ClientCert() (*x509.Certificate, error) {
 	return ctx.ClientCertFunc(ctx.Request)
 }
 
+// ClientIP returns the address of the client making the request.
+func (ctx Context) ClientIP() (netip.Addr, error) {
+	if ctx.ClientIPFunc != nil {
+		return ctx.ClientIPFunc(ctx.Request)
+	}
+
+	addrPort, err := netip.ParseAddrPort(ctx.Request.RemoteAddr)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	return addrPort.Addr().Unmap(), nil
+}
+
+// BeforeClientAuthn is executed before the credentials of the client are
+// validated. If it returns an error, the client must not be authenticated.
+func (ctx Context) BeforeClientAuthn(clientID string) error {
+	if ctx.BeforeClientAuthnFunc == nil {
+		return nil
+	}
+
+	var failures int
+	if ctx.ClientAuthnFailures != nil {
+		failures = ctx.ClientAuthnFailures.count(ctx.clientAuthnSource(clientID))
+	}
+	return ctx.BeforeClientAuthnFunc(ctx.Request, clientID, failures)
+}
+
+// ClientAuthnFunc returns the function validating the custom client
+// authentication method, if registered.
+func (ctx Context) ClientAuthnFunc(method goidc.ClientAuthnType) (goidc.ClientAuthnFunc, bool) {
+	f, ok := ctx.ClientAuthnFuncs[method]
+	return f, ok
+}
+
+// TokenIssuer returns the issuer registered for the custom token format.
+func (ctx Context) TokenIssuer(format goidc.TokenFormat) (goidc.TokenIssuer, bool) {
+	issuer, ok := ctx.TokenIssuers[format]
+	return issuer, ok
+}
+
+// CustomTokenID returns the identifier of a token produced by one of the
+// token issuers registered.
+func (ctx Context) CustomTokenID(token string) (string, bool) {
+	for _, issuer := range ctx.TokenIssuers {
+		if id, ok := issuer.ID(ctx, token); ok {
+			return id, true
+		}
+	}
+	return "", false
+}
+
+// ScopeInfos returns the human readable information of the scopes requested
+// that match one of the scopes of the server.
+func (ctx Context) ScopeInfos(scopes string) []goidc.ScopeInfo {
+	var infos []goidc.ScopeInfo
+	for _, requestedScope := range strutil.SplitWithSpaces(scopes) {
+		for _, scope := range ctx.Scopes {
+			if !scope.Matches(requestedScope) {
+				continue
+			}
+
+			infos = append(infos, goidc.ScopeInfo{
+				ID:                    requestedScope,
+				Title:                 scope.Title,
+				Description:           scope.Description,
+				LocalizedTitles:       scope.LocalizedTitles,
+				LocalizedDescriptions: scope.LocalizedDescriptions,
+			})
+			break
+		}
+	}
+	return infos
+}
+
+// SubjectIdentifier returns the subject identifier issued to the client for
+// the subject informed.
+// Subjects representing the client itself, e.g. in the client credentials
+// grant, are not transformed.
+func (ctx Context) SubjectIdentifier(c *goidc.Client, subject string) string {
+	if ctx.SubjectIdentifierFunc == nil || c == nil || subject == c.ID {
+		return subject
+	}
+	return ctx.SubjectIdentifierFunc(c, subject)
+}
+
+// AfterClientAuthn informs the result of the authentication of a registered
+// client along with its consecutive failures from the same address.
+func (ctx Context) AfterClientAuthn(clientID string, err error) {
+	var failures int
+	if ctx.ClientAuthnFailures != nil {
+		source := ctx.clientAuthnSource(clientID)
+		if err != nil {
+			failures = ctx.ClientAuthnFailures.fail(source)
+		} else {
+			ctx.ClientAuthnFailures.reset(source)
+		}
+	}
+
+	if ctx.AfterClientAuthnFunc == nil {
+		return
+	}
+	ctx.AfterClientAuthnFunc(ctx.Request, clientID, failures, err)
+}
+
+func (ctx Context) clientAuthnSource(clientID string) clientAuthnSource {
+	source := clientAuthnSource{clientID: clientID}
+	if ip, err := ctx.ClientIP(); err == nil {
+		source.address = ip.String()
+	}
+	return source
+}
+
 func (ctx Context) ValidateInitalAccessToken(token string) error {
 	if ctx.ValidateInitialAccessTokenFunc == nil {
 		return nil
@@ -106,6 +244,14 @@ func (ctx Context) ValidateInitalAccessToken(token string) error {
 	return ctx.ValidateInitialAccessTokenFunc(ctx.Request, token)
 }
 
+func (ctx Context) ValidateDCRChallenge() error {
+	if ctx.DCRChallengeFunc == nil {
+		return nil
+	}
+
+	return ctx.DCRChallengeFunc(ctx.Request)
+}
+
 func (ctx Context) HandleDynamicClient(c *goidc.ClientMetaInfo) error {
 	if ctx.HandleDynamicClientFunc == nil {
 		return nil
@@ -122,6 +268,15 @@ func (ctx Context) CheckJTI(jti string) error {
 	return ctx.CheckJTIFunc(ctx, jti)
 }
 
+func (ctx Context) CheckNonce(clientID, nonce string) error {
+	if ctx.CheckNonceFunc == nil {
+		return nil
+	}
+
+	return ctx.CheckNonceFunc(ctx, clientID, nonce,
+		timeutil.TimestampNow()+ctx.IDTokenLifetimeSecs)
+}
+
 func (ctx Context) RenderError(err error) error {
 	if ctx.RenderErrorFunc == nil {
 		// No need to call handleError here, since this error will end up being
@@ -134,6 +289,7 @@ func (ctx Context) RenderError(err error) error {
 }
 
 func (ctx Context) NotifyError(err error) {
+	ctx.recordEndpointErr(err)
 	if ctx.NotifyErrorFunc == nil {
 		return
 	}
@@ -141,13 +297,91 @@ func (ctx Context) NotifyError(err error) {
 	ctx.NotifyErrorFunc(ctx.Request, err)
 }
 
+// FilterIntrospection lets the token information returned to the caller of the
+// introspection endpoint be adjusted.
+func (ctx Context) FilterIntrospection(
+	caller goidc.IntrospectionCaller,
+	info *goidc.TokenInfo,
+) {
+	if ctx.FilterIntrospectionFunc == nil {
+		return
+	}
+
+	ctx.FilterIntrospectionFunc(ctx.Request, caller, info)
+}
+
+// IsIntrospectionCallerTrusted returns true if the caller of the introspection
+// endpoint can be informed why a token is not active.
+func (ctx Context) IsIntrospectionCallerTrusted(
+	caller goidc.IntrospectionCaller,
+) bool {
+	if ctx.IsIntrospectionCallerTrustedFunc == nil {
+		return false
+	}
+
+	return ctx.IsIntrospectionCallerTrustedFunc(ctx.Request, caller)
+}
+
+// NotifyTokenEvent informs that the grant session went through the event.
+func (ctx Context) NotifyTokenEvent(
+	eventType goidc.TokenEventType,
+	session *goidc.GrantSession,
+) {
+	ctx.recordTokenEvent(eventType, session)
+
+	if ctx.NotifyTokenEventFunc == nil {
+		return
+	}
+
+	ctx.NotifyTokenEventFunc(ctx.Request, goidc.TokenEvent{
+		Type:     eventType,
+		GrantID:  session.ID,
+		ClientID: session.ClientID,
+		Subject:  session.Subject,
+		TokenID:  session.TokenID,
+	})
+}
+
+// ClaimsFromSource returns the claims loaded from the claims source for the
+// grant, if one is configured.
+func (ctx Context) ClaimsFromSource(grantInfo goidc.GrantInfo) (map[string]any, error) {
+	if ctx.ClaimsSourceFunc == nil {
+		return nil, nil
+	}
+
+	key := newClaimsCacheKey(grantInfo)
+	if ctx.ClaimsCache != nil {
+		if claims, ok := ctx.ClaimsCache.get(key); ok {
+			return claims, nil
+		}
+	}
+
+	claims, err := ctx.ClaimsSourceFunc(ctx, grantInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	if ctx.ClaimsCache != nil {
+		ctx.ClaimsCache.set(key, claims)
+	}
+	return claims, nil
+}
+
 // AssertionAudiences returns the host names trusted by the server to validate
 // assertions.
 func (ctx Context) AssertionAudiences() []string {
-	audiences := []string{
-		ctx.Host,
-		ctx.BaseURL() + ctx.EndpointToken,
-		ctx.Host + ctx.Request.RequestURI,
+	if ctx.AssertionAudiencesReplaceDefaults {
+		return ctx.CustomAssertionAudiences
+	}
+
+	var audiences []string
+	for _, issuer := range ctx.Issuers() {
+		audiences = append(
+			audiences,
+			issuer,
+			issuer+ctx.EndpointPrefix+ctx.EndpointToken,
+			issuer+ctx.Request.RequestURI,
+		)
 	}
 	if ctx.MTLSIsEnabled {
 		audiences = append(
@@ -156,7 +390,22 @@ func (ctx Context) AssertionAudiences() []string {
 			ctx.MTLSHost+ctx.Request.RequestURI,
 		)
 	}
-	return audiences
+	return append(audiences, ctx.CustomAssertionAudiences...)
+}
+
+// RequestObjectAudiences returns the audiences accepted in request objects.
+func (ctx Context) RequestObjectAudiences() []string {
+	if ctx.AssertionAudiencesReplaceDefaults {
+		return ctx.CustomAssertionAudiences
+	}
+
+	return append(ctx.Issuers(), ctx.CustomAssertionAudiences...)
+}
+
+// Issuers returns the current issuer of the server followed by the former
+// ones still accepted in inbound tokens.
+func (ctx Context) Issuers() []string {
+	return append([]string{ctx.Host}, ctx.IssuerAliases...)
 }
 
 func (ctx Context) Policy(id string) goidc.AuthnPolicy {
@@ -175,7 +424,31 @@ func (ctx Context) AvailablePolicy(
 	policy goidc.AuthnPolicy,
 	ok bool,
 ) {
+	// When ACRs are requested, prefer the policies that can achieve them.
+	// If any ACR is essential, only the policies able to achieve one of the
+	// essential ones are considered.
+	acrs := session.RequestedACRs()
+	essentialACRs := session.EssentialACRs()
+	if len(essentialACRs) != 0 {
+		acrs = essentialACRs
+	}
+	for _, policy = range ctx.Policies {
+		if !policy.CanAchieve(acrs) {
+			continue
+		}
+		if ok = policy.SetUp(ctx.Request, client, session); ok {
+			return policy, true
+		}
+	}
+
+	if len(essentialACRs) != 0 {
+		return goidc.AuthnPolicy{}, false
+	}
+
 	for _, policy = range ctx.Policies {
+		if policy.CanAchieve(acrs) {
+			continue
+		}
 		if ok = policy.SetUp(ctx.Request, client, session); ok {
 			return policy, true
 		}
@@ -184,6 +457,30 @@ func (ctx Context) AvailablePolicy(
 	return goidc.AuthnPolicy{}, false
 }
 
+// AuthDetailsClaim returns the authorization details to be included in the ID
+// token or the user info response of the grant. If none is returned, the
+// claim must be omitted.
+func (ctx Context) AuthDetailsClaim(
+	client *goidc.Client,
+	grantInfo goidc.GrantInfo,
+) []goidc.AuthorizationDetail {
+	if !ctx.AuthDetailsIsEnabled || !ctx.AuthDetailsClaimIsEnabled {
+		return nil
+	}
+
+	details := grantInfo.ActiveAuthDetails
+	if details == nil {
+		details = grantInfo.GrantedAuthDetails
+	}
+
+	if len(details) == 0 || ctx.AuthDetailsClaimFunc == nil {
+		return details
+	}
+
+	// Pass a copy so the function can't change the details stored in sessions.
+	return ctx.AuthDetailsClaimFunc(ctx, client, grantInfo, slices.Clone(details))
+}
+
 func (ctx Context) CompareAuthDetails(
 	granted []goidc.AuthorizationDetail,
 	requested []goidc.AuthorizationDetail,
@@ -196,85 +493,199 @@ func (ctx Context) CompareAuthDetails(
 
 //---------------------------------------- CRUD ----------------------------------------//
 
-func (ctx Context) SaveClient(client *goidc.Client) error {
+func (ctx Context) SaveClient(client *goidc.Client) (err error) {
+	ctx, span := ctx.StartSpan("storage.SaveClient")
+	defer func() { EndSpan(span, err) }()
+
 	if err := ctx.ClientManager.Save(ctx.Context(), client); err != nil {
 		return goidc.Errorf(goidc.ErrorCodeInternalError, "internal error", err)
 	}
 	return nil
 }
 
-func (ctx Context) Client(id string) (*goidc.Client, error) {
+func (ctx Context) Client(id string) (_ *goidc.Client, err error) {
 	for _, staticClient := range ctx.StaticClients {
 		if staticClient.ID == id {
 			return staticClient, nil
 		}
 	}
 
-	return ctx.ClientManager.Client(ctx.Context(), id)
+	ctx, span := ctx.StartSpan("storage.Client")
+	defer func() { EndSpan(span, err) }()
+
+	c, err := ctx.ClientManager.Client(ctx.Context(), id)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.IsDisabled() {
+		return nil, goidc.ErrClientDisabled
+	}
+
+	return c, nil
 }
 
-func (ctx Context) DeleteClient(id string) error {
+func (ctx Context) DeleteClient(id string) (err error) {
+	ctx, span := ctx.StartSpan("storage.DeleteClient")
+	defer func() { EndSpan(span, err) }()
+
 	return ctx.ClientManager.Delete(ctx.Context(), id)
 }
 
-func (ctx Context) SaveGrantSession(session *goidc.GrantSession) error {
+func (ctx Context) SaveGrantSession(session *goidc.GrantSession) (err error) {
+	ctx, span := ctx.StartSpan("storage.SaveGrantSession")
+	defer func() { EndSpan(span, err) }()
+
+	ctx.evictIntrospectionCache(session.ID)
 	return ctx.GrantSessionManager.Save(
 		ctx.Context(),
 		session,
 	)
 }
 
+// SaveGrantSessionAsync enqueues the session to be saved in the background if
+// asynchronous persistence is enabled, waiting for room if the queue is full.
+// Otherwise, or if the writer was closed, the session is saved synchronously.
+func (ctx Context) SaveGrantSessionAsync(session *goidc.GrantSession) error {
+	if ctx.GrantSessionWriter == nil {
+		return ctx.SaveGrantSession(session)
+	}
+
+	if ctx.GrantSessionWriter.Enqueue(ctx.Context(), session) {
+		ctx.evictIntrospectionCache(session.ID)
+		return nil
+	}
+
+	if err := ctx.Context().Err(); err != nil {
+		return err
+	}
+	return ctx.SaveGrantSession(session)
+}
+
 func (ctx Context) GrantSessionByTokenID(
 	id string,
 ) (
-	*goidc.GrantSession,
-	error,
+	_ *goidc.GrantSession,
+	err error,
 ) {
+	ctx, span := ctx.StartSpan("storage.GrantSessionByTokenID")
+	defer func() { EndSpan(span, err) }()
+
 	return ctx.GrantSessionManager.SessionByTokenID(
 		ctx.Context(),
 		id,
 	)
 }
 
+// IntrospectionGrantSession returns the grant session of the token ID for
+// introspection. If the introspection cache is enabled, the session is served
+// from it when possible.
+func (ctx Context) IntrospectionGrantSession(tokenID string) (*goidc.GrantSession, error) {
+	if ctx.IntrospectionCache == nil {
+		return ctx.GrantSessionByTokenID(tokenID)
+	}
+
+	if session, err := ctx.IntrospectionCache.SessionByTokenID(ctx.Context(), tokenID); err == nil {
+		return session, nil
+	}
+
+	session, err := ctx.GrantSessionByTokenID(tokenID)
+	if err != nil {
+		return nil, err
+	}
+
+	// A failure to cache the session only means the next introspection
+	// reaches the storage again.
+	_ = ctx.IntrospectionCache.Save(ctx.Context(), session)
+	return session, nil
+}
+
+// evictIntrospectionCache removes the entries of the grant session from the
+// introspection cache, so changes such as revocations are seen right away.
+func (ctx Context) evictIntrospectionCache(grantID string) {
+	if ctx.IntrospectionCache == nil {
+		return
+	}
+	_ = ctx.IntrospectionCache.Delete(ctx.Context(), grantID)
+}
+
 func (ctx Context) GrantSessionByRefreshToken(
 	token string,
 ) (
-	*goidc.GrantSession,
-	error,
+	_ *goidc.GrantSession,
+	err error,
 ) {
+	ctx, span := ctx.StartSpan("storage.GrantSessionByRefreshToken")
+	defer func() { EndSpan(span, err) }()
+
 	return ctx.GrantSessionManager.SessionByRefreshToken(
 		ctx.Context(),
 		token,
 	)
 }
 
-func (ctx Context) DeleteGrantSession(id string) error {
+func (ctx Context) DeleteGrantSession(id string) (err error) {
+	ctx, span := ctx.StartSpan("storage.DeleteGrantSession")
+	defer func() { EndSpan(span, err) }()
+
+	ctx.evictIntrospectionCache(id)
 	return ctx.GrantSessionManager.Delete(ctx.Context(), id)
 }
 
-func (ctx Context) DeleteGrantSessionByAuthorizationCode(code string) error {
+// GrantSessionByAuthorizationCode returns an error if the grant session
+// manager does not implement [goidc.GrantSessionCodeFinder].
+func (ctx Context) GrantSessionByAuthorizationCode(
+	code string,
+) (
+	_ *goidc.GrantSession,
+	err error,
+) {
+	finder, ok := ctx.GrantSessionManager.(goidc.GrantSessionCodeFinder)
+	if !ok {
+		return nil, errors.New("the grant session manager cannot find sessions by authorization code")
+	}
+
+	ctx, span := ctx.StartSpan("storage.GrantSessionByAuthorizationCode")
+	defer func() { EndSpan(span, err) }()
+
+	return finder.SessionByAuthorizationCode(ctx.Context(), code)
+}
+
+func (ctx Context) DeleteGrantSessionByAuthorizationCode(code string) (err error) {
+	ctx, span := ctx.StartSpan("storage.DeleteGrantSessionByAuthorizationCode")
+	defer func() { EndSpan(span, err) }()
+
 	return ctx.GrantSessionManager.DeleteByAuthorizationCode(ctx.Context(), code)
 }
 
-func (ctx Context) SaveAuthnSession(session *goidc.AuthnSession) error {
+func (ctx Context) SaveAuthnSession(session *goidc.AuthnSession) (err error) {
+	ctx, span := ctx.StartSpan("storage.SaveAuthnSession")
+	defer func() { EndSpan(span, err) }()
+
 	return ctx.AuthnSessionManager.Save(ctx.Context(), session)
 }
 
 func (ctx Context) AuthnSessionByCallbackID(
 	id string,
 ) (
-	*goidc.AuthnSession,
-	error,
+	_ *goidc.AuthnSession,
+	err error,
 ) {
+	ctx, span := ctx.StartSpan("storage.AuthnSessionByCallbackID")
+	defer func() { EndSpan(span, err) }()
+
 	return ctx.AuthnSessionManager.SessionByCallbackID(ctx.Context(), id)
 }
 
 func (ctx Context) AuthnSessionByAuthorizationCode(
 	code string,
 ) (
-	*goidc.AuthnSession,
-	error,
+	_ *goidc.AuthnSession,
+	err error,
 ) {
+	ctx, span := ctx.StartSpan("storage.AuthnSessionByAuthorizationCode")
+	defer func() { EndSpan(span, err) }()
+
 	return ctx.AuthnSessionManager.SessionByAuthorizationCode(
 		ctx.Context(),
 		code,
@@ -284,16 +695,123 @@ func (ctx Context) AuthnSessionByAuthorizationCode(
 func (ctx Context) AuthnSessionByRequestURI(
 	uri string,
 ) (
-	*goidc.AuthnSession,
-	error,
+	_ *goidc.AuthnSession,
+	err error,
 ) {
+	ctx, span := ctx.StartSpan("storage.AuthnSessionByRequestURI")
+	defer func() { EndSpan(span, err) }()
+
 	return ctx.AuthnSessionManager.SessionByReferenceID(ctx.Context(), uri)
 }
 
-func (ctx Context) DeleteAuthnSession(id string) error {
+func (ctx Context) DeleteAuthnSession(id string) (err error) {
+	ctx, span := ctx.StartSpan("storage.DeleteAuthnSession")
+	defer func() { EndSpan(span, err) }()
+
 	return ctx.AuthnSessionManager.Delete(ctx.Context(), id)
 }
 
+func (ctx Context) SaveConsent(consent *goidc.Consent) (err error) {
+	ctx, span := ctx.StartSpan("storage.SaveConsent")
+	defer func() { EndSpan(span, err) }()
+
+	return ctx.ConsentManager.Save(ctx.Context(), consent)
+}
+
+func (ctx Context) Consent(subject, clientID string) (_ *goidc.Consent, err error) {
+	ctx, span := ctx.StartSpan("storage.Consent")
+	defer func() { EndSpan(span, err) }()
+
+	return ctx.ConsentManager.Consent(ctx.Context(), subject, clientID)
+}
+
+func (ctx Context) DeleteConsent(subject, clientID string) (err error) {
+	ctx, span := ctx.StartSpan("storage.DeleteConsent")
+	defer func() { EndSpan(span, err) }()
+
+	return ctx.ConsentManager.Delete(ctx.Context(), subject, clientID)
+}
+
+// RecordConsent merges the accesses granted in the authentication session
+// into the consent the user gave to the client.
+func (ctx Context) RecordConsent(session *goidc.AuthnSession) error {
+	if !ctx.ConsentIsEnabled || session.Subject == "" {
+		return nil
+	}
+
+	now := timeutil.TimestampNow()
+	consent := &goidc.Consent{
+		Subject:            session.Subject,
+		ClientID:           session.ClientID,
+		CreatedAtTimestamp: now,
+	}
+	if stored, err := ctx.Consent(session.Subject, session.ClientID); err == nil {
+		consent = copyConsent(stored)
+	}
+
+	consent.Merge(session)
+	consent.UpdatedAtTimestamp = now
+	return ctx.SaveConsent(consent)
+}
+
+// BindGrantToConsent records that the grant session was issued based on the
+// consent the user gave to the client, so it is revoked with the consent.
+// The references to expired grants are dropped.
+func (ctx Context) BindGrantToConsent(grantSession *goidc.GrantSession) error {
+	if !ctx.ConsentIsEnabled {
+		return nil
+	}
+
+	stored, err := ctx.Consent(grantSession.Subject, grantSession.ClientID)
+	if err != nil {
+		// No consent was recorded for the grant.
+		return nil
+	}
+
+	now := timeutil.TimestampNow()
+	consent := copyConsent(stored)
+	consent.Grants = slices.DeleteFunc(consent.Grants, func(g goidc.ConsentGrant) bool {
+		return g.ID == grantSession.ID || g.ExpiresAtTimestamp <= now
+	})
+	consent.Grants = append(consent.Grants, goidc.ConsentGrant{
+		ID:                 grantSession.ID,
+		ExpiresAtTimestamp: grantSession.ExpiresAtTimestamp,
+	})
+	return ctx.SaveConsent(consent)
+}
+
+// copyConsent returns a copy of the consent that can be modified without
+// affecting the one returned by the storage, which may be shared.
+func copyConsent(consent *goidc.Consent) *goidc.Consent {
+	c := *consent
+	c.Claims = slices.Clone(consent.Claims)
+	c.AuthorizationDetails = slices.Clone(consent.AuthorizationDetails)
+	c.Resources = slices.Clone(consent.Resources)
+	c.Grants = slices.Clone(consent.Grants)
+	return &c
+}
+
+func (ctx Context) SaveUserSession(session *goidc.UserSession) (err error) {
+	ctx, span := ctx.StartSpan("storage.SaveUserSession")
+	defer func() { EndSpan(span, err) }()
+
+	return ctx.UserSessionManager.Save(ctx.Context(), session)
+}
+
+func (ctx Context) UserSession(id string) (_ *goidc.UserSession, err error) {
+	ctx, span := ctx.StartSpan("storage.UserSession")
+	defer func() { EndSpan(span, err) }()
+
+	return ctx.UserSessionManager.UserSession(ctx.Context(), id)
+}
+
+func (ctx Context) DeleteUserSession(id string) (err error) {
+	ctx, span := ctx.StartSpan("storage.DeleteUserSession")
+	defer func() { EndSpan(span, err) }()
+
+	return ctx.UserSessionManager.Delete(ctx.Context(), id)
+}
+
 //---------------------------------------- HTTP Utils ----------------------------------------//
 
 func (ctx Context) BaseURL() string {
@@ -304,6 +822,35 @@ func (ctx Context) MTLSBaseURL() string {
 	return ctx.MTLSHost + ctx.EndpointPrefix
 }
 
+// MTLSEndpointHost returns on which hosts the endpoint is served.
+func (ctx Context) MTLSEndpointHost(endpoint goidc.Endpoint) goidc.EndpointHost {
+	if host, ok := ctx.MTLSEndpointHosts[endpoint]; ok {
+		return host
+	}
+	return goidc.EndpointHostAll
+}
+
+// IsMTLSHostRequest returns whether the request was sent to the mutual TLS
+// host.
+func (ctx Context) IsMTLSHostRequest() bool {
+	if !ctx.MTLSIsEnabled {
+		return false
+	}
+
+	// The proxy may forward the requests of both hosts with the same Host
+	// header, so only the presence of a client certificate is relied on.
+	if ctx.MTLSIsTerminatedByProxy {
+		cert, err := ctx.ClientCert()
+		return err == nil && cert != nil
+	}
+
+	mtlsURL, err := url.Parse(ctx.MTLSHost)
+	if err != nil {
+		return false
+	}
+	return ctx.Request.Host == mtlsURL.Host
+}
+
 func (ctx Context) BearerToken() (string, bool) {
 	token, tokenType, ok := ctx.AuthorizationToken()
 	if !ok {
@@ -437,6 +984,38 @@ func (ctx Context) WriteError(err error) {
 	}
 }
 
+// AllowCORS allows the origin of the request to read the response if it is
+// one of the origins allowed by the client.
+func (ctx Context) AllowCORS(c *goidc.Client) {
+	if !ctx.CORSIsEnabled {
+		return
+	}
+
+	ctx.Response.Header().Add("Vary", "Origin")
+	origin, ok := ctx.Header("Origin")
+	if !ok || !slices.Contains(c.AllowedCORSOrigins, origin) {
+		return
+	}
+
+	ctx.Response.Header().Set("Access-Control-Allow-Origin", origin)
+	ctx.Response.Header().Set("Access-Control-Expose-Headers", "WWW-Authenticate, DPoP-Nonce")
+}
+
+// WriteCORSPreflight answers a CORS preflight request.
+// Since the client is not known at this point, the preflight is accepted for
+// any origin and the decision is left to the actual request, whose response
+// can only be read by the origins allowed by the client, see [Context.AllowCORS].
+func (ctx Context) WriteCORSPreflight(methods string) {
+	ctx.Response.Header().Add("Vary", "Origin")
+	if origin, ok := ctx.Header("Origin"); ok {
+		ctx.Response.Header().Set("Access-Control-Allow-Origin", origin)
+		ctx.Response.Header().Set("Access-Control-Allow-Methods", methods)
+		ctx.Response.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, DPoP")
+		ctx.Response.Header().Set("Access-Control-Max-Age", "600")
+	}
+	ctx.Response.WriteHeader(http.StatusNoContent)
+}
+
 func (ctx Context) Redirect(redirectURL string) {
 	http.Redirect(ctx.Response, ctx.Request, redirectURL, http.StatusSeeOther)
 }
@@ -461,6 +1040,10 @@ func (ctx Context) RenderHTML(
 //---------------------------------------- Key Management ----------------------------------------//
 
 func (ctx Context) SigAlgs() []jose.SignatureAlgorithm {
+	if ctx.Precomputed != nil {
+		return slices.Clip(ctx.Precomputed.sigAlgs)
+	}
+
 	var algorithms []jose.SignatureAlgorithm
 	for _, privateKey := range ctx.PrivateJWKS.Keys {
 		if privateKey.Use == string(goidc.KeyUsageSignature) {
@@ -471,8 +1054,19 @@ func (ctx Context) SigAlgs() []jose.SignatureAlgorithm {
 }
 
 func (ctx Context) PublicKeys() jose.JSONWebKeySet {
+	if ctx.Precomputed != nil {
+		return jose.JSONWebKeySet{Keys: slices.Clip(ctx.Precomputed.publicKeys.Keys)}
+	}
+
 	publicKeys := []jose.JSONWebKey{}
 	for _, privateKey := range ctx.PrivateJWKS.Keys {
+		// Encryption keys not advertised for request objects are kept private,
+		// so clients don't encrypt with them.
+		if privateKey.Use == string(goidc.KeyUsageEncryption) &&
+			len(ctx.JAREncKeyIDs) != 0 &&
+			!slices.Contains(ctx.JAREncKeyIDs, privateKey.KeyID) {
+			continue
+		}
 		publicKeys = append(publicKeys, privateKey.Public())
 	}
 
@@ -480,6 +1074,14 @@ func (ctx Context) PublicKeys() jose.JSONWebKeySet {
 }
 
 func (ctx Context) PublicKey(keyID string) (jose.JSONWebKey, bool) {
+	if ctx.Precomputed != nil {
+		keys := ctx.Precomputed.publicKeys.Key(keyID)
+		if len(keys) == 0 {
+			return jose.JSONWebKey{}, false
+		}
+		return keys[0], true
+	}
+
 	key, ok := ctx.PrivateKey(keyID)
 	if !ok {
 		return jose.JSONWebKey{}, false
@@ -496,6 +1098,39 @@ func (ctx Context) PrivateKey(keyID string) (jose.JSONWebKey, bool) {
 	return keys[0], true
 }
 
+// JARDecryptionKey returns the private key that decrypts request objects
+// encrypted for the key ID informed. Retired keys are considered until they
+// expire.
+func (ctx Context) JARDecryptionKey(keyID string) (jose.JSONWebKey, bool) {
+	if key, ok := ctx.PrivateKey(keyID); ok && key.Use == string(goidc.KeyUsageEncryption) {
+		return key, true
+	}
+
+	now := timeutil.TimestampNow()
+	for _, retiredKey := range ctx.JARRetiredEncKeys {
+		if retiredKey.Key.KeyID == keyID && now < retiredKey.ExpiresAtTimestamp {
+			return retiredKey.Key, true
+		}
+	}
+
+	return jose.JSONWebKey{}, false
+}
+
+// JARDecryptionKeyAlgs returns the key management algorithms accepted for
+// encrypted request objects, including the ones of retired keys not yet
+// expired.
+func (ctx Context) JARDecryptionKeyAlgs() []jose.KeyAlgorithm {
+	algs := slices.Clone(ctx.JARKeyEncAlgs)
+	now := timeutil.TimestampNow()
+	for _, retiredKey := range ctx.JARRetiredEncKeys {
+		alg := jose.KeyAlgorithm(retiredKey.Key.Algorithm)
+		if now < retiredKey.ExpiresAtTimestamp && !slices.Contains(algs, alg) {
+			algs = append(algs, alg)
+		}
+	}
+	return algs
+}
+
 func (ctx Context) UserInfoSigKeyForClient(c *goidc.Client) (jose.JSONWebKey, bool) {
 	if c.UserInfoSigAlg == "" {
 		return ctx.UserSigKey()
@@ -570,6 +1205,31 @@ func (ctx Context) privateKeyByAlg(
 // 	return keys[0]
 // }
 
+// CodeChallengeMethods returns the PKCE code challenge methods clients are
+// allowed to use.
+func (ctx Context) CodeChallengeMethods() []goidc.CodeChallengeMethod {
+	if !ctx.PKCEPlainIsDisallowed {
+		return ctx.PKCEChallengeMethods
+	}
+
+	var methods []goidc.CodeChallengeMethod
+	for _, method := range ctx.PKCEChallengeMethods {
+		if method != goidc.CodeChallengeMethodPlain {
+			methods = append(methods, method)
+		}
+	}
+	return methods
+}
+
+// DefaultCodeChallengeMethod returns the PKCE code challenge method assumed
+// when clients don't inform one.
+func (ctx Context) DefaultCodeChallengeMethod() goidc.CodeChallengeMethod {
+	if ctx.PKCEPlainIsDisallowed && ctx.PKCEDefaultChallengeMethod == goidc.CodeChallengeMethodPlain {
+		return goidc.CodeChallengeMethodSHA256
+	}
+	return ctx.PKCEDefaultChallengeMethod
+}
+
 func (ctx Context) ShouldIssueRefreshToken(
 	client *goidc.Client,
 	grantInfo goidc.GrantInfo,
@@ -582,11 +1242,27 @@ func (ctx Context) ShouldIssueRefreshToken(
 	return ctx.ShouldIssueRefreshTokenFunc(client, grantInfo)
 }
 
+// RefreshTokenLifetime returns the lifetime in seconds of the refresh tokens
+// issued to the client. When client token metadata is enabled, the client can
+// shorten it.
+func (ctx Context) RefreshTokenLifetime(client *goidc.Client) int {
+	if ctx.ClientTokenMetadataIsEnabled &&
+		client.RefreshTokenLifetimeSecs > 0 &&
+		client.RefreshTokenLifetimeSecs < ctx.RefreshTokenLifetimeSecs {
+		return client.RefreshTokenLifetimeSecs
+	}
+	return ctx.RefreshTokenLifetimeSecs
+}
+
 func (ctx Context) TokenOptions(
 	grantInfo goidc.GrantInfo,
+	client *goidc.Client,
 ) goidc.TokenOptions {
 
 	opts := ctx.TokenOptionsFunc(grantInfo)
+	if ctx.ClientTokenMetadataIsEnabled {
+		opts = ctx.clientTokenOptions(opts, client)
+	}
 
 	// Opaque access tokens cannot be the same size of refresh tokens.
 	if opts.OpaqueLength == goidc.RefreshTokenLength {
@@ -596,6 +1272,46 @@ func (ctx Context) TokenOptions(
 	return opts
 }
 
+// clientTokenOptions adjusts the token options according to the token
+// metadata of the client, if any.
+func (ctx Context) clientTokenOptions(
+	opts goidc.TokenOptions,
+	client *goidc.Client,
+) goidc.TokenOptions {
+	if client.AccessTokenLifetimeSecs != 0 {
+		opts.LifetimeSecs = client.AccessTokenLifetimeSecs
+	}
+
+	if client.AccessTokenFormat == "" || client.AccessTokenFormat == opts.Format {
+		return opts
+	}
+
+	// Only the format is overridden, the other options informed for the grant
+	// are kept.
+	switch client.AccessTokenFormat {
+	case goidc.TokenFormatJWT:
+		if opts.JWTSignatureKeyID == "" {
+			key, ok := ctx.UserSigKey()
+			if !ok {
+				return opts
+			}
+			opts.JWTSignatureKeyID = key.KeyID
+		}
+		opts.Format = goidc.TokenFormatJWT
+	case goidc.TokenFormatOpaque:
+		if opts.OpaqueLength == 0 {
+			opts.OpaqueLength = defaultOpaqueTokenLength
+		}
+		opts.Format = goidc.TokenFormatOpaque
+	default:
+		if _, ok := ctx.TokenIssuer(client.AccessTokenFormat); ok {
+			opts.Format = client.AccessTokenFormat
+		}
+	}
+
+	return opts
+}
+
 func (ctx Context) HandleGrant(grantInfo *goidc.GrantInfo) error {
 	if ctx.HandleGrantFunc == nil {
 		return nil
@@ -614,10 +1330,57 @@ func (ctx Context) HandleGrant(grantInfo *goidc.GrantInfo) error {
 	return oidcErr
 }
 
+// TokenResponseParams returns the additional parameters to be included in the
+// token response for the grant informed.
+func (ctx Context) TokenResponseParams(grantInfo goidc.GrantInfo) map[string]any {
+	if ctx.TokenResponseHookFunc == nil {
+		return nil
+	}
+
+	return ctx.TokenResponseHookFunc(ctx.Request, grantInfo)
+}
+
+// TokenRenewalHints returns the renewal hints to be included in the token
+// response for the tokens informed.
+func (ctx Context) TokenRenewalHints(renewal goidc.TokenRenewal) map[string]any {
+	if ctx.TokenRenewalHintFunc == nil {
+		return nil
+	}
+
+	return ctx.TokenRenewalHintFunc(ctx.Request, renewal)
+}
+
 func (ctx Context) HandleJWTBearerGrantAssertion(assertion string) (goidc.JWTBearerGrantInfo, error) {
 	return ctx.HandleJWTBearerGrantAssertionFunc(ctx.Request, assertion)
 }
 
+// CustomGrantFunc returns the handler of the custom grant type, if registered.
+func (ctx Context) CustomGrantFunc(grantType goidc.GrantType) (goidc.HandleCustomGrantFunc, bool) {
+	f, ok := ctx.CustomGrantFuncs[grantType]
+	return f, ok
+}
+
+// ValidateURIPolicy verifies the URI informed by a client is allowed to be
+// fetched according to the URI policy, if any.
+func (ctx Context) ValidateURIPolicy(uri *url.URL) error {
+	if ctx.URIPolicyFunc == nil {
+		return nil
+	}
+
+	return ctx.URIPolicyFunc(ctx.Context(), uri)
+}
+
+// NewID generates an ID of the type informed with IDFunc. If it's not set or
+// returns an empty ID, defaultID is used.
+func (ctx Context) NewID(idType goidc.IDType, defaultID func() string) string {
+	if ctx.IDFunc != nil {
+		if id := ctx.IDFunc(ctx, idType); id != "" {
+			return id
+		}
+	}
+	return defaultID()
+}
+
 func (ctx Context) HTTPClient() *http.Client {
 
 	if ctx.HTTPClientFunc == nil {
@@ -627,6 +1390,43 @@ func (ctx Context) HTTPClient() *http.Client {
 	return ctx.HTTPClientFunc(ctx.Context())
 }
 
+// ClientPublicJWKS returns the public JWKS of the client. When the client
+// informs it by reference, it is served from ClientJWKSCache if available.
+func (ctx Context) ClientPublicJWKS(c *goidc.Client) (jose.JSONWebKeySet, error) {
+	if ctx.ClientJWKSCache == nil || c.PublicJWKS != nil || c.PublicJWKSURI == "" {
+		return c.FetchPublicJWKS(ctx.HTTPClient())
+	}
+
+	if jwks, ok := ctx.ClientJWKSCache.jwks(c.PublicJWKSURI); ok {
+		return jwks, nil
+	}
+
+	jwks, err := c.FetchPublicJWKS(ctx.HTTPClient())
+	if err != nil {
+		return jose.JSONWebKeySet{}, err
+	}
+	ctx.ClientJWKSCache.save(c.PublicJWKSURI, jwks)
+	return jwks, nil
+}
+
+// CachedSectorIdentifierRedirectURIs returns the redirect URIs fetched from
+// the sector identifier URI informed, if they are in SectorIdentifierCache.
+func (ctx Context) CachedSectorIdentifierRedirectURIs(uri string) ([]string, bool) {
+	if ctx.SectorIdentifierCache == nil {
+		return nil, false
+	}
+	return ctx.SectorIdentifierCache.redirectURIs(uri)
+}
+
+// CacheSectorIdentifierRedirectURIs keeps the redirect URIs fetched from the
+// sector identifier URI informed in SectorIdentifierCache, if available.
+func (ctx Context) CacheSectorIdentifierRedirectURIs(uri string, redirectURIs []string) {
+	if ctx.SectorIdentifierCache == nil {
+		return
+	}
+	ctx.SectorIdentifierCache.save(uri, redirectURIs)
+}
+
 //---------------------------------------- context.Context ----------------------------------------//
 
 func (ctx Context) Context() context.Context {
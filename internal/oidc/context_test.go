@@ -1,16 +1,22 @@
 package oidc_test
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-jose/go-jose/v4"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/luikyv/go-oidc/internal/oidc"
 	"github.com/luikyv/go-oidc/internal/oidctest"
+	"github.com/luikyv/go-oidc/internal/storage"
+	"github.com/luikyv/go-oidc/internal/timeutil"
 	"github.com/luikyv/go-oidc/pkg/goidc"
 )
 
@@ -153,6 +159,49 @@ func TestIntrospectionClientAuthnSigAlgs(t *testing.T) {
 	}
 }
 
+func TestPrecomputed(t *testing.T) {
+	// Given.
+	ctx := oidctest.NewContext(t)
+	ctx.TokenAuthnMethods = []goidc.ClientAuthnType{goidc.ClientAuthnPrivateKeyJWT}
+	ctx.PrivateKeyJWTSigAlgs = []jose.SignatureAlgorithm{jose.PS256}
+	ctx.ClientSecretJWTSigAlgs = []jose.SignatureAlgorithm{jose.HS256}
+	wantTokenAuthnSigAlgs := ctx.TokenAuthnSigAlgs()
+	wantClientAuthnSigAlgs := ctx.ClientAuthnSigAlgs()
+	wantSigAlgs := ctx.SigAlgs()
+	wantPublicKeys := ctx.PublicKeys()
+
+	// When.
+	ctx.Precomputed = oidc.NewPrecomputed(ctx.Configuration)
+
+	// Then.
+	if diff := cmp.Diff(ctx.TokenAuthnSigAlgs(), wantTokenAuthnSigAlgs); diff != "" {
+		t.Error(diff)
+	}
+
+	if diff := cmp.Diff(ctx.ClientAuthnSigAlgs(), wantClientAuthnSigAlgs); diff != "" {
+		t.Error(diff)
+	}
+
+	if diff := cmp.Diff(ctx.SigAlgs(), wantSigAlgs); diff != "" {
+		t.Error(diff)
+	}
+
+	if len(ctx.PublicKeys().Keys) != len(wantPublicKeys.Keys) {
+		t.Errorf("len(PublicKeys) = %d, want %d", len(ctx.PublicKeys().Keys), len(wantPublicKeys.Keys))
+	}
+
+	keyID := ctx.PrivateJWKS.Keys[0].KeyID
+	if key, ok := ctx.PublicKey(keyID); !ok || !key.IsPublic() {
+		t.Errorf("the public key %s must be found", keyID)
+	}
+
+	// Appending to a precomputed value must not change the shared one.
+	_ = append(ctx.ClientAuthnSigAlgs(), jose.ES256)
+	if diff := cmp.Diff(ctx.ClientAuthnSigAlgs(), wantClientAuthnSigAlgs); diff != "" {
+		t.Error(diff)
+	}
+}
+
 func TestHandleDynamicClient(t *testing.T) {
 	// Given.
 	ctx := oidc.Context{
@@ -237,6 +286,42 @@ func TestGetAudiences_MTLSIsEnabled(t *testing.T) {
 	}
 }
 
+func TestGetAudiences_CustomAudiences(t *testing.T) {
+	// Given.
+	host := "https://example.com"
+	ctx := oidc.Context{
+		Request: httptest.NewRequest(http.MethodPost, "/token", nil),
+		Configuration: &oidc.Configuration{
+			Host:                     host,
+			EndpointToken:            "/token",
+			CustomAssertionAudiences: []string{host + "/auth/token"},
+		},
+	}
+
+	// When.
+	auds := ctx.AssertionAudiences()
+
+	// Then.
+	wantedAuds := []string{host, host + "/token", host + "/token", host + "/auth/token"}
+	if !cmp.Equal(auds, wantedAuds) {
+		t.Errorf("Audiences() = %v, want %v", auds, wantedAuds)
+	}
+
+	// When.
+	ctx.AssertionAudiencesReplaceDefaults = true
+	auds = ctx.AssertionAudiences()
+
+	// Then.
+	wantedAuds = []string{host + "/auth/token"}
+	if !cmp.Equal(auds, wantedAuds) {
+		t.Errorf("Audiences() = %v, want %v", auds, wantedAuds)
+	}
+
+	if reqObjAuds := ctx.RequestObjectAudiences(); !cmp.Equal(reqObjAuds, wantedAuds) {
+		t.Errorf("RequestObjectAudiences() = %v, want %v", reqObjAuds, wantedAuds)
+	}
+}
+
 func TestPolicy(t *testing.T) {
 	// Given.
 	policyID := "random_policy_id"
@@ -288,6 +373,117 @@ func TestAvailablePolicy(t *testing.T) {
 	}
 }
 
+func TestAvailablePolicy_ACR(t *testing.T) {
+	// Given.
+	setUp := func(r *http.Request, c *goidc.Client, s *goidc.AuthnSession) bool {
+		return true
+	}
+	silverPolicy := goidc.NewPolicy("silver_policy", setUp, nil)
+	silverPolicy.ACRs = []goidc.ACR{goidc.ACRMaceIncommonIAPSilver}
+	bronzePolicy := goidc.NewPolicy("bronze_policy", setUp, nil)
+	bronzePolicy.ACRs = []goidc.ACR{goidc.ACRMaceIncommonIAPBronze}
+	ctx := oidc.Context{
+		Configuration: &oidc.Configuration{},
+	}
+	ctx.Policies = []goidc.AuthnPolicy{silverPolicy, bronzePolicy}
+
+	session := &goidc.AuthnSession{
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			ACRValues: string(goidc.ACRMaceIncommonIAPBronze),
+		},
+	}
+
+	// When.
+	policy, ok := ctx.AvailablePolicy(&goidc.Client{}, session)
+
+	// Then.
+	if !ok {
+		t.Fatal("a policy should be available")
+	}
+
+	if policy.ID != bronzePolicy.ID {
+		t.Errorf("ID = %s, want %s", policy.ID, bronzePolicy.ID)
+	}
+}
+
+func TestAvailablePolicy_EssentialACRNotAchievable(t *testing.T) {
+	// Given.
+	policy := goidc.NewPolicy(
+		"silver_policy",
+		func(r *http.Request, c *goidc.Client, s *goidc.AuthnSession) bool {
+			return true
+		},
+		nil,
+	)
+	policy.ACRs = []goidc.ACR{goidc.ACRMaceIncommonIAPSilver}
+	ctx := oidc.Context{
+		Configuration: &oidc.Configuration{},
+	}
+	ctx.Policies = []goidc.AuthnPolicy{policy}
+
+	session := &goidc.AuthnSession{
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			Claims: &goidc.ClaimsObject{
+				IDToken: map[string]goidc.ClaimObjectInfo{
+					goidc.ClaimACR: {
+						IsEssential: true,
+						Value:       string(goidc.ACRMaceIncommonIAPBronze),
+					},
+				},
+			},
+		},
+	}
+
+	// When.
+	_, ok := ctx.AvailablePolicy(&goidc.Client{}, session)
+
+	// Then.
+	if ok {
+		t.Error("no policy should be available for the essential acr")
+	}
+}
+
+func TestAvailablePolicy_EssentialACRPreferred(t *testing.T) {
+	// Given.
+	setUp := func(r *http.Request, c *goidc.Client, s *goidc.AuthnSession) bool {
+		return true
+	}
+	bronzePolicy := goidc.NewPolicy("bronze_policy", setUp, nil)
+	bronzePolicy.ACRs = []goidc.ACR{goidc.ACRMaceIncommonIAPBronze}
+	silverPolicy := goidc.NewPolicy("silver_policy", setUp, nil)
+	silverPolicy.ACRs = []goidc.ACR{goidc.ACRMaceIncommonIAPSilver}
+	ctx := oidc.Context{
+		Configuration: &oidc.Configuration{},
+	}
+	ctx.Policies = []goidc.AuthnPolicy{bronzePolicy, silverPolicy}
+
+	session := &goidc.AuthnSession{
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			ACRValues: string(goidc.ACRMaceIncommonIAPBronze),
+			Claims: &goidc.ClaimsObject{
+				IDToken: map[string]goidc.ClaimObjectInfo{
+					goidc.ClaimACR: {
+						IsEssential: true,
+						Value:       string(goidc.ACRMaceIncommonIAPSilver),
+					},
+				},
+			},
+		},
+	}
+
+	// When.
+	policy, ok := ctx.AvailablePolicy(&goidc.Client{}, session)
+
+	// Then.
+	if !ok {
+		t.Fatal("a policy should be available")
+	}
+
+	if policy.ID != silverPolicy.ID {
+		t.Errorf("ID = %s, want %s", policy.ID, silverPolicy.ID)
+	}
+}
+
 func TestAvailablePolicy_NoPolicyAvailable(t *testing.T) {
 	// Given.
 	unavailablePolicy := goidc.NewPolicy(
@@ -311,6 +507,63 @@ func TestAvailablePolicy_NoPolicyAvailable(t *testing.T) {
 	}
 }
 
+func TestClient_Disabled(t *testing.T) {
+	// Given.
+	ctx := oidctest.NewContext(t)
+	client, _ := oidctest.NewClient(t)
+	client.Status = goidc.ClientStatusDisabled
+	_ = ctx.SaveClient(client)
+
+	// When.
+	_, err := ctx.Client(client.ID)
+
+	// Then.
+	if err == nil {
+		t.Error("a disabled client should not be returned")
+	}
+}
+
+func TestAllowCORS(t *testing.T) {
+	// Given.
+	ctx := oidctest.NewContext(t)
+	ctx.CORSIsEnabled = true
+	ctx.Request.Header.Set("Origin", "https://app.example.com")
+	client := &goidc.Client{
+		ClientMetaInfo: goidc.ClientMetaInfo{
+			AllowedCORSOrigins: []string{"https://app.example.com"},
+		},
+	}
+
+	// When.
+	ctx.AllowCORS(client)
+
+	// Then.
+	origin := ctx.Response.Header().Get("Access-Control-Allow-Origin")
+	if origin != "https://app.example.com" {
+		t.Errorf("Access-Control-Allow-Origin = %s, want https://app.example.com", origin)
+	}
+}
+
+func TestAllowCORS_OriginNotAllowed(t *testing.T) {
+	// Given.
+	ctx := oidctest.NewContext(t)
+	ctx.CORSIsEnabled = true
+	ctx.Request.Header.Set("Origin", "https://attacker.example.com")
+	client := &goidc.Client{
+		ClientMetaInfo: goidc.ClientMetaInfo{
+			AllowedCORSOrigins: []string{"https://app.example.com"},
+		},
+	}
+
+	// When.
+	ctx.AllowCORS(client)
+
+	// Then.
+	if origin := ctx.Response.Header().Get("Access-Control-Allow-Origin"); origin != "" {
+		t.Errorf("Access-Control-Allow-Origin = %s, want empty", origin)
+	}
+}
+
 func TestBaseURL(t *testing.T) {
 	// Given.
 	ctx := oidc.Context{
@@ -748,3 +1001,624 @@ func TestJARMSigKeyForClient_ClientWithDefaultAlgorithm(t *testing.T) {
 		t.Errorf("KeyID = %s, want %s", jwk.KeyID, alternativeKey.KeyID)
 	}
 }
+
+func TestClaimsFromSource_Cache(t *testing.T) {
+	// Given.
+	calls := 0
+	ctx := oidctest.NewContext(t)
+	ctx.ClaimsSourceFunc = func(context.Context, goidc.GrantInfo) (map[string]any, error) {
+		calls++
+		return map[string]any{"email": "random@example.com", "name": "random"}, nil
+	}
+	ctx.ClaimsCache = oidc.NewClaimsCache(map[string]int{"email": 60, "name": 60}, 10)
+	grantInfo := goidc.GrantInfo{Subject: "random_subject", ClientID: "random_client_id"}
+
+	// When.
+	_, err := ctx.ClaimsFromSource(grantInfo)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	claims, err := ctx.ClaimsFromSource(grantInfo)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1", calls)
+	}
+
+	if claims["email"] != "random@example.com" {
+		t.Errorf("email = %v, want random@example.com", claims["email"])
+	}
+}
+
+func TestClaimsFromSource_ClaimNotCached(t *testing.T) {
+	// Given.
+	calls := 0
+	ctx := oidctest.NewContext(t)
+	ctx.ClaimsSourceFunc = func(context.Context, goidc.GrantInfo) (map[string]any, error) {
+		calls++
+		return map[string]any{"email": "random@example.com", "name": "random"}, nil
+	}
+	// The name claim has no lifetime, so the source must always be called.
+	ctx.ClaimsCache = oidc.NewClaimsCache(map[string]int{"email": 60}, 10)
+	grantInfo := goidc.GrantInfo{Subject: "random_subject", ClientID: "random_client_id"}
+
+	// When.
+	_, _ = ctx.ClaimsFromSource(grantInfo)
+	_, _ = ctx.ClaimsFromSource(grantInfo)
+
+	// Then.
+	if calls != 2 {
+		t.Errorf("calls = %d, want 2", calls)
+	}
+}
+
+func TestClaimsFromSource_CacheMaxEntries(t *testing.T) {
+	// Given.
+	calls := 0
+	ctx := oidctest.NewContext(t)
+	ctx.ClaimsSourceFunc = func(context.Context, goidc.GrantInfo) (map[string]any, error) {
+		calls++
+		return map[string]any{"email": "random@example.com"}, nil
+	}
+	ctx.ClaimsCache = oidc.NewClaimsCache(map[string]int{"email": 60}, 1)
+
+	// When.
+	_, _ = ctx.ClaimsFromSource(goidc.GrantInfo{Subject: "random_subject", ClientID: "random_client_id"})
+	_, _ = ctx.ClaimsFromSource(goidc.GrantInfo{Subject: "another_subject", ClientID: "random_client_id"})
+	_, _ = ctx.ClaimsFromSource(goidc.GrantInfo{Subject: "random_subject", ClientID: "random_client_id"})
+
+	// Then.
+	if calls != 3 {
+		t.Errorf("calls = %d, want 3", calls)
+	}
+}
+
+func TestClaimsFromSource_CacheKey(t *testing.T) {
+	// Given.
+	calls := 0
+	ctx := oidctest.NewContext(t)
+	ctx.ClaimsSourceFunc = func(context.Context, goidc.GrantInfo) (map[string]any, error) {
+		calls++
+		return map[string]any{"email": "random@example.com"}, nil
+	}
+	ctx.ClaimsCache = oidc.NewClaimsCache(map[string]int{"email": 60}, 10)
+
+	// When.
+	_, _ = ctx.ClaimsFromSource(goidc.GrantInfo{Subject: "random subject", ClientID: "random"})
+	_, _ = ctx.ClaimsFromSource(goidc.GrantInfo{Subject: "subject", ClientID: "random random"})
+
+	// Then.
+	if calls != 2 {
+		t.Errorf("calls = %d, want 2", calls)
+	}
+}
+
+func TestFilterClaims(t *testing.T) {
+	// Given.
+	ctx := oidctest.NewContext(t)
+	ctx.ClaimMappings = goidc.StandardClaimMappings
+	claims := map[string]any{
+		goidc.ClaimEmail:       "random@example.com",
+		goidc.ClaimPhoneNumber: "+5500000000000",
+		goidc.ClaimAddress:     "random address",
+		"random_claim":         "random_value",
+	}
+	grantInfo := goidc.GrantInfo{
+		ActiveScopes: "openid email",
+		Claims: &goidc.ClaimsObject{
+			IDToken:  map[string]goidc.ClaimObjectInfo{goidc.ClaimPhoneNumber: {}},
+			UserInfo: map[string]goidc.ClaimObjectInfo{goidc.ClaimAddress: {}},
+		},
+	}
+
+	// When.
+	idTokenClaims := ctx.FilterIDTokenClaims(grantInfo, claims)
+	userInfoClaims := ctx.FilterUserInfoClaims(grantInfo, claims)
+
+	// Then.
+	wantIDTokenClaims := map[string]any{
+		goidc.ClaimEmail:       "random@example.com",
+		goidc.ClaimPhoneNumber: "+5500000000000",
+		"random_claim":         "random_value",
+	}
+	if diff := cmp.Diff(idTokenClaims, wantIDTokenClaims); diff != "" {
+		t.Error(diff)
+	}
+
+	wantUserInfoClaims := map[string]any{
+		goidc.ClaimEmail:   "random@example.com",
+		goidc.ClaimAddress: "random address",
+		"random_claim":     "random_value",
+	}
+	if diff := cmp.Diff(userInfoClaims, wantUserInfoClaims); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestFilterClaims_ClaimSources(t *testing.T) {
+	// Given.
+	ctx := oidctest.NewContext(t)
+	ctx.ClaimMappings = goidc.StandardClaimMappings
+	session := &goidc.AuthnSession{}
+	session.SetUserInfoAggregatedClaims("src1", "random_jwt", goidc.ClaimAddress)
+	session.SetUserInfoDistributedClaims("src2", "https://example.com/claims", "", goidc.ClaimEmail)
+	grantInfo := goidc.GrantInfo{ActiveScopes: "openid email"}
+
+	// When.
+	claims := ctx.FilterUserInfoClaims(grantInfo, session.AdditionalUserInfoClaims)
+
+	// Then.
+	want := map[string]any{
+		goidc.ClaimNames:   map[string]any{goidc.ClaimEmail: "src2"},
+		goidc.ClaimSources: map[string]any{"src2": map[string]any{"endpoint": "https://example.com/claims"}},
+	}
+	if diff := cmp.Diff(claims, want); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestRefreshTokenLifetime(t *testing.T) {
+	// Given.
+	ctx := oidctest.NewContext(t)
+	ctx.RefreshTokenLifetimeSecs = 600
+	ctx.ClientTokenMetadataIsEnabled = true
+
+	testCases := []struct {
+		clientLifetimeSecs int
+		want               int
+	}{
+		{0, 600},
+		{300, 300},
+		{900, 600},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(fmt.Sprintf("client_lifetime_%d", testCase.clientLifetimeSecs), func(t *testing.T) {
+			client := &goidc.Client{}
+			client.RefreshTokenLifetimeSecs = testCase.clientLifetimeSecs
+
+			// When.
+			lifetimeSecs := ctx.RefreshTokenLifetime(client)
+
+			// Then.
+			if lifetimeSecs != testCase.want {
+				t.Errorf("RefreshTokenLifetime() = %d, want %d", lifetimeSecs, testCase.want)
+			}
+		})
+	}
+}
+
+func TestMinimizeIDTokenClaims_ByResponseType(t *testing.T) {
+	// Given.
+	ctx := oidctest.NewContext(t)
+	ctx.IDTokenClaimsMode = goidc.IDTokenClaimsByResponseType
+	claims := map[string]any{
+		goidc.ClaimEmail:       "random@example.com",
+		goidc.ClaimPhoneNumber: "+5500000000000",
+		"random_claim":         "random_value",
+	}
+	grantInfo := goidc.GrantInfo{
+		ActiveScopes: "openid email phone",
+		Claims: &goidc.ClaimsObject{
+			IDToken: map[string]goidc.ClaimObjectInfo{goidc.ClaimPhoneNumber: {}},
+		},
+	}
+
+	// When.
+	withAccessToken := ctx.MinimizeIDTokenClaims(grantInfo, claims, true)
+	withoutAccessToken := ctx.MinimizeIDTokenClaims(grantInfo, claims, false)
+
+	// Then.
+	want := map[string]any{
+		goidc.ClaimPhoneNumber: "+5500000000000",
+		"random_claim":         "random_value",
+	}
+	if diff := cmp.Diff(withAccessToken, want); diff != "" {
+		t.Error(diff)
+	}
+
+	if diff := cmp.Diff(withoutAccessToken, claims); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestMinimizeIDTokenClaims_Requested(t *testing.T) {
+	// Given.
+	ctx := oidctest.NewContext(t)
+	ctx.IDTokenClaimsMode = goidc.IDTokenClaimsRequested
+	claims := map[string]any{
+		goidc.ClaimEmail: "random@example.com",
+		"random_claim":   "random_value",
+	}
+
+	// When.
+	minimizedClaims := ctx.MinimizeIDTokenClaims(goidc.GrantInfo{}, claims, false)
+
+	// Then.
+	want := map[string]any{
+		"random_claim": "random_value",
+	}
+	if diff := cmp.Diff(minimizedClaims, want); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestScopeInfos(t *testing.T) {
+	// Given.
+	ctx := oidctest.NewContext(t)
+	paymentScope := goidc.NewDynamicScope("payment", func(requestedScope string) bool {
+		return strings.HasPrefix(requestedScope, "payment:")
+	})
+	paymentScope.Title = "Payments"
+	ctx.Scopes = []goidc.Scope{goidc.ScopeOpenID, paymentScope}
+
+	// When.
+	infos := ctx.ScopeInfos("payment:30 unknown openid")
+
+	// Then.
+	want := []goidc.ScopeInfo{
+		{ID: "payment:30", Title: "Payments"},
+		{ID: goidc.ScopeOpenID.ID},
+	}
+	if diff := cmp.Diff(infos, want); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestStartSpan_NoTracer(t *testing.T) {
+	// Given.
+	ctx := oidctest.NewContext(t)
+
+	// When.
+	spanCtx, span := ctx.StartSpan("random_span")
+	oidc.EndSpan(span, errors.New("random error"))
+
+	// Then.
+	if spanCtx.Context() != ctx.Context() {
+		t.Error("the context must not change when tracing is disabled")
+	}
+}
+
+func TestAuthDetailsClaim(t *testing.T) {
+	// Given.
+	client, _ := oidctest.NewClient(t)
+	grantInfo := goidc.GrantInfo{
+		GrantedAuthDetails: []goidc.AuthorizationDetail{
+			{"type": "payment_initiation"},
+			{"type": "internal_type"},
+		},
+	}
+	filterFunc := func(
+		_ context.Context,
+		_ *goidc.Client,
+		_ goidc.GrantInfo,
+		details []goidc.AuthorizationDetail,
+	) []goidc.AuthorizationDetail {
+		return details[:1]
+	}
+
+	testCases := []struct {
+		name string
+		ctx  oidc.Context
+		want []goidc.AuthorizationDetail
+	}{
+		{
+			"claim disabled",
+			oidc.Context{Configuration: &oidc.Configuration{
+				AuthDetailsIsEnabled: true,
+			}},
+			nil,
+		},
+		{
+			"all details",
+			oidc.Context{Configuration: &oidc.Configuration{
+				AuthDetailsIsEnabled:      true,
+				AuthDetailsClaimIsEnabled: true,
+			}},
+			grantInfo.GrantedAuthDetails,
+		},
+		{
+			"filtered details",
+			oidc.Context{Configuration: &oidc.Configuration{
+				AuthDetailsIsEnabled:      true,
+				AuthDetailsClaimIsEnabled: true,
+				AuthDetailsClaimFunc:      filterFunc,
+			}},
+			[]goidc.AuthorizationDetail{{"type": "payment_initiation"}},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// When.
+			got := testCase.ctx.AuthDetailsClaim(client, grantInfo)
+
+			// Then.
+			if diff := cmp.Diff(got, testCase.want); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestNewID(t *testing.T) {
+	// Given.
+	ctx := oidctest.NewContext(t)
+	ctx.IDFunc = func(_ context.Context, idType goidc.IDType) string {
+		if idType == goidc.IDTypeCallback {
+			return ""
+		}
+		return "custom_id"
+	}
+	defaultID := func() string { return "default_id" }
+
+	// When.
+	grantID := ctx.NewID(goidc.IDTypeGrantSession, defaultID)
+	callbackID := ctx.NewID(goidc.IDTypeCallback, defaultID)
+
+	// Then.
+	if grantID != "custom_id" {
+		t.Errorf("NewID() = %s, want custom_id", grantID)
+	}
+
+	if callbackID != "default_id" {
+		t.Errorf("NewID() = %s, want default_id when the function returns no ID", callbackID)
+	}
+}
+
+func TestCodeChallengeMethods_PlainDisallowed(t *testing.T) {
+	// Given.
+	ctx := oidctest.NewContext(t)
+	ctx.PKCEIsEnabled = true
+	ctx.PKCEDefaultChallengeMethod = goidc.CodeChallengeMethodPlain
+	ctx.PKCEChallengeMethods = []goidc.CodeChallengeMethod{
+		goidc.CodeChallengeMethodPlain,
+		goidc.CodeChallengeMethodSHA256,
+	}
+	ctx.PKCEPlainIsDisallowed = true
+
+	// When.
+	methods := ctx.CodeChallengeMethods()
+	defaultMethod := ctx.DefaultCodeChallengeMethod()
+
+	// Then.
+	if diff := cmp.Diff(methods, []goidc.CodeChallengeMethod{goidc.CodeChallengeMethodSHA256}); diff != "" {
+		t.Error(diff)
+	}
+
+	if defaultMethod != goidc.CodeChallengeMethodSHA256 {
+		t.Errorf("DefaultCodeChallengeMethod() = %s, want %s", defaultMethod, goidc.CodeChallengeMethodSHA256)
+	}
+}
+
+func TestClientPublicJWKS_Cached(t *testing.T) {
+	// Given.
+	numberOfCalls := 0
+	jwk := oidctest.PrivateRSAOAEPJWK(t, "client_enc_key")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		numberOfCalls++
+		_, _ = w.Write(oidctest.RawJWKS(jwk.Public()))
+	}))
+	defer server.Close()
+
+	ctx := oidctest.NewContext(t)
+	ctx.ClientJWKSCache = oidc.NewClientJWKSCache(time.Minute)
+
+	for i := 0; i < 2; i++ {
+		// The client is loaded again for every request.
+		client := &goidc.Client{
+			ClientMetaInfo: goidc.ClientMetaInfo{
+				PublicJWKSURI: server.URL,
+			},
+		}
+
+		// When.
+		jwks, err := ctx.ClientPublicJWKS(client)
+
+		// Then.
+		if err != nil {
+			t.Fatalf("unexpected error during attempt %d: %v", i+1, err)
+		}
+
+		if len(jwks.Key("client_enc_key")) != 1 {
+			t.Errorf("the client key was not returned. attempt %d", i+1)
+		}
+	}
+
+	if numberOfCalls != 1 {
+		t.Errorf("number of requests = %d, want 1", numberOfCalls)
+	}
+}
+
+func TestCacheSectorIdentifierRedirectURIs(t *testing.T) {
+	// Given.
+	ctx := oidctest.NewContext(t)
+	ctx.SectorIdentifierCache = oidc.NewSectorIdentifierCache(time.Minute, 2)
+
+	// When.
+	ctx.CacheSectorIdentifierRedirectURIs("https://example.com/sector1", []string{"https://example.com/callback1"})
+	ctx.CacheSectorIdentifierRedirectURIs("https://example.com/sector2", []string{"https://example.com/callback2"})
+	ctx.CacheSectorIdentifierRedirectURIs("https://example.com/sector3", []string{"https://example.com/callback3"})
+
+	// Then.
+	if _, ok := ctx.CachedSectorIdentifierRedirectURIs("https://example.com/sector1"); ok {
+		t.Error("the oldest entry should be evicted")
+	}
+
+	for _, uri := range []string{"https://example.com/sector2", "https://example.com/sector3"} {
+		if _, ok := ctx.CachedSectorIdentifierRedirectURIs(uri); !ok {
+			t.Errorf("%s should be cached", uri)
+		}
+	}
+}
+
+func TestBindGrantToConsent(t *testing.T) {
+	// Given.
+	ctx := oidctest.NewContext(t)
+	ctx.ConsentIsEnabled = true
+	manager := storage.NewConsentManager()
+	ctx.ConsentManager = manager
+	now := timeutil.TimestampNow()
+	stored := &goidc.Consent{
+		Subject:  "random_subject",
+		ClientID: "random_client_id",
+		Grants: []goidc.ConsentGrant{
+			{ID: "expired_grant_id", ExpiresAtTimestamp: now - 10},
+			{ID: "active_grant_id", ExpiresAtTimestamp: now + 60},
+		},
+	}
+	_ = manager.Save(context.Background(), stored)
+
+	// When.
+	err := ctx.BindGrantToConsent(&goidc.GrantSession{
+		ID:                 "random_grant_id",
+		ExpiresAtTimestamp: now + 60,
+		GrantInfo: goidc.GrantInfo{
+			Subject:  "random_subject",
+			ClientID: "random_client_id",
+		},
+	})
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	consent, _ := ctx.Consent("random_subject", "random_client_id")
+	want := []goidc.ConsentGrant{
+		{ID: "active_grant_id", ExpiresAtTimestamp: now + 60},
+		{ID: "random_grant_id", ExpiresAtTimestamp: now + 60},
+	}
+	if diff := cmp.Diff(consent.Grants, want); diff != "" {
+		t.Error(diff)
+	}
+
+	if len(stored.Grants) != 2 || stored.Grants[0].ID != "expired_grant_id" {
+		t.Errorf("the consent returned by the storage must not be modified: %v", stored.Grants)
+	}
+}
+
+func TestAfterClientAuthn_SuccessResetsFailures(t *testing.T) {
+	// Given.
+	ctx := oidctest.NewContext(t)
+	ctx.ClientAuthnFailures = oidc.NewClientAuthnFailures(time.Minute, 10)
+	var failures int
+	ctx.AfterClientAuthnFunc = func(_ *http.Request, _ string, n int, _ error) {
+		failures = n
+	}
+	authnErr := errors.New("invalid secret")
+
+	// When.
+	ctx.AfterClientAuthn("random_client_id", authnErr)
+	ctx.AfterClientAuthn("random_client_id", authnErr)
+
+	// Then.
+	if failures != 2 {
+		t.Fatalf("failures = %d, want 2", failures)
+	}
+
+	// When.
+	ctx.AfterClientAuthn("random_client_id", nil)
+	ctx.AfterClientAuthn("random_client_id", authnErr)
+
+	// Then.
+	if failures != 1 {
+		t.Errorf("the failures must be reset after a success, failures = %d", failures)
+	}
+}
+
+func TestAfterClientAuthn_MaxEntries(t *testing.T) {
+	// Given.
+	ctx := oidctest.NewContext(t)
+	ctx.ClientAuthnFailures = oidc.NewClientAuthnFailures(time.Minute, 1)
+	var failures int
+	ctx.BeforeClientAuthnFunc = func(_ *http.Request, _ string, n int) error {
+		failures = n
+		return nil
+	}
+	authnErr := errors.New("invalid secret")
+
+	// When.
+	ctx.AfterClientAuthn("random_client_id", authnErr)
+	ctx.AfterClientAuthn("other_client_id", authnErr)
+
+	// Then.
+	_ = ctx.BeforeClientAuthn("random_client_id")
+	if failures != 0 {
+		t.Errorf("the oldest entry should be evicted, failures = %d", failures)
+	}
+
+	_ = ctx.BeforeClientAuthn("other_client_id")
+	if failures != 1 {
+		t.Errorf("failures = %d, want 1", failures)
+	}
+}
+
+func TestTokenOptions_ClientTokenFormat(t *testing.T) {
+	testCases := []struct {
+		name   string
+		opts   goidc.TokenOptions
+		format goidc.TokenFormat
+		want   goidc.TokenOptions
+	}{
+		{
+			name: "jwt to opaque",
+			opts: goidc.TokenOptions{
+				Format:            goidc.TokenFormatJWT,
+				LifetimeSecs:      60,
+				JWTSignatureKeyID: "random_key_id",
+				OpaqueLength:      70,
+				OpaqueIsStateless: true,
+			},
+			format: goidc.TokenFormatOpaque,
+			want: goidc.TokenOptions{
+				Format:            goidc.TokenFormatOpaque,
+				LifetimeSecs:      60,
+				JWTSignatureKeyID: "random_key_id",
+				OpaqueLength:      70,
+				OpaqueIsStateless: true,
+			},
+		},
+		{
+			name: "opaque to jwt",
+			opts: goidc.TokenOptions{
+				Format:            goidc.TokenFormatOpaque,
+				LifetimeSecs:      60,
+				JWTSignatureKeyID: "random_key_id",
+				OpaqueLength:      70,
+			},
+			format: goidc.TokenFormatJWT,
+			want: goidc.TokenOptions{
+				Format:            goidc.TokenFormatJWT,
+				LifetimeSecs:      60,
+				JWTSignatureKeyID: "random_key_id",
+				OpaqueLength:      70,
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// Given.
+			ctx := oidctest.NewContext(t)
+			ctx.ClientTokenMetadataIsEnabled = true
+			ctx.TokenOptionsFunc = func(goidc.GrantInfo) goidc.TokenOptions {
+				return testCase.opts
+			}
+			client, _ := oidctest.NewClient(t)
+			client.AccessTokenFormat = testCase.format
+
+			// When.
+			opts := ctx.TokenOptions(goidc.GrantInfo{}, client)
+
+			// Then.
+			if diff := cmp.Diff(opts, testCase.want); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package oidc
+
+import (
+	"context"
+
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+type endpointInfoKey struct{}
+
+// EndpointInfo collects what happened during a request to an endpoint so it
+// can be reported once the request is handled.
+type EndpointInfo struct {
+	Client *goidc.Client
+	Err    error
+}
+
+// WithEndpointInfo returns a copy of the context in which the information
+// about the request is collected into the returned EndpointInfo.
+func WithEndpointInfo(ctx context.Context) (context.Context, *EndpointInfo) {
+	info := &EndpointInfo{}
+	return context.WithValue(ctx, endpointInfoKey{}, info), info
+}
+
+func (ctx Context) endpointInfo() *EndpointInfo {
+	info, _ := ctx.Context().Value(endpointInfoKey{}).(*EndpointInfo)
+	return info
+}
+
+// SetAuthenticatedClient records the client authenticated during the request.
+func (ctx Context) SetAuthenticatedClient(c *goidc.Client) {
+	if info := ctx.endpointInfo(); info != nil {
+		info.Client = c
+	}
+}
+
+func (ctx Context) recordEndpointErr(err error) {
+	if info := ctx.endpointInfo(); info != nil && info.Err == nil {
+		info.Err = err
+	}
+}
+
+// MergeEndpointInfo records the information collected in another context, e.g.
+// by a handler running in a separate goroutine.
+func (ctx Context) MergeEndpointInfo(other *EndpointInfo) {
+	if other.Client != nil {
+		ctx.SetAuthenticatedClient(other.Client)
+	}
+	if other.Err != nil {
+		ctx.recordEndpointErr(other.Err)
+	}
+}
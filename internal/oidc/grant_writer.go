@@ -0,0 +1,89 @@
+package oidc
+
+import (
+	"context"
+	"sync"
+
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+// GrantSessionWriter persists grant sessions in the background so their writes
+// stay out of the critical path of token responses.
+// Sessions are written one at a time in the order they were enqueued, so
+// successive updates of the same session are not reordered.
+type GrantSessionWriter struct {
+	manager goidc.GrantSessionManager
+	errFunc goidc.GrantSessionSaveErrorFunc
+	queue   chan grantSessionWrite
+	done    chan struct{}
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+type grantSessionWrite struct {
+	ctx     context.Context
+	session *goidc.GrantSession
+}
+
+// NewGrantSessionWriter starts a writer with a queue of at most queueSize
+// sessions. errFunc, if not nil, is called when a write fails.
+func NewGrantSessionWriter(
+	manager goidc.GrantSessionManager,
+	queueSize int,
+	errFunc goidc.GrantSessionSaveErrorFunc,
+) *GrantSessionWriter {
+	w := &GrantSessionWriter{
+		manager: manager,
+		errFunc: errFunc,
+		queue:   make(chan grantSessionWrite, queueSize),
+		done:    make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// Enqueue schedules the session to be saved. A copy of the session is saved,
+// so the caller can keep using it.
+// If the queue is full, Enqueue waits for room instead of letting the session
+// skip the queue, which would reorder the writes. False is returned if ctx is
+// done before the session could be enqueued, or if the writer was closed, in
+// which case Enqueue returns only after the sessions enqueued are saved.
+func (w *GrantSessionWriter) Enqueue(ctx context.Context, session *goidc.GrantSession) bool {
+	w.mu.RLock()
+	if w.closed {
+		w.mu.RUnlock()
+		<-w.done
+		return false
+	}
+	defer w.mu.RUnlock()
+
+	s := *session
+	select {
+	case w.queue <- grantSessionWrite{ctx: context.WithoutCancel(ctx), session: &s}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// Close stops accepting sessions and waits until the ones enqueued are saved.
+func (w *GrantSessionWriter) Close() {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.queue)
+	}
+	w.mu.Unlock()
+
+	<-w.done
+}
+
+func (w *GrantSessionWriter) run() {
+	defer close(w.done)
+	for write := range w.queue {
+		if err := w.manager.Save(write.ctx, write.session); err != nil && w.errFunc != nil {
+			w.errFunc(write.ctx, write.session, err)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package oidc_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/luikyv/go-oidc/internal/oidc"
+	"github.com/luikyv/go-oidc/internal/oidctest"
+	"github.com/luikyv/go-oidc/internal/storage"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+func TestGrantSessionWriter(t *testing.T) {
+	// Given.
+	manager := storage.NewGrantSessionManager()
+	writer := oidc.NewGrantSessionWriter(manager, 10, nil)
+	session := &goidc.GrantSession{ID: "random_session_id"}
+
+	// When.
+	ok := writer.Enqueue(context.Background(), session)
+	writer.Close()
+
+	// Then.
+	if !ok {
+		t.Fatal("the session should be enqueued")
+	}
+
+	if _, exists := manager.Sessions[session.ID]; !exists {
+		t.Error("the session was not saved")
+	}
+
+	if writer.Enqueue(context.Background(), session) {
+		t.Error("sessions cannot be enqueued after the writer is closed")
+	}
+}
+
+func TestGrantSessionWriter_SaveError(t *testing.T) {
+	// Given.
+	var errSession *goidc.GrantSession
+	var saveErr error
+	writer := oidc.NewGrantSessionWriter(
+		failingGrantSessionManager{storage.NewGrantSessionManager()},
+		10,
+		func(_ context.Context, gs *goidc.GrantSession, err error) {
+			errSession, saveErr = gs, err
+		},
+	)
+
+	// When.
+	writer.Enqueue(context.Background(), &goidc.GrantSession{ID: "random_session_id"})
+	writer.Close()
+
+	// Then.
+	if saveErr == nil {
+		t.Fatal("the error func should be called")
+	}
+
+	if errSession.ID != "random_session_id" {
+		t.Errorf("ID = %s, want random_session_id", errSession.ID)
+	}
+}
+
+func TestGrantSessionWriter_QueueFull(t *testing.T) {
+	// Given.
+	manager := &blockingGrantSessionManager{
+		GrantSessionManager: storage.NewGrantSessionManager(),
+		release:             make(chan struct{}),
+	}
+	writer := oidc.NewGrantSessionWriter(manager, 1, nil)
+	// The first session is held by the manager and the second fills the queue.
+	writer.Enqueue(context.Background(), &goidc.GrantSession{ID: "session_1"})
+	writer.Enqueue(context.Background(), &goidc.GrantSession{ID: "session_2"})
+
+	// When.
+	enqueued := make(chan bool)
+	go func() {
+		enqueued <- writer.Enqueue(context.Background(), &goidc.GrantSession{ID: "session_3"})
+	}()
+
+	// Then.
+	select {
+	case <-enqueued:
+		t.Fatal("the session should wait for room in the queue")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(manager.release)
+	if !<-enqueued {
+		t.Fatal("the session should be enqueued")
+	}
+	writer.Close()
+
+	want := []string{"session_1", "session_2", "session_3"}
+	if diff := cmp.Diff(manager.saved, want); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestSaveGrantSessionAsync_WriterClosed(t *testing.T) {
+	// Given.
+	ctx := oidctest.NewContext(t)
+	manager := storage.NewGrantSessionManager()
+	ctx.GrantSessionManager = manager
+	ctx.GrantSessionWriter = oidc.NewGrantSessionWriter(manager, 1, nil)
+	ctx.GrantSessionWriter.Close()
+	session := &goidc.GrantSession{ID: "random_session_id"}
+
+	// When.
+	err := ctx.SaveGrantSessionAsync(session)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, exists := manager.Sessions[session.ID]; !exists {
+		t.Error("the session should be saved synchronously")
+	}
+}
+
+func TestSaveGrantSessionAsync_ContextDone(t *testing.T) {
+	// Given.
+	manager := &blockingGrantSessionManager{
+		GrantSessionManager: storage.NewGrantSessionManager(),
+		release:             make(chan struct{}),
+	}
+	writer := oidc.NewGrantSessionWriter(manager, 1, nil)
+	defer writer.Close()
+	defer close(manager.release)
+	writer.Enqueue(context.Background(), &goidc.GrantSession{ID: "session_1"})
+	writer.Enqueue(context.Background(), &goidc.GrantSession{ID: "session_2"})
+
+	ctx := oidctest.NewContext(t)
+	ctx.GrantSessionManager = manager
+	ctx.GrantSessionWriter = writer
+	c, cancel := context.WithCancel(context.Background())
+	cancel()
+	ctx.SetContext(c)
+
+	// When.
+	err := ctx.SaveGrantSessionAsync(&goidc.GrantSession{ID: "session_3"})
+
+	// Then.
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want %v", err, context.Canceled)
+	}
+
+	if _, exists := manager.Sessions["session_3"]; exists {
+		t.Error("the session should not skip the queue")
+	}
+}
+
+type failingGrantSessionManager struct {
+	*storage.GrantSessionManager
+}
+
+func (failingGrantSessionManager) Save(context.Context, *goidc.GrantSession) error {
+	return errors.New("unavailable")
+}
+
+// blockingGrantSessionManager holds the saves until release is closed and
+// records the order in which the sessions were saved.
+type blockingGrantSessionManager struct {
+	*storage.GrantSessionManager
+	release chan struct{}
+	saved   []string
+}
+
+func (m *blockingGrantSessionManager) Save(ctx context.Context, gs *goidc.GrantSession) error {
+	<-m.release
+	m.saved = append(m.saved, gs.ID)
+	return m.GrantSessionManager.Save(ctx, gs)
+}
@@ -0,0 +1,64 @@
+package oidc
+
+import (
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/luikyv/go-oidc/internal/timeutil"
+)
+
+// ClientJWKSCache keeps the client JWKS fetched from jwks_uri in memory, so
+// they are not fetched again every time a response is encrypted.
+type ClientJWKSCache struct {
+	ttl     time.Duration
+	entries map[string]clientJWKSCacheEntry
+	mu      sync.Mutex
+}
+
+type clientJWKSCacheEntry struct {
+	jwks      jose.JSONWebKeySet
+	expiresAt time.Time
+}
+
+// NewClientJWKSCache creates a cache whose entries are kept for ttl.
+func NewClientJWKSCache(ttl time.Duration) *ClientJWKSCache {
+	return &ClientJWKSCache{
+		ttl:     ttl,
+		entries: make(map[string]clientJWKSCacheEntry),
+	}
+}
+
+func (c *ClientJWKSCache) save(uri string, jwks jose.JSONWebKeySet) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := timeutil.Now()
+	for entryURI, entry := range c.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(c.entries, entryURI)
+		}
+	}
+
+	c.entries[uri] = clientJWKSCacheEntry{
+		jwks:      jwks,
+		expiresAt: now.Add(c.ttl),
+	}
+}
+
+func (c *ClientJWKSCache) jwks(uri string) (jose.JSONWebKeySet, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[uri]
+	if !ok {
+		return jose.JSONWebKeySet{}, false
+	}
+
+	if !timeutil.Now().Before(entry.expiresAt) {
+		delete(c.entries, uri)
+		return jose.JSONWebKeySet{}, false
+	}
+
+	return entry.jwks, true
+}
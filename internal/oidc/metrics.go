@@ -0,0 +1,103 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+// Metrics holds the instruments used to record the metrics of the provider.
+type Metrics struct {
+	tokens             goidc.Counter
+	authorizations     goidc.Counter
+	clientAuthnFailure goidc.Counter
+	requestDuration    goidc.Histogram
+	activeGrants       goidc.Counter
+}
+
+func NewMetrics(meter goidc.Meter) *Metrics {
+	return &Metrics{
+		tokens: meter.Counter(goidc.MetricTokens,
+			"Number of tokens issued."),
+		authorizations: meter.Counter(goidc.MetricAuthorizations,
+			"Number of authorization flows finished."),
+		clientAuthnFailure: meter.Counter(goidc.MetricClientAuthnFailures,
+			"Number of failed client authentications."),
+		requestDuration: meter.Histogram(goidc.MetricRequestDuration,
+			"Duration of the requests to the endpoints.", "s"),
+		activeGrants: meter.UpDownCounter(goidc.MetricActiveGrants,
+			"Number of grants issued and not yet revoked or expired."),
+	}
+}
+
+// RecordRequestDuration records how long a request to the endpoint took.
+// It does nothing if metrics are not configured.
+func (m *Metrics) RecordRequestDuration(
+	ctx context.Context,
+	duration time.Duration,
+	attrs ...goidc.Attribute,
+) {
+	if m == nil {
+		return
+	}
+	m.requestDuration.Record(ctx, duration.Seconds(), attrs...)
+}
+
+// RecordAuthorization records the outcome of an authorization flow.
+// A nil error means the flow finished successfully.
+// The client is not recorded, since the number of clients is unbounded and
+// each would create a new time series.
+func (ctx Context) RecordAuthorization(err error) {
+	if ctx.Metrics == nil {
+		return
+	}
+
+	var attrs []goidc.Attribute
+	if err != nil {
+		attrs = append(attrs, goidc.Attribute{Key: goidc.AttributeErrorType, Value: errorType(err)})
+	}
+	ctx.Metrics.authorizations.Add(ctx, 1, attrs...)
+}
+
+// RecordClientAuthnFailure records that a client failed to authenticate.
+func (ctx Context) RecordClientAuthnFailure(err error) {
+	if ctx.Metrics == nil {
+		return
+	}
+	ctx.Metrics.clientAuthnFailure.Add(ctx, 1,
+		goidc.Attribute{Key: goidc.AttributeErrorType, Value: errorType(err)})
+}
+
+func (ctx Context) recordTokenEvent(
+	eventType goidc.TokenEventType,
+	session *goidc.GrantSession,
+) {
+	if ctx.Metrics == nil {
+		return
+	}
+
+	switch eventType {
+	case goidc.TokenEventIssuance, goidc.TokenEventRefresh:
+		ctx.Metrics.tokens.Add(ctx, 1,
+			goidc.Attribute{Key: goidc.AttributeGrantType, Value: string(session.GrantType)},
+			goidc.Attribute{Key: goidc.AttributeTokenEvent, Value: string(eventType)},
+		)
+		if eventType == goidc.TokenEventIssuance {
+			ctx.Metrics.activeGrants.Add(ctx, 1)
+		}
+	case goidc.TokenEventRevocation, goidc.TokenEventExpiry:
+		ctx.Metrics.activeGrants.Add(ctx, -1)
+	}
+}
+
+// errorType returns the error code, if err is a [goidc.Error], so the
+// attributes have a bounded number of values.
+func errorType(err error) string {
+	var oidcErr goidc.Error
+	if errors.As(err, &oidcErr) {
+		return string(oidcErr.Code)
+	}
+	return "unknown"
+}
@@ -0,0 +1,35 @@
+package oidc
+
+import (
+	"github.com/go-jose/go-jose/v4"
+)
+
+// Precomputed holds values derived from the configuration once the provider
+// is built, so they are not recomputed for every request.
+// The slices are shared between requests, so they are returned with their
+// capacity clipped to prevent appends from writing to them.
+type Precomputed struct {
+	tokenAuthnSigAlgs              []jose.SignatureAlgorithm
+	tokenIntrospectionAuthnSigAlgs []jose.SignatureAlgorithm
+	tokenRevocationAuthnSigAlgs    []jose.SignatureAlgorithm
+	clientAuthnSigAlgs             []jose.SignatureAlgorithm
+	sigAlgs                        []jose.SignatureAlgorithm
+	publicKeys                     jose.JSONWebKeySet
+}
+
+// NewPrecomputed derives the values from the configuration. It must be called
+// once the configuration is final and before its Precomputed field is set, so
+// the values are computed with the same logic used when nothing is
+// precomputed.
+func NewPrecomputed(config *Configuration) *Precomputed {
+	ctx := Context{Configuration: config}
+
+	return &Precomputed{
+		tokenAuthnSigAlgs:              ctx.TokenAuthnSigAlgs(),
+		tokenIntrospectionAuthnSigAlgs: ctx.TokenIntrospectionAuthnSigAlgs(),
+		tokenRevocationAuthnSigAlgs:    ctx.TokenRevocationAuthnSigAlgs(),
+		clientAuthnSigAlgs:             ctx.ClientAuthnSigAlgs(),
+		sigAlgs:                        ctx.SigAlgs(),
+		publicKeys:                     ctx.PublicKeys(),
+	}
+}
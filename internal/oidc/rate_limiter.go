@@ -0,0 +1,73 @@
+package oidc
+
+import (
+	"sync"
+
+	"github.com/luikyv/go-oidc/internal/timeutil"
+)
+
+// RateLimiter allows at most a number of events per key, e.g. per IP
+// address, in fixed windows of time.
+// Events are counted in memory, so each instance of the provider limits them
+// separately.
+type RateLimiter struct {
+	max        int
+	windowSecs int
+
+	mu      sync.Mutex
+	windows map[string]*rateLimitWindow
+	// prunedAtTimestamp is when the expired windows were last removed.
+	prunedAtTimestamp int
+}
+
+type rateLimitWindow struct {
+	count            int
+	startedTimestamp int
+}
+
+// NewRateLimiter creates a limiter that allows max events per key every
+// windowSecs seconds.
+func NewRateLimiter(max, windowSecs int) *RateLimiter {
+	return &RateLimiter{
+		max:        max,
+		windowSecs: windowSecs,
+		windows:    map[string]*rateLimitWindow{},
+	}
+}
+
+// Allow records an event for the key and returns whether it is within the
+// limit.
+func (l *RateLimiter) Allow(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := timeutil.TimestampNow()
+	l.prune(now)
+
+	window, ok := l.windows[key]
+	if !ok || now >= window.startedTimestamp+l.windowSecs {
+		window = &rateLimitWindow{startedTimestamp: now}
+		l.windows[key] = window
+	}
+
+	if window.count >= l.max {
+		return false
+	}
+	window.count++
+	return true
+}
+
+// prune removes the windows already finished, at most once per window, so
+// keys that stop sending events don't accumulate.
+func (l *RateLimiter) prune(now int) {
+	if now < l.prunedAtTimestamp+l.windowSecs {
+		return
+	}
+
+	for key, window := range l.windows {
+		if now >= window.startedTimestamp+l.windowSecs {
+			delete(l.windows, key)
+		}
+	}
+	l.prunedAtTimestamp = now
+}
@@ -0,0 +1,28 @@
+package oidc_test
+
+import (
+	"testing"
+
+	"github.com/luikyv/go-oidc/internal/oidc"
+)
+
+func TestRateLimiter(t *testing.T) {
+	// Given.
+	limiter := oidc.NewRateLimiter(2, 60)
+
+	// When.
+	allowed := []bool{
+		limiter.Allow("192.0.2.1"),
+		limiter.Allow("192.0.2.1"),
+		limiter.Allow("192.0.2.1"),
+		limiter.Allow("192.0.2.2"),
+	}
+
+	// Then.
+	want := []bool{true, true, false, true}
+	for i := range want {
+		if allowed[i] != want[i] {
+			t.Errorf("Allow() = %t, want %t. attempt %d", allowed[i], want[i], i+1)
+		}
+	}
+}
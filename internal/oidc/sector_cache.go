@@ -0,0 +1,82 @@
+package oidc
+
+import (
+	"sync"
+	"time"
+
+	"github.com/luikyv/go-oidc/internal/timeutil"
+)
+
+// SectorIdentifierCache keeps the redirect URIs fetched from
+// sector_identifier_uri in memory, so the same document is not requested on
+// every registration.
+// Since any client able to register can add entries, the number of entries is
+// bounded and the ones closest to expiring are evicted first.
+type SectorIdentifierCache struct {
+	ttl        time.Duration
+	maxEntries int
+	entries    map[string]sectorIdentifierCacheEntry
+	mu         sync.Mutex
+}
+
+type sectorIdentifierCacheEntry struct {
+	redirectURIs []string
+	expiresAt    time.Time
+}
+
+// NewSectorIdentifierCache creates a cache whose entries are kept for ttl and
+// that holds at most maxEntries.
+func NewSectorIdentifierCache(ttl time.Duration, maxEntries int) *SectorIdentifierCache {
+	return &SectorIdentifierCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[string]sectorIdentifierCacheEntry),
+	}
+}
+
+func (c *SectorIdentifierCache) save(uri string, redirectURIs []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := timeutil.Now()
+	for entryURI, entry := range c.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(c.entries, entryURI)
+		}
+	}
+
+	if _, ok := c.entries[uri]; !ok && len(c.entries) >= c.maxEntries {
+		var oldestURI string
+		var oldest time.Time
+		for entryURI, entry := range c.entries {
+			// Ties are broken by the URI so the eviction is deterministic.
+			if oldestURI == "" || entry.expiresAt.Before(oldest) ||
+				(entry.expiresAt.Equal(oldest) && entryURI < oldestURI) {
+				oldestURI, oldest = entryURI, entry.expiresAt
+			}
+		}
+		delete(c.entries, oldestURI)
+	}
+
+	c.entries[uri] = sectorIdentifierCacheEntry{
+		redirectURIs: redirectURIs,
+		expiresAt:    now.Add(c.ttl),
+	}
+}
+
+func (c *SectorIdentifierCache) redirectURIs(uri string) ([]string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[uri]
+	if !ok {
+		return nil, false
+	}
+
+	if !timeutil.Now().Before(entry.expiresAt) {
+		delete(c.entries, uri)
+		return nil, false
+	}
+
+	return entry.redirectURIs, true
+}
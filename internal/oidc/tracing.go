@@ -0,0 +1,33 @@
+package oidc
+
+import "github.com/luikyv/go-oidc/pkg/goidc"
+
+// StartSpan starts a span as a child of the span in the context, if any.
+// The context returned carries the new span, so the operations executed with
+// it are traced as its children.
+// If no tracer is configured, the span returned does nothing.
+func (ctx Context) StartSpan(name string, attrs ...goidc.Attribute) (Context, goidc.Span) {
+	if ctx.Tracer == nil {
+		return ctx, noopSpan{}
+	}
+
+	spanCtx, span := ctx.Tracer.Start(ctx.Context(), name, attrs...)
+	ctx.SetContext(spanCtx)
+	return ctx, span
+}
+
+// EndSpan records the error, if any, and ends the span.
+func EndSpan(span goidc.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+	}
+	span.End()
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttributes(...goidc.Attribute) {}
+
+func (noopSpan) RecordError(error) {}
+
+func (noopSpan) End() {}
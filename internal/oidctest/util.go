@@ -1,58 +1,25 @@
 package oidctest
 
 import (
-	"crypto/rand"
-	"crypto/rsa"
-	"encoding/json"
-	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/go-jose/go-jose/v4"
-	"github.com/go-jose/go-jose/v4/jwt"
 	"github.com/luikyv/go-oidc/internal/oidc"
 	"github.com/luikyv/go-oidc/internal/storage"
 	"github.com/luikyv/go-oidc/pkg/goidc"
-	"golang.org/x/crypto/bcrypt"
+	fixtures "github.com/luikyv/go-oidc/pkg/oidctest"
 )
 
 var (
-	Scope1 = goidc.NewScope("scope1")
-	Scope2 = goidc.NewScope("scope2")
+	Scope1 = fixtures.Scope1
+	Scope2 = fixtures.Scope2
 )
 
 func NewClient(t *testing.T) (client *goidc.Client, secret string) {
 	t.Helper()
-
-	secret = "test_secret"
-	hashedSecret, _ := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
-	client = &goidc.Client{
-		ID:           "test_client",
-		HashedSecret: string(hashedSecret),
-		ClientMetaInfo: goidc.ClientMetaInfo{
-			TokenAuthnMethod: goidc.ClientAuthnSecretPost,
-			RedirectURIs:     []string{"https://example.com/callback"},
-			ScopeIDs:         fmt.Sprintf("%s %s %s", Scope1.ID, Scope2.ID, goidc.ScopeOpenID.ID),
-			GrantTypes: []goidc.GrantType{
-				goidc.GrantAuthorizationCode,
-				goidc.GrantImplicit,
-				goidc.GrantRefreshToken,
-				goidc.GrantClientCredentials,
-			},
-			ResponseTypes: []goidc.ResponseType{
-				goidc.ResponseTypeCode,
-				goidc.ResponseTypeIDToken,
-				goidc.ResponseTypeToken,
-				goidc.ResponseTypeCodeAndIDToken,
-				goidc.ResponseTypeCodeAndToken,
-				goidc.ResponseTypeIDTokenAndToken,
-				goidc.ResponseTypeCodeAndIDTokenAndToken,
-			},
-		},
-	}
-
-	return client, secret
+	return fixtures.NewClient(t)
 }
 
 func NewContext(t *testing.T) oidc.Context {
@@ -102,6 +69,8 @@ func NewContext(t *testing.T) oidc.Context {
 			}
 		},
 		AuthnSessionTimeoutSecs: 60,
+		AuthzCodeLifetimeSecs:   60,
+		AuthzCodeLength:         30,
 		TokenAuthnMethods: []goidc.ClientAuthnType{
 			goidc.ClientAuthnNone,
 			goidc.ClientAuthnSecretPost,
@@ -178,27 +147,7 @@ func PrivateRSAOAEPJWK(
 	keyID string,
 ) jose.JSONWebKey {
 	t.Helper()
-
-	jwkStr := `
-		{
-		"p": "6Kn6z5npGNQ_N6z7Ujp4p3SkaRrgTRLNq1k-NqKeyYPUsqOrwddoeuZ_xZzoKhBDehSepsrk8fnJ0D2Y1mInufgoXpPI0raDO5HTeymUj8b6fvZEHOBukv4XHtu01Gp7aetXX-6Cd8Hw0hSpdGSdDldh3S92eULxyUaOQd5XRc0",
-		"kty": "RSA",
-		"q": "xjk0Hy_Bh93zw2u5u-t446NZZWfP9jPAiBeVPHXhzpmsq8-K_t9xnIVM0KScxEZ7VzqXcVpLYBBJVxBeCS_qs4P0AHwBsqECOExSD4oIq7qJUG4xjFQ9g78pbCCnXB18uQr0MoWu85XJaOVudyDLgXgYs-kbJvNQ9-KknBRWp1M",
-		"d": "eeqF_6aoCO79D3Yf1dDDOVaakrmaPfFsiUNqj-yiwcPXG7PEIRlvkN7zXqkGSOPXYQ99X9TXHa0OsHWIDYth1sxmtpY0NZkEwUKkOq4QgKbjMZOzUimwlyo9NmzOM1lwj1PXjSeaH9921_jQLYj7bZ6PVJeir8BhCz720MSsOc0PjYYm78Fm57lilsJYxLqr3tu16TZ4n77ZFu4yDSkm91J0iBUTUtMsAQdeFfkaPqdUWftaQqzTmTvTlExQQE3rtSKClSeJUZBvB5T6MGOizZ8d0qVgP7k4AakhaOjRX97jEH-FNKmRrvSyrh66QK0BTZRyd3zI6H0z9NMT2KzeqQ",
-		"e": "AQAB",
-		"use": "enc",
-		"qi": "mYkPTGpY1YJo-b-f8RbX0lO6PYSPfjgm3UB58FQwUS7uiEgUmWs2DmGC9LCUfUc6V1qcnq9C_IT76-4nXKI7DucAdizBHZZf4lSr7HJ75gCUdeIXkBZSCTJLB9OUWBaZ-LhWLjVECf2UmMbSFOLYHshIOgNWpVqWFQLY4xDXpvY",
-		"dp": "hqfqO0DOwcoFlImPIzYoInLFvPcLHlBlrGgIM8LGt8aO0Z0ciSHMnGTPSmXXkJC9HOjWMZ54BvwUq2sbC-jfKSjQ5HwP3LQ5G774cO3Nx7DXxaduIHBcTsK0Su3JqK7AIrtMZH88D2e1o0DGGlEo_OXiBAu2O9Rc76rgJosyY3k",
-		"alg": "RSA-OAEP",
-		"dq": "ZD9Z1MvaHFRri1FXxWn44WcjNt2hlunlXO5QUxtq74lYgiucJ_npAzeG-Z3Gipz6k8rV_EWmCRczgAyPAiZxlAgPxo7wbN5wuPggKCuu5uqXt02DUWzpD1AGKuD4wuVGxm57wXFKYXZHPf2KOEUlpnyOQa6KRNCZCkRc63J9wHE",
-		"n": "tCd1NEgyMS87vQncSxB2XS8ywCHgYKt4RyibIMxlMBdTEG1BBzICAq5mlITzBJni_pRM25ugxjdVdCR1szc91oLi2cPQESlwsOaj2wCW_d3W8JCQA5Wln_TZtKmFCviDVQIxVZz7CeiL0irRjbrd7jjEx10VREvZt49LK0JbP6nQ44E-_zAN8LUQQgwCgB_IF0dvSYGVJJ-yAUxknwpaTGUUMFjhR7Nk49ya812Z1tIjEVgGOo1LOQoUItEn1Gr73cy5zDemzy6Y0LcFeiDj5GQfqIsI2cIM3Mk9Medc-YsYQ0UfdmKZkyLwytnR2tH6aGp0_zCyVooIDHcXe7Jcdw"
-	}`
-	var jwk jose.JSONWebKey
-	if err := json.Unmarshal([]byte(jwkStr), &jwk); err != nil {
-		t.Fatal(err)
-	}
-	jwk.KeyID = keyID
-	return jwk
+	return fixtures.PrivateRSAOAEPJWK(t, keyID)
 }
 
 func PrivateRS256JWK(
@@ -206,68 +155,27 @@ func PrivateRS256JWK(
 	keyID string,
 	usage goidc.KeyUsage,
 ) jose.JSONWebKey {
-	return privateRSAJWK(t, keyID, jose.RS256, usage)
+	t.Helper()
+	return fixtures.PrivateRS256JWK(t, keyID, usage)
 }
 
 func PrivatePS256JWK(
 	t *testing.T,
 	keyID string,
 	usage goidc.KeyUsage,
-) jose.JSONWebKey {
-	return privateRSAJWK(t, keyID, jose.PS256, usage)
-}
-
-func privateRSAJWK(
-	t *testing.T,
-	keyID string,
-	alg jose.SignatureAlgorithm,
-	usage goidc.KeyUsage,
 ) jose.JSONWebKey {
 	t.Helper()
-
-	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
-	if err != nil {
-		t.Fatalf("could not generated PS256 JWK: %v", err)
-	}
-	return jose.JSONWebKey{
-		Key:       privateKey,
-		KeyID:     keyID,
-		Algorithm: string(alg),
-		Use:       string(usage),
-	}
+	return fixtures.PrivatePS256JWK(t, keyID, usage)
 }
 
 func RawJWKS(jwk jose.JSONWebKey) []byte {
-	jwks, _ := json.Marshal(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{jwk}})
-	return jwks
+	return fixtures.RawJWKS(jwk)
 }
 
 func SafeClaims(jws string, jwk jose.JSONWebKey) (map[string]any, error) {
-	parsedToken, err := jwt.ParseSigned(jws, []jose.SignatureAlgorithm{jose.SignatureAlgorithm(jwk.Algorithm)})
-	if err != nil {
-		return nil, err
-	}
-
-	var claims map[string]any
-	err = parsedToken.Claims(jwk.Public().Key, &claims)
-	if err != nil {
-		return nil, err
-	}
-
-	return claims, nil
+	return fixtures.SafeClaims(jws, jwk)
 }
 
 func UnsafeClaims(jws string, algs ...jose.SignatureAlgorithm) (map[string]any, error) {
-	parsedToken, err := jwt.ParseSigned(jws, algs)
-	if err != nil {
-		return nil, err
-	}
-
-	var claims map[string]any
-	err = parsedToken.UnsafeClaimsWithoutVerification(&claims)
-	if err != nil {
-		return nil, err
-	}
-
-	return claims, nil
+	return fixtures.UnsafeClaims(jws, algs...)
 }
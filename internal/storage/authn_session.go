@@ -3,6 +3,7 @@ package storage
 import (
 	"context"
 	"errors"
+	"sort"
 	"sync"
 
 	"github.com/luikyv/go-oidc/pkg/goidc"
@@ -10,7 +11,16 @@ import (
 
 type AuthnSessionManager struct {
 	Sessions map[string]*goidc.AuthnSession
+	// MaxSessions limits how many sessions are kept in memory. When the limit
+	// is reached, expired sessions are removed and then the sessions closest
+	// to expiring are evicted. Zero means no limit.
+	MaxSessions int
+	// MaxBytes limits the approximate memory used by the sessions, estimated
+	// by the size of their JSON encoding. Sessions are evicted as with
+	// MaxSessions. Zero means no limit.
+	MaxBytes int
 	mu       sync.RWMutex
+	queue    sessionQueue
 }
 
 func NewAuthnSessionManager() *AuthnSessionManager {
@@ -26,7 +36,9 @@ func (m *AuthnSessionManager) Save(
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	m.Sessions[session.ID] = session
+	save(&m.queue, m.Sessions, session.ID, session, func(s *goidc.AuthnSession) int {
+		return s.ExpiresAtTimestamp
+	}, m.MaxSessions, m.MaxBytes)
 	return nil
 }
 
@@ -81,10 +93,35 @@ func (m *AuthnSessionManager) SessionByReferenceID(
 	return session, nil
 }
 
+func (m *AuthnSessionManager) List(
+	_ context.Context,
+	filter goidc.SessionFilter,
+) (
+	[]*goidc.AuthnSession,
+	error,
+) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	sessions := make([]*goidc.AuthnSession, 0, len(m.Sessions))
+	for _, s := range m.Sessions {
+		if filter.MatchesAuthnSession(s) {
+			sessions = append(sessions, s)
+		}
+	}
+	// Sort the sessions so pagination is consistent between calls.
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].ID < sessions[j].ID
+	})
+
+	return paginate(sessions, filter.Offset, filter.Limit), nil
+}
+
 func (m *AuthnSessionManager) Delete(_ context.Context, id string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	m.queue.remove(id)
 	delete(m.Sessions, id)
 	return nil
 }
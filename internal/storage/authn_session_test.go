@@ -2,9 +2,13 @@ package storage_test
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
 	"testing"
 
 	"github.com/luikyv/go-oidc/internal/storage"
+	"github.com/luikyv/go-oidc/internal/timeutil"
 	"github.com/luikyv/go-oidc/pkg/goidc"
 )
 
@@ -30,6 +34,114 @@ func TestSaveAuthnSession(t *testing.T) {
 	}
 }
 
+func TestSaveAuthnSession_MaxSessions(t *testing.T) {
+	// Given.
+	manager := storage.NewAuthnSessionManager()
+	manager.MaxSessions = 2
+	now := timeutil.TimestampNow()
+	manager.Sessions["expired_session_id"] = &goidc.AuthnSession{
+		ID:                 "expired_session_id",
+		ExpiresAtTimestamp: now - 10,
+	}
+	manager.Sessions["closest_to_expire_session_id"] = &goidc.AuthnSession{
+		ID:                 "closest_to_expire_session_id",
+		ExpiresAtTimestamp: now + 60,
+	}
+
+	for _, id := range []string{"session_id_1", "session_id_2"} {
+		// When.
+		err := manager.Save(context.Background(), &goidc.AuthnSession{
+			ID:                 id,
+			ExpiresAtTimestamp: now + 600,
+		})
+
+		// Then.
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if len(manager.Sessions) != 2 {
+		t.Fatalf("len(manager.Sessions) = %d, want 2", len(manager.Sessions))
+	}
+
+	for _, id := range []string{"session_id_1", "session_id_2"} {
+		if _, ok := manager.Sessions[id]; !ok {
+			t.Errorf("session %s was evicted", id)
+		}
+	}
+}
+
+func TestSaveAuthnSession_MaxSessionsEvictsInExpirationOrder(t *testing.T) {
+	// Given.
+	manager := storage.NewAuthnSessionManager()
+	manager.MaxSessions = 3
+	now := timeutil.TimestampNow()
+
+	// When.
+	for i := range 10 {
+		_ = manager.Save(context.Background(), &goidc.AuthnSession{
+			ID:                 fmt.Sprintf("session_id_%d", i),
+			ExpiresAtTimestamp: now + 600 - i,
+		})
+	}
+	// Saving a session again with a later expiration must keep it.
+	_ = manager.Save(context.Background(), &goidc.AuthnSession{
+		ID:                 "session_id_9",
+		ExpiresAtTimestamp: now + 1200,
+	})
+	_ = manager.Save(context.Background(), &goidc.AuthnSession{
+		ID:                 "session_id_10",
+		ExpiresAtTimestamp: now + 900,
+	})
+
+	// Then.
+	if len(manager.Sessions) != 3 {
+		t.Fatalf("len(manager.Sessions) = %d, want 3", len(manager.Sessions))
+	}
+
+	for _, id := range []string{"session_id_0", "session_id_9", "session_id_10"} {
+		if _, ok := manager.Sessions[id]; !ok {
+			t.Errorf("session %s was evicted", id)
+		}
+	}
+}
+
+func TestSaveAuthnSession_MaxBytes(t *testing.T) {
+	// Given.
+	manager := storage.NewAuthnSessionManager()
+	now := timeutil.TimestampNow()
+	newSession := func(id string, exp int) *goidc.AuthnSession {
+		return &goidc.AuthnSession{ID: id, ExpiresAtTimestamp: exp}
+	}
+	sessionBytes, _ := json.Marshal(newSession("session_id_1", now+60))
+	// Room for two sessions.
+	manager.MaxBytes = 2 * len(sessionBytes)
+	_ = manager.Save(context.Background(), newSession("session_id_1", now+60))
+	_ = manager.Save(context.Background(), newSession("session_id_2", now+70))
+
+	// When.
+	err := manager.Save(context.Background(), newSession("session_id_3", now+80))
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, ok := manager.Sessions["session_id_1"]; ok || len(manager.Sessions) != 2 {
+		t.Fatalf("the session closest to expiring must be evicted, got %d sessions", len(manager.Sessions))
+	}
+
+	// When.
+	_ = manager.Delete(context.Background(), "session_id_3")
+	_ = manager.Save(context.Background(), newSession("session_id_4", now+90))
+
+	// Then.
+	if len(manager.Sessions) != 2 {
+		t.Errorf("deleted sessions must not count towards the limit, got %d sessions", len(manager.Sessions))
+	}
+}
+
 func TestAuthnSessionByCallbackID(t *testing.T) {
 	// Given.
 	manager := storage.NewAuthnSessionManager()
@@ -133,3 +245,65 @@ func TestDeleteAuthnSession_SessionDoesNotExist(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 }
+
+func TestListAuthnSessions(t *testing.T) {
+	// Given.
+	manager := storage.NewAuthnSessionManager()
+	now := timeutil.TimestampNow()
+	manager.Sessions["session_1"] = &goidc.AuthnSession{
+		ID:                 "session_1",
+		Subject:            "user_1",
+		ClientID:           "client_1",
+		ExpiresAtTimestamp: now + 60,
+	}
+	manager.Sessions["session_2"] = &goidc.AuthnSession{
+		ID:                 "session_2",
+		Subject:            "user_2",
+		ClientID:           "client_1",
+		ExpiresAtTimestamp: now + 60,
+	}
+	manager.Sessions["session_3"] = &goidc.AuthnSession{
+		ID:                 "session_3",
+		Subject:            "user_1",
+		ClientID:           "client_2",
+		ExpiresAtTimestamp: now + 60,
+	}
+	manager.Sessions["session_4"] = &goidc.AuthnSession{
+		ID:                 "session_4",
+		Subject:            "user_1",
+		ClientID:           "client_1",
+		ExpiresAtTimestamp: now - 1,
+	}
+
+	testCases := []struct {
+		filter goidc.SessionFilter
+		want   []string
+	}{
+		{goidc.SessionFilter{}, []string{"session_1", "session_2", "session_3"}},
+		{goidc.SessionFilter{Subject: "user_1"}, []string{"session_1", "session_3"}},
+		{goidc.SessionFilter{Subject: "user_1", ClientID: "client_1"}, []string{"session_1"}},
+		{goidc.SessionFilter{ID: "session_2"}, []string{"session_2"}},
+		{goidc.SessionFilter{ID: "session_4"}, nil},
+		{goidc.SessionFilter{Offset: 1, Limit: 1}, []string{"session_2"}},
+		{goidc.SessionFilter{Offset: 10}, nil},
+	}
+
+	for _, testCase := range testCases {
+		// When.
+		sessions, err := manager.List(context.Background(), testCase.filter)
+
+		// Then.
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var ids []string
+		for _, s := range sessions {
+			ids = append(ids, s.ID)
+		}
+
+		if !slices.Equal(ids, testCase.want) {
+			t.Errorf("List(%+v) = %v, want %v", testCase.filter, ids, testCase.want)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package storage
 import (
 	"context"
 	"errors"
+	"sort"
 	"sync"
 
 	"github.com/luikyv/go-oidc/pkg/goidc"
@@ -54,6 +55,30 @@ func (m *ClientManager) Client(
 	return c, nil
 }
 
+func (m *ClientManager) List(
+	_ context.Context,
+	filter goidc.ClientFilter,
+) (
+	[]*goidc.Client,
+	error,
+) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	clients := make([]*goidc.Client, 0, len(m.Clients))
+	for _, c := range m.Clients {
+		if filter.Matches(c) {
+			clients = append(clients, c)
+		}
+	}
+	// Sort the clients so pagination is consistent between calls.
+	sort.Slice(clients, func(i, j int) bool {
+		return clients[i].ID < clients[j].ID
+	})
+
+	return paginate(clients, filter.Offset, filter.Limit), nil
+}
+
 func (m *ClientManager) Delete(
 	_ context.Context,
 	id string,
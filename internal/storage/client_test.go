@@ -2,6 +2,7 @@ package storage_test
 
 import (
 	"context"
+	"slices"
 	"testing"
 
 	"github.com/luikyv/go-oidc/internal/storage"
@@ -97,3 +98,46 @@ func TestDeleteClient_ClientDoesNotExist(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 }
+
+func TestListClients(t *testing.T) {
+	// Given.
+	manager := storage.NewClientManager()
+	manager.Clients["client_1"] = &goidc.Client{ID: "client_1", OwnerID: "owner_1"}
+	manager.Clients["client_2"] = &goidc.Client{ID: "client_2", OwnerID: "owner_2"}
+	manager.Clients["client_3"] = &goidc.Client{
+		ID:      "client_3",
+		OwnerID: "owner_1",
+		Status:  goidc.ClientStatusDisabled,
+	}
+	manager.Clients["client_4"] = &goidc.Client{ID: "client_4", OwnerID: "owner_1"}
+
+	testCases := []struct {
+		filter goidc.ClientFilter
+		want   []string
+	}{
+		{goidc.ClientFilter{}, []string{"client_1", "client_2", "client_3", "client_4"}},
+		{goidc.ClientFilter{OwnerID: "owner_1"}, []string{"client_1", "client_3", "client_4"}},
+		{goidc.ClientFilter{OwnerID: "owner_1", Status: goidc.ClientStatusActive}, []string{"client_1", "client_4"}},
+		{goidc.ClientFilter{Offset: 1, Limit: 2}, []string{"client_2", "client_3"}},
+		{goidc.ClientFilter{Offset: 10}, nil},
+	}
+
+	for _, testCase := range testCases {
+		// When.
+		clients, err := manager.List(context.Background(), testCase.filter)
+
+		// Then.
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var ids []string
+		for _, c := range clients {
+			ids = append(ids, c.ID)
+		}
+
+		if !slices.Equal(ids, testCase.want) {
+			t.Errorf("List(%+v) = %v, want %v", testCase.filter, ids, testCase.want)
+		}
+	}
+}
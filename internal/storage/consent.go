@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+type ConsentManager struct {
+	Consents map[string]*goidc.Consent
+	mu       sync.RWMutex
+}
+
+func NewConsentManager() *ConsentManager {
+	return &ConsentManager{
+		Consents: make(map[string]*goidc.Consent),
+	}
+}
+
+func (m *ConsentManager) Save(
+	_ context.Context,
+	consent *goidc.Consent,
+) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.Consents[consentKey(consent.Subject, consent.ClientID)] = consent
+	return nil
+}
+
+func (m *ConsentManager) Consent(
+	_ context.Context,
+	subject string,
+	clientID string,
+) (
+	*goidc.Consent,
+	error,
+) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	consent, exists := m.Consents[consentKey(subject, clientID)]
+	if !exists {
+		return nil, errors.New("entity not found")
+	}
+
+	return consent, nil
+}
+
+func (m *ConsentManager) Delete(
+	_ context.Context,
+	subject string,
+	clientID string,
+) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.Consents, consentKey(subject, clientID))
+	return nil
+}
+
+func consentKey(subject, clientID string) string {
+	return subject + " " + clientID
+}
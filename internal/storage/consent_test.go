@@ -0,0 +1,77 @@
+package storage_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/luikyv/go-oidc/internal/storage"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+func TestSaveConsent(t *testing.T) {
+	// Given.
+	manager := storage.NewConsentManager()
+	consent := &goidc.Consent{
+		Subject:  "random_subject",
+		ClientID: "random_client_id",
+	}
+
+	// When.
+	err := manager.Save(context.Background(), consent)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(manager.Consents) != 1 {
+		t.Errorf("len(manager.Consents) = %d, want 1", len(manager.Consents))
+	}
+}
+
+func TestConsent(t *testing.T) {
+	// Given.
+	manager := storage.NewConsentManager()
+	_ = manager.Save(context.Background(), &goidc.Consent{
+		Subject:  "random_subject",
+		ClientID: "random_client_id",
+		Scopes:   "openid",
+	})
+
+	// When.
+	consent, err := manager.Consent(context.Background(), "random_subject", "random_client_id")
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if consent.Scopes != "openid" {
+		t.Errorf("Scopes = %s, want openid", consent.Scopes)
+	}
+
+	if _, err := manager.Consent(context.Background(), "random_subject", "other_client_id"); err == nil {
+		t.Error("the consent of another client should not be found")
+	}
+}
+
+func TestDeleteConsent(t *testing.T) {
+	// Given.
+	manager := storage.NewConsentManager()
+	_ = manager.Save(context.Background(), &goidc.Consent{
+		Subject:  "random_subject",
+		ClientID: "random_client_id",
+	})
+
+	// When.
+	err := manager.Delete(context.Background(), "random_subject", "random_client_id")
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(manager.Consents) != 0 {
+		t.Errorf("len(manager.Consents) = %d, want 0", len(manager.Consents))
+	}
+}
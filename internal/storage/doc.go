@@ -3,5 +3,7 @@
 // [goidc.GrantSessionManager].
 //
 // The implementations store entities in memory so when the server restarts all
-// of them are lost.
+// of them are lost. The session managers can be bounded with MaxSessions, in
+// which case expired sessions are removed and then the sessions closest to
+// expiring are evicted when the limit is reached.
 package storage
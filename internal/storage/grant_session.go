@@ -3,6 +3,7 @@ package storage
 import (
 	"context"
 	"errors"
+	"sort"
 	"sync"
 
 	"github.com/luikyv/go-oidc/pkg/goidc"
@@ -10,7 +11,16 @@ import (
 
 type GrantSessionManager struct {
 	Sessions map[string]*goidc.GrantSession
+	// MaxSessions limits how many sessions are kept in memory. When the limit
+	// is reached, expired sessions are removed and then the sessions closest
+	// to expiring are evicted. Zero means no limit.
+	MaxSessions int
+	// MaxBytes limits the approximate memory used by the sessions, estimated
+	// by the size of their JSON encoding. Sessions are evicted as with
+	// MaxSessions. Zero means no limit.
+	MaxBytes int
 	mu       sync.RWMutex
+	queue    sessionQueue
 }
 
 func NewGrantSessionManager() *GrantSessionManager {
@@ -26,7 +36,9 @@ func (m *GrantSessionManager) Save(
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	m.Sessions[grantSession.ID] = grantSession
+	save(&m.queue, m.Sessions, grantSession.ID, grantSession, func(s *goidc.GrantSession) int {
+		return s.ExpiresAtTimestamp
+	}, m.MaxSessions, m.MaxBytes)
 	return nil
 }
 
@@ -58,14 +70,57 @@ func (m *GrantSessionManager) SessionByRefreshToken(_ context.Context, refreshTo
 	return grantSession, nil
 }
 
+func (m *GrantSessionManager) List(
+	_ context.Context,
+	filter goidc.SessionFilter,
+) (
+	[]*goidc.GrantSession,
+	error,
+) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	sessions := make([]*goidc.GrantSession, 0, len(m.Sessions))
+	for _, s := range m.Sessions {
+		if filter.MatchesGrantSession(s) {
+			sessions = append(sessions, s)
+		}
+	}
+	// Sort the sessions so pagination is consistent between calls.
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].ID < sessions[j].ID
+	})
+
+	return paginate(sessions, filter.Offset, filter.Limit), nil
+}
+
 func (m *GrantSessionManager) Delete(_ context.Context, id string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	m.queue.remove(id)
 	delete(m.Sessions, id)
 	return nil
 }
 
+func (m *GrantSessionManager) SessionByAuthorizationCode(
+	_ context.Context,
+	code string,
+) (
+	*goidc.GrantSession,
+	error,
+) {
+	grantSession, exists := m.firstSession(func(t *goidc.GrantSession) bool {
+		return t.AuthorizationCode == code
+	})
+
+	if !exists {
+		return nil, errors.New("entity not found")
+	}
+
+	return grantSession, nil
+}
+
 func (m *GrantSessionManager) DeleteByAuthorizationCode(
 	ctx context.Context,
 	code string,
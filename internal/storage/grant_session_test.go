@@ -2,9 +2,11 @@ package storage_test
 
 import (
 	"context"
+	"slices"
 	"testing"
 
 	"github.com/luikyv/go-oidc/internal/storage"
+	"github.com/luikyv/go-oidc/internal/timeutil"
 	"github.com/luikyv/go-oidc/pkg/goidc"
 )
 
@@ -30,6 +32,36 @@ func TestSaveGrantSession(t *testing.T) {
 	}
 }
 
+func TestSaveGrantSession_MaxSessions(t *testing.T) {
+	// Given.
+	manager := storage.NewGrantSessionManager()
+	manager.MaxSessions = 1
+	now := timeutil.TimestampNow()
+	manager.Sessions["old_session_id"] = &goidc.GrantSession{
+		ID:                 "old_session_id",
+		ExpiresAtTimestamp: now + 60,
+	}
+
+	// When.
+	err := manager.Save(context.Background(), &goidc.GrantSession{
+		ID:                 "new_session_id",
+		ExpiresAtTimestamp: now + 600,
+	})
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(manager.Sessions) != 1 {
+		t.Fatalf("len(manager.Sessions) = %d, want 1", len(manager.Sessions))
+	}
+
+	if _, ok := manager.Sessions["new_session_id"]; !ok {
+		t.Error("the new session must be kept")
+	}
+}
+
 func TestGetGrantSessionByTokenID_HappyPath(t *testing.T) {
 	// Given.
 	manager := storage.NewGrantSessionManager()
@@ -110,3 +142,61 @@ func TestDeleteAuthnGrantSession(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 }
+
+func TestListGrantSessions(t *testing.T) {
+	// Given.
+	manager := storage.NewGrantSessionManager()
+	now := timeutil.TimestampNow()
+	manager.Sessions["session_1"] = &goidc.GrantSession{
+		ID:                 "session_1",
+		ExpiresAtTimestamp: now + 60,
+		GrantInfo:          goidc.GrantInfo{Subject: "user_1", ClientID: "client_1"},
+	}
+	manager.Sessions["session_2"] = &goidc.GrantSession{
+		ID:                 "session_2",
+		ExpiresAtTimestamp: now + 60,
+		GrantInfo:          goidc.GrantInfo{Subject: "user_2", ClientID: "client_1"},
+	}
+	manager.Sessions["session_3"] = &goidc.GrantSession{
+		ID:                 "session_3",
+		ExpiresAtTimestamp: now + 60,
+		GrantInfo:          goidc.GrantInfo{Subject: "user_1", ClientID: "client_2"},
+	}
+	manager.Sessions["session_4"] = &goidc.GrantSession{
+		ID:                 "session_4",
+		ExpiresAtTimestamp: now - 1,
+		GrantInfo:          goidc.GrantInfo{Subject: "user_1", ClientID: "client_1"},
+	}
+
+	testCases := []struct {
+		filter goidc.SessionFilter
+		want   []string
+	}{
+		{goidc.SessionFilter{}, []string{"session_1", "session_2", "session_3"}},
+		{goidc.SessionFilter{Subject: "user_1"}, []string{"session_1", "session_3"}},
+		{goidc.SessionFilter{Subject: "user_1", ClientID: "client_1"}, []string{"session_1"}},
+		{goidc.SessionFilter{ID: "session_2"}, []string{"session_2"}},
+		{goidc.SessionFilter{ID: "session_4"}, nil},
+		{goidc.SessionFilter{Offset: 1, Limit: 1}, []string{"session_2"}},
+		{goidc.SessionFilter{Offset: 10}, nil},
+	}
+
+	for _, testCase := range testCases {
+		// When.
+		sessions, err := manager.List(context.Background(), testCase.filter)
+
+		// Then.
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var ids []string
+		for _, s := range sessions {
+			ids = append(ids, s.ID)
+		}
+
+		if !slices.Equal(ids, testCase.want) {
+			t.Errorf("List(%+v) = %v, want %v", testCase.filter, ids, testCase.want)
+		}
+	}
+}
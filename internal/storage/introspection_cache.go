@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/luikyv/go-oidc/internal/timeutil"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+// IntrospectionCache implements [goidc.IntrospectionCache] keeping the grant
+// sessions in memory for TTL.
+type IntrospectionCache struct {
+	TTL     time.Duration
+	entries map[string]introspectionCacheEntry
+	mu      sync.Mutex
+}
+
+type introspectionCacheEntry struct {
+	session   *goidc.GrantSession
+	expiresAt time.Time
+}
+
+func NewIntrospectionCache(ttl time.Duration) *IntrospectionCache {
+	return &IntrospectionCache{
+		TTL:     ttl,
+		entries: make(map[string]introspectionCacheEntry),
+	}
+}
+
+func (c *IntrospectionCache) Save(_ context.Context, session *goidc.GrantSession) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := timeutil.Now()
+	for tokenID, entry := range c.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(c.entries, tokenID)
+		}
+	}
+
+	c.entries[session.TokenID] = introspectionCacheEntry{
+		session:   session,
+		expiresAt: now.Add(c.TTL),
+	}
+	return nil
+}
+
+func (c *IntrospectionCache) SessionByTokenID(_ context.Context, tokenID string) (*goidc.GrantSession, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[tokenID]
+	if !ok {
+		return nil, errors.New("entity not found")
+	}
+
+	if !timeutil.Now().Before(entry.expiresAt) {
+		delete(c.entries, tokenID)
+		return nil, errors.New("entity not found")
+	}
+
+	return entry.session, nil
+}
+
+func (c *IntrospectionCache) Delete(_ context.Context, grantID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for tokenID, entry := range c.entries {
+		if entry.session.ID == grantID {
+			delete(c.entries, tokenID)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package storage_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/luikyv/go-oidc/internal/storage"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+func TestIntrospectionCache(t *testing.T) {
+	// Given.
+	cache := storage.NewIntrospectionCache(time.Minute)
+	session := &goidc.GrantSession{
+		ID:      "random_session_id",
+		TokenID: "random_token_id",
+	}
+
+	// When.
+	err := cache.Save(context.Background(), session)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, err := cache.SessionByTokenID(context.Background(), session.TokenID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got != session {
+		t.Errorf("SessionByTokenID() = %v, want %v", got, session)
+	}
+}
+
+func TestIntrospectionCache_Expired(t *testing.T) {
+	// Given.
+	cache := storage.NewIntrospectionCache(-time.Second)
+	session := &goidc.GrantSession{
+		ID:      "random_session_id",
+		TokenID: "random_token_id",
+	}
+	if err := cache.Save(context.Background(), session); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// When.
+	_, err := cache.SessionByTokenID(context.Background(), session.TokenID)
+
+	// Then.
+	if err == nil {
+		t.Fatal("expired entries must not be returned")
+	}
+}
+
+func TestIntrospectionCache_Delete(t *testing.T) {
+	// Given.
+	cache := storage.NewIntrospectionCache(time.Minute)
+	session := &goidc.GrantSession{
+		ID:      "random_session_id",
+		TokenID: "random_token_id",
+	}
+	if err := cache.Save(context.Background(), session); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The token was refreshed.
+	refreshedSession := *session
+	refreshedSession.TokenID = "random_token_id_2"
+	if err := cache.Save(context.Background(), &refreshedSession); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// When.
+	err := cache.Delete(context.Background(), session.ID)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, tokenID := range []string{session.TokenID, refreshedSession.TokenID} {
+		if _, err := cache.SessionByTokenID(context.Background(), tokenID); err == nil {
+			t.Errorf("the entry for %s must be deleted", tokenID)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+type UserSessionManager struct {
+	Sessions map[string]*goidc.UserSession
+	mu       sync.RWMutex
+}
+
+func NewUserSessionManager() *UserSessionManager {
+	return &UserSessionManager{
+		Sessions: make(map[string]*goidc.UserSession),
+	}
+}
+
+func (m *UserSessionManager) Save(
+	_ context.Context,
+	session *goidc.UserSession,
+) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.Sessions[session.ID] = session
+	return nil
+}
+
+func (m *UserSessionManager) UserSession(
+	_ context.Context,
+	id string,
+) (
+	*goidc.UserSession,
+	error,
+) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	session, exists := m.Sessions[id]
+	if !exists {
+		return nil, errors.New("entity not found")
+	}
+
+	return session, nil
+}
+
+func (m *UserSessionManager) List(
+	_ context.Context,
+	filter goidc.SessionFilter,
+) (
+	[]*goidc.UserSession,
+	error,
+) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	sessions := make([]*goidc.UserSession, 0, len(m.Sessions))
+	for _, s := range m.Sessions {
+		if filter.MatchesUserSession(s) {
+			sessions = append(sessions, s)
+		}
+	}
+	// Sort the sessions so pagination is consistent between calls.
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].ID < sessions[j].ID
+	})
+
+	return paginate(sessions, filter.Offset, filter.Limit), nil
+}
+
+func (m *UserSessionManager) Delete(
+	_ context.Context,
+	id string,
+) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.Sessions, id)
+	return nil
+}
@@ -1,5 +1,10 @@
 package storage
 
+import (
+	"container/heap"
+	"encoding/json"
+)
+
 // findFirst returns the first element in a slice for which the condition is true.
 // If no element is found, 'ok' is set to false.
 func findFirst[T interface{}](slice []T, condition func(T) bool) (element T, ok bool) {
@@ -11,3 +16,165 @@ func findFirst[T interface{}](slice []T, condition func(T) bool) (element T, ok
 
 	return element, false
 }
+
+// sessionQueue orders the sessions kept by an in memory manager by their
+// expiration, so the ones closest to expiring are evicted in logarithmic time
+// when a limit is reached. Its zero value is ready to use.
+//
+// Sessions saved again or deleted leave stale items in the heap, which are
+// skipped when popped and dropped when the heap is compacted.
+type sessionQueue struct {
+	items   expiryHeap
+	tracked map[string]trackedSession
+	bytes   int
+}
+
+type trackedSession struct {
+	expiresAt int
+	size      int
+}
+
+// save stores the entry and evicts other entries while there are more than
+// maxEntries or their approximate size exceeds maxBytes. A limit of zero means
+// no limit. The entry saved is never evicted.
+func save[T any](
+	q *sessionQueue,
+	entries map[string]T,
+	id string,
+	entry T,
+	expiresAt func(T) int,
+	maxEntries int,
+	maxBytes int,
+) {
+	if maxEntries <= 0 && maxBytes <= 0 {
+		entries[id] = entry
+		return
+	}
+
+	// The entries can be changed without the queue, e.g. by writing to the
+	// map directly, in which case the queue is rebuilt.
+	if q.tracked == nil || len(q.tracked) != len(entries) {
+		rebuild(q, entries, expiresAt, maxBytes > 0)
+	}
+
+	size := 0
+	if maxBytes > 0 {
+		size = approximateSize(entry)
+	}
+	q.remove(id)
+	entries[id] = entry
+	q.tracked[id] = trackedSession{expiresAt: expiresAt(entry), size: size}
+	q.bytes += size
+	heap.Push(&q.items, expiryItem{id: id, expiresAt: expiresAt(entry)})
+
+	var skipped []expiryItem
+	for q.items.Len() != 0 &&
+		((maxEntries > 0 && len(entries) > maxEntries) ||
+			(maxBytes > 0 && q.bytes > maxBytes && len(entries) > 1)) {
+		item := heap.Pop(&q.items).(expiryItem)
+		tracked, ok := q.tracked[item.id]
+		if !ok || tracked.expiresAt != item.expiresAt {
+			continue
+		}
+
+		if item.id == id {
+			skipped = append(skipped, item)
+			continue
+		}
+
+		q.remove(item.id)
+		delete(entries, item.id)
+	}
+
+	for _, item := range skipped {
+		heap.Push(&q.items, item)
+	}
+
+	if q.items.Len() > 2*len(q.tracked)+16 {
+		q.compact()
+	}
+}
+
+// remove stops tracking the entry. The caller must delete it from the map.
+func (q *sessionQueue) remove(id string) {
+	if tracked, ok := q.tracked[id]; ok {
+		q.bytes -= tracked.size
+		delete(q.tracked, id)
+	}
+}
+
+func (q *sessionQueue) compact() {
+	q.items = q.items[:0]
+	for id, tracked := range q.tracked {
+		q.items = append(q.items, expiryItem{id: id, expiresAt: tracked.expiresAt})
+	}
+	heap.Init(&q.items)
+}
+
+func rebuild[T any](q *sessionQueue, entries map[string]T, expiresAt func(T) int, withSize bool) {
+	q.tracked = make(map[string]trackedSession, len(entries))
+	q.bytes = 0
+	for id, entry := range entries {
+		size := 0
+		if withSize {
+			size = approximateSize(entry)
+		}
+		q.tracked[id] = trackedSession{expiresAt: expiresAt(entry), size: size}
+		q.bytes += size
+	}
+	q.compact()
+}
+
+// approximateSize estimates the memory used by an entry with the size of its
+// JSON encoding.
+func approximateSize(entry any) int {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return 0
+	}
+	return len(b)
+}
+
+type expiryItem struct {
+	id        string
+	expiresAt int
+}
+
+// expiryHeap implements [heap.Interface] with the items closest to expiring
+// first.
+type expiryHeap []expiryItem
+
+func (h expiryHeap) Len() int { return len(h) }
+
+func (h expiryHeap) Less(i, j int) bool {
+	if h[i].expiresAt == h[j].expiresAt {
+		return h[i].id < h[j].id
+	}
+	return h[i].expiresAt < h[j].expiresAt
+}
+
+func (h expiryHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *expiryHeap) Push(x any) { *h = append(*h, x.(expiryItem)) }
+
+func (h *expiryHeap) Pop() any {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
+
+// paginate returns the page of items defined by offset and limit. A zero
+// limit means all the items after offset.
+func paginate[T any](items []T, offset, limit int) []T {
+	if offset >= len(items) {
+		return nil
+	}
+	items = items[offset:]
+
+	if limit > 0 && limit < len(items) {
+		items = items[:limit]
+	}
+
+	return items
+}
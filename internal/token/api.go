@@ -6,12 +6,19 @@ import (
 	"github.com/luikyv/go-oidc/internal/oidc"
 )
 
-func RegisterHandlers(router *http.ServeMux, config *oidc.Configuration) {
+func RegisterHandlers(router oidc.Router, config *oidc.Configuration) {
 	router.HandleFunc(
 		"POST "+config.EndpointPrefix+config.EndpointToken,
 		oidc.Handler(config, handleCreate),
 	)
 
+	if config.CORSIsEnabled {
+		router.HandleFunc(
+			"OPTIONS "+config.EndpointPrefix+config.EndpointToken,
+			oidc.Handler(config, handlePreflight),
+		)
+	}
+
 	if config.TokenIntrospectionIsEnabled {
 		router.HandleFunc(
 			"POST "+config.EndpointPrefix+config.EndpointIntrospection,
@@ -40,6 +47,10 @@ func handleCreate(ctx oidc.Context) {
 	}
 }
 
+func handlePreflight(ctx oidc.Context) {
+	ctx.WriteCORSPreflight(http.MethodPost)
+}
+
 func handleIntrospection(ctx oidc.Context) {
 	req := newQueryRequest(ctx.Request)
 	tokenInfo, err := introspect(ctx, req)
@@ -28,6 +28,7 @@ func generateAuthorizationCodeGrant(
 	if err != nil {
 		return response{}, err
 	}
+	ctx.AllowCORS(client)
 
 	session, err := authnSession(ctx, req.authorizationCode)
 	if err != nil {
@@ -44,7 +45,7 @@ func generateAuthorizationCodeGrant(
 		return response{}, err
 	}
 
-	token, err := Make(ctx, grantInfo)
+	token, err := Make(ctx, client, grantInfo)
 	if err != nil {
 		return response{}, goidc.Errorf(goidc.ErrorCodeInternalError,
 			"could not generate access token for the authorization code grant", err)
@@ -67,10 +68,16 @@ func generateAuthorizationCodeGrant(
 		TokenType:            token.Type,
 		RefreshToken:         grantSession.RefreshToken,
 		AuthorizationDetails: grantInfo.ActiveAuthDetails,
+		AdditionalParams:     ctx.TokenResponseParams(grantInfo),
 	}
 
 	if strutil.ContainsOpenID(grantInfo.ActiveScopes) {
-		tokenResp.IDToken, err = MakeIDToken(ctx, client, newIDTokenOptions(grantInfo))
+		idTokenOpts, err := newIDTokenOptions(ctx, client, grantInfo)
+		if err != nil {
+			return response{}, err
+		}
+
+		tokenResp.IDToken, err = MakeIDToken(ctx, client, idTokenOpts)
 		if err != nil {
 			return response{}, goidc.Errorf(goidc.ErrorCodeInternalError,
 				"could not generate access id token for the authorization code grant", err)
@@ -86,6 +93,7 @@ func generateAuthorizationCodeGrant(
 		tokenResp.Resources = grantInfo.ActiveResources
 	}
 
+	setRenewalHints(ctx, &tokenResp, grantSession)
 	return tokenResp, nil
 }
 
@@ -104,7 +112,7 @@ func authnSession(
 		// Invalidate any grant associated with the authorization code.
 		// This ensures that even if the code is compromised, the access token
 		// that it generated cannot be misused by a malicious client.
-		_ = ctx.DeleteGrantSessionByAuthorizationCode(authzCode)
+		revokeGrantSessionByAuthorizationCode(ctx, authzCode)
 		return nil, goidc.Errorf(goidc.ErrorCodeInvalidGrant,
 			"invalid authorization code", err)
 	}
@@ -117,6 +125,22 @@ func authnSession(
 	return session, nil
 }
 
+// revokeGrantSessionByAuthorizationCode deletes the grant session issued with
+// the authorization code, if any. The revocation is only notified when the
+// session can be found by its code.
+func revokeGrantSessionByAuthorizationCode(ctx oidc.Context, code string) {
+	grantSession, err := ctx.GrantSessionByAuthorizationCode(code)
+	if err != nil {
+		_ = ctx.DeleteGrantSessionByAuthorizationCode(code)
+		return
+	}
+
+	if err := ctx.DeleteGrantSession(grantSession.ID); err != nil {
+		return
+	}
+	ctx.NotifyTokenEvent(goidc.TokenEventRevocation, grantSession)
+}
+
 func generateAuthorizationCodeGrantSession(
 	ctx oidc.Context,
 	client *goidc.Client,
@@ -128,18 +152,24 @@ func generateAuthorizationCodeGrantSession(
 	error,
 ) {
 
-	grantSession := NewGrantSession(grantInfo, token)
+	grantSession := NewGrantSession(ctx, grantInfo, token)
 	grantSession.AuthorizationCode = code
 	if ctx.ShouldIssueRefreshToken(client, grantInfo) {
 		grantSession.RefreshToken = refreshToken()
-		grantSession.ExpiresAtTimestamp = timeutil.TimestampNow() + ctx.RefreshTokenLifetimeSecs
+		grantSession.ExpiresAtTimestamp = timeutil.TimestampNow() + ctx.RefreshTokenLifetime(client)
 	}
 
-	if err := ctx.SaveGrantSession(grantSession); err != nil {
+	if err := saveGrantSession(ctx, grantSession, token); err != nil {
 		return nil, goidc.Errorf(goidc.ErrorCodeInternalError,
 			"internal error", err)
 	}
 
+	if err := ctx.BindGrantToConsent(grantSession); err != nil {
+		return nil, goidc.Errorf(goidc.ErrorCodeInternalError,
+			"could not bind the grant to the consent", err)
+	}
+
+	ctx.NotifyTokenEvent(goidc.TokenEventIssuance, grantSession)
 	return grantSession, nil
 }
 
@@ -191,6 +221,27 @@ func validateAuthorizationCodeGrantRequest(
 		return err
 	}
 
+	return validateClientCertBinding(ctx, session)
+}
+
+// validateClientCertBinding makes sure the authorization code is exchanged
+// with the certificate its pushed authorization request was bound to, if any.
+func validateClientCertBinding(ctx oidc.Context, session *goidc.AuthnSession) error {
+	if session.ClientCertThumbprint == "" {
+		return nil
+	}
+
+	cert, err := ctx.ClientCert()
+	if err != nil {
+		return goidc.Errorf(goidc.ErrorCodeInvalidGrant,
+			"the authorization code is bound to a client certificate", err)
+	}
+
+	if hashBase64URLSHA256(string(cert.Raw)) != session.ClientCertThumbprint {
+		return goidc.NewError(goidc.ErrorCodeInvalidGrant,
+			"the authorization code is bound to another client certificate")
+	}
+
 	return nil
 }
 
@@ -212,7 +263,9 @@ func authorizationCodeGrantInfo(
 		AdditionalIDTokenClaims:  session.AdditionalIDTokenClaims,
 		AdditionalUserInfoClaims: session.AdditionalUserInfoClaims,
 		AdditionalTokenClaims:    session.AdditionalTokenClaims,
+		Claims:                   session.Claims,
 		Store:                    session.Store,
+		SessionID:                session.SessionID,
 	}
 
 	if req.scopes != "" {
@@ -1,8 +1,11 @@
 package token
 
 import (
+	"crypto/x509"
 	"errors"
 	"fmt"
+	"net/http"
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -89,6 +92,47 @@ func TestGenerateGrant_AuthorizationCodeGrant(t *testing.T) {
 	}
 }
 
+func TestGenerateGrant_AuthorizationCodeGrant_SessionID(t *testing.T) {
+	// Given.
+	ctx, client, session := setUpAuthzCodeGrant(t)
+	session.SessionID = "random_sid"
+	if err := ctx.SaveAuthnSession(session); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	req := request{
+		grantType:         goidc.GrantAuthorizationCode,
+		redirectURI:       client.RedirectURIs[0],
+		authorizationCode: session.AuthorizationCode,
+	}
+
+	// When.
+	tokenResp, err := generateGrant(ctx, req)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	grantSessions := oidctest.GrantSessions(t, ctx)
+	if len(grantSessions) != 1 {
+		t.Fatalf("len(grantSessions) = %d, want 1", len(grantSessions))
+	}
+
+	if grantSessions[0].SessionID != "random_sid" {
+		t.Errorf("SessionID = %s, want random_sid", grantSessions[0].SessionID)
+	}
+
+	claims, err := oidctest.SafeClaims(tokenResp.IDToken, ctx.PrivateJWKS.Keys[0])
+	if err != nil {
+		t.Fatalf("error parsing claims: %v", err)
+	}
+
+	if claims[goidc.ClaimSessionID] != "random_sid" {
+		t.Errorf("sid = %v, want random_sid", claims[goidc.ClaimSessionID])
+	}
+}
+
 func TestGenerateGrant_AuthorizationCodeGrant_AuthDetails(t *testing.T) {
 
 	// Given.
@@ -341,8 +385,13 @@ func TestGenerateGrant_AuthorizationCodeGrant_CodeReuseInvalidatesGrant(t *testi
 	_ = ctx.DeleteAuthnSession(session.ID)
 	_ = ctx.SaveGrantSession(&goidc.GrantSession{
 		ID:                "random_id",
+		TokenID:           "random_token_id",
 		AuthorizationCode: session.AuthorizationCode,
 	})
+	var events []goidc.TokenEvent
+	ctx.NotifyTokenEventFunc = func(_ *http.Request, event goidc.TokenEvent) {
+		events = append(events, event)
+	}
 
 	req := request{
 		grantType:         goidc.GrantAuthorizationCode,
@@ -371,6 +420,96 @@ func TestGenerateGrant_AuthorizationCodeGrant_CodeReuseInvalidatesGrant(t *testi
 	if len(grantSessions) != 0 {
 		t.Errorf("len(grantSessions) = %d, want 0", len(grantSessions))
 	}
+
+	want := []goidc.TokenEvent{{
+		Type:    goidc.TokenEventRevocation,
+		GrantID: "random_id",
+		TokenID: "random_token_id",
+	}}
+	if diff := cmp.Diff(events, want); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestGenerateGrant_AuthorizationCodeGrant_DPoPBoundCodeWithDPoPDisabled(t *testing.T) {
+	// Given.
+	ctx, client, session := setUpAuthzCodeGrant(t)
+	session.DPoPJWKThumbprint = "random_jkt"
+
+	req := request{
+		grantType:         goidc.GrantAuthorizationCode,
+		redirectURI:       client.RedirectURIs[0],
+		authorizationCode: session.AuthorizationCode,
+	}
+
+	// When.
+	_, err := generateGrant(ctx, req)
+
+	// Then.
+	if err == nil {
+		t.Fatal("a code bound to a dpop key cannot be used without a proof")
+	}
+
+	var oidcErr goidc.Error
+	if !errors.As(err, &oidcErr) {
+		t.Fatal("invalid error type")
+	}
+
+	if oidcErr.Code != goidc.ErrorCodeInvalidGrant {
+		t.Errorf("ErrorCode = %s, want %s", oidcErr.Code, goidc.ErrorCodeInvalidGrant)
+	}
+}
+
+func TestGenerateGrant_AuthorizationCodeGrant_ClientCertBoundCode(t *testing.T) {
+	testCases := []struct {
+		name    string
+		certRaw string
+		wantErr bool
+	}{
+		{"same certificate", "random_cert", false},
+		{"other certificate", "other_cert", true},
+		{"no certificate", "", true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// Given.
+			ctx, client, session := setUpAuthzCodeGrant(t)
+			session.ClientCertThumbprint = hashBase64URLSHA256("random_cert")
+			ctx.ClientCertFunc = func(*http.Request) (*x509.Certificate, error) {
+				if testCase.certRaw == "" {
+					return nil, errors.New("no certificate")
+				}
+				return &x509.Certificate{Raw: []byte(testCase.certRaw)}, nil
+			}
+
+			req := request{
+				grantType:         goidc.GrantAuthorizationCode,
+				redirectURI:       client.RedirectURIs[0],
+				authorizationCode: session.AuthorizationCode,
+			}
+
+			// When.
+			_, err := generateGrant(ctx, req)
+
+			// Then.
+			if !testCase.wantErr {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+
+			var oidcErr goidc.Error
+			if !errors.As(err, &oidcErr) {
+				t.Fatalf("err = %v, want %s", err, goidc.ErrorCodeInvalidGrant)
+			}
+
+			if oidcErr.Code != goidc.ErrorCodeInvalidGrant {
+				t.Errorf("ErrorCode = %s, want %s", oidcErr.Code, goidc.ErrorCodeInvalidGrant)
+			}
+		})
+	}
 }
 
 func TestIsPkceValid(t *testing.T) {
@@ -405,6 +544,34 @@ func TestIsPkceValid(t *testing.T) {
 	}
 }
 
+func TestVerifierEntropyBits(t *testing.T) {
+	testCases := []struct {
+		codeVerifier string
+		minBits      float64
+		maxBits      float64
+	}{
+		{strings.Repeat("a", 43), 0, 0},
+		{strings.Repeat("ab", 22), 43, 45},
+		{"4ea55634198fb6a0c120d46b26359cf50ccea86fd03302b9bca9fa98", 200, 224},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(
+			fmt.Sprintf("case %v", i),
+			func(t *testing.T) {
+				// When.
+				got := verifierEntropyBits(testCase.codeVerifier)
+
+				// Then.
+				if got < testCase.minBits || got > testCase.maxBits {
+					t.Errorf("verifierEntropyBits() = %f, want between %f and %f",
+						got, testCase.minBits, testCase.maxBits)
+				}
+			},
+		)
+	}
+}
+
 func setUpAuthzCodeGrant(t *testing.T) (
 	ctx oidc.Context,
 	client *goidc.Client,
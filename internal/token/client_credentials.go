@@ -19,6 +19,7 @@ func generateClientCredentialsGrant(
 	if oauthErr != nil {
 		return response{}, oauthErr
 	}
+	ctx.AllowCORS(c)
 
 	if oauthErr := validateClientCredentialsGrantRequest(ctx, req, c); oauthErr != nil {
 		return response{}, oauthErr
@@ -29,13 +30,13 @@ func generateClientCredentialsGrant(
 		return response{}, err
 	}
 
-	token, err := Make(ctx, grantInfo)
+	token, err := Make(ctx, c, grantInfo)
 	if err != nil {
 		return response{}, goidc.Errorf(goidc.ErrorCodeInternalError,
 			"could not generate an access token for the client credentials grant", err)
 	}
 
-	_, err = generateClientCredentialsGrantSession(ctx, grantInfo, token)
+	grantSession, err := generateClientCredentialsGrantSession(ctx, grantInfo, token)
 	if err != nil {
 		return response{}, err
 	}
@@ -45,12 +46,14 @@ func generateClientCredentialsGrant(
 		ExpiresIn:            token.LifetimeSecs,
 		TokenType:            token.Type,
 		AuthorizationDetails: grantInfo.ActiveAuthDetails,
+		AdditionalParams:     ctx.TokenResponseParams(grantInfo),
 	}
 
 	if req.scopes != grantInfo.ActiveScopes {
 		tokenResp.Scopes = grantInfo.ActiveScopes
 	}
 
+	setRenewalHints(ctx, &tokenResp, grantSession)
 	return tokenResp, nil
 }
 
@@ -63,12 +66,13 @@ func generateClientCredentialsGrantSession(
 	error,
 ) {
 
-	grantSession := NewGrantSession(grantInfo, token)
-	if err := ctx.SaveGrantSession(grantSession); err != nil {
+	grantSession := NewGrantSession(ctx, grantInfo, token)
+	if err := saveGrantSession(ctx, grantSession, token); err != nil {
 		return nil, goidc.Errorf(goidc.ErrorCodeInternalError,
 			"could not store the grant session", err)
 	}
 
+	ctx.NotifyTokenEvent(goidc.TokenEventIssuance, grantSession)
 	return grantSession, nil
 }
 
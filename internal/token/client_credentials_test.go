@@ -1,6 +1,7 @@
 package token
 
 import (
+	"net/http"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -59,6 +60,80 @@ func TestHandleGrantCreation_ClientCredentialsGrant(t *testing.T) {
 	}
 }
 
+func TestHandleGrantCreation_ClientCredentialsGrant_TokenResponseHook(t *testing.T) {
+	// Given.
+	ctx, client := setUpClientCredentialsGrant(t)
+	ctx.TokenResponseHookFunc = func(_ *http.Request, gi goidc.GrantInfo) map[string]any {
+		return map[string]any{"patient": gi.Subject}
+	}
+
+	req := request{
+		grantType: goidc.GrantClientCredentials,
+		scopes:    oidctest.Scope1.ID,
+	}
+
+	// When.
+	tokenResp, err := generateGrant(ctx, req)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("error generating the client credentials grant: %v", err)
+	}
+
+	want := map[string]any{"patient": client.ID}
+	if diff := cmp.Diff(tokenResp.AdditionalParams, want); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestHandleGrantCreation_ClientCredentialsGrant_ExpirationHints(t *testing.T) {
+	// Given.
+	ctx, _ := setUpClientCredentialsGrant(t)
+	ctx.TokenExpirationHintsIsEnabled = true
+	ctx.TokenResponseHookFunc = func(*http.Request, goidc.GrantInfo) map[string]any {
+		return map[string]any{"refresh_after": "overridden"}
+	}
+	var renewal goidc.TokenRenewal
+	ctx.TokenRenewalHintFunc = func(_ *http.Request, r goidc.TokenRenewal) map[string]any {
+		renewal = r
+		return map[string]any{
+			"refresh_after": r.AccessTokenExpiresAtTimestamp - 10,
+			"renew_in":      50,
+		}
+	}
+
+	req := request{
+		grantType: goidc.GrantClientCredentials,
+		scopes:    oidctest.Scope1.ID,
+	}
+
+	// When.
+	tokenResp, err := generateGrant(ctx, req)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("error generating the client credentials grant: %v", err)
+	}
+
+	now := timeutil.TimestampNow()
+	if diff := tokenResp.ExpiresAt - (now + tokenResp.ExpiresIn); diff < -1 || diff > 1 {
+		t.Errorf("ExpiresAt = %d, want %d", tokenResp.ExpiresAt, now+tokenResp.ExpiresIn)
+	}
+
+	if tokenResp.RefreshExpiresIn != 0 {
+		t.Errorf("RefreshExpiresIn = %d, want 0 since no refresh token was issued", tokenResp.RefreshExpiresIn)
+	}
+
+	if renewal.AccessTokenExpiresAtTimestamp != tokenResp.ExpiresAt {
+		t.Errorf("AccessTokenExpiresAtTimestamp = %d, want %d", renewal.AccessTokenExpiresAtTimestamp, tokenResp.ExpiresAt)
+	}
+
+	want := map[string]any{"refresh_after": "overridden", "renew_in": 50}
+	if diff := cmp.Diff(tokenResp.AdditionalParams, want); diff != "" {
+		t.Error(diff)
+	}
+}
+
 func TestHandleGrantCreation_ClientCredentialsGrant_ResourceIndicators(t *testing.T) {
 	// Given.
 	ctx, client := setUpClientCredentialsGrant(t)
@@ -0,0 +1,150 @@
+package token
+
+import (
+	"slices"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/luikyv/go-oidc/internal/clientutil"
+	"github.com/luikyv/go-oidc/internal/oidc"
+	"github.com/luikyv/go-oidc/internal/strutil"
+	"github.com/luikyv/go-oidc/internal/timeutil"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+// generateCustomGrant issues tokens for a grant type registered as custom.
+// The handler of the grant defines the access granted, everything else, i.e.
+// client authentication, token binding and token issuance, works as it does
+// for the other grant types.
+func generateCustomGrant(
+	ctx oidc.Context,
+	req request,
+	handle goidc.HandleCustomGrantFunc,
+) (
+	response,
+	error,
+) {
+	client, err := clientutil.Authenticated(ctx, clientutil.TokenAuthnContext)
+	if err != nil {
+		return response{}, err
+	}
+	ctx.AllowCORS(client)
+
+	if err := validateCustomGrantRequest(ctx, req, client); err != nil {
+		return response{}, err
+	}
+
+	grantInfo, err := customGrantInfo(ctx, req, client, handle)
+	if err != nil {
+		return response{}, err
+	}
+
+	token, err := Make(ctx, client, grantInfo)
+	if err != nil {
+		return response{}, goidc.Errorf(goidc.ErrorCodeInternalError,
+			"could not generate an access token for the custom grant", err)
+	}
+
+	grantSession := NewGrantSession(ctx, grantInfo, token)
+	if ctx.ShouldIssueRefreshToken(client, grantInfo) {
+		grantSession.RefreshToken = refreshToken()
+		grantSession.ExpiresAtTimestamp = timeutil.TimestampNow() + ctx.RefreshTokenLifetime(client)
+	}
+
+	if err := saveGrantSession(ctx, grantSession, token); err != nil {
+		return response{}, goidc.Errorf(goidc.ErrorCodeInternalError,
+			"could not store the grant session", err)
+	}
+	ctx.NotifyTokenEvent(goidc.TokenEventIssuance, grantSession)
+
+	tokenResp := response{
+		AccessToken:          token.Value,
+		ExpiresIn:            token.LifetimeSecs,
+		TokenType:            token.Type,
+		RefreshToken:         grantSession.RefreshToken,
+		AuthorizationDetails: grantInfo.ActiveAuthDetails,
+		AdditionalParams:     ctx.TokenResponseParams(grantInfo),
+	}
+
+	if strutil.ContainsOpenID(grantInfo.ActiveScopes) {
+		idTokenOpts, err := newIDTokenOptions(ctx, client, grantInfo)
+		if err != nil {
+			return response{}, err
+		}
+
+		tokenResp.IDToken, err = makeIDToken(ctx, client, idTokenOpts)
+		if err != nil {
+			return response{}, goidc.Errorf(goidc.ErrorCodeInternalError,
+				"could not generate an id token for the custom grant", err)
+		}
+	}
+
+	if grantInfo.ActiveScopes != req.scopes {
+		tokenResp.Scopes = grantInfo.ActiveScopes
+	}
+
+	if ctx.ResourceIndicatorsIsEnabled &&
+		!cmp.Equal(grantInfo.ActiveResources, req.resources) {
+		tokenResp.Resources = grantInfo.ActiveResources
+	}
+
+	setRenewalHints(ctx, &tokenResp, grantSession)
+	return tokenResp, nil
+}
+
+func validateCustomGrantRequest(
+	ctx oidc.Context,
+	req request,
+	client *goidc.Client,
+) error {
+	if !slices.Contains(client.GrantTypes, req.grantType) {
+		return goidc.NewError(goidc.ErrorCodeUnauthorizedClient, "invalid grant type")
+	}
+
+	if !clientutil.AreScopesAllowed(client, ctx.Scopes, req.scopes) {
+		return goidc.NewError(goidc.ErrorCodeInvalidScope, "invalid scope")
+	}
+
+	if err := validateResources(ctx, ctx.Resources, req); err != nil {
+		return err
+	}
+
+	if err := validateAuthDetailsTypes(ctx, req); err != nil {
+		return err
+	}
+
+	return validateBinding(ctx, client, nil)
+}
+
+func customGrantInfo(
+	ctx oidc.Context,
+	req request,
+	client *goidc.Client,
+	handle goidc.HandleCustomGrantFunc,
+) (
+	goidc.GrantInfo,
+	error,
+) {
+	grantInfo, err := handle(ctx.Request, client)
+	if err != nil {
+		return goidc.GrantInfo{}, err
+	}
+
+	grantInfo.GrantType = req.grantType
+	grantInfo.ClientID = client.ID
+	if grantInfo.Subject == "" {
+		grantInfo.Subject = client.ID
+	}
+	// The scopes requested are granted unless the handler decided otherwise.
+	if grantInfo.ActiveScopes == "" && grantInfo.GrantedScopes == "" {
+		grantInfo.ActiveScopes = req.scopes
+		grantInfo.GrantedScopes = req.scopes
+	}
+
+	setPoP(ctx, &grantInfo)
+
+	if err := ctx.HandleGrant(&grantInfo); err != nil {
+		return goidc.GrantInfo{}, err
+	}
+
+	return grantInfo, nil
+}
@@ -0,0 +1,91 @@
+package token
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/luikyv/go-oidc/internal/oidctest"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+const customGrant goidc.GrantType = "urn:example:params:grant-type:custom"
+
+func TestHandleGrantCreation_CustomGrant(t *testing.T) {
+	// Given.
+	ctx, client := setUpClientCredentialsGrant(t)
+	client.GrantTypes = append(client.GrantTypes, customGrant)
+	ctx.CustomGrantFuncs = map[goidc.GrantType]goidc.HandleCustomGrantFunc{
+		customGrant: func(r *http.Request, c *goidc.Client) (goidc.GrantInfo, error) {
+			return goidc.GrantInfo{
+				Subject:       r.PostFormValue("user"),
+				ActiveScopes:  oidctest.Scope1.ID,
+				GrantedScopes: oidctest.Scope1.ID,
+			}, nil
+		},
+	}
+	ctx.Request.PostForm.Set("user", "random_user")
+
+	req := request{
+		grantType: customGrant,
+		scopes:    oidctest.Scope1.ID,
+	}
+
+	// When.
+	tokenResp, err := generateGrant(ctx, req)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	claims, err := oidctest.SafeClaims(tokenResp.AccessToken, ctx.PrivateJWKS.Keys[0])
+	if err != nil {
+		t.Fatalf("error parsing claims: %v", err)
+	}
+
+	if claims["sub"] != "random_user" {
+		t.Errorf("sub = %v, want random_user", claims["sub"])
+	}
+
+	if claims["client_id"] != client.ID {
+		t.Errorf("client_id = %v, want %s", claims["client_id"], client.ID)
+	}
+
+	grantSessions := oidctest.GrantSessions(t, ctx)
+	if len(grantSessions) != 1 {
+		t.Fatalf("len(grantSessions) = %d, want 1", len(grantSessions))
+	}
+
+	if grantSessions[0].GrantType != customGrant {
+		t.Errorf("GrantType = %s, want %s", grantSessions[0].GrantType, customGrant)
+	}
+}
+
+func TestHandleGrantCreation_CustomGrant_NotAllowedForClient(t *testing.T) {
+	// Given.
+	ctx, _ := setUpClientCredentialsGrant(t)
+	ctx.CustomGrantFuncs = map[goidc.GrantType]goidc.HandleCustomGrantFunc{
+		customGrant: func(*http.Request, *goidc.Client) (goidc.GrantInfo, error) {
+			t.Error("the handler should not be called")
+			return goidc.GrantInfo{}, nil
+		},
+	}
+
+	req := request{
+		grantType: customGrant,
+	}
+
+	// When.
+	_, err := generateGrant(ctx, req)
+
+	// Then.
+	var oidcErr goidc.Error
+	if !errors.As(err, &oidcErr) {
+		t.Fatalf("invalid error: %v", err)
+	}
+
+	if oidcErr.Code != goidc.ErrorCodeUnauthorizedClient {
+		t.Errorf("Code = %s, want %s", oidcErr.Code, goidc.ErrorCodeUnauthorizedClient)
+	}
+}
@@ -2,11 +2,15 @@ package token
 
 import (
 	"errors"
+	"fmt"
+	"slices"
 
+	"github.com/go-jose/go-jose/v4/jwt"
 	"github.com/google/uuid"
 	"github.com/luikyv/go-oidc/internal/clientutil"
 	"github.com/luikyv/go-oidc/internal/jwtutil"
 	"github.com/luikyv/go-oidc/internal/oidc"
+	"github.com/luikyv/go-oidc/internal/strutil"
 	"github.com/luikyv/go-oidc/pkg/goidc"
 )
 
@@ -17,40 +21,105 @@ func introspect(
 	goidc.TokenInfo,
 	error,
 ) {
-	c, err := clientutil.Authenticated(ctx, clientutil.TokenIntrospectionAuthnContext)
+	caller, err := authenticateIntrospectionCaller(ctx)
 	if err != nil {
 		return goidc.TokenInfo{}, err
 	}
 
-	if err := validateIntrospectionRequest(ctx, req, c); err != nil {
-		return goidc.TokenInfo{}, err
+	if req.token == "" {
+		return goidc.TokenInfo{}, goidc.NewError(goidc.ErrorCodeInvalidRequest,
+			"token is required")
 	}
 
 	// The information of an invalid token must not be sent as an error.
 	// It will be returned as the default value of [goidc.TokenInfo] with the
 	// field is_active as false.
-	tokenInfo, _ := IntrospectionInfo(ctx, req.token)
+	tokenInfo, err := IntrospectionInfo(ctx, req.token)
+	if err != nil {
+		if ctx.IsIntrospectionCallerTrusted(caller) && tokenInfo.Reason != "" {
+			tokenInfo.AdditionalTokenClaims = map[string]any{
+				goidc.ClaimInactiveReason: tokenInfo.Reason,
+			}
+		}
+		return tokenInfo, nil
+	}
+
+	ctx.FilterIntrospection(caller, &tokenInfo)
 	return tokenInfo, nil
 }
 
-func validateIntrospectionRequest(
+// authenticateIntrospectionCaller makes sure the entity calling the
+// introspection endpoint is allowed to do so.
+// If a bearer scope is configured and the request carries an access token, the
+// caller is authenticated with it. Otherwise, client authentication is required.
+func authenticateIntrospectionCaller(
 	ctx oidc.Context,
-	req queryRequest,
-	c *goidc.Client,
-) error {
+) (
+	goidc.IntrospectionCaller,
+	error,
+) {
+	if ctx.TokenIntrospectionBearerScope != "" {
+		if token, tokenType, ok := ctx.AuthorizationToken(); ok &&
+			(tokenType == goidc.TokenTypeBearer || tokenType == goidc.TokenTypeDPoP) {
+			info, err := validateIntrospectionBearerToken(ctx, token)
+			if err != nil {
+				return goidc.IntrospectionCaller{}, err
+			}
+			return goidc.IntrospectionCaller{Token: &info}, nil
+		}
+	}
+
+	c, err := clientutil.Authenticated(ctx, clientutil.TokenIntrospectionAuthnContext)
+	if err != nil {
+		return goidc.IntrospectionCaller{}, err
+	}
 
 	if !ctx.IsClientAllowedTokenIntrospection(c) {
-		return goidc.NewError(goidc.ErrorCodeAccessDenied,
+		return goidc.IntrospectionCaller{}, goidc.NewError(goidc.ErrorCodeAccessDenied,
 			"client not allowed to introspect tokens")
 	}
 
-	if req.token == "" {
-		return goidc.NewError(goidc.ErrorCodeInvalidRequest, "token is required")
+	return goidc.IntrospectionCaller{Client: c}, nil
+}
+
+// validateIntrospectionBearerToken validates that the access token presented by
+// a resource server is active and was granted the introspection scope.
+func validateIntrospectionBearerToken(
+	ctx oidc.Context,
+	token string,
+) (
+	goidc.TokenInfo,
+	error,
+) {
+	info, err := IntrospectionInfo(ctx, token)
+	if err != nil {
+		return goidc.TokenInfo{}, goidc.Errorf(goidc.ErrorCodeInvalidToken,
+			"invalid token", err)
+	}
+
+	if info.Type != goidc.TokenHintAccess {
+		return goidc.TokenInfo{}, goidc.NewError(goidc.ErrorCodeInvalidToken,
+			"invalid token")
+	}
+
+	if info.Confirmation != nil {
+		if err := ValidatePoP(ctx, token, *info.Confirmation); err != nil {
+			return goidc.TokenInfo{}, err
+		}
+	}
+
+	if !slices.Contains(strutil.SplitWithSpaces(info.Scopes),
+		ctx.TokenIntrospectionBearerScope) {
+		return goidc.TokenInfo{}, goidc.NewError(goidc.ErrorCodeAccessDenied,
+			"token not allowed to introspect tokens")
 	}
 
-	return nil
+	return info, nil
 }
 
+// IntrospectionInfo returns the information about a token.
+// If the token is not active, an error is returned along with a
+// [goidc.TokenInfo] informing the reason.
 func IntrospectionInfo(
 	ctx oidc.Context,
 	accessToken string,
@@ -58,6 +127,44 @@ func IntrospectionInfo(
 	goidc.TokenInfo,
 	error,
 ) {
+	info, err := tokenInfo(ctx, accessToken)
+	if err != nil {
+		return info, err
+	}
+
+	// Tokens stop being active while the client they were issued to is
+	// disabled. Note this loads the client on every introspection, so client
+	// managers backed by remote storage should cache clients.
+	_, err = ctx.Client(info.ClientID)
+	if errors.Is(err, goidc.ErrClientDisabled) {
+		return inactiveTokenInfo(goidc.TokenInactiveReasonClientDisabled), err
+	}
+	if err != nil {
+		return inactiveTokenInfo(goidc.TokenInactiveReasonUnknown),
+			fmt.Errorf("could not load the client of the token: %w", err)
+	}
+
+	return info, nil
+}
+
+func tokenInfo(
+	ctx oidc.Context,
+	accessToken string,
+) (
+	goidc.TokenInfo,
+	error,
+) {
+	// Stateless tokens are identified by whether they can be decrypted, which
+	// is only possible for tokens issued by this server.
+	if ctx.TokenEncryptionKey != nil {
+		if claims, err := parseStatelessToken(ctx, accessToken); err == nil {
+			return statelessTokenInfo(claims)
+		}
+	}
+
+	if tokenID, ok := ctx.CustomTokenID(accessToken); ok {
+		return customTokenInfo(ctx, tokenID)
+	}
 
 	if len(accessToken) == goidc.RefreshTokenLength {
 		return refreshTokenInfo(ctx, accessToken)
@@ -79,12 +186,18 @@ func refreshTokenInfo(
 ) {
 	grantSession, err := ctx.GrantSessionByRefreshToken(token)
 	if err != nil {
-		return goidc.TokenInfo{},
+		return inactiveTokenInfo(goidc.TokenInactiveReasonUnknown),
 			errors.New("token not found")
 	}
 
 	if grantSession.IsExpired() {
-		return goidc.TokenInfo{}, errors.New("token is expired")
+		return inactiveTokenInfo(goidc.TokenInactiveReasonExpired),
+			errors.New("token is expired")
+	}
+
+	sub, err := issuedSubject(ctx, grantSession)
+	if err != nil {
+		return inactiveTokenInfo(goidc.TokenInactiveReasonUnknown), err
 	}
 
 	var cnf *goidc.TokenConfirmation
@@ -103,7 +216,7 @@ func refreshTokenInfo(
 		Scopes:                grantSession.GrantedScopes,
 		AuthorizationDetails:  grantSession.GrantedAuthDetails,
 		ClientID:              grantSession.ClientID,
-		Subject:               grantSession.Subject,
+		Subject:               sub,
 		ExpiresAtTimestamp:    grantSession.ExpiresAtTimestamp,
 		Confirmation:          cnf,
 		ResourceAudiences:     grantSession.GrantedResources,
@@ -119,11 +232,40 @@ func jwtTokenInfo(
 	error,
 ) {
 	claims, err := validClaims(ctx, accessToken)
-	if err != nil || claims[goidc.ClaimTokenID] == nil {
-		return goidc.TokenInfo{}, errors.New("invalid token")
+	if errors.Is(err, jwt.ErrExpired) {
+		return inactiveTokenInfo(goidc.TokenInactiveReasonExpired),
+			errors.New("token is expired")
+	}
+
+	tokenID, ok := claims[goidc.ClaimTokenID].(string)
+	if err != nil || !ok {
+		return inactiveTokenInfo(goidc.TokenInactiveReasonUnknown),
+			errors.New("invalid token")
 	}
 
-	return tokenIntrospectionInfoByID(ctx, claims[goidc.ClaimTokenID].(string))
+	info, err := tokenIntrospectionInfoByID(ctx, tokenID)
+	// The token has a valid signature and is not expired, so if its grant
+	// cannot be found anymore, it was revoked.
+	if info.Reason == goidc.TokenInactiveReasonUnknown {
+		info.Reason = goidc.TokenInactiveReasonRevoked
+	}
+	return info, err
+}
+
+func customTokenInfo(
+	ctx oidc.Context,
+	tokenID string,
+) (
+	goidc.TokenInfo,
+	error,
+) {
+	info, err := tokenIntrospectionInfoByID(ctx, tokenID)
+	// The token was verified by its issuer, so if its grant cannot be found
+	// anymore, it was revoked.
+	if info.Reason == goidc.TokenInactiveReasonUnknown {
+		info.Reason = goidc.TokenInactiveReasonRevoked
+	}
+	return info, err
 }
 
 func opaqueTokenInfo(
@@ -137,7 +279,8 @@ func opaqueTokenInfo(
 	// If the provided token is mistakenly in a valid UUID format, the function
 	// returns an error to indicate an invalid token.
 	if uuid.Validate(token) == nil {
-		return goidc.TokenInfo{}, errors.New("invalid token")
+		return inactiveTokenInfo(goidc.TokenInactiveReasonUnknown),
+			errors.New("invalid token")
 	}
 	return tokenIntrospectionInfoByID(ctx, token)
 }
@@ -149,13 +292,20 @@ func tokenIntrospectionInfoByID(
 	goidc.TokenInfo,
 	error,
 ) {
-	grantSession, err := ctx.GrantSessionByTokenID(tokenID)
+	grantSession, err := ctx.IntrospectionGrantSession(tokenID)
 	if err != nil {
-		return goidc.TokenInfo{}, errors.New("token not found")
+		return inactiveTokenInfo(goidc.TokenInactiveReasonUnknown),
+			errors.New("token not found")
 	}
 
 	if grantSession.HasLastTokenExpired() {
-		return goidc.TokenInfo{}, errors.New("token is expired")
+		return inactiveTokenInfo(goidc.TokenInactiveReasonExpired),
+			errors.New("token is expired")
+	}
+
+	sub, err := issuedSubject(ctx, grantSession)
+	if err != nil {
+		return inactiveTokenInfo(goidc.TokenInactiveReasonUnknown), err
 	}
 
 	var cnf *goidc.TokenConfirmation
@@ -173,10 +323,31 @@ func tokenIntrospectionInfoByID(
 		Scopes:                grantSession.ActiveScopes,
 		AuthorizationDetails:  grantSession.GrantedAuthDetails,
 		ClientID:              grantSession.ClientID,
-		Subject:               grantSession.Subject,
+		Subject:               sub,
 		ExpiresAtTimestamp:    grantSession.LastTokenExpiresAtTimestamp,
 		Confirmation:          cnf,
 		ResourceAudiences:     grantSession.ActiveResources,
 		AdditionalTokenClaims: grantSession.AdditionalTokenClaims,
 	}, nil
 }
+
+// issuedSubject returns the subject identifier issued to the client of the
+// grant session.
+func issuedSubject(ctx oidc.Context, grantSession *goidc.GrantSession) (string, error) {
+	if ctx.SubjectIdentifierFunc == nil {
+		return grantSession.Subject, nil
+	}
+
+	c, err := ctx.Client(grantSession.ClientID)
+	if err != nil {
+		return "", fmt.Errorf("could not load the client of the grant session: %w", err)
+	}
+	return ctx.SubjectIdentifier(c, grantSession.Subject), nil
+}
+
+func inactiveTokenInfo(reason goidc.TokenInactiveReason) goidc.TokenInfo {
+	return goidc.TokenInfo{
+		IsActive: false,
+		Reason:   reason,
+	}
+}
@@ -1,11 +1,17 @@
 package token
 
 import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/luikyv/go-oidc/internal/oidc"
 	"github.com/luikyv/go-oidc/internal/oidctest"
+	"github.com/luikyv/go-oidc/internal/storage"
 	"github.com/luikyv/go-oidc/internal/strutil"
 	"github.com/luikyv/go-oidc/internal/timeutil"
 	"github.com/luikyv/go-oidc/pkg/goidc"
@@ -100,6 +106,478 @@ func TestIntrospect_RefreshToken(t *testing.T) {
 	}
 }
 
+func TestIntrospect_SubjectIdentifierFunc(t *testing.T) {
+	// Given.
+	ctx, client := setUpIntrospection(t)
+	ctx.SubjectIdentifierFunc = func(c *goidc.Client, userID string) string {
+		return c.ID + ":" + userID
+	}
+
+	accessToken := "opaque_token"
+	grantSession := &goidc.GrantSession{
+		TokenID:                     accessToken,
+		LastTokenExpiresAtTimestamp: timeutil.TimestampNow() + 60,
+		GrantInfo: goidc.GrantInfo{
+			Subject:  "random_subject",
+			ClientID: client.ID,
+		},
+	}
+	_ = ctx.SaveGrantSession(grantSession)
+
+	// When.
+	tokenInfo, err := introspect(ctx, queryRequest{token: accessToken})
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if want := client.ID + ":random_subject"; tokenInfo.Subject != want {
+		t.Errorf("Subject = %s, want %s", tokenInfo.Subject, want)
+	}
+}
+
+func TestIntrospect_BearerToken(t *testing.T) {
+	// Given.
+	ctx, client := setUpIntrospection(t)
+	ctx.TokenIntrospectionBearerScope = "introspect"
+	ctx.Request.PostForm = nil
+
+	_ = ctx.SaveClient(&goidc.Client{ID: "resource_server"})
+
+	rsToken := "rs_token"
+	_ = ctx.SaveGrantSession(&goidc.GrantSession{
+		ID:                          "rs_grant",
+		TokenID:                     rsToken,
+		LastTokenExpiresAtTimestamp: timeutil.TimestampNow() + 60,
+		GrantInfo: goidc.GrantInfo{
+			ActiveScopes: "introspect",
+			ClientID:     "resource_server",
+		},
+	})
+	ctx.Request.Header.Set("Authorization", "Bearer "+rsToken)
+
+	accessToken := "opaque_token"
+	_ = ctx.SaveGrantSession(&goidc.GrantSession{
+		TokenID:                     accessToken,
+		LastTokenExpiresAtTimestamp: timeutil.TimestampNow() + 60,
+		GrantInfo: goidc.GrantInfo{
+			ActiveScopes: goidc.ScopeOpenID.ID,
+			ClientID:     client.ID,
+		},
+	})
+
+	// When.
+	tokenInfo, err := introspect(ctx, queryRequest{token: accessToken})
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !tokenInfo.IsActive {
+		t.Error("the token should be active")
+	}
+
+	if tokenInfo.ClientID != client.ID {
+		t.Errorf("ClientID = %s, want %s", tokenInfo.ClientID, client.ID)
+	}
+}
+
+func TestIntrospect_BearerTokenWithoutScope(t *testing.T) {
+	// Given.
+	ctx, _ := setUpIntrospection(t)
+	ctx.TokenIntrospectionBearerScope = "introspect"
+	ctx.Request.PostForm = nil
+
+	_ = ctx.SaveClient(&goidc.Client{ID: "resource_server"})
+
+	rsToken := "rs_token"
+	_ = ctx.SaveGrantSession(&goidc.GrantSession{
+		TokenID:                     rsToken,
+		LastTokenExpiresAtTimestamp: timeutil.TimestampNow() + 60,
+		GrantInfo: goidc.GrantInfo{
+			ActiveScopes: goidc.ScopeOpenID.ID,
+			ClientID:     "resource_server",
+		},
+	})
+	ctx.Request.Header.Set("Authorization", "Bearer "+rsToken)
+
+	// When.
+	_, err := introspect(ctx, queryRequest{token: "opaque_token"})
+
+	// Then.
+	var oidcErr goidc.Error
+	if !errors.As(err, &oidcErr) {
+		t.Fatalf("invalid error type: %v", err)
+	}
+
+	if oidcErr.Code != goidc.ErrorCodeAccessDenied {
+		t.Errorf("error code = %s, want %s", oidcErr.Code, goidc.ErrorCodeAccessDenied)
+	}
+}
+
+func TestIntrospect_InvalidBearerToken(t *testing.T) {
+	// Given.
+	ctx, _ := setUpIntrospection(t)
+	ctx.TokenIntrospectionBearerScope = "introspect"
+	ctx.Request.PostForm = nil
+	ctx.Request.Header.Set("Authorization", "Bearer invalid_token")
+
+	// When.
+	_, err := introspect(ctx, queryRequest{token: "opaque_token"})
+
+	// Then.
+	var oidcErr goidc.Error
+	if !errors.As(err, &oidcErr) {
+		t.Fatalf("invalid error type: %v", err)
+	}
+
+	if oidcErr.Code != goidc.ErrorCodeInvalidToken {
+		t.Errorf("error code = %s, want %s", oidcErr.Code, goidc.ErrorCodeInvalidToken)
+	}
+}
+
+func TestIntrospect_FilterIntrospection(t *testing.T) {
+	// Given.
+	ctx, client := setUpIntrospection(t)
+	ctx.FilterIntrospectionFunc = func(
+		_ *http.Request,
+		caller goidc.IntrospectionCaller,
+		info *goidc.TokenInfo,
+	) {
+		if caller.Client == nil || caller.Client.ID != client.ID {
+			t.Errorf("unexpected caller: %v", caller)
+		}
+		info.Scopes = "scope1"
+	}
+
+	accessToken := "opaque_token"
+	_ = ctx.SaveGrantSession(&goidc.GrantSession{
+		TokenID:                     accessToken,
+		LastTokenExpiresAtTimestamp: timeutil.TimestampNow() + 60,
+		GrantInfo: goidc.GrantInfo{
+			ActiveScopes: "scope1 scope2",
+			ClientID:     client.ID,
+		},
+	})
+
+	// When.
+	tokenInfo, err := introspect(ctx, queryRequest{token: accessToken})
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if tokenInfo.Scopes != "scope1" {
+		t.Errorf("Scopes = %s, want scope1", tokenInfo.Scopes)
+	}
+}
+
+func TestIntrospect_InactiveReason(t *testing.T) {
+	// Given.
+	ctx, client := setUpIntrospection(t)
+	ctx.IsIntrospectionCallerTrustedFunc = func(
+		_ *http.Request,
+		caller goidc.IntrospectionCaller,
+	) bool {
+		return caller.Client != nil && caller.Client.ID == client.ID
+	}
+
+	accessToken := "opaque_token"
+	_ = ctx.SaveGrantSession(&goidc.GrantSession{
+		TokenID:                     accessToken,
+		LastTokenExpiresAtTimestamp: timeutil.TimestampNow() - 10,
+		GrantInfo: goidc.GrantInfo{
+			ClientID: client.ID,
+		},
+	})
+
+	// When.
+	tokenInfo, err := introspect(ctx, queryRequest{token: accessToken})
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := goidc.TokenInfo{
+		IsActive: false,
+		Reason:   goidc.TokenInactiveReasonExpired,
+		AdditionalTokenClaims: map[string]any{
+			goidc.ClaimInactiveReason: goidc.TokenInactiveReasonExpired,
+		},
+	}
+	if diff := cmp.Diff(tokenInfo, want); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestIntrospect_InactiveReasonNotExposed(t *testing.T) {
+	// Given.
+	ctx, _ := setUpIntrospection(t)
+
+	// When.
+	tokenInfo, err := introspect(ctx, queryRequest{token: "unknown_token"})
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if tokenInfo.AdditionalTokenClaims != nil {
+		t.Errorf("AdditionalTokenClaims = %v, want nil", tokenInfo.AdditionalTokenClaims)
+	}
+
+	if tokenInfo.Reason != goidc.TokenInactiveReasonUnknown {
+		t.Errorf("Reason = %s, want %s", tokenInfo.Reason, goidc.TokenInactiveReasonUnknown)
+	}
+}
+
+func TestIntrospectionInfo_RevokedJWT(t *testing.T) {
+	// Given.
+	ctx := oidctest.NewContext(t)
+	client, _ := oidctest.NewClient(t)
+	token, err := Make(ctx, client, goidc.GrantInfo{
+		Subject:  "random_subject",
+		ClientID: client.ID,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// When.
+	tokenInfo, err := IntrospectionInfo(ctx, token.Value)
+
+	// Then.
+	if err == nil {
+		t.Fatal("a token without a grant session should be inactive")
+	}
+
+	if tokenInfo.Reason != goidc.TokenInactiveReasonRevoked {
+		t.Errorf("Reason = %s, want %s", tokenInfo.Reason, goidc.TokenInactiveReasonRevoked)
+	}
+}
+
+func TestIntrospectionInfo_ClientDisabled(t *testing.T) {
+	// Given.
+	ctx := oidctest.NewContext(t)
+	client, _ := oidctest.NewClient(t)
+	client.Status = goidc.ClientStatusDisabled
+	_ = ctx.SaveClient(client)
+
+	accessToken := "opaque_token"
+	_ = ctx.SaveGrantSession(&goidc.GrantSession{
+		TokenID:                     accessToken,
+		LastTokenExpiresAtTimestamp: timeutil.TimestampNow() + 60,
+		GrantInfo: goidc.GrantInfo{
+			ClientID: client.ID,
+		},
+	})
+
+	// When.
+	tokenInfo, err := IntrospectionInfo(ctx, accessToken)
+
+	// Then.
+	if err == nil {
+		t.Fatal("the token of a disabled client should be inactive")
+	}
+
+	if tokenInfo.IsActive {
+		t.Error("the token should be inactive")
+	}
+
+	if tokenInfo.Reason != goidc.TokenInactiveReasonClientDisabled {
+		t.Errorf("Reason = %s, want %s", tokenInfo.Reason, goidc.TokenInactiveReasonClientDisabled)
+	}
+}
+
+func TestIntrospectionInfo_ClientNotFound(t *testing.T) {
+	// Given.
+	ctx := oidctest.NewContext(t)
+
+	accessToken := "opaque_token"
+	_ = ctx.SaveGrantSession(&goidc.GrantSession{
+		TokenID:                     accessToken,
+		LastTokenExpiresAtTimestamp: timeutil.TimestampNow() + 60,
+		GrantInfo: goidc.GrantInfo{
+			ClientID: "deleted_client_id",
+		},
+	})
+
+	// When.
+	tokenInfo, err := IntrospectionInfo(ctx, accessToken)
+
+	// Then.
+	if err == nil {
+		t.Fatal("the token of a client that cannot be loaded should be inactive")
+	}
+
+	if tokenInfo.IsActive {
+		t.Error("the token should be inactive")
+	}
+}
+
+func TestIntrospectionInfo_Cached(t *testing.T) {
+	// Given.
+	ctx, client := setUpIntrospection(t)
+	ctx.IntrospectionCache = storage.NewIntrospectionCache(time.Minute)
+
+	accessToken := "opaque_token"
+	grantSession := &goidc.GrantSession{
+		ID:                          "random_grant_id",
+		TokenID:                     accessToken,
+		LastTokenExpiresAtTimestamp: timeutil.TimestampNow() + 60,
+		GrantInfo: goidc.GrantInfo{
+			ClientID: client.ID,
+		},
+	}
+	_ = ctx.SaveGrantSession(grantSession)
+	if _, err := IntrospectionInfo(ctx, accessToken); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Remove the session bypassing the provider, so only the cache has it.
+	_ = ctx.GrantSessionManager.Delete(context.Background(), grantSession.ID)
+
+	// When.
+	tokenInfo, err := IntrospectionInfo(ctx, accessToken)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("the session must be served from the cache: %v", err)
+	}
+
+	if !tokenInfo.IsActive {
+		t.Error("the token must be active")
+	}
+}
+
+func TestIntrospectionInfo_CacheEvictedOnRevocation(t *testing.T) {
+	// Given.
+	ctx, client := setUpIntrospection(t)
+	ctx.IntrospectionCache = storage.NewIntrospectionCache(time.Minute)
+
+	accessToken := "opaque_token"
+	grantSession := &goidc.GrantSession{
+		ID:                          "random_grant_id",
+		TokenID:                     accessToken,
+		LastTokenExpiresAtTimestamp: timeutil.TimestampNow() + 60,
+		GrantInfo: goidc.GrantInfo{
+			ClientID: client.ID,
+		},
+	}
+	_ = ctx.SaveGrantSession(grantSession)
+	if _, err := IntrospectionInfo(ctx, accessToken); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_ = ctx.DeleteGrantSession(grantSession.ID)
+
+	// When.
+	tokenInfo, err := IntrospectionInfo(ctx, accessToken)
+
+	// Then.
+	if err == nil {
+		t.Fatal("a revoked token must be inactive")
+	}
+
+	if tokenInfo.IsActive {
+		t.Error("the token must not be active")
+	}
+}
+
+func TestIntrospect_CustomToken(t *testing.T) {
+	// Given.
+	ctx, client := setUpIntrospection(t)
+	format := goidc.TokenFormat("prefixed")
+	ctx.TokenIssuers = map[goidc.TokenFormat]goidc.TokenIssuer{
+		format: prefixedTokenIssuer{},
+	}
+	ctx.TokenOptionsFunc = func(goidc.GrantInfo) goidc.TokenOptions {
+		return goidc.TokenOptions{Format: format, LifetimeSecs: 60}
+	}
+
+	grantInfo := goidc.GrantInfo{
+		Subject:       "random_subject",
+		ClientID:      client.ID,
+		ActiveScopes:  goidc.ScopeOpenID.ID,
+		JWKThumbprint: "random_thumbprint",
+	}
+	token, err := Make(ctx, client, grantInfo)
+	if err != nil {
+		t.Fatalf("unexpected error making the token: %v", err)
+	}
+	_ = ctx.SaveGrantSession(NewGrantSession(ctx, grantInfo, token))
+
+	// When.
+	tokenInfo, err := introspect(ctx, queryRequest{token: token.Value})
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if token.Format != format || token.Type != goidc.TokenTypeDPoP {
+		t.Errorf("Format, Type = %s, %s, want %s, %s", token.Format, token.Type, format, goidc.TokenTypeDPoP)
+	}
+
+	want := goidc.TokenInfo{
+		GrantID:            tokenInfo.GrantID,
+		IsActive:           true,
+		Type:               goidc.TokenHintAccess,
+		ClientID:           client.ID,
+		Subject:            "random_subject",
+		Scopes:             goidc.ScopeOpenID.ID,
+		ExpiresAtTimestamp: tokenInfo.ExpiresAtTimestamp,
+		Confirmation:       &goidc.TokenConfirmation{JWKThumbprint: "random_thumbprint"},
+	}
+	if diff := cmp.Diff(tokenInfo, want); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestIntrospectionInfo_RevokedCustomToken(t *testing.T) {
+	// Given.
+	ctx := oidctest.NewContext(t)
+	ctx.TokenIssuers = map[goidc.TokenFormat]goidc.TokenIssuer{
+		"prefixed": prefixedTokenIssuer{},
+	}
+
+	// When.
+	tokenInfo, err := IntrospectionInfo(ctx, "prefixed.random_id")
+
+	// Then.
+	if err == nil {
+		t.Fatal("a token without a grant session should be inactive")
+	}
+
+	if tokenInfo.Reason != goidc.TokenInactiveReasonRevoked {
+		t.Errorf("Reason = %s, want %s", tokenInfo.Reason, goidc.TokenInactiveReasonRevoked)
+	}
+}
+
+// prefixedTokenIssuer issues tokens made of a fixed prefix followed by their
+// identifier.
+type prefixedTokenIssuer struct{}
+
+func (prefixedTokenIssuer) Issue(
+	_ context.Context,
+	_ *goidc.Client,
+	_ goidc.GrantInfo,
+	_ goidc.TokenOptions,
+) (
+	string,
+	string,
+	error,
+) {
+	id := strutil.Random(10)
+	return "prefixed." + id, id, nil
+}
+
+func (prefixedTokenIssuer) ID(_ context.Context, token string) (string, bool) {
+	return strings.CutPrefix(token, "prefixed.")
+}
+
 func setUpIntrospection(t *testing.T) (ctx oidc.Context, client *goidc.Client) {
 	t.Helper()
 
@@ -41,6 +41,7 @@ func generateJWTBearerGrant(
 	if client == nil {
 		client = makeAnonymousClient(ctx)
 	}
+	ctx.AllowCORS(client)
 
 	if err := validateJWTBearerGrantRequest(ctx, req, client); err != nil {
 		return response{}, err
@@ -57,7 +58,7 @@ func generateJWTBearerGrant(
 		return response{}, err
 	}
 
-	token, err := Make(ctx, grantInfo)
+	token, err := Make(ctx, client, grantInfo)
 	if err != nil {
 		return response{}, goidc.Errorf(goidc.ErrorCodeInternalError,
 			"could not generate an access token for the jwt bearer grant", err)
@@ -79,10 +80,16 @@ func generateJWTBearerGrant(
 		TokenType:            token.Type,
 		RefreshToken:         grantSession.RefreshToken,
 		AuthorizationDetails: grantInfo.ActiveAuthDetails,
+		AdditionalParams:     ctx.TokenResponseParams(grantInfo),
 	}
 
 	if strutil.ContainsOpenID(grantInfo.ActiveScopes) {
-		tokenResp.IDToken, err = makeIDToken(ctx, client, newIDTokenOptions(grantInfo))
+		idTokenOpts, err := newIDTokenOptions(ctx, client, grantInfo)
+		if err != nil {
+			return response{}, err
+		}
+
+		tokenResp.IDToken, err = makeIDToken(ctx, client, idTokenOpts)
 		if err != nil {
 			return response{}, goidc.Errorf(goidc.ErrorCodeInternalError,
 				"could not generate access id token for the authorization code grant", err)
@@ -98,6 +105,7 @@ func generateJWTBearerGrant(
 		tokenResp.Resources = grantInfo.ActiveResources
 	}
 
+	setRenewalHints(ctx, &tokenResp, grantSession)
 	return tokenResp, nil
 }
 
@@ -181,17 +189,18 @@ func generateJWTBearerGrantSession(
 	error,
 ) {
 
-	grantSession := NewGrantSession(grantInfo, token)
+	grantSession := NewGrantSession(ctx, grantInfo, token)
 	if ctx.ShouldIssueRefreshToken(client, grantInfo) {
 		grantSession.RefreshToken = refreshToken()
-		grantSession.ExpiresAtTimestamp = timeutil.TimestampNow() + ctx.RefreshTokenLifetimeSecs
+		grantSession.ExpiresAtTimestamp = timeutil.TimestampNow() + ctx.RefreshTokenLifetime(client)
 	}
 
-	if err := ctx.SaveGrantSession(grantSession); err != nil {
+	if err := saveGrantSession(ctx, grantSession, token); err != nil {
 		return nil, goidc.Errorf(goidc.ErrorCodeInternalError,
 			"internal error", err)
 	}
 
+	ctx.NotifyTokenEvent(goidc.TokenEventIssuance, grantSession)
 	return grantSession, nil
 }
 
@@ -2,10 +2,8 @@ package token
 
 import (
 	"crypto/sha256"
-	"crypto/sha512"
 	"encoding/base64"
 	"fmt"
-	"hash"
 
 	"github.com/go-jose/go-jose/v4"
 	"github.com/google/uuid"
@@ -22,9 +20,13 @@ func MakeIDToken(
 	client *goidc.Client,
 	idTokenOpts IDTokenOptions,
 ) (
-	string,
-	error,
+	_ string,
+	err error,
 ) {
+	ctx, span := ctx.StartSpan("token.MakeIDToken",
+		goidc.Attribute{Key: goidc.AttributeClientID, Value: client.ID})
+	defer func() { oidc.EndSpan(span, err) }()
+
 	idToken, err := makeIDToken(ctx, client, idTokenOpts)
 	if err != nil {
 		return "", err
@@ -40,17 +42,50 @@ func MakeIDToken(
 
 func Make(
 	ctx oidc.Context,
+	client *goidc.Client,
 	grantInfo goidc.GrantInfo,
 ) (
 	Token,
 	error,
 ) {
-	opts := ctx.TokenOptions(grantInfo)
+	return makeForGrant(ctx, client, "", grantInfo)
+}
+
+// makeForGrant generates an access token for the grant session identified
+// by grantID.
+// If grantID is empty, the token is assumed to belong to a new grant session.
+func makeForGrant(
+	ctx oidc.Context,
+	client *goidc.Client,
+	grantID string,
+	grantInfo goidc.GrantInfo,
+) (
+	_ Token,
+	err error,
+) {
+	opts := ctx.TokenOptions(grantInfo, client)
+	ctx, span := ctx.StartSpan("token.Make",
+		goidc.Attribute{Key: goidc.AttributeClientID, Value: client.ID},
+		goidc.Attribute{Key: goidc.AttributeTokenFormat, Value: string(opts.Format)})
+	defer func() { oidc.EndSpan(span, err) }()
+
+	// Self-contained tokens carry the subject identifier issued to the client.
+	// Opaque tokens resolve it when introspected.
+	grantInfo.Subject = ctx.SubjectIdentifier(client, grantInfo.Subject)
+
 	if opts.Format == goidc.TokenFormatJWT {
 		return makeJWTToken(ctx, grantInfo, opts)
-	} else {
-		return makeOpaqueToken(ctx, grantInfo, opts)
 	}
+
+	if issuer, ok := ctx.TokenIssuer(opts.Format); ok {
+		return makeCustomToken(ctx, issuer, client, grantInfo, opts)
+	}
+
+	if opts.OpaqueIsStateless {
+		return makeStatelessOpaqueToken(ctx, grantID, grantInfo, opts)
+	}
+
+	return makeOpaqueToken(ctx, grantInfo, opts)
 }
 
 func makeIDToken(
@@ -104,7 +139,7 @@ func idTokenClaims(
 
 	claims := map[string]any{
 		goidc.ClaimIssuer:   ctx.Host,
-		goidc.ClaimSubject:  opts.Subject,
+		goidc.ClaimSubject:  ctx.SubjectIdentifier(client, opts.Subject),
 		goidc.ClaimIssuedAt: now,
 		goidc.ClaimExpiry:   now + ctx.IDTokenLifetimeSecs,
 	}
@@ -115,24 +150,32 @@ func idTokenClaims(
 	}
 
 	if opts.AccessToken != "" {
-		claims[goidc.ClaimAccessTokenHash] = halfHashIDTokenClaim(
+		claims[goidc.ClaimAccessTokenHash] = goidc.HalfHashClaim(
 			opts.AccessToken,
 			sigAlg,
 		)
 	}
 
 	if opts.AuthorizationCode != "" {
-		claims[goidc.ClaimAuthzCodeHash] = halfHashIDTokenClaim(
+		claims[goidc.ClaimAuthzCodeHash] = goidc.HalfHashClaim(
 			opts.AuthorizationCode,
 			sigAlg,
 		)
 	}
 
 	if opts.State != "" {
-		claims[goidc.ClaimStateHash] = halfHashIDTokenClaim(opts.State, sigAlg)
+		claims[goidc.ClaimStateHash] = goidc.HalfHashClaim(opts.State, sigAlg)
+	}
+
+	if len(opts.AuthDetails) != 0 {
+		claims[goidc.ClaimAuthDetails] = opts.AuthDetails
+	}
+
+	if opts.SessionID != "" {
+		claims[goidc.ClaimSessionID] = opts.SessionID
 	}
 
-	for k, v := range opts.AdditionalIDTokenClaims {
+	for k, v := range ctx.FilterClaims(client, opts.AdditionalIDTokenClaims) {
 		claims[k] = v
 	}
 
@@ -147,10 +190,9 @@ func encryptIDToken(
 	string,
 	error,
 ) {
-	jwk, err := clientutil.JWKByAlg(ctx, c, string(c.IDTokenKeyEncAlg))
+	jwk, err := clientutil.EncJWK(ctx, c, c.IDTokenKeyEncAlg)
 	if err != nil {
-		return "", goidc.Errorf(goidc.ErrorCodeInvalidRequest,
-			"could not encrypt the id token", err)
+		return "", err
 	}
 
 	contentEncAlg := c.IDTokenContentEncAlg
@@ -236,6 +278,43 @@ func makeJWTToken(
 	}, nil
 }
 
+// makeCustomToken generates an access token with the issuer registered for
+// the format of the token options.
+func makeCustomToken(
+	ctx oidc.Context,
+	issuer goidc.TokenIssuer,
+	client *goidc.Client,
+	grantInfo goidc.GrantInfo,
+	opts goidc.TokenOptions,
+) (
+	Token,
+	error,
+) {
+	accessToken, id, err := issuer.Issue(ctx, client, grantInfo, opts)
+	if err != nil {
+		return Token{}, goidc.Errorf(goidc.ErrorCodeInternalError,
+			"could not issue the access token", err)
+	}
+
+	if id == "" {
+		return Token{}, goidc.NewError(goidc.ErrorCodeInternalError,
+			"the access token issued has no identifier")
+	}
+
+	tokenType := goidc.TokenTypeBearer
+	if grantInfo.JWKThumbprint != "" {
+		tokenType = goidc.TokenTypeDPoP
+	}
+
+	return Token{
+		ID:           id,
+		Format:       opts.Format,
+		Value:        accessToken,
+		Type:         tokenType,
+		LifetimeSecs: opts.LifetimeSecs,
+	}, nil
+}
+
 func makeOpaqueToken(
 	_ oidc.Context,
 	grantInfo goidc.GrantInfo,
@@ -259,22 +338,6 @@ func makeOpaqueToken(
 	}, nil
 }
 
-func halfHashIDTokenClaim(claim string, alg jose.SignatureAlgorithm) string {
-	var hash hash.Hash
-	switch alg {
-	case jose.RS384, jose.ES384, jose.PS384, jose.HS384:
-		hash = sha512.New384()
-	case jose.RS512, jose.ES512, jose.PS512, jose.HS512:
-		hash = sha512.New()
-	default:
-		hash = sha256.New()
-	}
-
-	hash.Write([]byte(claim))
-	halfHashedClaim := hash.Sum(nil)[:hash.Size()/2]
-	return base64.RawURLEncoding.EncodeToString(halfHashedClaim)
-}
-
 func hashBase64URLSHA256(s string) string {
 	hash := sha256.New()
 	hash.Write([]byte(s))
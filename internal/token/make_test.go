@@ -1,6 +1,7 @@
 package token_test
 
 import (
+	"context"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -53,6 +54,98 @@ func TestMakeIDToken(t *testing.T) {
 	}
 }
 
+func TestMakeIDToken_FilterClaims(t *testing.T) {
+	// Given.
+	ctx := oidctest.NewContext(t)
+	ctx.FilterClaimsFunc = func(_ context.Context, _ *goidc.Client, claims map[string]any) map[string]any {
+		claims["random_claim"] = "redacted"
+		return claims
+	}
+	client, _ := oidctest.NewClient(t)
+	idTokenOptions := token.IDTokenOptions{
+		Subject: "random_subject",
+		AdditionalIDTokenClaims: map[string]any{
+			"random_claim": "random_value",
+		},
+	}
+
+	// When.
+	idToken, err := token.MakeIDToken(ctx, client, idTokenOptions)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	claims, err := oidctest.SafeClaims(idToken, ctx.PrivateJWKS.Keys[0])
+	if err != nil {
+		t.Fatalf("error parsing claims: %v", err)
+	}
+
+	if claims["random_claim"] != "redacted" {
+		t.Errorf("random_claim = %v, want redacted", claims["random_claim"])
+	}
+
+	if claims["sub"] != "random_subject" {
+		t.Errorf("sub = %v, want random_subject", claims["sub"])
+	}
+}
+
+func TestMakeIDToken_AuthDetails(t *testing.T) {
+	// Given.
+	ctx := oidctest.NewContext(t)
+	client, _ := oidctest.NewClient(t)
+	idTokenOptions := token.IDTokenOptions{
+		Subject:     "random_subject",
+		AuthDetails: []goidc.AuthorizationDetail{{"type": "payment_initiation"}},
+	}
+
+	// When.
+	idToken, err := token.MakeIDToken(ctx, client, idTokenOptions)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	claims, err := oidctest.SafeClaims(idToken, ctx.PrivateJWKS.Keys[0])
+	if err != nil {
+		t.Fatalf("error parsing claims: %v", err)
+	}
+
+	want := []any{map[string]any{"type": "payment_initiation"}}
+	if diff := cmp.Diff(claims["authorization_details"], want); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestMakeIDToken_SessionID(t *testing.T) {
+	// Given.
+	ctx := oidctest.NewContext(t)
+	client, _ := oidctest.NewClient(t)
+	idTokenOptions := token.IDTokenOptions{
+		Subject:   "random_subject",
+		SessionID: "random_sid",
+	}
+
+	// When.
+	idToken, err := token.MakeIDToken(ctx, client, idTokenOptions)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	claims, err := oidctest.SafeClaims(idToken, ctx.PrivateJWKS.Keys[0])
+	if err != nil {
+		t.Fatalf("error parsing claims: %v", err)
+	}
+
+	if claims[goidc.ClaimSessionID] != "random_sid" {
+		t.Errorf("sid = %v, want random_sid", claims[goidc.ClaimSessionID])
+	}
+}
+
 func TestMakeIDToken_Unsigned(t *testing.T) {
 	// Given.
 	ctx := oidctest.NewContext(t)
@@ -111,7 +204,7 @@ func TestMakeToken_JWTToken(t *testing.T) {
 	}
 
 	// When.
-	token, err := token.Make(ctx, grantInfo)
+	token, err := token.Make(ctx, client, grantInfo)
 
 	// Then.
 	if err != nil {
@@ -150,6 +243,45 @@ func TestMakeToken_JWTToken(t *testing.T) {
 
 }
 
+func TestMakeToken_SubjectIdentifierFunc(t *testing.T) {
+	// Given.
+	ctx := oidctest.NewContext(t)
+	ctx.SubjectIdentifierFunc = func(c *goidc.Client, userID string) string {
+		return c.ID + ":" + userID
+	}
+	client, _ := oidctest.NewClient(t)
+	grantInfo := goidc.GrantInfo{
+		Subject:  "random_subject",
+		ClientID: client.ID,
+	}
+
+	// When.
+	accessToken, err := token.Make(ctx, client, grantInfo)
+	if err != nil {
+		t.Fatalf("unexpected error making the access token: %v", err)
+	}
+	idToken, err := token.MakeIDToken(ctx, client, token.IDTokenOptions{
+		Subject: grantInfo.Subject,
+	})
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error making the id token: %v", err)
+	}
+
+	wantSub := client.ID + ":random_subject"
+	for _, tkn := range []string{accessToken.Value, idToken} {
+		claims, err := oidctest.SafeClaims(tkn, ctx.PrivateJWKS.Keys[0])
+		if err != nil {
+			t.Fatalf("error parsing claims: %v", err)
+		}
+
+		if claims["sub"] != wantSub {
+			t.Errorf("sub = %v, want %s", claims["sub"], wantSub)
+		}
+	}
+}
+
 func TestMakeToken_OpaqueToken(t *testing.T) {
 	// Given.
 	ctx := oidctest.NewContext(t)
@@ -158,12 +290,13 @@ func TestMakeToken_OpaqueToken(t *testing.T) {
 	) goidc.TokenOptions {
 		return goidc.NewOpaqueTokenOptions(10, 60)
 	}
+	client, _ := oidctest.NewClient(t)
 	grantInfo := goidc.GrantInfo{
 		Subject: "random_subject",
 	}
 
 	// When.
-	token, err := token.Make(ctx, grantInfo)
+	token, err := token.Make(ctx, client, grantInfo)
 
 	// Then.
 	if err != nil {
@@ -178,3 +311,33 @@ func TestMakeToken_OpaqueToken(t *testing.T) {
 		t.Errorf("ID = %s, want %s", token.ID, token.Value)
 	}
 }
+
+func TestMakeToken_ClientTokenMetadata(t *testing.T) {
+	// Given.
+	ctx := oidctest.NewContext(t)
+	ctx.ClientTokenMetadataIsEnabled = true
+	ctx.ClientTokenMaxLifetimeSecs = 600
+	client, _ := oidctest.NewClient(t)
+	client.AccessTokenFormat = goidc.TokenFormatOpaque
+	client.AccessTokenLifetimeSecs = 600
+	grantInfo := goidc.GrantInfo{
+		Subject:  "random_subject",
+		ClientID: client.ID,
+	}
+
+	// When.
+	token, err := token.Make(ctx, client, grantInfo)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if token.Format != goidc.TokenFormatOpaque {
+		t.Errorf("Format = %s, want %s", token.Format, goidc.TokenFormatOpaque)
+	}
+
+	if token.LifetimeSecs != 600 {
+		t.Errorf("LifetimeSecs = %d, want 600", token.LifetimeSecs)
+	}
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/luikyv/go-oidc/internal/dpop"
+	"github.com/luikyv/go-oidc/internal/oidc"
 	"github.com/luikyv/go-oidc/internal/timeutil"
 	"github.com/luikyv/go-oidc/pkg/goidc"
 )
@@ -16,11 +17,20 @@ type Token struct {
 	Value        string
 	Type         goidc.TokenType
 	LifetimeSecs int
+	// GrantID is the ID of the grant session the token was issued for.
+	// It is only defined when the ID must be known before the grant session
+	// is created, e.g. for stateless tokens.
+	GrantID string
 }
 
 type IDTokenOptions struct {
 	Subject                 string
 	AdditionalIDTokenClaims map[string]any
+	// AuthDetails are returned in the authorization_details claim if not
+	// empty.
+	AuthDetails []goidc.AuthorizationDetail
+	// SessionID is returned in the sid claim if not empty.
+	SessionID string
 	// These values here below are intended to be hashed and placed in the ID token.
 	// Then, the ID token can be used as a detached signature for the implicit grant.
 	AccessToken       string
@@ -28,11 +38,57 @@ type IDTokenOptions struct {
 	State             string
 }
 
-func newIDTokenOptions(grantInfo goidc.GrantInfo) IDTokenOptions {
+func newIDTokenOptions(
+	ctx oidc.Context,
+	client *goidc.Client,
+	grantInfo goidc.GrantInfo,
+) (
+	IDTokenOptions,
+	error,
+) {
+	claims, err := WithSourceClaims(ctx, grantInfo, grantInfo.AdditionalIDTokenClaims)
+	if err != nil {
+		return IDTokenOptions{}, err
+	}
+
+	claims = ctx.FilterIDTokenClaims(grantInfo, claims)
+	// ID tokens issued by the token endpoint always come with an access token.
 	return IDTokenOptions{
 		Subject:                 grantInfo.Subject,
-		AdditionalIDTokenClaims: grantInfo.AdditionalIDTokenClaims,
+		AdditionalIDTokenClaims: ctx.MinimizeIDTokenClaims(grantInfo, claims, true),
+		AuthDetails:             ctx.AuthDetailsClaim(client, grantInfo),
+		SessionID:               grantInfo.SessionID,
+	}, nil
+}
+
+// WithSourceClaims returns a copy of claims overridden by the claims loaded
+// from the claims source for the grant, if one is configured.
+func WithSourceClaims(
+	ctx oidc.Context,
+	grantInfo goidc.GrantInfo,
+	claims map[string]any,
+) (
+	map[string]any,
+	error,
+) {
+	sourceClaims, err := ctx.ClaimsFromSource(grantInfo)
+	if err != nil {
+		return nil, goidc.Errorf(goidc.ErrorCodeInternalError,
+			"could not load the user claims", err)
+	}
+
+	if sourceClaims == nil {
+		return claims, nil
+	}
+
+	mergedClaims := make(map[string]any, len(claims)+len(sourceClaims))
+	for k, v := range claims {
+		mergedClaims[k] = v
 	}
+	for k, v := range sourceClaims {
+		mergedClaims[k] = v
+	}
+	return mergedClaims, nil
 }
 
 type request struct {
@@ -78,6 +134,78 @@ type response struct {
 	Scopes               string                      `json:"scope,omitempty"`
 	AuthorizationDetails []goidc.AuthorizationDetail `json:"authorization_details,omitempty"`
 	Resources            goidc.Resources             `json:"resources,omitempty"`
+	// ExpiresAt is the timestamp when the access token expires.
+	ExpiresAt int `json:"expires_at,omitempty"`
+	// RefreshExpiresIn is the lifetime in seconds of the refresh token.
+	RefreshExpiresIn int `json:"refresh_expires_in,omitempty"`
+	// AdditionalParams are extra parameters informed by the token response and
+	// renewal hint hooks.
+	AdditionalParams map[string]any `json:"-"`
+}
+
+func (resp response) MarshalJSON() ([]byte, error) {
+
+	type tokenResponse response
+	attributesBytes, err := json.Marshal(tokenResponse(resp))
+	if err != nil {
+		return nil, err
+	}
+
+	var rawValues map[string]any
+	if err := json.Unmarshal(attributesBytes, &rawValues); err != nil {
+		return nil, err
+	}
+
+	// Inline the additional parameters without overriding the standard ones.
+	for k, v := range resp.AdditionalParams {
+		if _, ok := rawValues[k]; ok || isStandardResponseParam(k) {
+			continue
+		}
+		rawValues[k] = v
+	}
+
+	return json.Marshal(rawValues)
+}
+
+func isStandardResponseParam(param string) bool {
+	switch param {
+	case "access_token", "id_token", "refresh_token", "expires_in",
+		"expires_at", "refresh_expires_in", "token_type", "scope",
+		"authorization_details", "resources":
+		return true
+	default:
+		return false
+	}
+}
+
+// setRenewalHints informs in the response when the tokens of the grant session
+// expire and includes the renewal hints, if any, so clients can schedule the
+// renewal of their tokens.
+func setRenewalHints(ctx oidc.Context, resp *response, grantSession *goidc.GrantSession) {
+	renewal := goidc.TokenRenewal{
+		GrantInfo:                     grantSession.GrantInfo,
+		AccessTokenExpiresAtTimestamp: grantSession.LastTokenExpiresAtTimestamp,
+	}
+	if grantSession.RefreshToken != "" {
+		renewal.RefreshTokenExpiresAtTimestamp = grantSession.ExpiresAtTimestamp
+	}
+
+	if ctx.TokenExpirationHintsIsEnabled {
+		resp.ExpiresAt = renewal.AccessTokenExpiresAtTimestamp
+		if renewal.RefreshTokenExpiresAtTimestamp != 0 {
+			resp.RefreshExpiresIn = max(renewal.RefreshTokenExpiresAtTimestamp-timeutil.TimestampNow(), 0)
+		}
+	}
+
+	for k, v := range ctx.TokenRenewalHints(renewal) {
+		if _, ok := resp.AdditionalParams[k]; ok {
+			continue
+		}
+		if resp.AdditionalParams == nil {
+			resp.AdditionalParams = map[string]any{}
+		}
+		resp.AdditionalParams[k] = v
+	}
 }
 
 type queryRequest struct {
@@ -98,10 +226,24 @@ type bindindValidationsOptions struct {
 	dpop           dpop.ValidationOptions
 }
 
-func NewGrantSession(grantInfo goidc.GrantInfo, token Token) *goidc.GrantSession {
+// saveGrantSession saves the grant session, asynchronously when the access
+// token is a JWT, since it can be used without the session being stored.
+func saveGrantSession(ctx oidc.Context, session *goidc.GrantSession, token Token) error {
+	if token.Format == goidc.TokenFormatJWT {
+		return ctx.SaveGrantSessionAsync(session)
+	}
+	return ctx.SaveGrantSession(session)
+}
+
+func NewGrantSession(ctx oidc.Context, grantInfo goidc.GrantInfo, token Token) *goidc.GrantSession {
+	id := token.GrantID
+	if id == "" {
+		id = ctx.NewID(goidc.IDTypeGrantSession, uuid.NewString)
+	}
+
 	timestampNow := timeutil.TimestampNow()
 	return &goidc.GrantSession{
-		ID:                          uuid.New().String(),
+		ID:                          id,
 		TokenID:                     token.ID,
 		CreatedAtTimestamp:          timestampNow,
 		LastTokenExpiresAtTimestamp: timestampNow + token.LifetimeSecs,
@@ -2,6 +2,7 @@ package token
 
 import (
 	"bytes"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -42,3 +43,40 @@ func TestNewRequest(t *testing.T) {
 		t.Error(diff)
 	}
 }
+
+func TestResponse_MarshalJSON(t *testing.T) {
+	// Given.
+	resp := response{
+		AccessToken: "random_token",
+		ExpiresIn:   60,
+		TokenType:   goidc.TokenTypeBearer,
+		AdditionalParams: map[string]any{
+			"patient":      "random_patient",
+			"access_token": "overridden_token",
+			"scope":        "overridden_scope",
+		},
+	}
+
+	// When.
+	respBytes, err := json.Marshal(resp)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var got map[string]any
+	if err := json.Unmarshal(respBytes, &got); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := map[string]any{
+		"access_token": "random_token",
+		"expires_in":   float64(60),
+		"token_type":   string(goidc.TokenTypeBearer),
+		"patient":      "random_patient",
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Error(diff)
+	}
+}
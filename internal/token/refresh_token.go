@@ -27,6 +27,7 @@ func generateRefreshTokenGrant(
 	if err != nil {
 		return response{}, err
 	}
+	ctx.AllowCORS(c)
 
 	grantSession, err := ctx.GrantSessionByRefreshToken(req.refreshToken)
 	if err != nil {
@@ -42,7 +43,7 @@ func generateRefreshTokenGrant(
 		return response{}, err
 	}
 
-	token, err := Make(ctx, grantSession.GrantInfo)
+	token, err := makeForGrant(ctx, c, grantSession.ID, grantSession.GrantInfo)
 	if err != nil {
 		return response{}, goidc.Errorf(goidc.ErrorCodeInternalError,
 			"could not generate token during refresh token grant", err)
@@ -57,6 +58,7 @@ func generateRefreshTokenGrant(
 		ExpiresIn:            token.LifetimeSecs,
 		TokenType:            token.Type,
 		AuthorizationDetails: grantSession.ActiveAuthDetails,
+		AdditionalParams:     ctx.TokenResponseParams(grantSession.GrantInfo),
 	}
 
 	if ctx.RefreshTokenRotationIsEnabled {
@@ -64,17 +66,19 @@ func generateRefreshTokenGrant(
 	}
 
 	if strutil.ContainsOpenID(grantSession.ActiveScopes) {
-		tokenResp.IDToken, err = MakeIDToken(
-			ctx,
-			c,
-			newIDTokenOptions(grantSession.GrantInfo),
-		)
+		idTokenOpts, err := newIDTokenOptions(ctx, c, grantSession.GrantInfo)
+		if err != nil {
+			return response{}, err
+		}
+
+		tokenResp.IDToken, err = MakeIDToken(ctx, c, idTokenOpts)
 		if err != nil {
 			return response{}, goidc.Errorf(goidc.ErrorCodeInternalError,
 				"could not generate id token during refresh token grant", err)
 		}
 	}
 
+	setRenewalHints(ctx, &tokenResp, grantSession)
 	return tokenResp, nil
 }
 
@@ -130,11 +134,12 @@ func updateRefreshTokenGrantSession(
 
 	updatePoPForRefreshedToken(ctx, &grantSession.GrantInfo)
 
-	if err := ctx.SaveGrantSession(grantSession); err != nil {
+	if err := saveGrantSession(ctx, grantSession, token); err != nil {
 		return goidc.Errorf(goidc.ErrorCodeInternalError,
 			"could not store the grant session", err)
 	}
 
+	ctx.NotifyTokenEvent(goidc.TokenEventRefresh, grantSession)
 	return nil
 }
 
@@ -170,7 +175,9 @@ func validateRefreshTokenGrantRequest(
 	}
 
 	if grantSession.IsExpired() {
-		_ = ctx.DeleteGrantSession(grantSession.ID)
+		if err := ctx.DeleteGrantSession(grantSession.ID); err == nil {
+			ctx.NotifyTokenEvent(goidc.TokenEventExpiry, grantSession)
+		}
 		return goidc.NewError(goidc.ErrorCodeUnauthorizedClient, "the refresh token is expired")
 	}
 
@@ -1,6 +1,7 @@
 package token
 
 import (
+	"net/http"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -68,6 +69,35 @@ func TestGenerateGrant_RefreshTokenGrant(t *testing.T) {
 	}
 }
 
+func TestGenerateGrant_RefreshTokenGrant_ExpirationHints(t *testing.T) {
+	// Given.
+	ctx, _, grantSession := setUpRefreshTokenGrant(t)
+	ctx.TokenExpirationHintsIsEnabled = true
+
+	req := request{
+		grantType:    goidc.GrantRefreshToken,
+		refreshToken: grantSession.RefreshToken,
+	}
+
+	// When.
+	tokenResp, err := generateGrant(ctx, req)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("error generating the refresh token grant: %v", err)
+	}
+
+	now := timeutil.TimestampNow()
+	wantRefreshExpiresIn := grantSession.ExpiresAtTimestamp - now
+	if diff := tokenResp.RefreshExpiresIn - wantRefreshExpiresIn; diff < -1 || diff > 1 {
+		t.Errorf("RefreshExpiresIn = %d, want %d", tokenResp.RefreshExpiresIn, wantRefreshExpiresIn)
+	}
+
+	if diff := tokenResp.ExpiresAt - (now + tokenResp.ExpiresIn); diff < -1 || diff > 1 {
+		t.Errorf("ExpiresAt = %d, want %d", tokenResp.ExpiresAt, now+tokenResp.ExpiresIn)
+	}
+}
+
 func TestGenerateGrant_RefreshTokenGrant_AuthDetails(t *testing.T) {
 
 	// Given.
@@ -243,6 +273,39 @@ func TestGenerateGrant_ExpiredRefreshToken(t *testing.T) {
 	}
 }
 
+func TestGenerateGrant_ExpiredRefreshToken_NotifyTokenEvent(t *testing.T) {
+	// Given.
+	ctx, client, grantSession := setUpRefreshTokenGrant(t)
+	grantSession.ExpiresAtTimestamp = timeutil.TimestampNow() - 10
+	var events []goidc.TokenEvent
+	ctx.NotifyTokenEventFunc = func(_ *http.Request, event goidc.TokenEvent) {
+		events = append(events, event)
+	}
+
+	// When.
+	_, err := generateGrant(ctx, request{
+		grantType:    goidc.GrantRefreshToken,
+		refreshToken: grantSession.RefreshToken,
+	})
+
+	// Then.
+	if err == nil {
+		t.Fatal("an expired grant session should result in failure")
+	}
+
+	want := []goidc.TokenEvent{
+		{
+			Type:     goidc.TokenEventExpiry,
+			GrantID:  grantSession.ID,
+			ClientID: client.ID,
+			Subject:  grantSession.Subject,
+		},
+	}
+	if diff := cmp.Diff(events, want); diff != "" {
+		t.Error(diff)
+	}
+}
+
 func setUpRefreshTokenGrant(t *testing.T) (
 	ctx oidc.Context,
 	client *goidc.Client,
@@ -29,6 +29,16 @@ func revoke(ctx oidc.Context, req queryRequest) error {
 			"token was not issued for this client")
 	}
 
-	_ = ctx.DeleteGrantSession(info.GrantID)
+	if err := ctx.DeleteGrantSession(info.GrantID); err != nil {
+		return nil
+	}
+
+	ctx.NotifyTokenEvent(goidc.TokenEventRevocation, &goidc.GrantSession{
+		ID: info.GrantID,
+		GrantInfo: goidc.GrantInfo{
+			ClientID: info.ClientID,
+			Subject:  info.Subject,
+		},
+	})
 	return nil
 }
@@ -2,8 +2,10 @@ package token
 
 import (
 	"errors"
+	"net/http"
 	"testing"
 
+	"github.com/google/go-cmp/cmp"
 	"github.com/luikyv/go-oidc/internal/oidc"
 	"github.com/luikyv/go-oidc/internal/oidctest"
 	"github.com/luikyv/go-oidc/internal/strutil"
@@ -44,6 +46,85 @@ func TestRevoke_OpaqueToken(t *testing.T) {
 	}
 }
 
+func TestRevoke_StatelessOpaqueToken(t *testing.T) {
+	// Given.
+	ctx, client := setUpRevocation(t)
+	ctx.TokenEncryptionKey = []byte("0123456789abcdef")
+	ctx.TokenOptionsFunc = func(gi goidc.GrantInfo) goidc.TokenOptions {
+		return goidc.NewStatelessOpaqueTokenOptions(60)
+	}
+
+	grantInfo := goidc.GrantInfo{ClientID: client.ID}
+	token, err := Make(ctx, client, grantInfo)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_ = ctx.SaveGrantSession(NewGrantSession(ctx, grantInfo, token))
+
+	// When.
+	err = revoke(ctx, queryRequest{token: token.Value})
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	grantSessions := oidctest.GrantSessions(t, ctx)
+	if len(grantSessions) != 0 {
+		t.Errorf("len(grantSessions) = %d, want 0", len(grantSessions))
+	}
+
+	tokenID, err := ExtractID(ctx, token.Value)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if tokenID != token.ID {
+		t.Errorf("ExtractID() = %s, want %s", tokenID, token.ID)
+	}
+}
+
+func TestRevoke_NotifyTokenEvent(t *testing.T) {
+	// Given.
+	ctx, client := setUpRevocation(t)
+	var events []goidc.TokenEvent
+	ctx.NotifyTokenEventFunc = func(_ *http.Request, event goidc.TokenEvent) {
+		events = append(events, event)
+	}
+
+	accessToken := "opaque_token"
+	grantSession := &goidc.GrantSession{
+		ID:                          "random_grant_id",
+		TokenID:                     accessToken,
+		LastTokenExpiresAtTimestamp: timeutil.TimestampNow() + 10,
+		GrantInfo: goidc.GrantInfo{
+			ClientID: client.ID,
+			Subject:  "random_user",
+		},
+	}
+	_ = ctx.SaveGrantSession(grantSession)
+
+	// When.
+	err := revoke(ctx, queryRequest{token: accessToken})
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []goidc.TokenEvent{
+		{
+			Type:     goidc.TokenEventRevocation,
+			GrantID:  grantSession.ID,
+			ClientID: client.ID,
+			Subject:  "random_user",
+		},
+	}
+	if diff := cmp.Diff(events, want); diff != "" {
+		t.Error(diff)
+	}
+}
+
 func TestRevoke_RefreshToken(t *testing.T) {
 	// Given.
 	ctx, client := setUpRevocation(t)
@@ -97,6 +178,7 @@ func TestRevoke_InvalidToken(t *testing.T) {
 func TestRevoke_TokenNotIssuedToClient(t *testing.T) {
 	// Given.
 	ctx, _ := setUpRevocation(t)
+	_ = ctx.SaveClient(&goidc.Client{ID: "another_client_id"})
 
 	accessToken := "opaque_token"
 	now := timeutil.TimestampNow()
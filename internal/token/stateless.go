@@ -0,0 +1,193 @@
+package token
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/luikyv/go-oidc/internal/oidc"
+	"github.com/luikyv/go-oidc/internal/timeutil"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+// statelessTokenClaims is the content encrypted inside stateless opaque tokens.
+type statelessTokenClaims struct {
+	TokenID              string                      `json:"jti"`
+	GrantID              string                      `json:"gid"`
+	ExpiresAtTimestamp   int                         `json:"exp"`
+	Subject              string                      `json:"sub"`
+	ClientID             string                      `json:"client_id,omitempty"`
+	Scopes               string                      `json:"scope,omitempty"`
+	AuthorizationDetails []goidc.AuthorizationDetail `json:"authorization_details,omitempty"`
+	Resources            goidc.Resources             `json:"aud,omitempty"`
+	JWKThumbprint        string                      `json:"jkt,omitempty"`
+	ClientCertThumbprint string                      `json:"x5t#S256,omitempty"`
+	AdditionalClaims     map[string]any              `json:"claims,omitempty"`
+}
+
+func makeStatelessOpaqueToken(
+	ctx oidc.Context,
+	grantID string,
+	grantInfo goidc.GrantInfo,
+	opts goidc.TokenOptions,
+) (
+	Token,
+	error,
+) {
+	if grantID == "" {
+		grantID = ctx.NewID(goidc.IDTypeGrantSession, uuid.NewString)
+	}
+
+	claims := statelessTokenClaims{
+		TokenID:              uuid.NewString(),
+		GrantID:              grantID,
+		ExpiresAtTimestamp:   timeutil.TimestampNow() + opts.LifetimeSecs,
+		Subject:              grantInfo.Subject,
+		ClientID:             grantInfo.ClientID,
+		Scopes:               grantInfo.ActiveScopes,
+		AuthorizationDetails: grantInfo.ActiveAuthDetails,
+		Resources:            grantInfo.ActiveResources,
+		JWKThumbprint:        grantInfo.JWKThumbprint,
+		ClientCertThumbprint: grantInfo.ClientCertThumbprint,
+		AdditionalClaims:     grantInfo.AdditionalTokenClaims,
+	}
+
+	accessToken, err := encryptStatelessToken(ctx, claims)
+	if err != nil {
+		return Token{}, goidc.Errorf(goidc.ErrorCodeInternalError,
+			"could not encrypt the access token", err)
+	}
+
+	tokenType := goidc.TokenTypeBearer
+	if grantInfo.JWKThumbprint != "" {
+		tokenType = goidc.TokenTypeDPoP
+	}
+
+	return Token{
+		ID:           claims.TokenID,
+		GrantID:      grantID,
+		Format:       goidc.TokenFormatOpaque,
+		Value:        accessToken,
+		Type:         tokenType,
+		LifetimeSecs: opts.LifetimeSecs,
+	}, nil
+}
+
+func encryptStatelessToken(
+	ctx oidc.Context,
+	claims statelessTokenClaims,
+) (
+	string,
+	error,
+) {
+	aead, err := tokenAEAD(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	plaintext, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	// The issuer, i.e. the host, is used as additional data so tokens cannot
+	// be replayed against other servers sharing the same key.
+	ciphertext := aead.Seal(nonce, nonce, plaintext, []byte(ctx.Host))
+	return base64.RawURLEncoding.EncodeToString(ciphertext), nil
+}
+
+// parseStatelessToken decrypts a stateless opaque token.
+// An error is returned if the value informed was not issued as a stateless
+// token by this server.
+func parseStatelessToken(
+	ctx oidc.Context,
+	token string,
+) (
+	statelessTokenClaims,
+	error,
+) {
+	aead, err := tokenAEAD(ctx)
+	if err != nil {
+		return statelessTokenClaims{}, err
+	}
+
+	ciphertext, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return statelessTokenClaims{}, err
+	}
+
+	if len(ciphertext) < aead.NonceSize() {
+		return statelessTokenClaims{}, errors.New("invalid token")
+	}
+
+	nonce, ciphertext := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	// Tokens issued before an issuer migration are bound to a former issuer.
+	var plaintext []byte
+	for _, issuer := range ctx.Issuers() {
+		plaintext, err = aead.Open(nil, nonce, ciphertext, []byte(issuer))
+		if err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return statelessTokenClaims{}, err
+	}
+
+	var claims statelessTokenClaims
+	if err := json.Unmarshal(plaintext, &claims); err != nil {
+		return statelessTokenClaims{}, err
+	}
+
+	return claims, nil
+}
+
+func statelessTokenInfo(claims statelessTokenClaims) (goidc.TokenInfo, error) {
+	if timeutil.TimestampNow() >= claims.ExpiresAtTimestamp {
+		return inactiveTokenInfo(goidc.TokenInactiveReasonExpired),
+			errors.New("token is expired")
+	}
+
+	var cnf *goidc.TokenConfirmation
+	if claims.JWKThumbprint != "" || claims.ClientCertThumbprint != "" {
+		cnf = &goidc.TokenConfirmation{
+			JWKThumbprint:        claims.JWKThumbprint,
+			ClientCertThumbprint: claims.ClientCertThumbprint,
+		}
+	}
+
+	return goidc.TokenInfo{
+		GrantID:               claims.GrantID,
+		IsActive:              true,
+		Type:                  goidc.TokenHintAccess,
+		Scopes:                claims.Scopes,
+		AuthorizationDetails:  claims.AuthorizationDetails,
+		ClientID:              claims.ClientID,
+		Subject:               claims.Subject,
+		ExpiresAtTimestamp:    claims.ExpiresAtTimestamp,
+		Confirmation:          cnf,
+		ResourceAudiences:     claims.Resources,
+		AdditionalTokenClaims: claims.AdditionalClaims,
+	}, nil
+}
+
+func tokenAEAD(ctx oidc.Context) (cipher.AEAD, error) {
+	if ctx.TokenEncryptionKey == nil {
+		return nil, errors.New("the token encryption key is not configured")
+	}
+
+	block, err := aes.NewCipher(ctx.TokenEncryptionKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
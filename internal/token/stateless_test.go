@@ -0,0 +1,131 @@
+package token
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/luikyv/go-oidc/internal/oidctest"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+func TestMake_StatelessOpaqueToken(t *testing.T) {
+	// Given.
+	ctx := oidctest.NewContext(t)
+	ctx.TokenEncryptionKey = []byte("0123456789abcdef0123456789abcdef")
+	ctx.TokenOptionsFunc = func(gi goidc.GrantInfo) goidc.TokenOptions {
+		return goidc.NewStatelessOpaqueTokenOptions(60)
+	}
+	_ = ctx.SaveClient(&goidc.Client{ID: "random_client_id"})
+
+	grantInfo := goidc.GrantInfo{
+		Subject:       "random_subject",
+		ClientID:      "random_client_id",
+		ActiveScopes:  goidc.ScopeOpenID.ID,
+		JWKThumbprint: "random_jkt",
+	}
+
+	// When.
+	token, err := Make(ctx, &goidc.Client{}, grantInfo)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if token.GrantID == "" {
+		t.Fatal("the grant ID of stateless tokens must be defined")
+	}
+
+	if token.Type != goidc.TokenTypeDPoP {
+		t.Errorf("Type = %s, want %s", token.Type, goidc.TokenTypeDPoP)
+	}
+
+	tokenInfo, err := IntrospectionInfo(ctx, token.Value)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := goidc.TokenInfo{
+		GrantID:            token.GrantID,
+		IsActive:           true,
+		Type:               goidc.TokenHintAccess,
+		Scopes:             goidc.ScopeOpenID.ID,
+		ClientID:           "random_client_id",
+		Subject:            "random_subject",
+		ExpiresAtTimestamp: tokenInfo.ExpiresAtTimestamp,
+		Confirmation: &goidc.TokenConfirmation{
+			JWKThumbprint: "random_jkt",
+		},
+	}
+	if diff := cmp.Diff(tokenInfo, want); diff != "" {
+		t.Error(diff)
+	}
+
+	grantSessions := oidctest.GrantSessions(t, ctx)
+	if len(grantSessions) != 0 {
+		t.Errorf("len(grantSessions) = %d, want 0", len(grantSessions))
+	}
+}
+
+func TestMake_StatelessOpaqueToken_KeyNotConfigured(t *testing.T) {
+	// Given.
+	ctx := oidctest.NewContext(t)
+	ctx.TokenOptionsFunc = func(gi goidc.GrantInfo) goidc.TokenOptions {
+		return goidc.NewStatelessOpaqueTokenOptions(60)
+	}
+
+	// When.
+	_, err := Make(ctx, &goidc.Client{}, goidc.GrantInfo{})
+
+	// Then.
+	if err == nil {
+		t.Fatal("the token cannot be issued without an encryption key")
+	}
+}
+
+func TestIntrospectionInfo_StatelessOpaqueToken_DifferentKey(t *testing.T) {
+	// Given.
+	ctx := oidctest.NewContext(t)
+	ctx.TokenEncryptionKey = []byte("0123456789abcdef")
+	ctx.TokenOptionsFunc = func(gi goidc.GrantInfo) goidc.TokenOptions {
+		return goidc.NewStatelessOpaqueTokenOptions(60)
+	}
+
+	token, err := Make(ctx, &goidc.Client{}, goidc.GrantInfo{Subject: "random_subject"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx.TokenEncryptionKey = []byte("fedcba9876543210")
+
+	// When.
+	_, err = IntrospectionInfo(ctx, token.Value)
+
+	// Then.
+	if err == nil {
+		t.Fatal("a token encrypted with a different key must be invalid")
+	}
+}
+
+func TestIntrospectionInfo_StatelessOpaqueToken_Expired(t *testing.T) {
+	// Given.
+	ctx := oidctest.NewContext(t)
+	ctx.TokenEncryptionKey = []byte("0123456789abcdef")
+
+	token, err := encryptStatelessToken(ctx, statelessTokenClaims{
+		TokenID:            "random_token_id",
+		GrantID:            "random_grant_id",
+		ExpiresAtTimestamp: 0,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// When.
+	_, err = IntrospectionInfo(ctx, token)
+
+	// Then.
+	if err == nil {
+		t.Fatal("the token should be expired")
+	}
+}
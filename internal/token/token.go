@@ -1,33 +1,29 @@
 package token
 
 import (
-	"time"
-
-	"github.com/go-jose/go-jose/v4/jwt"
-	"github.com/luikyv/go-oidc/internal/jwtutil"
 	"github.com/luikyv/go-oidc/internal/oidc"
 	"github.com/luikyv/go-oidc/pkg/goidc"
+	pkgtoken "github.com/luikyv/go-oidc/pkg/token"
 )
 
 // ExtractID returns the ID of a token.
-// If it's a JWT, the ID is the the "jti" claim. Otherwise, the token is
+// If it's a JWT, the ID is the the "jti" claim. If it's a stateless opaque
+// token, the ID is the one encrypted in it. If it was produced by a custom
+// token issuer, the ID is the one the issuer informs. Otherwise, the token is
 // considered opaque and its ID is the token itself.
 func ExtractID(ctx oidc.Context, token string) (string, error) {
-	if !jwtutil.IsJWS(token) {
-		return token, nil
-	}
-
-	claims, err := validClaims(ctx, token)
-	if err != nil {
-		return "", err
+	// Stateless tokens are identified the same way as during introspection.
+	if ctx.TokenEncryptionKey != nil {
+		if claims, err := parseStatelessToken(ctx, token); err == nil {
+			return claims.TokenID, nil
+		}
 	}
 
-	tokenID := claims[string(goidc.ClaimTokenID)]
-	if tokenID == nil {
-		return "", goidc.NewError(goidc.ErrorCodeAccessDenied, "invalid token")
+	if id, ok := ctx.CustomTokenID(token); ok {
+		return id, nil
 	}
 
-	return tokenID.(string), nil
+	return verifier(ctx).ExtractID(token)
 }
 
 // validClaims verifies a token and returns its claims.
@@ -38,37 +34,17 @@ func validClaims(
 	map[string]any,
 	error,
 ) {
-	parsedToken, err := jwt.ParseSigned(token, ctx.SigAlgs())
-	if err != nil {
-		// If the token is not a valid JWT, we'll treat it as an opaque token.
-		return nil, goidc.Errorf(goidc.ErrorCodeInvalidRequest,
-			"could not parse the token", err)
-	}
-
-	if len(parsedToken.Headers) != 1 || parsedToken.Headers[0].KeyID == "" {
-		return nil, goidc.NewError(goidc.ErrorCodeInvalidRequest, "invalid header kid")
-	}
-
-	keyID := parsedToken.Headers[0].KeyID
-	publicKey, ok := ctx.PublicKey(keyID)
-	if !ok || publicKey.Use != string(goidc.KeyUsageSignature) {
-		return nil, goidc.NewError(goidc.ErrorCodeAccessDenied, "invalid token")
-	}
-
-	var claims jwt.Claims
-	var rawClaims map[string]any
-	if err := parsedToken.Claims(publicKey.Key, &claims, &rawClaims); err != nil {
-		return nil, goidc.Errorf(goidc.ErrorCodeAccessDenied,
-			"invalid token", err)
-	}
+	return verifier(ctx).ValidClaims(token)
+}
 
-	if err := claims.ValidateWithLeeway(jwt.Expected{
-		Issuer: ctx.Host,
-	}, time.Duration(0)); err != nil {
-		return nil, goidc.Errorf(goidc.ErrorCodeAccessDenied, "invalid token", err)
+// verifier validates the tokens signed with the keys of the provider.
+func verifier(ctx oidc.Context) pkgtoken.Verifier {
+	return pkgtoken.Verifier{
+		Issuer:        ctx.Host,
+		IssuerAliases: ctx.IssuerAliases,
+		JWKS:          ctx.PublicKeys(),
+		SigAlgs:       ctx.SigAlgs(),
 	}
-
-	return rawClaims, nil
 }
 
 func generateGrant(
@@ -78,6 +54,10 @@ func generateGrant(
 	tokenResp response,
 	err error,
 ) {
+	ctx, span := ctx.StartSpan("token.GenerateGrant",
+		goidc.Attribute{Key: goidc.AttributeGrantType, Value: string(req.grantType)})
+	defer func() { oidc.EndSpan(span, err) }()
+
 	switch req.grantType {
 	case goidc.GrantClientCredentials:
 		return generateClientCredentialsGrant(ctx, req)
@@ -87,8 +67,12 @@ func generateGrant(
 		return generateRefreshTokenGrant(ctx, req)
 	case goidc.GrantJWTBearer:
 		return generateJWTBearerGrant(ctx, req)
-	default:
-		return response{}, goidc.NewError(goidc.ErrorCodeUnsupportedGrantType,
-			"unsupported grant type")
 	}
+
+	if f, ok := ctx.CustomGrantFunc(req.grantType); ok {
+		return generateCustomGrant(ctx, req, f)
+	}
+
+	return response{}, goidc.NewError(goidc.ErrorCodeUnsupportedGrantType,
+		"unsupported grant type")
 }
@@ -1,6 +1,7 @@
 package token
 
 import (
+	"math"
 	"slices"
 
 	"github.com/luikyv/go-oidc/internal/dpop"
@@ -36,6 +37,11 @@ func validateBindingDPoP(
 ) error {
 
 	if !ctx.DPoPIsEnabled {
+		// A grant bound to a DPoP key cannot be used without a proof, even if
+		// DPoP was disabled after the binding.
+		if opts.dpopIsRequired {
+			return goidc.NewError(goidc.ErrorCodeInvalidGrant, "the grant is bound to a dpop key")
+		}
 		return nil
 	}
 
@@ -208,9 +214,18 @@ func validatePkce(
 		return goidc.NewError(goidc.ErrorCodeInvalidRequest, "invalid code verifier")
 	}
 
+	if req.codeVerifier != "" && ctx.PKCEMinVerifierEntropyBits != 0 &&
+		verifierEntropyBits(req.codeVerifier) < float64(ctx.PKCEMinVerifierEntropyBits) {
+		return goidc.NewError(goidc.ErrorCodeInvalidRequest, "the code verifier is not random enough")
+	}
+
 	codeChallengeMethod := session.CodeChallengeMethod
 	if codeChallengeMethod == "" {
-		codeChallengeMethod = ctx.PKCEDefaultChallengeMethod
+		codeChallengeMethod = ctx.DefaultCodeChallengeMethod()
+	}
+	// The method could have been disallowed after the session was created.
+	if session.CodeChallenge != "" && !slices.Contains(ctx.CodeChallengeMethods(), codeChallengeMethod) {
+		return goidc.NewError(goidc.ErrorCodeInvalidGrant, "invalid code_challenge_method")
 	}
 	// In the case PKCE is enabled, if the session was created with a code
 	// challenge, the token request must contain the right code verifier.
@@ -225,6 +240,24 @@ func validatePkce(
 	return nil
 }
 
+// verifierEntropyBits estimates the entropy of the code verifier based on the
+// frequency of its characters, so verifiers with repeated characters or
+// patterns are penalized.
+func verifierEntropyBits(codeVerifier string) float64 {
+	frequencies := map[rune]int{}
+	for _, c := range codeVerifier {
+		frequencies[c]++
+	}
+
+	n := float64(len(codeVerifier))
+	var bitsPerChar float64
+	for _, count := range frequencies {
+		p := float64(count) / n
+		bitsPerChar -= p * math.Log2(p)
+	}
+	return bitsPerChar * n
+}
+
 func isPKCEValid(codeVerifier string, codeChallenge string, codeChallengeMethod goidc.CodeChallengeMethod) bool {
 	switch codeChallengeMethod {
 	case goidc.CodeChallengeMethodPlain:
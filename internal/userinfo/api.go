@@ -6,7 +6,7 @@ import (
 	"github.com/luikyv/go-oidc/internal/oidc"
 )
 
-func RegisterHandlers(router *http.ServeMux, config *oidc.Configuration) {
+func RegisterHandlers(router oidc.Router, config *oidc.Configuration) {
 	router.HandleFunc(
 		"POST "+config.EndpointPrefix+config.EndpointUserInfo,
 		oidc.Handler(config, handle),
@@ -16,6 +16,17 @@ func RegisterHandlers(router *http.ServeMux, config *oidc.Configuration) {
 		"GET "+config.EndpointPrefix+config.EndpointUserInfo,
 		oidc.Handler(config, handle),
 	)
+
+	if config.CORSIsEnabled {
+		router.HandleFunc(
+			"OPTIONS "+config.EndpointPrefix+config.EndpointUserInfo,
+			oidc.Handler(config, handlePreflight),
+		)
+	}
+}
+
+func handlePreflight(ctx oidc.Context) {
+	ctx.WriteCORSPreflight(http.MethodGet + ", " + http.MethodPost)
 }
 
 func handle(ctx oidc.Context) {
@@ -1,6 +1,8 @@
 package userinfo
 
 import (
+	"errors"
+
 	"github.com/go-jose/go-jose/v4"
 	"github.com/luikyv/go-oidc/internal/clientutil"
 	"github.com/luikyv/go-oidc/internal/jwtutil"
@@ -33,10 +35,15 @@ func handleUserInfoRequest(ctx oidc.Context) (response, error) {
 	}
 
 	client, err := ctx.Client(grantSession.ClientID)
+	if errors.Is(err, goidc.ErrClientDisabled) {
+		return response{}, goidc.Errorf(goidc.ErrorCodeInvalidToken,
+			"invalid token", err)
+	}
 	if err != nil {
 		return response{}, goidc.Errorf(goidc.ErrorCodeInternalError,
 			"could not load the client", err)
 	}
+	ctx.AllowCORS(client)
 
 	resp, err := userInfoResponse(ctx, client, grantSession)
 	if err != nil {
@@ -55,13 +62,24 @@ func userInfoResponse(
 	error,
 ) {
 
+	claims, err := token.WithSourceClaims(ctx, grantSession.GrantInfo,
+		grantSession.AdditionalUserInfoClaims)
+	if err != nil {
+		return response{}, err
+	}
+
 	userInfoClaims := map[string]any{
-		goidc.ClaimSubject: grantSession.Subject,
+		goidc.ClaimSubject: ctx.SubjectIdentifier(c, grantSession.Subject),
 	}
-	for k, v := range grantSession.AdditionalUserInfoClaims {
+	claims = ctx.FilterUserInfoClaims(grantSession.GrantInfo, claims)
+	for k, v := range ctx.FilterClaims(c, claims) {
 		userInfoClaims[k] = v
 	}
 
+	if details := ctx.AuthDetailsClaim(c, grantSession.GrantInfo); len(details) != 0 {
+		userInfoClaims[goidc.ClaimAuthDetails] = details
+	}
+
 	// If the client doesn't require the user info to be signed,
 	// we'll just return the claims as a JSON object.
 	if c.UserInfoSigAlg == "" {
@@ -136,10 +154,9 @@ func encryptUserInfoJWT(
 	string,
 	error,
 ) {
-	jwk, err := clientutil.JWKByAlg(ctx, c, string(c.UserInfoKeyEncAlg))
+	jwk, err := clientutil.EncJWK(ctx, c, c.UserInfoKeyEncAlg)
 	if err != nil {
-		return "", goidc.Errorf(goidc.ErrorCodeInvalidRequest,
-			"could not find a jwk to encrypt the user info response", err)
+		return "", err
 	}
 
 	contentEncAlg := c.UserInfoContentEncAlg
@@ -1,6 +1,7 @@
 package userinfo
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"testing"
@@ -10,6 +11,7 @@ import (
 	"github.com/luikyv/go-oidc/internal/oidc"
 	"github.com/luikyv/go-oidc/internal/oidctest"
 	"github.com/luikyv/go-oidc/internal/timeutil"
+	"github.com/luikyv/go-oidc/internal/token"
 	"github.com/luikyv/go-oidc/pkg/goidc"
 )
 
@@ -40,6 +42,247 @@ func TestHandleUserInfoRequest(t *testing.T) {
 	}
 }
 
+func TestHandleUserInfoRequest_ClientDisabled(t *testing.T) {
+	// Given.
+	ctx, client, _ := setUp(t)
+	client.Status = goidc.ClientStatusDisabled
+	_ = ctx.SaveClient(client)
+
+	// When.
+	_, err := handleUserInfoRequest(ctx)
+
+	// Then.
+	var oidcErr goidc.Error
+	if !errors.As(err, &oidcErr) {
+		t.Fatalf("invalid error type: %v", err)
+	}
+
+	if oidcErr.Code != goidc.ErrorCodeInvalidToken {
+		t.Errorf("Code = %s, want %s", oidcErr.Code, goidc.ErrorCodeInvalidToken)
+	}
+}
+
+func TestHandleUserInfoRequest_StatelessOpaqueToken(t *testing.T) {
+	// Given.
+	ctx, client, _ := setUp(t)
+	ctx.TokenEncryptionKey = []byte("0123456789abcdef")
+	ctx.TokenOptionsFunc = func(gi goidc.GrantInfo) goidc.TokenOptions {
+		return goidc.NewStatelessOpaqueTokenOptions(60)
+	}
+
+	grantInfo := goidc.GrantInfo{
+		ActiveScopes: goidc.ScopeOpenID.ID,
+		Subject:      "stateless_subject",
+		ClientID:     client.ID,
+	}
+	tkn, err := token.Make(ctx, client, grantInfo)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := ctx.SaveGrantSession(token.NewGrantSession(ctx, grantInfo, tkn)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ctx.Request.Header.Set("Authorization", "Bearer "+tkn.Value)
+
+	// When.
+	resp, err := handleUserInfoRequest(ctx)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if resp.claims[goidc.ClaimSubject] != "stateless_subject" {
+		t.Errorf("sub = %v, want stateless_subject", resp.claims[goidc.ClaimSubject])
+	}
+}
+
+func TestHandleUserInfoRequest_SubjectIdentifierFunc(t *testing.T) {
+	// Given.
+	ctx, client, _ := setUp(t)
+	ctx.SubjectIdentifierFunc = func(c *goidc.Client, userID string) string {
+		return c.ID + ":" + userID
+	}
+
+	// When.
+	resp, err := handleUserInfoRequest(ctx)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if want := client.ID + ":random_subject"; resp.claims["sub"] != want {
+		t.Errorf("sub = %v, want %s", resp.claims["sub"], want)
+	}
+}
+
+func TestHandleUserInfoRequest_ClaimsSource(t *testing.T) {
+	// Given.
+	ctx, _, _ := setUp(t)
+	ctx.ClaimsSourceFunc = func(_ context.Context, grantInfo goidc.GrantInfo) (map[string]any, error) {
+		return map[string]any{
+			"random_claim": "fresh_value",
+			"email":        grantInfo.Subject + "@example.com",
+		}, nil
+	}
+
+	// When.
+	resp, err := handleUserInfoRequest(ctx)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := response{
+		claims: map[string]any{
+			"sub":          "random_subject",
+			"random_claim": "fresh_value",
+			"email":        "random_subject@example.com",
+		},
+	}
+	if diff := cmp.Diff(
+		resp,
+		want,
+		cmp.AllowUnexported(response{}),
+	); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestHandleUserInfoRequest_ClaimsSourceError(t *testing.T) {
+	// Given.
+	ctx, _, _ := setUp(t)
+	ctx.ClaimsSourceFunc = func(context.Context, goidc.GrantInfo) (map[string]any, error) {
+		return nil, errors.New("random error")
+	}
+
+	// When.
+	_, err := handleUserInfoRequest(ctx)
+
+	// Then.
+	if err == nil {
+		t.Fatal("the claims source error must be returned")
+	}
+}
+
+func TestHandleUserInfoRequest_ClaimMappings(t *testing.T) {
+	// Given.
+	ctx, _, _ := setUp(t)
+	ctx.ClaimMappings = goidc.StandardClaimMappings
+	ctx.ClaimsSourceFunc = func(context.Context, goidc.GrantInfo) (map[string]any, error) {
+		return map[string]any{
+			goidc.ClaimEmail:       "random@example.com",
+			goidc.ClaimPhoneNumber: "+5500000000000",
+		}, nil
+	}
+
+	// When.
+	resp, err := handleUserInfoRequest(ctx)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := response{
+		claims: map[string]any{
+			"sub":          "random_subject",
+			"random_claim": "random_value",
+		},
+	}
+	if diff := cmp.Diff(
+		resp,
+		want,
+		cmp.AllowUnexported(response{}),
+	); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestHandleUserInfoRequest_FilterClaims(t *testing.T) {
+	// Given.
+	ctx, client, grantSession := setUp(t)
+	ctx.FilterClaimsFunc = func(_ context.Context, c *goidc.Client, claims map[string]any) map[string]any {
+		if c.ID == client.ID {
+			delete(claims, "random_claim")
+		}
+		return claims
+	}
+
+	// When.
+	resp, err := handleUserInfoRequest(ctx)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := response{
+		claims: map[string]any{
+			"sub": "random_subject",
+		},
+	}
+	if diff := cmp.Diff(
+		resp,
+		want,
+		cmp.AllowUnexported(response{}),
+	); diff != "" {
+		t.Error(diff)
+	}
+
+	if grantSession.AdditionalUserInfoClaims["random_claim"] != "random_value" {
+		t.Error("the claims of the grant session must not be modified")
+	}
+}
+
+func TestHandleUserInfoRequest_AuthDetailsClaim(t *testing.T) {
+	// Given.
+	ctx, _, grantSession := setUp(t)
+	ctx.AuthDetailsIsEnabled = true
+	ctx.AuthDetailsClaimIsEnabled = true
+	ctx.AuthDetailsClaimFunc = func(
+		_ context.Context,
+		_ *goidc.Client,
+		_ goidc.GrantInfo,
+		details []goidc.AuthorizationDetail,
+	) []goidc.AuthorizationDetail {
+		return details[:1]
+	}
+	grantSession.ActiveAuthDetails = []goidc.AuthorizationDetail{
+		{"type": "payment_initiation"},
+		{"type": "internal_type"},
+	}
+	if err := ctx.SaveGrantSession(grantSession); err != nil {
+		t.Fatalf("error saving the grant session: %v", err)
+	}
+
+	// When.
+	resp, err := handleUserInfoRequest(ctx)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := response{
+		claims: map[string]any{
+			"sub":                   "random_subject",
+			"random_claim":          "random_value",
+			"authorization_details": []goidc.AuthorizationDetail{{"type": "payment_initiation"}},
+		},
+	}
+	if diff := cmp.Diff(
+		resp,
+		want,
+		cmp.AllowUnexported(response{}),
+	); diff != "" {
+		t.Error(diff)
+	}
+}
+
 func TestHandleUserInfoRequest_SignedResponse(t *testing.T) {
 	// Given.
 	ctx, client, _ := setUp(t)
@@ -0,0 +1,115 @@
+// Package device remembers the devices, i.e. user agents, where users
+// completed a strong authentication, so policies can skip multi factor steps
+// on devices they trust while recording risk signals about them.
+//
+//	devices := device.New(device.Config{Store: device.NewMemoryStore()})
+//	policy := goidc.NewSequentialPolicy(
+//		"mfa",
+//		func(*http.Request, *goidc.Client, *goidc.AuthnSession) bool { return true },
+//		pages.LoginStep(verifyPassword),
+//		devices.Step(totp.Step(totpConfig)),
+//	)
+//
+// Devices are identified by a random ID kept in a cookie and they are trusted
+// per user, so a user agent shared by several users is only trusted for the
+// ones who completed the wrapped step in it.
+package device
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/luikyv/go-oidc/internal/timeutil"
+)
+
+const (
+	// SignalIPAddress is the address of the client of the request.
+	SignalIPAddress = "ip_address"
+	// SignalUserAgent is the User-Agent header of the request.
+	SignalUserAgent = "user_agent"
+
+	// maxSignals limits how many signals are kept per device. The oldest ones
+	// are discarded first.
+	maxSignals = 20
+)
+
+// ErrDeviceNotFound must be returned by a [Store] when the device is not
+// known for the user.
+var ErrDeviceNotFound = errors.New("device not found")
+
+// Store persists the devices of the users.
+type Store interface {
+	// Save creates or updates the device, which is identified by its ID and
+	// subject.
+	Save(ctx context.Context, device *Device) error
+	// Device returns the device of the user or [ErrDeviceNotFound].
+	Device(ctx context.Context, subject, id string) (*Device, error)
+	// Devices returns all the devices of the user.
+	Devices(ctx context.Context, subject string) ([]*Device, error)
+	Delete(ctx context.Context, subject, id string) error
+}
+
+// Device is a user agent used by a user.
+type Device struct {
+	ID      string `json:"id"`
+	Subject string `json:"sub"`
+	// TrustedUntilTimestamp is when the device stops being trusted. Zero
+	// means the device is not trusted.
+	TrustedUntilTimestamp int      `json:"trusted_until,omitempty"`
+	CreatedAtTimestamp    int      `json:"created_at"`
+	LastSeenTimestamp     int      `json:"last_seen_at"`
+	Signals               []Signal `json:"signals,omitempty"`
+}
+
+// IsTrusted returns whether the device can skip the multi factor steps.
+func (d *Device) IsTrusted() bool {
+	return timeutil.TimestampNow() < d.TrustedUntilTimestamp
+}
+
+// LastSignal returns the most recent signal of the type informed.
+func (d *Device) LastSignal(typ string) (Signal, bool) {
+	for i := len(d.Signals) - 1; i >= 0; i-- {
+		if d.Signals[i].Type == typ {
+			return d.Signals[i], true
+		}
+	}
+	return Signal{}, false
+}
+
+// Record appends the signals to the ones of the device, keeping only the most
+// recent ones, and updates when the device was last seen.
+func (d *Device) Record(signals ...Signal) {
+	d.Signals = append(d.Signals, signals...)
+	if len(d.Signals) > maxSignals {
+		d.Signals = d.Signals[len(d.Signals)-maxSignals:]
+	}
+	d.LastSeenTimestamp = timeutil.TimestampNow()
+}
+
+// Signal is a piece of information about a request that can indicate risk,
+// e.g. a new IP address.
+type Signal struct {
+	Type      string `json:"type"`
+	Value     string `json:"value"`
+	Timestamp int    `json:"timestamp"`
+}
+
+// Signals returns the IP address and user agent signals of the request.
+// The IP address is taken from the connection, so deployments behind proxies
+// should record the address forwarded by them instead.
+func Signals(r *http.Request) []Signal {
+	now := timeutil.TimestampNow()
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		ip = host
+	}
+
+	signals := []Signal{{Type: SignalIPAddress, Value: ip, Timestamp: now}}
+	if ua := strings.TrimSpace(r.UserAgent()); ua != "" {
+		signals = append(signals, Signal{Type: SignalUserAgent, Value: ua, Timestamp: now})
+	}
+	return signals
+}
@@ -0,0 +1,139 @@
+package device_test
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"slices"
+	"testing"
+
+	"github.com/luikyv/go-oidc/pkg/device"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+	"github.com/luikyv/go-oidc/pkg/providertest"
+)
+
+func TestStep(t *testing.T) {
+	// Given.
+	store := device.NewMemoryStore()
+	mfaCalls := 0
+	sim := newSimulator(t, device.Config{Store: store}, &mfaCalls)
+	sim.Start(t, nil)
+	sim.Code(t)
+
+	// When.
+	sim.Start(t, nil)
+
+	// Then.
+	sim.Code(t)
+	if mfaCalls != 1 {
+		t.Errorf("mfa calls = %d, want 1", mfaCalls)
+	}
+
+	amrs := sim.Session().IDTokenClaimAMRs()
+	if !slices.Equal(amrs, []goidc.AMR{goidc.AMRPassword, goidc.AMRRiskBased}) {
+		t.Errorf("amr = %v, want [pwd rba]", amrs)
+	}
+
+	devices, _ := store.Devices(context.Background(), providertest.DefaultSubject)
+	if len(devices) != 1 {
+		t.Fatalf("len(devices) = %d, want 1", len(devices))
+	}
+
+	if _, ok := devices[0].LastSignal(device.SignalIPAddress); !ok {
+		t.Error("the ip address should be recorded")
+	}
+}
+
+func TestMemoryStore_ReturnsCopies(t *testing.T) {
+	// Given.
+	ctx := context.Background()
+	store := device.NewMemoryStore()
+	d := &device.Device{ID: "device_id", Subject: providertest.DefaultSubject}
+	if err := store.Save(ctx, d); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// When.
+	d.Record(device.Signal{Type: device.SignalIPAddress, Value: "127.0.0.1"})
+	got, err := store.Device(ctx, d.Subject, d.ID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got.Record(device.Signal{Type: device.SignalIPAddress, Value: "127.0.0.1"})
+
+	// Then.
+	stored, err := store.Device(ctx, d.Subject, d.ID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(stored.Signals) != 0 {
+		t.Errorf("len(signals) = %d, want 0", len(stored.Signals))
+	}
+}
+
+func TestStep_PromptLogin(t *testing.T) {
+	// Given.
+	mfaCalls := 0
+	sim := newSimulator(t, device.Config{Store: device.NewMemoryStore()}, &mfaCalls)
+	sim.Start(t, nil)
+	sim.Code(t)
+
+	// When.
+	sim.Start(t, url.Values{"prompt": {"login"}})
+
+	// Then.
+	sim.Code(t)
+	if mfaCalls != 2 {
+		t.Errorf("mfa calls = %d, want 2", mfaCalls)
+	}
+}
+
+func TestStep_Risky(t *testing.T) {
+	// Given.
+	mfaCalls := 0
+	sim := newSimulator(t, device.Config{
+		Store: device.NewMemoryStore(),
+		IsRisky: func(*http.Request, *device.Device, []device.Signal) bool {
+			return true
+		},
+	}, &mfaCalls)
+	sim.Start(t, nil)
+	sim.Code(t)
+
+	// When.
+	sim.Start(t, nil)
+
+	// Then.
+	sim.Code(t)
+	if mfaCalls != 2 {
+		t.Errorf("mfa calls = %d, want 2", mfaCalls)
+	}
+}
+
+func newSimulator(t *testing.T, config device.Config, mfaCalls *int) *providertest.PolicySimulator {
+	t.Helper()
+
+	login := func(_ http.ResponseWriter, _ *http.Request, session *goidc.AuthnSession) (goidc.AuthnStatus, error) {
+		session.SetUserID(providertest.DefaultSubject)
+		session.SetIDTokenClaimAMR(goidc.AMRPassword)
+		return goidc.StatusSuccess, nil
+	}
+	mfa := func(_ http.ResponseWriter, _ *http.Request, session *goidc.AuthnSession) (goidc.AuthnStatus, error) {
+		*mfaCalls++
+		session.AddIDTokenClaimAMR(goidc.AMROneTimePassoword)
+		return goidc.StatusSuccess, nil
+	}
+	grant := func(_ http.ResponseWriter, _ *http.Request, session *goidc.AuthnSession) (goidc.AuthnStatus, error) {
+		session.GrantScopes(session.Scopes)
+		return goidc.StatusSuccess, nil
+	}
+
+	return providertest.NewPolicySimulator(t, goidc.NewSequentialPolicy(
+		"device",
+		func(*http.Request, *goidc.Client, *goidc.AuthnSession) bool { return true },
+		login,
+		device.New(config).Step(mfa),
+		grant,
+	))
+}
@@ -0,0 +1,165 @@
+package device
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/luikyv/go-oidc/internal/strutil"
+	"github.com/luikyv/go-oidc/internal/timeutil"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+const (
+	defaultCookieName = "goidc_device"
+	deviceIDLength    = 32
+	// cookieMaxAgeSecs keeps the device ID for longer than it is trusted, so
+	// the signals of the device keep being recorded.
+	cookieMaxAgeSecs = 31536000 // 1 year.
+
+	defaultTrustSecs = 2592000 // 30 days.
+)
+
+// IsRiskyFunc decides whether a request coming from a trusted device is risky,
+// in which case the wrapped step is run anyway. signals are the ones of the
+// request, which are not recorded in the device yet.
+type IsRiskyFunc func(r *http.Request, device *Device, signals []Signal) bool
+
+// Config defines how devices are remembered.
+type Config struct {
+	Store Store
+	// TrustSecs is for how long a device is trusted after the user completes
+	// the wrapped step in it. It defaults to 30 days.
+	TrustSecs int
+	// IsRisky, if set, is consulted before skipping the wrapped step on a
+	// trusted device.
+	IsRisky IsRiskyFunc
+	// CookieName defaults to "goidc_device".
+	CookieName string
+}
+
+// Devices remembers the devices of the users.
+type Devices struct {
+	config Config
+}
+
+func New(config Config) *Devices {
+	if config.TrustSecs == 0 {
+		config.TrustSecs = defaultTrustSecs
+	}
+	if config.CookieName == "" {
+		config.CookieName = defaultCookieName
+	}
+	return &Devices{config: config}
+}
+
+// Step wraps a multi factor step so it is skipped on devices trusted by the
+// user, in which case the amr "rba" is added to the ID token, since the
+// authentication relied on what is known about the device.
+// The step must be placed after the user is identified. When the wrapped step
+// succeeds, the device becomes trusted for the user. The signals of the
+// request are recorded in both cases.
+// The wrapped step is always run if the client requested prompt=login.
+func (d *Devices) Step(step goidc.AuthnStep) goidc.AuthnStep {
+	return func(w http.ResponseWriter, r *http.Request, session *goidc.AuthnSession) (goidc.AuthnStatus, error) {
+		if session.Subject == "" {
+			return goidc.StatusFailure, errors.New("the user must be identified before checking the device")
+		}
+
+		device, err := d.Device(r, session.Subject)
+		if err != nil {
+			return goidc.StatusFailure, err
+		}
+
+		signals := Signals(r)
+		if device != nil && device.IsTrusted() && !session.MustReauthenticate() &&
+			(d.config.IsRisky == nil || !d.config.IsRisky(r, device, signals)) {
+			device.Record(signals...)
+			if err := d.config.Store.Save(r.Context(), device); err != nil {
+				return goidc.StatusFailure, err
+			}
+			session.AddIDTokenClaimAMR(goidc.AMRRiskBased)
+			return goidc.StatusSuccess, nil
+		}
+
+		status, err := step(w, r, session)
+		if err != nil || status != goidc.StatusSuccess {
+			return status, err
+		}
+
+		if err := d.Trust(w, r, session.Subject); err != nil {
+			return goidc.StatusFailure, err
+		}
+		return goidc.StatusSuccess, nil
+	}
+}
+
+// Device returns the device of the request as known for the user, or nil if
+// the device was never seen for them.
+func (d *Devices) Device(r *http.Request, subject string) (*Device, error) {
+	cookie, err := r.Cookie(d.config.CookieName)
+	if err != nil {
+		return nil, nil
+	}
+
+	device, err := d.config.Store.Device(r.Context(), subject, cookie.Value)
+	if errors.Is(err, ErrDeviceNotFound) {
+		return nil, nil
+	}
+	return device, err
+}
+
+// IsTrusted returns whether the device of the request is trusted by the user.
+func (d *Devices) IsTrusted(r *http.Request, subject string) (bool, error) {
+	device, err := d.Device(r, subject)
+	if err != nil {
+		return false, err
+	}
+	return device != nil && device.IsTrusted(), nil
+}
+
+// Trust makes the device of the request trusted by the user for
+// [Config.TrustSecs] and records the signals of the request. If the user
+// agent has no device ID yet, one is generated and set in a cookie.
+func (d *Devices) Trust(w http.ResponseWriter, r *http.Request, subject string) error {
+	device, err := d.Device(r, subject)
+	if err != nil {
+		return err
+	}
+
+	now := timeutil.TimestampNow()
+	if device == nil {
+		id := strutil.Random(deviceIDLength)
+		if cookie, err := r.Cookie(d.config.CookieName); err == nil {
+			// Keep the ID the user agent already has, so it is the same for
+			// all the users.
+			id = cookie.Value
+		}
+		device = &Device{ID: id, Subject: subject, CreatedAtTimestamp: now}
+	}
+
+	device.TrustedUntilTimestamp = now + d.config.TrustSecs
+	device.Record(Signals(r)...)
+	if err := d.config.Store.Save(r.Context(), device); err != nil {
+		return err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     d.config.CookieName,
+		Value:    device.ID,
+		Path:     "/",
+		MaxAge:   cookieMaxAgeSecs,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return nil
+}
+
+// Forget stops trusting the device of the request for the user.
+func (d *Devices) Forget(r *http.Request, subject string) error {
+	device, err := d.Device(r, subject)
+	if err != nil || device == nil {
+		return err
+	}
+	return d.config.Store.Delete(r.Context(), subject, device.ID)
+}
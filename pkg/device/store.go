@@ -0,0 +1,71 @@
+package device
+
+import (
+	"context"
+	"slices"
+	"sync"
+)
+
+// MemoryStore is a [Store] that keeps the devices in memory.
+// It is meant for tests and development, since the devices are lost when the
+// process exits.
+// Devices are copied in and out of the store, so changes to them only take
+// effect once they are saved.
+type MemoryStore struct {
+	mu      sync.RWMutex
+	devices map[string]*Device
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{devices: map[string]*Device{}}
+}
+
+func (s *MemoryStore) Save(_ context.Context, device *Device) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.devices[deviceKey(device.Subject, device.ID)] = copyDevice(device)
+	return nil
+}
+
+func (s *MemoryStore) Device(_ context.Context, subject, id string) (*Device, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	device, ok := s.devices[deviceKey(subject, id)]
+	if !ok {
+		return nil, ErrDeviceNotFound
+	}
+	return copyDevice(device), nil
+}
+
+func (s *MemoryStore) Devices(_ context.Context, subject string) ([]*Device, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var devices []*Device
+	for _, device := range s.devices {
+		if device.Subject == subject {
+			devices = append(devices, copyDevice(device))
+		}
+	}
+	return devices, nil
+}
+
+func (s *MemoryStore) Delete(_ context.Context, subject, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.devices, deviceKey(subject, id))
+	return nil
+}
+
+func copyDevice(device *Device) *Device {
+	d := *device
+	d.Signals = slices.Clone(device.Signals)
+	return &d
+}
+
+func deviceKey(subject, id string) string {
+	return subject + " " + id
+}
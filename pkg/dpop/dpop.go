@@ -0,0 +1,89 @@
+// Package dpop contains helpers for clients creating DPoP proofs as defined
+// in RFC 9449.
+package dpop
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/luikyv/go-oidc/internal/jwtutil"
+	"github.com/luikyv/go-oidc/internal/strutil"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+const (
+	jtiLength = 32
+	typ       = "dpop+jwt"
+)
+
+// ProofOptions holds the information about the request the DPoP proof is
+// created for.
+type ProofOptions struct {
+	// Method is the HTTP method of the request, e.g. POST.
+	Method string
+	// URI is the HTTP target URI of the request. Its query and fragment
+	// components are removed from the "htu" claim.
+	URI string
+	// AccessToken must be informed when the proof is sent along with an access
+	// token so its hash is included as the "ath" claim.
+	AccessToken string
+	// Nonce is the value the server provided in the DPoP-Nonce header, if any.
+	Nonce string
+}
+
+// NewProof creates a DPoP proof for a request signed with the private JWK.
+// The public part of the key is embedded in the header of the proof, and the
+// signature algorithm is defined by the "alg" of the JWK.
+func NewProof(jwk jose.JSONWebKey, opts ProofOptions) (string, error) {
+	if jwk.IsPublic() {
+		return "", errors.New("a private key is required to sign dpop proofs")
+	}
+
+	if opts.Method == "" {
+		return "", errors.New("the http method is required")
+	}
+
+	htu, err := url.Parse(opts.URI)
+	if err != nil || htu.Scheme == "" || htu.Host == "" {
+		return "", errors.New("the http uri must be an absolute url")
+	}
+	htu.RawQuery = ""
+	htu.Fragment = ""
+
+	claims := map[string]any{
+		goidc.ClaimTokenID:  strutil.Random(jtiLength),
+		"htm":               opts.Method,
+		"htu":               htu.String(),
+		goidc.ClaimIssuedAt: time.Now().Unix(),
+	}
+
+	if opts.AccessToken != "" {
+		claims["ath"] = hashBase64URLSHA256(opts.AccessToken)
+	}
+
+	if opts.Nonce != "" {
+		claims[goidc.ClaimNonce] = opts.Nonce
+	}
+
+	return jwtutil.Sign(claims, jwk, (&jose.SignerOptions{EmbedJWK: true}).WithType(typ))
+}
+
+// JWKThumbprint returns the base64url encoded SHA-256 thumbprint of the JWK.
+// It can be informed as the "dpop_jkt" parameter of authorization requests.
+func JWKThumbprint(jwk jose.JSONWebKey) (string, error) {
+	thumbprint, err := jwk.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(thumbprint), nil
+}
+
+func hashBase64URLSHA256(s string) string {
+	hash := sha256.Sum256([]byte(s))
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
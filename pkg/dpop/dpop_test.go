@@ -0,0 +1,128 @@
+package dpop_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	internaldpop "github.com/luikyv/go-oidc/internal/dpop"
+	"github.com/luikyv/go-oidc/internal/oidc"
+	"github.com/luikyv/go-oidc/internal/oidctest"
+	"github.com/luikyv/go-oidc/pkg/dpop"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+func TestNewProof(t *testing.T) {
+	// Given.
+	jwk := oidctest.PrivatePS256JWK(t, "dpop_key", goidc.KeyUsageSignature)
+	accessToken := "random_access_token"
+
+	// When.
+	proof, err := dpop.NewProof(jwk, dpop.ProofOptions{
+		Method:      http.MethodGet,
+		URI:         "https://example.com/resource?param=value",
+		AccessToken: accessToken,
+	})
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	jkt, err := dpop.JWKThumbprint(jwk)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx := oidc.Context{
+		Configuration: &oidc.Configuration{
+			Host:             "https://example.com",
+			DPoPIsEnabled:    true,
+			DPoPSigAlgs:      []jose.SignatureAlgorithm{jose.PS256},
+			DPoPLifetimeSecs: 60,
+		},
+		Request: httptest.NewRequest(http.MethodGet, "/resource", nil),
+	}
+	if err := internaldpop.ValidateJWT(ctx, proof, internaldpop.ValidationOptions{
+		AccessToken:   accessToken,
+		JWKThumbprint: jkt,
+	}); err != nil {
+		t.Errorf("the proof must be valid: %v", err)
+	}
+}
+
+func TestNewProof_Nonce(t *testing.T) {
+	// Given.
+	jwk := oidctest.PrivatePS256JWK(t, "dpop_key", goidc.KeyUsageSignature)
+
+	// When.
+	proof, err := dpop.NewProof(jwk, dpop.ProofOptions{
+		Method: http.MethodPost,
+		URI:    "https://example.com/token",
+		Nonce:  "random_nonce",
+	})
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	parsedProof, err := jwt.ParseSigned(proof, []jose.SignatureAlgorithm{jose.PS256})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var claims map[string]any
+	if err := parsedProof.Claims(jwk.Public().Key, &claims); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if claims[goidc.ClaimNonce] != "random_nonce" {
+		t.Errorf("nonce = %v, want random_nonce", claims[goidc.ClaimNonce])
+	}
+
+	if _, ok := claims["ath"]; ok {
+		t.Error("the ath claim must not be present without an access token")
+	}
+}
+
+func TestNewProof_InvalidOptions(t *testing.T) {
+	// Given.
+	jwk := oidctest.PrivatePS256JWK(t, "dpop_key", goidc.KeyUsageSignature)
+
+	testCases := []struct {
+		name string
+		jwk  jose.JSONWebKey
+		opts dpop.ProofOptions
+	}{
+		{
+			name: "public key",
+			jwk:  jwk.Public(),
+			opts: dpop.ProofOptions{Method: http.MethodGet, URI: "https://example.com"},
+		},
+		{
+			name: "missing method",
+			jwk:  jwk,
+			opts: dpop.ProofOptions{URI: "https://example.com"},
+		},
+		{
+			name: "relative uri",
+			jwk:  jwk,
+			opts: dpop.ProofOptions{Method: http.MethodGet, URI: "/resource"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// When.
+			_, err := dpop.NewProof(testCase.jwk, testCase.opts)
+
+			// Then.
+			if err == nil {
+				t.Error("an error was expected")
+			}
+		})
+	}
+}
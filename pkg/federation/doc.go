@@ -0,0 +1,30 @@
+// Package federation contains helpers for authentication policies that
+// delegate the user login to an upstream OpenID Provider.
+//
+// The upstream provider is configured with [UpstreamOP]. Its authentication
+// step handles the outbound authorization request, the state and nonce
+// correctness, the validation of the callback and the mapping of the upstream
+// claims into the [goidc.AuthnSession].
+//
+//	upstream := federation.UpstreamOP{...}
+//	policy := goidc.NewSequentialPolicy(
+//		"federated_login",
+//		setUpFunc,
+//		upstream.AuthnStep(),
+//		consentStep,
+//	)
+//
+//	server := http.NewServeMux()
+//	server.Handle("/", op.Handler())
+//	server.Handle("POST /federation/callback", upstream.RedirectHandler(authorizeEndpoint))
+//
+// Only upstream OpenID Providers are supported. Federating with SAML identity
+// providers is out of the scope of this package and must be implemented by a
+// custom [goidc.AuthnFunc]. The authorization requests and ID tokens are handled by the rp
+// package, so the upstream provider must support PKCE.
+//
+// The scopes requested upstream are the ones of [UpstreamOP.Scopes]. They are
+// not derived from the scopes requested to this server, i.e. the upstream
+// grant is not scoped down to the downstream request. Only the claims mapped
+// by [UpstreamOP.MapClaims] reach the session.
+package federation
@@ -0,0 +1,213 @@
+package federation
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/luikyv/go-oidc/internal/strutil"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+	"github.com/luikyv/go-oidc/pkg/rp"
+)
+
+const (
+	stateLength        = 32
+	nonceLength        = 32
+	codeVerifierLength = 64
+	// Keys used to keep the federation information in the session store.
+	paramState        = "federation_state"
+	paramNonce        = "federation_nonce"
+	paramCodeVerifier = "federation_code_verifier"
+)
+
+// MapClaimsFunc defines a function that maps the claims of the ID token issued
+// by the upstream provider into the authentication session.
+type MapClaimsFunc func(claims map[string]any, session *goidc.AuthnSession) error
+
+// UpstreamOP holds the information needed to authenticate users with an
+// upstream OpenID Provider.
+// The upstream provider must support the authorization code flow with PKCE and
+// the form_post response mode.
+type UpstreamOP struct {
+	Issuer                string
+	AuthorizationEndpoint string
+	TokenEndpoint         string
+	ClientID              string
+	// ClientSecret is used to authenticate with client_secret_post at the
+	// upstream token endpoint.
+	ClientSecret string
+	// RedirectURI is the URI registered at the upstream provider.
+	// It must be handled by [UpstreamOP.RedirectHandler].
+	RedirectURI string
+	// Scopes are the scopes requested to the upstream provider.
+	// If empty, only openid is requested.
+	Scopes []string
+	// JWKS contains the public keys of the upstream provider used to verify
+	// ID tokens.
+	JWKS    jose.JSONWebKeySet
+	SigAlgs []jose.SignatureAlgorithm
+	// IDTokenLeeway is the clock skew tolerated when validating the upstream
+	// ID tokens. If zero, one minute is used.
+	IDTokenLeeway time.Duration
+	// MapClaims maps the upstream claims into the session.
+	// If nil, the subject of the session is set to the upstream subject.
+	MapClaims  MapClaimsFunc
+	HTTPClient *http.Client
+}
+
+// AuthnStep returns an authentication step that delegates the user login to
+// the upstream provider.
+// When first executed, the user is redirected to the upstream authorization
+// endpoint. When resumed at the callback endpoint, the authorization response
+// is validated, the code is exchanged for an ID token and its claims are mapped
+// into the session.
+func (op UpstreamOP) AuthnStep() goidc.AuthnStep {
+	return func(
+		w http.ResponseWriter,
+		r *http.Request,
+		session *goidc.AuthnSession,
+	) (
+		goidc.AuthnStatus,
+		error,
+	) {
+		state, ok := session.Parameter(paramState).(string)
+		if !ok {
+			return op.redirect(w, r, session)
+		}
+
+		if err := op.finish(r, session, state); err != nil {
+			return goidc.StatusFailure, err
+		}
+		return goidc.StatusSuccess, nil
+	}
+}
+
+// RedirectHandler returns a handler for the redirect URI registered at the
+// upstream provider.
+// It forwards the authorization response posted by the upstream provider to
+// the callback endpoint of the authentication session, which is identified
+// by the state.
+// authorizeEndpoint is the full URL of the authorization endpoint of this
+// server, e.g. "https://example.com/authorize".
+func (op UpstreamOP) RedirectHandler(authorizeEndpoint string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		callbackID, _, ok := strings.Cut(r.PostFormValue("state"), ".")
+		if !ok || callbackID == "" {
+			http.Error(w, "invalid state", http.StatusBadRequest)
+			return
+		}
+
+		// Use 307 so the browser posts the same form to the callback endpoint.
+		http.Redirect(w, r, authorizeEndpoint+"/"+url.PathEscape(callbackID),
+			http.StatusTemporaryRedirect)
+	})
+}
+
+func (op UpstreamOP) redirect(
+	w http.ResponseWriter,
+	r *http.Request,
+	session *goidc.AuthnSession,
+) (
+	goidc.AuthnStatus,
+	error,
+) {
+	// The callback ID is embedded in the state so the redirect handler can
+	// find the session the upstream response belongs to.
+	authReq := rp.AuthorizationRequest{
+		State:        session.CallbackID + "." + strutil.Random(stateLength),
+		Nonce:        strutil.Random(nonceLength),
+		CodeVerifier: strutil.Random(codeVerifierLength),
+		Params:       url.Values{},
+	}
+	session.StoreParameter(paramState, authReq.State)
+	session.StoreParameter(paramNonce, authReq.Nonce)
+	session.StoreParameter(paramCodeVerifier, authReq.CodeVerifier)
+
+	authReq.Params.Set("response_mode", string(goidc.ResponseModeFormPost))
+	if session.LoginHint != "" {
+		authReq.Params.Set("login_hint", session.LoginHint)
+	}
+
+	authURL, err := op.client().AuthorizationURL(r.Context(), authReq)
+	if err != nil {
+		return goidc.StatusFailure, err
+	}
+
+	http.Redirect(w, r, authURL, http.StatusSeeOther)
+	return goidc.StatusInProgress, nil
+}
+
+func (op UpstreamOP) finish(
+	r *http.Request,
+	session *goidc.AuthnSession,
+	state string,
+) error {
+	// Make sure the upstream response cannot be replayed.
+	delete(session.Store, paramState)
+
+	nonce, _ := session.Parameter(paramNonce).(string)
+	codeVerifier, _ := session.Parameter(paramCodeVerifier).(string)
+	authReq := rp.AuthorizationRequest{
+		State:        state,
+		Nonce:        nonce,
+		CodeVerifier: codeVerifier,
+	}
+	if nonce == "" {
+		return goidc.NewError(goidc.ErrorCodeAccessDenied, "the upstream nonce is missing")
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return goidc.Errorf(goidc.ErrorCodeAccessDenied, "invalid upstream response", err)
+	}
+
+	client := op.client()
+	code, err := client.ParseAuthorizationResponse(authReq, r.PostForm)
+	if err != nil {
+		return goidc.Errorf(goidc.ErrorCodeAccessDenied, "invalid upstream response", err)
+	}
+
+	tokenResp, err := client.Exchange(r.Context(), authReq, code)
+	if err != nil {
+		return goidc.Errorf(goidc.ErrorCodeAccessDenied,
+			"could not exchange the upstream code", err)
+	}
+
+	if tokenResp.IDToken == "" {
+		return goidc.NewError(goidc.ErrorCodeAccessDenied,
+			"the upstream token response doesn't contain an id token")
+	}
+
+	if op.MapClaims == nil {
+		sub, ok := tokenResp.IDTokenClaims[goidc.ClaimSubject].(string)
+		if !ok || sub == "" {
+			return goidc.NewError(goidc.ErrorCodeAccessDenied,
+				"the upstream id token doesn't contain a valid subject")
+		}
+		session.SetUserID(sub)
+		return nil
+	}
+
+	return op.MapClaims(tokenResp.IDTokenClaims, session)
+}
+
+// client returns the relying party used to interact with the upstream
+// provider.
+func (op UpstreamOP) client() rp.Client {
+	return rp.Client{
+		ID:          op.ClientID,
+		Secret:      op.ClientSecret,
+		RedirectURI: op.RedirectURI,
+		Scopes:      op.Scopes,
+		Provider: rp.ProviderMetadata{
+			Issuer:                op.Issuer,
+			AuthorizationEndpoint: op.AuthorizationEndpoint,
+			TokenEndpoint:         op.TokenEndpoint,
+			IDTokenSigAlgs:        op.SigAlgs,
+		},
+		ProviderJWKS:  &op.JWKS,
+		IDTokenLeeway: op.IDTokenLeeway,
+		HTTPClient:    op.HTTPClient,
+	}
+}
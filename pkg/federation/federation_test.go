@@ -0,0 +1,219 @@
+package federation_test
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/luikyv/go-oidc/internal/jwtutil"
+	"github.com/luikyv/go-oidc/internal/oidctest"
+	"github.com/luikyv/go-oidc/pkg/federation"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+func TestAuthnStep(t *testing.T) {
+	// Given.
+	jwk := oidctest.PrivateRS256JWK(t, "upstream_key", goidc.KeyUsageSignature)
+	var nonce, codeChallenge string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.PostFormValue("code") != "upstream_code" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		hash := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+		if base64.RawURLEncoding.EncodeToString(hash[:]) != codeChallenge {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		now := time.Now().Unix()
+		idToken, _ := jwtutil.Sign(map[string]any{
+			"iss":   "https://upstream.com",
+			"sub":   "upstream_subject",
+			"aud":   "random_client_id",
+			"iat":   now,
+			"exp":   now + 60,
+			"nonce": nonce,
+		}, jwk, (&jose.SignerOptions{}).WithHeader("kid", jwk.KeyID))
+		_ = json.NewEncoder(w).Encode(map[string]any{"id_token": idToken})
+	}))
+	defer server.Close()
+
+	op := federation.UpstreamOP{
+		Issuer:                "https://upstream.com",
+		AuthorizationEndpoint: "https://upstream.com/authorize",
+		TokenEndpoint:         server.URL,
+		ClientID:              "random_client_id",
+		RedirectURI:           "https://example.com/federation/callback",
+		JWKS:                  jose.JSONWebKeySet{Keys: []jose.JSONWebKey{jwk.Public()}},
+	}
+	step := op.AuthnStep()
+	session := &goidc.AuthnSession{CallbackID: "random_callback_id"}
+
+	// When.
+	w := httptest.NewRecorder()
+	status, err := step(w, httptest.NewRequest(http.MethodGet, "/authorize", nil), session)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if status != goidc.StatusInProgress {
+		t.Fatalf("status = %s, want %s", status, goidc.StatusInProgress)
+	}
+
+	redirectURL, err := url.Parse(w.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("could not parse the redirect url: %v", err)
+	}
+	state := redirectURL.Query().Get("state")
+	nonce = redirectURL.Query().Get("nonce")
+	codeChallenge = redirectURL.Query().Get("code_challenge")
+	if method := redirectURL.Query().Get("code_challenge_method"); method != "S256" {
+		t.Errorf("code_challenge_method = %s, want S256", method)
+	}
+	if !strings.HasPrefix(state, session.CallbackID+".") {
+		t.Errorf("the state %s must contain the callback id", state)
+	}
+
+	// When.
+	form := url.Values{}
+	form.Set("state", state)
+	form.Set("code", "upstream_code")
+	callbackReq := httptest.NewRequest(http.MethodPost, "/federation/callback",
+		strings.NewReader(form.Encode()))
+	callbackReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = httptest.NewRecorder()
+	op.RedirectHandler("https://example.com/authorize").ServeHTTP(w, callbackReq)
+
+	// Then.
+	if w.Code != http.StatusTemporaryRedirect {
+		t.Errorf("status code = %d, want %d", w.Code, http.StatusTemporaryRedirect)
+	}
+
+	if w.Header().Get("Location") != "https://example.com/authorize/random_callback_id" {
+		t.Errorf("Location = %s, want the callback endpoint", w.Header().Get("Location"))
+	}
+
+	// When.
+	status, err = step(httptest.NewRecorder(), callbackReq, session)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if status != goidc.StatusSuccess {
+		t.Errorf("status = %s, want %s", status, goidc.StatusSuccess)
+	}
+
+	if session.Subject != "upstream_subject" {
+		t.Errorf("Subject = %s, want upstream_subject", session.Subject)
+	}
+}
+
+func TestAuthnStep_InvalidState(t *testing.T) {
+	// Given.
+	op := federation.UpstreamOP{}
+	step := op.AuthnStep()
+	session := &goidc.AuthnSession{CallbackID: "random_callback_id"}
+	_, _ = step(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/authorize", nil), session)
+
+	form := url.Values{}
+	form.Set("state", "invalid_state")
+	form.Set("code", "upstream_code")
+	req := httptest.NewRequest(http.MethodPost, "/authorize/random_callback_id",
+		strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	// When.
+	status, err := step(httptest.NewRecorder(), req, session)
+
+	// Then.
+	if err == nil {
+		t.Fatal("the upstream state must be validated")
+	}
+
+	if status != goidc.StatusFailure {
+		t.Errorf("status = %s, want %s", status, goidc.StatusFailure)
+	}
+}
+
+func TestAuthnStep_IDTokenLeeway(t *testing.T) {
+	// Given.
+	jwk := oidctest.PrivateRS256JWK(t, "upstream_key", goidc.KeyUsageSignature)
+	var nonce string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		now := time.Now().Unix()
+		idToken, _ := jwtutil.Sign(map[string]any{
+			"iss":   "https://upstream.com",
+			"sub":   "upstream_subject",
+			"aud":   "random_client_id",
+			"iat":   now - 600,
+			"exp":   now - 120,
+			"nonce": nonce,
+		}, jwk, (&jose.SignerOptions{}).WithHeader("kid", jwk.KeyID))
+		_ = json.NewEncoder(w).Encode(map[string]any{"id_token": idToken})
+	}))
+	defer server.Close()
+
+	testCases := []struct {
+		name      string
+		leeway    time.Duration
+		wantError bool
+	}{
+		{
+			name:      "default leeway",
+			wantError: true,
+		},
+		{
+			name:   "custom leeway",
+			leeway: 5 * time.Minute,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			op := federation.UpstreamOP{
+				Issuer:                "https://upstream.com",
+				AuthorizationEndpoint: "https://upstream.com/authorize",
+				TokenEndpoint:         server.URL,
+				ClientID:              "random_client_id",
+				RedirectURI:           "https://example.com/federation/callback",
+				JWKS:                  jose.JSONWebKeySet{Keys: []jose.JSONWebKey{jwk.Public()}},
+				IDTokenLeeway:         testCase.leeway,
+			}
+			step := op.AuthnStep()
+			session := &goidc.AuthnSession{CallbackID: "random_callback_id"}
+
+			w := httptest.NewRecorder()
+			_, _ = step(w, httptest.NewRequest(http.MethodGet, "/authorize", nil), session)
+			redirectURL, _ := url.Parse(w.Header().Get("Location"))
+			nonce = redirectURL.Query().Get("nonce")
+
+			form := url.Values{}
+			form.Set("state", redirectURL.Query().Get("state"))
+			form.Set("code", "upstream_code")
+			req := httptest.NewRequest(http.MethodPost, "/authorize/random_callback_id",
+				strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+			// When.
+			_, err := step(httptest.NewRecorder(), req, session)
+
+			// Then.
+			if (err != nil) != testCase.wantError {
+				t.Errorf("err = %v, want error = %t", err, testCase.wantError)
+			}
+		})
+	}
+}
@@ -2,6 +2,8 @@ package goidc
 
 import (
 	"context"
+	"slices"
+	"strings"
 
 	"github.com/luikyv/go-oidc/internal/timeutil"
 )
@@ -30,11 +32,18 @@ type AuthnSession struct {
 	// CallbackID is the id used to fetch the authentication session after user
 	// interaction during calls to the callback endpoint.
 	CallbackID string `json:"callback_id"`
+	// CallbackBinding is the value of the cookie set in the user agent that
+	// started the authentication. When informed, calls to the callback endpoint
+	// must present it.
+	CallbackBinding string `json:"callback_binding,omitempty"`
 	// PolicyID is the id of the autentication policy used to authenticate
 	// the user.
 	PolicyID           string `json:"policy_id"`
 	ExpiresAtTimestamp int    `json:"expires_at"`
 	CreatedAtTimestamp int    `json:"created_at"`
+	// MaxExpiresAtTimestamp is the latest the session can be kept alive until
+	// with ExtendExpiry. Zero means no limit.
+	MaxExpiresAtTimestamp int `json:"max_expires_at,omitempty"`
 	// Subject is the user identifier.
 	//
 	// This value must be informed during the authentication flow.
@@ -51,11 +60,30 @@ type AuthnSession struct {
 	IDTokenHintClaims  map[string]any        `json:"id_token_hint_claim,omitempty"`
 	// ProtectedParameters contains custom parameters sent by PAR.
 	ProtectedParameters map[string]any `json:"protected_params,omitempty"`
+	// ClientCertThumbprint is the thumbprint of the certificate used by the
+	// client to push the authorization request, if the request is bound to
+	// it. The authorization code can then only be exchanged with the same
+	// certificate.
+	ClientCertThumbprint string `json:"client_cert_thumbprint,omitempty"`
 	// Store allows storing information between user interactions.
 	Store                    map[string]any `json:"store,omitempty"`
 	AdditionalTokenClaims    map[string]any `json:"additional_token_claims,omitempty"`
 	AdditionalIDTokenClaims  map[string]any `json:"additional_id_token_claims,omitempty"`
 	AdditionalUserInfoClaims map[string]any `json:"additional_user_info_claims,omitempty"`
+	// ScopeInfos holds the human readable information of the scopes
+	// requested, in the order they were requested. Scopes not known by the
+	// server are not included.
+	ScopeInfos []ScopeInfo `json:"scope_infos,omitempty"`
+	// Accounts are the users already authenticated in the user agent, from
+	// the most to the least recently used. They are only loaded when user
+	// sessions are enabled.
+	Accounts []Account `json:"accounts,omitempty"`
+	// SessionID identifies the session of the user at the provider and is
+	// returned in the sid claim of ID tokens. Policies can set it, e.g. to the
+	// ID of a session managed by the application. Otherwise, it is generated
+	// once the flow finishes, if the sid claim is enabled, and shared by all
+	// the flows of the same user session.
+	SessionID string `json:"sid,omitempty"`
 	AuthorizationParameters
 }
 
@@ -75,6 +103,19 @@ func (s *AuthnSession) Parameter(key string) any {
 	return s.Store[key]
 }
 
+// authnStep returns the index of the current step of a sequential policy.
+// The value may be a float64 if the session was decoded from JSON.
+func (s *AuthnSession) authnStep() int {
+	switch step := s.Parameter(authnStepParam).(type) {
+	case int:
+		return step
+	case float64:
+		return int(step)
+	default:
+		return 0
+	}
+}
+
 func (s *AuthnSession) SetTokenClaim(claim string, value any) {
 	if s.AdditionalTokenClaims == nil {
 		s.AdditionalTokenClaims = make(map[string]any)
@@ -82,6 +123,56 @@ func (s *AuthnSession) SetTokenClaim(claim string, value any) {
 	s.AdditionalTokenClaims[claim] = value
 }
 
+// RequestedACRs returns the ACRs requested by the client in order of
+// preference, either with the acr_values parameter or with the acr claim of
+// the claims parameter.
+func (s *AuthnSession) RequestedACRs() []ACR {
+	var acrs []ACR
+	for _, acr := range strings.Fields(s.ACRValues) {
+		acrs = append(acrs, ACR(acr))
+	}
+
+	return appendACRs(acrs, s.acrClaimValues(false)...)
+}
+
+// EssentialACRs returns the ACRs the client requested as essential with the
+// claims parameter. If they are informed, the user must authenticate with one
+// of them.
+func (s *AuthnSession) EssentialACRs() []ACR {
+	return s.acrClaimValues(true)
+}
+
+func (s *AuthnSession) acrClaimValues(essentialOnly bool) []ACR {
+	if s.Claims == nil {
+		return nil
+	}
+
+	var acrs []ACR
+	for _, claim := range []map[string]ClaimObjectInfo{s.Claims.IDToken, s.Claims.UserInfo} {
+		info, ok := claim[ClaimACR]
+		if !ok || (essentialOnly && !info.IsEssential) {
+			continue
+		}
+
+		if info.Value != "" {
+			acrs = appendACRs(acrs, ACR(info.Value))
+		}
+		for _, value := range info.Values {
+			acrs = appendACRs(acrs, ACR(value))
+		}
+	}
+	return acrs
+}
+
+func appendACRs(acrs []ACR, values ...ACR) []ACR {
+	for _, acr := range values {
+		if !slices.Contains(acrs, acr) {
+			acrs = append(acrs, acr)
+		}
+	}
+	return acrs
+}
+
 func (s *AuthnSession) SetIDTokenClaimACR(acr ACR) {
 	s.SetIDTokenClaim(ClaimACR, acr)
 }
@@ -94,6 +185,42 @@ func (s *AuthnSession) SetIDTokenClaimAMR(amrs ...AMR) {
 	s.SetIDTokenClaim(ClaimAMR, amrs)
 }
 
+// AddIDTokenClaimAMR appends the authentication methods to the ones already
+// set for the ID token, so each step of a multi step policy can record the
+// methods it used.
+func (s *AuthnSession) AddIDTokenClaimAMR(amrs ...AMR) {
+	current := s.IDTokenClaimAMRs()
+	for _, amr := range amrs {
+		if !slices.Contains(current, amr) {
+			current = append(current, amr)
+		}
+	}
+	s.SetIDTokenClaimAMR(current...)
+}
+
+// IDTokenClaimAMRs returns the authentication methods set for the ID token.
+func (s *AuthnSession) IDTokenClaimAMRs() []AMR {
+	switch amrs := s.AdditionalIDTokenClaims[ClaimAMR].(type) {
+	case []AMR:
+		return slices.Clone(amrs)
+	// The session may have been decoded from JSON by the storage.
+	case []any:
+		var values []AMR
+		for _, amr := range amrs {
+			if v, ok := amr.(string); ok {
+				values = append(values, AMR(v))
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
+
+func (s *AuthnSession) SetIDTokenAddress(address AddressClaim) {
+	s.SetIDTokenClaim(ClaimAddress, address)
+}
+
 // SetIDTokenClaim sets a claim that will be accessible in the ID token.
 func (s *AuthnSession) SetIDTokenClaim(claim string, value any) {
 	if s.AdditionalIDTokenClaims == nil {
@@ -114,6 +241,10 @@ func (s *AuthnSession) SetUserInfoClaimAMR(amrs ...AMR) {
 	s.SetUserInfoClaim(ClaimAMR, amrs)
 }
 
+func (s *AuthnSession) SetUserInfoAddress(address AddressClaim) {
+	s.SetUserInfoClaim(ClaimAddress, address)
+}
+
 // SetUserInfoClaim sets a claim that will be accessible via the user info endpoint.
 func (s *AuthnSession) SetUserInfoClaim(claim string, value any) {
 	if s.AdditionalUserInfoClaims == nil {
@@ -122,6 +253,90 @@ func (s *AuthnSession) SetUserInfoClaim(claim string, value any) {
 	s.AdditionalUserInfoClaims[claim] = value
 }
 
+// SetIDTokenAggregatedClaims references claims asserted by another claims
+// provider in the ID token. claimsJWT is the JWT signed by the claims provider
+// containing the claims informed.
+func (s *AuthnSession) SetIDTokenAggregatedClaims(source, claimsJWT string, claims ...string) {
+	s.AdditionalIDTokenClaims = setClaimSource(s.AdditionalIDTokenClaims, source,
+		map[string]any{"JWT": claimsJWT}, claims)
+}
+
+// SetIDTokenDistributedClaims references claims in the ID token that can be
+// retrieved from the endpoint of another claims provider.
+// accessToken is optional and is the token the client must present to the
+// endpoint.
+func (s *AuthnSession) SetIDTokenDistributedClaims(
+	source string,
+	endpoint string,
+	accessToken string,
+	claims ...string,
+) {
+	s.AdditionalIDTokenClaims = setClaimSource(s.AdditionalIDTokenClaims, source,
+		distributedClaimSource(endpoint, accessToken), claims)
+}
+
+// SetUserInfoAggregatedClaims references claims asserted by another claims
+// provider in the user info response. claimsJWT is the JWT signed by the claims
+// provider containing the claims informed.
+func (s *AuthnSession) SetUserInfoAggregatedClaims(source, claimsJWT string, claims ...string) {
+	s.AdditionalUserInfoClaims = setClaimSource(s.AdditionalUserInfoClaims, source,
+		map[string]any{"JWT": claimsJWT}, claims)
+}
+
+// SetUserInfoDistributedClaims references claims in the user info response
+// that can be retrieved from the endpoint of another claims provider.
+// accessToken is optional and is the token the client must present to the
+// endpoint.
+func (s *AuthnSession) SetUserInfoDistributedClaims(
+	source string,
+	endpoint string,
+	accessToken string,
+	claims ...string,
+) {
+	s.AdditionalUserInfoClaims = setClaimSource(s.AdditionalUserInfoClaims, source,
+		distributedClaimSource(endpoint, accessToken), claims)
+}
+
+func distributedClaimSource(endpoint, accessToken string) map[string]any {
+	claimSource := map[string]any{"endpoint": endpoint}
+	if accessToken != "" {
+		claimSource["access_token"] = accessToken
+	}
+	return claimSource
+}
+
+// setClaimSource adds the source to the "_claim_sources" claim and points the
+// claim names to it in the "_claim_names" claim as defined by OpenID Connect
+// Core, section 5.6.2.
+func setClaimSource(
+	claims map[string]any,
+	source string,
+	claimSource map[string]any,
+	claimNames []string,
+) map[string]any {
+	if claims == nil {
+		claims = make(map[string]any)
+	}
+
+	names, ok := claims[ClaimNames].(map[string]any)
+	if !ok {
+		names = make(map[string]any)
+	}
+	for _, name := range claimNames {
+		names[name] = source
+	}
+	claims[ClaimNames] = names
+
+	sources, ok := claims[ClaimSources].(map[string]any)
+	if !ok {
+		sources = make(map[string]any)
+	}
+	sources[source] = claimSource
+	claims[ClaimSources] = sources
+
+	return claims
+}
+
 // GrantScopes sets the scopes the client will have access to.
 func (s *AuthnSession) GrantScopes(scopes string) {
 	s.GrantedScopes = scopes
@@ -138,6 +353,133 @@ func (s *AuthnSession) GrantResources(resources []string) {
 	s.GrantedResources = resources
 }
 
+// ScopeParams returns the parameters of the structured scope requested by the
+// client, if any.
+func (s *AuthnSession) ScopeParams(scope Scope) (map[string]any, bool) {
+	return scope.Params(s.Scopes)
+}
+
+// IDTokenHintSubject returns the subject of the ID token sent by the client as
+// id_token_hint.
+// The signature of the ID token hint is validated against the server keys
+// before the session is created, so the subject can be used to identify the
+// user.
+func (s *AuthnSession) IDTokenHintSubject() (string, bool) {
+	sub, ok := s.IDTokenHintClaims[ClaimSubject].(string)
+	if !ok || sub == "" {
+		return "", false
+	}
+	return sub, true
+}
+
+// NormalizedLoginHint returns the login_hint sent by the client in a canonical
+// form, so it can be compared with user identifiers.
+// Surrounding spaces and the "acct:" and "mailto:" prefixes are removed and
+// email addresses are lower cased.
+func (s *AuthnSession) NormalizedLoginHint() string {
+	hint := strings.TrimSpace(s.LoginHint)
+	for _, prefix := range []string{"acct:", "mailto:"} {
+		hint = strings.TrimPrefix(hint, prefix)
+	}
+
+	if strings.Contains(hint, "@") {
+		hint = strings.ToLower(hint)
+	}
+	return hint
+}
+
+// MustReauthenticate returns whether the user must be authenticated again even
+// if there is already an active user session, i.e. when the client sent
+// prompt=login or max_age=0.
+func (s *AuthnSession) MustReauthenticate() bool {
+	return s.Prompt == PromptTypeLogin ||
+		(s.MaxAuthnAgeSecs != nil && *s.MaxAuthnAgeSecs == 0)
+}
+
+// IsAuthnTooOld returns whether an authentication that happened at authTime
+// is older than the max_age requested by the client.
+func (s *AuthnSession) IsAuthnTooOld(authTime int) bool {
+	if s.MaxAuthnAgeSecs == nil {
+		return false
+	}
+
+	return timeutil.TimestampNow() > authTime+*s.MaxAuthnAgeSecs
+}
+
+// SelectAccount identifies the user as the account informed, which must be
+// one of the accounts of the session. The auth_time, amr and acr claims of the
+// ID token are set with the values recorded when the account authenticated.
+// It returns false if no account matches the subject.
+func (s *AuthnSession) SelectAccount(subject string) bool {
+	i := slices.IndexFunc(s.Accounts, func(a Account) bool {
+		return a.Subject == subject
+	})
+	if i == -1 {
+		return false
+	}
+
+	account := s.Accounts[i]
+	s.SetUserID(account.Subject)
+	s.SetIDTokenClaimAuthTime(account.AuthTime)
+	if len(account.AMRs) != 0 {
+		s.SetIDTokenClaimAMR(account.AMRs...)
+	}
+	if account.ACR != "" {
+		s.SetIDTokenClaimACR(account.ACR)
+	}
+	return true
+}
+
+// MustSelectAccount returns whether the user must be asked to choose an
+// account even if one could be selected automatically, i.e. when the client
+// sent prompt=select_account.
+func (s *AuthnSession) MustSelectAccount() bool {
+	return s.Prompt == PromptTypeSelectAccount
+}
+
+// HintedAccount returns the account identified by the id_token_hint or the
+// login_hint sent by the client, if it is one of the accounts of the session.
+func (s *AuthnSession) HintedAccount() (Account, bool) {
+	hints := []string{s.NormalizedLoginHint()}
+	if sub, ok := s.IDTokenHintSubject(); ok {
+		hints = []string{sub}
+	}
+
+	for _, account := range s.Accounts {
+		if slices.Contains(hints, account.Subject) {
+			return account, true
+		}
+	}
+	return Account{}, false
+}
+
+// MustPromptConsent returns whether the user must be asked for consent even if
+// it was granted before, i.e. when the client sent prompt=consent.
+func (s *AuthnSession) MustPromptConsent() bool {
+	return s.Prompt == PromptTypeConsent
+}
+
+// IsInteractionAllowed returns whether the user can be prompted during the
+// authentication, which is not the case when the client sent prompt=none.
+func (s *AuthnSession) IsInteractionAllowed() bool {
+	return s.Prompt != PromptTypeNone
+}
+
+// ExtendExpiry keeps the session alive for at least secs seconds from now, but
+// not past MaxExpiresAtTimestamp.
+// This allows pausing the authentication while waiting for out of band steps,
+// e.g. the user clicking on an email verification link. The session can be
+// resumed later with its callback ID.
+func (s *AuthnSession) ExtendExpiry(secs int) {
+	expiresAt := timeutil.TimestampNow() + secs
+	if s.MaxExpiresAtTimestamp != 0 && expiresAt > s.MaxExpiresAtTimestamp {
+		expiresAt = s.MaxExpiresAtTimestamp
+	}
+	if expiresAt > s.ExpiresAtTimestamp {
+		s.ExpiresAtTimestamp = expiresAt
+	}
+}
+
 func (s *AuthnSession) IsExpired() bool {
 	return timeutil.TimestampNow() >= s.ExpiresAtTimestamp
 }
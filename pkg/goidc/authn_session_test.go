@@ -1,8 +1,10 @@
 package goidc_test
 
 import (
+	"encoding/json"
 	"testing"
 
+	"github.com/google/go-cmp/cmp"
 	"github.com/luikyv/go-oidc/internal/timeutil"
 	"github.com/luikyv/go-oidc/pkg/goidc"
 )
@@ -49,6 +51,25 @@ func TestAddIDTokenClaim(t *testing.T) {
 	}
 }
 
+func TestAddIDTokenClaimAMR(t *testing.T) {
+	// Given.
+	session := goidc.AuthnSession{}
+	session.SetIDTokenClaimAMR(goidc.AMRPassword)
+	// Simulate a session decoded from JSON by the storage.
+	data, _ := json.Marshal(session)
+	session = goidc.AuthnSession{}
+	_ = json.Unmarshal(data, &session)
+
+	// When.
+	session.AddIDTokenClaimAMR(goidc.AMRPassword, goidc.AMROneTimePassoword)
+
+	// Then.
+	want := []goidc.AMR{goidc.AMRPassword, goidc.AMROneTimePassoword}
+	if diff := cmp.Diff(session.IDTokenClaimAMRs(), want); diff != "" {
+		t.Error(diff)
+	}
+}
+
 func TestAddUserInfoClaim(t *testing.T) {
 	for i := 0; i < 2; i++ {
 		// Given.
@@ -63,6 +84,76 @@ func TestAddUserInfoClaim(t *testing.T) {
 	}
 }
 
+func TestSetUserInfoAddress(t *testing.T) {
+	// Given.
+	session := goidc.AuthnSession{}
+	address := goidc.AddressClaim{
+		StreetAddress: "1234 Hollywood Blvd.",
+		Locality:      "Los Angeles",
+		Country:       "US",
+	}
+
+	// When.
+	session.SetUserInfoAddress(address)
+
+	// Then.
+	claim, err := json.Marshal(session.AdditionalUserInfoClaims[goidc.ClaimAddress])
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := `{"street_address":"1234 Hollywood Blvd.","locality":"Los Angeles","country":"US"}`
+	if string(claim) != want {
+		t.Errorf("address = %s, want %s", claim, want)
+	}
+}
+
+func TestSetUserInfoAggregatedAndDistributedClaims(t *testing.T) {
+	// Given.
+	session := goidc.AuthnSession{}
+
+	// When.
+	session.SetUserInfoAggregatedClaims("src1", "random_jwt", "address", "phone_number")
+	session.SetUserInfoDistributedClaims("src2", "https://example.com/claims", "random_token",
+		"credit_score")
+
+	// Then.
+	want := map[string]any{
+		goidc.ClaimNames: map[string]any{
+			"address":      "src1",
+			"phone_number": "src1",
+			"credit_score": "src2",
+		},
+		goidc.ClaimSources: map[string]any{
+			"src1": map[string]any{"JWT": "random_jwt"},
+			"src2": map[string]any{
+				"endpoint":     "https://example.com/claims",
+				"access_token": "random_token",
+			},
+		},
+	}
+	if diff := cmp.Diff(session.AdditionalUserInfoClaims, want); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestSetIDTokenDistributedClaims(t *testing.T) {
+	// Given.
+	session := goidc.AuthnSession{}
+
+	// When.
+	session.SetIDTokenDistributedClaims("src1", "https://example.com/claims", "", "payment_info")
+
+	// Then.
+	want := map[string]any{
+		goidc.ClaimNames:   map[string]any{"payment_info": "src1"},
+		goidc.ClaimSources: map[string]any{"src1": map[string]any{"endpoint": "https://example.com/claims"}},
+	}
+	if diff := cmp.Diff(session.AdditionalIDTokenClaims, want); diff != "" {
+		t.Error(diff)
+	}
+}
+
 func TestIsExpired(t *testing.T) {
 	// Given.
 	now := timeutil.TimestampNow()
@@ -74,3 +165,236 @@ func TestIsExpired(t *testing.T) {
 		t.Errorf("IsExpired() = %t, want true", session.IsExpired())
 	}
 }
+
+func TestMustReauthenticate(t *testing.T) {
+	// Given.
+	maxAge := 0
+	testCases := []struct {
+		session goidc.AuthnSession
+		want    bool
+	}{
+		{goidc.AuthnSession{}, false},
+		{
+			goidc.AuthnSession{
+				AuthorizationParameters: goidc.AuthorizationParameters{
+					Prompt: goidc.PromptTypeLogin,
+				},
+			},
+			true,
+		},
+		{
+			goidc.AuthnSession{
+				AuthorizationParameters: goidc.AuthorizationParameters{
+					MaxAuthnAgeSecs: &maxAge,
+				},
+			},
+			true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		// When.
+		got := testCase.session.MustReauthenticate()
+		// Then.
+		if got != testCase.want {
+			t.Errorf("MustReauthenticate() = %t, want %t", got, testCase.want)
+		}
+	}
+}
+
+func TestIsAuthnTooOld(t *testing.T) {
+	// Given.
+	maxAge := 60
+	session := goidc.AuthnSession{
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			MaxAuthnAgeSecs: &maxAge,
+		},
+	}
+	now := timeutil.TimestampNow()
+
+	// Then.
+	if session.IsAuthnTooOld(now - 30) {
+		t.Error("the authentication should not be too old")
+	}
+
+	if !session.IsAuthnTooOld(now - 120) {
+		t.Error("the authentication should be too old")
+	}
+
+	if (&goidc.AuthnSession{}).IsAuthnTooOld(0) {
+		t.Error("the authentication cannot be too old if max_age is not informed")
+	}
+}
+
+func TestRequestedACRs(t *testing.T) {
+	// Given.
+	session := goidc.AuthnSession{
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			ACRValues: "urn:mace:incommon:iap:silver 0",
+			Claims: &goidc.ClaimsObject{
+				IDToken: map[string]goidc.ClaimObjectInfo{
+					goidc.ClaimACR: {
+						IsEssential: true,
+						Values:      []string{"urn:mace:incommon:iap:bronze", "0"},
+					},
+				},
+			},
+		},
+	}
+
+	// Then.
+	if diff := cmp.Diff(session.RequestedACRs(), []goidc.ACR{
+		goidc.ACRMaceIncommonIAPSilver,
+		goidc.ACRNoAssuranceLevel,
+		goidc.ACRMaceIncommonIAPBronze,
+	}); diff != "" {
+		t.Error(diff)
+	}
+
+	if diff := cmp.Diff(session.EssentialACRs(), []goidc.ACR{
+		goidc.ACRMaceIncommonIAPBronze,
+		goidc.ACRNoAssuranceLevel,
+	}); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestIDTokenHintSubject(t *testing.T) {
+	// Given.
+	session := goidc.AuthnSession{
+		IDTokenHintClaims: map[string]any{
+			goidc.ClaimSubject: "random_subject",
+		},
+	}
+
+	// When.
+	sub, ok := session.IDTokenHintSubject()
+
+	// Then.
+	if !ok {
+		t.Fatal("the subject should be found")
+	}
+
+	if sub != "random_subject" {
+		t.Errorf("IDTokenHintSubject() = %s, want random_subject", sub)
+	}
+
+	if _, ok := (&goidc.AuthnSession{}).IDTokenHintSubject(); ok {
+		t.Error("no subject should be found without an id token hint")
+	}
+}
+
+func TestNormalizedLoginHint(t *testing.T) {
+	testCases := []struct {
+		hint string
+		want string
+	}{
+		{" User@Example.com ", "user@example.com"},
+		{"mailto:User@Example.com", "user@example.com"},
+		{"acct:User@Example.com", "user@example.com"},
+		{"UserName", "UserName"},
+	}
+
+	for _, testCase := range testCases {
+		// Given.
+		session := goidc.AuthnSession{
+			AuthorizationParameters: goidc.AuthorizationParameters{
+				LoginHint: testCase.hint,
+			},
+		}
+
+		// When.
+		got := session.NormalizedLoginHint()
+
+		// Then.
+		if got != testCase.want {
+			t.Errorf("NormalizedLoginHint() = %s, want %s", got, testCase.want)
+		}
+	}
+}
+
+func TestExtendExpiry(t *testing.T) {
+	// Given.
+	now := timeutil.TimestampNow()
+	session := goidc.AuthnSession{
+		ExpiresAtTimestamp: now + 60,
+	}
+
+	// When.
+	session.ExtendExpiry(3600)
+
+	// Then.
+	if session.ExpiresAtTimestamp != now+3600 {
+		t.Errorf("ExpiresAtTimestamp = %d, want %d", session.ExpiresAtTimestamp, now+3600)
+	}
+
+	// A shorter extension must not reduce the expiry.
+	session.ExtendExpiry(10)
+	if session.ExpiresAtTimestamp != now+3600 {
+		t.Errorf("ExpiresAtTimestamp = %d, want %d", session.ExpiresAtTimestamp, now+3600)
+	}
+}
+
+func TestExtendExpiry_MaxExpiry(t *testing.T) {
+	// Given.
+	now := timeutil.TimestampNow()
+	session := goidc.AuthnSession{
+		ExpiresAtTimestamp:    now + 60,
+		MaxExpiresAtTimestamp: now + 600,
+	}
+
+	// When.
+	session.ExtendExpiry(3600)
+
+	// Then.
+	if session.ExpiresAtTimestamp != now+600 {
+		t.Errorf("ExpiresAtTimestamp = %d, want %d", session.ExpiresAtTimestamp, now+600)
+	}
+}
+
+func TestSelectAccount(t *testing.T) {
+	// Given.
+	session := goidc.AuthnSession{
+		Accounts: []goidc.Account{
+			{Subject: "random_subject", AuthTime: 10, AMRs: []goidc.AMR{goidc.AMRPassword}},
+		},
+	}
+
+	// When.
+	ok := session.SelectAccount("random_subject")
+
+	// Then.
+	if !ok {
+		t.Fatal("the account should be selected")
+	}
+
+	if session.Subject != "random_subject" {
+		t.Errorf("Subject = %s, want random_subject", session.Subject)
+	}
+
+	if session.AdditionalIDTokenClaims[goidc.ClaimAuthTime] != 10 {
+		t.Errorf("auth_time = %v, want 10", session.AdditionalIDTokenClaims[goidc.ClaimAuthTime])
+	}
+
+	if session.SelectAccount("unknown_subject") {
+		t.Error("unknown accounts must not be selected")
+	}
+}
+
+func TestHintedAccount(t *testing.T) {
+	// Given.
+	session := goidc.AuthnSession{
+		Accounts: []goidc.Account{{Subject: "user@example.com"}, {Subject: "random_subject"}},
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			LoginHint: "mailto:User@Example.com",
+		},
+	}
+
+	// When.
+	account, ok := session.HintedAccount()
+
+	// Then.
+	if !ok || account.Subject != "user@example.com" {
+		t.Errorf("HintedAccount() = %v, %t, want user@example.com", account, ok)
+	}
+}
@@ -4,10 +4,15 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
 
 	"github.com/go-jose/go-jose/v4"
+	"github.com/luikyv/go-oidc/internal/timeutil"
 )
 
 // ClientManager gathers all the logic needed to manage clients.
@@ -17,6 +22,146 @@ type ClientManager interface {
 	Delete(ctx context.Context, id string) error
 }
 
+// ClientAuthnFunc validates the credentials sent by a client registered with a
+// custom authentication method, e.g. attest_jwt_client_auth.
+// The client is identified by the usual means, e.g. the client_id parameter,
+// before the function runs. If an error is returned, the client is not
+// authenticated.
+type ClientAuthnFunc func(r *http.Request, client *Client) error
+
+// BeforeClientAuthnFunc defines a function that is executed before the
+// credentials of a client are validated. failures is the number of
+// consecutive failed authentications of the client from the same address.
+// If an error is returned, the authentication fails with it, e.g. an error with
+// code [ErrorCodeSlowDown] when the client is temporarily locked.
+type BeforeClientAuthnFunc func(r *http.Request, clientID string, failures int) error
+
+// AfterClientAuthnFunc defines a function that is executed with the result of
+// the authentication of a registered client. err is nil if the client was
+// authenticated, in which case failures is zero. Otherwise, failures is the
+// number of consecutive failed authentications of the client from the same
+// address, including this one.
+type AfterClientAuthnFunc func(r *http.Request, clientID string, failures int, err error)
+
+// ClientAuthnThrottler locks clients for a while after every maxFailures
+// consecutive authentication failures from the same address.
+// Locks are kept in memory, so each instance of the provider applies them
+// separately.
+//
+//	throttler := goidc.NewClientAuthnThrottler(5, 300)
+//	provider.WithClientAuthnHooks(throttler.BeforeAuthn, throttler.AfterAuthn)
+type ClientAuthnThrottler struct {
+	// ClientIPFunc returns the address the failures are attributed to, so a
+	// client cannot be locked by requests sent from elsewhere. If nil, the
+	// remote address of the request is used. When the provider runs behind a
+	// proxy, it should be the same function informed to the provider.
+	ClientIPFunc ClientIPFunc
+	maxFailures  int
+	lockSecs     int
+	mu           sync.Mutex
+	// locks maps the clients locked to the timestamp until when they are
+	// locked.
+	locks map[clientAuthnLock]int
+}
+
+// clientAuthnLock identifies a client locked for an address.
+type clientAuthnLock struct {
+	clientID string
+	address  netip.Addr
+}
+
+// NewClientAuthnThrottler creates a throttler that locks a client for lockSecs
+// after maxFailures consecutive authentication failures.
+func NewClientAuthnThrottler(maxFailures, lockSecs int) *ClientAuthnThrottler {
+	return &ClientAuthnThrottler{
+		maxFailures: maxFailures,
+		lockSecs:    lockSecs,
+		locks:       map[clientAuthnLock]int{},
+	}
+}
+
+// BeforeAuthn implements [BeforeClientAuthnFunc].
+func (t *ClientAuthnThrottler) BeforeAuthn(r *http.Request, clientID string, _ int) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.locks[t.lock(r, clientID)] > timeutil.TimestampNow() {
+		return NewError(ErrorCodeSlowDown, "too many failed authentication attempts")
+	}
+	return nil
+}
+
+// AfterAuthn implements [AfterClientAuthnFunc].
+func (t *ClientAuthnThrottler) AfterAuthn(r *http.Request, clientID string, failures int, err error) {
+	if err == nil || failures == 0 || failures%t.maxFailures != 0 {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := timeutil.TimestampNow()
+	for lock, lockedUntil := range t.locks {
+		if lockedUntil <= now {
+			delete(t.locks, lock)
+		}
+	}
+	t.locks[t.lock(r, clientID)] = now + t.lockSecs
+}
+
+func (t *ClientAuthnThrottler) lock(r *http.Request, clientID string) clientAuthnLock {
+	lock := clientAuthnLock{clientID: clientID}
+	if t.ClientIPFunc != nil {
+		lock.address, _ = t.ClientIPFunc(r)
+		return lock
+	}
+
+	if addrPort, err := netip.ParseAddrPort(r.RemoteAddr); err == nil {
+		lock.address = addrPort.Addr().Unmap()
+	}
+	return lock
+}
+
+// ClientLister is implemented by client managers that can enumerate the
+// clients they store. It is required for the administrative client API.
+type ClientLister interface {
+	List(ctx context.Context, filter ClientFilter) ([]*Client, error)
+}
+
+// ClientFilter defines which clients are returned when listing them.
+// Zero values mean no filtering.
+type ClientFilter struct {
+	OwnerID string
+	Status  ClientStatus
+	// Offset is the number of matching clients to skip.
+	Offset int
+	// Limit is the maximum number of clients to return.
+	Limit int
+}
+
+// Matches returns whether the client satisfies the filter, without
+// considering pagination.
+func (f ClientFilter) Matches(c *Client) bool {
+	if f.OwnerID != "" && c.OwnerID != f.OwnerID {
+		return false
+	}
+
+	if f.Status != "" && c.status() != f.Status {
+		return false
+	}
+
+	return true
+}
+
+type ClientStatus string
+
+const (
+	ClientStatusActive ClientStatus = "active"
+	// ClientStatusDisabled prevents the client from being used, while keeping
+	// its information.
+	ClientStatusDisabled ClientStatus = "disabled"
+)
+
 // Client contains all information about an OAuth client.
 type Client struct {
 	ID string `json:"client_id"`
@@ -29,6 +174,15 @@ type Client struct {
 	// HashedRegistrationAccessToken is the hash of the registration access token
 	// generated during dynamic client registration.
 	HashedRegistrationAccessToken string `json:"hashed_registration_access_token"`
+	// OwnerID identifies who manages the client, e.g. a developer account of
+	// a back office.
+	OwnerID string `json:"owner_id,omitempty"`
+	// Status is the client status. An empty status is considered active.
+	Status ClientStatus `json:"status,omitempty"`
+	// BrandingIsVerified indicates that logo_uri, client_uri, policy_uri and
+	// tos_uri were verified to be reachable when the client was last
+	// registered or updated.
+	BrandingIsVerified bool `json:"branding_is_verified,omitempty"`
 	ClientMetaInfo
 }
 
@@ -36,6 +190,21 @@ func (c *Client) IsPublic() bool {
 	return c.TokenAuthnMethod == ClientAuthnNone
 }
 
+// ErrClientDisabled is returned when loading a client whose status is
+// [ClientStatusDisabled].
+var ErrClientDisabled = errors.New("the client is disabled")
+
+func (c *Client) IsDisabled() bool {
+	return c.Status == ClientStatusDisabled
+}
+
+func (c *Client) status() ClientStatus {
+	if c.Status == "" {
+		return ClientStatusActive
+	}
+	return c.Status
+}
+
 // FetchPublicJWKS fetches the client public JWKS either directly from the jwks
 // attribute or using jwks_uri.
 //
@@ -44,8 +213,10 @@ func (c *Client) FetchPublicJWKS(httpClient *http.Client) (jose.JSONWebKeySet, e
 	var jwks jose.JSONWebKeySet
 
 	if c.PublicJWKS != nil {
-		err := json.Unmarshal(c.PublicJWKS, &jwks)
-		return jwks, err
+		if err := json.Unmarshal(c.PublicJWKS, &jwks); err != nil {
+			return jose.JSONWebKeySet{}, fmt.Errorf("the client jwks is invalid: %w", err)
+		}
+		return jwks, nil
 	}
 
 	if c.PublicJWKSURI == "" {
@@ -60,32 +231,42 @@ func (c *Client) FetchPublicJWKS(httpClient *http.Client) (jose.JSONWebKeySet, e
 	// Cache the client JWKS.
 	c.PublicJWKS = rawJWKS
 
-	err = json.Unmarshal(c.PublicJWKS, &jwks)
-	return jwks, err
+	if err := json.Unmarshal(c.PublicJWKS, &jwks); err != nil {
+		return jose.JSONWebKeySet{}, fmt.Errorf("the client jwks fetched from jwks_uri is invalid: %w", err)
+	}
+	return jwks, nil
 }
 
 func (c *Client) fetchJWKS(httpClient *http.Client) (json.RawMessage, error) {
 	resp, err := httpClient.Get(c.PublicJWKSURI)
-	if err != nil || resp.StatusCode != http.StatusOK {
-		return nil, errors.New("could not fetch client jwks")
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch the client jwks: %w", err)
 	}
-
 	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("could not fetch the client jwks: jwks_uri responded with status %d", resp.StatusCode)
+	}
+
 	return io.ReadAll(resp.Body)
 }
 
 type ClientMetaInfo struct {
-	Name          string          `json:"client_name,omitempty"`
-	LogoURI       string          `json:"logo_uri,omitempty"`
-	RedirectURIs  []string        `json:"redirect_uris,omitempty"`
-	RequestURIs   []string        `json:"request_uris,omitempty"`
-	GrantTypes    []GrantType     `json:"grant_types"`
-	ResponseTypes []ResponseType  `json:"response_types"`
-	PublicJWKSURI string          `json:"jwks_uri,omitempty"`
-	PublicJWKS    json.RawMessage `json:"jwks,omitempty"`
+	Name              string          `json:"client_name,omitempty"`
+	LogoURI           string          `json:"logo_uri,omitempty"`
+	ClientURI         string          `json:"client_uri,omitempty"`
+	PolicyURI         string          `json:"policy_uri,omitempty"`
+	TermsOfServiceURI string          `json:"tos_uri,omitempty"`
+	RedirectURIs      []string        `json:"redirect_uris,omitempty"`
+	RequestURIs       []string        `json:"request_uris,omitempty"`
+	GrantTypes        []GrantType     `json:"grant_types"`
+	ResponseTypes     []ResponseType  `json:"response_types"`
+	PublicJWKSURI     string          `json:"jwks_uri,omitempty"`
+	PublicJWKS        json.RawMessage `json:"jwks,omitempty"`
 	// ScopeIDs contains the scopes available to the client separeted by spaces.
 	ScopeIDs                      string                  `json:"scope"`
 	SubIdentifierType             SubjectIdentifierType   `json:"subject_type,omitempty"`
+	SectorIdentifierURI           string                  `json:"sector_identifier_uri,omitempty"`
 	IDTokenSigAlg                 jose.SignatureAlgorithm `json:"id_token_signed_response_alg,omitempty"`
 	IDTokenKeyEncAlg              jose.KeyAlgorithm       `json:"id_token_encrypted_response_alg,omitempty"`
 	IDTokenContentEncAlg          jose.ContentEncryption  `json:"id_token_encrypted_response_enc,omitempty"`
@@ -115,6 +296,31 @@ type ClientMetaInfo struct {
 	DefaultMaxAgeSecs         *int     `json:"default_max_age,omitempty"`
 	DefaultACRValues          string   `json:"default_acr_values,omitempty"`
 	PARIsRequired             bool     `json:"require_pushed_authorization_requests,omitempty"`
+	// AllowedCORSOrigins are the origins allowed to make cross origin requests
+	// on behalf of the client to the token and user info endpoints, e.g.
+	// "https://app.example.com".
+	AllowedCORSOrigins []string `json:"allowed_cors_origins,omitempty"`
+	// AllowedSourceCIDRs restricts the networks the client can call the token
+	// and pushed authorization endpoints from, e.g. "203.0.113.0/24".
+	// If empty, requests are accepted from any address.
+	AllowedSourceCIDRs []string `json:"allowed_source_cidrs,omitempty"`
+	// AccessTokenFormat overrides the format of the access tokens issued to
+	// the client.
+	AccessTokenFormat TokenFormat `json:"access_token_format,omitempty"`
+	// AccessTokenLifetimeSecs overrides the lifetime of the access tokens
+	// issued to the client. It cannot exceed the limit defined by the server.
+	AccessTokenLifetimeSecs int `json:"access_token_lifetime,omitempty"`
+	// RefreshTokenIsDisabled indicates that the client must not receive
+	// refresh tokens even if it is allowed the refresh token grant.
+	RefreshTokenIsDisabled bool `json:"refresh_token_disabled,omitempty"`
+	// RefreshTokenLifetimeSecs limits the lifetime of the refresh tokens issued
+	// to the client. It cannot exceed the lifetime defined by the server.
+	RefreshTokenLifetimeSecs int `json:"refresh_token_lifetime,omitempty"`
+	// LocalizedAttributes holds the human readable attributes informed for a
+	// specific language and script, e.g. "client_name#ja-Jpan-JP".
+	// The keys are the attribute name followed by "#" and the BCP47 language
+	// tag. This field is flattened for DCR responses.
+	LocalizedAttributes map[string]string `json:"localized_attributes,omitempty"`
 	// CustomAttributes holds any additional attributes a client has.
 	// This field is flattened for DCR responses.
 	CustomAttributes map[string]any `json:"custom_attributes,omitempty"`
@@ -130,3 +336,116 @@ func (c *ClientMetaInfo) SetAttribute(key string, value any) {
 func (c *ClientMetaInfo) Attribute(key string) any {
 	return c.CustomAttributes[key]
 }
+
+// StringAttribute returns the custom attribute as a string.
+func (c *ClientMetaInfo) StringAttribute(key string) (string, bool) {
+	value, ok := c.CustomAttributes[key].(string)
+	return value, ok
+}
+
+// StringsAttribute returns the custom attribute as a list of strings, e.g.
+// "software_roles".
+// It accepts values decoded from JSON, which are represented as []any.
+func (c *ClientMetaInfo) StringsAttribute(key string) ([]string, bool) {
+	switch values := c.CustomAttributes[key].(type) {
+	case []string:
+		return values, true
+	case []any:
+		strs := make([]string, 0, len(values))
+		for _, v := range values {
+			s, ok := v.(string)
+			if !ok {
+				return nil, false
+			}
+			strs = append(strs, s)
+		}
+		return strs, true
+	default:
+		return nil, false
+	}
+}
+
+// BoolAttribute returns the custom attribute as a boolean.
+func (c *ClientMetaInfo) BoolAttribute(key string) (bool, bool) {
+	value, ok := c.CustomAttributes[key].(bool)
+	return value, ok
+}
+
+// IntAttribute returns the custom attribute as an integer.
+// It accepts values decoded from JSON, which are represented as float64.
+func (c *ClientMetaInfo) IntAttribute(key string) (int, bool) {
+	switch value := c.CustomAttributes[key].(type) {
+	case int:
+		return value, true
+	case float64:
+		return int(value), true
+	default:
+		return 0, false
+	}
+}
+
+// SetLocalizedAttribute sets the value of a human readable attribute, such as
+// "client_name", for the BCP47 language tag informed.
+func (c *ClientMetaInfo) SetLocalizedAttribute(name, tag, value string) {
+	if c.LocalizedAttributes == nil {
+		c.LocalizedAttributes = make(map[string]string)
+	}
+	c.LocalizedAttributes[name+"#"+tag] = value
+}
+
+// LocalizedName returns the client name that best matches the BCP47 language
+// tag informed, falling back to the default name.
+func (c *ClientMetaInfo) LocalizedName(tag string) string {
+	return c.localizedAttribute("client_name", tag, c.Name)
+}
+
+// LocalizedLogoURI returns the logo URI that best matches the BCP47 language
+// tag informed, falling back to the default logo URI.
+func (c *ClientMetaInfo) LocalizedLogoURI(tag string) string {
+	return c.localizedAttribute("logo_uri", tag, c.LogoURI)
+}
+
+// LocalizedClientURI returns the client home page that best matches the BCP47
+// language tag informed, falling back to the default client URI.
+func (c *ClientMetaInfo) LocalizedClientURI(tag string) string {
+	return c.localizedAttribute("client_uri", tag, c.ClientURI)
+}
+
+// LocalizedPolicyURI returns the policy URI that best matches the BCP47
+// language tag informed, falling back to the default policy URI.
+func (c *ClientMetaInfo) LocalizedPolicyURI(tag string) string {
+	return c.localizedAttribute("policy_uri", tag, c.PolicyURI)
+}
+
+// LocalizedTermsOfServiceURI returns the terms of service URI that best
+// matches the BCP47 language tag informed, falling back to the default one.
+func (c *ClientMetaInfo) LocalizedTermsOfServiceURI(tag string) string {
+	return c.localizedAttribute("tos_uri", tag, c.TermsOfServiceURI)
+}
+
+// localizedAttribute looks for the value of the attribute informed for the
+// language tag. If there's no exact match, the tag is truncated from the end
+// as described in RFC 4647 section 3.4, e.g. "fr-CA" falls back to "fr".
+func (c *ClientMetaInfo) localizedAttribute(name, tag, defaultValue string) string {
+	return localizedValue(c.LocalizedAttributes, name+"#", tag, defaultValue)
+}
+
+// localizedValue looks for the value keyed by the prefix followed by the
+// language tag, truncating the tag from the end until there's a match.
+func localizedValue(values map[string]string, prefix, tag, defaultValue string) string {
+	for tag != "" {
+		for key, value := range values {
+			if strings.EqualFold(key, prefix+tag) {
+				return value
+			}
+		}
+
+		i := strings.LastIndex(tag, "-")
+		if i == -1 {
+			break
+		}
+		tag = tag[:i]
+	}
+
+	return defaultValue
+}
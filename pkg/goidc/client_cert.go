@@ -0,0 +1,125 @@
+package goidc
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	// HeaderXFCC is the header used by Envoy and Istio to forward the
+	// certificates of the clients that established mutual TLS with them.
+	HeaderXFCC string = "X-Forwarded-Client-Cert"
+	// HeaderNginxClientCert is the header used by ingress-nginx to forward
+	// the client certificate.
+	HeaderNginxClientCert string = "ssl-client-cert"
+)
+
+// XFCCClientCertFunc returns a [ClientCertFunc] that reads the client
+// certificate from the Envoy's x-forwarded-client-cert header.
+// Envoy must be configured to forward the certificate, e.g. with
+// forward_client_cert_details set to SANITIZE_SET and set_current_client_cert_details
+// including the cert.
+//
+// When multiple proxies append elements to the header, index selects which one
+// contains the client certificate. The first element is at index 0 and
+// negative values count from the end, so -1 selects the element appended by
+// the proxy closest to the provider.
+// Only proxies in front of the provider can be trusted to append elements, so
+// the index must not let a client choose an element it wrote itself.
+func XFCCClientCertFunc(index int) ClientCertFunc {
+	return func(r *http.Request) (*x509.Certificate, error) {
+		// Proxies may append a new header field instead of extending the
+		// existing one, so all the fields are combined.
+		header := strings.Join(r.Header.Values(HeaderXFCC), ",")
+		if header == "" {
+			return nil, errors.New("the client certificate was not informed")
+		}
+
+		elements := splitUnquoted(header, ',')
+		i := index
+		if i < 0 {
+			i += len(elements)
+		}
+		if i < 0 || i >= len(elements) {
+			return nil, errors.New("the xfcc header doesn't have the element expected")
+		}
+
+		for _, pair := range splitUnquoted(elements[i], ';') {
+			key, value, _ := strings.Cut(strings.TrimSpace(pair), "=")
+			if !strings.EqualFold(key, "Cert") {
+				continue
+			}
+			return parseEscapedPEMCert(unquote(value))
+		}
+
+		return nil, errors.New("the xfcc element doesn't contain the client certificate")
+	}
+}
+
+// NginxClientCertFunc is a [ClientCertFunc] that reads the client
+// certificate from the header [HeaderNginxClientCert], which ingress-nginx
+// sets with the URL encoded PEM certificate when
+// nginx.ingress.kubernetes.io/auth-tls-pass-certificate-to-upstream is
+// enabled.
+func NginxClientCertFunc(r *http.Request) (*x509.Certificate, error) {
+	header := r.Header.Get(HeaderNginxClientCert)
+	if header == "" {
+		return nil, errors.New("the client certificate was not informed")
+	}
+	return parseEscapedPEMCert(header)
+}
+
+func parseEscapedPEMCert(escapedCert string) (*x509.Certificate, error) {
+	rawCert, err := url.QueryUnescape(escapedCert)
+	if err != nil {
+		return nil, fmt.Errorf("could not url decode the client certificate: %w", err)
+	}
+
+	block, _ := pem.Decode([]byte(rawCert))
+	if block == nil {
+		return nil, errors.New("could not decode the client certificate")
+	}
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse the client certificate: %w", err)
+	}
+
+	return cert, nil
+}
+
+// splitUnquoted splits s around sep, ignoring the separators between double
+// quotes.
+func splitUnquoted(s string, sep rune) []string {
+	var parts []string
+	var part strings.Builder
+	quoted, escaped := false, false
+	for _, c := range s {
+		switch {
+		case escaped:
+			escaped = false
+		case c == '\\' && quoted:
+			escaped = true
+		case c == '"':
+			quoted = !quoted
+		case c == sep && !quoted:
+			parts = append(parts, part.String())
+			part.Reset()
+			continue
+		}
+		part.WriteRune(c)
+	}
+	return append(parts, part.String())
+}
+
+func unquote(s string) string {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return s
+	}
+	return strings.ReplaceAll(s[1:len(s)-1], `\"`, `"`)
+}
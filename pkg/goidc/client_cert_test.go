@@ -0,0 +1,164 @@
+package goidc_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+func TestXFCCClientCertFunc(t *testing.T) {
+	// Given.
+	clientCert, escapedClientCert := escapedCert(t, "random_client")
+	_, escapedProxyCert := escapedCert(t, "random_proxy")
+	req := httptest.NewRequest(http.MethodPost, "/token", nil)
+	req.Header.Set(goidc.HeaderXFCC,
+		`By=spiffe://cluster.local/ns/default/sa/op;Hash=abc;Cert="`+escapedClientCert+
+			`";Subject="CN=random_client,O=random, \"org\"";DNS=client.example.com,`+
+			`By=spiffe://cluster.local/ns/default/sa/gateway;Hash=def;Cert="`+escapedProxyCert+`"`)
+
+	// When.
+	cert, err := goidc.XFCCClientCertFunc(0)(req)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !cert.Equal(clientCert) {
+		t.Errorf("the first element must be selected, got %s", cert.Subject)
+	}
+
+	// When.
+	cert, err = goidc.XFCCClientCertFunc(-1)(req)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cert.Subject.CommonName != "random_proxy" {
+		t.Errorf("the last element must be selected, got %s", cert.Subject)
+	}
+}
+
+func TestXFCCClientCertFunc_NegativeIndexReused(t *testing.T) {
+	// Given.
+	_, escapedClientCert := escapedCert(t, "random_client")
+	_, escapedProxyCert := escapedCert(t, "random_proxy")
+	certFunc := goidc.XFCCClientCertFunc(-1)
+
+	longReq := httptest.NewRequest(http.MethodPost, "/token", nil)
+	longReq.Header.Set(goidc.HeaderXFCC,
+		`Hash=abc;Cert="`+escapedClientCert+`",Hash=def;Cert="`+escapedProxyCert+`"`)
+	shortReq := httptest.NewRequest(http.MethodPost, "/token", nil)
+	shortReq.Header.Set(goidc.HeaderXFCC, `Hash=def;Cert="`+escapedProxyCert+`"`)
+
+	// When.
+	_, err := certFunc(longReq)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cert, err := certFunc(shortReq)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cert.Subject.CommonName != "random_proxy" {
+		t.Errorf("the last element must be selected, got %s", cert.Subject)
+	}
+}
+
+func TestXFCCClientCertFunc_MultipleHeaderFields(t *testing.T) {
+	// Given.
+	_, escapedClientCert := escapedCert(t, "random_client")
+	_, escapedProxyCert := escapedCert(t, "random_proxy")
+	req := httptest.NewRequest(http.MethodPost, "/token", nil)
+	req.Header.Add(goidc.HeaderXFCC, `Hash=abc;Cert="`+escapedClientCert+`"`)
+	req.Header.Add(goidc.HeaderXFCC, `Hash=def;Cert="`+escapedProxyCert+`"`)
+
+	// When.
+	cert, err := goidc.XFCCClientCertFunc(-1)(req)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cert.Subject.CommonName != "random_proxy" {
+		t.Errorf("the element of the last header field must be selected, got %s", cert.Subject)
+	}
+}
+
+func TestXFCCClientCertFunc_MissingCert(t *testing.T) {
+	// Given.
+	req := httptest.NewRequest(http.MethodPost, "/token", nil)
+	req.Header.Set(goidc.HeaderXFCC, `By=spiffe://cluster.local/ns/default/sa/op;Hash=abc`)
+
+	// When.
+	_, err := goidc.XFCCClientCertFunc(0)(req)
+
+	// Then.
+	if err == nil {
+		t.Error("the certificate must be required")
+	}
+}
+
+func TestNginxClientCertFunc(t *testing.T) {
+	// Given.
+	clientCert, escapedClientCert := escapedCert(t, "random_client")
+	req := httptest.NewRequest(http.MethodPost, "/token", nil)
+	req.Header.Set(goidc.HeaderNginxClientCert, escapedClientCert)
+
+	// When.
+	cert, err := goidc.NginxClientCertFunc(req)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !cert.Equal(clientCert) {
+		t.Errorf("unexpected certificate %s", cert.Subject)
+	}
+}
+
+func escapedCert(t *testing.T, commonName string) (*x509.Certificate, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("could not generate the key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("could not create the certificate: %v", err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("could not parse the certificate: %v", err)
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	return cert, url.QueryEscape(string(certPEM))
+}
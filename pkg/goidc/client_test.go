@@ -4,6 +4,7 @@ import (
 	"crypto/rand"
 	"crypto/rsa"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -61,3 +62,121 @@ func privatePs256JWK(keyID string) jose.JSONWebKey {
 		Use:       string(goidc.KeyUsageSignature),
 	}
 }
+
+func TestLocalizedName(t *testing.T) {
+	// Given.
+	client := goidc.Client{
+		ClientMetaInfo: goidc.ClientMetaInfo{
+			Name: "My App",
+		},
+	}
+	client.SetLocalizedAttribute("client_name", "ja-Jpan-JP", "アプリ")
+	client.SetLocalizedAttribute("client_name", "fr", "Mon App")
+
+	testCases := []struct {
+		tag  string
+		want string
+	}{
+		{"ja-Jpan-JP", "アプリ"},
+		{"fr-CA", "Mon App"},
+		{"FR", "Mon App"},
+		{"de", "My App"},
+		{"", "My App"},
+	}
+
+	for _, testCase := range testCases {
+		// When.
+		got := client.LocalizedName(testCase.tag)
+
+		// Then.
+		if got != testCase.want {
+			t.Errorf("LocalizedName(%s) = %s, want %s", testCase.tag, got, testCase.want)
+		}
+	}
+}
+
+func TestTypedAttributes(t *testing.T) {
+	// Given.
+	client := goidc.Client{}
+	client.SetAttribute("string", "value")
+	client.SetAttribute("strings", []any{"value1", "value2"})
+	client.SetAttribute("bool", true)
+	client.SetAttribute("int", float64(10))
+
+	// Then.
+	if s, ok := client.StringAttribute("string"); !ok || s != "value" {
+		t.Errorf("StringAttribute() = %s, %t, want value, true", s, ok)
+	}
+
+	if strs, ok := client.StringsAttribute("strings"); !ok || len(strs) != 2 {
+		t.Errorf("StringsAttribute() = %v, %t, want [value1 value2], true", strs, ok)
+	}
+
+	if b, ok := client.BoolAttribute("bool"); !ok || !b {
+		t.Errorf("BoolAttribute() = %t, %t, want true, true", b, ok)
+	}
+
+	if i, ok := client.IntAttribute("int"); !ok || i != 10 {
+		t.Errorf("IntAttribute() = %d, %t, want 10, true", i, ok)
+	}
+
+	if _, ok := client.StringAttribute("int"); ok {
+		t.Error("int should not be returned as a string")
+	}
+}
+
+func TestClientAuthnThrottler(t *testing.T) {
+	// Given.
+	throttler := goidc.NewClientAuthnThrottler(2, 60)
+	req := httptest.NewRequest(http.MethodPost, "/token", nil)
+	authnErr := errors.New("invalid secret")
+
+	// When.
+	throttler.AfterAuthn(req, "random_client_id", 1, authnErr)
+
+	// Then.
+	if err := throttler.BeforeAuthn(req, "random_client_id", 1); err != nil {
+		t.Fatalf("the client must not be locked after a single failure: %v", err)
+	}
+
+	// When.
+	throttler.AfterAuthn(req, "random_client_id", 2, authnErr)
+
+	// Then.
+	err := throttler.BeforeAuthn(req, "random_client_id", 2)
+	var oidcErr goidc.Error
+	if !errors.As(err, &oidcErr) || oidcErr.Code != goidc.ErrorCodeSlowDown {
+		t.Fatalf("the client must be locked with slow_down, got %v", err)
+	}
+
+	if err := throttler.BeforeAuthn(req, "other_client_id", 0); err != nil {
+		t.Errorf("other clients must not be locked: %v", err)
+	}
+}
+
+func TestClientAuthnThrottler_OtherAddress(t *testing.T) {
+	// Given.
+	throttler := goidc.NewClientAuthnThrottler(1, 60)
+	req := httptest.NewRequest(http.MethodPost, "/token", nil)
+	req.RemoteAddr = "198.51.100.1:1234"
+	throttler.AfterAuthn(req, "random_client_id", 1, errors.New("invalid secret"))
+
+	// When.
+	otherReq := httptest.NewRequest(http.MethodPost, "/token", nil)
+	otherReq.RemoteAddr = "198.51.100.2:1234"
+	err := throttler.BeforeAuthn(otherReq, "random_client_id", 0)
+
+	// Then.
+	if err != nil {
+		t.Errorf("the client must not be locked for other addresses: %v", err)
+	}
+
+	// When.
+	req.RemoteAddr = "198.51.100.1:5678"
+	err = throttler.BeforeAuthn(req, "random_client_id", 1)
+
+	// Then.
+	if err == nil {
+		t.Error("the client must be locked for the address that failed")
+	}
+}
@@ -0,0 +1,176 @@
+package goidc
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// ConsentManager contains all the logic needed to manage the consents users
+// granted to clients.
+type ConsentManager interface {
+	Save(ctx context.Context, consent *Consent) error
+	Consent(ctx context.Context, subject, clientID string) (*Consent, error)
+	Delete(ctx context.Context, subject, clientID string) error
+}
+
+// Consent records the accesses a user granted to a client.
+type Consent struct {
+	Subject  string `json:"sub"`
+	ClientID string `json:"client_id"`
+	// Scopes is a space separated list of the scopes the user consented to.
+	Scopes string `json:"scope,omitempty"`
+	// Claims are the names of the claims the user consented to share with the
+	// client through the claims parameter.
+	Claims               []string              `json:"claims,omitempty"`
+	AuthorizationDetails []AuthorizationDetail `json:"authorization_details,omitempty"`
+	Resources            Resources             `json:"resources,omitempty"`
+	// Grants are the grant sessions issued based on this consent. They are
+	// revoked along with the consent.
+	Grants             []ConsentGrant `json:"grants,omitempty"`
+	CreatedAtTimestamp int            `json:"created_at"`
+	UpdatedAtTimestamp int            `json:"updated_at"`
+}
+
+// ConsentGrant references a grant session issued based on a consent.
+// ExpiresAtTimestamp allows dropping the references to grants that can no
+// longer be used.
+type ConsentGrant struct {
+	ID                 string `json:"id"`
+	ExpiresAtTimestamp int    `json:"expires_at"`
+}
+
+// Covers returns whether the consent includes all the accesses requested in
+// the authentication session, so the user doesn't need to be prompted again.
+func (c *Consent) Covers(session *AuthnSession) bool {
+	consentedScopes := strings.Split(c.Scopes, " ")
+	for _, scope := range strings.Split(session.Scopes, " ") {
+		if scope != "" && !slices.Contains(consentedScopes, scope) {
+			return false
+		}
+	}
+
+	for _, claim := range requestedClaims(session.Claims) {
+		if !slices.Contains(c.Claims, claim) {
+			return false
+		}
+	}
+
+	for _, resource := range session.Resources {
+		if !slices.Contains(c.Resources, resource) {
+			return false
+		}
+	}
+
+	for _, authDetail := range session.AuthDetails {
+		if !slices.ContainsFunc(c.AuthorizationDetails, func(d AuthorizationDetail) bool {
+			return reflect.DeepEqual(d, authDetail)
+		}) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Merge adds the accesses granted in the authentication session to the
+// consent.
+func (c *Consent) Merge(session *AuthnSession) {
+	consentedScopes := strings.Split(c.Scopes, " ")
+	for _, scope := range strings.Split(session.GrantedScopes, " ") {
+		if scope != "" && !slices.Contains(consentedScopes, scope) {
+			consentedScopes = append(consentedScopes, scope)
+		}
+	}
+	c.Scopes = strings.TrimSpace(strings.Join(consentedScopes, " "))
+
+	for _, claim := range grantedClaims(session) {
+		if !slices.Contains(c.Claims, claim) {
+			c.Claims = append(c.Claims, claim)
+		}
+	}
+
+	for _, resource := range session.GrantedResources {
+		if !slices.Contains(c.Resources, resource) {
+			c.Resources = append(c.Resources, resource)
+		}
+	}
+
+	for _, authDetail := range session.GrantedAuthDetails {
+		if !slices.ContainsFunc(c.AuthorizationDetails, func(d AuthorizationDetail) bool {
+			return reflect.DeepEqual(d, authDetail)
+		}) {
+			c.AuthorizationDetails = append(c.AuthorizationDetails, authDetail)
+		}
+	}
+}
+
+func requestedClaims(claims *ClaimsObject) []string {
+	if claims == nil {
+		return nil
+	}
+
+	var names []string
+	for name := range claims.UserInfo {
+		names = append(names, name)
+	}
+	for name := range claims.IDToken {
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// grantedClaims returns the claims requested through the claims parameter that
+// were released in the session, either in the ID token or in the userinfo
+// response.
+func grantedClaims(session *AuthnSession) []string {
+	var names []string
+	for _, name := range requestedClaims(session.Claims) {
+		_, inIDToken := session.AdditionalIDTokenClaims[name]
+		_, inUserInfo := session.AdditionalUserInfoClaims[name]
+		if inIDToken || inUserInfo {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// ConsentContext gathers the information a consent screen needs to describe
+// the client and what it is requesting.
+type ConsentContext struct {
+	ClientID          string
+	ClientName        string
+	ClientURI         string
+	LogoURI           string
+	PolicyURI         string
+	TermsOfServiceURI string
+	// BrandingIsVerified indicates that the client URIs were verified to be
+	// reachable during registration, see [Client.BrandingIsVerified].
+	BrandingIsVerified bool
+	// Scopes is a space separated list of the scopes requested.
+	Scopes               string
+	Claims               []string
+	AuthorizationDetails []AuthorizationDetail
+	Resources            Resources
+}
+
+// NewConsentContext creates the consent context for the authentication session
+// using the client information that best matches the BCP47 language tag.
+func NewConsentContext(client *Client, session *AuthnSession, tag string) ConsentContext {
+	return ConsentContext{
+		ClientID:             client.ID,
+		BrandingIsVerified:   client.BrandingIsVerified,
+		ClientName:           client.LocalizedName(tag),
+		ClientURI:            client.LocalizedClientURI(tag),
+		LogoURI:              client.LocalizedLogoURI(tag),
+		PolicyURI:            client.LocalizedPolicyURI(tag),
+		TermsOfServiceURI:    client.LocalizedTermsOfServiceURI(tag),
+		Scopes:               session.Scopes,
+		Claims:               requestedClaims(session.Claims),
+		AuthorizationDetails: session.AuthDetails,
+		Resources:            session.Resources,
+	}
+}
@@ -0,0 +1,170 @@
+package goidc_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+func TestConsent_Covers(t *testing.T) {
+	// Given.
+	consent := goidc.Consent{
+		Scopes:    "openid email",
+		Resources: []string{"https://resource.com"},
+		AuthorizationDetails: []goidc.AuthorizationDetail{
+			{"type": "payment", "amount": "10"},
+		},
+	}
+
+	testCases := []struct {
+		name    string
+		session goidc.AuthnSession
+		want    bool
+	}{
+		{
+			name: "subset of scopes",
+			session: goidc.AuthnSession{
+				AuthorizationParameters: goidc.AuthorizationParameters{
+					Scopes: "openid",
+				},
+			},
+			want: true,
+		},
+		{
+			name: "new scope",
+			session: goidc.AuthnSession{
+				AuthorizationParameters: goidc.AuthorizationParameters{
+					Scopes: "openid profile",
+				},
+			},
+			want: false,
+		},
+		{
+			name: "new resource",
+			session: goidc.AuthnSession{
+				AuthorizationParameters: goidc.AuthorizationParameters{
+					Scopes:    "openid",
+					Resources: []string{"https://other-resource.com"},
+				},
+			},
+			want: false,
+		},
+		{
+			name: "same authorization details",
+			session: goidc.AuthnSession{
+				AuthorizationParameters: goidc.AuthorizationParameters{
+					AuthDetails: []goidc.AuthorizationDetail{
+						{"type": "payment", "amount": "10"},
+					},
+				},
+			},
+			want: true,
+		},
+		{
+			name: "different authorization details",
+			session: goidc.AuthnSession{
+				AuthorizationParameters: goidc.AuthorizationParameters{
+					AuthDetails: []goidc.AuthorizationDetail{
+						{"type": "payment", "amount": "20"},
+					},
+				},
+			},
+			want: false,
+		},
+		{
+			name: "new claim",
+			session: goidc.AuthnSession{
+				AuthorizationParameters: goidc.AuthorizationParameters{
+					Claims: &goidc.ClaimsObject{
+						UserInfo: map[string]goidc.ClaimObjectInfo{
+							"email": {},
+						},
+					},
+				},
+			},
+			want: false,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// When.
+			got := consent.Covers(&testCase.session)
+
+			// Then.
+			if got != testCase.want {
+				t.Errorf("Covers() = %t, want %t", got, testCase.want)
+			}
+		})
+	}
+}
+
+func TestConsent_Merge(t *testing.T) {
+	// Given.
+	consent := goidc.Consent{
+		Scopes: "openid",
+	}
+	session := goidc.AuthnSession{
+		GrantedScopes:    "openid email",
+		GrantedResources: []string{"https://resource.com"},
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			Claims: &goidc.ClaimsObject{
+				IDToken: map[string]goidc.ClaimObjectInfo{
+					"email":        {},
+					"phone_number": {},
+				},
+			},
+		},
+	}
+	// Only the email is released, so the phone number is not consented to.
+	session.SetIDTokenClaim("email", "random@email.com")
+
+	// When.
+	consent.Merge(&session)
+
+	// Then.
+	want := goidc.Consent{
+		Scopes:    "openid email",
+		Claims:    []string{"email"},
+		Resources: []string{"https://resource.com"},
+	}
+	if diff := cmp.Diff(consent, want); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestNewConsentContext(t *testing.T) {
+	// Given.
+	client := &goidc.Client{
+		ID:                 "random_client_id",
+		BrandingIsVerified: true,
+		ClientMetaInfo: goidc.ClientMetaInfo{
+			Name:      "My App",
+			LogoURI:   "https://example.com/logo.png",
+			PolicyURI: "https://example.com/policy",
+		},
+	}
+	client.SetLocalizedAttribute("client_name", "fr", "Mon App")
+	session := &goidc.AuthnSession{
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			Scopes: "openid scope1",
+		},
+	}
+
+	// When.
+	consentCtx := goidc.NewConsentContext(client, session, "fr-CA")
+
+	// Then.
+	want := goidc.ConsentContext{
+		ClientID:           "random_client_id",
+		ClientName:         "Mon App",
+		LogoURI:            "https://example.com/logo.png",
+		PolicyURI:          "https://example.com/policy",
+		BrandingIsVerified: true,
+		Scopes:             "openid scope1",
+	}
+	if diff := cmp.Diff(consentCtx, want); diff != "" {
+		t.Error(diff)
+	}
+}
@@ -24,25 +24,64 @@ const (
 	ErrorCodeInvalidClientMetadata  ErrorCode = "invalid_client_metadata"
 	ErrorCodeRequestURINotSupported ErrorCode = "request_uri_not_supported"
 	ErrorCodeLoginRequired          ErrorCode = "login_required"
+	ErrorCodeConsentRequired        ErrorCode = "consent_required"
+	ErrorCodeInteractionRequired    ErrorCode = "interaction_required"
+	// ErrorCodeAccountSelectionRequired is returned when the user must choose
+	// one of the accounts authenticated in the user agent, but the client
+	// requested no interaction.
+	ErrorCodeAccountSelectionRequired ErrorCode = "account_selection_required"
+	// ErrorCodeUnmetAuthnRequirements is returned when the authorization
+	// server cannot satisfy the essential authentication requirements, e.g.
+	// an essential acr.
+	ErrorCodeUnmetAuthnRequirements ErrorCode = "unmet_authentication_requirements"
+	// ErrorCodeInsufficientScope is returned by resource servers when the
+	// access token doesn't have the scopes required to access a resource.
+	ErrorCodeInsufficientScope ErrorCode = "insufficient_scope"
+	// ErrorCodeSlowDown is returned when a client is making requests too
+	// often, e.g. after repeated authentication failures.
+	ErrorCodeSlowDown ErrorCode = "slow_down"
+	// ErrorCodeTemporarilyUnavailable is returned when the provider cannot
+	// handle the request in time, e.g. when the timeout of an endpoint is
+	// exceeded.
+	ErrorCodeTemporarilyUnavailable ErrorCode = "temporarily_unavailable"
 )
 
 func (c ErrorCode) StatusCode() int {
 	switch c {
-	case ErrorCodeAccessDenied:
+	case ErrorCodeAccessDenied, ErrorCodeInsufficientScope:
 		return http.StatusForbidden
 	case ErrorCodeInvalidClient, ErrorCodeInvalidToken, ErrorCodeUnauthorizedClient:
 		return http.StatusUnauthorized
 	case ErrorCodeInternalError:
 		return http.StatusInternalServerError
+	case ErrorCodeTemporarilyUnavailable:
+		return http.StatusServiceUnavailable
 	default:
 		return http.StatusBadRequest
 	}
 }
 
+// ErrorHandling defines how an error returned by an authentication policy is
+// reported by the authorization endpoints.
+type ErrorHandling int
+
+const (
+	// ErrorHandlingRedirect redirects the user agent to the client with the
+	// parameters "error" and "error_description". This is the default.
+	ErrorHandlingRedirect ErrorHandling = iota
+	// ErrorHandlingRender shows the error to the user with the render error
+	// function of the provider instead of redirecting it to the client.
+	// If no render error function is defined, the error is written as JSON.
+	ErrorHandlingRender
+	// ErrorHandlingJSON writes the error as a JSON response.
+	ErrorHandlingJSON
+)
+
 type Error struct {
 	Code        ErrorCode `json:"error"`
 	Description string    `json:"error_description"`
 	wrapped     error
+	handling    ErrorHandling
 }
 
 func NewError(code ErrorCode, desc string) Error {
@@ -64,6 +103,21 @@ func (err Error) Unwrap() error {
 	return err.wrapped
 }
 
+// WithHandling returns a copy of the error that is reported as defined by h
+// when returned by an authentication policy.
+//
+//	return goidc.StatusFailure, goidc.NewError(goidc.ErrorCodeAccessDenied,
+//		"the account is locked").WithHandling(goidc.ErrorHandlingRender)
+func (err Error) WithHandling(h ErrorHandling) Error {
+	err.handling = h
+	return err
+}
+
+// Handling returns how the error is reported by the authorization endpoints.
+func (err Error) Handling() ErrorHandling {
+	return err.handling
+}
+
 func Errorf(code ErrorCode, desc string, err error) Error {
 	return Error{
 		Code:        code,
@@ -20,6 +20,31 @@ type GrantSessionManager interface {
 	DeleteByAuthorizationCode(context.Context, string) error
 }
 
+// GrantSessionCodeFinder is implemented by grant session managers that can
+// find the session issued with an authorization code. When implemented, the
+// grant deleted because its authorization code was reused is notified as a
+// [TokenEventRevocation].
+type GrantSessionCodeFinder interface {
+	SessionByAuthorizationCode(context.Context, string) (*GrantSession, error)
+}
+
+// IntrospectionCache keeps the grant sessions looked up during token
+// introspection for a short time, so resource servers introspecting every
+// request don't reach the grant session storage each time.
+// Entries are evicted when their grant session is saved or deleted through
+// the provider. Since these evictions are not seen by the caches of other
+// instances, a shared cache, e.g. backed by Redis, should be used when running
+// multiple instances of the provider.
+type IntrospectionCache interface {
+	// Save caches the session under its current token ID.
+	Save(context.Context, *GrantSession) error
+	// SessionByTokenID returns an error if no session is cached for the token
+	// ID or if the entry expired.
+	SessionByTokenID(context.Context, string) (*GrantSession, error)
+	// Delete evicts the entries of the grant session with the ID informed.
+	Delete(ctx context.Context, grantID string) error
+}
+
 // GrantSession represents the granted access an entity (a user or the client
 // itself) gave to a client.
 // It holds information about the token issued to a client and about the user
@@ -42,6 +67,80 @@ type GrantSession struct {
 
 type HandleGrantFunc func(*http.Request, *GrantInfo) error
 
+// HandleCustomGrantFunc handles a request to the token endpoint for a grant
+// type registered as custom.
+// The client is already authenticated when it runs. The parameters specific to
+// the grant type can be read from the request's form.
+// The information returned defines the access granted, e.g. the subject and
+// the active scopes. The client ID, the grant type and the token binding are
+// set by the provider, which then issues the tokens as for any other grant.
+type HandleCustomGrantFunc func(r *http.Request, client *Client) (GrantInfo, error)
+
+type TokenEventType string
+
+const (
+	// TokenEventIssuance happens when a new grant is created and its first
+	// access token is issued.
+	TokenEventIssuance TokenEventType = "issuance"
+	// TokenEventRefresh happens when a new access token is issued for an
+	// existing grant using a refresh token.
+	TokenEventRefresh TokenEventType = "refresh"
+	// TokenEventRevocation happens when a grant is revoked, e.g. through the
+	// revocation endpoint or when a consent is revoked.
+	TokenEventRevocation TokenEventType = "revocation"
+	// TokenEventExpiry happens when a grant is deleted because it expired.
+	TokenEventExpiry TokenEventType = "expiry"
+)
+
+// TokenEvent describes a change in the lifecycle of a grant and its tokens.
+type TokenEvent struct {
+	Type     TokenEventType
+	GrantID  string
+	ClientID string
+	Subject  string
+	// TokenID is the identifier of the access token issued, if any.
+	TokenID string
+}
+
+// NotifyTokenEventFunc defines a function that is executed when tokens are
+// issued, refreshed, revoked or deleted due to expiration.
+// It can be used, for instance, to feed audit pipelines or invalidate caches.
+type NotifyTokenEventFunc func(*http.Request, TokenEvent)
+
+// GrantSessionSaveErrorFunc defines a function that is executed when a grant
+// session persisted asynchronously could not be saved.
+// The token for the session was already issued when it runs.
+type GrantSessionSaveErrorFunc func(context.Context, *GrantSession, error)
+
+// TokenResponseHookFunc defines a function that returns additional parameters
+// to be included in the token endpoint response, e.g. "patient" for
+// SMART-on-FHIR.
+// It is executed after the grant is created and before the response is written.
+// Parameters that conflict with standard token response fields are ignored.
+type TokenResponseHookFunc func(*http.Request, GrantInfo) map[string]any
+
+// TokenRenewal informs when the tokens issued in a token response expire.
+type TokenRenewal struct {
+	GrantInfo                     GrantInfo
+	AccessTokenExpiresAtTimestamp int
+	// RefreshTokenExpiresAtTimestamp is zero if the grant has no refresh
+	// token.
+	RefreshTokenExpiresAtTimestamp int
+}
+
+// TokenRenewalHintFunc defines a function that returns parameters to be
+// included in the token endpoint response to help clients schedule the
+// renewal of their tokens, e.g. a "refresh_after" timestamp.
+// Standard token response parameters cannot be overridden.
+type TokenRenewalHintFunc func(*http.Request, TokenRenewal) map[string]any
+
+// ClaimsSourceFunc loads the claims of the user associated with a grant.
+// It is called when ID tokens and user info responses are issued, so fresh
+// profile data can be loaded from a user store instead of relying only on the
+// claims set during authentication.
+// The claims returned take precedence over the ones in the grant.
+type ClaimsSourceFunc func(context.Context, GrantInfo) (map[string]any, error)
+
 // GrantInfo contains the information assigned during token issuance.
 //
 //   - For authorization_code and refresh_token grant types:
@@ -81,17 +180,28 @@ type GrantInfo struct {
 	AdditionalIDTokenClaims  map[string]any `json:"additional_id_token_claims,omitempty"`
 	AdditionalUserInfoClaims map[string]any `json:"additional_user_info_claims,omitempty"`
 	AdditionalTokenClaims    map[string]any `json:"additional_token_claims,omitempty"`
+	// Claims are the claims the client requested with the claims parameter.
+	Claims *ClaimsObject `json:"claims,omitempty"`
 
 	// JWKThumbprint stores the thumbprint of the JWK provided via DPoP.
 	JWKThumbprint string `json:"jwk_thumbprint,omitempty"`
 	// ClientCertThumbprint contains the thumbprint of the certificate used by
 	// the client to generate the token.
 	ClientCertThumbprint string `json:"certificate_thumbprint,omitempty"`
+	// SessionID identifies the session of the user in which the grant was
+	// authorized, see [AuthnSession.SessionID].
+	SessionID string `json:"sid,omitempty"`
 
 	// Store allows storing custom data within the grant session.
 	Store map[string]any `json:"store"`
 }
 
+// ScopeParams returns the parameters of the structured scope active for the
+// grant, if any.
+func (g GrantInfo) ScopeParams(scope Scope) (map[string]any, bool) {
+	return scope.Params(g.ActiveScopes)
+}
+
 func (g *GrantSession) IsExpired() bool {
 	return timeutil.TimestampNow() >= g.ExpiresAtTimestamp
 }
@@ -0,0 +1,49 @@
+package goidc
+
+import "context"
+
+// MeterProvider provides the meter used to record the metrics of the
+// provider.
+// It mirrors the OpenTelemetry meter provider, so one can be plugged in with a
+// thin adapter. A Prometheus registry can be used the same way.
+type MeterProvider interface {
+	Meter(name string) Meter
+}
+
+// Meter creates the instruments used to record measurements.
+type Meter interface {
+	// Counter creates an instrument that only goes up.
+	Counter(name, description string) Counter
+	// UpDownCounter creates an instrument that can also go down.
+	UpDownCounter(name, description string) Counter
+	// Histogram creates an instrument that records the distribution of values.
+	Histogram(name, description, unit string) Histogram
+}
+
+type Counter interface {
+	Add(ctx context.Context, incr int64, attrs ...Attribute)
+}
+
+type Histogram interface {
+	Record(ctx context.Context, value float64, attrs ...Attribute)
+}
+
+// Metric names recorded by the provider.
+const (
+	// MetricTokens counts the tokens issued, either for new grants or when
+	// grants are refreshed.
+	MetricTokens = "goidc.tokens"
+	// MetricAuthorizations counts the authorization flows finished, either
+	// successfully or not.
+	MetricAuthorizations = "goidc.authorizations"
+	// MetricClientAuthnFailures counts the clients that failed to
+	// authenticate.
+	MetricClientAuthnFailures = "goidc.client_authn.failures"
+	// MetricRequestDuration records how long the endpoints take to respond.
+	MetricRequestDuration = "goidc.http.server.request.duration"
+	// MetricActiveGrants tracks the grant sessions issued and not yet revoked
+	// or expired.
+	// Grants whose expiration is never noticed by the provider, e.g. they are
+	// removed directly by the storage, are not subtracted.
+	MetricActiveGrants = "goidc.grants.active"
+)
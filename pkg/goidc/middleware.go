@@ -1,7 +1,13 @@
 package goidc
 
 import (
+	"bytes"
+	"io"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
 )
 
 func CacheControlMiddleware(next http.Handler) http.Handler {
@@ -14,3 +20,161 @@ func CacheControlMiddleware(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// sensitiveParams are the parameters that must never be logged in plain
+// text.
+var sensitiveParams = map[string]struct{}{
+	"code":             {},
+	"code_verifier":    {},
+	"access_token":     {},
+	"refresh_token":    {},
+	"id_token":         {},
+	"id_token_hint":    {},
+	"token":            {},
+	"assertion":        {},
+	"client_assertion": {},
+	"client_secret":    {},
+	"request":          {},
+	"response":         {},
+	"login_hint_token": {},
+	"subject_token":    {},
+	"actor_token":      {},
+	"device_code":      {},
+}
+
+const redacted = "REDACTED"
+
+// maxRecordedFormBytes is the size of the form kept to find the client ID of
+// the request.
+const maxRecordedFormBytes = 64 << 10
+
+// LoggingMiddleware returns a middleware that logs every request with its
+// method, path, status, latency, client ID and correlation ID.
+// Tokens, assertions, secrets and authorization codes in the query are
+// redacted and headers and bodies are never logged.
+// If logger is nil, [slog.Default] is used.
+//
+//	op.Run(":80", goidc.LoggingMiddleware(logger))
+func LoggingMiddleware(logger *slog.Logger) MiddlewareFunc {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			var form *formRecorder
+			if isFormRequest(r) && r.Body != nil {
+				form = &formRecorder{ReadCloser: r.Body}
+				r = r.WithContext(r.Context())
+				r.Body = form
+			}
+
+			sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(sw, r)
+			clientID := requestClientID(r, form)
+
+			attrs := []slog.Attr{
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", sw.status),
+				slog.Duration("latency", time.Since(start)),
+			}
+			if query := redactedQuery(r.URL.Query()); query != "" {
+				attrs = append(attrs, slog.String("query", query))
+			}
+			if clientID != "" {
+				attrs = append(attrs, slog.String("client_id", clientID))
+			}
+			if correlationID := requestCorrelationID(r); correlationID != "" {
+				attrs = append(attrs, slog.String("correlation_id", correlationID))
+			}
+
+			level := slog.LevelInfo
+			if sw.status >= http.StatusInternalServerError {
+				level = slog.LevelError
+			}
+			logger.LogAttrs(r.Context(), level, "request handled", attrs...)
+		})
+	}
+}
+
+// requestClientID returns the client ID informed in the request, if any.
+// The form is not parsed by the middleware, otherwise it would be read before
+// the handler enforces its limits on the size of the body. Instead, the client
+// ID is taken from the part of the form read by the handler.
+func requestClientID(r *http.Request, form *formRecorder) string {
+	if id, _, ok := r.BasicAuth(); ok {
+		if id, err := url.QueryUnescape(id); err == nil {
+			return id
+		}
+	}
+
+	if form != nil {
+		if form.truncated {
+			return ""
+		}
+		values, _ := url.ParseQuery(form.buf.String())
+		return values.Get("client_id")
+	}
+
+	return r.URL.Query().Get("client_id")
+}
+
+func isFormRequest(r *http.Request) bool {
+	return r.Method == http.MethodPost &&
+		strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded")
+}
+
+// formRecorder keeps a copy of the body read by the handler, up to
+// maxRecordedFormBytes.
+type formRecorder struct {
+	io.ReadCloser
+	buf       bytes.Buffer
+	truncated bool
+}
+
+func (r *formRecorder) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if !r.truncated {
+		if r.buf.Len()+n > maxRecordedFormBytes {
+			r.truncated = true
+			r.buf = bytes.Buffer{}
+		} else {
+			r.buf.Write(p[:n])
+		}
+	}
+	return n, err
+}
+
+func requestCorrelationID(r *http.Request) string {
+	if id := r.Header.Get(HeaderCorrelationID); id != "" {
+		return id
+	}
+	return r.Header.Get(HeaderFAPIInteractionID)
+}
+
+func redactedQuery(query url.Values) string {
+	for param := range query {
+		if _, ok := sensitiveParams[param]; ok {
+			query[param] = []string{redacted}
+		}
+	}
+	return query.Encode()
+}
+
+// statusWriter records the status code written to the response.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
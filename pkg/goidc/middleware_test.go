@@ -0,0 +1,92 @@
+package goidc_test
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+func TestLoggingMiddleware(t *testing.T) {
+	// Given.
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, nil))
+
+	var clientID string
+	handler := goidc.LoggingMiddleware(logger)(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			clientID = r.PostFormValue("client_id")
+			w.WriteHeader(http.StatusBadRequest)
+		},
+	))
+
+	form := url.Values{
+		"client_id":     {"random_client_id"},
+		"client_secret": {"random_secret"},
+		"code":          {"random_code"},
+	}
+	req := httptest.NewRequest(http.MethodPost, "/token?code=random_code&state=random_state",
+		strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set(goidc.HeaderCorrelationID, "random_correlation_id")
+
+	// When.
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	// Then.
+	if clientID != "random_client_id" {
+		t.Errorf("the form must still be available to the handler, client_id = %s", clientID)
+	}
+
+	log := logs.String()
+	for _, want := range []string{
+		`"status":400`,
+		`"path":"/token"`,
+		`"client_id":"random_client_id"`,
+		`"correlation_id":"random_correlation_id"`,
+		`state=random_state`,
+		`code=REDACTED`,
+	} {
+		if !strings.Contains(log, want) {
+			t.Errorf("the log %s must contain %s", log, want)
+		}
+	}
+
+	for _, secret := range []string{"random_secret", "random_code"} {
+		if strings.Contains(log, secret) {
+			t.Errorf("the log %s must not contain %s", log, secret)
+		}
+	}
+}
+
+func TestLoggingMiddleware_BodyNotRead(t *testing.T) {
+	// Given.
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, nil))
+	handler := goidc.LoggingMiddleware(logger)(http.HandlerFunc(
+		func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+		},
+	))
+
+	body := strings.NewReader(url.Values{"client_id": {"random_client_id"}}.Encode())
+	req := httptest.NewRequest(http.MethodPost, "/token", body)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	// When.
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	// Then.
+	if body.Len() == 0 {
+		t.Error("the middleware must not read the body the handler didn't read")
+	}
+
+	if strings.Contains(logs.String(), "random_client_id") {
+		t.Errorf("the log %s must not contain the client ID", logs.String())
+	}
+}
@@ -1,14 +1,30 @@
 package goidc
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"crypto/tls"
 	"crypto/x509"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"net"
 	"net/http"
+	"net/netip"
+	"net/url"
 	"slices"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/go-jose/go-jose/v4"
+	"github.com/luikyv/go-oidc/internal/timeutil"
 )
 
 // RefreshTokenLength has an unusual value so to avoid refresh tokens and
@@ -26,6 +42,15 @@ const (
 	ProfileFAPI2  Profile = "fapi2"
 )
 
+// FAPIAllowedCipherSuites are the TLS 1.2 cipher suites permitted by FAPI 2.0.
+// TLS 1.3 cipher suites are not configurable and are all accepted.
+var FAPIAllowedCipherSuites = []uint16{
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+}
+
 type GrantType string
 
 const (
@@ -143,6 +168,12 @@ const (
 	ClaimAccessTokenHash     string = "at_hash"
 	ClaimAuthzCodeHash       string = "c_hash"
 	ClaimStateHash           string = "s_hash"
+	ClaimSessionID           string = "sid"
+	ClaimNames               string = "_claim_names"
+	ClaimSources             string = "_claim_sources"
+	// ClaimInactiveReason is a non standard claim used to inform trusted
+	// callers of the introspection endpoint why a token is not active.
+	ClaimInactiveReason string = "inactive_reason"
 )
 
 type KeyUsage string
@@ -172,8 +203,18 @@ const (
 	// TODO: Implement pairwise.
 )
 
+// SubjectIdentifierFunc returns the subject identifier issued to the client for
+// the user informed, e.g. to scope identifiers to a tenant. It must always
+// return the same identifier for the same client and user.
+type SubjectIdentifierFunc func(client *Client, userID string) string
+
 const (
 	HeaderDPoP string = "DPoP"
+	// HeaderCorrelationID identifies the requests that belong to the same
+	// interaction.
+	HeaderCorrelationID string = "X-Correlation-ID"
+	// HeaderFAPIInteractionID is the correlation header defined by FAPI.
+	HeaderFAPIInteractionID string = "X-FAPI-Interaction-ID"
 )
 
 type AuthnStatus string
@@ -191,6 +232,20 @@ const (
 	TokenFormatOpaque TokenFormat = "opaque"
 )
 
+// TokenIssuer produces access tokens in a self-contained format other than JWT,
+// e.g. PASETO, Biscuit or CWT.
+// The grant session is stored as for the other formats, so introspection,
+// revocation and sender constraining work the same way. The confirmation of
+// sender constrained tokens is informed in the grant info.
+type TokenIssuer interface {
+	// Issue creates the access token for the grant informed. It returns the
+	// token and an identifier unique to it, e.g. its "jti" claim.
+	Issue(ctx context.Context, client *Client, grantInfo GrantInfo, opts TokenOptions) (token, id string, err error)
+	// ID verifies the token, e.g. its signature, and returns its identifier.
+	// ok is false if the token was not issued by this issuer or is not valid.
+	ID(ctx context.Context, token string) (id string, ok bool)
+}
+
 // AMR defines a type for authentication method references.
 type AMR string
 
@@ -242,6 +297,27 @@ const (
 	TokenHintRefresh TokenTypeHint = "refresh_token"
 )
 
+// TokenInactiveReason explains why a token is not active.
+type TokenInactiveReason string
+
+const (
+	TokenInactiveReasonExpired TokenInactiveReason = "expired"
+	// TokenInactiveReasonRevoked indicates the token is well formed and was
+	// issued by the server, but its grant no longer exists.
+	TokenInactiveReasonRevoked TokenInactiveReason = "revoked"
+	// TokenInactiveReasonUnknown indicates the token was not issued by the
+	// server or could not be found.
+	TokenInactiveReasonUnknown TokenInactiveReason = "unknown"
+	// TokenInactiveReasonCnfMismatch indicates the proof of possession
+	// presented with the token doesn't match its confirmation claim.
+	// The provider doesn't know the proof presented to resource servers, so
+	// this reason is only set by the validator in the resource package.
+	TokenInactiveReasonCnfMismatch TokenInactiveReason = "cnf_mismatch"
+	// TokenInactiveReasonClientDisabled indicates the client the token was
+	// issued to is disabled.
+	TokenInactiveReasonClientDisabled TokenInactiveReason = "client_disabled"
+)
+
 // ACR defines a type for authentication context references.
 type ACR string
 
@@ -253,15 +329,128 @@ const (
 
 type ClientCertFunc func(*http.Request) (*x509.Certificate, error)
 
+// TLSClientCertPolicy defines how the certificates presented by clients
+// authenticating with tls_client_auth are verified, in addition to comparing
+// their subject DN or SAN with the client's metadata.
+type TLSClientCertPolicy struct {
+	// RootCAsFunc returns the certificate authorities trusted to issue the
+	// certificate of the client. Different pools can be returned for clients
+	// of different ecosystems.
+	RootCAsFunc func(context.Context, *Client) (*x509.CertPool, error)
+	// Intermediates are the intermediate certificates used to build the
+	// chain. The intermediates presented during the TLS handshake, if any, are
+	// used as well.
+	Intermediates *x509.CertPool
+	// ExtKeyUsages are the extended key usages accepted for the client
+	// certificate. If empty, [x509.ExtKeyUsageClientAuth] is required.
+	ExtKeyUsages []x509.ExtKeyUsage
+	// CheckRevocationFunc verifies that no certificate in the chain was
+	// revoked, e.g. with OCSP or CRLs. The chain starts with the client
+	// certificate and ends with the root CA.
+	// If nil, revocation is not checked.
+	CheckRevocationFunc func(ctx context.Context, chain []*x509.Certificate) error
+}
+
+// CRLRevocationCheck returns a function for
+// [TLSClientCertPolicy.CheckRevocationFunc] that rejects the certificates
+// present in the revocation lists.
+// The lists must be verified and refreshed by the caller.
+func CRLRevocationCheck(
+	lists ...*x509.RevocationList,
+) func(context.Context, []*x509.Certificate) error {
+	return func(_ context.Context, chain []*x509.Certificate) error {
+		for _, cert := range chain {
+			for _, list := range lists {
+				if !bytes.Equal(list.RawIssuer, cert.RawIssuer) {
+					continue
+				}
+				for _, entry := range list.RevokedCertificateEntries {
+					if entry.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+						return fmt.Errorf("the certificate %s was revoked", cert.Subject)
+					}
+				}
+			}
+		}
+		return nil
+	}
+}
+
 type MiddlewareFunc func(next http.Handler) http.Handler
 
 // HandleDynamicClientFunc defines a function that will be executed during DCR
 // and DCM.
 // It can be used to modify the client and perform custom validations.
+// It runs before the client metadata is validated, so the metadata informed
+// might not be valid yet.
 type HandleDynamicClientFunc func(*http.Request, *ClientMetaInfo) error
 
 type ValidateInitialAccessTokenFunc func(*http.Request, string) error
 
+// InitialAccessTokenOptions defines the initial access tokens issued by the
+// provider for dynamic client registration.
+type InitialAccessTokenOptions struct {
+	// LifetimeSecs defines for how long the token can be used to register
+	// clients.
+	LifetimeSecs int
+	// ScopeIDs, if not empty, are the only scopes clients registered with the
+	// token can have. Clients that don't inform any scope are registered with
+	// all of them.
+	ScopeIDs []string
+}
+
+// DCRChallengeFunc defines a function executed before a client registers
+// without an initial access token, e.g. to verify a captcha response or a
+// proof of work sent with the request.
+// If an error is returned, the registration is denied.
+type DCRChallengeFunc func(*http.Request) error
+
+// BeforeRequestFunc defines a function that is executed before a request to
+// an endpoint of the provider is handled. endpoint is the pattern the
+// endpoint is registered with, e.g. "POST /token".
+// If an error is returned, the request is not handled and the error is written
+// as the response, e.g. an error with code [ErrorCodeSlowDown] when a quota is
+// exceeded.
+type BeforeRequestFunc func(r *http.Request, endpoint string) error
+
+// AfterResponseFunc defines a function that is executed after a request to an
+// endpoint of the provider is handled.
+type AfterResponseFunc func(r *http.Request, outcome EndpointOutcome)
+
+// EndpointOutcome describes how a request to an endpoint was handled.
+type EndpointOutcome struct {
+	// Endpoint is the pattern the endpoint is registered with, e.g.
+	// "POST /token".
+	Endpoint string
+	// Client is the client authenticated during the request, if any.
+	Client *Client
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+	// Err is the error reported during the request, if any. Errors redirected
+	// to the client are also reported.
+	Err      error
+	Duration time.Duration
+}
+
+// RequestLimits bounds the resources used by the requests to an endpoint.
+// Zero values mean no limit.
+type RequestLimits struct {
+	// MaxBodyBytes is the maximum size of the request body. Larger requests
+	// are rejected with the status 413 and the error invalid_request.
+	MaxBodyBytes int64
+	// Timeout is the maximum time to handle a request. When it is exceeded,
+	// the context of the request is cancelled and the error
+	// [ErrorCodeTemporarilyUnavailable] is returned.
+	Timeout time.Duration
+}
+
+// RetiredKey is a key no longer published by the server, but still accepted
+// until ExpiresAtTimestamp, e.g. to decrypt the request objects clients
+// encrypted before a key rotation.
+type RetiredKey struct {
+	Key                jose.JSONWebKey
+	ExpiresAtTimestamp int
+}
+
 // RenderErrorFunc defines a function that will be called when errors
 // during the authorization request cannot be handled.
 type RenderErrorFunc func(http.ResponseWriter, *http.Request, error) error
@@ -281,12 +470,86 @@ var (
 // scope is a match or not.
 type MatchScopeFunc func(requestedScope string) bool
 
+// ParseScopeFunc defines a function executed to extract the parameters
+// embedded in a requested scope.
+type ParseScopeFunc func(requestedScope string) (map[string]any, error)
+
 type Scope struct {
 	// ID is the string representation of the scope.
 	// Its value will be published as is in the well known endpoint.
 	ID string
 	// Matches validates if a requested scope matches the current scope.
 	Matches MatchScopeFunc
+	// Parse extracts the parameters of a requested scope that matches the
+	// current scope.
+	// It is only defined for structured scopes.
+	Parse ParseScopeFunc
+	// Title is a short human readable name of the scope that consent pages
+	// can show to users, e.g. "Your profile".
+	Title string
+	// Description explains to users what the scope gives access to.
+	// Descriptions are published at "scope_descriptions" in the well known
+	// endpoint.
+	Description string
+	// LocalizedTitles and LocalizedDescriptions map BCP47 language tags, e.g.
+	// "pt-BR", to the translations of the title and description.
+	LocalizedTitles       map[string]string
+	LocalizedDescriptions map[string]string
+}
+
+// ScopeInfo is the human readable information of a scope requested by a
+// client. It is available to authentication policies through
+// [AuthnSession.ScopeInfos] so they can render consent pages.
+type ScopeInfo struct {
+	// ID is the scope as requested by the client, e.g. "payment:30" for a
+	// dynamic scope.
+	ID                    string            `json:"id"`
+	Title                 string            `json:"title,omitempty"`
+	Description           string            `json:"description,omitempty"`
+	LocalizedTitles       map[string]string `json:"localized_titles,omitempty"`
+	LocalizedDescriptions map[string]string `json:"localized_descriptions,omitempty"`
+}
+
+// LocalizedTitle returns the title that best matches the BCP47 language tag
+// informed, falling back to the default title and then to the scope ID.
+func (s ScopeInfo) LocalizedTitle(tag string) string {
+	title := localizedValue(s.LocalizedTitles, "", tag, s.Title)
+	if title == "" {
+		return s.ID
+	}
+	return title
+}
+
+// LocalizedDescription returns the description that best matches the BCP47
+// language tag informed, falling back to the default description.
+func (s ScopeInfo) LocalizedDescription(tag string) string {
+	return localizedValue(s.LocalizedDescriptions, "", tag, s.Description)
+}
+
+// Params returns the parameters of the first scope in the space separated
+// list of scopes that matches the current scope.
+// It can be used inside authentication policies and token options to read
+// the payload of structured scopes.
+//
+//	params, ok := paymentScope.Params(session.Scopes)
+func (s Scope) Params(scopes string) (map[string]any, bool) {
+	if s.Parse == nil {
+		return nil, false
+	}
+
+	for _, requestedScope := range strings.Split(scopes, " ") {
+		if !s.Matches(requestedScope) {
+			continue
+		}
+
+		params, err := s.Parse(requestedScope)
+		if err != nil {
+			continue
+		}
+		return params, true
+	}
+
+	return nil, false
 }
 
 // NewScope creates a scope where the validation logic is simple string comparison.
@@ -321,11 +584,212 @@ func NewDynamicScope(
 	}
 }
 
+// NewStructuredScope creates a scope whose requested values carry parameters
+// encoded as base64url JSON objects in the format "<scope>:base64url(json)".
+//
+//	paymentScope := NewStructuredScope("payment")
+//
+//	// This results in true.
+//	paymentScope.Matches("payment:eyJhbW91bnQiOjMwfQ")
+//
+//	// This results in map[string]any{"amount": 30}.
+//	params, _ := paymentScope.Params("openid payment:eyJhbW91bnQiOjMwfQ")
+func NewStructuredScope(scope string) Scope {
+	parse := func(requestedScope string) (map[string]any, error) {
+		payload, ok := strings.CutPrefix(requestedScope, scope+":")
+		if !ok {
+			return nil, errors.New("the scope does not match")
+		}
+
+		payloadBytes, err := base64.RawURLEncoding.DecodeString(
+			strings.TrimRight(payload, "="),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("invalid scope encoding: %w", err)
+		}
+
+		var params map[string]any
+		if err := json.Unmarshal(payloadBytes, &params); err != nil {
+			return nil, fmt.Errorf("invalid scope payload: %w", err)
+		}
+
+		if params == nil {
+			return nil, errors.New("the scope payload must be a json object")
+		}
+
+		return params, nil
+	}
+
+	return Scope{
+		ID: scope,
+		Matches: func(requestedScope string) bool {
+			_, err := parse(requestedScope)
+			return err == nil
+		},
+		Parse: parse,
+	}
+}
+
 // CheckJTIFunc defines a function to verify when a JTI is safe to use.
 type CheckJTIFunc func(context.Context, string) error
 
+// CheckNonceFunc defines a function to verify that a nonce was not used before
+// by the client. The nonce must be remembered at least until expiresAtTimestamp
+// so replays are detected during the lifetime of the ID tokens issued with it.
+type CheckNonceFunc func(ctx context.Context, clientID, nonce string, expiresAtTimestamp int) error
+
+// NonceCache keeps the nonces used by clients in memory until they expire.
+// Since nonces are not shared between instances of the provider, a storage
+// backed [CheckNonceFunc] should be used when running multiple instances.
+//
+//	cache := goidc.NewNonceCache()
+//	provider.WithNonceReplayPrevention(cache.CheckNonce)
+type NonceCache struct {
+	mu     sync.Mutex
+	nonces map[string]int
+}
+
+func NewNonceCache() *NonceCache {
+	return &NonceCache{
+		nonces: map[string]int{},
+	}
+}
+
+// CheckNonce implements [CheckNonceFunc].
+func (c *NonceCache) CheckNonce(_ context.Context, clientID, nonce string, expiresAtTimestamp int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := timeutil.TimestampNow()
+	for key, exp := range c.nonces {
+		if exp <= now {
+			delete(c.nonces, key)
+		}
+	}
+
+	key := clientID + " " + nonce
+	if _, ok := c.nonces[key]; ok {
+		return errors.New("the nonce was already used")
+	}
+	c.nonces[key] = expiresAtTimestamp
+	return nil
+}
+
 type HTTPClientFunc func(ctx context.Context) *http.Client
 
+// IDType identifies what an ID is generated for.
+type IDType string
+
+const (
+	IDTypeAuthnSession IDType = "authn_session"
+	IDTypeGrantSession IDType = "grant_session"
+	IDTypeUserSession  IDType = "user_session"
+	IDTypeCallback     IDType = "callback"
+	// IDTypeRequestURI is the random part of the request URIs returned by the
+	// pushed authorization endpoint, i.e. what follows
+	// "urn:ietf:params:oauth:request_uri:".
+	IDTypeRequestURI IDType = "request_uri"
+	IDTypeClient     IDType = "client"
+	// IDTypeSessionID is the sid claim of ID tokens.
+	IDTypeSessionID IDType = "session_id"
+)
+
+// IDFunc generates the IDs of the type informed, e.g. UUIDv7 for database
+// locality or IDs with a prefix identifying their type.
+// If an empty ID is returned, the default one is used.
+// Callback IDs and request URIs are handed to user agents, so they must be
+// unguessable.
+type IDFunc func(ctx context.Context, idType IDType) string
+
+// Endpoint identifies an endpoint of the provider that can be served on the
+// mutual TLS host.
+type Endpoint string
+
+const (
+	EndpointToken               Endpoint = "token"
+	EndpointPushedAuthorization Endpoint = "pushed_authorization"
+	EndpointUserInfo            Endpoint = "userinfo"
+	EndpointDCR                 Endpoint = "registration"
+	EndpointIntrospection       Endpoint = "introspection"
+	EndpointTokenRevocation     Endpoint = "revocation"
+)
+
+// EndpointHost defines on which hosts an endpoint is served when mutual TLS
+// is enabled.
+type EndpointHost string
+
+const (
+	// EndpointHostAll serves the endpoint on both the main and the mutual TLS
+	// hosts. This is the default.
+	EndpointHostAll EndpointHost = "all"
+	// EndpointHostMain serves the endpoint only on the main host.
+	EndpointHostMain EndpointHost = "main"
+	// EndpointHostMTLS serves the endpoint only on the mutual TLS host.
+	EndpointHostMTLS EndpointHost = "mtls"
+)
+
+// ClientIPFunc defines a function that returns the address of the client
+// making the request. It can be used when the provider is behind a proxy, e.g.
+// by reading a trusted X-Forwarded-For header.
+type ClientIPFunc func(*http.Request) (netip.Addr, error)
+
+// URIPolicyFunc defines a function that decides whether the server is allowed
+// to fetch a URI informed by a client, e.g. jwks_uri during registration.
+// An error must be returned if the URI is not allowed.
+type URIPolicyFunc func(ctx context.Context, uri *url.URL) error
+
+// DenyPrivateNetworkURIs is a [URIPolicyFunc] that rejects URIs whose host
+// resolves to loopback, private, shared (CGNAT), link local or unspecified
+// addresses, which prevents clients from making the server reach internal
+// resources (SSRF).
+// Since the host can resolve to a different address when it is fetched, this
+// must be combined with [DenyPrivateNetworkDialControl] in the HTTP client.
+func DenyPrivateNetworkURIs(ctx context.Context, uri *url.URL) error {
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, uri.Hostname())
+	if err != nil {
+		return fmt.Errorf("could not resolve the host %s: %w", uri.Hostname(), err)
+	}
+
+	for _, addr := range addrs {
+		if !isPublicIP(addr.IP) {
+			return fmt.Errorf("the host %s resolves to a non public address", uri.Hostname())
+		}
+	}
+
+	return nil
+}
+
+// DenyPrivateNetworkDialControl is a [net.Dialer] Control function that
+// rejects connections to the addresses denied by [DenyPrivateNetworkURIs].
+// Since it checks the address actually dialed, a host cannot pass validation
+// and then resolve to an internal address (DNS rebinding).
+func DenyPrivateNetworkDialControl(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+		return fmt.Errorf("the address %s is not public", host)
+	}
+	return nil
+}
+
+// sharedAddressSpace is the range reserved for carrier-grade NAT (RFC 6598).
+var sharedAddressSpace = &net.IPNet{
+	IP:   net.IPv4(100, 64, 0, 0),
+	Mask: net.CIDRMask(10, 32),
+}
+
+func isPublicIP(ip net.IP) bool {
+	return !ip.IsLoopback() &&
+		!ip.IsPrivate() &&
+		!ip.IsLinkLocalUnicast() &&
+		!ip.IsLinkLocalMulticast() &&
+		!ip.IsUnspecified() &&
+		!sharedAddressSpace.Contains(ip)
+}
+
 type ShouldIssueRefreshTokenFunc func(*Client, GrantInfo) bool
 
 // TokenOptionsFunc defines a function that returns token configuration and is
@@ -338,6 +802,11 @@ type TokenOptions struct {
 	LifetimeSecs      int
 	JWTSignatureKeyID string
 	OpaqueLength      int
+	// OpaqueIsStateless indicates that the opaque token is an encrypted handle
+	// containing the grant information, so it can be introspected without a
+	// storage lookup.
+	// The provider must be configured with a token encryption key.
+	OpaqueIsStateless bool
 }
 
 func NewJWTTokenOptions(
@@ -362,6 +831,20 @@ func NewOpaqueTokenOptions(
 	}
 }
 
+// NewStatelessOpaqueTokenOptions creates options for opaque tokens that are
+// AEAD encrypted handles readable only by the authorization server.
+// The token carries the grant and expiry information, so introspecting it
+// doesn't require fetching the grant session.
+// Note: the information of the token is fixed when it is issued, then
+// revoking the grant session doesn't invalidate it.
+func NewStatelessOpaqueTokenOptions(lifetimeSecs int) TokenOptions {
+	return TokenOptions{
+		Format:            TokenFormatOpaque,
+		LifetimeSecs:      lifetimeSecs,
+		OpaqueIsStateless: true,
+	}
+}
+
 // AuthnFunc executes the user authentication logic.
 // If it returns [StatusSuccess], the flow will end successfully and the client
 // will be granted the accesses the user consented.
@@ -383,6 +866,26 @@ type AuthnPolicy struct {
 	ID           string
 	SetUp        SetUpAuthnFunc
 	Authenticate AuthnFunc
+	// ACRs are the authentication context references the policy is able to
+	// achieve.
+	// When informed, the policy is only selected if it can achieve the ACRs
+	// requested by the client. If empty, the policy is considered able to
+	// achieve any ACR.
+	ACRs []ACR
+}
+
+// CanAchieve returns whether the policy may achieve one of the ACRs informed.
+func (p AuthnPolicy) CanAchieve(acrs []ACR) bool {
+	if len(p.ACRs) == 0 || len(acrs) == 0 {
+		return true
+	}
+
+	for _, acr := range acrs {
+		if slices.Contains(p.ACRs, acr) {
+			return true
+		}
+	}
+	return false
 }
 
 // NewPolicy creates a policy that will be selected based on setUpFunc and that
@@ -399,11 +902,88 @@ func NewPolicy(
 	}
 }
 
+// AuthnStep executes one stage of a sequential authentication policy, e.g.
+// login, MFA or consent.
+// If it returns [StatusSuccess], the next step is executed right away.
+// If it returns [StatusInProgress], the flow is suspended and it is resumed
+// at the same step when the callback endpoint is called.
+// If it returns [StatusFailure] or an error, the flow ends with failure.
+type AuthnStep func(http.ResponseWriter, *http.Request, *AuthnSession) (AuthnStatus, error)
+
+// authnStepParam is the key of the session store where the index of the
+// current step of a sequential policy is kept.
+const authnStepParam = "goidc_authn_step"
+
+// NewSequentialPolicy creates a policy that will be selected based on
+// setUpFunc and that authenticates users by running the steps in order.
+// The index of the current step is persisted in the session store, so the
+// flow resumes where it stopped after user interaction.
+//
+//	policy := NewSequentialPolicy(
+//		"policy",
+//		func(r *http.Request, c *Client, s *AuthnSession) bool { return true },
+//		loginStep,
+//		mfaStep,
+//		consentStep,
+//	)
+func NewSequentialPolicy(
+	id string,
+	setUpFunc SetUpAuthnFunc,
+	steps ...AuthnStep,
+) AuthnPolicy {
+	authnFunc := func(
+		w http.ResponseWriter,
+		r *http.Request,
+		session *AuthnSession,
+	) (
+		AuthnStatus,
+		error,
+	) {
+		for i := session.authnStep(); i < len(steps); i++ {
+			session.StoreParameter(authnStepParam, i)
+			status, err := steps[i](w, r, session)
+			if err != nil {
+				return StatusFailure, err
+			}
+
+			if status != StatusSuccess {
+				return status, nil
+			}
+		}
+
+		delete(session.Store, authnStepParam)
+		return StatusSuccess, nil
+	}
+
+	return NewPolicy(id, setUpFunc, authnFunc)
+}
+
 type TokenConfirmation struct {
 	JWKThumbprint        string `json:"jkt"`
 	ClientCertThumbprint string `json:"x5t#S256"`
 }
 
+// IntrospectionCaller identifies the entity calling the introspection endpoint.
+// Exactly one of its fields is set depending on how the caller authenticated.
+type IntrospectionCaller struct {
+	// Client is the client authenticated at the introspection endpoint.
+	Client *Client
+	// Token is the information about the access token a resource server used
+	// as credential.
+	Token *TokenInfo
+}
+
+// FilterIntrospectionFunc defines a function that is executed before the
+// information of an active token is returned at the introspection endpoint.
+// It can modify the token information, e.g. drop scopes, authorization details
+// or claims that are not relevant to the calling resource server.
+type FilterIntrospectionFunc func(*http.Request, IntrospectionCaller, *TokenInfo)
+
+// IsIntrospectionCallerTrustedFunc defines a function that decides whether the
+// caller of the introspection endpoint is trusted to receive the reason why a
+// token is not active.
+type IsIntrospectionCallerTrustedFunc func(*http.Request, IntrospectionCaller) bool
+
 type TokenInfo struct {
 	// GrantID is the ID of the grant session associated to token.
 	GrantID               string                `json:"-"`
@@ -417,6 +997,9 @@ type TokenInfo struct {
 	ExpiresAtTimestamp    int                   `json:"exp,omitempty"`
 	Confirmation          *TokenConfirmation    `json:"cnf,omitempty"`
 	AdditionalTokenClaims map[string]any        `json:"-"`
+	// Reason is the reason why the token is not active.
+	// It is only informed when IsActive is false.
+	Reason TokenInactiveReason `json:"-"`
 }
 
 func (ti TokenInfo) MarshalJSON() ([]byte, error) {
@@ -489,6 +1072,64 @@ func (resources Resources) MarshalJSON() ([]byte, error) {
 	return json.Marshal([]string(resources))
 }
 
+// ResolveIssuerFunc defines a function that returns the issuer responsible for
+// authenticating the user identified by the WebFinger resource, e.g.
+// "acct:joe@example.com".
+// If an error is returned, the resource is considered unknown.
+type ResolveIssuerFunc func(r *http.Request, resource string) (string, error)
+
+// FilterClaimsFunc defines a function that transforms the user claims before
+// they are returned in ID tokens and user info responses, e.g. to redact claims
+// for low trust clients.
+// The claims returned replace the ones informed.
+type FilterClaimsFunc func(context.Context, *Client, map[string]any) map[string]any
+
+// AddressClaim is the value of the "address" claim as defined by OpenID
+// Connect Core, section 5.1.1.
+type AddressClaim struct {
+	// Formatted is the full mailing address, which may contain new lines.
+	Formatted     string `json:"formatted,omitempty"`
+	StreetAddress string `json:"street_address,omitempty"`
+	// Locality is the city or locality.
+	Locality   string `json:"locality,omitempty"`
+	Region     string `json:"region,omitempty"`
+	PostalCode string `json:"postal_code,omitempty"`
+	Country    string `json:"country,omitempty"`
+}
+
+// StandardClaimMappings are the claims OpenID Connect Core defines to be
+// requested with the profile, email, address and phone scopes.
+var StandardClaimMappings = map[string][]string{
+	ScopeProfile.ID: {
+		ClaimName, ClaimFamilyName, ClaimGivenName, ClaimMiddleName,
+		ClaimNickname, ClaimPreferredUsername, ClaimProfile, ClaimPicture,
+		ClaimWebsite, ClaimGender, ClaimBirthdate, ClaimZoneInfo, ClaimLocale,
+		ClaimUpdatedAt,
+	},
+	ScopeEmail.ID:   {ClaimEmail, ClaimEmailVerified},
+	ScopeAddress.ID: {ClaimAddress},
+	ScopePhone.ID:   {ClaimPhoneNumber, ClaimPhoneNumberVerified},
+}
+
+// IDTokenClaimsMode defines which of the user claims mapped to scopes, e.g.
+// the standard profile claims, are placed in ID tokens. Claims requested for
+// the ID token with the claims parameter are always placed in it.
+type IDTokenClaimsMode int
+
+const (
+	// IDTokenClaimsAll places in the ID token all the claims the grant gives
+	// access to. This is the default.
+	IDTokenClaimsAll IDTokenClaimsMode = iota
+	// IDTokenClaimsByResponseType follows OpenID Connect Core and places the
+	// claims of the scopes granted in the ID token only when no access token is
+	// issued, e.g. for the response type "id_token". Otherwise, they are only
+	// returned by the user info endpoint.
+	IDTokenClaimsByResponseType
+	// IDTokenClaimsRequested places in the ID token only the claims mapped to
+	// scopes that were requested for it with the claims parameter.
+	IDTokenClaimsRequested
+)
+
 type ClaimsObject struct {
 	UserInfo map[string]ClaimObjectInfo `json:"userinfo"`
 	IDToken  map[string]ClaimObjectInfo `json:"id_token"`
@@ -613,3 +1254,62 @@ type IsClientAllowedFunc func(*Client) bool
 // refresh_token grant types to validate that the requested authorization details
 // are consistent with the granted ones.
 type CompareAuthDetailsFunc func(granted, requested []AuthorizationDetail) error
+
+// AuthDetailsClaimFunc selects which of the authorization details of a grant
+// are returned in the "authorization_details" claim of ID tokens and user info
+// responses, e.g. to leave out the details meant only for resource servers.
+// If no authorization detail is returned, the claim is omitted.
+type AuthDetailsClaimFunc func(
+	ctx context.Context,
+	client *Client,
+	grantInfo GrantInfo,
+	details []AuthorizationDetail,
+) []AuthorizationDetail
+
+// HalfHashClaim computes the value of the ID token hash claims, i.e. at_hash,
+// c_hash and s_hash, for the value informed.
+// The hash is the base64url encoding of the left-most half of the hash of the
+// value, using the hash algorithm associated with the ID token signature
+// algorithm.
+func HalfHashClaim(value string, alg jose.SignatureAlgorithm) string {
+	var h hash.Hash
+	switch alg {
+	case jose.RS384, jose.ES384, jose.PS384, jose.HS384:
+		h = sha512.New384()
+	case jose.RS512, jose.ES512, jose.PS512, jose.HS512:
+		h = sha512.New()
+	default:
+		h = sha256.New()
+	}
+
+	h.Write([]byte(value))
+	halfHashedClaim := h.Sum(nil)[:h.Size()/2]
+	return base64.RawURLEncoding.EncodeToString(halfHashedClaim)
+}
+
+// VerifyHalfHashClaim reports whether hashClaim, e.g. the value of an at_hash,
+// c_hash or s_hash claim, matches the value informed.
+func VerifyHalfHashClaim(
+	hashClaim string,
+	value string,
+	alg jose.SignatureAlgorithm,
+) bool {
+	want := HalfHashClaim(value, alg)
+	return subtle.ConstantTimeCompare([]byte(hashClaim), []byte(want)) == 1
+}
+
+// VerifyStateHash reports whether the s_hash claim of an ID token used as a
+// detached signature matches the state returned in the authorization response.
+// If the state is empty, the s_hash claim must not be present.
+func VerifyStateHash(
+	idTokenClaims map[string]any,
+	state string,
+	alg jose.SignatureAlgorithm,
+) bool {
+	stateHash, ok := idTokenClaims[ClaimStateHash].(string)
+	if !ok {
+		return state == ""
+	}
+
+	return VerifyHalfHashClaim(stateHash, state, alg)
+}
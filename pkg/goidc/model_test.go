@@ -1,8 +1,15 @@
 package goidc_test
 
 import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"testing"
+	"time"
 
+	"github.com/go-jose/go-jose/v4"
 	"github.com/google/go-cmp/cmp"
 	"github.com/luikyv/go-oidc/pkg/goidc"
 )
@@ -31,3 +38,329 @@ func TestAuthorizationDetails(t *testing.T) {
 		t.Errorf(diff)
 	}
 }
+
+func TestHalfHashClaim(t *testing.T) {
+	testCases := []struct {
+		alg  jose.SignatureAlgorithm
+		want string
+	}{
+		{jose.RS256, "VOwDqWyt4Ytv5N7KlkWzzg"},
+		{jose.PS384, "xEP50XfJPwmdjOEx6pDCly3yAazUmfYG"},
+		{jose.ES512, "KvPUFGvM-vJxmFV0lZ9Zpet96_-NSAm6Zm4fnmRL5dI"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(string(testCase.alg), func(t *testing.T) {
+			// When.
+			got := goidc.HalfHashClaim("random_state", testCase.alg)
+
+			// Then.
+			if got != testCase.want {
+				t.Errorf("HalfHashClaim() = %s, want %s", got, testCase.want)
+			}
+
+			if !goidc.VerifyHalfHashClaim(got, "random_state", testCase.alg) {
+				t.Error("the hash claim should be valid")
+			}
+
+			if goidc.VerifyHalfHashClaim(got, "invalid_state", testCase.alg) {
+				t.Error("the hash claim should not be valid for a different value")
+			}
+		})
+	}
+}
+
+func TestVerifyStateHash(t *testing.T) {
+	// Given.
+	claims := map[string]any{
+		goidc.ClaimStateHash: goidc.HalfHashClaim("random_state", jose.PS256),
+	}
+
+	// Then.
+	if !goidc.VerifyStateHash(claims, "random_state", jose.PS256) {
+		t.Error("the s_hash claim should be valid")
+	}
+
+	if goidc.VerifyStateHash(claims, "invalid_state", jose.PS256) {
+		t.Error("the s_hash claim should not be valid for a different state")
+	}
+
+	if goidc.VerifyStateHash(map[string]any{}, "random_state", jose.PS256) {
+		t.Error("a missing s_hash claim should not be valid when state is informed")
+	}
+}
+
+func TestNewStructuredScope(t *testing.T) {
+	// Given.
+	scope := goidc.NewStructuredScope("payment")
+
+	// Then.
+	if !scope.Matches("payment:eyJhbW91bnQiOjMwfQ") {
+		t.Error("the structured scope should match")
+	}
+
+	if scope.Matches("payment") {
+		t.Error("the scope without payload should not match")
+	}
+
+	if scope.Matches("payment:invalid") {
+		t.Error("the scope with an invalid payload should not match")
+	}
+
+	if scope.Matches("payments:eyJhbW91bnQiOjMwfQ") {
+		t.Error("a scope with a different prefix should not match")
+	}
+}
+
+func TestScope_Params(t *testing.T) {
+	// Given.
+	scope := goidc.NewStructuredScope("payment")
+
+	// When.
+	params, ok := scope.Params("openid payment:eyJhbW91bnQiOjMwfQ")
+
+	// Then.
+	if !ok {
+		t.Fatal("the params should be found")
+	}
+
+	if diff := cmp.Diff(params, map[string]any{"amount": float64(30)}); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestScopeInfo_Localized(t *testing.T) {
+	// Given.
+	info := goidc.ScopeInfo{
+		ID:                    "profile",
+		Title:                 "Your profile",
+		Description:           "Your name and picture.",
+		LocalizedTitles:       map[string]string{"pt": "Seu perfil"},
+		LocalizedDescriptions: map[string]string{"pt-BR": "Seu nome e foto."},
+	}
+
+	testCases := []struct {
+		tag             string
+		wantTitle       string
+		wantDescription string
+	}{
+		{"pt-BR", "Seu perfil", "Seu nome e foto."},
+		{"pt-PT", "Seu perfil", "Your name and picture."},
+		{"en", "Your profile", "Your name and picture."},
+	}
+
+	for _, testCase := range testCases {
+		// When.
+		title := info.LocalizedTitle(testCase.tag)
+		description := info.LocalizedDescription(testCase.tag)
+
+		// Then.
+		if title != testCase.wantTitle {
+			t.Errorf("LocalizedTitle(%s) = %s, want %s", testCase.tag, title, testCase.wantTitle)
+		}
+
+		if description != testCase.wantDescription {
+			t.Errorf("LocalizedDescription(%s) = %s, want %s", testCase.tag, description, testCase.wantDescription)
+		}
+	}
+}
+
+func TestScopeInfo_LocalizedTitleFallsBackToID(t *testing.T) {
+	// Given.
+	info := goidc.ScopeInfo{ID: "payment:30"}
+
+	// When.
+	title := info.LocalizedTitle("en")
+
+	// Then.
+	if title != "payment:30" {
+		t.Errorf("LocalizedTitle() = %s, want payment:30", title)
+	}
+}
+
+func TestScope_Params_NotStructured(t *testing.T) {
+	// When.
+	_, ok := goidc.ScopeOpenID.Params("openid")
+
+	// Then.
+	if ok {
+		t.Error("simple scopes should not have params")
+	}
+}
+
+func TestNewSequentialPolicy(t *testing.T) {
+	// Given.
+	var calls []string
+	firstStep := func(w http.ResponseWriter, r *http.Request, s *goidc.AuthnSession) (goidc.AuthnStatus, error) {
+		calls = append(calls, "first")
+		return goidc.StatusSuccess, nil
+	}
+	secondStep := func(w http.ResponseWriter, r *http.Request, s *goidc.AuthnSession) (goidc.AuthnStatus, error) {
+		calls = append(calls, "second")
+		if r.URL.Query().Get("otp") == "" {
+			return goidc.StatusInProgress, nil
+		}
+		return goidc.StatusSuccess, nil
+	}
+	thirdStep := func(w http.ResponseWriter, r *http.Request, s *goidc.AuthnSession) (goidc.AuthnStatus, error) {
+		calls = append(calls, "third")
+		return goidc.StatusSuccess, nil
+	}
+
+	policy := goidc.NewSequentialPolicy(
+		"random_policy",
+		func(r *http.Request, c *goidc.Client, s *goidc.AuthnSession) bool {
+			return true
+		},
+		firstStep,
+		secondStep,
+		thirdStep,
+	)
+	session := &goidc.AuthnSession{}
+
+	// When.
+	status, err := policy.Authenticate(
+		httptest.NewRecorder(),
+		httptest.NewRequest(http.MethodGet, "/authorize", nil),
+		session,
+	)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if status != goidc.StatusInProgress {
+		t.Fatalf("status = %s, want %s", status, goidc.StatusInProgress)
+	}
+
+	// Simulate the session being persisted as JSON between interactions.
+	sessionBytes, _ := json.Marshal(session)
+	session = &goidc.AuthnSession{}
+	_ = json.Unmarshal(sessionBytes, session)
+
+	// When.
+	status, err = policy.Authenticate(
+		httptest.NewRecorder(),
+		httptest.NewRequest(http.MethodPost, "/authorize/callback?otp=123", nil),
+		session,
+	)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if status != goidc.StatusSuccess {
+		t.Errorf("status = %s, want %s", status, goidc.StatusSuccess)
+	}
+
+	if diff := cmp.Diff(calls, []string{"first", "second", "second", "third"}); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestNewSequentialPolicy_StepFails(t *testing.T) {
+	// Given.
+	policy := goidc.NewSequentialPolicy(
+		"random_policy",
+		func(r *http.Request, c *goidc.Client, s *goidc.AuthnSession) bool {
+			return true
+		},
+		func(w http.ResponseWriter, r *http.Request, s *goidc.AuthnSession) (goidc.AuthnStatus, error) {
+			return goidc.StatusSuccess, goidc.NewError(goidc.ErrorCodeAccessDenied, "denied")
+		},
+	)
+
+	// When.
+	status, err := policy.Authenticate(
+		httptest.NewRecorder(),
+		httptest.NewRequest(http.MethodGet, "/authorize", nil),
+		&goidc.AuthnSession{},
+	)
+
+	// Then.
+	if err == nil {
+		t.Fatal("an error was expected")
+	}
+
+	if status != goidc.StatusFailure {
+		t.Errorf("status = %s, want %s", status, goidc.StatusFailure)
+	}
+}
+
+func TestDenyPrivateNetworkURIs(t *testing.T) {
+	testCases := []struct {
+		uri       string
+		isAllowed bool
+	}{
+		{"https://127.0.0.1/jwks", false},
+		{"https://10.0.0.1/jwks", false},
+		{"https://192.168.0.1/jwks", false},
+		{"https://169.254.169.254/latest/meta-data", false},
+		{"https://100.64.0.1/jwks", false},
+		{"https://[::1]/jwks", false},
+		{"https://8.8.8.8/jwks", true},
+	}
+
+	for _, testCase := range testCases {
+		// Given.
+		uri, _ := url.Parse(testCase.uri)
+
+		// When.
+		err := goidc.DenyPrivateNetworkURIs(context.Background(), uri)
+
+		// Then.
+		if isAllowed := err == nil; isAllowed != testCase.isAllowed {
+			t.Errorf("DenyPrivateNetworkURIs(%s) allowed = %t, want %t", testCase.uri, isAllowed, testCase.isAllowed)
+		}
+	}
+}
+
+func TestDenyPrivateNetworkDialControl(t *testing.T) {
+	testCases := []struct {
+		address   string
+		isAllowed bool
+	}{
+		{"127.0.0.1:443", false},
+		{"10.0.0.1:443", false},
+		{"100.100.100.100:443", false},
+		{"169.254.169.254:80", false},
+		{"[::ffff:127.0.0.1]:443", false},
+		{"[fd00::1]:443", false},
+		{"8.8.8.8:443", true},
+		{"[2001:4860:4860::8888]:443", true},
+	}
+
+	for _, testCase := range testCases {
+		// When.
+		err := goidc.DenyPrivateNetworkDialControl("tcp", testCase.address, nil)
+
+		// Then.
+		if isAllowed := err == nil; isAllowed != testCase.isAllowed {
+			t.Errorf("DenyPrivateNetworkDialControl(%s) allowed = %t, want %t", testCase.address, isAllowed, testCase.isAllowed)
+		}
+	}
+}
+
+func TestNonceCache(t *testing.T) {
+	// Given.
+	cache := goidc.NewNonceCache()
+	exp := int(time.Now().Unix()) + 60
+
+	// When.
+	err := cache.CheckNonce(context.Background(), "random_client_id", "random_nonce", exp)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := cache.CheckNonce(context.Background(), "random_client_id", "random_nonce", exp); err == nil {
+		t.Error("the nonce must not be reused by the same client")
+	}
+
+	if err := cache.CheckNonce(context.Background(), "other_client_id", "random_nonce", exp); err != nil {
+		t.Errorf("the nonce can be used by other clients: %v", err)
+	}
+}
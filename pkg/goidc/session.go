@@ -0,0 +1,87 @@
+package goidc
+
+import "context"
+
+// AuthnSessionLister is implemented by authentication session managers that
+// can enumerate the sessions they store. It is required for the
+// administrative session API.
+type AuthnSessionLister interface {
+	List(ctx context.Context, filter SessionFilter) ([]*AuthnSession, error)
+}
+
+// GrantSessionLister is implemented by grant session managers that can
+// enumerate the sessions they store. It is required for the administrative
+// session API.
+type GrantSessionLister interface {
+	List(ctx context.Context, filter SessionFilter) ([]*GrantSession, error)
+}
+
+// UserSessionLister is implemented by user session managers that can
+// enumerate the sessions they store. It is required for the administrative
+// session API.
+type UserSessionLister interface {
+	List(ctx context.Context, filter SessionFilter) ([]*UserSession, error)
+}
+
+// SessionFilter defines which sessions are returned when listing them.
+// Zero values mean no filtering, but expired sessions are never returned.
+type SessionFilter struct {
+	ID      string
+	Subject string
+	// ClientID does not apply to user sessions, since they are shared by all
+	// the clients.
+	ClientID string
+	// Offset is the number of matching sessions to skip.
+	Offset int
+	// Limit is the maximum number of sessions to return.
+	Limit int
+}
+
+// MatchesAuthnSession returns whether the authentication session satisfies
+// the filter, without considering pagination.
+func (f SessionFilter) MatchesAuthnSession(s *AuthnSession) bool {
+	return !s.IsExpired() && f.matches(s.ID, s.Subject, s.ClientID)
+}
+
+// MatchesGrantSession returns whether the grant session satisfies the filter,
+// without considering pagination.
+func (f SessionFilter) MatchesGrantSession(s *GrantSession) bool {
+	return !s.IsExpired() && f.matches(s.ID, s.Subject, s.ClientID)
+}
+
+// MatchesUserSession returns whether the user session satisfies the filter,
+// without considering pagination. The subject matches any of the accounts
+// signed in the session.
+func (f SessionFilter) MatchesUserSession(s *UserSession) bool {
+	if s.IsExpired() {
+		return false
+	}
+
+	if f.ID != "" && s.ID != f.ID {
+		return false
+	}
+
+	if f.Subject != "" {
+		if _, ok := s.Account(f.Subject); !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (f SessionFilter) matches(id, subject, clientID string) bool {
+	if f.ID != "" && id != f.ID {
+		return false
+	}
+
+	if f.Subject != "" && subject != f.Subject {
+		return false
+	}
+
+	if f.ClientID != "" && clientID != f.ClientID {
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,54 @@
+package goidc_test
+
+import (
+	"testing"
+
+	"github.com/luikyv/go-oidc/internal/timeutil"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+func TestSessionFilter_MatchesUserSession(t *testing.T) {
+	// Given.
+	session := &goidc.UserSession{
+		ID:                 "random_session_id",
+		Accounts:           []goidc.Account{{Subject: "random_subject_1"}, {Subject: "random_subject_2"}},
+		ExpiresAtTimestamp: timeutil.TimestampNow() + 60,
+	}
+
+	testCases := []struct {
+		filter goidc.SessionFilter
+		want   bool
+	}{
+		{goidc.SessionFilter{}, true},
+		{goidc.SessionFilter{Subject: "random_subject_2"}, true},
+		{goidc.SessionFilter{Subject: "random_subject_3"}, false},
+		{goidc.SessionFilter{ID: "invalid_session_id"}, false},
+		{goidc.SessionFilter{ClientID: "random_client_id"}, true},
+	}
+
+	for _, testCase := range testCases {
+		// When.
+		got := testCase.filter.MatchesUserSession(session)
+
+		// Then.
+		if got != testCase.want {
+			t.Errorf("MatchesUserSession(%+v) = %t, want %t", testCase.filter, got, testCase.want)
+		}
+	}
+}
+
+func TestSessionFilter_ExpiredSession(t *testing.T) {
+	// Given.
+	session := &goidc.GrantSession{
+		ID:                 "random_session_id",
+		ExpiresAtTimestamp: timeutil.TimestampNow() - 1,
+	}
+
+	// When.
+	got := goidc.SessionFilter{}.MatchesGrantSession(session)
+
+	// Then.
+	if got {
+		t.Error("expired sessions must not match")
+	}
+}
@@ -0,0 +1,51 @@
+package goidc
+
+import "context"
+
+// TracerProvider provides the tracer used to instrument the provider.
+// It mirrors the OpenTelemetry tracer provider, so one can be plugged in with
+// a thin adapter.
+type TracerProvider interface {
+	Tracer(name string) Tracer
+}
+
+// Tracer starts spans for the operations performed by the provider, e.g.
+// endpoint handling, client authentication, token minting and storage calls.
+type Tracer interface {
+	// Start creates a span as a child of the span in ctx, if any, and returns
+	// a context carrying the new span.
+	Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span)
+}
+
+// Span represents a single traced operation.
+type Span interface {
+	SetAttributes(attrs ...Attribute)
+	// RecordError marks the operation as failed.
+	RecordError(err error)
+	End()
+}
+
+// Attribute is a key value pair describing a span or a measurement.
+// Keys follow the OpenTelemetry semantic conventions when one applies, e.g.
+// "http.request.method".
+type Attribute struct {
+	Key   string
+	Value any
+}
+
+// InstrumentationName is the name used to obtain the tracer and the meter from
+// the [TracerProvider] and the [MeterProvider].
+const InstrumentationName = "github.com/luikyv/go-oidc"
+
+const (
+	AttributeHTTPMethod     = "http.request.method"
+	AttributeHTTPRoute      = "http.route"
+	AttributeHTTPStatusCode = "http.response.status_code"
+	AttributeURLPath        = "url.path"
+	AttributeClientID       = "oauth.client_id"
+	AttributeGrantType      = "oauth.grant_type"
+	AttributeClientAuthn    = "oauth.client_authn_method"
+	AttributeTokenFormat    = "oauth.token_format"
+	AttributeTokenEvent     = "oauth.token_event"
+	AttributeErrorType      = "error.type"
+)
@@ -0,0 +1,68 @@
+package goidc
+
+import (
+	"context"
+	"slices"
+
+	"github.com/luikyv/go-oidc/internal/timeutil"
+)
+
+// UserSessionManager contains all the logic needed to manage the single sign
+// on sessions of user agents.
+type UserSessionManager interface {
+	Save(ctx context.Context, session *UserSession) error
+	UserSession(ctx context.Context, id string) (*UserSession, error)
+	Delete(ctx context.Context, id string) error
+}
+
+// UserSession is the single sign on session of a user agent. It holds the
+// accounts that authenticated in the user agent, so they can be recognized in
+// later authorization requests without authenticating again.
+type UserSession struct {
+	ID string `json:"id"`
+	// Accounts are ordered from the most to the least recently used.
+	Accounts           []Account `json:"accounts"`
+	CreatedAtTimestamp int       `json:"created_at"`
+	ExpiresAtTimestamp int       `json:"expires_at"`
+	// SessionID is the sid claim of the ID tokens issued for the session.
+	// Unlike the ID, which is the value of the cookie, it can be shared with
+	// clients.
+	SessionID string `json:"sid,omitempty"`
+}
+
+// Account is a user authenticated in a user session.
+type Account struct {
+	Subject  string `json:"sub"`
+	AuthTime int    `json:"auth_time"`
+	AMRs     []AMR  `json:"amr,omitempty"`
+	ACR      ACR    `json:"acr,omitempty"`
+}
+
+// Account returns the account of the user session for the subject informed.
+func (s *UserSession) Account(subject string) (Account, bool) {
+	i := slices.IndexFunc(s.Accounts, func(a Account) bool {
+		return a.Subject == subject
+	})
+	if i == -1 {
+		return Account{}, false
+	}
+	return s.Accounts[i], true
+}
+
+// AddAccount makes the account the most recently used one, replacing any
+// previous account for the same subject.
+func (s *UserSession) AddAccount(account Account) {
+	s.RemoveAccount(account.Subject)
+	s.Accounts = append([]Account{account}, s.Accounts...)
+}
+
+// RemoveAccount signs the user out of the user session.
+func (s *UserSession) RemoveAccount(subject string) {
+	s.Accounts = slices.DeleteFunc(s.Accounts, func(a Account) bool {
+		return a.Subject == subject
+	})
+}
+
+func (s *UserSession) IsExpired() bool {
+	return timeutil.TimestampNow() >= s.ExpiresAtTimestamp
+}
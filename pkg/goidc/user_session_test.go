@@ -0,0 +1,26 @@
+package goidc_test
+
+import (
+	"testing"
+
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+func TestUserSession_AddAccount(t *testing.T) {
+	// Given.
+	session := goidc.UserSession{
+		Accounts: []goidc.Account{{Subject: "random_subject_1"}, {Subject: "random_subject_2", AuthTime: 1}},
+	}
+
+	// When.
+	session.AddAccount(goidc.Account{Subject: "random_subject_2", AuthTime: 2})
+
+	// Then.
+	if len(session.Accounts) != 2 {
+		t.Fatalf("len(Accounts) = %d, want 2", len(session.Accounts))
+	}
+
+	if session.Accounts[0].Subject != "random_subject_2" || session.Accounts[0].AuthTime != 2 {
+		t.Errorf("the account added must be the first one, got %v", session.Accounts[0])
+	}
+}
@@ -0,0 +1,300 @@
+// Package magiclink provides a passwordless authentication step that emails
+// the user a single use link to sign in.
+//
+// The link points to the handler returned by [MagicLink.Handler], which
+// resumes the authentication session with the provider, so the flow finishes
+// in the user agent where the link is opened.
+//
+//	links, err := magiclink.New(magiclink.Config{
+//		URL:        issuer + "/magic-link",
+//		Key:        key,
+//		LookupUser: lookupUser,
+//		Send:       sendEmail,
+//	})
+//	...
+//	op, err := provider.New(
+//		goidc.ProfileOpenID,
+//		issuer,
+//		jwks,
+//		provider.WithPolicy(goidc.NewSequentialPolicy("email", setUp, links.Step())),
+//	)
+//	...
+//	mux.Handle("/", op.Handler())
+//	mux.Handle("GET /magic-link", links.Handler(op.ResumeAuthentication))
+//
+// Links are signed with the key informed and expire after
+// [Config.LifetimeSecs]. The random nonce they carry is kept in the
+// authentication session and discarded once used, so each link can only be
+// used once and only the last link sent for a session is valid.
+package magiclink
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	_ "embed"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/luikyv/go-oidc/internal/strutil"
+	"github.com/luikyv/go-oidc/internal/timeutil"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+const (
+	tokenQueryParam = "token"
+	emailFormParam  = "email"
+
+	// nonceParam and subjectParam are the keys of the session store where the
+	// nonce of the link sent and the user it was sent to are kept.
+	nonceParam   = "magic_link_nonce"
+	subjectParam = "magic_link_subject"
+
+	nonceLength         = 32
+	minKeyLength        = 32
+	defaultLifetimeSecs = 600
+)
+
+//go:embed templates/magiclink.html
+var pageTemplate string
+
+var defaultTemplate = template.Must(template.New("magiclink").Parse(pageTemplate))
+
+// ErrUserNotFound must be returned by a [LookupUserFunc] when no user has the
+// email informed. The user agent is told a link was sent anyway, so it cannot
+// be used to find out which emails are registered.
+var ErrUserNotFound = errors.New("user not found")
+
+// LookupUserFunc returns the subject of the user with the email informed.
+type LookupUserFunc func(ctx context.Context, email string) (subject string, err error)
+
+// SendFunc delivers the link to the email informed.
+type SendFunc func(ctx context.Context, email, link string) error
+
+// Config defines how the links are issued.
+type Config struct {
+	// URL is where [MagicLink.Handler] is served, e.g.
+	// "https://example.com/magic-link".
+	URL string
+	// Key signs the links. It must have at least 32 bytes.
+	Key        []byte
+	LookupUser LookupUserFunc
+	Send       SendFunc
+	// LifetimeSecs is how long a link remains valid. It defaults to 600.
+	// The authentication session is kept alive for at least as long.
+	LifetimeSecs int
+	// CallbackURL is the URL to which the email is posted followed by
+	// "/{callback_id}". If not set, it is posted to the path of the
+	// authorization endpoint the request was received at.
+	CallbackURL string
+	// Template, if set, replaces the page where the email is typed. It is
+	// executed with a [Page].
+	Template *template.Template
+}
+
+// Page is the data used to render the page where the email is typed.
+type Page struct {
+	Action string
+	Email  string
+	// Sent is whether the link was just sent, in which case no form is
+	// needed.
+	Sent bool
+}
+
+// MagicLink issues and verifies the links.
+type MagicLink struct {
+	config Config
+}
+
+// New validates the configuration and returns the links ready to be used.
+func New(config Config) (*MagicLink, error) {
+	if config.URL == "" {
+		return nil, errors.New("the magic link url is required")
+	}
+	if len(config.Key) < minKeyLength {
+		return nil, errors.New("the magic link key must have at least 32 bytes")
+	}
+	if config.LookupUser == nil || config.Send == nil {
+		return nil, errors.New("the functions to look up users and send links are required")
+	}
+
+	if config.LifetimeSecs == 0 {
+		config.LifetimeSecs = defaultLifetimeSecs
+	}
+	if config.Template == nil {
+		config.Template = defaultTemplate
+	}
+	return &MagicLink{config: config}, nil
+}
+
+// Step authenticates the user with the link sent to their email. The
+// login_hint sent by the client, if any, is used as the default email.
+// On success, the auth_time claim of the ID token is set.
+func (m *MagicLink) Step() goidc.AuthnStep {
+	return func(w http.ResponseWriter, r *http.Request, session *goidc.AuthnSession) (goidc.AuthnStatus, error) {
+		if token := r.URL.Query().Get(tokenQueryParam); token != "" {
+			return m.verify(session, token)
+		}
+
+		if !session.IsInteractionAllowed() {
+			return goidc.StatusFailure, goidc.NewError(goidc.ErrorCodeLoginRequired,
+				"the user must authenticate")
+		}
+
+		email := strings.TrimSpace(r.PostFormValue(emailFormParam))
+		if email == "" {
+			return m.render(w, Page{
+				Action: m.action(r, session),
+				Email:  session.NormalizedLoginHint(),
+			})
+		}
+
+		if err := m.send(r.Context(), session, email); err != nil {
+			return goidc.StatusFailure, err
+		}
+		return m.render(w, Page{Email: email, Sent: true})
+	}
+}
+
+// Handler serves the links, resuming the authentication session they were
+// issued for with resume, usually the ResumeAuthentication method of the
+// provider.
+func (m *MagicLink) Handler(
+	resume func(w http.ResponseWriter, r *http.Request, callbackID string),
+) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, err := m.parse(r.URL.Query().Get(tokenQueryParam))
+		if err != nil {
+			http.Error(w, "invalid or expired link", http.StatusBadRequest)
+			return
+		}
+
+		resume(w, r, claims.CallbackID)
+	})
+}
+
+// send issues a new link for the session and delivers it if the email belongs
+// to a user.
+func (m *MagicLink) send(ctx context.Context, session *goidc.AuthnSession, email string) error {
+	subject, err := m.config.LookupUser(ctx, email)
+	if errors.Is(err, ErrUserNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	claims := linkClaims{
+		CallbackID: session.CallbackID,
+		Nonce:      strutil.Random(nonceLength),
+		Expiry:     timeutil.TimestampNow() + m.config.LifetimeSecs,
+	}
+	token, err := m.sign(claims)
+	if err != nil {
+		return err
+	}
+
+	// The session must outlive the link so the user has time to open it.
+	session.ExtendExpiry(m.config.LifetimeSecs)
+	session.StoreParameter(nonceParam, claims.Nonce)
+	session.StoreParameter(subjectParam, subject)
+
+	link := m.config.URL + "?" + url.Values{tokenQueryParam: {token}}.Encode()
+	return m.config.Send(ctx, email, link)
+}
+
+func (m *MagicLink) verify(session *goidc.AuthnSession, token string) (goidc.AuthnStatus, error) {
+	claims, err := m.parse(token)
+	if err != nil {
+		return goidc.StatusFailure, goidc.Errorf(goidc.ErrorCodeAccessDenied,
+			"invalid or expired link", err)
+	}
+
+	nonce, _ := session.Parameter(nonceParam).(string)
+	if claims.CallbackID != session.CallbackID || nonce == "" ||
+		subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1 {
+		return goidc.StatusFailure, goidc.NewError(goidc.ErrorCodeAccessDenied,
+			"the link was already used or replaced by a newer one")
+	}
+	delete(session.Store, nonceParam)
+
+	subject, _ := session.Parameter(subjectParam).(string)
+	delete(session.Store, subjectParam)
+	session.SetUserID(subject)
+	session.SetIDTokenClaimAuthTime(timeutil.TimestampNow())
+	return goidc.StatusSuccess, nil
+}
+
+func (m *MagicLink) render(w http.ResponseWriter, page Page) (goidc.AuthnStatus, error) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := m.config.Template.Execute(w, page); err != nil {
+		return goidc.StatusFailure, err
+	}
+	return goidc.StatusInProgress, nil
+}
+
+// action returns the URL to which the email is posted.
+func (m *MagicLink) action(r *http.Request, session *goidc.AuthnSession) string {
+	callbackURL := strings.TrimSuffix(m.config.CallbackURL, "/")
+	if callbackURL == "" {
+		callbackURL = strings.TrimSuffix(r.URL.Path, "/"+session.CallbackID)
+	}
+	return callbackURL + "/" + session.CallbackID
+}
+
+// linkClaims is the signed content of a link.
+type linkClaims struct {
+	CallbackID string `json:"cid"`
+	Nonce      string `json:"nonce"`
+	Expiry     int    `json:"exp"`
+}
+
+// sign returns the claims encoded in base64url followed by their HMAC.
+func (m *MagicLink) sign(claims linkClaims) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(m.mac(encoded)), nil
+}
+
+func (m *MagicLink) parse(token string) (linkClaims, error) {
+	encoded, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return linkClaims{}, errors.New("malformed link token")
+	}
+
+	decodedSig, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(decodedSig, m.mac(encoded)) {
+		return linkClaims{}, errors.New("invalid link signature")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return linkClaims{}, errors.New("malformed link token")
+	}
+
+	var claims linkClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return linkClaims{}, errors.New("malformed link token")
+	}
+
+	if timeutil.TimestampNow() >= claims.Expiry {
+		return linkClaims{}, errors.New("the link expired")
+	}
+	return claims, nil
+}
+
+func (m *MagicLink) mac(encoded string) []byte {
+	h := hmac.New(sha256.New, m.config.Key)
+	h.Write([]byte(encoded))
+	return h.Sum(nil)
+}
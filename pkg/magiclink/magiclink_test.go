@@ -0,0 +1,178 @@
+package magiclink_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/luikyv/go-oidc/pkg/goidc"
+	"github.com/luikyv/go-oidc/pkg/magiclink"
+	"github.com/luikyv/go-oidc/pkg/providertest"
+)
+
+func TestStep(t *testing.T) {
+	// Given.
+	var links []string
+	sim, magicLinks := newSimulator(t, &links)
+
+	// When.
+	sim.Start(t, url.Values{"login_hint": {"mailto:User@Example.com"}})
+
+	// Then.
+	if !strings.Contains(sim.Body, `value="user@example.com"`) {
+		t.Fatalf("the email page was not rendered with the login hint: %s", sim.Body)
+	}
+
+	// When.
+	sim.Submit(t, url.Values{"email": {"user@example.com"}})
+
+	// Then.
+	if !strings.Contains(sim.Body, "Check your email") || len(links) != 1 {
+		t.Fatalf("the link was not sent: %s", sim.Body)
+	}
+
+	// When.
+	resp := openLink(t, sim, magicLinks, links[0])
+
+	// Then.
+	location, _ := url.Parse(resp.Header().Get("Location"))
+	if !strings.HasPrefix(location.String(), providertest.RedirectURI) || location.Query().Get("code") == "" {
+		t.Fatalf("the user was not redirected with a code, status %d: %s", resp.Code, resp.Body.String())
+	}
+
+	if sim.Session().Subject != providertest.DefaultSubject {
+		t.Errorf("Subject = %s, want %s", sim.Session().Subject, providertest.DefaultSubject)
+	}
+
+	// When.
+	resp = openLink(t, sim, magicLinks, links[0])
+
+	// Then.
+	if resp.Code == http.StatusSeeOther || resp.Code == http.StatusFound {
+		t.Errorf("a link must not be used twice, got redirect to %s", resp.Header().Get("Location"))
+	}
+}
+
+func TestStep_UnknownUser(t *testing.T) {
+	// Given.
+	var links []string
+	sim, _ := newSimulator(t, &links)
+	sim.Start(t, nil)
+
+	// When.
+	sim.Submit(t, url.Values{"email": {"unknown@example.com"}})
+
+	// Then.
+	if !strings.Contains(sim.Body, "Check your email") {
+		t.Errorf("the page must not reveal the user is unknown: %s", sim.Body)
+	}
+
+	if len(links) != 0 {
+		t.Errorf("no link should be sent, got %v", links)
+	}
+}
+
+func TestStep_ReplacedLink(t *testing.T) {
+	// Given.
+	var links []string
+	sim, magicLinks := newSimulator(t, &links)
+	sim.Start(t, nil)
+	sim.Submit(t, url.Values{"email": {"user@example.com"}})
+	sim.Submit(t, url.Values{"email": {"user@example.com"}})
+
+	// When.
+	resp := openLink(t, sim, magicLinks, links[0])
+
+	// Then.
+	location, _ := url.Parse(resp.Header().Get("Location"))
+	if got := location.Query().Get("error"); got != string(goidc.ErrorCodeAccessDenied) {
+		t.Errorf("error = %s, want %s", got, goidc.ErrorCodeAccessDenied)
+	}
+}
+
+func TestHandler_InvalidSignature(t *testing.T) {
+	// Given.
+	var links []string
+	sim, magicLinks := newSimulator(t, &links)
+	sim.Start(t, nil)
+	sim.Submit(t, url.Values{"email": {"user@example.com"}})
+
+	// When.
+	resp := openLink(t, sim, magicLinks, links[0]+"invalid")
+
+	// Then.
+	if resp.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", resp.Code, http.StatusBadRequest)
+	}
+}
+
+func TestNew_ShortKey(t *testing.T) {
+	// When.
+	_, err := magiclink.New(magiclink.Config{
+		URL:        "https://example.com/magic-link",
+		Key:        []byte("short"),
+		LookupUser: lookupUser,
+		Send:       func(context.Context, string, string) error { return nil },
+	})
+
+	// Then.
+	if err == nil {
+		t.Fatal("short keys must be rejected")
+	}
+}
+
+func newSimulator(t *testing.T, links *[]string) (*providertest.PolicySimulator, *magiclink.MagicLink) {
+	t.Helper()
+
+	magicLinks, err := magiclink.New(magiclink.Config{
+		URL:        "https://example.com/magic-link",
+		Key:        []byte(strings.Repeat("k", 32)),
+		LookupUser: lookupUser,
+		Send: func(_ context.Context, _, link string) error {
+			*links = append(*links, link)
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	grant := func(_ http.ResponseWriter, _ *http.Request, session *goidc.AuthnSession) (goidc.AuthnStatus, error) {
+		session.GrantScopes(session.Scopes)
+		return goidc.StatusSuccess, nil
+	}
+
+	sim := providertest.NewPolicySimulator(t, goidc.NewSequentialPolicy(
+		"magic_link",
+		func(*http.Request, *goidc.Client, *goidc.AuthnSession) bool { return true },
+		magicLinks.Step(),
+		grant,
+	))
+	return sim, magicLinks
+}
+
+func lookupUser(_ context.Context, email string) (string, error) {
+	if email != "user@example.com" {
+		return "", magiclink.ErrUserNotFound
+	}
+	return providertest.DefaultSubject, nil
+}
+
+// openLink simulates the user opening the link, possibly in another user
+// agent.
+func openLink(
+	t *testing.T,
+	sim *providertest.PolicySimulator,
+	magicLinks *magiclink.MagicLink,
+	link string,
+) *httptest.ResponseRecorder {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, link, nil)
+	magicLinks.Handler(sim.Server.Provider.ResumeAuthentication).ServeHTTP(w, r)
+	return w
+}
@@ -0,0 +1,13 @@
+// Package oidctest provides keys, clients and JWT helpers to write tests
+// against the types of this module, e.g. table driven tests of authentication
+// policies or of clients registered in a provider.
+//
+// The clients returned by [NewClient] always have the same ID, secret and
+// redirect URI, so expected values can be hardcoded in tests. Signing keys are
+// generated for every call since RSA key generation cannot be made
+// deterministic, while [PrivateRSAOAEPJWK] always returns the same key
+// material.
+//
+//	client, secret := oidctest.NewClient(t)
+//	jwk := oidctest.PrivatePS256JWK(t, "server_key", goidc.KeyUsageSignature)
+package oidctest
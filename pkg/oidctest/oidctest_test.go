@@ -0,0 +1,50 @@
+package oidctest_test
+
+import (
+	"testing"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+	"github.com/luikyv/go-oidc/pkg/oidctest"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestNewClient(t *testing.T) {
+	// When.
+	client, secret := oidctest.NewClient(t)
+
+	// Then.
+	if client.ID != oidctest.ClientID || secret != oidctest.ClientSecret {
+		t.Errorf("client = %s, secret = %s, want %s, %s", client.ID, secret,
+			oidctest.ClientID, oidctest.ClientSecret)
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(client.HashedSecret), []byte(secret)); err != nil {
+		t.Errorf("the hashed secret doesn't match the secret: %v", err)
+	}
+}
+
+func TestSafeClaims(t *testing.T) {
+	// Given.
+	jwk := oidctest.PrivatePS256JWK(t, "random_key", goidc.KeyUsageSignature)
+	signer, _ := jose.NewSigner(jose.SigningKey{Algorithm: jose.PS256, Key: jwk.Key}, nil)
+	jws, _ := jwt.Signed(signer).Claims(map[string]any{"sub": "random_subject"}).Serialize()
+
+	// When.
+	claims, err := oidctest.SafeClaims(jws, jwk)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if claims["sub"] != "random_subject" {
+		t.Errorf("sub = %v, want random_subject", claims["sub"])
+	}
+
+	otherJWK := oidctest.PrivatePS256JWK(t, "random_key", goidc.KeyUsageSignature)
+	if _, err := oidctest.SafeClaims(jws, otherJWK); err == nil {
+		t.Error("the signature must be verified")
+	}
+}
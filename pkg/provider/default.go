@@ -7,15 +7,48 @@ import (
 
 const (
 	defaultAuthnSessionTimeoutSecs = 1800 // 30 minutes.
-	defaultIDTokenLifetimeSecs     = 600
-	defaultTokenLifetimeSecs       = 300
-	defaultJWTLifetimeSecs         = 600
-	defaultJWTLeewayTimeSecs       = 30
+	// defaultAuthnSessionMaxLifetimeSecs limits how long authn sessions can
+	// be extended while waiting for out of band steps.
+	defaultAuthnSessionMaxLifetimeSecs = 86400 // 1 day.
+	defaultIDTokenLifetimeSecs         = 600
+	defaultTokenLifetimeSecs           = 300
+	defaultJWTLifetimeSecs             = 600
+	defaultJWTLeewayTimeSecs           = 30
+	defaultAuthzCodeLifetimeSecs       = 60
+	defaultAuthzCodeLength             = 30
+	// minAuthzCodeLength gives authorization codes at least 128 bits of
+	// entropy.
+	minAuthzCodeLength = 22
+	// fapi2MaxAuthzCodeLifetimeSecs is the longest lifetime of authorization
+	// codes allowed by FAPI 2.0.
+	fapi2MaxAuthzCodeLifetimeSecs = 60
+	// defaultRefreshTokenLifetimeSecs is the lifetime of refresh tokens when
+	// none is informed to [WithRefreshTokenGrant].
+	defaultRefreshTokenLifetimeSecs = 2592000 // 30 days.
+	// defaultUserSessionLifetimeSecs is for how long a user agent is
+	// remembered after its last authorization when user sessions are enabled.
+	defaultUserSessionLifetimeSecs = 86400 // 1 day.
+	// defaultSectorIdentifierCacheTTLSecs defines for how long the redirect
+	// URIs fetched from a sector_identifier_uri are reused and
+	// defaultSectorIdentifierCacheMaxEntries how many documents are kept.
+	defaultSectorIdentifierCacheTTLSecs    = 300
+	defaultSectorIdentifierCacheMaxEntries = 1000
+	// defaultClaimsCacheMaxEntries defines for how many combinations of
+	// client, user and scopes the claims are cached.
+	defaultClaimsCacheMaxEntries = 10000
+	// defaultClientAuthnFailuresTTLSecs defines for how long the failed
+	// authentications of a client from an address are counted after the last
+	// one and defaultClientAuthnFailuresMaxEntries how many pairs of client
+	// and address are kept.
+	defaultClientAuthnFailuresTTLSecs    = 3600
+	defaultClientAuthnFailuresMaxEntries = 10000
 
 	defaultPrivateKeyJWTSigAlg = jose.RS256
 	defaultSecretJWTSigAlg     = jose.HS256
 
 	defaultEndpointWellKnown                  = "/.well-known/openid-configuration"
+	defaultEndpointOAuthServerMetadata        = "/.well-known/oauth-authorization-server"
+	defaultEndpointWebFinger                  = "/.well-known/webfinger"
 	defaultEndpointJSONWebKeySet              = "/jwks"
 	defaultEndpointPushedAuthorizationRequest = "/par"
 	defaultEndpointAuthorize                  = "/authorize"
@@ -26,6 +59,16 @@ const (
 	defaultEndpointTokenRevocation            = "/revoke"
 )
 
+// leewayUnset marks a leeway that was not informed, since zero is a valid
+// leeway.
+const leewayUnset = -1
+
+// defaultIssueRefreshTokenFunc issues refresh tokens to all the clients allowed
+// the refresh token grant, except the ones that disabled them through metadata.
+func defaultIssueRefreshTokenFunc(client *goidc.Client, _ goidc.GrantInfo) bool {
+	return !client.RefreshTokenIsDisabled
+}
+
 func defaultTokenOptionsFunc(
 	sigKeyID string,
 ) goidc.TokenOptionsFunc {
@@ -0,0 +1,69 @@
+package provider
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/luikyv/go-oidc/internal/oidc"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+// hostRouter wraps the handlers registered so the endpoints restricted to the
+// main or the mutual TLS host are not found when requested on the other one.
+type hostRouter struct {
+	oidc.Router
+	config *oidc.Configuration
+}
+
+func (r hostRouter) HandleFunc(
+	pattern string,
+	handler func(http.ResponseWriter, *http.Request),
+) {
+	endpoint, ok := r.endpoint(pattern)
+	if !ok {
+		r.Router.HandleFunc(pattern, handler)
+		return
+	}
+
+	host := r.config.MTLSEndpointHosts[endpoint]
+	if host == "" || host == goidc.EndpointHostAll {
+		r.Router.HandleFunc(pattern, handler)
+		return
+	}
+
+	r.Router.HandleFunc(pattern, func(w http.ResponseWriter, req *http.Request) {
+		isMTLSHost := oidc.NewContext(w, req, r.config).IsMTLSHostRequest()
+		if isMTLSHost != (host == goidc.EndpointHostMTLS) {
+			http.NotFound(w, req)
+			return
+		}
+		handler(w, req)
+	})
+}
+
+// endpoint returns the endpoint served by the pattern, e.g. "POST /token", if
+// it can be served on the mutual TLS host.
+func (r hostRouter) endpoint(pattern string) (goidc.Endpoint, bool) {
+	path := pattern
+	if _, p, ok := strings.Cut(pattern, " "); ok {
+		path = p
+	}
+
+	for endpoint, endpointPath := range map[goidc.Endpoint]string{
+		goidc.EndpointToken:               r.config.EndpointToken,
+		goidc.EndpointPushedAuthorization: r.config.EndpointPushedAuthorization,
+		goidc.EndpointUserInfo:            r.config.EndpointUserInfo,
+		goidc.EndpointDCR:                 r.config.EndpointDCR,
+		goidc.EndpointIntrospection:       r.config.EndpointIntrospection,
+		goidc.EndpointTokenRevocation:     r.config.EndpointTokenRevocation,
+	} {
+		if endpointPath == "" {
+			continue
+		}
+		endpointPath = r.config.EndpointPrefix + endpointPath
+		if path == endpointPath || strings.HasPrefix(path, endpointPath+"/") {
+			return endpoint, true
+		}
+	}
+	return "", false
+}
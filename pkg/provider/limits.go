@@ -0,0 +1,186 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/luikyv/go-oidc/internal/oidc"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+// limitedRouter wraps the handlers registered so the size of the request
+// bodies and the time spent handling requests are bounded.
+type limitedRouter struct {
+	oidc.Router
+	config *oidc.Configuration
+}
+
+func (r limitedRouter) HandleFunc(
+	pattern string,
+	handler func(http.ResponseWriter, *http.Request),
+) {
+	limits := r.limits(pattern)
+	if limits == (goidc.RequestLimits{}) {
+		r.Router.HandleFunc(pattern, handler)
+		return
+	}
+
+	r.Router.HandleFunc(pattern, func(w http.ResponseWriter, req *http.Request) {
+		if limits.MaxBodyBytes > 0 {
+			if err := limitBody(w, req, limits.MaxBodyBytes); err != nil {
+				r.writeBodyError(w, req, err)
+				return
+			}
+		}
+
+		if limits.Timeout > 0 {
+			r.serveWithTimeout(w, req, handler, limits)
+			return
+		}
+
+		handler(w, req)
+	})
+}
+
+// limits returns the limits of the endpoint. The fields not defined for the
+// endpoint default to the limits of all endpoints.
+func (r limitedRouter) limits(pattern string) goidc.RequestLimits {
+	limits := r.config.EndpointRequestLimits[pattern]
+	limits.MaxBodyBytes = nonZeroOrDefault(limits.MaxBodyBytes, r.config.RequestLimits.MaxBodyBytes)
+	limits.Timeout = nonZeroOrDefault(limits.Timeout, r.config.RequestLimits.Timeout)
+	return limits
+}
+
+// limitBody reads the request body up to maxBytes so oversized requests are
+// rejected before being handled.
+func limitBody(w http.ResponseWriter, req *http.Request, maxBytes int64) error {
+	if req.ContentLength > maxBytes {
+		return &http.MaxBytesError{Limit: maxBytes}
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, req.Body, maxBytes))
+	if err != nil {
+		return err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return nil
+}
+
+func (r limitedRouter) writeBodyError(w http.ResponseWriter, req *http.Request, err error) {
+	ctx := oidc.NewContext(w, req, r.config)
+
+	var maxBytesErr *http.MaxBytesError
+	if !errors.As(err, &maxBytesErr) {
+		ctx.WriteError(goidc.Errorf(goidc.ErrorCodeInvalidRequest, "could not read the request body", err))
+		return
+	}
+
+	err = goidc.Errorf(goidc.ErrorCodeInvalidRequest, "the request body is too large", err)
+	ctx.NotifyError(err)
+	if err := ctx.Write(err, http.StatusRequestEntityTooLarge); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// serveWithTimeout runs the handler with a deadline. The response is buffered
+// so it can be replaced by an error if the deadline is exceeded.
+func (r limitedRouter) serveWithTimeout(
+	w http.ResponseWriter,
+	req *http.Request,
+	handler func(http.ResponseWriter, *http.Request),
+	limits goidc.RequestLimits,
+) {
+	ctx, cancel := context.WithTimeout(req.Context(), limits.Timeout)
+	defer cancel()
+
+	// The handler collects what happens during the request apart, so it
+	// doesn't race with the error written when the deadline is exceeded.
+	handlerCtx, info := oidc.WithEndpointInfo(ctx)
+	tw := &timeoutWriter{header: http.Header{}}
+	done := make(chan struct{})
+	panicChan := make(chan any, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				panicChan <- p
+			}
+		}()
+		handler(tw, req.WithContext(handlerCtx))
+		close(done)
+	}()
+
+	select {
+	case p := <-panicChan:
+		panic(p)
+	case <-done:
+		oidc.NewContext(w, req, r.config).MergeEndpointInfo(info)
+		tw.flush(w)
+	case <-ctx.Done():
+		tw.mu.Lock()
+		defer tw.mu.Unlock()
+		tw.timedOut = true
+
+		if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			// The request was cancelled by the client, so there is no one to
+			// answer.
+			return
+		}
+		oidc.NewContext(w, req, r.config).WriteError(goidc.Errorf(
+			goidc.ErrorCodeTemporarilyUnavailable, "the request timed out", ctx.Err()))
+	}
+}
+
+// timeoutWriter buffers the response of a handler running with a deadline.
+type timeoutWriter struct {
+	mu       sync.Mutex
+	header   http.Header
+	buf      bytes.Buffer
+	status   int
+	timedOut bool
+}
+
+func (w *timeoutWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *timeoutWriter) WriteHeader(status int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.timedOut || w.status != 0 {
+		return
+	}
+	w.status = status
+}
+
+func (w *timeoutWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.buf.Write(b)
+}
+
+// flush writes the response buffered to w.
+func (w *timeoutWriter) flush(rw http.ResponseWriter) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for key, values := range w.header {
+		rw.Header()[key] = values
+	}
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	rw.WriteHeader(w.status)
+	_, _ = rw.Write(w.buf.Bytes())
+}
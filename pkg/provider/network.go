@@ -0,0 +1,80 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+const (
+	dialTimeout   = 30 * time.Second
+	dialKeepAlive = 30 * time.Second
+)
+
+// publicNetworkHTTPClientFunc wraps f so the clients it returns only connect
+// to public addresses.
+// The transport built for the last transport seen is reused, so connections
+// are pooled as long as f returns clients with the same transport.
+func publicNetworkHTTPClientFunc(f goidc.HTTPClientFunc) goidc.HTTPClientFunc {
+	var mu sync.Mutex
+	var base, guarded *http.Transport
+
+	return func(ctx context.Context) *http.Client {
+		client := http.DefaultClient
+		if f != nil {
+			client = f(ctx)
+		}
+
+		transport := http.DefaultTransport
+		if client.Transport != nil {
+			transport = client.Transport
+		}
+
+		publicClient := *client
+		t, ok := transport.(*http.Transport)
+		if !ok {
+			publicClient.Transport = roundTripperFunc(func(*http.Request) (*http.Response, error) {
+				return nil, errors.New("the http client transport cannot be restricted to public addresses")
+			})
+			return &publicClient
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if t != base {
+			base, guarded = t, publicNetworkTransport(t)
+		}
+		publicClient.Transport = guarded
+		return &publicClient
+	}
+}
+
+// publicNetworkTransport returns a copy of t that rejects connections to
+// private addresses.
+// The proxy is removed, otherwise the address checked would be the one of the
+// proxy instead of the destination.
+func publicNetworkTransport(t *http.Transport) *http.Transport {
+	t = t.Clone()
+	dialer := &net.Dialer{
+		Timeout:   dialTimeout,
+		KeepAlive: dialKeepAlive,
+		Control:   goidc.DenyPrivateNetworkDialControl,
+	}
+	t.Proxy = nil
+	t.DialContext = dialer.DialContext
+	// Custom TLS dialers would take priority over DialContext.
+	t.DialTLSContext = nil
+	t.DialTLS = nil
+	return t
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
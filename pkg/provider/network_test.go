@@ -0,0 +1,104 @@
+package provider
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/luikyv/go-oidc/internal/oidctest"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+func TestNew_PrivateNetworkURIsDenied(t *testing.T) {
+	// Given.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	jwk := oidctest.PrivatePS256JWK(t, "server_key", goidc.KeyUsageSignature)
+
+	// When.
+	op, err := New(
+		goidc.ProfileOpenID,
+		"https://example.com",
+		jose.JSONWebKeySet{Keys: []jose.JSONWebKey{jwk}},
+		WithPrivateNetworkURIsDenied(),
+	)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("could not create the provider: %v", err)
+	}
+
+	if op.config.URIPolicyFunc == nil {
+		t.Error("the default uri policy must be set")
+	}
+
+	resp, err := op.config.HTTPClientFunc(context.Background()).Get(server.URL)
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("the request to a loopback address must be rejected")
+	}
+}
+
+func TestPublicNetworkHTTPClientFunc(t *testing.T) {
+	// Given.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	transport := &http.Transport{}
+	f := publicNetworkHTTPClientFunc(func(context.Context) *http.Client {
+		return &http.Client{Transport: transport}
+	})
+
+	// When.
+	client := f(context.Background())
+	resp, err := client.Get(server.URL)
+
+	// Then.
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("the request to a loopback address must be rejected")
+	}
+
+	if client.Transport == transport {
+		t.Error("the transport informed must not be used directly")
+	}
+
+	if f(context.Background()).Transport != client.Transport {
+		t.Error("the transport must be reused")
+	}
+
+	if transport.DialContext != nil {
+		t.Error("the transport informed must not be modified")
+	}
+}
+
+func TestPublicNetworkHTTPClientFunc_UnsupportedTransport(t *testing.T) {
+	// Given.
+	called := false
+	f := publicNetworkHTTPClientFunc(func(context.Context) *http.Client {
+		return &http.Client{Transport: roundTripperFunc(func(*http.Request) (*http.Response, error) {
+			called = true
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+		})}
+	})
+
+	// When.
+	resp, err := f(context.Background()).Get("https://example.com")
+
+	// Then.
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("the request must be rejected")
+	}
+
+	if called {
+		t.Error("the transport informed must not be used")
+	}
+}
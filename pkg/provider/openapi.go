@@ -0,0 +1,437 @@
+package provider
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+const openAPIVersion = "3.0.3"
+
+// OpenAPISpec returns an OpenAPI 3 document describing the endpoints enabled
+// for the provider, their parameters and error responses.
+// The document can be encoded as JSON and consumed by API gateways and client
+// SDK generators.
+//
+//	spec, _ := json.Marshal(op.OpenAPISpec())
+func (p Provider) OpenAPISpec() map[string]any {
+	patterns := make([]string, 0)
+	for pattern := range p.HandlerFuncs() {
+		patterns = append(patterns, pattern)
+	}
+	slices.Sort(patterns)
+
+	paths := map[string]map[string]any{}
+	for _, pattern := range patterns {
+		method, path, _ := strings.Cut(pattern, " ")
+		op := p.openAPIOperation(method, strings.TrimPrefix(path, p.config.EndpointPrefix))
+		if op == nil {
+			continue
+		}
+
+		if paths[path] == nil {
+			paths[path] = map[string]any{}
+		}
+		paths[path][strings.ToLower(method)] = op
+	}
+
+	securitySchemes := map[string]any{
+		"bearer": map[string]any{"type": "http", "scheme": "bearer"},
+	}
+	if p.config.DPoPIsEnabled {
+		securitySchemes["dpop"] = map[string]any{
+			"type": "apiKey",
+			"in":   "header",
+			"name": "Authorization",
+			"description": "DPoP bound access token sent as \"DPoP <token>\" " +
+				"along with a DPoP proof header.",
+		}
+	}
+
+	return map[string]any{
+		"openapi": openAPIVersion,
+		"info": map[string]any{
+			"title":   "OpenID Provider",
+			"version": "1.0.0",
+		},
+		"servers": []any{map[string]any{"url": p.config.Host}},
+		"paths":   paths,
+		"components": map[string]any{
+			"schemas": map[string]any{
+				"Error": map[string]any{
+					"type":     "object",
+					"required": []string{"error"},
+					"properties": map[string]any{
+						"error":             map[string]any{"type": "string"},
+						"error_description": map[string]any{"type": "string"},
+					},
+				},
+			},
+			"securitySchemes": securitySchemes,
+		},
+	}
+}
+
+// openAPIOperation describes the operation of an endpoint. Nil is returned
+// for endpoints that are not meant to be called directly by clients, e.g. CORS
+// preflight and authentication callbacks.
+func (p Provider) openAPIOperation(method, path string) map[string]any {
+	if method == http.MethodOptions {
+		return nil
+	}
+
+	switch path {
+	case p.config.EndpointWellKnown:
+		return operation("Get the provider metadata", nil, response(http.StatusOK,
+			"The OpenID Provider metadata.", "application/json"))
+	case p.config.EndpointOAuthServerMetadata:
+		return operation("Get the authorization server metadata", nil, response(http.StatusOK,
+			"The OAuth 2.0 Authorization Server metadata.", "application/json"))
+	case p.config.EndpointWebFinger:
+		return operation("Discover the issuer of a user", []any{
+			map[string]any{"name": "resource", "in": "query", "required": true,
+				"schema": map[string]any{"type": "string"}},
+			map[string]any{"name": "rel", "in": "query", "required": false,
+				"schema": map[string]any{"type": "string"}},
+		}, response(http.StatusOK, "The JSON Resource Descriptor.", "application/jrd+json"),
+			errorResponse(http.StatusBadRequest),
+			response(http.StatusNotFound, "The resource is unknown.", ""))
+	case p.config.EndpointJWKS:
+		return operation("Get the provider public keys", nil, response(http.StatusOK,
+			"The JSON Web Key Set of the provider.", "application/json"))
+	case p.config.EndpointAuthorize:
+		return p.authorizeOperation(method)
+	case p.config.EndpointPushedAuthorization:
+		// The request_uri parameter is the result of pushing the request.
+		params := slices.DeleteFunc(p.authorizeParams(), func(param any) bool {
+			return param.(map[string]any)["name"] == "request_uri"
+		})
+		params = append(params, p.clientAuthnParams()...)
+		return withFormBody(operation("Push an authorization request", nil,
+			response(http.StatusCreated, "The request URI.", "application/json"),
+			errorResponse(http.StatusBadRequest), errorResponse(http.StatusUnauthorized),
+		), params, p.dpopHeader())
+	case p.config.EndpointToken:
+		return p.tokenOperation()
+	case p.config.EndpointUserInfo:
+		return withSecurity(p, operation("Get the claims of the user", nil,
+			response(http.StatusOK, "The user claims.", "application/json", "application/jwt"),
+			errorResponse(http.StatusUnauthorized), errorResponse(http.StatusForbidden),
+		))
+	case p.config.EndpointIntrospection:
+		params := append([]any{
+			formParam("token", true, nil),
+			formParam("token_type_hint", false, []string{"access_token", "refresh_token"}),
+		}, p.clientAuthnParams()...)
+		return withFormBody(operation("Introspect a token", nil,
+			response(http.StatusOK, "The token information.", "application/json"),
+			errorResponse(http.StatusBadRequest), errorResponse(http.StatusUnauthorized),
+		), params, nil)
+	case p.config.EndpointTokenRevocation:
+		params := append([]any{
+			formParam("token", true, nil),
+			formParam("token_type_hint", false, []string{"access_token", "refresh_token"}),
+		}, p.clientAuthnParams()...)
+		return withFormBody(operation("Revoke a token", nil,
+			response(http.StatusOK, "The token was revoked.", ""),
+			errorResponse(http.StatusBadRequest), errorResponse(http.StatusUnauthorized),
+		), params, nil)
+	case p.config.EndpointDCR:
+		return operation("Register a client", nil,
+			response(http.StatusCreated, "The client information.", "application/json"),
+			errorResponse(http.StatusBadRequest),
+		)
+	case p.config.EndpointDCR + "/{client_id}":
+		return p.dcrManagementOperation(method)
+	default:
+		return nil
+	}
+}
+
+func (p Provider) authorizeOperation(method string) map[string]any {
+	responses := []map[string]any{
+		response(http.StatusSeeOther, "Redirection to the client with the response.", ""),
+		errorResponse(http.StatusBadRequest),
+	}
+
+	if method == http.MethodPost {
+		return withFormBody(operation("Start an authorization request", nil, responses...),
+			p.authorizeParams(), nil)
+	}
+
+	var params []any
+	for _, param := range p.authorizeParams() {
+		param := param.(map[string]any)
+		params = append(params, map[string]any{
+			"name":     param["name"],
+			"in":       "query",
+			"required": param["required"],
+			"schema":   param["schema"],
+		})
+	}
+	return operation("Start an authorization request", params, responses...)
+}
+
+func (p Provider) authorizeParams() []any {
+	var responseTypes []string
+	for _, rt := range p.config.ResponseTypes {
+		responseTypes = append(responseTypes, string(rt))
+	}
+
+	var responseModes []string
+	for _, rm := range p.config.ResponseModes {
+		responseModes = append(responseModes, string(rm))
+	}
+
+	params := []any{
+		formParam("client_id", true, nil),
+		formParam("response_type", false, responseTypes),
+		formParam("response_mode", false, responseModes),
+		formParam("redirect_uri", false, nil),
+		formParam("scope", false, nil),
+		formParam("state", false, nil),
+		formParam("nonce", false, nil),
+		formParam("prompt", false, nil),
+	}
+
+	if p.config.PKCEIsEnabled {
+		params = append(params,
+			formParam("code_challenge", p.config.PKCEIsRequired, nil),
+			formParam("code_challenge_method", false, nil),
+		)
+	}
+
+	if p.config.PARIsEnabled {
+		params = append(params, formParam("request_uri", p.config.PARIsRequired, nil))
+	}
+
+	if p.config.JARIsEnabled {
+		params = append(params, formParam("request", p.config.JARIsRequired, nil))
+	}
+
+	if p.config.ClaimsParamIsEnabled {
+		params = append(params, formParam("claims", false, nil))
+	}
+
+	if p.config.AuthDetailsIsEnabled {
+		params = append(params, formParam("authorization_details", false, nil))
+	}
+
+	if p.config.ResourceIndicatorsIsEnabled {
+		params = append(params, formParam("resource", p.config.ResourceIndicatorsIsRequired, nil))
+	}
+
+	return params
+}
+
+func (p Provider) tokenOperation() map[string]any {
+	var grantTypes []string
+	for _, gt := range p.config.GrantTypes {
+		grantTypes = append(grantTypes, string(gt))
+	}
+
+	params := []any{
+		formParam("grant_type", true, grantTypes),
+		formParam("code", false, nil),
+		formParam("redirect_uri", false, nil),
+		formParam("refresh_token", false, nil),
+		formParam("scope", false, nil),
+	}
+
+	if slices.Contains(p.config.GrantTypes, goidc.GrantJWTBearer) {
+		params = append(params, formParam("assertion", false, nil))
+	}
+
+	if p.config.PKCEIsEnabled {
+		params = append(params, formParam("code_verifier", false, nil))
+	}
+
+	if p.config.ResourceIndicatorsIsEnabled {
+		params = append(params, formParam("resource", false, nil))
+	}
+
+	return withFormBody(operation("Issue tokens", nil,
+		response(http.StatusOK, "The token response.", "application/json"),
+		errorResponse(http.StatusBadRequest), errorResponse(http.StatusUnauthorized),
+	), append(params, p.clientAuthnParams()...), p.dpopHeader())
+}
+
+func (p Provider) dcrManagementOperation(method string) map[string]any {
+	params := []any{map[string]any{
+		"name":     "client_id",
+		"in":       "path",
+		"required": true,
+		"schema":   map[string]any{"type": "string"},
+	}}
+
+	var op map[string]any
+	switch method {
+	case http.MethodGet:
+		op = operation("Get a client", params,
+			response(http.StatusOK, "The client information.", "application/json"))
+	case http.MethodPut, http.MethodPatch:
+		op = operation("Update a client", params,
+			response(http.StatusOK, "The client information.", "application/json"),
+			errorResponse(http.StatusBadRequest))
+	case http.MethodDelete:
+		op = operation("Delete a client", params,
+			response(http.StatusNoContent, "The client was deleted.", ""))
+	default:
+		return nil
+	}
+
+	responses := op["responses"].(map[string]any)
+	responses["401"] = errorResponse(http.StatusUnauthorized)["response"]
+	op["security"] = []any{map[string]any{"bearer": []string{}}}
+	return op
+}
+
+// clientAuthnParams returns the form parameters used by clients to
+// authenticate according to the methods enabled.
+func (p Provider) clientAuthnParams() []any {
+	var params []any
+	if slices.Contains(p.config.TokenAuthnMethods, goidc.ClientAuthnNone) ||
+		slices.Contains(p.config.TokenAuthnMethods, goidc.ClientAuthnSecretPost) ||
+		slices.Contains(p.config.TokenAuthnMethods, goidc.ClientAuthnTLS) ||
+		slices.Contains(p.config.TokenAuthnMethods, goidc.ClientAuthnSelfSignedTLS) {
+		params = append(params, formParam("client_id", false, nil))
+	}
+
+	if slices.Contains(p.config.TokenAuthnMethods, goidc.ClientAuthnSecretPost) {
+		params = append(params, formParam("client_secret", false, nil))
+	}
+
+	if slices.Contains(p.config.TokenAuthnMethods, goidc.ClientAuthnSecretJWT) ||
+		slices.Contains(p.config.TokenAuthnMethods, goidc.ClientAuthnPrivateKeyJWT) {
+		params = append(params,
+			formParam("client_assertion", false, nil),
+			formParam("client_assertion_type", false,
+				[]string{string(goidc.AssertionTypeJWTBearer)}),
+		)
+	}
+
+	return params
+}
+
+func (p Provider) dpopHeader() map[string]any {
+	if !p.config.DPoPIsEnabled {
+		return nil
+	}
+
+	return map[string]any{
+		"name":     goidc.HeaderDPoP,
+		"in":       "header",
+		"required": p.config.DPoPIsRequired,
+		"schema":   map[string]any{"type": "string"},
+	}
+}
+
+func withSecurity(p Provider, op map[string]any) map[string]any {
+	security := []any{map[string]any{"bearer": []string{}}}
+	if p.config.DPoPIsEnabled {
+		security = append(security, map[string]any{"dpop": []string{}})
+	}
+	op["security"] = security
+	return op
+}
+
+// withFormBody adds the form parameters as the request body of the operation.
+// If informed, header is added to the operation parameters.
+func withFormBody(op map[string]any, params []any, header map[string]any) map[string]any {
+	properties := map[string]any{}
+	var required []string
+	for _, param := range params {
+		param := param.(map[string]any)
+		name := param["name"].(string)
+		properties[name] = param["schema"]
+		if param["required"].(bool) && !slices.Contains(required, name) {
+			required = append(required, name)
+		}
+	}
+
+	schema := map[string]any{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) != 0 {
+		schema["required"] = required
+	}
+
+	op["requestBody"] = map[string]any{
+		"required": true,
+		"content": map[string]any{
+			"application/x-www-form-urlencoded": map[string]any{"schema": schema},
+		},
+	}
+
+	if header != nil {
+		op["parameters"] = append(op["parameters"].([]any), header)
+	}
+	return op
+}
+
+func operation(summary string, params []any, responses ...map[string]any) map[string]any {
+	if params == nil {
+		params = []any{}
+	}
+
+	resps := map[string]any{}
+	for _, resp := range responses {
+		resps[resp["status"].(string)] = resp["response"]
+	}
+
+	return map[string]any{
+		"summary":    summary,
+		"parameters": params,
+		"responses":  resps,
+	}
+}
+
+func formParam(name string, required bool, enum []string) map[string]any {
+	schema := map[string]any{"type": "string"}
+	if len(enum) != 0 {
+		schema["enum"] = enum
+	}
+
+	return map[string]any{
+		"name":     name,
+		"required": required,
+		"schema":   schema,
+	}
+}
+
+func response(status int, description string, contentTypes ...string) map[string]any {
+	resp := map[string]any{"description": description}
+
+	content := map[string]any{}
+	for _, contentType := range contentTypes {
+		if contentType != "" {
+			content[contentType] = map[string]any{"schema": map[string]any{"type": "object"}}
+		}
+	}
+	if len(content) != 0 {
+		resp["content"] = content
+	}
+
+	return map[string]any{
+		"status":   strconv.Itoa(status),
+		"response": resp,
+	}
+}
+
+func errorResponse(status int) map[string]any {
+	return map[string]any{
+		"status": strconv.Itoa(status),
+		"response": map[string]any{
+			"description": http.StatusText(status),
+			"content": map[string]any{
+				"application/json": map[string]any{
+					"schema": map[string]any{"$ref": "#/components/schemas/Error"},
+				},
+			},
+		},
+	}
+}
@@ -2,10 +2,12 @@ package provider
 
 import (
 	"errors"
+	"fmt"
 	"slices"
 	"strings"
 
 	"github.com/go-jose/go-jose/v4"
+	"github.com/luikyv/go-oidc/internal/oidc"
 	"github.com/luikyv/go-oidc/pkg/goidc"
 )
 
@@ -44,6 +46,180 @@ func WithGrantSessionStorage(
 	}
 }
 
+// WithInMemoryStorageLimits bounds how many authn and grant sessions the
+// default in memory storages keep, so a flood of requests cannot exhaust the
+// memory of the server. When a limit is reached, expired sessions are removed
+// first and then the sessions closest to expiring are evicted.
+// Zero means no limit. The limits have no effect on storages replaced with
+// [WithAuthnSessionStorage] or [WithGrantSessionStorage].
+func WithInMemoryStorageLimits(maxAuthnSessions, maxGrantSessions int) ProviderOption {
+	return func(p Provider) error {
+		if maxAuthnSessions < 0 || maxGrantSessions < 0 {
+			return errors.New("the storage limits cannot be negative")
+		}
+		p.config.MaxAuthnSessions = maxAuthnSessions
+		p.config.MaxGrantSessions = maxGrantSessions
+		return nil
+	}
+}
+
+// WithInMemoryStorageMaxBytes bounds the approximate memory, in bytes, used by
+// the authn and grant sessions of the default in memory storages. The size of
+// a session is estimated by the length of its JSON encoding. When a limit is
+// exceeded, the sessions are evicted as in [WithInMemoryStorageLimits].
+// Zero means no limit. The limits have no effect on storages replaced with
+// [WithAuthnSessionStorage] or [WithGrantSessionStorage].
+func WithInMemoryStorageMaxBytes(maxAuthnSessionBytes, maxGrantSessionBytes int) ProviderOption {
+	return func(p Provider) error {
+		if maxAuthnSessionBytes < 0 || maxGrantSessionBytes < 0 {
+			return errors.New("the storage limits cannot be negative")
+		}
+		p.config.MaxAuthnSessionBytes = maxAuthnSessionBytes
+		p.config.MaxGrantSessionBytes = maxGrantSessionBytes
+		return nil
+	}
+}
+
+// WithAsyncGrantSessionStorage takes the write of grant sessions out of the
+// critical path of token responses when the access tokens issued are JWTs.
+// The sessions are queued and saved in the background, so a token may be
+// returned before its grant session is stored, e.g. introspecting or
+// refreshing it right after may fail.
+// The same applies to the sessions updated when refresh tokens are rotated, so
+// the previous refresh token remains valid until the queued write is saved.
+// Opaque tokens are not affected, since they cannot be used without the grant
+// session.
+// At most queueSize sessions wait to be saved. When the queue is full, the
+// requests wait for room in the queue, so the sessions are always saved in
+// the order they were issued.
+// errFunc, if not nil, is called when a session could not be saved.
+// Call [Provider.Close] to wait for the sessions queued when shutting down.
+func WithAsyncGrantSessionStorage(
+	queueSize int,
+	errFunc goidc.GrantSessionSaveErrorFunc,
+) ProviderOption {
+	return func(p Provider) error {
+		if queueSize <= 0 {
+			return errors.New("the grant session queue size must be positive")
+		}
+		p.config.AsyncGrantSessionIsEnabled = true
+		p.config.GrantSessionQueueSize = queueSize
+		p.config.GrantSessionSaveErrorFunc = errFunc
+		return nil
+	}
+}
+
+// WithEndpointHooks defines functions that run around every request to the
+// endpoints of the provider, e.g. to enforce quotas or to bill clients.
+// before, if not nil, runs before the request is handled and can reject it by
+// returning an error. after, if not nil, runs once the response is written and
+// receives the client authenticated, if any, and the outcome of the request.
+func WithEndpointHooks(before goidc.BeforeRequestFunc, after goidc.AfterResponseFunc) ProviderOption {
+	return func(p Provider) error {
+		p.config.BeforeRequestFunc = before
+		p.config.AfterResponseFunc = after
+		return nil
+	}
+}
+
+// WithRequestLimits bounds the size of the request bodies and the time spent
+// handling requests for all the endpoints of the provider, so oversized or
+// slow requests, e.g. large request objects or registration payloads, don't
+// tie up the server.
+// Use [WithEndpointRequestLimits] to override the limits for an endpoint.
+func WithRequestLimits(limits goidc.RequestLimits) ProviderOption {
+	return func(p Provider) error {
+		if limits.MaxBodyBytes < 0 || limits.Timeout < 0 {
+			return errors.New("the request limits must not be negative")
+		}
+		p.config.RequestLimits = limits
+		return nil
+	}
+}
+
+// WithEndpointRequestLimits overrides the request limits for the endpoint
+// identified by its [http.ServeMux] pattern, e.g. "POST /par". The pattern
+// must include the endpoint prefix, if any, as in [Provider.HandlerFuncs].
+// Fields left as zero default to the limits defined with [WithRequestLimits].
+func WithEndpointRequestLimits(endpoint string, limits goidc.RequestLimits) ProviderOption {
+	return func(p Provider) error {
+		if limits.MaxBodyBytes < 0 || limits.Timeout < 0 {
+			return errors.New("the request limits must not be negative")
+		}
+		if p.config.EndpointRequestLimits == nil {
+			p.config.EndpointRequestLimits = map[string]goidc.RequestLimits{}
+		}
+		p.config.EndpointRequestLimits[endpoint] = limits
+		return nil
+	}
+}
+
+// WithConsent enables recording the consents users grant to clients.
+// The accesses granted at the end of each successful authorization flow are
+// added to the consent of the user for the client. Use [Provider.ConsentStep]
+// to skip prompting the user when a previous consent covers the request.
+func WithConsent() ProviderOption {
+	return func(p Provider) error {
+		p.config.ConsentIsEnabled = true
+		return nil
+	}
+}
+
+// WithConsentStorage replaces the default consent storage which keeps the
+// consents stored in memory.
+// This also enables consent recording, see [WithConsent].
+func WithConsentStorage(
+	storage goidc.ConsentManager,
+) ProviderOption {
+	return func(p Provider) error {
+		p.config.ConsentIsEnabled = true
+		p.config.ConsentManager = storage
+		return nil
+	}
+}
+
+// WithUserSessions enables single sign on user sessions.
+// The accounts authenticated in a user agent are recorded at the end of each
+// successful authorization flow in a user session identified by a cookie, and
+// they are loaded into [goidc.AuthnSession.Accounts] in later authorization
+// requests, so policies can skip authentication or let the user switch
+// accounts. The session expires lifetimeSecs after its last use. If
+// lifetimeSecs is zero, it defaults to one day.
+func WithUserSessions(lifetimeSecs int) ProviderOption {
+	return func(p Provider) error {
+		p.config.UserSessionIsEnabled = true
+		p.config.UserSessionLifetimeSecs = lifetimeSecs
+		return nil
+	}
+}
+
+// WithSessionIDClaim returns the "sid" claim in ID tokens identifying the
+// session of the user at the provider, e.g. for logout or to correlate
+// events. When user sessions are enabled, see [WithUserSessions], all the flows
+// of a user session share the same sid. Otherwise, each flow gets its own.
+// Policies can also define it with [goidc.AuthnSession.SessionID].
+// The sid is kept in the grant sessions, so ID tokens issued when refreshing
+// tokens carry it as well.
+func WithSessionIDClaim() ProviderOption {
+	return func(p Provider) error {
+		p.config.SessionIDClaimIsEnabled = true
+		return nil
+	}
+}
+
+// WithUserSessionStorage replaces the default user session storage which
+// keeps the sessions in memory.
+// This also enables user sessions, see [WithUserSessions].
+func WithUserSessionStorage(
+	storage goidc.UserSessionManager,
+) ProviderOption {
+	return func(p Provider) error {
+		p.config.UserSessionIsEnabled = true
+		p.config.UserSessionManager = storage
+		return nil
+	}
+}
+
 // WithPathPrefix defines a shared prefix for all endpoints.
 // When using the provider http handler directly, the path prefix must be added
 // to the router.
@@ -170,6 +346,22 @@ func WithClaimTypes(
 	}
 }
 
+// WithSubjectIdentifierFunc defines how the subject of a user is presented to
+// each client, e.g. to issue tenant scoped identifiers. The identifier returned
+// is used consistently in ID tokens, JWT access tokens, the userinfo response
+// and token introspection.
+// The subject of tokens issued to a client acting on its own behalf, e.g. in
+// the client credentials grant, is not transformed.
+func WithSubjectIdentifierFunc(f goidc.SubjectIdentifierFunc) ProviderOption {
+	return func(p Provider) error {
+		if f == nil {
+			return errors.New("the subject identifier function must not be nil")
+		}
+		p.config.SubjectIdentifierFunc = f
+		return nil
+	}
+}
+
 // WithUserSignatureAlgs set the algorithms available to sign the user info
 // endpoint response and ID tokens.
 func WithUserSignatureAlgs(
@@ -247,6 +439,47 @@ func WithDCR(
 	}
 }
 
+// WithDCRInitialAccessTokens makes dynamic client registration require
+// initial access tokens issued by [Provider.NewInitialAccessToken].
+// The validation informed to [WithDCR], if any, is executed after the token
+// is verified, e.g. to reject tokens already used.
+// To enable dynamic client registration, see [WithDCR].
+func WithDCRInitialAccessTokens() ProviderOption {
+	return func(p Provider) error {
+		p.config.DCRInitialAccessTokenIsRequired = true
+		return nil
+	}
+}
+
+// WithDCROpenRegistration allows clients to register without an initial
+// access token, e.g. in public test environments. At most maxPerIP
+// registrations are accepted from the same IP address every windowSecs
+// seconds. The address is obtained as defined by [WithClientIPFunc].
+// Open registration cannot be combined with the validation of initial access
+// tokens informed to [WithDCR].
+// To require a captcha or a proof of work, see [WithDCRChallengeFunc].
+func WithDCROpenRegistration(maxPerIP, windowSecs int) ProviderOption {
+	return func(p Provider) error {
+		if maxPerIP <= 0 || windowSecs <= 0 {
+			return errors.New("the registration limit and its window must be positive")
+		}
+		p.config.DCROpenRegistrationIsEnabled = true
+		p.config.DCRMaxRegistrationsPerIP = maxPerIP
+		p.config.DCRRegistrationWindowSecs = windowSecs
+		return nil
+	}
+}
+
+// WithDCRChallengeFunc defines a function executed before open registrations,
+// e.g. to verify a captcha or a proof of work.
+// To enable open registration, see [WithDCROpenRegistration].
+func WithDCRChallengeFunc(f goidc.DCRChallengeFunc) ProviderOption {
+	return func(p Provider) error {
+		p.config.DCRChallengeFunc = f
+		return nil
+	}
+}
+
 // WithDCRTokenRotation makes the registration access token rotate during client
 // update requests.
 // To enable dynamic client registration, see [WithDCR].
@@ -257,6 +490,67 @@ func WithDCRTokenRotation() ProviderOption {
 	}
 }
 
+// WithCORS makes the token and user info endpoints answer cross origin
+// requests from the origins each client registered with the metadata
+// "allowed_cors_origins", so browser based clients can call them.
+func WithCORS() ProviderOption {
+	return func(p Provider) error {
+		p.config.CORSIsEnabled = true
+		return nil
+	}
+}
+
+// WithDCRJWKSURIFetch makes the server fetch the jwks_uri informed during
+// dynamic client registration to validate the keys it contains.
+// To enable dynamic client registration, see [WithDCR].
+func WithDCRJWKSURIFetch() ProviderOption {
+	return func(p Provider) error {
+		p.config.DCRJWKSURIIsFetched = true
+		return nil
+	}
+}
+
+// WithDCRClientURIVerification makes the server fetch logo_uri, client_uri,
+// policy_uri and tos_uri informed during dynamic client registration to make
+// sure they are reachable. The URIs must use https and logo_uri must also
+// point to an image. Clients registered or updated while the verification is
+// enabled are marked with [goidc.Client.BrandingIsVerified].
+// To enable dynamic client registration, see [WithDCR].
+func WithDCRClientURIVerification() ProviderOption {
+	return func(p Provider) error {
+		p.config.DCRClientURIsAreVerified = true
+		return nil
+	}
+}
+
+// WithURIPolicy defines which URIs informed by clients, such as jwks_uri and
+// sector_identifier_uri, are allowed to be used during registration.
+// To reject URIs resolving to private address ranges, see
+// [WithPrivateNetworkURIsDenied].
+func WithURIPolicy(f goidc.URIPolicyFunc) ProviderOption {
+	return func(p Provider) error {
+		p.config.URIPolicyFunc = f
+		return nil
+	}
+}
+
+// WithPrivateNetworkURIsDenied prevents the provider from making requests to
+// private address ranges, e.g. when fetching the jwks_uri,
+// sector_identifier_uri or request_uri of a client.
+// URIs informed during registration are validated with
+// [goidc.DenyPrivateNetworkURIs], unless a policy is set with
+// [WithURIPolicy], and the HTTP clients used for every request are changed to
+// only dial public addresses with [goidc.DenyPrivateNetworkDialControl].
+// The dialer and the proxy set in the transport of a client defined with
+// [WithHTTPClientFunc] are replaced, and clients whose transport is not an
+// [*http.Transport] cannot be used.
+func WithPrivateNetworkURIsDenied() ProviderOption {
+	return func(p Provider) error {
+		p.config.PrivateNetworkURIsAreDenied = true
+		return nil
+	}
+}
+
 // WithClientCredentialsGrant makes available the client credentials grant.
 func WithClientCredentialsGrant() ProviderOption {
 	return func(p Provider) error {
@@ -268,12 +562,22 @@ func WithClientCredentialsGrant() ProviderOption {
 
 // WithRefreshTokenGrant makes available the refresh token grant.
 // The default refresh token lifetime is [defaultRefreshTokenLifetimeSecs] and
-// the default logic to issue refresh token is [defaultIssueRefreshTokenFunc].
+// the default logic to issue refresh token is [defaultIssueRefreshTokenFunc],
+// which honors the metadata "refresh_token_disabled" of the client.
+// With [WithClientTokenMetadata], clients can also shorten the lifetime of
+// their refresh tokens with the metadata "refresh_token_lifetime".
 func WithRefreshTokenGrant(
 	f goidc.ShouldIssueRefreshTokenFunc,
 	lifetimeSecs int,
 ) ProviderOption {
 	return func(p Provider) error {
+		if f == nil {
+			f = defaultIssueRefreshTokenFunc
+		}
+		if lifetimeSecs == 0 {
+			lifetimeSecs = defaultRefreshTokenLifetimeSecs
+		}
+
 		p.config.GrantTypes = append(p.config.GrantTypes,
 			goidc.GrantRefreshToken)
 		p.config.ShouldIssueRefreshTokenFunc = f
@@ -309,6 +613,54 @@ func WithTokenOptions(tokenOpts goidc.TokenOptionsFunc) ProviderOption {
 	}
 }
 
+// WithTokenIssuer registers an issuer producing access tokens in a custom
+// format. The format is selected by returning it in the token options, see
+// [WithTokenOptions], or, with [WithClientTokenMetadata], by registering it as
+// the "access_token_format" of a client.
+// Grant sessions are stored as for the native formats, so the tokens issued can
+// be introspected, revoked and sender constrained the same way.
+func WithTokenIssuer(format goidc.TokenFormat, issuer goidc.TokenIssuer) ProviderOption {
+	return func(p Provider) error {
+		switch format {
+		case "", goidc.TokenFormatJWT, goidc.TokenFormatOpaque:
+			return fmt.Errorf("the token format %q cannot be registered as custom", format)
+		}
+
+		if issuer == nil {
+			return errors.New("the token issuer must not be nil")
+		}
+
+		if p.config.TokenIssuers == nil {
+			p.config.TokenIssuers = map[goidc.TokenFormat]goidc.TokenIssuer{}
+		}
+		p.config.TokenIssuers[format] = issuer
+		return nil
+	}
+}
+
+// WithTokenEncryptionKey sets the AES key used to encrypt stateless opaque
+// tokens, see [goidc.NewStatelessOpaqueTokenOptions].
+// The key must have 16, 24 or 32 bytes.
+func WithTokenEncryptionKey(key []byte) ProviderOption {
+	return func(p Provider) error {
+		p.config.TokenEncryptionKey = key
+		return nil
+	}
+}
+
+// WithClientTokenMetadata allows clients to define the format and lifetime of
+// their access tokens with the metadata "access_token_format" and
+// "access_token_lifetime". The client metadata takes precedence over the
+// options returned by the token options function, see [WithTokenOptions].
+// maxLifetimeSecs limits the access token lifetime a client can request.
+func WithClientTokenMetadata(maxLifetimeSecs int) ProviderOption {
+	return func(p Provider) error {
+		p.config.ClientTokenMetadataIsEnabled = true
+		p.config.ClientTokenMaxLifetimeSecs = maxLifetimeSecs
+		return nil
+	}
+}
+
 // WithHandleGrantFunc defines a function executed everytime a new grant is created.
 // It can be used to perform validations or change the grant information before
 // issuing a new access token.
@@ -319,6 +671,38 @@ func WithHandleGrantFunc(grantHandler goidc.HandleGrantFunc) ProviderOption {
 	}
 }
 
+// WithTokenResponseHookFunc defines a function executed after a grant is
+// created whose result is appended to the token endpoint response.
+// Standard token response parameters cannot be overridden.
+func WithTokenResponseHookFunc(f goidc.TokenResponseHookFunc) ProviderOption {
+	return func(p Provider) error {
+		p.config.TokenResponseHookFunc = f
+		return nil
+	}
+}
+
+// WithTokenExpirationHints includes in token responses the timestamp when the
+// access token expires, expires_at, and, if a refresh token was issued, how
+// many seconds it remains valid, refresh_expires_in, so clients can schedule
+// refreshes without guessing.
+func WithTokenExpirationHints() ProviderOption {
+	return func(p Provider) error {
+		p.config.TokenExpirationHintsIsEnabled = true
+		return nil
+	}
+}
+
+// WithTokenRenewalHintFunc defines a function whose result is appended to the
+// token endpoint response to hint clients when to renew their tokens.
+// Standard token response parameters and the ones informed by
+// [WithTokenResponseHookFunc] cannot be overridden.
+func WithTokenRenewalHintFunc(f goidc.TokenRenewalHintFunc) ProviderOption {
+	return func(p Provider) error {
+		p.config.TokenRenewalHintFunc = f
+		return nil
+	}
+}
+
 // WithAuthorizationCodeGrant allows the authorization_code grant type and the
 // associated response types.
 func WithAuthorizationCodeGrant() ProviderOption {
@@ -354,8 +738,47 @@ func WithScopes(scopes ...goidc.Scope) ProviderOption {
 	}
 }
 
+// WithDiscoveryCacheMaxAge allows clients to cache the provider metadata and
+// the JWKS for maxAgeSecs seconds without revalidating them.
+// By default, clients can keep the responses but must revalidate them with
+// their ETag before every use.
+// The max age of the JWKS is shortened when the certificate of a key is about
+// to expire, so clients pick up rotated keys in time.
+func WithDiscoveryCacheMaxAge(maxAgeSecs int) ProviderOption {
+	return func(p Provider) error {
+		p.config.DiscoveryCacheMaxAgeSecs = maxAgeSecs
+		return nil
+	}
+}
+
+// WithDiscoveryCompression gzip encodes the provider metadata and the JWKS for
+// clients that accept it, which shrinks large key sets, e.g. with certificate
+// chains in x5c, considerably.
+func WithDiscoveryCompression() ProviderOption {
+	return func(p Provider) error {
+		p.config.DiscoveryCompressionIsEnabled = true
+		return nil
+	}
+}
+
+// WithWebFinger enables the WebFinger endpoint so clients can discover the
+// issuer of a user from an identifier such as an email address, as defined by
+// OpenID Connect Discovery.
+// resolveIssuerFunc is optional and can be used to inform a different issuer
+// per user or to reject unknown users. If nil, the provider's issuer is
+// returned for any resource.
+func WithWebFinger(resolveIssuerFunc goidc.ResolveIssuerFunc) ProviderOption {
+	return func(p Provider) error {
+		p.config.WebFingerIsEnabled = true
+		p.config.ResolveIssuerFunc = resolveIssuerFunc
+		return nil
+	}
+}
+
 // WithPAR allows authorization flows to start at the pushed authorization
 // request endpoint.
+// Specific clients can still be forced to use it with the metadata
+// "require_pushed_authorization_requests".
 func WithPAR(lifetimeSecs int) ProviderOption {
 	return func(p Provider) error {
 		p.config.PARIsEnabled = true
@@ -374,6 +797,19 @@ func WithPARRequired(lifetimeSecs int) ProviderOption {
 	}
 }
 
+// WithPARClientCertBinding binds pushed authorization requests to the client
+// certificate used to push them, so the authorization code issued can only be
+// exchanged at the token endpoint with the same certificate.
+// Requests pushed with a DPoP proof are always bound to its key, as defined in
+// RFC 9449.
+// To enable pushed authorization request, see [WithPAR].
+func WithPARClientCertBinding() ProviderOption {
+	return func(p Provider) error {
+		p.config.PARClientCertBindingIsEnabled = true
+		return nil
+	}
+}
+
 // WithUnregisteredRedirectURIsForPAR allows clients to inform unregistered
 // redirect URIs during requests to pushed authorization endpoint.
 // To enable pushed authorization request, see [WithPAR].
@@ -384,9 +820,21 @@ func WithUnregisteredRedirectURIsForPAR() ProviderOption {
 	}
 }
 
+// WithParallelValidation makes the independent validations of authorization
+// and pushed authorization requests, e.g. scopes, response types and the ID
+// token hint, run concurrently. Errors are still reported in the same order
+// as when the validations run sequentially.
+func WithParallelValidation() ProviderOption {
+	return func(p Provider) error {
+		p.config.ParallelValidationIsEnabled = true
+		return nil
+	}
+}
+
 // WithJAR allows authorization requests to be securely sent as signed JWTs.
 // Clients can choose the signing algorithm by setting the attribute
-// "request_object_signing_alg".
+// "request_object_signing_alg" and require signed request objects for
+// themselves with "require_signed_request_object".
 // By default, the max difference between "iat" and "exp" of request objects is
 // set to [defaultJWTLifetimeSecs].
 func WithJAR(
@@ -437,6 +885,34 @@ func WithJAREncryption(
 	}
 }
 
+// WithJAREncryptionKeys defines which encryption keys of the server JWKS are
+// advertised for request object encryption. The other encryption keys are not
+// published, but still decrypt request objects, e.g. while a new key is being
+// staged.
+// To enable JAR encryption, see [WithJAREncryption].
+func WithJAREncryptionKeys(keyID string, keyIDs ...string) ProviderOption {
+	keyIDs = appendIfNotIn(keyIDs, keyID)
+	return func(p Provider) error {
+		p.config.JAREncKeyIDs = keyIDs
+		return nil
+	}
+}
+
+// WithJARRetiredEncryptionKey keeps decrypting request objects encrypted with a
+// key removed from the server JWKS until expiresAtTimestamp, so clients have
+// time to pick up the new keys after a rotation.
+// The key must be private and is never published.
+// To enable JAR encryption, see [WithJAREncryption].
+func WithJARRetiredEncryptionKey(key jose.JSONWebKey, expiresAtTimestamp int) ProviderOption {
+	return func(p Provider) error {
+		p.config.JARRetiredEncKeys = append(p.config.JARRetiredEncKeys, goidc.RetiredKey{
+			Key:                key,
+			ExpiresAtTimestamp: expiresAtTimestamp,
+		})
+		return nil
+	}
+}
+
 // WithJARContentEncryptionAlgs overrides the default content encryption
 // algorithm for request objects which is A128CBC-HS256.
 // To enable JAR encryption, see [WithJAREncryption].
@@ -564,6 +1040,111 @@ func WithAssertionLifetime(secs int) ProviderOption {
 	}
 }
 
+// WithAssertionLeeway defines the clock skew tolerated when validating the
+// time claims of client assertions.
+// If not informed, the leeway defined with [WithJWTLeeway] is used.
+func WithAssertionLeeway(secs int) ProviderOption {
+	return func(p Provider) error {
+		if secs < 0 {
+			return errors.New("the leeway must not be negative")
+		}
+		p.config.AssertionLeewayTimeSecs = secs
+		return nil
+	}
+}
+
+// WithAssertionAudiences defines additional audiences accepted in client
+// assertions and request objects.
+// By default, the audiences are derived from the issuer and the URI of the
+// current request, which may not match what clients see when the provider
+// runs behind a proxy rewriting paths.
+func WithAssertionAudiences(aud string, auds ...string) ProviderOption {
+	return func(p Provider) error {
+		p.config.CustomAssertionAudiences = append(p.config.CustomAssertionAudiences, aud)
+		p.config.CustomAssertionAudiences = append(p.config.CustomAssertionAudiences, auds...)
+		return nil
+	}
+}
+
+// WithAssertionAudiencesOnly makes the audiences informed the only ones
+// accepted in client assertions and request objects, replacing the ones
+// derived from the issuer and the current request.
+func WithAssertionAudiencesOnly(aud string, auds ...string) ProviderOption {
+	return func(p Provider) error {
+		p.config.AssertionAudiencesReplaceDefaults = true
+		return WithAssertionAudiences(aud, auds...)(p)
+	}
+}
+
+// WithIssuerAliases defines former issuers of the provider that are still
+// accepted during an issuer migration, e.g. as the audience of client
+// assertions and request objects, as the issuer of ID token hints and of the
+// tokens presented to the provider.
+// Tokens and responses are always issued with the current issuer.
+func WithIssuerAliases(alias string, aliases ...string) ProviderOption {
+	return func(p Provider) error {
+		p.config.IssuerAliases = append(p.config.IssuerAliases, alias)
+		p.config.IssuerAliases = append(p.config.IssuerAliases, aliases...)
+		return nil
+	}
+}
+
+// WithJWTLeeway defines the clock skew tolerated when validating the time
+// claims of the JWTs sent to the provider, i.e. client assertions, request
+// objects, DPoP proofs and ID token hints. This keeps clients with minor clock
+// drifts from being rejected.
+// The leeway of each validation can be overridden with the specific options,
+// e.g. [WithJARLeeway].
+// A leeway of zero disables the tolerance. The default is 30 seconds.
+func WithJWTLeeway(secs int) ProviderOption {
+	return func(p Provider) error {
+		if secs < 0 {
+			return errors.New("the leeway must not be negative")
+		}
+		p.config.JWTLeewayTimeSecs = secs
+		return nil
+	}
+}
+
+// WithJARLeeway defines the clock skew tolerated when validating the time
+// claims of request objects.
+// If not informed, the leeway defined with [WithJWTLeeway] is used.
+func WithJARLeeway(secs int) ProviderOption {
+	return func(p Provider) error {
+		if secs < 0 {
+			return errors.New("the leeway must not be negative")
+		}
+		p.config.JARLeewayTimeSecs = secs
+		return nil
+	}
+}
+
+// WithDPoPLeeway defines the clock skew tolerated when validating the time
+// claims of DPoP proofs.
+// If not informed, the leeway defined with [WithJWTLeeway] is used.
+func WithDPoPLeeway(secs int) ProviderOption {
+	return func(p Provider) error {
+		if secs < 0 {
+			return errors.New("the leeway must not be negative")
+		}
+		p.config.DPoPLeewayTimeSecs = secs
+		return nil
+	}
+}
+
+// WithIDTokenHintLeeway defines the clock skew tolerated when validating the
+// time claims of ID token hints.
+// If not informed, the leeway defined with [WithJWTLeeway] is used.
+func WithIDTokenHintLeeway(secs int) ProviderOption {
+	return func(p Provider) error {
+		if secs < 0 {
+			return errors.New("the leeway must not be negative")
+		}
+		p.config.IDTokenHintLeewayTimeSecs = secs
+		return nil
+	}
+}
+
 // WithIssuerResponseParameter enables the "iss" parameter to be sent in the
 // response of authorization requests.
 func WithIssuerResponseParameter() ProviderOption {
@@ -597,10 +1178,25 @@ func WithAuthorizationDetails(
 	}
 }
 
+// WithAuthorizationDetailsClaim returns the authorization details of the grant
+// in the "authorization_details" claim of ID tokens and user info responses,
+// in addition to token introspection.
+// f, if not nil, selects which authorization details are returned.
+// This option is only effective if authorization details are enabled with
+// [WithAuthorizationDetails].
+func WithAuthorizationDetailsClaim(f goidc.AuthDetailsClaimFunc) ProviderOption {
+	return func(p Provider) error {
+		p.config.AuthDetailsClaimIsEnabled = true
+		p.config.AuthDetailsClaimFunc = f
+		return nil
+	}
+}
+
 // WithMTLS allows requests to be established with mutual TLS.
-// The default logic to extract the client certificate is using the header
-// [goidc.HeaderClientCert]. For more info, see [defaultClientCertFunc].
-// The client certificate logic can be overriden with [WithClientCertFunc].
+// clientCertFunc extracts the client certificate from the request. When the
+// TLS connection is terminated by a proxy, [goidc.XFCCClientCertFunc] and
+// [goidc.NginxClientCertFunc] read the certificate forwarded by Envoy, Istio
+// and ingress-nginx.
 func WithMTLS(
 	host string,
 	clientCertFunc goidc.ClientCertFunc,
@@ -613,6 +1209,93 @@ func WithMTLS(
 	}
 }
 
+// WithMTLSTerminatedByProxy allows requests to be established with mutual TLS
+// when a proxy, e.g. a load balancer, terminates the mutual TLS connections for
+// host and forwards the requests to the same handler serving the main host, so
+// the provider doesn't need a separate listener.
+// The client certificate is read exclusively with clientCertFunc, e.g.
+// [goidc.XFCCClientCertFunc], and a request is considered sent to the mutual
+// TLS host when clientCertFunc returns a certificate. The discovery document
+// still advertises host for the mutual TLS endpoints.
+// For more info, see [WithMTLS].
+func WithMTLSTerminatedByProxy(
+	host string,
+	clientCertFunc goidc.ClientCertFunc,
+) ProviderOption {
+	return func(p Provider) error {
+		if clientCertFunc == nil {
+			return errors.New("the client certificate function is required when mutual TLS is terminated by a proxy")
+		}
+		p.config.MTLSIsTerminatedByProxy = true
+		return WithMTLS(host, clientCertFunc)(p)
+	}
+}
+
+// WithMTLSEndpointHost defines on which hosts the endpoint is served when
+// mutual TLS is enabled, see [WithMTLS]. By default, the endpoints are served on
+// both the main and the mutual TLS hosts.
+// Requests to an endpoint on a host where it isn't served are answered with
+// 404 and the discovery document only advertises the hosts serving it, e.g.
+// the user info endpoint can be kept on the main host only while the token
+// endpoint is only reachable with mutual TLS.
+func WithMTLSEndpointHost(endpoint goidc.Endpoint, host goidc.EndpointHost) ProviderOption {
+	return func(p Provider) error {
+		switch host {
+		case goidc.EndpointHostAll, goidc.EndpointHostMain, goidc.EndpointHostMTLS:
+		default:
+			return fmt.Errorf("invalid endpoint host %q", host)
+		}
+
+		if p.config.MTLSEndpointHosts == nil {
+			p.config.MTLSEndpointHosts = map[goidc.Endpoint]goidc.EndpointHost{}
+		}
+		p.config.MTLSEndpointHosts[endpoint] = host
+		return nil
+	}
+}
+
+// WithTLSClientCertPolicy makes the certificates of clients authenticating
+// with tls_client_auth be verified against trusted CAs, their extended key
+// usages and optionally their revocation status, in addition to the
+// comparison of the subject DN or SAN.
+// If policy.RootCAsFunc is nil, the system's root CAs are trusted.
+func WithTLSClientCertPolicy(policy goidc.TLSClientCertPolicy) ProviderOption {
+	return func(p Provider) error {
+		p.config.TLSClientCertPolicy = &policy
+		return nil
+	}
+}
+
+// WithClientAuthnHooks defines functions executed before and after clients
+// are authenticated, e.g. to throttle or lock clients after repeated bad
+// secrets or assertions. [goidc.ClientAuthnThrottler] provides an in memory
+// implementation.
+// The functions receive the consecutive failures of the client from the
+// address of the request, which are counted in memory and only for registered
+// clients.
+// Either function can be nil.
+func WithClientAuthnHooks(
+	before goidc.BeforeClientAuthnFunc,
+	after goidc.AfterClientAuthnFunc,
+) ProviderOption {
+	return func(p Provider) error {
+		p.config.BeforeClientAuthnFunc = before
+		p.config.AfterClientAuthnFunc = after
+		return nil
+	}
+}
+
+// WithClientIPFunc defines how the address of the client making a request is
+// determined, e.g. when the provider is behind a proxy. The address is
+// compared with [goidc.ClientMetaInfo.AllowedSourceCIDRs].
+// By default, the remote address of the request is used.
+func WithClientIPFunc(f goidc.ClientIPFunc) ProviderOption {
+	return func(p Provider) error {
+		p.config.ClientIPFunc = f
+		return nil
+	}
+}
+
 // WithTLSCertTokenBinding makes requests to /token return tokens bound to the
 // client certificate if any is sent.
 // To enable MTLS, see [WithMTLS].
@@ -685,6 +1368,29 @@ func WithTokenAuthnMethods(
 	}
 }
 
+// WithClientAuthnFunc registers a client authentication method not supported
+// natively, e.g. attest_jwt_client_auth, which is then advertised for the token
+// endpoint and accepted during dynamic client registration.
+// To accept the method at the introspection or revocation endpoints, inform
+// it in [WithTokenIntrospection] or [WithTokenRevocation].
+func WithClientAuthnFunc(method goidc.ClientAuthnType, f goidc.ClientAuthnFunc) ProviderOption {
+	return func(p Provider) error {
+		switch method {
+		case "", goidc.ClientAuthnNone, goidc.ClientAuthnSecretBasic,
+			goidc.ClientAuthnSecretPost, goidc.ClientAuthnSecretJWT,
+			goidc.ClientAuthnPrivateKeyJWT, goidc.ClientAuthnTLS,
+			goidc.ClientAuthnSelfSignedTLS:
+			return fmt.Errorf("the authentication method %q cannot be registered as custom", method)
+		}
+
+		if p.config.ClientAuthnFuncs == nil {
+			p.config.ClientAuthnFuncs = map[goidc.ClientAuthnType]goidc.ClientAuthnFunc{}
+		}
+		p.config.ClientAuthnFuncs[method] = f
+		return nil
+	}
+}
+
 // WithTokenIntrospection allows authorized clients to introspect tokens.
 // A client can only introspect tokens if it has the grant type
 // [goidc.GrantIntrospection].
@@ -702,6 +1408,89 @@ func WithTokenIntrospection(
 	}
 }
 
+// WithClientJWKSCache keeps the client JWKS fetched from jwks_uri in memory
+// for ttlSecs seconds when resolving the keys ID tokens, user info and JARM
+// responses are encrypted with, so the keys are not fetched for every
+// response.
+// Clients that rotate their encryption keys must keep the previous ones
+// published for at least ttlSecs seconds.
+func WithClientJWKSCache(ttlSecs int) ProviderOption {
+	return func(p Provider) error {
+		if ttlSecs <= 0 {
+			return errors.New("the client jwks cache ttl must be positive")
+		}
+		p.config.ClientJWKSCacheTTLSecs = ttlSecs
+		return nil
+	}
+}
+
+// WithIntrospectionCache keeps the grant sessions looked up during token
+// introspection in memory for ttlSecs seconds, which reduces the load on the
+// grant session storage when resource servers introspect every request.
+// The cache entries of a grant are evicted when it is updated or revoked by
+// this instance, but other instances only see the changes once the entries
+// expire. Use [WithIntrospectionCacheStorage] to share the cache between
+// instances.
+// This option is only effective if token introspection is enabled with
+// [WithTokenIntrospection].
+func WithIntrospectionCache(ttlSecs int) ProviderOption {
+	return func(p Provider) error {
+		if ttlSecs <= 0 {
+			return errors.New("the introspection cache ttl must be positive")
+		}
+		p.config.IntrospectionCacheTTLSecs = ttlSecs
+		return nil
+	}
+}
+
+// WithIntrospectionCacheStorage replaces the default introspection cache, which
+// keeps the entries in memory, e.g. by one backed by Redis.
+// This also enables the introspection cache, see [WithIntrospectionCache].
+func WithIntrospectionCacheStorage(cache goidc.IntrospectionCache) ProviderOption {
+	return func(p Provider) error {
+		p.config.IntrospectionCache = cache
+		return nil
+	}
+}
+
+// WithTokenIntrospectionBearerScope allows resource servers to call the
+// introspection endpoint with an access token granted the given scope instead
+// of authenticating as a client.
+// Client authentication is still accepted when no access token is informed.
+// This option is only effective if token introspection is enabled with
+// [WithTokenIntrospection].
+func WithTokenIntrospectionBearerScope(scope string) ProviderOption {
+	return func(p Provider) error {
+		p.config.TokenIntrospectionBearerScope = scope
+		return nil
+	}
+}
+
+// WithFilterIntrospectionFunc defines a function to adjust the information of
+// active tokens based on who is calling the introspection endpoint.
+// For instance, scopes and claims not relevant to the resource server asking
+// for the information can be removed to limit data exposure between APIs.
+func WithFilterIntrospectionFunc(f goidc.FilterIntrospectionFunc) ProviderOption {
+	return func(p Provider) error {
+		p.config.FilterIntrospectionFunc = f
+		return nil
+	}
+}
+
+// WithTokenIntrospectionInactiveReason makes the introspection endpoint inform
+// trusted callers why a token is not active using the non standard claim
+// [goidc.ClaimInactiveReason].
+// The reason is always available in [goidc.TokenInfo] returned by
+// [Provider.TokenInfo], regardless of this option.
+func WithTokenIntrospectionInactiveReason(
+	f goidc.IsIntrospectionCallerTrustedFunc,
+) ProviderOption {
+	return func(p Provider) error {
+		p.config.IsIntrospectionCallerTrustedFunc = f
+		return nil
+	}
+}
+
 // WithTokenRevocation allows clients to revoke tokens.
 // If no authentication methods are specified, default to using the values set
 // for the token endpoint.
@@ -721,6 +1510,9 @@ func WithTokenRevocation(
 
 // WithPKCE makes proof key for code exchange available to clients.
 // The first code challenged informed is used as the default.
+// Once PKCE is enabled, it is required for public clients, i.e. clients
+// authenticating with [goidc.ClientAuthnNone], and optional for the others.
+// To require it for all clients, see [WithPKCERequired].
 func WithPKCE(
 	defaultMethod goidc.CodeChallengeMethod,
 	methods ...goidc.CodeChallengeMethod,
@@ -746,6 +1538,30 @@ func WithPKCERequired(
 	}
 }
 
+// WithPKCEPlainDisallowed makes the plain code challenge method rejected and
+// unpublished in the discovery metadata even if it was informed with
+// [WithPKCE] or [WithPKCERequired].
+// If plain was the default method, S256 is assumed instead.
+func WithPKCEPlainDisallowed() ProviderOption {
+	return func(p Provider) error {
+		p.config.PKCEPlainIsDisallowed = true
+		return nil
+	}
+}
+
+// WithPKCEMinVerifierEntropy rejects code verifiers whose entropy is estimated
+// below bits.
+// The estimate is based on the frequency of the characters of the verifier,
+// which penalizes repeated characters and patterns. For reference, a verifier
+// made of 32 random octets encoded as base64url, as suggested by RFC 7636, is
+// estimated at around 200 bits.
+func WithPKCEMinVerifierEntropy(bits int) ProviderOption {
+	return func(p Provider) error {
+		p.config.PKCEMinVerifierEntropyBits = bits
+		return nil
+	}
+}
+
 // WithACRs makes available authentication context references.
 // These values will be published as are in the well know endpoint response.
 func WithACRs(
@@ -783,6 +1599,61 @@ func WithAuthenticationSessionTimeout(secs int) ProviderOption {
 	}
 }
 
+// WithAuthenticationSessionMaxLifetime limits for how long after they are
+// created authentication sessions can be kept alive with
+// [goidc.AuthnSession.ExtendExpiry].
+// The default is [defaultAuthnSessionMaxLifetimeSecs].
+func WithAuthenticationSessionMaxLifetime(secs int) ProviderOption {
+	return func(p Provider) error {
+		if secs <= 0 {
+			return errors.New("the authentication session max lifetime must be positive")
+		}
+		p.config.AuthnSessionMaxLifetimeSecs = secs
+		return nil
+	}
+}
+
+// WithAuthorizationCodeLifetime overrides for how long authorization codes can
+// be exchanged for tokens.
+// The default is [defaultAuthzCodeLifetimeSecs]. For [goidc.ProfileFAPI2],
+// the lifetime cannot exceed [fapi2MaxAuthzCodeLifetimeSecs].
+func WithAuthorizationCodeLifetime(secs int) ProviderOption {
+	return func(p Provider) error {
+		if secs <= 0 {
+			return errors.New("the authorization code lifetime must be positive")
+		}
+		p.config.AuthzCodeLifetimeSecs = secs
+		return nil
+	}
+}
+
+// WithAuthorizationCodeLength overrides the number of characters of
+// authorization codes, which controls their entropy.
+// The default is [defaultAuthzCodeLength] and the length cannot be less than
+// [minAuthzCodeLength].
+func WithAuthorizationCodeLength(length int) ProviderOption {
+	return func(p Provider) error {
+		if length < minAuthzCodeLength {
+			return fmt.Errorf("the authorization code length must be at least %d", minAuthzCodeLength)
+		}
+		p.config.AuthzCodeLength = length
+		return nil
+	}
+}
+
+// WithCallbackBinding binds authentication sessions in progress to the user
+// agent that started them.
+// When the session is created, a cookie scoped to the callback endpoint of
+// the session is set and calls to the callback endpoint without it are
+// rejected. This prevents an attacker who learned a callback ID from resuming
+// the authentication of another user.
+func WithCallbackBinding() ProviderOption {
+	return func(p Provider) error {
+		p.config.CallbackBindingIsEnabled = true
+		return nil
+	}
+}
+
 // WithStaticClient adds a static client to the provider.
 // The static clients are kept in memory only and are checked before consulting
 // the client manager.
@@ -793,6 +1664,35 @@ func WithStaticClient(client *goidc.Client) ProviderOption {
 	}
 }
 
+// WithStaticClientsFromFile loads static clients from a JSON file or from all
+// the JSON files in a directory. Each file holds either a single client or an
+// array of clients in the same format they are stored, e.g.
+//
+//	[{
+//		"client_id": "random_client",
+//		"hashed_secret": "$2a$10$...",
+//		"token_endpoint_auth_method": "client_secret_post",
+//		"redirect_uris": ["https://rp.example.com/callback"],
+//		"grant_types": ["authorization_code"],
+//		"response_types": ["code"],
+//		"scope": "openid",
+//		"jwks": {"keys": [...]}
+//	}]
+//
+// The clients are loaded when the provider is created and their metadata is
+// validated with the same rules as dynamic client registration.
+// Like the clients added with [WithStaticClient], they are kept in memory and
+// are checked before consulting the client manager.
+func WithStaticClientsFromFile(path string) ProviderOption {
+	return func(p Provider) error {
+		if path == "" {
+			return errors.New("the static clients path must not be empty")
+		}
+		p.config.StaticClientPaths = append(p.config.StaticClientPaths, path)
+		return nil
+	}
+}
+
 // WithPolicy adds an authentication policy that will be evaluated at runtime
 // and then executed if selected.
 func WithPolicy(policy goidc.AuthnPolicy) ProviderOption {
@@ -813,6 +1713,30 @@ func WithRenderErrorFunc(render goidc.RenderErrorFunc) ProviderOption {
 	}
 }
 
+// WithTracerProvider instruments the provider with the tracer obtained from tp.
+// Spans are created for every endpoint request and for internal stages such as
+// client authentication, grant validation, token minting and storage calls.
+// An OpenTelemetry tracer provider can be used with an adapter that implements
+// [goidc.TracerProvider].
+func WithTracerProvider(tp goidc.TracerProvider) ProviderOption {
+	return func(p Provider) error {
+		p.config.Tracer = tp.Tracer(goidc.InstrumentationName)
+		return nil
+	}
+}
+
+// WithMeterProvider records metrics of the provider with the meter obtained
+// from mp, e.g. tokens issued per grant type, authorization outcomes, client
+// authentication failures, endpoint latencies and active grants.
+// The metric names are listed in the goidc package, e.g.
+// [goidc.MetricTokens].
+func WithMeterProvider(mp goidc.MeterProvider) ProviderOption {
+	return func(p Provider) error {
+		p.config.Metrics = oidc.NewMetrics(mp.Meter(goidc.InstrumentationName))
+		return nil
+	}
+}
+
 // WithNotifyErrorFunc defines a handler to be executed when an error happens.
 // For instance, this can be used to log information about the error.
 func WithNotifyErrorFunc(f goidc.NotifyErrorFunc) ProviderOption {
@@ -822,6 +1746,76 @@ func WithNotifyErrorFunc(f goidc.NotifyErrorFunc) ProviderOption {
 	}
 }
 
+// WithNotifyTokenEventFunc defines a handler to be executed when tokens are
+// issued, refreshed, revoked or deleted because they expired.
+// For instance, this can be used to feed audit pipelines or to invalidate
+// caches without polling the storage.
+func WithNotifyTokenEventFunc(f goidc.NotifyTokenEventFunc) ProviderOption {
+	return func(p Provider) error {
+		p.config.NotifyTokenEventFunc = f
+		return nil
+	}
+}
+
+// WithClaimsSource defines a function to load the claims of users when ID
+// tokens and user info responses are issued, instead of relying only on the
+// claims set in the authentication session.
+// cacheLifetimeSecs optionally maps claim names to how many seconds their
+// values can be reused. The source is called again as soon as any of the
+// claims it returned is stale, so claims without a lifetime are always loaded.
+// The claims are cached per client, user and scopes for at most 10000 of
+// these combinations, evicting the ones closest to expiring first.
+func WithClaimsSource(
+	f goidc.ClaimsSourceFunc,
+	cacheLifetimeSecs map[string]int,
+) ProviderOption {
+	return func(p Provider) error {
+		p.config.ClaimsSourceFunc = f
+		if len(cacheLifetimeSecs) != 0 {
+			p.config.ClaimsCache = oidc.NewClaimsCache(cacheLifetimeSecs, defaultClaimsCacheMaxEntries)
+		}
+		return nil
+	}
+}
+
+// WithClaimMappings defines which user claims each scope gives access to.
+// The claims mapped are only returned in ID tokens and user info responses if
+// their scope was granted or the client requested them with the claims
+// parameter. Claims not mapped to any scope are always returned.
+// If mappings is nil, [goidc.StandardClaimMappings] is used.
+func WithClaimMappings(mappings map[string][]string) ProviderOption {
+	return func(p Provider) error {
+		if mappings == nil {
+			mappings = goidc.StandardClaimMappings
+		}
+		p.config.ClaimMappings = mappings
+		return nil
+	}
+}
+
+// WithIDTokenClaimsMode defines whether the user claims mapped to scopes are
+// placed in ID tokens or only returned by the user info endpoint, see
+// [goidc.IDTokenClaimsMode].
+// The claims mapped to scopes are the ones defined with [WithClaimMappings] or
+// [goidc.StandardClaimMappings] if no mappings are defined.
+func WithIDTokenClaimsMode(mode goidc.IDTokenClaimsMode) ProviderOption {
+	return func(p Provider) error {
+		p.config.IDTokenClaimsMode = mode
+		return nil
+	}
+}
+
+// WithFilterClaimsFunc defines a function to transform the user claims before
+// they are returned in ID tokens and user info responses.
+// This allows data minimization policies to be enforced in one place, e.g.
+// redacting claims for low trust clients.
+func WithFilterClaimsFunc(f goidc.FilterClaimsFunc) ProviderOption {
+	return func(p Provider) error {
+		p.config.FilterClaimsFunc = f
+		return nil
+	}
+}
+
 // WithCheckJTIFunc registers a function to validate JWT IDs (JTI) during JWT
 // processing.
 // This function is used to prevent replay attacks by ensuring that each JTI is
@@ -833,6 +1827,17 @@ func WithCheckJTIFunc(f goidc.CheckJTIFunc) ProviderOption {
 	}
 }
 
+// WithNonceReplayPrevention makes the provider reject authorization requests
+// for implicit and hybrid flows whose nonce was already used by the client
+// during the lifetime of ID tokens.
+// [goidc.NonceCache] provides an in memory implementation.
+func WithNonceReplayPrevention(f goidc.CheckNonceFunc) ProviderOption {
+	return func(p Provider) error {
+		p.config.CheckNonceFunc = f
+		return nil
+	}
+}
+
 // WithResourceIndicators enables client to indicate which resources they intend
 // to access.
 func WithResourceIndicators(
@@ -869,6 +1874,16 @@ func WithHTTPClientFunc(f goidc.HTTPClientFunc) ProviderOption {
 	}
 }
 
+// WithIDFunc defines how the IDs of authentication, grant and user sessions,
+// callbacks, request URIs and dynamically registered clients are generated.
+// The default IDs are used when f returns an empty string.
+func WithIDFunc(f goidc.IDFunc) ProviderOption {
+	return func(p Provider) error {
+		p.config.IDFunc = f
+		return nil
+	}
+}
+
 // WithJWTBearerGrant enables the JWT bearer grant type.
 func WithJWTBearerGrant(
 	f goidc.HandleJWTBearerGrantAssertionFunc,
@@ -881,6 +1896,31 @@ func WithJWTBearerGrant(
 	}
 }
 
+// WithCustomGrant registers a grant type not supported natively, e.g. a vendor
+// specific one, which is then advertised in discovery and accepted at the token
+// endpoint from the clients registered with it.
+// The client is authenticated before f runs and the tokens are issued from the
+// [goidc.GrantInfo] it returns, bound to the DPoP key or the client certificate
+// if informed, as for any other grant.
+func WithCustomGrant(grantType goidc.GrantType, f goidc.HandleCustomGrantFunc) ProviderOption {
+	return func(p Provider) error {
+		switch grantType {
+		case "", goidc.GrantAuthorizationCode, goidc.GrantClientCredentials,
+			goidc.GrantImplicit, goidc.GrantRefreshToken, goidc.GrantJWTBearer:
+			return fmt.Errorf("the grant type %q cannot be registered as custom", grantType)
+		}
+
+		if p.config.CustomGrantFuncs == nil {
+			p.config.CustomGrantFuncs = map[goidc.GrantType]goidc.HandleCustomGrantFunc{}
+		}
+		if _, ok := p.config.CustomGrantFuncs[grantType]; !ok {
+			p.config.GrantTypes = append(p.config.GrantTypes, grantType)
+		}
+		p.config.CustomGrantFuncs[grantType] = f
+		return nil
+	}
+}
+
 // WithJWTBearerGrantClientAuthnRequired makes client authentication required
 // for the jwt bearer grant type.
 func WithJWTBearerGrantClientAuthnRequired() ProviderOption {
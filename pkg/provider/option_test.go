@@ -4,8 +4,10 @@ import (
 	"context"
 	"crypto/x509"
 	"net/http"
+	"net/netip"
 	"slices"
 	"testing"
+	"time"
 
 	"github.com/go-jose/go-jose/v4"
 	"github.com/google/go-cmp/cmp"
@@ -75,6 +77,403 @@ func TestWithGrantSessionStorage(t *testing.T) {
 	}
 }
 
+func TestWithInMemoryStorageLimits(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+
+	// When.
+	err := WithInMemoryStorageLimits(100, 200)(p)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if p.config.MaxAuthnSessions != 100 {
+		t.Errorf("MaxAuthnSessions = %d, want 100", p.config.MaxAuthnSessions)
+	}
+
+	if p.config.MaxGrantSessions != 200 {
+		t.Errorf("MaxGrantSessions = %d, want 200", p.config.MaxGrantSessions)
+	}
+}
+
+func TestWithInMemoryStorageLimits_Negative(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+
+	// When.
+	err := WithInMemoryStorageLimits(-1, 0)(p)
+
+	// Then.
+	if err == nil {
+		t.Fatal("negative limits must be rejected")
+	}
+}
+
+func TestWithInMemoryStorageMaxBytes(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+
+	// When.
+	err := WithInMemoryStorageMaxBytes(1000, 2000)(p)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if p.config.MaxAuthnSessionBytes != 1000 {
+		t.Errorf("MaxAuthnSessionBytes = %d, want 1000", p.config.MaxAuthnSessionBytes)
+	}
+
+	if p.config.MaxGrantSessionBytes != 2000 {
+		t.Errorf("MaxGrantSessionBytes = %d, want 2000", p.config.MaxGrantSessionBytes)
+	}
+}
+
+func TestWithInMemoryStorageMaxBytes_Negative(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+
+	// When.
+	err := WithInMemoryStorageMaxBytes(0, -1)(p)
+
+	// Then.
+	if err == nil {
+		t.Fatal("negative limits must be rejected")
+	}
+}
+
+func TestWithParallelValidation(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+
+	// When.
+	err := WithParallelValidation()(p)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !p.config.ParallelValidationIsEnabled {
+		t.Error("ParallelValidationIsEnabled cannot be false")
+	}
+}
+
+func TestWithAsyncGrantSessionStorage(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+
+	// When.
+	err := WithAsyncGrantSessionStorage(100, nil)(p)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !p.config.AsyncGrantSessionIsEnabled {
+		t.Error("AsyncGrantSessionIsEnabled cannot be false")
+	}
+
+	if p.config.GrantSessionQueueSize != 100 {
+		t.Errorf("GrantSessionQueueSize = %d, want 100", p.config.GrantSessionQueueSize)
+	}
+}
+
+func TestWithAsyncGrantSessionStorage_InvalidQueueSize(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+
+	// When.
+	err := WithAsyncGrantSessionStorage(0, nil)(p)
+
+	// Then.
+	if err == nil {
+		t.Fatal("the queue size must be positive")
+	}
+}
+
+func TestWithCustomGrant(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+	grantType := goidc.GrantType("urn:example:params:grant-type:custom")
+
+	// When.
+	err := WithCustomGrant(grantType, func(*http.Request, *goidc.Client) (goidc.GrantInfo, error) {
+		return goidc.GrantInfo{}, nil
+	})(p)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if p.config.CustomGrantFuncs[grantType] == nil {
+		t.Error("the custom grant handler was not registered")
+	}
+
+	if !slices.Contains(p.config.GrantTypes, grantType) {
+		t.Errorf("GrantTypes = %v, want %s included", p.config.GrantTypes, grantType)
+	}
+}
+
+func TestWithCustomGrant_NativeGrantType(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+
+	// When.
+	err := WithCustomGrant(goidc.GrantClientCredentials, nil)(p)
+
+	// Then.
+	if err == nil {
+		t.Fatal("native grant types cannot be registered as custom")
+	}
+}
+
+func TestWithClientAuthnFunc(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+	method := goidc.ClientAuthnType("attest_jwt_client_auth")
+
+	// When.
+	err := WithClientAuthnFunc(method, func(*http.Request, *goidc.Client) error {
+		return nil
+	})(p)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if p.config.ClientAuthnFuncs[method] == nil {
+		t.Error("the client authentication function was not registered")
+	}
+}
+
+func TestWithClientAuthnFunc_NativeMethod(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+
+	// When.
+	err := WithClientAuthnFunc(goidc.ClientAuthnPrivateKeyJWT, nil)(p)
+
+	// Then.
+	if err == nil {
+		t.Fatal("native methods cannot be registered as custom")
+	}
+}
+
+func TestWithRequestLimits(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+
+	// When.
+	err := WithRequestLimits(goidc.RequestLimits{MaxBodyBytes: 1024, Timeout: time.Second})(p)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := Provider{
+		config: &oidc.Configuration{
+			RequestLimits: goidc.RequestLimits{MaxBodyBytes: 1024, Timeout: time.Second},
+		},
+	}
+	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestWithEndpointRequestLimits(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+
+	// When.
+	err := WithEndpointRequestLimits("POST /par", goidc.RequestLimits{MaxBodyBytes: 1024})(p)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := Provider{
+		config: &oidc.Configuration{
+			EndpointRequestLimits: map[string]goidc.RequestLimits{
+				"POST /par": {MaxBodyBytes: 1024},
+			},
+		},
+	}
+	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestWithEndpointRequestLimits_Negative(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+
+	// When.
+	err := WithEndpointRequestLimits("POST /par", goidc.RequestLimits{Timeout: -time.Second})(p)
+
+	// Then.
+	if err == nil {
+		t.Fatal("negative limits must be rejected")
+	}
+}
+
+func TestWithConsent(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+
+	// When.
+	err := WithConsent()(p)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := Provider{
+		config: &oidc.Configuration{
+			ConsentIsEnabled: true,
+		},
+	}
+	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestWithConsentStorage(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+	st := storage.NewConsentManager()
+
+	// When.
+	err := WithConsentStorage(st)(p)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if p.config.ConsentManager != st {
+		t.Errorf("invalid consent manager")
+	}
+
+	if !p.config.ConsentIsEnabled {
+		t.Errorf("consent must be enabled")
+	}
+}
+
+func TestWithUserSessions(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+
+	// When.
+	err := WithUserSessions(3600)(p)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := Provider{
+		config: &oidc.Configuration{
+			UserSessionIsEnabled:    true,
+			UserSessionLifetimeSecs: 3600,
+		},
+	}
+	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestWithSessionIDClaim(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+
+	// When.
+	err := WithSessionIDClaim()(p)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := Provider{
+		config: &oidc.Configuration{
+			SessionIDClaimIsEnabled: true,
+		},
+	}
+	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestWithUserSessionStorage(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+	st := storage.NewUserSessionManager()
+
+	// When.
+	err := WithUserSessionStorage(st)(p)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if p.config.UserSessionManager != st {
+		t.Errorf("invalid user session manager")
+	}
+
+	if !p.config.UserSessionIsEnabled {
+		t.Errorf("user sessions must be enabled")
+	}
+}
+
 func TestWithPathPrefix(t *testing.T) {
 	// Given.
 	p := Provider{
@@ -82,7 +481,1271 @@ func TestWithPathPrefix(t *testing.T) {
 	}
 
 	// When.
-	err := WithPathPrefix("/auth")(p)
+	err := WithPathPrefix("/auth")(p)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := Provider{
+		config: &oidc.Configuration{
+			EndpointPrefix: "/auth",
+		},
+	}
+	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestWithJWKSEndpoint(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+
+	// When.
+	err := WithJWKSEndpoint("/jwks")(p)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := Provider{
+		config: &oidc.Configuration{
+			EndpointJWKS: "/jwks",
+		},
+	}
+	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestWithTokenEndpoint(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+
+	// When.
+	err := WithTokenEndpoint("/token")(p)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := Provider{
+		config: &oidc.Configuration{
+			EndpointToken: "/token",
+		},
+	}
+	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestWithAuthorizeEndpoint(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+
+	// When.
+	err := WithAuthorizeEndpoint("/authorize")(p)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := Provider{
+		config: &oidc.Configuration{
+			EndpointAuthorize: "/authorize",
+		},
+	}
+	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestWithPAREndpoint(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+
+	// When.
+	err := WithPAREndpoint("/par")(p)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := Provider{
+		config: &oidc.Configuration{
+			EndpointPushedAuthorization: "/par",
+		},
+	}
+	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestWithDCREndpoint(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+
+	// When.
+	err := WithDCREndpoint("/register")(p)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := Provider{
+		config: &oidc.Configuration{
+			EndpointDCR: "/register",
+		},
+	}
+	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestWithUserInfoEndpoint(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+
+	// When.
+	err := WithUserInfoEndpoint("/userinfo")(p)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := Provider{
+		config: &oidc.Configuration{
+			EndpointUserInfo: "/userinfo",
+		},
+	}
+	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestWithIntrospectionEndpoint(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+
+	// When.
+	err := WithIntrospectionEndpoint("/introspect")(p)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := Provider{
+		config: &oidc.Configuration{
+			EndpointIntrospection: "/introspect",
+		},
+	}
+	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestWithTokenRevocationEndpoint(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+
+	// When.
+	err := WithTokenRevocationEndpoint("/revoke")(p)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := Provider{
+		config: &oidc.Configuration{
+			EndpointTokenRevocation: "/revoke",
+		},
+	}
+	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestWithClaims(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+
+	// When.
+	err := WithClaims("claim_one", "claim_two")(p)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := Provider{
+		config: &oidc.Configuration{
+			Claims:     []string{"claim_one", "claim_two"},
+			ClaimTypes: []goidc.ClaimType{goidc.ClaimTypeNormal},
+		},
+	}
+	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestWithClaimTypes(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+
+	// When.
+	err := WithClaimTypes(goidc.ClaimTypeDistributed)(p)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := Provider{
+		config: &oidc.Configuration{
+			ClaimTypes: []goidc.ClaimType{goidc.ClaimTypeDistributed},
+		},
+	}
+	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestWithUserSignatureAlgs(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+
+	// When.
+	err := WithUserSignatureAlgs(jose.RS256)(p)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := Provider{
+		config: &oidc.Configuration{
+			UserDefaultSigAlg: jose.RS256,
+			UserSigAlgs:       []jose.SignatureAlgorithm{jose.RS256},
+		},
+	}
+	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestWithIDTokenLifetime(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+
+	// When.
+	err := WithIDTokenLifetime(60)(p)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := Provider{
+		config: &oidc.Configuration{
+			IDTokenLifetimeSecs: 60,
+		},
+	}
+	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestWithUserInfoEncryption(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+
+	// When.
+	err := WithUserInfoEncryption(jose.RSA_OAEP)(p)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := Provider{
+		config: &oidc.Configuration{
+			UserEncIsEnabled: true,
+			UserKeyEncAlgs:   []jose.KeyAlgorithm{jose.RSA_OAEP},
+		},
+	}
+	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestWithUserInfoEncryption_NoAlgInformed(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+
+	// When.
+	err := WithUserInfoEncryption(jose.RSA_OAEP_256)(p)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := Provider{
+		config: &oidc.Configuration{
+			UserEncIsEnabled: true,
+			UserKeyEncAlgs:   []jose.KeyAlgorithm{jose.RSA_OAEP_256},
+		},
+	}
+	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestWithUserInfoContentEncryptionAlgs(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+
+	// When.
+	err := WithUserInfoContentEncryptionAlgs(jose.A128GCM)(p)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := Provider{
+		config: &oidc.Configuration{
+			UserDefaultContentEncAlg: jose.A128GCM,
+			UserContentEncAlgs:       []jose.ContentEncryption{jose.A128GCM},
+		},
+	}
+	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestWithDCR(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+	var handleDCRFunc goidc.HandleDynamicClientFunc = func(
+		r *http.Request,
+		c *goidc.ClientMetaInfo,
+	) error {
+		return nil
+	}
+	var validateInitialTokenFunc goidc.ValidateInitialAccessTokenFunc = func(
+		r *http.Request,
+		s string,
+	) error {
+		return nil
+	}
+
+	// When.
+	err := WithDCR(handleDCRFunc, validateInitialTokenFunc)(p)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !p.config.DCRIsEnabled {
+		t.Error("DCRIsEnabled cannot be false")
+	}
+
+	if p.config.HandleDynamicClientFunc == nil {
+		t.Error("HandleDynamicClientFunc cannot be nil")
+	}
+
+	if p.config.ValidateInitialAccessTokenFunc == nil {
+		t.Error("ValidateInitialAccessTokenFunc cannot be nil")
+	}
+}
+
+func TestWithDCRInitialAccessTokens(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+
+	// When.
+	err := WithDCRInitialAccessTokens()(p)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := Provider{
+		config: &oidc.Configuration{
+			DCRInitialAccessTokenIsRequired: true,
+		},
+	}
+	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestWithDCROpenRegistration(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+
+	// When.
+	err := WithDCROpenRegistration(5, 3600)(p)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := Provider{
+		config: &oidc.Configuration{
+			DCROpenRegistrationIsEnabled: true,
+			DCRMaxRegistrationsPerIP:     5,
+			DCRRegistrationWindowSecs:    3600,
+		},
+	}
+	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestWithDCROpenRegistration_InvalidLimit(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+
+	// When.
+	err := WithDCROpenRegistration(0, 3600)(p)
+
+	// Then.
+	if err == nil {
+		t.Fatal("a non positive limit must be rejected")
+	}
+}
+
+func TestWithDCRChallengeFunc(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+
+	// When.
+	err := WithDCRChallengeFunc(func(r *http.Request) error { return nil })(p)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if p.config.DCRChallengeFunc == nil {
+		t.Error("DCRChallengeFunc cannot be nil")
+	}
+}
+
+func TestWithDCRTokenRotation(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+
+	// When.
+	err := WithDCRTokenRotation()(p)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := Provider{
+		config: &oidc.Configuration{
+			DCRTokenRotationIsEnabled: true,
+		},
+	}
+	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestWithCORS(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+
+	// When.
+	err := WithCORS()(p)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := Provider{
+		config: &oidc.Configuration{
+			CORSIsEnabled: true,
+		},
+	}
+	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestWithDCRJWKSURIFetch(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+
+	// When.
+	err := WithDCRJWKSURIFetch()(p)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := Provider{
+		config: &oidc.Configuration{
+			DCRJWKSURIIsFetched: true,
+		},
+	}
+	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestWithDCRClientURIVerification(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+
+	// When.
+	err := WithDCRClientURIVerification()(p)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := Provider{
+		config: &oidc.Configuration{
+			DCRClientURIsAreVerified: true,
+		},
+	}
+	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestWithURIPolicy(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+
+	// When.
+	err := WithURIPolicy(goidc.DenyPrivateNetworkURIs)(p)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if p.config.URIPolicyFunc == nil {
+		t.Error("URIPolicyFunc cannot be nil")
+	}
+}
+
+func TestWithPrivateNetworkURIsDenied(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+
+	// When.
+	err := WithPrivateNetworkURIsDenied()(p)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := Provider{
+		config: &oidc.Configuration{
+			PrivateNetworkURIsAreDenied: true,
+		},
+	}
+	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestWithClientCredentialsGrant(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+
+	// When.
+	err := WithClientCredentialsGrant()(p)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := Provider{
+		config: &oidc.Configuration{
+			GrantTypes: []goidc.GrantType{goidc.GrantClientCredentials},
+		},
+	}
+	if diff := cmp.Diff(
+		p,
+		want,
+		cmp.AllowUnexported(Provider{}),
+	); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestWithRefreshTokenGrant(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+	var shouldIssueRefreshTokenFunc goidc.ShouldIssueRefreshTokenFunc = func(
+		c *goidc.Client,
+		gi goidc.GrantInfo,
+	) bool {
+		return false
+	}
+
+	// When.
+	err := WithRefreshTokenGrant(shouldIssueRefreshTokenFunc, 300)(p)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !slices.Contains(p.config.GrantTypes, goidc.GrantRefreshToken) {
+		t.Error("refresh token grant is missing")
+	}
+
+	if p.config.ShouldIssueRefreshTokenFunc == nil {
+		t.Error("ValidateInitialAccessTokenFunc cannot be nil")
+	}
+}
+
+func TestWithRefreshTokenGrant_Defaults(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+
+	// When.
+	err := WithRefreshTokenGrant(nil, 0)(p)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if p.config.RefreshTokenLifetimeSecs != defaultRefreshTokenLifetimeSecs {
+		t.Errorf("RefreshTokenLifetimeSecs = %d, want %d", p.config.RefreshTokenLifetimeSecs, defaultRefreshTokenLifetimeSecs)
+	}
+
+	client := &goidc.Client{}
+	if !p.config.ShouldIssueRefreshTokenFunc(client, goidc.GrantInfo{}) {
+		t.Error("refresh tokens should be issued by default")
+	}
+
+	client.RefreshTokenIsDisabled = true
+	if p.config.ShouldIssueRefreshTokenFunc(client, goidc.GrantInfo{}) {
+		t.Error("refresh tokens should not be issued to clients that disabled them")
+	}
+}
+
+func TestWithRefreshTokenRotation(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+
+	// When.
+	err := WithRefreshTokenRotation()(p)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := Provider{
+		config: &oidc.Configuration{
+			RefreshTokenRotationIsEnabled: true,
+		},
+	}
+	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestWithOpenIDScopeRequired(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+
+	// When.
+	err := WithOpenIDScopeRequired()(p)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := Provider{
+		config: &oidc.Configuration{
+			OpenIDIsRequired: true,
+		},
+	}
+	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestWithClientTokenMetadata(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+
+	// When.
+	err := WithClientTokenMetadata(600)(p)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := Provider{
+		config: &oidc.Configuration{
+			ClientTokenMetadataIsEnabled: true,
+			ClientTokenMaxLifetimeSecs:   600,
+		},
+	}
+	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestWithTokenOptions(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+	var tokenOpts goidc.TokenOptionsFunc = func(
+		grantInfo goidc.GrantInfo,
+	) goidc.TokenOptions {
+		return goidc.NewOpaqueTokenOptions(10, 60)
+	}
+
+	// When.
+	err := WithTokenOptions(tokenOpts)(p)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if p.config.TokenOptionsFunc == nil {
+		t.Error("TokenOptionsFunc cannot be nil")
+	}
+}
+
+func TestWithTokenEncryptionKey(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+
+	// When.
+	err := WithTokenEncryptionKey([]byte("0123456789abcdef"))(p)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := Provider{
+		config: &oidc.Configuration{
+			TokenEncryptionKey: []byte("0123456789abcdef"),
+		},
+	}
+	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestWithHandleGrantFunc(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+	var grantHandler goidc.HandleGrantFunc = func(r *http.Request, gi *goidc.GrantInfo) error {
+		return nil
+	}
+
+	// When.
+	err := WithHandleGrantFunc(grantHandler)(p)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if p.config.HandleGrantFunc == nil {
+		t.Error("HandleGrantFunc cannot be nil")
+	}
+}
+
+func TestWithTokenResponseHookFunc(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+	var hook goidc.TokenResponseHookFunc = func(r *http.Request, gi goidc.GrantInfo) map[string]any {
+		return nil
+	}
+
+	// When.
+	err := WithTokenResponseHookFunc(hook)(p)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if p.config.TokenResponseHookFunc == nil {
+		t.Error("TokenResponseHookFunc cannot be nil")
+	}
+}
+
+func TestWithTokenExpirationHints(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+
+	// When.
+	err := WithTokenExpirationHints()(p)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := Provider{
+		config: &oidc.Configuration{
+			TokenExpirationHintsIsEnabled: true,
+		},
+	}
+	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestWithTokenRenewalHintFunc(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+	var hook goidc.TokenRenewalHintFunc = func(*http.Request, goidc.TokenRenewal) map[string]any {
+		return nil
+	}
+
+	// When.
+	err := WithTokenRenewalHintFunc(hook)(p)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if p.config.TokenRenewalHintFunc == nil {
+		t.Error("TokenRenewalHintFunc cannot be nil")
+	}
+}
+
+func TestWithImplicitGrant(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+
+	// When.
+	err := WithImplicitGrant()(p)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := Provider{
+		config: &oidc.Configuration{
+			GrantTypes: []goidc.GrantType{goidc.GrantImplicit},
+		},
+	}
+	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestWithScopes(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+
+	// When
+	err := WithScopes(goidc.ScopeEmail)(p)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(p.config.Scopes) != 2 {
+		t.Error("there should be only two scopes")
+	}
+}
+
+func TestWithPAR(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+
+	// When.
+	err := WithPAR(60)(p)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := Provider{
+		config: &oidc.Configuration{
+			PARIsEnabled:    true,
+			PARLifetimeSecs: 60,
+		},
+	}
+	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestWithPARRequired(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+
+	// When.
+	err := WithPARRequired(60)(p)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := Provider{
+		config: &oidc.Configuration{
+			PARIsEnabled:    true,
+			PARIsRequired:   true,
+			PARLifetimeSecs: 60,
+		},
+	}
+	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestWithPARClientCertBinding(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+
+	// When.
+	err := WithPARClientCertBinding()(p)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := Provider{
+		config: &oidc.Configuration{
+			PARClientCertBindingIsEnabled: true,
+		},
+	}
+	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestWithUnregisteredRedirectURIsForPAR(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+
+	// When.
+	err := WithUnregisteredRedirectURIsForPAR()(p)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := Provider{
+		config: &oidc.Configuration{
+			PARAllowUnregisteredRedirectURI: true,
+		},
+	}
+	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestWithJAR(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+
+	// When.
+	err := WithJAR(jose.PS256)(p)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := Provider{
+		config: &oidc.Configuration{
+			JARIsEnabled: true,
+			JARSigAlgs:   []jose.SignatureAlgorithm{jose.PS256},
+		},
+	}
+	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestWithJAR_NoAlgInformed(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+
+	// When.
+	err := WithJAR(jose.RS256)(p)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := Provider{
+		config: &oidc.Configuration{
+			JARIsEnabled: true,
+			JARSigAlgs:   []jose.SignatureAlgorithm{jose.RS256},
+		},
+	}
+	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestWithJARRequired(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+
+	// When.
+	err := WithJARRequired(jose.PS256)(p)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := Provider{
+		config: &oidc.Configuration{
+			JARIsEnabled:  true,
+			JARIsRequired: true,
+			JARSigAlgs:    []jose.SignatureAlgorithm{jose.PS256},
+		},
+	}
+	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestWithJAREncryption(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+
+	// When.
+	err := WithJAREncryption(jose.RSA_OAEP_256)(p)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := Provider{
+		config: &oidc.Configuration{
+			JAREncIsEnabled: true,
+			JARKeyEncAlgs:   []jose.KeyAlgorithm{jose.RSA_OAEP_256},
+		},
+	}
+	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestWithJAREncryptionKeys(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+
+	// When.
+	err := WithJAREncryptionKeys("enc_key")(p)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := Provider{
+		config: &oidc.Configuration{
+			JAREncKeyIDs: []string{"enc_key"},
+		},
+	}
+	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestWithJARRetiredEncryptionKey(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+	key := oidctest.PrivateRSAOAEPJWK(t, "retired_enc_key")
+
+	// When.
+	err := WithJARRetiredEncryptionKey(key, 10)(p)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := Provider{
+		config: &oidc.Configuration{
+			JARRetiredEncKeys: []goidc.RetiredKey{{Key: key, ExpiresAtTimestamp: 10}},
+		},
+	}
+	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestWithJARContentEncryptionAlgs(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+
+	// When.
+	err := WithJARContentEncryptionAlgs(jose.A128GCM)(p)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := Provider{
+		config: &oidc.Configuration{
+			JARContentEncAlgs: []jose.ContentEncryption{jose.A128GCM},
+		},
+	}
+	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestWithJARM(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+
+	// When.
+	err := WithJARM(jose.RS256)(p)
 
 	// Then.
 	if err != nil {
@@ -91,7 +1754,9 @@ func TestWithPathPrefix(t *testing.T) {
 
 	want := Provider{
 		config: &oidc.Configuration{
-			EndpointPrefix: "/auth",
+			JARMIsEnabled:     true,
+			JARMDefaultSigAlg: jose.RS256,
+			JARMSigAlgs:       []jose.SignatureAlgorithm{jose.RS256},
 		},
 	}
 	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
@@ -99,14 +1764,14 @@ func TestWithPathPrefix(t *testing.T) {
 	}
 }
 
-func TestWithJWKSEndpoint(t *testing.T) {
+func TestWithJARMEncryption(t *testing.T) {
 	// Given.
 	p := Provider{
 		config: &oidc.Configuration{},
 	}
 
 	// When.
-	err := WithJWKSEndpoint("/jwks")(p)
+	err := WithJARMEncryption(jose.RSA_OAEP)(p)
 
 	// Then.
 	if err != nil {
@@ -115,7 +1780,8 @@ func TestWithJWKSEndpoint(t *testing.T) {
 
 	want := Provider{
 		config: &oidc.Configuration{
-			EndpointJWKS: "/jwks",
+			JARMEncIsEnabled: true,
+			JARMKeyEncAlgs:   []jose.KeyAlgorithm{jose.RSA_OAEP},
 		},
 	}
 	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
@@ -123,14 +1789,14 @@ func TestWithJWKSEndpoint(t *testing.T) {
 	}
 }
 
-func TestWithTokenEndpoint(t *testing.T) {
+func TestWithJARMEncryption_NoAlgInformed(t *testing.T) {
 	// Given.
 	p := Provider{
 		config: &oidc.Configuration{},
 	}
 
 	// When.
-	err := WithTokenEndpoint("/token")(p)
+	err := WithJARMEncryption(jose.RSA_OAEP_256)(p)
 
 	// Then.
 	if err != nil {
@@ -139,7 +1805,8 @@ func TestWithTokenEndpoint(t *testing.T) {
 
 	want := Provider{
 		config: &oidc.Configuration{
-			EndpointToken: "/token",
+			JARMEncIsEnabled: true,
+			JARMKeyEncAlgs:   []jose.KeyAlgorithm{jose.RSA_OAEP_256},
 		},
 	}
 	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
@@ -147,14 +1814,14 @@ func TestWithTokenEndpoint(t *testing.T) {
 	}
 }
 
-func TestWithAuthorizeEndpoint(t *testing.T) {
+func TestWithJARMContentEncryptionAlgs(t *testing.T) {
 	// Given.
 	p := Provider{
 		config: &oidc.Configuration{},
 	}
 
 	// When.
-	err := WithAuthorizeEndpoint("/authorize")(p)
+	err := WithJARMContentEncryptionAlgs(jose.A128GCM)(p)
 
 	// Then.
 	if err != nil {
@@ -163,7 +1830,8 @@ func TestWithAuthorizeEndpoint(t *testing.T) {
 
 	want := Provider{
 		config: &oidc.Configuration{
-			EndpointAuthorize: "/authorize",
+			JARMDefaultContentEncAlg: jose.A128GCM,
+			JARMContentEncAlgs:       []jose.ContentEncryption{jose.A128GCM},
 		},
 	}
 	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
@@ -171,14 +1839,14 @@ func TestWithAuthorizeEndpoint(t *testing.T) {
 	}
 }
 
-func TestWithPAREndpoint(t *testing.T) {
+func TestWithAssertionLifetime(t *testing.T) {
 	// Given.
 	p := Provider{
 		config: &oidc.Configuration{},
 	}
 
 	// When.
-	err := WithPAREndpoint("/par")(p)
+	err := WithAssertionLifetime(60)(p)
 
 	// Then.
 	if err != nil {
@@ -187,7 +1855,7 @@ func TestWithPAREndpoint(t *testing.T) {
 
 	want := Provider{
 		config: &oidc.Configuration{
-			EndpointPushedAuthorization: "/par",
+			AssertionLifetimeSecs: 60,
 		},
 	}
 	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
@@ -195,14 +1863,14 @@ func TestWithPAREndpoint(t *testing.T) {
 	}
 }
 
-func TestWithDCREndpoint(t *testing.T) {
+func TestWithIssuerResponseParameter(t *testing.T) {
 	// Given.
 	p := Provider{
 		config: &oidc.Configuration{},
 	}
 
 	// When.
-	err := WithDCREndpoint("/register")(p)
+	err := WithIssuerResponseParameter()(p)
 
 	// Then.
 	if err != nil {
@@ -211,7 +1879,7 @@ func TestWithDCREndpoint(t *testing.T) {
 
 	want := Provider{
 		config: &oidc.Configuration{
-			EndpointDCR: "/register",
+			IssuerRespParamIsEnabled: true,
 		},
 	}
 	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
@@ -219,38 +1887,47 @@ func TestWithDCREndpoint(t *testing.T) {
 	}
 }
 
-func TestWithUserInfoEndpoint(t *testing.T) {
+func TestWithAuthorizationDetails(t *testing.T) {
 	// Given.
 	p := Provider{
 		config: &oidc.Configuration{},
 	}
+	var compareDetailsFunc goidc.CompareAuthDetailsFunc = func(
+		granted, requested []goidc.AuthorizationDetail,
+	) error {
+		return nil
+	}
 
 	// When.
-	err := WithUserInfoEndpoint("/userinfo")(p)
+	err := WithAuthorizationDetails(compareDetailsFunc, "detail_type")(p)
 
 	// Then.
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	want := Provider{
-		config: &oidc.Configuration{
-			EndpointUserInfo: "/userinfo",
-		},
+	if !p.config.AuthDetailsIsEnabled {
+		t.Errorf("auth details must be enabled")
 	}
-	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
-		t.Error(diff)
+
+	if p.config.CompareAuthDetailsFunc == nil {
+		t.Error("CompareAuthDetailsFunc cannot be nil")
+	}
+
+	if p.config.AuthDetailTypes == nil {
+		t.Error("auth detail types should be set")
 	}
+
 }
 
-func TestWithIntrospectionEndpoint(t *testing.T) {
+func TestWithAuthorizationDetailsClaim(t *testing.T) {
 	// Given.
 	p := Provider{
 		config: &oidc.Configuration{},
 	}
 
 	// When.
-	err := WithIntrospectionEndpoint("/introspect")(p)
+	err := WithAuthorizationDetailsClaim(nil)(p)
 
 	// Then.
 	if err != nil {
@@ -259,7 +1936,7 @@ func TestWithIntrospectionEndpoint(t *testing.T) {
 
 	want := Provider{
 		config: &oidc.Configuration{
-			EndpointIntrospection: "/introspect",
+			AuthDetailsClaimIsEnabled: true,
 		},
 	}
 	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
@@ -267,38 +1944,85 @@ func TestWithIntrospectionEndpoint(t *testing.T) {
 	}
 }
 
-func TestWithTokenRevocationEndpoint(t *testing.T) {
+func TestWithMTLS(t *testing.T) {
 	// Given.
 	p := Provider{
 		config: &oidc.Configuration{},
 	}
+	var clientCertFunc goidc.ClientCertFunc = func(
+		r *http.Request,
+	) (*x509.Certificate, error) {
+		return nil, nil
+	}
 
 	// When.
-	err := WithTokenRevocationEndpoint("/revoke")(p)
+	err := WithMTLS("https://matls-example.com", clientCertFunc)(p)
 
 	// Then.
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	want := Provider{
-		config: &oidc.Configuration{
-			EndpointTokenRevocation: "/revoke",
-		},
+	if p.config.MTLSHost != "https://matls-example.com" {
+		t.Errorf("MTLSHost = %s, want https://matls-example.com", p.config.MTLSHost)
 	}
-	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
-		t.Error(diff)
+
+	if p.config.ClientCertFunc == nil {
+		t.Error("ClientCertFunc cannot be nil")
 	}
 }
 
-func TestWithClaims(t *testing.T) {
+func TestWithMTLSTerminatedByProxy(t *testing.T) {
 	// Given.
 	p := Provider{
 		config: &oidc.Configuration{},
 	}
+	clientCertFunc := goidc.XFCCClientCertFunc(0)
 
 	// When.
-	err := WithClaims("claim_one", "claim_two")(p)
+	err := WithMTLSTerminatedByProxy("https://matls-example.com", clientCertFunc)(p)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !p.config.MTLSIsEnabled || !p.config.MTLSIsTerminatedByProxy {
+		t.Error("mutual TLS terminated by a proxy must be enabled")
+	}
+
+	if p.config.MTLSHost != "https://matls-example.com" {
+		t.Errorf("MTLSHost = %s, want https://matls-example.com", p.config.MTLSHost)
+	}
+
+	if p.config.ClientCertFunc == nil {
+		t.Error("ClientCertFunc cannot be nil")
+	}
+}
+
+func TestWithMTLSTerminatedByProxy_NoClientCertFunc(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+
+	// When.
+	err := WithMTLSTerminatedByProxy("https://matls-example.com", nil)(p)
+
+	// Then.
+	if err == nil {
+		t.Fatal("the client certificate function must be required")
+	}
+}
+
+func TestWithMTLSEndpointHost(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+
+	// When.
+	err := WithMTLSEndpointHost(goidc.EndpointUserInfo, goidc.EndpointHostMain)(p)
 
 	// Then.
 	if err != nil {
@@ -307,8 +2031,9 @@ func TestWithClaims(t *testing.T) {
 
 	want := Provider{
 		config: &oidc.Configuration{
-			Claims:     []string{"claim_one", "claim_two"},
-			ClaimTypes: []goidc.ClaimType{goidc.ClaimTypeNormal},
+			MTLSEndpointHosts: map[goidc.Endpoint]goidc.EndpointHost{
+				goidc.EndpointUserInfo: goidc.EndpointHostMain,
+			},
 		},
 	}
 	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
@@ -316,14 +2041,29 @@ func TestWithClaims(t *testing.T) {
 	}
 }
 
-func TestWithClaimTypes(t *testing.T) {
+func TestWithMTLSEndpointHost_InvalidHost(t *testing.T) {
 	// Given.
 	p := Provider{
 		config: &oidc.Configuration{},
 	}
 
 	// When.
-	err := WithClaimTypes(goidc.ClaimTypeDistributed)(p)
+	err := WithMTLSEndpointHost(goidc.EndpointUserInfo, "invalid_host")(p)
+
+	// Then.
+	if err == nil {
+		t.Fatal("the host must be validated")
+	}
+}
+
+func TestWithTLSCertTokenBinding(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+
+	// When.
+	err := WithTLSCertTokenBinding()(p)
 
 	// Then.
 	if err != nil {
@@ -332,7 +2072,7 @@ func TestWithClaimTypes(t *testing.T) {
 
 	want := Provider{
 		config: &oidc.Configuration{
-			ClaimTypes: []goidc.ClaimType{goidc.ClaimTypeDistributed},
+			MTLSTokenBindingIsEnabled: true,
 		},
 	}
 	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
@@ -340,14 +2080,14 @@ func TestWithClaimTypes(t *testing.T) {
 	}
 }
 
-func TestWithUserSignatureAlgs(t *testing.T) {
+func TestWithTLSCertTokenBindingRequired(t *testing.T) {
 	// Given.
 	p := Provider{
 		config: &oidc.Configuration{},
 	}
 
 	// When.
-	err := WithUserSignatureAlgs(jose.RS256)(p)
+	err := WithTLSCertTokenBindingRequired()(p)
 
 	// Then.
 	if err != nil {
@@ -356,8 +2096,8 @@ func TestWithUserSignatureAlgs(t *testing.T) {
 
 	want := Provider{
 		config: &oidc.Configuration{
-			UserDefaultSigAlg: jose.RS256,
-			UserSigAlgs:       []jose.SignatureAlgorithm{jose.RS256},
+			MTLSTokenBindingIsEnabled:  true,
+			MTLSTokenBindingIsRequired: true,
 		},
 	}
 	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
@@ -365,14 +2105,14 @@ func TestWithUserSignatureAlgs(t *testing.T) {
 	}
 }
 
-func TestWithIDTokenLifetime(t *testing.T) {
+func TestWithDPoP(t *testing.T) {
 	// Given.
 	p := Provider{
 		config: &oidc.Configuration{},
 	}
 
 	// When.
-	err := WithIDTokenLifetime(60)(p)
+	err := WithDPoP(jose.PS256)(p)
 
 	// Then.
 	if err != nil {
@@ -381,7 +2121,8 @@ func TestWithIDTokenLifetime(t *testing.T) {
 
 	want := Provider{
 		config: &oidc.Configuration{
-			IDTokenLifetimeSecs: 60,
+			DPoPIsEnabled: true,
+			DPoPSigAlgs:   []jose.SignatureAlgorithm{jose.PS256},
 		},
 	}
 	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
@@ -389,14 +2130,14 @@ func TestWithIDTokenLifetime(t *testing.T) {
 	}
 }
 
-func TestWithUserInfoEncryption(t *testing.T) {
+func TestWithDPoP_NoAlgInformed(t *testing.T) {
 	// Given.
 	p := Provider{
 		config: &oidc.Configuration{},
 	}
 
 	// When.
-	err := WithUserInfoEncryption(jose.RSA_OAEP)(p)
+	err := WithDPoP(jose.RS256)(p)
 
 	// Then.
 	if err != nil {
@@ -405,8 +2146,8 @@ func TestWithUserInfoEncryption(t *testing.T) {
 
 	want := Provider{
 		config: &oidc.Configuration{
-			UserEncIsEnabled: true,
-			UserKeyEncAlgs:   []jose.KeyAlgorithm{jose.RSA_OAEP},
+			DPoPIsEnabled: true,
+			DPoPSigAlgs:   []jose.SignatureAlgorithm{jose.RS256},
 		},
 	}
 	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
@@ -414,14 +2155,14 @@ func TestWithUserInfoEncryption(t *testing.T) {
 	}
 }
 
-func TestWithUserInfoEncryption_NoAlgInformed(t *testing.T) {
+func TestWithDPoPRequired(t *testing.T) {
 	// Given.
 	p := Provider{
 		config: &oidc.Configuration{},
 	}
 
 	// When.
-	err := WithUserInfoEncryption(jose.RSA_OAEP_256)(p)
+	err := WithDPoPRequired(jose.PS256)(p)
 
 	// Then.
 	if err != nil {
@@ -430,8 +2171,9 @@ func TestWithUserInfoEncryption_NoAlgInformed(t *testing.T) {
 
 	want := Provider{
 		config: &oidc.Configuration{
-			UserEncIsEnabled: true,
-			UserKeyEncAlgs:   []jose.KeyAlgorithm{jose.RSA_OAEP_256},
+			DPoPIsEnabled:  true,
+			DPoPIsRequired: true,
+			DPoPSigAlgs:    []jose.SignatureAlgorithm{jose.PS256},
 		},
 	}
 	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
@@ -439,14 +2181,14 @@ func TestWithUserInfoEncryption_NoAlgInformed(t *testing.T) {
 	}
 }
 
-func TestWithUserInfoContentEncryptionAlgs(t *testing.T) {
+func TestWithTokenBindingRequired(t *testing.T) {
 	// Given.
 	p := Provider{
 		config: &oidc.Configuration{},
 	}
 
 	// When.
-	err := WithUserInfoContentEncryptionAlgs(jose.A128GCM)(p)
+	err := WithTokenBindingRequired()(p)
 
 	// Then.
 	if err != nil {
@@ -455,8 +2197,7 @@ func TestWithUserInfoContentEncryptionAlgs(t *testing.T) {
 
 	want := Provider{
 		config: &oidc.Configuration{
-			UserDefaultContentEncAlg: jose.A128GCM,
-			UserContentEncAlgs:       []jose.ContentEncryption{jose.A128GCM},
+			TokenBindingIsRequired: true,
 		},
 	}
 	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
@@ -464,53 +2205,42 @@ func TestWithUserInfoContentEncryptionAlgs(t *testing.T) {
 	}
 }
 
-func TestWithDCR(t *testing.T) {
+func TestWithIntrospection(t *testing.T) {
 	// Given.
 	p := Provider{
 		config: &oidc.Configuration{},
 	}
-	var handleDCRFunc goidc.HandleDynamicClientFunc = func(
-		r *http.Request,
-		c *goidc.ClientMetaInfo,
-	) error {
-		return nil
-	}
-	var validateInitialTokenFunc goidc.ValidateInitialAccessTokenFunc = func(
-		r *http.Request,
-		s string,
-	) error {
-		return nil
-	}
 
 	// When.
-	err := WithDCR(handleDCRFunc, validateInitialTokenFunc)(p)
+	err := WithTokenIntrospection(
+		nil,
+		goidc.ClientAuthnSecretPost,
+	)(p)
 
 	// Then.
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	if !p.config.DCRIsEnabled {
-		t.Error("DCRIsEnabled cannot be false")
-	}
-
-	if p.config.HandleDynamicClientFunc == nil {
-		t.Error("HandleDynamicClientFunc cannot be nil")
+	want := Provider{
+		config: &oidc.Configuration{
+			TokenIntrospectionIsEnabled:    true,
+			TokenIntrospectionAuthnMethods: []goidc.ClientAuthnType{goidc.ClientAuthnSecretPost},
+		},
 	}
-
-	if p.config.ValidateInitialAccessTokenFunc == nil {
-		t.Error("ValidateInitialAccessTokenFunc cannot be nil")
+	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
+		t.Error(diff)
 	}
 }
 
-func TestWithDCRTokenRotation(t *testing.T) {
+func TestWithClientJWKSCache(t *testing.T) {
 	// Given.
 	p := Provider{
 		config: &oidc.Configuration{},
 	}
 
 	// When.
-	err := WithDCRTokenRotation()(p)
+	err := WithClientJWKSCache(60)(p)
 
 	// Then.
 	if err != nil {
@@ -519,7 +2249,7 @@ func TestWithDCRTokenRotation(t *testing.T) {
 
 	want := Provider{
 		config: &oidc.Configuration{
-			DCRTokenRotationIsEnabled: true,
+			ClientJWKSCacheTTLSecs: 60,
 		},
 	}
 	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
@@ -527,14 +2257,29 @@ func TestWithDCRTokenRotation(t *testing.T) {
 	}
 }
 
-func TestWithClientCredentialsGrant(t *testing.T) {
+func TestWithClientJWKSCache_InvalidTTL(t *testing.T) {
 	// Given.
 	p := Provider{
 		config: &oidc.Configuration{},
 	}
 
 	// When.
-	err := WithClientCredentialsGrant()(p)
+	err := WithClientJWKSCache(-1)(p)
+
+	// Then.
+	if err == nil {
+		t.Fatal("a non positive ttl must be rejected")
+	}
+}
+
+func TestWithIntrospectionCache(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+
+	// When.
+	err := WithIntrospectionCache(5)(p)
 
 	// Then.
 	if err != nil {
@@ -543,79 +2288,57 @@ func TestWithClientCredentialsGrant(t *testing.T) {
 
 	want := Provider{
 		config: &oidc.Configuration{
-			GrantTypes: []goidc.GrantType{goidc.GrantClientCredentials},
+			IntrospectionCacheTTLSecs: 5,
 		},
 	}
-	if diff := cmp.Diff(
-		p,
-		want,
-		cmp.AllowUnexported(Provider{}),
-	); diff != "" {
+	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
 		t.Error(diff)
 	}
 }
 
-func TestWithRefreshTokenGrant(t *testing.T) {
+func TestWithIntrospectionCache_InvalidTTL(t *testing.T) {
 	// Given.
-	p := Provider{
-		config: &oidc.Configuration{},
-	}
-	var shouldIssueRefreshTokenFunc goidc.ShouldIssueRefreshTokenFunc = func(
-		c *goidc.Client,
-		gi goidc.GrantInfo,
-	) bool {
-		return false
+	p := Provider{
+		config: &oidc.Configuration{},
 	}
 
 	// When.
-	err := WithRefreshTokenGrant(shouldIssueRefreshTokenFunc, 300)(p)
+	err := WithIntrospectionCache(0)(p)
 
 	// Then.
-	if err != nil {
-		t.Fatalf("unexpected error: %v", err)
-	}
-
-	if !slices.Contains(p.config.GrantTypes, goidc.GrantRefreshToken) {
-		t.Error("refresh token grant is missing")
-	}
-
-	if p.config.ShouldIssueRefreshTokenFunc == nil {
-		t.Error("ValidateInitialAccessTokenFunc cannot be nil")
+	if err == nil {
+		t.Fatal("a non positive ttl must be rejected")
 	}
 }
 
-func TestWithRefreshTokenRotation(t *testing.T) {
+func TestWithIntrospectionCacheStorage(t *testing.T) {
 	// Given.
 	p := Provider{
 		config: &oidc.Configuration{},
 	}
+	cache := storage.NewIntrospectionCache(time.Minute)
 
 	// When.
-	err := WithRefreshTokenRotation()(p)
+	err := WithIntrospectionCacheStorage(cache)(p)
 
 	// Then.
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	want := Provider{
-		config: &oidc.Configuration{
-			RefreshTokenRotationIsEnabled: true,
-		},
-	}
-	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
-		t.Error(diff)
+	if p.config.IntrospectionCache != cache {
+		t.Error("the introspection cache must be set")
 	}
 }
 
-func TestWithOpenIDScopeRequired(t *testing.T) {
+func TestWithTokenIntrospectionBearerScope(t *testing.T) {
 	// Given.
 	p := Provider{
 		config: &oidc.Configuration{},
 	}
 
 	// When.
-	err := WithOpenIDScopeRequired()(p)
+	err := WithTokenIntrospectionBearerScope("introspect")(p)
 
 	// Then.
 	if err != nil {
@@ -624,7 +2347,7 @@ func TestWithOpenIDScopeRequired(t *testing.T) {
 
 	want := Provider{
 		config: &oidc.Configuration{
-			OpenIDIsRequired: true,
+			TokenIntrospectionBearerScope: "introspect",
 		},
 	}
 	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
@@ -632,60 +2355,64 @@ func TestWithOpenIDScopeRequired(t *testing.T) {
 	}
 }
 
-func TestWithTokenOptions(t *testing.T) {
+func TestWithFilterIntrospectionFunc(t *testing.T) {
 	// Given.
 	p := Provider{
 		config: &oidc.Configuration{},
 	}
-	var tokenOpts goidc.TokenOptionsFunc = func(
-		grantInfo goidc.GrantInfo,
-	) goidc.TokenOptions {
-		return goidc.NewOpaqueTokenOptions(10, 60)
+	var filterFunc goidc.FilterIntrospectionFunc = func(
+		r *http.Request,
+		caller goidc.IntrospectionCaller,
+		info *goidc.TokenInfo,
+	) {
 	}
 
 	// When.
-	err := WithTokenOptions(tokenOpts)(p)
+	err := WithFilterIntrospectionFunc(filterFunc)(p)
 
 	// Then.
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	if p.config.TokenOptionsFunc == nil {
-		t.Error("TokenOptionsFunc cannot be nil")
+	if p.config.FilterIntrospectionFunc == nil {
+		t.Error("FilterIntrospectionFunc cannot be nil")
 	}
 }
 
-func TestWithHandleGrantFunc(t *testing.T) {
+func TestWithTokenIntrospectionInactiveReason(t *testing.T) {
 	// Given.
 	p := Provider{
 		config: &oidc.Configuration{},
 	}
-	var grantHandler goidc.HandleGrantFunc = func(r *http.Request, gi *goidc.GrantInfo) error {
-		return nil
+	var trustedFunc goidc.IsIntrospectionCallerTrustedFunc = func(
+		r *http.Request,
+		caller goidc.IntrospectionCaller,
+	) bool {
+		return true
 	}
 
 	// When.
-	err := WithHandleGrantFunc(grantHandler)(p)
+	err := WithTokenIntrospectionInactiveReason(trustedFunc)(p)
 
 	// Then.
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	if p.config.HandleGrantFunc == nil {
-		t.Error("HandleGrantFunc cannot be nil")
+	if p.config.IsIntrospectionCallerTrustedFunc == nil {
+		t.Error("IsIntrospectionCallerTrustedFunc cannot be nil")
 	}
 }
 
-func TestWithImplicitGrant(t *testing.T) {
+func TestWithTokenRevocation(t *testing.T) {
 	// Given.
 	p := Provider{
 		config: &oidc.Configuration{},
 	}
 
 	// When.
-	err := WithImplicitGrant()(p)
+	err := WithTokenRevocation(nil, goidc.ClientAuthnNone)(p)
 
 	// Then.
 	if err != nil {
@@ -694,7 +2421,8 @@ func TestWithImplicitGrant(t *testing.T) {
 
 	want := Provider{
 		config: &oidc.Configuration{
-			GrantTypes: []goidc.GrantType{goidc.GrantImplicit},
+			TokenRevocationIsEnabled:    true,
+			TokenRevocationAuthnMethods: []goidc.ClientAuthnType{goidc.ClientAuthnNone},
 		},
 	}
 	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
@@ -702,33 +2430,40 @@ func TestWithImplicitGrant(t *testing.T) {
 	}
 }
 
-func TestWithScopes(t *testing.T) {
+func TestWithPKCE(t *testing.T) {
 	// Given.
 	p := Provider{
 		config: &oidc.Configuration{},
 	}
 
-	// When
-	err := WithScopes(goidc.ScopeEmail)(p)
+	// When.
+	err := WithPKCE(goidc.CodeChallengeMethodPlain)(p)
 
 	// Then.
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	if len(p.config.Scopes) != 2 {
-		t.Error("there should be only two scopes")
+	want := Provider{
+		config: &oidc.Configuration{
+			PKCEIsEnabled:              true,
+			PKCEDefaultChallengeMethod: goidc.CodeChallengeMethodPlain,
+			PKCEChallengeMethods:       []goidc.CodeChallengeMethod{goidc.CodeChallengeMethodPlain},
+		},
+	}
+	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
+		t.Error(diff)
 	}
 }
 
-func TestWithPAR(t *testing.T) {
+func TestWithPKCE_NoMethodInformed(t *testing.T) {
 	// Given.
 	p := Provider{
 		config: &oidc.Configuration{},
 	}
 
 	// When.
-	err := WithPAR(60)(p)
+	err := WithPKCE(goidc.CodeChallengeMethodSHA256)(p)
 
 	// Then.
 	if err != nil {
@@ -737,8 +2472,9 @@ func TestWithPAR(t *testing.T) {
 
 	want := Provider{
 		config: &oidc.Configuration{
-			PARIsEnabled:    true,
-			PARLifetimeSecs: 60,
+			PKCEIsEnabled:              true,
+			PKCEDefaultChallengeMethod: goidc.CodeChallengeMethodSHA256,
+			PKCEChallengeMethods:       []goidc.CodeChallengeMethod{goidc.CodeChallengeMethodSHA256},
 		},
 	}
 	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
@@ -746,14 +2482,14 @@ func TestWithPAR(t *testing.T) {
 	}
 }
 
-func TestWithPARRequired(t *testing.T) {
+func TestWithPKCERequired(t *testing.T) {
 	// Given.
 	p := Provider{
 		config: &oidc.Configuration{},
 	}
 
 	// When.
-	err := WithPARRequired(60)(p)
+	err := WithPKCERequired(goidc.CodeChallengeMethodPlain)(p)
 
 	// Then.
 	if err != nil {
@@ -762,9 +2498,10 @@ func TestWithPARRequired(t *testing.T) {
 
 	want := Provider{
 		config: &oidc.Configuration{
-			PARIsEnabled:    true,
-			PARIsRequired:   true,
-			PARLifetimeSecs: 60,
+			PKCEIsEnabled:              true,
+			PKCEIsRequired:             true,
+			PKCEDefaultChallengeMethod: goidc.CodeChallengeMethodPlain,
+			PKCEChallengeMethods:       []goidc.CodeChallengeMethod{goidc.CodeChallengeMethodPlain},
 		},
 	}
 	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
@@ -772,14 +2509,14 @@ func TestWithPARRequired(t *testing.T) {
 	}
 }
 
-func TestWithUnregisteredRedirectURIsForPAR(t *testing.T) {
+func TestWithACRs(t *testing.T) {
 	// Given.
 	p := Provider{
 		config: &oidc.Configuration{},
 	}
 
 	// When.
-	err := WithUnregisteredRedirectURIsForPAR()(p)
+	err := WithACRs("0")(p)
 
 	// Then.
 	if err != nil {
@@ -788,7 +2525,7 @@ func TestWithUnregisteredRedirectURIsForPAR(t *testing.T) {
 
 	want := Provider{
 		config: &oidc.Configuration{
-			PARAllowUnregisteredRedirectURI: true,
+			ACRs: []goidc.ACR{"0"},
 		},
 	}
 	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
@@ -796,14 +2533,14 @@ func TestWithUnregisteredRedirectURIsForPAR(t *testing.T) {
 	}
 }
 
-func TestWithJAR(t *testing.T) {
+func TestWithDisplayValues(t *testing.T) {
 	// Given.
 	p := Provider{
 		config: &oidc.Configuration{},
 	}
 
 	// When.
-	err := WithJAR(jose.PS256)(p)
+	err := WithDisplayValues(goidc.DisplayValuePage)(p)
 
 	// Then.
 	if err != nil {
@@ -812,8 +2549,7 @@ func TestWithJAR(t *testing.T) {
 
 	want := Provider{
 		config: &oidc.Configuration{
-			JARIsEnabled: true,
-			JARSigAlgs:   []jose.SignatureAlgorithm{jose.PS256},
+			DisplayValues: []goidc.DisplayValue{goidc.DisplayValuePage},
 		},
 	}
 	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
@@ -821,14 +2557,14 @@ func TestWithJAR(t *testing.T) {
 	}
 }
 
-func TestWithJAR_NoAlgInformed(t *testing.T) {
+func TestWithAuthenticationSessionTimeout(t *testing.T) {
 	// Given.
 	p := Provider{
 		config: &oidc.Configuration{},
 	}
 
 	// When.
-	err := WithJAR(jose.RS256)(p)
+	err := WithAuthenticationSessionTimeout(10)(p)
 
 	// Then.
 	if err != nil {
@@ -837,8 +2573,7 @@ func TestWithJAR_NoAlgInformed(t *testing.T) {
 
 	want := Provider{
 		config: &oidc.Configuration{
-			JARIsEnabled: true,
-			JARSigAlgs:   []jose.SignatureAlgorithm{jose.RS256},
+			AuthnSessionTimeoutSecs: 10,
 		},
 	}
 	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
@@ -846,14 +2581,14 @@ func TestWithJAR_NoAlgInformed(t *testing.T) {
 	}
 }
 
-func TestWithJARRequired(t *testing.T) {
+func TestWithAuthenticationSessionMaxLifetime(t *testing.T) {
 	// Given.
 	p := Provider{
 		config: &oidc.Configuration{},
 	}
 
 	// When.
-	err := WithJARRequired(jose.PS256)(p)
+	err := WithAuthenticationSessionMaxLifetime(3600)(p)
 
 	// Then.
 	if err != nil {
@@ -862,24 +2597,30 @@ func TestWithJARRequired(t *testing.T) {
 
 	want := Provider{
 		config: &oidc.Configuration{
-			JARIsEnabled:  true,
-			JARIsRequired: true,
-			JARSigAlgs:    []jose.SignatureAlgorithm{jose.PS256},
+			AuthnSessionMaxLifetimeSecs: 3600,
 		},
 	}
 	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
 		t.Error(diff)
 	}
+
+	// When.
+	err = WithAuthenticationSessionMaxLifetime(0)(p)
+
+	// Then.
+	if err == nil {
+		t.Error("the max lifetime must be positive")
+	}
 }
 
-func TestWithJAREncryption(t *testing.T) {
+func TestWithAuthorizationCodeLifetime(t *testing.T) {
 	// Given.
 	p := Provider{
 		config: &oidc.Configuration{},
 	}
 
 	// When.
-	err := WithJAREncryption(jose.RSA_OAEP_256)(p)
+	err := WithAuthorizationCodeLifetime(30)(p)
 
 	// Then.
 	if err != nil {
@@ -888,8 +2629,7 @@ func TestWithJAREncryption(t *testing.T) {
 
 	want := Provider{
 		config: &oidc.Configuration{
-			JAREncIsEnabled: true,
-			JARKeyEncAlgs:   []jose.KeyAlgorithm{jose.RSA_OAEP_256},
+			AuthzCodeLifetimeSecs: 30,
 		},
 	}
 	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
@@ -897,14 +2637,29 @@ func TestWithJAREncryption(t *testing.T) {
 	}
 }
 
-func TestWithJARContentEncryptionAlgs(t *testing.T) {
+func TestWithAuthorizationCodeLifetime_InvalidLifetime(t *testing.T) {
 	// Given.
 	p := Provider{
 		config: &oidc.Configuration{},
 	}
 
 	// When.
-	err := WithJARContentEncryptionAlgs(jose.A128GCM)(p)
+	err := WithAuthorizationCodeLifetime(0)(p)
+
+	// Then.
+	if err == nil {
+		t.Fatal("a non positive lifetime must be rejected")
+	}
+}
+
+func TestWithAuthorizationCodeLength(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+
+	// When.
+	err := WithAuthorizationCodeLength(43)(p)
 
 	// Then.
 	if err != nil {
@@ -913,7 +2668,7 @@ func TestWithJARContentEncryptionAlgs(t *testing.T) {
 
 	want := Provider{
 		config: &oidc.Configuration{
-			JARContentEncAlgs: []jose.ContentEncryption{jose.A128GCM},
+			AuthzCodeLength: 43,
 		},
 	}
 	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
@@ -921,14 +2676,29 @@ func TestWithJARContentEncryptionAlgs(t *testing.T) {
 	}
 }
 
-func TestWithJARM(t *testing.T) {
+func TestWithAuthorizationCodeLength_TooShort(t *testing.T) {
 	// Given.
 	p := Provider{
 		config: &oidc.Configuration{},
 	}
 
 	// When.
-	err := WithJARM(jose.RS256)(p)
+	err := WithAuthorizationCodeLength(10)(p)
+
+	// Then.
+	if err == nil {
+		t.Fatal("codes with less than 128 bits of entropy must be rejected")
+	}
+}
+
+func TestWithCallbackBinding(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+
+	// When.
+	err := WithCallbackBinding()(p)
 
 	// Then.
 	if err != nil {
@@ -937,9 +2707,7 @@ func TestWithJARM(t *testing.T) {
 
 	want := Provider{
 		config: &oidc.Configuration{
-			JARMIsEnabled:     true,
-			JARMDefaultSigAlg: jose.RS256,
-			JARMSigAlgs:       []jose.SignatureAlgorithm{jose.RS256},
+			CallbackBindingIsEnabled: true,
 		},
 	}
 	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
@@ -947,14 +2715,15 @@ func TestWithJARM(t *testing.T) {
 	}
 }
 
-func TestWithJARMEncryption(t *testing.T) {
+func TestWithStaticClient(t *testing.T) {
 	// Given.
 	p := Provider{
 		config: &oidc.Configuration{},
 	}
+	c, _ := oidctest.NewClient(t)
 
 	// When.
-	err := WithJARMEncryption(jose.RSA_OAEP)(p)
+	err := WithStaticClient(c)(p)
 
 	// Then.
 	if err != nil {
@@ -963,8 +2732,7 @@ func TestWithJARMEncryption(t *testing.T) {
 
 	want := Provider{
 		config: &oidc.Configuration{
-			JARMEncIsEnabled: true,
-			JARMKeyEncAlgs:   []jose.KeyAlgorithm{jose.RSA_OAEP},
+			StaticClients: []*goidc.Client{c},
 		},
 	}
 	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
@@ -972,14 +2740,17 @@ func TestWithJARMEncryption(t *testing.T) {
 	}
 }
 
-func TestWithJARMEncryption_NoAlgInformed(t *testing.T) {
+func TestWithPolicy(t *testing.T) {
 	// Given.
 	p := Provider{
 		config: &oidc.Configuration{},
 	}
+	policy := goidc.AuthnPolicy{
+		ID: "policy_id",
+	}
 
 	// When.
-	err := WithJARMEncryption(jose.RSA_OAEP_256)(p)
+	err := WithPolicy(policy)(p)
 
 	// Then.
 	if err != nil {
@@ -988,8 +2759,7 @@ func TestWithJARMEncryption_NoAlgInformed(t *testing.T) {
 
 	want := Provider{
 		config: &oidc.Configuration{
-			JARMEncIsEnabled: true,
-			JARMKeyEncAlgs:   []jose.KeyAlgorithm{jose.RSA_OAEP_256},
+			Policies: []goidc.AuthnPolicy{policy},
 		},
 	}
 	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
@@ -997,63 +2767,96 @@ func TestWithJARMEncryption_NoAlgInformed(t *testing.T) {
 	}
 }
 
-func TestWithJARMContentEncryptionAlgs(t *testing.T) {
+func TestWithRenderErrorFunc(t *testing.T) {
 	// Given.
 	p := Provider{
 		config: &oidc.Configuration{},
 	}
+	var renderFunc goidc.RenderErrorFunc = func(
+		w http.ResponseWriter,
+		r *http.Request,
+		err error,
+	) error {
+		return nil
+	}
 
 	// When.
-	err := WithJARMContentEncryptionAlgs(jose.A128GCM)(p)
+	err := WithRenderErrorFunc(renderFunc)(p)
 
 	// Then.
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	want := Provider{
-		config: &oidc.Configuration{
-			JARMDefaultContentEncAlg: jose.A128GCM,
-			JARMContentEncAlgs:       []jose.ContentEncryption{jose.A128GCM},
-		},
+	if p.config.RenderErrorFunc == nil {
+		t.Error("RenderErrorFunc cannot be nil")
 	}
-	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
-		t.Error(diff)
+}
+
+func TestWithNotifyErrorFunc(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+	var handleErrorFunc goidc.NotifyErrorFunc = func(
+		r *http.Request,
+		err error,
+	) {
+	}
+
+	// When.
+	err := WithNotifyErrorFunc(handleErrorFunc)(p)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if p.config.NotifyErrorFunc == nil {
+		t.Error("HandleErrorFunc cannot be nil")
 	}
 }
 
-func TestWithAssertionLifetime(t *testing.T) {
+func TestWithClaimsSource(t *testing.T) {
 	// Given.
 	p := Provider{
 		config: &oidc.Configuration{},
 	}
+	var sourceFunc goidc.ClaimsSourceFunc = func(
+		ctx context.Context,
+		grantInfo goidc.GrantInfo,
+	) (
+		map[string]any,
+		error,
+	) {
+		return nil, nil
+	}
 
 	// When.
-	err := WithAssertionLifetime(60)(p)
+	err := WithClaimsSource(sourceFunc, map[string]int{"email": 60})(p)
 
 	// Then.
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	want := Provider{
-		config: &oidc.Configuration{
-			AssertionLifetimeSecs: 60,
-		},
+	if p.config.ClaimsSourceFunc == nil {
+		t.Error("ClaimsSourceFunc cannot be nil")
 	}
-	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
-		t.Error(diff)
+
+	if p.config.ClaimsCache == nil || p.config.ClaimsCache.LifetimeSecs["email"] != 60 {
+		t.Error("the claims cache was not configured")
 	}
 }
 
-func TestWithIssuerResponseParameter(t *testing.T) {
+func TestWithClaimMappings(t *testing.T) {
 	// Given.
 	p := Provider{
 		config: &oidc.Configuration{},
 	}
 
 	// When.
-	err := WithIssuerResponseParameter()(p)
+	err := WithClaimMappings(nil)(p)
 
 	// Then.
 	if err != nil {
@@ -1062,7 +2865,7 @@ func TestWithIssuerResponseParameter(t *testing.T) {
 
 	want := Provider{
 		config: &oidc.Configuration{
-			IssuerRespParamIsEnabled: true,
+			ClaimMappings: goidc.StandardClaimMappings,
 		},
 	}
 	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
@@ -1070,124 +2873,129 @@ func TestWithIssuerResponseParameter(t *testing.T) {
 	}
 }
 
-func TestWithAuthorizationDetails(t *testing.T) {
+func TestWithFilterClaimsFunc(t *testing.T) {
 	// Given.
 	p := Provider{
 		config: &oidc.Configuration{},
 	}
-	var compareDetailsFunc goidc.CompareAuthDetailsFunc = func(
-		granted, requested []goidc.AuthorizationDetail,
-	) error {
-		return nil
+	var filterFunc goidc.FilterClaimsFunc = func(
+		ctx context.Context,
+		client *goidc.Client,
+		claims map[string]any,
+	) map[string]any {
+		return claims
 	}
 
 	// When.
-	err := WithAuthorizationDetails(compareDetailsFunc, "detail_type")(p)
+	err := WithFilterClaimsFunc(filterFunc)(p)
 
 	// Then.
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	if !p.config.AuthDetailsIsEnabled {
-		t.Errorf("auth details must be enabled")
+	if p.config.FilterClaimsFunc == nil {
+		t.Error("FilterClaimsFunc cannot be nil")
 	}
+}
 
-	if p.config.CompareAuthDetailsFunc == nil {
-		t.Error("CompareAuthDetailsFunc cannot be nil")
+func TestWithWebFinger(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
 	}
 
-	if p.config.AuthDetailTypes == nil {
-		t.Error("auth detail types should be set")
+	// When.
+	err := WithWebFinger(nil)(p)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := Provider{
+		config: &oidc.Configuration{
+			WebFingerIsEnabled: true,
+		},
+	}
+	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
+		t.Error(diff)
 	}
-
 }
 
-func TestWithMTLS(t *testing.T) {
+func TestWithNotifyTokenEventFunc(t *testing.T) {
 	// Given.
 	p := Provider{
 		config: &oidc.Configuration{},
 	}
-	var clientCertFunc goidc.ClientCertFunc = func(
+	var notifyFunc goidc.NotifyTokenEventFunc = func(
 		r *http.Request,
-	) (*x509.Certificate, error) {
-		return nil, nil
+		event goidc.TokenEvent,
+	) {
 	}
 
 	// When.
-	err := WithMTLS("https://matls-example.com", clientCertFunc)(p)
+	err := WithNotifyTokenEventFunc(notifyFunc)(p)
 
 	// Then.
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	if p.config.MTLSHost != "https://matls-example.com" {
-		t.Errorf("MTLSHost = %s, want https://matls-example.com", p.config.MTLSHost)
-	}
-
-	if p.config.ClientCertFunc == nil {
-		t.Error("ClientCertFunc cannot be nil")
+	if p.config.NotifyTokenEventFunc == nil {
+		t.Error("NotifyTokenEventFunc cannot be nil")
 	}
 }
 
-func TestWithTLSCertTokenBinding(t *testing.T) {
+func TestWithCheckJTIFunc(t *testing.T) {
 	// Given.
 	p := Provider{
 		config: &oidc.Configuration{},
 	}
+	var checkJTIFunc goidc.CheckJTIFunc = func(ctx context.Context, s string) error {
+		return nil
+	}
 
 	// When.
-	err := WithTLSCertTokenBinding()(p)
+	err := WithCheckJTIFunc(checkJTIFunc)(p)
 
 	// Then.
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	want := Provider{
-		config: &oidc.Configuration{
-			MTLSTokenBindingIsEnabled: true,
-		},
-	}
-	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
-		t.Error(diff)
+	if p.config.CheckJTIFunc == nil {
+		t.Error("CheckJTIFunc cannot be nil")
 	}
 }
 
-func TestWithTLSCertTokenBindingRequired(t *testing.T) {
+func TestWithNonceReplayPrevention(t *testing.T) {
 	// Given.
 	p := Provider{
 		config: &oidc.Configuration{},
 	}
 
 	// When.
-	err := WithTLSCertTokenBindingRequired()(p)
+	err := WithNonceReplayPrevention(goidc.NewNonceCache().CheckNonce)(p)
 
 	// Then.
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	want := Provider{
-		config: &oidc.Configuration{
-			MTLSTokenBindingIsEnabled:  true,
-			MTLSTokenBindingIsRequired: true,
-		},
-	}
-	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
-		t.Error(diff)
+	if p.config.CheckNonceFunc == nil {
+		t.Error("CheckNonceFunc cannot be nil")
 	}
 }
 
-func TestWithDPoP(t *testing.T) {
+func TestWithResourceIndicators(t *testing.T) {
 	// Given.
 	p := Provider{
 		config: &oidc.Configuration{},
 	}
 
 	// When.
-	err := WithDPoP(jose.PS256)(p)
+	err := WithResourceIndicators("https://resource.com")(p)
 
 	// Then.
 	if err != nil {
@@ -1196,8 +3004,8 @@ func TestWithDPoP(t *testing.T) {
 
 	want := Provider{
 		config: &oidc.Configuration{
-			DPoPIsEnabled: true,
-			DPoPSigAlgs:   []jose.SignatureAlgorithm{jose.PS256},
+			ResourceIndicatorsIsEnabled: true,
+			Resources:                   []string{"https://resource.com"},
 		},
 	}
 	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
@@ -1205,14 +3013,14 @@ func TestWithDPoP(t *testing.T) {
 	}
 }
 
-func TestWithDPoP_NoAlgInformed(t *testing.T) {
+func TestWithResourceIndicatorsRequired(t *testing.T) {
 	// Given.
 	p := Provider{
 		config: &oidc.Configuration{},
 	}
 
 	// When.
-	err := WithDPoP(jose.RS256)(p)
+	err := WithResourceIndicatorsRequired("https://resource.com")(p)
 
 	// Then.
 	if err != nil {
@@ -1221,8 +3029,9 @@ func TestWithDPoP_NoAlgInformed(t *testing.T) {
 
 	want := Provider{
 		config: &oidc.Configuration{
-			DPoPIsEnabled: true,
-			DPoPSigAlgs:   []jose.SignatureAlgorithm{jose.RS256},
+			ResourceIndicatorsIsEnabled:  true,
+			ResourceIndicatorsIsRequired: true,
+			Resources:                    []string{"https://resource.com"},
 		},
 	}
 	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
@@ -1230,92 +3039,78 @@ func TestWithDPoP_NoAlgInformed(t *testing.T) {
 	}
 }
 
-func TestWithDPoPRequired(t *testing.T) {
+func TestWithHTTPClientFunc(t *testing.T) {
 	// Given.
 	p := Provider{
 		config: &oidc.Configuration{},
 	}
 
 	// When.
-	err := WithDPoPRequired(jose.PS256)(p)
+	err := WithHTTPClientFunc(func(ctx context.Context) *http.Client {
+		return http.DefaultClient
+	})(p)
 
 	// Then.
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	want := Provider{
-		config: &oidc.Configuration{
-			DPoPIsEnabled:  true,
-			DPoPIsRequired: true,
-			DPoPSigAlgs:    []jose.SignatureAlgorithm{jose.PS256},
-		},
-	}
-	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
-		t.Error(diff)
+	if p.config.HTTPClientFunc == nil {
+		t.Error("HTTPClientFunc cannot be nil")
 	}
 }
 
-func TestWithTokenBindingRequired(t *testing.T) {
+func TestWithIDFunc(t *testing.T) {
 	// Given.
 	p := Provider{
 		config: &oidc.Configuration{},
 	}
+	var idFunc goidc.IDFunc = func(_ context.Context, _ goidc.IDType) string {
+		return "random_id"
+	}
 
 	// When.
-	err := WithTokenBindingRequired()(p)
+	err := WithIDFunc(idFunc)(p)
 
 	// Then.
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	want := Provider{
-		config: &oidc.Configuration{
-			TokenBindingIsRequired: true,
-		},
-	}
-	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
-		t.Error(diff)
+	if p.config.IDFunc == nil {
+		t.Error("IDFunc cannot be nil")
 	}
 }
 
-func TestWithIntrospection(t *testing.T) {
+func TestJWTBearerGrant(t *testing.T) {
 	// Given.
 	p := Provider{
 		config: &oidc.Configuration{},
 	}
 
 	// When.
-	err := WithTokenIntrospection(
-		nil,
-		goidc.ClientAuthnSecretPost,
-	)(p)
+	err := WithJWTBearerGrant(func(r *http.Request, assertion string) (goidc.JWTBearerGrantInfo, error) {
+		return goidc.JWTBearerGrantInfo{}, nil
+	})(p)
 
 	// Then.
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	want := Provider{
-		config: &oidc.Configuration{
-			TokenIntrospectionIsEnabled:    true,
-			TokenIntrospectionAuthnMethods: []goidc.ClientAuthnType{goidc.ClientAuthnSecretPost},
-		},
-	}
-	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
-		t.Error(diff)
+	if p.config.HandleJWTBearerGrantAssertionFunc == nil {
+		t.Error("HandleJWTBearerGrantAssertionFunc cannot be nil")
 	}
 }
 
-func TestWithTokenRevocation(t *testing.T) {
+func TestWithDiscoveryCacheMaxAge(t *testing.T) {
 	// Given.
 	p := Provider{
 		config: &oidc.Configuration{},
 	}
 
 	// When.
-	err := WithTokenRevocation(nil, goidc.ClientAuthnNone)(p)
+	err := WithDiscoveryCacheMaxAge(300)(p)
 
 	// Then.
 	if err != nil {
@@ -1324,8 +3119,7 @@ func TestWithTokenRevocation(t *testing.T) {
 
 	want := Provider{
 		config: &oidc.Configuration{
-			TokenRevocationIsEnabled:    true,
-			TokenRevocationAuthnMethods: []goidc.ClientAuthnType{goidc.ClientAuthnNone},
+			DiscoveryCacheMaxAgeSecs: 300,
 		},
 	}
 	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
@@ -1333,14 +3127,14 @@ func TestWithTokenRevocation(t *testing.T) {
 	}
 }
 
-func TestWithPKCE(t *testing.T) {
+func TestWithDiscoveryCompression(t *testing.T) {
 	// Given.
 	p := Provider{
 		config: &oidc.Configuration{},
 	}
 
 	// When.
-	err := WithPKCE(goidc.CodeChallengeMethodPlain)(p)
+	err := WithDiscoveryCompression()(p)
 
 	// Then.
 	if err != nil {
@@ -1349,9 +3143,7 @@ func TestWithPKCE(t *testing.T) {
 
 	want := Provider{
 		config: &oidc.Configuration{
-			PKCEIsEnabled:              true,
-			PKCEDefaultChallengeMethod: goidc.CodeChallengeMethodPlain,
-			PKCEChallengeMethods:       []goidc.CodeChallengeMethod{goidc.CodeChallengeMethodPlain},
+			DiscoveryCompressionIsEnabled: true,
 		},
 	}
 	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
@@ -1359,14 +3151,14 @@ func TestWithPKCE(t *testing.T) {
 	}
 }
 
-func TestWithPKCE_NoMethodInformed(t *testing.T) {
+func TestWithPKCEPlainDisallowed(t *testing.T) {
 	// Given.
 	p := Provider{
 		config: &oidc.Configuration{},
 	}
 
 	// When.
-	err := WithPKCE(goidc.CodeChallengeMethodSHA256)(p)
+	err := WithPKCEPlainDisallowed()(p)
 
 	// Then.
 	if err != nil {
@@ -1375,9 +3167,7 @@ func TestWithPKCE_NoMethodInformed(t *testing.T) {
 
 	want := Provider{
 		config: &oidc.Configuration{
-			PKCEIsEnabled:              true,
-			PKCEDefaultChallengeMethod: goidc.CodeChallengeMethodSHA256,
-			PKCEChallengeMethods:       []goidc.CodeChallengeMethod{goidc.CodeChallengeMethodSHA256},
+			PKCEPlainIsDisallowed: true,
 		},
 	}
 	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
@@ -1385,14 +3175,14 @@ func TestWithPKCE_NoMethodInformed(t *testing.T) {
 	}
 }
 
-func TestWithPKCERequired(t *testing.T) {
+func TestWithPKCEMinVerifierEntropy(t *testing.T) {
 	// Given.
 	p := Provider{
 		config: &oidc.Configuration{},
 	}
 
 	// When.
-	err := WithPKCERequired(goidc.CodeChallengeMethodPlain)(p)
+	err := WithPKCEMinVerifierEntropy(128)(p)
 
 	// Then.
 	if err != nil {
@@ -1401,10 +3191,7 @@ func TestWithPKCERequired(t *testing.T) {
 
 	want := Provider{
 		config: &oidc.Configuration{
-			PKCEIsEnabled:              true,
-			PKCEIsRequired:             true,
-			PKCEDefaultChallengeMethod: goidc.CodeChallengeMethodPlain,
-			PKCEChallengeMethods:       []goidc.CodeChallengeMethod{goidc.CodeChallengeMethodPlain},
+			PKCEMinVerifierEntropyBits: 128,
 		},
 	}
 	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
@@ -1412,14 +3199,14 @@ func TestWithPKCERequired(t *testing.T) {
 	}
 }
 
-func TestWithACRs(t *testing.T) {
+func TestWithJWTLeeway(t *testing.T) {
 	// Given.
 	p := Provider{
 		config: &oidc.Configuration{},
 	}
 
 	// When.
-	err := WithACRs("0")(p)
+	err := WithJWTLeeway(10)(p)
 
 	// Then.
 	if err != nil {
@@ -1428,7 +3215,7 @@ func TestWithACRs(t *testing.T) {
 
 	want := Provider{
 		config: &oidc.Configuration{
-			ACRs: []goidc.ACR{"0"},
+			JWTLeewayTimeSecs: 10,
 		},
 	}
 	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
@@ -1436,63 +3223,90 @@ func TestWithACRs(t *testing.T) {
 	}
 }
 
-func TestWithDisplayValues(t *testing.T) {
+func TestWithJWTLeeway_Zero(t *testing.T) {
 	// Given.
-	p := Provider{
-		config: &oidc.Configuration{},
-	}
+	jwk := oidctest.PrivatePS256JWK(t, "server_key", goidc.KeyUsageSignature)
 
 	// When.
-	err := WithDisplayValues(goidc.DisplayValuePage)(p)
+	p, err := New(
+		goidc.ProfileOpenID,
+		"https://example.com",
+		jose.JSONWebKeySet{Keys: []jose.JSONWebKey{jwk}},
+		WithJWTLeeway(0),
+		WithDPoPLeeway(5),
+	)
 
 	// Then.
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	want := Provider{
-		config: &oidc.Configuration{
-			DisplayValues: []goidc.DisplayValue{goidc.DisplayValuePage},
-		},
+	if p.config.JWTLeewayTimeSecs != 0 {
+		t.Errorf("JWTLeewayTimeSecs = %d, want 0", p.config.JWTLeewayTimeSecs)
 	}
-	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
-		t.Error(diff)
+
+	if p.config.JARLeewayTimeSecs != 0 {
+		t.Errorf("JARLeewayTimeSecs = %d, want 0", p.config.JARLeewayTimeSecs)
+	}
+
+	if p.config.DPoPLeewayTimeSecs != 5 {
+		t.Errorf("DPoPLeewayTimeSecs = %d, want 5", p.config.DPoPLeewayTimeSecs)
 	}
 }
 
-func TestWithAuthenticationSessionTimeout(t *testing.T) {
+func TestWithJWTLeeway_Default(t *testing.T) {
 	// Given.
-	p := Provider{
-		config: &oidc.Configuration{},
-	}
+	jwk := oidctest.PrivatePS256JWK(t, "server_key", goidc.KeyUsageSignature)
 
 	// When.
-	err := WithAuthenticationSessionTimeout(10)(p)
+	p, err := New(
+		goidc.ProfileOpenID,
+		"https://example.com",
+		jose.JSONWebKeySet{Keys: []jose.JSONWebKey{jwk}},
+		WithJARLeeway(0),
+	)
 
 	// Then.
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	want := Provider{
-		config: &oidc.Configuration{
-			AuthnSessionTimeoutSecs: 10,
-		},
+	if p.config.JWTLeewayTimeSecs != defaultJWTLeewayTimeSecs {
+		t.Errorf("JWTLeewayTimeSecs = %d, want %d", p.config.JWTLeewayTimeSecs, defaultJWTLeewayTimeSecs)
 	}
-	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
-		t.Error(diff)
+
+	if p.config.IDTokenHintLeewayTimeSecs != defaultJWTLeewayTimeSecs {
+		t.Errorf("IDTokenHintLeewayTimeSecs = %d, want %d", p.config.IDTokenHintLeewayTimeSecs, defaultJWTLeewayTimeSecs)
+	}
+
+	if p.config.JARLeewayTimeSecs != 0 {
+		t.Errorf("JARLeewayTimeSecs = %d, want 0", p.config.JARLeewayTimeSecs)
 	}
 }
 
-func TestWithStaticClient(t *testing.T) {
+func TestWithJWTLeeway_Negative(t *testing.T) {
 	// Given.
 	p := Provider{
 		config: &oidc.Configuration{},
 	}
-	c, _ := oidctest.NewClient(t)
 
 	// When.
-	err := WithStaticClient(c)(p)
+	err := WithJWTLeeway(-1)(p)
+
+	// Then.
+	if err == nil {
+		t.Fatal("negative leeways must be rejected")
+	}
+}
+
+func TestWithAssertionLeeway(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: &oidc.Configuration{},
+	}
+
+	// When.
+	err := WithAssertionLeeway(10)(p)
 
 	// Then.
 	if err != nil {
@@ -1501,7 +3315,7 @@ func TestWithStaticClient(t *testing.T) {
 
 	want := Provider{
 		config: &oidc.Configuration{
-			StaticClients: []*goidc.Client{c},
+			AssertionLeewayTimeSecs: 10,
 		},
 	}
 	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
@@ -1509,17 +3323,14 @@ func TestWithStaticClient(t *testing.T) {
 	}
 }
 
-func TestWithPolicy(t *testing.T) {
+func TestWithAssertionAudiences(t *testing.T) {
 	// Given.
 	p := Provider{
 		config: &oidc.Configuration{},
 	}
-	policy := goidc.AuthnPolicy{
-		ID: "policy_id",
-	}
 
 	// When.
-	err := WithPolicy(policy)(p)
+	err := WithAssertionAudiences("https://example.com/auth", "https://example.com/auth/token")(p)
 
 	// Then.
 	if err != nil {
@@ -1528,7 +3339,7 @@ func TestWithPolicy(t *testing.T) {
 
 	want := Provider{
 		config: &oidc.Configuration{
-			Policies: []goidc.AuthnPolicy{policy},
+			CustomAssertionAudiences: []string{"https://example.com/auth", "https://example.com/auth/token"},
 		},
 	}
 	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
@@ -1536,138 +3347,132 @@ func TestWithPolicy(t *testing.T) {
 	}
 }
 
-func TestWithRenderErrorFunc(t *testing.T) {
+func TestWithAssertionAudiencesOnly(t *testing.T) {
 	// Given.
 	p := Provider{
 		config: &oidc.Configuration{},
 	}
-	var renderFunc goidc.RenderErrorFunc = func(
-		w http.ResponseWriter,
-		r *http.Request,
-		err error,
-	) error {
-		return nil
-	}
 
 	// When.
-	err := WithRenderErrorFunc(renderFunc)(p)
+	err := WithAssertionAudiencesOnly("https://example.com/auth")(p)
 
 	// Then.
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	if p.config.RenderErrorFunc == nil {
-		t.Error("RenderErrorFunc cannot be nil")
+	want := Provider{
+		config: &oidc.Configuration{
+			CustomAssertionAudiences:          []string{"https://example.com/auth"},
+			AssertionAudiencesReplaceDefaults: true,
+		},
+	}
+	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
+		t.Error(diff)
 	}
 }
 
-func TestWithNotifyErrorFunc(t *testing.T) {
+func TestWithIssuerAliases(t *testing.T) {
 	// Given.
 	p := Provider{
 		config: &oidc.Configuration{},
 	}
-	var handleErrorFunc goidc.NotifyErrorFunc = func(
-		r *http.Request,
-		err error,
-	) {
-	}
 
 	// When.
-	err := WithNotifyErrorFunc(handleErrorFunc)(p)
+	err := WithIssuerAliases("https://old.example.com")(p)
 
 	// Then.
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	if p.config.NotifyErrorFunc == nil {
-		t.Error("HandleErrorFunc cannot be nil")
+	want := Provider{
+		config: &oidc.Configuration{
+			IssuerAliases: []string{"https://old.example.com"},
+		},
+	}
+	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
+		t.Error(diff)
 	}
 }
 
-func TestWithCheckJTIFunc(t *testing.T) {
+func TestWithTLSClientCertPolicy(t *testing.T) {
 	// Given.
 	p := Provider{
 		config: &oidc.Configuration{},
 	}
-	var checkJTIFunc goidc.CheckJTIFunc = func(ctx context.Context, s string) error {
-		return nil
+	policy := goidc.TLSClientCertPolicy{
+		ExtKeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
 	}
 
 	// When.
-	err := WithCheckJTIFunc(checkJTIFunc)(p)
+	err := WithTLSClientCertPolicy(policy)(p)
 
 	// Then.
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	if p.config.CheckJTIFunc == nil {
-		t.Error("CheckJTIFunc cannot be nil")
+	want := Provider{
+		config: &oidc.Configuration{
+			TLSClientCertPolicy: &policy,
+		},
+	}
+	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
+		t.Error(diff)
 	}
 }
 
-func TestWithResourceIndicators(t *testing.T) {
+func TestWithClientIPFunc(t *testing.T) {
 	// Given.
 	p := Provider{
 		config: &oidc.Configuration{},
 	}
 
 	// When.
-	err := WithResourceIndicators("https://resource.com")(p)
+	err := WithClientIPFunc(func(r *http.Request) (netip.Addr, error) {
+		return netip.Addr{}, nil
+	})(p)
 
 	// Then.
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	want := Provider{
-		config: &oidc.Configuration{
-			ResourceIndicatorsIsEnabled: true,
-			Resources:                   []string{"https://resource.com"},
-		},
-	}
-	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
-		t.Error(diff)
+	if p.config.ClientIPFunc == nil {
+		t.Error("the client ip function must be set")
 	}
 }
 
-func TestWithResourceIndicatorsRequired(t *testing.T) {
+func TestWithClientAuthnHooks(t *testing.T) {
 	// Given.
 	p := Provider{
 		config: &oidc.Configuration{},
 	}
+	throttler := goidc.NewClientAuthnThrottler(5, 60)
 
 	// When.
-	err := WithResourceIndicatorsRequired("https://resource.com")(p)
+	err := WithClientAuthnHooks(throttler.BeforeAuthn, throttler.AfterAuthn)(p)
 
 	// Then.
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	want := Provider{
-		config: &oidc.Configuration{
-			ResourceIndicatorsIsEnabled:  true,
-			ResourceIndicatorsIsRequired: true,
-			Resources:                    []string{"https://resource.com"},
-		},
-	}
-	if diff := cmp.Diff(p, want, cmp.AllowUnexported(Provider{})); diff != "" {
-		t.Error(diff)
+	if p.config.BeforeClientAuthnFunc == nil || p.config.AfterClientAuthnFunc == nil {
+		t.Error("the client authn hooks must be set")
 	}
 }
 
-func TestWithHTTPClientFunc(t *testing.T) {
+func TestWithSubjectIdentifierFunc(t *testing.T) {
 	// Given.
 	p := Provider{
 		config: &oidc.Configuration{},
 	}
 
 	// When.
-	err := WithHTTPClientFunc(func(ctx context.Context) *http.Client {
-		return http.DefaultClient
+	err := WithSubjectIdentifierFunc(func(c *goidc.Client, userID string) string {
+		return c.ID + ":" + userID
 	})(p)
 
 	// Then.
@@ -1675,28 +3480,22 @@ func TestWithHTTPClientFunc(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	if p.config.HTTPClientFunc == nil {
-		t.Error("HTTPClientFunc cannot be nil")
+	if p.config.SubjectIdentifierFunc == nil {
+		t.Error("the subject identifier function was not set")
 	}
 }
 
-func TestJWTBearerGrant(t *testing.T) {
+func TestWithTokenIssuer_NativeFormat(t *testing.T) {
 	// Given.
 	p := Provider{
 		config: &oidc.Configuration{},
 	}
 
 	// When.
-	err := WithJWTBearerGrant(func(r *http.Request, assertion string) (goidc.JWTBearerGrantInfo, error) {
-		return goidc.JWTBearerGrantInfo{}, nil
-	})(p)
+	err := WithTokenIssuer(goidc.TokenFormatJWT, nil)(p)
 
 	// Then.
-	if err != nil {
-		t.Fatalf("unexpected error: %v", err)
-	}
-
-	if p.config.HandleJWTBearerGrantAssertionFunc == nil {
-		t.Error("HandleJWTBearerGrantAssertionFunc cannot be nil")
+	if err == nil {
+		t.Fatal("native formats cannot be registered as custom")
 	}
 }
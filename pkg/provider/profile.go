@@ -0,0 +1,157 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/luikyv/go-oidc/internal/oidc"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+// ProfileReport lists the requirements of a profile that the configuration of
+// the provider doesn't meet.
+type ProfileReport struct {
+	Profile    goidc.Profile
+	Violations []ProfileViolation
+}
+
+// IsCompliant returns whether the configuration meets all the requirements
+// of the profile checked.
+func (r ProfileReport) IsCompliant() bool {
+	return len(r.Violations) == 0
+}
+
+// ProfileViolation is a requirement of a profile not met by the provider.
+type ProfileViolation struct {
+	// Requirement identifies the rule broken, e.g. "par_required".
+	Requirement string
+	Description string
+}
+
+// CheckProfile runs the validators of the profile informed against the
+// current configuration of the provider without switching to it, which is
+// useful while migrating a deployment, e.g. from [goidc.ProfileOpenID] to
+// [goidc.ProfileFAPI2].
+// Only requirements that depend on the configuration are checked. The ones
+// enforced per request, such as the nbf claim of request objects, are applied
+// once the profile is selected. Profiles without specific requirements always
+// comply.
+func (p Provider) CheckProfile(profile goidc.Profile) ProfileReport {
+	report := ProfileReport{Profile: profile}
+	for _, v := range profileValidators[profile] {
+		if err := v.validate(p.config); err != nil {
+			report.Violations = append(report.Violations, ProfileViolation{
+				Requirement: v.requirement,
+				Description: err.Error(),
+			})
+		}
+	}
+	return report
+}
+
+type profileValidator struct {
+	requirement string
+	validate    func(*oidc.Configuration) error
+}
+
+var profileValidators = map[goidc.Profile][]profileValidator{
+	goidc.ProfileFAPI2: {
+		{"par_required", validateFAPI2PAR},
+		{"pkce_s256_required", validateFAPI2PKCE},
+		{"code_response_type_only", validateFAPI2ResponseTypes},
+		{"implicit_grant_not_allowed", validateFAPI2Grants},
+		{"confidential_client_authn", validateFAPI2ClientAuthn},
+		{"sender_constrained_tokens", validateFAPI2TokenBinding},
+		{"issuer_response_parameter", validateFAPI2IssuerRespParam},
+		{"signing_algorithms", validateFAPI2SigAlgs},
+		{"authorization_code_lifetime", validateFAPI2AuthzCodeLifetime},
+	},
+}
+
+// fapi2SigAlgs are the signing algorithms allowed by FAPI 2.0.
+var fapi2SigAlgs = []jose.SignatureAlgorithm{jose.PS256, jose.ES256, jose.EdDSA}
+
+func validateFAPI2PAR(config *oidc.Configuration) error {
+	if !config.PARIsRequired {
+		return errors.New("pushed authorization requests must be required")
+	}
+	return nil
+}
+
+func validateFAPI2PKCE(config *oidc.Configuration) error {
+	if !config.PKCEIsRequired {
+		return errors.New("PKCE must be required")
+	}
+
+	if !slices.Equal(config.PKCEChallengeMethods, []goidc.CodeChallengeMethod{goidc.CodeChallengeMethodSHA256}) {
+		return fmt.Errorf("only the code challenge method %s must be allowed, got %v",
+			goidc.CodeChallengeMethodSHA256, config.PKCEChallengeMethods)
+	}
+	return nil
+}
+
+func validateFAPI2ResponseTypes(config *oidc.Configuration) error {
+	for _, respType := range config.ResponseTypes {
+		if respType != goidc.ResponseTypeCode {
+			return fmt.Errorf("the response type %s is not allowed", respType)
+		}
+	}
+	return nil
+}
+
+func validateFAPI2Grants(config *oidc.Configuration) error {
+	if slices.Contains(config.GrantTypes, goidc.GrantImplicit) {
+		return errors.New("the implicit grant is not allowed")
+	}
+	return nil
+}
+
+func validateFAPI2ClientAuthn(config *oidc.Configuration) error {
+	for _, method := range config.TokenAuthnMethods {
+		switch method {
+		case goidc.ClientAuthnPrivateKeyJWT, goidc.ClientAuthnTLS, goidc.ClientAuthnSelfSignedTLS:
+		default:
+			return fmt.Errorf("the client authentication method %s is not allowed", method)
+		}
+	}
+	return nil
+}
+
+func validateFAPI2TokenBinding(config *oidc.Configuration) error {
+	if !config.TokenBindingIsRequired {
+		return errors.New("access tokens must be sender constrained with DPoP or TLS")
+	}
+	return nil
+}
+
+func validateFAPI2IssuerRespParam(config *oidc.Configuration) error {
+	if !config.IssuerRespParamIsEnabled {
+		return errors.New("the iss authorization response parameter must be enabled")
+	}
+	return nil
+}
+
+func validateFAPI2AuthzCodeLifetime(config *oidc.Configuration) error {
+	if config.AuthzCodeLifetimeSecs > fapi2MaxAuthzCodeLifetimeSecs {
+		return fmt.Errorf("the authorization code lifetime must not exceed %d seconds, got %d",
+			fapi2MaxAuthzCodeLifetimeSecs, config.AuthzCodeLifetimeSecs)
+	}
+	return nil
+}
+
+func validateFAPI2SigAlgs(config *oidc.Configuration) error {
+	for _, alg := range slices.Concat(
+		config.UserSigAlgs,
+		config.JARSigAlgs,
+		config.JARMSigAlgs,
+		config.PrivateKeyJWTSigAlgs,
+		config.DPoPSigAlgs,
+	) {
+		if !slices.Contains(fapi2SigAlgs, alg) {
+			return fmt.Errorf("the signing algorithm %s is not allowed", alg)
+		}
+	}
+	return nil
+}
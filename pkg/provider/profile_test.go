@@ -0,0 +1,94 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/google/go-cmp/cmp"
+	"github.com/luikyv/go-oidc/internal/oidc"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+func TestCheckProfile_FAPI2(t *testing.T) {
+	// Given.
+	p := Provider{config: &oidc.Configuration{
+		Profile:                  goidc.ProfileOpenID,
+		GrantTypes:               []goidc.GrantType{goidc.GrantAuthorizationCode},
+		ResponseTypes:            []goidc.ResponseType{goidc.ResponseTypeCode},
+		TokenAuthnMethods:        []goidc.ClientAuthnType{goidc.ClientAuthnPrivateKeyJWT},
+		UserSigAlgs:              []jose.SignatureAlgorithm{jose.PS256},
+		PrivateKeyJWTSigAlgs:     []jose.SignatureAlgorithm{jose.PS256},
+		DPoPIsEnabled:            true,
+		DPoPSigAlgs:              []jose.SignatureAlgorithm{jose.ES256},
+		TokenBindingIsRequired:   true,
+		PARIsEnabled:             true,
+		PARIsRequired:            true,
+		PKCEIsEnabled:            true,
+		PKCEIsRequired:           true,
+		PKCEChallengeMethods:     []goidc.CodeChallengeMethod{goidc.CodeChallengeMethodSHA256},
+		IssuerRespParamIsEnabled: true,
+	}}
+
+	// When.
+	report := p.CheckProfile(goidc.ProfileFAPI2)
+
+	// Then.
+	if !report.IsCompliant() {
+		t.Errorf("the configuration must comply with FAPI 2.0, got %v", report.Violations)
+	}
+
+	if p.config.Profile != goidc.ProfileOpenID {
+		t.Error("the profile of the provider must not change")
+	}
+}
+
+func TestCheckProfile_FAPI2Violations(t *testing.T) {
+	// Given.
+	p := Provider{config: &oidc.Configuration{
+		GrantTypes:            []goidc.GrantType{goidc.GrantAuthorizationCode, goidc.GrantImplicit},
+		ResponseTypes:         []goidc.ResponseType{goidc.ResponseTypeCode, goidc.ResponseTypeIDToken},
+		TokenAuthnMethods:     []goidc.ClientAuthnType{goidc.ClientAuthnSecretBasic},
+		UserSigAlgs:           []jose.SignatureAlgorithm{jose.RS256},
+		PKCEIsEnabled:         true,
+		PKCEIsRequired:        true,
+		PKCEChallengeMethods:  []goidc.CodeChallengeMethod{goidc.CodeChallengeMethodSHA256, goidc.CodeChallengeMethodPlain},
+		AuthzCodeLifetimeSecs: 120,
+	}}
+
+	// When.
+	report := p.CheckProfile(goidc.ProfileFAPI2)
+
+	// Then.
+	var requirements []string
+	for _, v := range report.Violations {
+		requirements = append(requirements, v.Requirement)
+	}
+
+	want := []string{
+		"par_required",
+		"pkce_s256_required",
+		"code_response_type_only",
+		"implicit_grant_not_allowed",
+		"confidential_client_authn",
+		"sender_constrained_tokens",
+		"issuer_response_parameter",
+		"signing_algorithms",
+		"authorization_code_lifetime",
+	}
+	if diff := cmp.Diff(requirements, want); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestCheckProfile_OpenID(t *testing.T) {
+	// Given.
+	p := Provider{config: &oidc.Configuration{}}
+
+	// When.
+	report := p.CheckProfile(goidc.ProfileOpenID)
+
+	// Then.
+	if !report.IsCompliant() {
+		t.Errorf("the openid profile has no requirements, got %v", report.Violations)
+	}
+}
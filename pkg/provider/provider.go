@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"reflect"
 	"slices"
+	"time"
 
 	"github.com/go-jose/go-jose/v4"
 	"github.com/luikyv/go-oidc/internal/authorize"
@@ -44,6 +45,12 @@ func New(
 			Profile:     profile,
 			Host:        issuer,
 			PrivateJWKS: privateJWKS,
+			// The leeways start unset so they can be explicitly set to zero.
+			JWTLeewayTimeSecs:         leewayUnset,
+			AssertionLeewayTimeSecs:   leewayUnset,
+			JARLeewayTimeSecs:         leewayUnset,
+			DPoPLeewayTimeSecs:        leewayUnset,
+			IDTokenHintLeewayTimeSecs: leewayUnset,
 		},
 	}
 
@@ -61,9 +68,31 @@ func New(
 		return Provider{}, err
 	}
 
+	p.config.Precomputed = oidc.NewPrecomputed(p.config)
+	if err := p.loadStaticClients(); err != nil {
+		return Provider{}, err
+	}
+
+	if p.config.AsyncGrantSessionIsEnabled {
+		p.config.GrantSessionWriter = oidc.NewGrantSessionWriter(
+			p.config.GrantSessionManager,
+			p.config.GrantSessionQueueSize,
+			p.config.GrantSessionSaveErrorFunc,
+		)
+	}
 	return p, nil
 }
 
+// Close waits for the grant sessions being persisted in the background to be
+// saved. See [WithAsyncGrantSessionStorage].
+// After Close, grant sessions are saved synchronously once the ones queued are
+// saved.
+func (p Provider) Close() {
+	if p.config.GrantSessionWriter != nil {
+		p.config.GrantSessionWriter.Close()
+	}
+}
+
 // Handler returns an HTTP handler with all the logic defined for the openid
 // provider.
 // This may be used to add the oidc logic to a HTTP server.
@@ -73,17 +102,73 @@ func New(
 func (p Provider) Handler() http.Handler {
 
 	server := http.NewServeMux()
-
-	discovery.RegisterHandlers(server, p.config)
-	token.RegisterHandlers(server, p.config)
-	authorize.RegisterHandlers(server, p.config)
-	userinfo.RegisterHandlers(server, p.config)
-	dcr.RegisterHandlers(server, p.config)
+	p.registerHandlers(server)
 
 	handler := goidc.CacheControlMiddleware(server)
 	return handler
 }
 
+// HandlerFuncs returns the handlers of the openid provider keyed by their
+// [http.ServeMux] patterns, e.g. "POST /token".
+// This may be used to mount the endpoints into routers other than
+// [http.ServeMux] along with their native middleware chains.
+//
+// Some patterns contain wildcards, e.g. "GET /register/{client_id}". Routers
+// that don't populate the request's path values must do it with
+// [http.Request.SetPathValue] before calling the handler.
+//
+//	for pattern, handler := range op.HandlerFuncs() {
+//		method, path, _ := strings.Cut(pattern, " ")
+//		router.MethodFunc(method, path, handler)
+//	}
+func (p Provider) HandlerFuncs() map[string]http.HandlerFunc {
+	funcs := handlerFuncs{}
+	p.registerHandlers(funcs)
+
+	for pattern, handler := range funcs {
+		funcs[pattern] = goidc.CacheControlMiddleware(handler).ServeHTTP
+	}
+	return funcs
+}
+
+func (p Provider) registerHandlers(router oidc.Router) {
+	if p.config.Tracer != nil || p.config.Metrics != nil {
+		router = instrumentedRouter{
+			Router:  router,
+			tracer:  p.config.Tracer,
+			metrics: p.config.Metrics,
+		}
+	}
+
+	if p.config.BeforeRequestFunc != nil || p.config.AfterResponseFunc != nil {
+		router = hookedRouter{Router: router, config: p.config}
+	}
+
+	if p.config.RequestLimits != (goidc.RequestLimits{}) || len(p.config.EndpointRequestLimits) != 0 {
+		router = limitedRouter{Router: router, config: p.config}
+	}
+
+	if len(p.config.MTLSEndpointHosts) != 0 {
+		router = hostRouter{Router: router, config: p.config}
+	}
+
+	discovery.RegisterHandlers(router, p.config)
+	token.RegisterHandlers(router, p.config)
+	authorize.RegisterHandlers(router, p.config)
+	userinfo.RegisterHandlers(router, p.config)
+	dcr.RegisterHandlers(router, p.config)
+}
+
+// handlerFuncs implements [oidc.Router] by collecting the handlers registered.
+type handlerFuncs map[string]http.HandlerFunc
+
+func (h handlerFuncs) HandleFunc(
+	pattern string,
+	handler func(http.ResponseWriter, *http.Request),
+) {
+	h[pattern] = handler
+}
+
 func (p Provider) Run(
 	address string,
 	middlewares ...goidc.MiddlewareFunc,
@@ -120,6 +205,8 @@ func (p Provider) ValidateTokenPoP(
 	return token.ValidatePoP(ctx, accessToken, cnf)
 }
 
+// Client returns the client with the ID informed.
+// Disabled clients are not returned, see [goidc.ClientStatusDisabled].
 func (p *Provider) Client(
 	ctx context.Context,
 	id string,
@@ -133,7 +220,298 @@ func (p *Provider) Client(
 		}
 	}
 
-	return p.config.ClientManager.Client(ctx, id)
+	c, err := p.config.ClientManager.Client(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.IsDisabled() {
+		return nil, goidc.ErrClientDisabled
+	}
+
+	return c, nil
+}
+
+// NewInitialAccessToken issues a token that allows clients to be registered
+// dynamically until it expires, optionally restricting the scopes they can
+// have. The token is a JWT signed with the default signing key, so it
+// doesn't need to be stored.
+// To require these tokens during registration, see
+// [WithDCRInitialAccessTokens].
+func (p Provider) NewInitialAccessToken(opts goidc.InitialAccessTokenOptions) (string, error) {
+	ctx := oidc.NewContext(nil, nil, p.config)
+	return dcr.NewInitialAccessToken(ctx, opts)
+}
+
+// Clients lists the clients stored by the client manager matching the filter.
+// Static clients are not included.
+// This is intended for trusted back office use, so no authentication is
+// performed. The client manager must implement [goidc.ClientLister].
+func (p Provider) Clients(
+	ctx context.Context,
+	filter goidc.ClientFilter,
+) (
+	[]*goidc.Client,
+	error,
+) {
+	lister, ok := p.config.ClientManager.(goidc.ClientLister)
+	if !ok {
+		return nil, errors.New("the client manager does not support listing clients")
+	}
+
+	return lister.List(ctx, filter)
+}
+
+// UpdateClient creates or replaces a client bypassing dynamic client
+// registration, so neither the registration access token nor the metadata
+// are validated.
+// This is intended for trusted back office use, e.g. suspending a client by
+// setting its status to [goidc.ClientStatusDisabled].
+func (p Provider) UpdateClient(
+	ctx context.Context,
+	client *goidc.Client,
+) error {
+	if slices.ContainsFunc(p.config.StaticClients, func(c *goidc.Client) bool {
+		return c.ID == client.ID
+	}) {
+		return errors.New("static clients cannot be updated")
+	}
+
+	return p.config.ClientManager.Save(ctx, client)
+}
+
+// DeleteClient deletes a client bypassing dynamic client registration.
+// This is intended for trusted back office use.
+func (p Provider) DeleteClient(
+	ctx context.Context,
+	id string,
+) error {
+	if slices.ContainsFunc(p.config.StaticClients, func(c *goidc.Client) bool {
+		return c.ID == id
+	}) {
+		return errors.New("static clients cannot be deleted")
+	}
+
+	return p.config.ClientManager.Delete(ctx, id)
+}
+
+// ConsentContext returns the information a consent screen needs to describe
+// the client and what it is requesting, using the client information that
+// best matches the BCP47 language tag, e.g. the "ui_locales" requested.
+func (p Provider) ConsentContext(
+	ctx context.Context,
+	session *goidc.AuthnSession,
+	tag string,
+) (
+	goidc.ConsentContext,
+	error,
+) {
+	client, err := p.Client(ctx, session.ClientID)
+	if err != nil {
+		return goidc.ConsentContext{}, err
+	}
+
+	return goidc.NewConsentContext(client, session, tag), nil
+}
+
+// ConsentStep wraps the authentication step responsible for asking the user's
+// consent.
+// If the consent previously given by the user covers everything the client
+// requested, the step is skipped and the accesses are granted right away,
+// unless the client requested prompt=consent.
+// It must be placed after the user is identified, since the consent is looked
+// up by subject.
+func (p Provider) ConsentStep(step goidc.AuthnStep) goidc.AuthnStep {
+	return func(
+		w http.ResponseWriter,
+		r *http.Request,
+		session *goidc.AuthnSession,
+	) (
+		goidc.AuthnStatus,
+		error,
+	) {
+		if !p.config.ConsentIsEnabled || session.MustPromptConsent() {
+			return step(w, r, session)
+		}
+
+		ctx := oidc.NewContext(w, r, p.config)
+		consent, err := ctx.Consent(session.Subject, session.ClientID)
+		if err != nil || !consent.Covers(session) {
+			return step(w, r, session)
+		}
+
+		session.GrantScopes(session.Scopes)
+		session.GrantAuthorizationDetails(session.AuthDetails)
+		session.GrantResources(session.Resources)
+		return goidc.StatusSuccess, nil
+	}
+}
+
+// ResumeAuthentication continues an authentication session in progress
+// identified by its callback ID and writes the result to the response, the
+// same way the callback endpoint does.
+// It is intended for out of band steps that resume the flow outside the
+// callback endpoint, e.g. an email verification link handled by the
+// application, so the application is responsible for making sure the request
+// comes from the right user. Policies waiting for such steps can keep the
+// session alive with [goidc.AuthnSession.ExtendExpiry].
+func (p Provider) ResumeAuthentication(
+	w http.ResponseWriter,
+	r *http.Request,
+	callbackID string,
+) {
+	ctx := oidc.NewContext(w, r, p.config)
+	err := authorize.ResumeAuth(ctx, callbackID)
+	if err == nil {
+		return
+	}
+
+	if err := ctx.RenderError(err); err != nil {
+		ctx.WriteError(err)
+	}
+}
+
+// RevokeConsent deletes the consent the user gave to the client and revokes
+// all the grants issued based on it.
+func (p Provider) RevokeConsent(
+	ctx context.Context,
+	subject string,
+	clientID string,
+) error {
+	oidcCtx := oidc.NewContext(nil, nil, p.config)
+	oidcCtx.SetContext(ctx)
+
+	consent, err := oidcCtx.Consent(subject, clientID)
+	if err != nil {
+		return err
+	}
+
+	for _, grant := range consent.Grants {
+		if err := oidcCtx.DeleteGrantSession(grant.ID); err != nil {
+			return err
+		}
+		oidcCtx.NotifyTokenEvent(goidc.TokenEventRevocation, &goidc.GrantSession{
+			ID: grant.ID,
+			GrantInfo: goidc.GrantInfo{
+				ClientID: clientID,
+				Subject:  subject,
+			},
+		})
+	}
+
+	return oidcCtx.DeleteConsent(subject, clientID)
+}
+
+// AuthnSessions lists the authentication sessions in progress, e.g. the ones
+// of a user or of a client.
+// This is intended for trusted back office use and requires the
+// authentication session manager to implement [goidc.AuthnSessionLister].
+func (p Provider) AuthnSessions(
+	ctx context.Context,
+	filter goidc.SessionFilter,
+) (
+	[]*goidc.AuthnSession,
+	error,
+) {
+	lister, ok := p.config.AuthnSessionManager.(goidc.AuthnSessionLister)
+	if !ok {
+		return nil, errors.New("the authn session manager does not support listing sessions")
+	}
+
+	return lister.List(ctx, filter)
+}
+
+// TerminateAuthnSession deletes an authentication session in progress, so the
+// flow it belongs to can no longer be completed.
+// This is intended for trusted back office use.
+func (p Provider) TerminateAuthnSession(
+	ctx context.Context,
+	id string,
+) error {
+	oidcCtx := oidc.NewContext(nil, nil, p.config)
+	oidcCtx.SetContext(ctx)
+	return oidcCtx.DeleteAuthnSession(id)
+}
+
+// GrantSessions lists the active grant sessions, e.g. the ones of a user or of
+// a client.
+// This is intended for trusted back office use and requires the grant session
+// manager to implement [goidc.GrantSessionLister].
+func (p Provider) GrantSessions(
+	ctx context.Context,
+	filter goidc.SessionFilter,
+) (
+	[]*goidc.GrantSession,
+	error,
+) {
+	lister, ok := p.config.GrantSessionManager.(goidc.GrantSessionLister)
+	if !ok {
+		return nil, errors.New("the grant session manager does not support listing sessions")
+	}
+
+	return lister.List(ctx, filter)
+}
+
+// TerminateGrantSession revokes a grant session, invalidating the tokens
+// issued for it. The revocation is notified as a
+// [goidc.TokenEventRevocation].
+// This is intended for trusted back office use.
+func (p Provider) TerminateGrantSession(
+	ctx context.Context,
+	id string,
+) error {
+	oidcCtx := oidc.NewContext(nil, nil, p.config)
+	oidcCtx.SetContext(ctx)
+
+	// Load the session when possible, so the notification carries the
+	// client and the subject of the grant.
+	session := &goidc.GrantSession{ID: id}
+	if sessions, err := p.GrantSessions(ctx, goidc.SessionFilter{ID: id}); err == nil && len(sessions) != 0 {
+		session = sessions[0]
+	}
+
+	if err := oidcCtx.DeleteGrantSession(id); err != nil {
+		return err
+	}
+	oidcCtx.NotifyTokenEvent(goidc.TokenEventRevocation, session)
+	return nil
+}
+
+// UserSessions lists the active single sign on sessions, e.g. the ones where
+// a user is signed in.
+// This is intended for trusted back office use and requires user sessions to
+// be enabled with a manager implementing [goidc.UserSessionLister].
+func (p Provider) UserSessions(
+	ctx context.Context,
+	filter goidc.SessionFilter,
+) (
+	[]*goidc.UserSession,
+	error,
+) {
+	lister, ok := p.config.UserSessionManager.(goidc.UserSessionLister)
+	if !ok {
+		return nil, errors.New("the user session manager does not support listing sessions")
+	}
+
+	return lister.List(ctx, filter)
+}
+
+// TerminateUserSession deletes a single sign on session, so all the accounts
+// signed in it must authenticate again. The grants issued during the session
+// are not affected, they can be revoked with [Provider.TerminateGrantSession].
+// Back channel logout is not supported yet, so clients are not notified.
+// This is intended for trusted back office use.
+func (p Provider) TerminateUserSession(
+	ctx context.Context,
+	id string,
+) error {
+	if !p.config.UserSessionIsEnabled {
+		return errors.New("user sessions are not enabled")
+	}
+
+	oidcCtx := oidc.NewContext(nil, nil, p.config)
+	oidcCtx.SetContext(ctx)
+	return oidcCtx.DeleteUserSession(id)
 }
 
 func (p Provider) setDefaults() error {
@@ -155,18 +533,74 @@ func (p Provider) setDefaults() error {
 		p.config.Scopes,
 		[]goidc.Scope{goidc.ScopeOpenID},
 	)
+	p.config.JWTLeewayTimeSecs = setOrDefaultLeeway(
+		p.config.JWTLeewayTimeSecs,
+		defaultJWTLeewayTimeSecs,
+	)
+	p.config.AssertionLeewayTimeSecs = setOrDefaultLeeway(
+		p.config.AssertionLeewayTimeSecs,
+		p.config.JWTLeewayTimeSecs,
+	)
+	p.config.JARLeewayTimeSecs = setOrDefaultLeeway(
+		p.config.JARLeewayTimeSecs,
+		p.config.JWTLeewayTimeSecs,
+	)
+	p.config.DPoPLeewayTimeSecs = setOrDefaultLeeway(
+		p.config.DPoPLeewayTimeSecs,
+		p.config.JWTLeewayTimeSecs,
+	)
+	p.config.IDTokenHintLeewayTimeSecs = setOrDefaultLeeway(
+		p.config.IDTokenHintLeewayTimeSecs,
+		p.config.JWTLeewayTimeSecs,
+	)
 	p.config.ClientManager = nonZeroOrDefault(
 		p.config.ClientManager,
 		goidc.ClientManager(storage.NewClientManager()),
 	)
-	p.config.AuthnSessionManager = nonZeroOrDefault(
-		p.config.AuthnSessionManager,
-		goidc.AuthnSessionManager(storage.NewAuthnSessionManager()),
-	)
-	p.config.GrantSessionManager = nonZeroOrDefault(
-		p.config.GrantSessionManager,
-		goidc.GrantSessionManager(storage.NewGrantSessionManager()),
-	)
+	if p.config.AuthnSessionManager == nil {
+		manager := storage.NewAuthnSessionManager()
+		manager.MaxSessions = p.config.MaxAuthnSessions
+		manager.MaxBytes = p.config.MaxAuthnSessionBytes
+		p.config.AuthnSessionManager = manager
+	}
+	if p.config.GrantSessionManager == nil {
+		manager := storage.NewGrantSessionManager()
+		manager.MaxSessions = p.config.MaxGrantSessions
+		manager.MaxBytes = p.config.MaxGrantSessionBytes
+		p.config.GrantSessionManager = manager
+	}
+	if p.config.IntrospectionCache == nil && p.config.IntrospectionCacheTTLSecs > 0 {
+		p.config.IntrospectionCache = storage.NewIntrospectionCache(
+			time.Duration(p.config.IntrospectionCacheTTLSecs) * time.Second,
+		)
+	}
+	if p.config.DCROpenRegistrationIsEnabled {
+		p.config.DCRRegistrationLimiter = oidc.NewRateLimiter(
+			p.config.DCRMaxRegistrationsPerIP,
+			p.config.DCRRegistrationWindowSecs,
+		)
+	}
+	if p.config.ClientJWKSCacheTTLSecs > 0 {
+		p.config.ClientJWKSCache = oidc.NewClientJWKSCache(
+			time.Duration(p.config.ClientJWKSCacheTTLSecs) * time.Second,
+		)
+	}
+	if p.config.ConsentIsEnabled {
+		p.config.ConsentManager = nonZeroOrDefault(
+			p.config.ConsentManager,
+			goidc.ConsentManager(storage.NewConsentManager()),
+		)
+	}
+	if p.config.UserSessionIsEnabled {
+		p.config.UserSessionManager = nonZeroOrDefault(
+			p.config.UserSessionManager,
+			goidc.UserSessionManager(storage.NewUserSessionManager()),
+		)
+		p.config.UserSessionLifetimeSecs = nonZeroOrDefault(
+			p.config.UserSessionLifetimeSecs,
+			defaultUserSessionLifetimeSecs,
+		)
+	}
 	p.config.TokenOptionsFunc = nonZeroOrDefault(
 		p.config.TokenOptionsFunc,
 		defaultTokenOptionsFunc(defaultSigKey.KeyID),
@@ -188,14 +622,34 @@ func (p Provider) setDefaults() error {
 		p.config.AuthnSessionTimeoutSecs,
 		defaultAuthnSessionTimeoutSecs,
 	)
+	p.config.AuthnSessionMaxLifetimeSecs = nonZeroOrDefault(
+		p.config.AuthnSessionMaxLifetimeSecs,
+		defaultAuthnSessionMaxLifetimeSecs,
+	)
 	p.config.IDTokenLifetimeSecs = nonZeroOrDefault(
 		p.config.IDTokenLifetimeSecs,
 		defaultIDTokenLifetimeSecs,
 	)
+	p.config.AuthzCodeLifetimeSecs = nonZeroOrDefault(
+		p.config.AuthzCodeLifetimeSecs,
+		defaultAuthzCodeLifetimeSecs,
+	)
+	p.config.AuthzCodeLength = nonZeroOrDefault(
+		p.config.AuthzCodeLength,
+		defaultAuthzCodeLength,
+	)
 	p.config.EndpointWellKnown = nonZeroOrDefault(
 		p.config.EndpointWellKnown,
 		defaultEndpointWellKnown,
 	)
+	p.config.EndpointOAuthServerMetadata = nonZeroOrDefault(
+		p.config.EndpointOAuthServerMetadata,
+		defaultEndpointOAuthServerMetadata,
+	)
+	p.config.EndpointWebFinger = nonZeroOrDefault(
+		p.config.EndpointWebFinger,
+		defaultEndpointWebFinger,
+	)
 	p.config.EndpointJWKS = nonZeroOrDefault(
 		p.config.EndpointJWKS,
 		defaultEndpointJSONWebKeySet,
@@ -239,6 +693,17 @@ func (p Provider) setDefaults() error {
 		)
 	}
 
+	// Custom authentication methods are advertised for the token endpoint
+	// even if the methods were replaced after they were registered.
+	var customAuthnMethods []goidc.ClientAuthnType
+	for method := range p.config.ClientAuthnFuncs {
+		if !slices.Contains(p.config.TokenAuthnMethods, method) {
+			customAuthnMethods = append(customAuthnMethods, method)
+		}
+	}
+	slices.Sort(customAuthnMethods)
+	p.config.TokenAuthnMethods = append(p.config.TokenAuthnMethods, customAuthnMethods...)
+
 	authnMethods := append(
 		p.config.TokenAuthnMethods,
 		p.config.TokenIntrospectionAuthnMethods...,
@@ -267,11 +732,22 @@ func (p Provider) setDefaults() error {
 		)
 	}
 
+	if p.config.BeforeClientAuthnFunc != nil || p.config.AfterClientAuthnFunc != nil {
+		p.config.ClientAuthnFailures = oidc.NewClientAuthnFailures(
+			defaultClientAuthnFailuresTTLSecs*time.Second,
+			defaultClientAuthnFailuresMaxEntries,
+		)
+	}
+
 	if p.config.DCRIsEnabled {
 		p.config.EndpointDCR = nonZeroOrDefault(
 			p.config.EndpointDCR,
 			defaultEndpointDynamicClient,
 		)
+		p.config.SectorIdentifierCache = oidc.NewSectorIdentifierCache(
+			defaultSectorIdentifierCacheTTLSecs*time.Second,
+			defaultSectorIdentifierCacheMaxEntries,
+		)
 	}
 
 	if p.config.PARIsEnabled {
@@ -286,10 +762,6 @@ func (p Provider) setDefaults() error {
 			p.config.JARLifetimeSecs,
 			defaultJWTLifetimeSecs,
 		)
-		p.config.JARLeewayTimeSecs = nonZeroOrDefault(
-			p.config.JARLeewayTimeSecs,
-			defaultJWTLeewayTimeSecs,
-		)
 	}
 
 	if p.config.JAREncIsEnabled {
@@ -329,10 +801,6 @@ func (p Provider) setDefaults() error {
 			p.config.DPoPLifetimeSecs,
 			defaultJWTLifetimeSecs,
 		)
-		p.config.DPoPLeewayTimeSecs = nonZeroOrDefault(
-			p.config.DPoPLeewayTimeSecs,
-			defaultJWTLeewayTimeSecs,
-		)
 	}
 
 	if p.config.TokenIntrospectionIsEnabled {
@@ -360,6 +828,13 @@ func (p Provider) setDefaults() error {
 		)
 	}
 
+	if p.config.PrivateNetworkURIsAreDenied {
+		if p.config.URIPolicyFunc == nil {
+			p.config.URIPolicyFunc = goidc.DenyPrivateNetworkURIs
+		}
+		p.config.HTTPClientFunc = publicNetworkHTTPClientFunc(p.config.HTTPClientFunc)
+	}
+
 	return nil
 }
 
@@ -368,10 +843,16 @@ func (p Provider) validate() error {
 		p.config,
 		validateJWKS,
 		validateSigKeys,
+		validateAuthzCode,
+		validateDCR,
 		validateEncKeys,
 		validateJAREnc,
+		validateJAREncKeys,
 		validateJARMEnc,
 		validateTokenBinding,
+		validateMTLSEndpointHosts,
+		validateTokenEncryptionKey,
+		validateClientTokenMetadata,
 	)
 }
 
@@ -391,6 +872,15 @@ func nonZeroOrDefault[T any](s1 T, s2 T) T {
 	return s1
 }
 
+// setOrDefaultLeeway returns the leeway if it was set, zero included, or the
+// default otherwise.
+func setOrDefaultLeeway(secs, defaultSecs int) int {
+	if secs == leewayUnset {
+		return defaultSecs
+	}
+	return secs
+}
+
 func isNil(i any) bool {
 	return i == nil
 }
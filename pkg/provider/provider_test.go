@@ -0,0 +1,770 @@
+package provider_test
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/google/go-cmp/cmp"
+	"github.com/luikyv/go-oidc/internal/oidctest"
+	"github.com/luikyv/go-oidc/internal/storage"
+	"github.com/luikyv/go-oidc/internal/timeutil"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+	"github.com/luikyv/go-oidc/pkg/provider"
+)
+
+func TestHandlerFuncs(t *testing.T) {
+	// Given.
+	jwk := oidctest.PrivatePS256JWK(t, "server_key", goidc.KeyUsageSignature)
+	op, err := provider.New(
+		goidc.ProfileOpenID,
+		"https://example.com",
+		jose.JSONWebKeySet{Keys: []jose.JSONWebKey{jwk}},
+		provider.WithDCR(nil, nil),
+	)
+	if err != nil {
+		t.Fatalf("could not create the provider: %v", err)
+	}
+
+	// When.
+	funcs := op.HandlerFuncs()
+
+	// Then.
+	for _, pattern := range []string{
+		"GET /.well-known/openid-configuration",
+		"GET /authorize",
+		"POST /token",
+		"GET /register/{client_id}",
+	} {
+		if _, ok := funcs[pattern]; !ok {
+			t.Errorf("the pattern %s was not registered", pattern)
+		}
+	}
+
+	// When.
+	req := httptest.NewRequest(http.MethodGet, "/.well-known/openid-configuration", nil)
+	w := httptest.NewRecorder()
+	funcs["GET /.well-known/openid-configuration"](w, req)
+
+	// Then.
+	if w.Code != http.StatusOK {
+		t.Errorf("status code = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	if w.Header().Get("Cache-Control") == "" {
+		t.Error("the cache control header must be set")
+	}
+}
+
+func TestOpenAPISpec(t *testing.T) {
+	// Given.
+	jwk := oidctest.PrivatePS256JWK(t, "server_key", goidc.KeyUsageSignature)
+	op, err := provider.New(
+		goidc.ProfileOpenID,
+		"https://example.com",
+		jose.JSONWebKeySet{Keys: []jose.JSONWebKey{jwk}},
+		provider.WithPAR(60),
+		provider.WithDPoP(jose.PS256),
+	)
+	if err != nil {
+		t.Fatalf("could not create the provider: %v", err)
+	}
+
+	// When.
+	spec := op.OpenAPISpec()
+
+	// Then.
+	if _, err := json.Marshal(spec); err != nil {
+		t.Fatalf("the spec must be json encodable: %v", err)
+	}
+
+	paths := spec["paths"].(map[string]map[string]any)
+	for _, path := range []string{"/authorize", "/par", "/token", "/userinfo", "/jwks"} {
+		if _, ok := paths[path]; !ok {
+			t.Errorf("the path %s was not documented", path)
+		}
+	}
+
+	if _, ok := paths["/introspect"]; ok {
+		t.Error("disabled endpoints must not be documented")
+	}
+
+	if _, ok := paths["/authorize/{callback}"]; ok {
+		t.Error("the authentication callback must not be documented")
+	}
+
+	token := paths["/token"]["post"].(map[string]any)
+	params := token["parameters"].([]any)
+	if len(params) != 1 || params[0].(map[string]any)["name"] != goidc.HeaderDPoP {
+		t.Errorf("the dpop header must be documented for the token endpoint, got %v", params)
+	}
+}
+
+func TestWithTracerProvider(t *testing.T) {
+	// Given.
+	jwk := oidctest.PrivatePS256JWK(t, "server_key", goidc.KeyUsageSignature)
+	tracer := &recordingTracer{}
+	op, err := provider.New(
+		goidc.ProfileOpenID,
+		"https://example.com",
+		jose.JSONWebKeySet{Keys: []jose.JSONWebKey{jwk}},
+		provider.WithTracerProvider(tracer),
+	)
+	if err != nil {
+		t.Fatalf("could not create the provider: %v", err)
+	}
+
+	form := url.Values{
+		"grant_type":    {string(goidc.GrantClientCredentials)},
+		"client_id":     {"random_client_id"},
+		"client_secret": {"random_secret"},
+	}
+	req := httptest.NewRequest(http.MethodPost, "/token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+
+	// When.
+	op.Handler().ServeHTTP(w, req)
+
+	// Then.
+	want := map[string]string{
+		"POST /token":         "",
+		"token.GenerateGrant": "POST /token",
+		"client.Authenticate": "token.GenerateGrant",
+		"storage.Client":      "client.Authenticate",
+	}
+	for name, parent := range want {
+		span, ok := tracer.spans[name]
+		if !ok {
+			t.Errorf("the span %s was not started", name)
+			continue
+		}
+		if span.parent != parent {
+			t.Errorf("the parent of %s = %q, want %q", name, span.parent, parent)
+		}
+		if !span.ended {
+			t.Errorf("the span %s was not ended", name)
+		}
+	}
+
+	endpointSpan := tracer.spans["POST /token"]
+	if endpointSpan != nil && endpointSpan.attrs[goidc.AttributeHTTPStatusCode] != w.Code {
+		t.Errorf("status code attribute = %v, want %d",
+			endpointSpan.attrs[goidc.AttributeHTTPStatusCode], w.Code)
+	}
+
+	if authnSpan := tracer.spans["client.Authenticate"]; authnSpan != nil && authnSpan.err == nil {
+		t.Error("the client authentication error must be recorded")
+	}
+}
+
+func TestClient_Disabled(t *testing.T) {
+	// Given.
+	jwk := oidctest.PrivatePS256JWK(t, "server_key", goidc.KeyUsageSignature)
+	op, err := provider.New(
+		goidc.ProfileOpenID,
+		"https://example.com",
+		jose.JSONWebKeySet{Keys: []jose.JSONWebKey{jwk}},
+	)
+	if err != nil {
+		t.Fatalf("could not create the provider: %v", err)
+	}
+
+	client, _ := oidctest.NewClient(t)
+	client.Status = goidc.ClientStatusDisabled
+	if err := op.UpdateClient(context.Background(), client); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// When.
+	_, err = op.Client(context.Background(), client.ID)
+
+	// Then.
+	if err == nil {
+		t.Error("disabled clients must not be returned")
+	}
+}
+
+func TestWithEndpointHooks(t *testing.T) {
+	// Given.
+	jwk := oidctest.PrivatePS256JWK(t, "server_key", goidc.KeyUsageSignature)
+	client, secret := oidctest.NewClient(t)
+	var endpoints []string
+	var outcome goidc.EndpointOutcome
+	op, err := provider.New(
+		goidc.ProfileOpenID,
+		"https://example.com",
+		jose.JSONWebKeySet{Keys: []jose.JSONWebKey{jwk}},
+		provider.WithClientCredentialsGrant(),
+		provider.WithStaticClient(client),
+		provider.WithEndpointHooks(
+			func(_ *http.Request, endpoint string) error {
+				endpoints = append(endpoints, endpoint)
+				return nil
+			},
+			func(_ *http.Request, o goidc.EndpointOutcome) {
+				outcome = o
+			},
+		),
+	)
+	if err != nil {
+		t.Fatalf("could not create the provider: %v", err)
+	}
+
+	form := url.Values{
+		"grant_type":    {string(goidc.GrantClientCredentials)},
+		"client_id":     {client.ID},
+		"client_secret": {secret},
+	}
+	req := httptest.NewRequest(http.MethodPost, "/token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+
+	// When.
+	op.Handler().ServeHTTP(w, req)
+
+	// Then.
+	if w.Code != http.StatusOK {
+		t.Fatalf("Code = %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+
+	if len(endpoints) != 1 || endpoints[0] != "POST /token" {
+		t.Errorf("endpoints = %v, want [POST /token]", endpoints)
+	}
+
+	if outcome.Endpoint != "POST /token" {
+		t.Errorf("Endpoint = %s, want POST /token", outcome.Endpoint)
+	}
+
+	if outcome.Client == nil || outcome.Client.ID != client.ID {
+		t.Errorf("Client = %v, want %s", outcome.Client, client.ID)
+	}
+
+	if outcome.StatusCode != http.StatusOK {
+		t.Errorf("StatusCode = %d, want %d", outcome.StatusCode, http.StatusOK)
+	}
+
+	if outcome.Err != nil {
+		t.Errorf("unexpected error: %v", outcome.Err)
+	}
+}
+
+func TestWithEndpointHooks_RequestRejected(t *testing.T) {
+	// Given.
+	jwk := oidctest.PrivatePS256JWK(t, "server_key", goidc.KeyUsageSignature)
+	var outcome goidc.EndpointOutcome
+	op, err := provider.New(
+		goidc.ProfileOpenID,
+		"https://example.com",
+		jose.JSONWebKeySet{Keys: []jose.JSONWebKey{jwk}},
+		provider.WithEndpointHooks(
+			func(*http.Request, string) error {
+				return goidc.NewError(goidc.ErrorCodeSlowDown, "quota exceeded")
+			},
+			func(_ *http.Request, o goidc.EndpointOutcome) {
+				outcome = o
+			},
+		),
+	)
+	if err != nil {
+		t.Fatalf("could not create the provider: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/token", nil)
+	w := httptest.NewRecorder()
+
+	// When.
+	op.Handler().ServeHTTP(w, req)
+
+	// Then.
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Code = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+
+	var oidcErr goidc.Error
+	if !errors.As(outcome.Err, &oidcErr) || oidcErr.Code != goidc.ErrorCodeSlowDown {
+		t.Errorf("Err = %v, want %s", outcome.Err, goidc.ErrorCodeSlowDown)
+	}
+
+	if outcome.StatusCode != http.StatusBadRequest {
+		t.Errorf("StatusCode = %d, want %d", outcome.StatusCode, http.StatusBadRequest)
+	}
+}
+
+func TestWithRequestLimits_BodyTooLarge(t *testing.T) {
+	// Given.
+	jwk := oidctest.PrivatePS256JWK(t, "server_key", goidc.KeyUsageSignature)
+	client, secret := oidctest.NewClient(t)
+	op, err := provider.New(
+		goidc.ProfileOpenID,
+		"https://example.com",
+		jose.JSONWebKeySet{Keys: []jose.JSONWebKey{jwk}},
+		provider.WithClientCredentialsGrant(),
+		provider.WithStaticClient(client),
+		provider.WithRequestLimits(goidc.RequestLimits{MaxBodyBytes: 1 << 20}),
+		provider.WithEndpointRequestLimits("POST /token", goidc.RequestLimits{MaxBodyBytes: 64}),
+	)
+	if err != nil {
+		t.Fatalf("could not create the provider: %v", err)
+	}
+
+	form := url.Values{
+		"grant_type":    {string(goidc.GrantClientCredentials)},
+		"client_id":     {client.ID},
+		"client_secret": {secret},
+		"scope":         {strings.Repeat("scope ", 20)},
+	}
+	req := httptest.NewRequest(http.MethodPost, "/token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+
+	// When.
+	op.Handler().ServeHTTP(w, req)
+
+	// Then.
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("Code = %d, want %d", w.Code, http.StatusRequestEntityTooLarge)
+	}
+
+	var oidcErr goidc.Error
+	if err := json.Unmarshal(w.Body.Bytes(), &oidcErr); err != nil {
+		t.Fatalf("could not parse the response: %v", err)
+	}
+
+	if oidcErr.Code != goidc.ErrorCodeInvalidRequest {
+		t.Errorf("Code = %s, want %s", oidcErr.Code, goidc.ErrorCodeInvalidRequest)
+	}
+}
+
+func TestWithRequestLimits_BodyWithinLimit(t *testing.T) {
+	// Given.
+	jwk := oidctest.PrivatePS256JWK(t, "server_key", goidc.KeyUsageSignature)
+	client, secret := oidctest.NewClient(t)
+	op, err := provider.New(
+		goidc.ProfileOpenID,
+		"https://example.com",
+		jose.JSONWebKeySet{Keys: []jose.JSONWebKey{jwk}},
+		provider.WithClientCredentialsGrant(),
+		provider.WithStaticClient(client),
+		provider.WithRequestLimits(goidc.RequestLimits{
+			MaxBodyBytes: 1 << 20,
+			Timeout:      time.Minute,
+		}),
+	)
+	if err != nil {
+		t.Fatalf("could not create the provider: %v", err)
+	}
+
+	form := url.Values{
+		"grant_type":    {string(goidc.GrantClientCredentials)},
+		"client_id":     {client.ID},
+		"client_secret": {secret},
+	}
+	req := httptest.NewRequest(http.MethodPost, "/token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+
+	// When.
+	op.Handler().ServeHTTP(w, req)
+
+	// Then.
+	if w.Code != http.StatusOK {
+		t.Fatalf("Code = %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+
+	if w.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("Content-Type = %s, want application/json", w.Header().Get("Content-Type"))
+	}
+}
+
+func TestWithRequestLimits_Timeout(t *testing.T) {
+	// Given.
+	jwk := oidctest.PrivatePS256JWK(t, "server_key", goidc.KeyUsageSignature)
+	var outcome goidc.EndpointOutcome
+	op, err := provider.New(
+		goidc.ProfileOpenID,
+		"https://example.com",
+		jose.JSONWebKeySet{Keys: []jose.JSONWebKey{jwk}},
+		provider.WithClientCredentialsGrant(),
+		provider.WithClientStorage(slowClientManager{storage.NewClientManager()}),
+		provider.WithEndpointRequestLimits("POST /token", goidc.RequestLimits{Timeout: 10 * time.Millisecond}),
+		provider.WithEndpointHooks(nil, func(_ *http.Request, o goidc.EndpointOutcome) {
+			outcome = o
+		}),
+	)
+	if err != nil {
+		t.Fatalf("could not create the provider: %v", err)
+	}
+
+	form := url.Values{
+		"grant_type":    {string(goidc.GrantClientCredentials)},
+		"client_id":     {"random_client_id"},
+		"client_secret": {"random_secret"},
+	}
+	req := httptest.NewRequest(http.MethodPost, "/token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+
+	// When.
+	op.Handler().ServeHTTP(w, req)
+
+	// Then.
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Code = %d, want %d: %s", w.Code, http.StatusServiceUnavailable, w.Body.String())
+	}
+
+	var oidcErr goidc.Error
+	if err := json.Unmarshal(w.Body.Bytes(), &oidcErr); err != nil {
+		t.Fatalf("could not parse the response: %v", err)
+	}
+
+	if oidcErr.Code != goidc.ErrorCodeTemporarilyUnavailable {
+		t.Errorf("Code = %s, want %s", oidcErr.Code, goidc.ErrorCodeTemporarilyUnavailable)
+	}
+
+	if outcome.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("StatusCode = %d, want %d", outcome.StatusCode, http.StatusServiceUnavailable)
+	}
+}
+
+// slowClientManager blocks when fetching clients until the request is
+// cancelled.
+type slowClientManager struct {
+	goidc.ClientManager
+}
+
+func (slowClientManager) Client(ctx context.Context, _ string) (*goidc.Client, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+type recordingTracer struct {
+	spans map[string]*recordedSpan
+}
+
+func (t *recordingTracer) Tracer(string) goidc.Tracer {
+	t.spans = map[string]*recordedSpan{}
+	return t
+}
+
+type spanKey struct{}
+
+func (t *recordingTracer) Start(
+	ctx context.Context,
+	name string,
+	attrs ...goidc.Attribute,
+) (
+	context.Context,
+	goidc.Span,
+) {
+	span := &recordedSpan{name: name, attrs: map[string]any{}}
+	if parent, ok := ctx.Value(spanKey{}).(*recordedSpan); ok {
+		span.parent = parent.name
+	}
+	span.SetAttributes(attrs...)
+	t.spans[name] = span
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+type recordedSpan struct {
+	name   string
+	parent string
+	attrs  map[string]any
+	err    error
+	ended  bool
+}
+
+func (s *recordedSpan) SetAttributes(attrs ...goidc.Attribute) {
+	for _, attr := range attrs {
+		s.attrs[attr.Key] = attr.Value
+	}
+}
+
+func (s *recordedSpan) RecordError(err error) {
+	s.err = err
+}
+
+func (s *recordedSpan) End() {
+	s.ended = true
+}
+
+func TestWithMTLSEndpointHost(t *testing.T) {
+	testCases := []struct {
+		name     string
+		target   string
+		wantCode int
+	}{
+		{"token_on_mtls_host", "https://matls.example.com/token", http.StatusOK},
+		{"token_on_main_host", "https://example.com/token", http.StatusNotFound},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// Given.
+			jwk := oidctest.PrivatePS256JWK(t, "server_key", goidc.KeyUsageSignature)
+			client, secret := oidctest.NewClient(t)
+			op, err := provider.New(
+				goidc.ProfileOpenID,
+				"https://example.com",
+				jose.JSONWebKeySet{Keys: []jose.JSONWebKey{jwk}},
+				provider.WithClientCredentialsGrant(),
+				provider.WithStaticClient(client),
+				provider.WithMTLS("https://matls.example.com", goidc.XFCCClientCertFunc(0)),
+				provider.WithMTLSEndpointHost(goidc.EndpointToken, goidc.EndpointHostMTLS),
+			)
+			if err != nil {
+				t.Fatalf("could not create the provider: %v", err)
+			}
+
+			form := url.Values{
+				"grant_type":    {string(goidc.GrantClientCredentials)},
+				"client_id":     {client.ID},
+				"client_secret": {secret},
+			}
+			req := httptest.NewRequest(http.MethodPost, testCase.target, strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			w := httptest.NewRecorder()
+
+			// When.
+			op.Handler().ServeHTTP(w, req)
+
+			// Then.
+			if w.Code != testCase.wantCode {
+				t.Errorf("Code = %d, want %d: %s", w.Code, testCase.wantCode, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestWithMTLSTerminatedByProxy(t *testing.T) {
+	testCases := []struct {
+		name       string
+		clientCert string
+		wantCode   int
+	}{
+		{"request_with_client_cert", "random_cert", http.StatusOK},
+		{"request_without_client_cert", "", http.StatusNotFound},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// Given.
+			jwk := oidctest.PrivatePS256JWK(t, "server_key", goidc.KeyUsageSignature)
+			client, secret := oidctest.NewClient(t)
+			clientCertFunc := func(r *http.Request) (*x509.Certificate, error) {
+				if r.Header.Get("X-Client-Cert") == "" {
+					return nil, errors.New("the client certificate was not forwarded")
+				}
+				return &x509.Certificate{}, nil
+			}
+			op, err := provider.New(
+				goidc.ProfileOpenID,
+				"https://example.com",
+				jose.JSONWebKeySet{Keys: []jose.JSONWebKey{jwk}},
+				provider.WithClientCredentialsGrant(),
+				provider.WithStaticClient(client),
+				provider.WithMTLSTerminatedByProxy("https://matls.example.com", clientCertFunc),
+				provider.WithMTLSEndpointHost(goidc.EndpointToken, goidc.EndpointHostMTLS),
+			)
+			if err != nil {
+				t.Fatalf("could not create the provider: %v", err)
+			}
+
+			form := url.Values{
+				"grant_type":    {string(goidc.GrantClientCredentials)},
+				"client_id":     {client.ID},
+				"client_secret": {secret},
+			}
+			// The proxy forwards the requests with the host of the provider.
+			req := httptest.NewRequest(http.MethodPost, "https://example.com/token", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if testCase.clientCert != "" {
+				req.Header.Set("X-Client-Cert", testCase.clientCert)
+			}
+			w := httptest.NewRecorder()
+
+			// When.
+			op.Handler().ServeHTTP(w, req)
+
+			// Then.
+			if w.Code != testCase.wantCode {
+				t.Errorf("Code = %d, want %d: %s", w.Code, testCase.wantCode, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestWithMeterProvider(t *testing.T) {
+	// Given.
+	jwk := oidctest.PrivatePS256JWK(t, "server_key", goidc.KeyUsageSignature)
+	meter := &recordingMeter{}
+	op, err := provider.New(
+		goidc.ProfileOpenID,
+		"https://example.com",
+		jose.JSONWebKeySet{Keys: []jose.JSONWebKey{jwk}},
+		provider.WithMeterProvider(meter),
+	)
+	if err != nil {
+		t.Fatalf("could not create the provider: %v", err)
+	}
+
+	form := url.Values{
+		"grant_type":    {string(goidc.GrantClientCredentials)},
+		"client_id":     {"random_client_id"},
+		"client_secret": {"random_secret"},
+	}
+	req := httptest.NewRequest(http.MethodPost, "/token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+
+	// When.
+	op.Handler().ServeHTTP(w, req)
+
+	// Then.
+	if failures := meter.measurements[goidc.MetricClientAuthnFailures]; len(failures) != 1 {
+		t.Errorf("client authn failures = %d, want 1", len(failures))
+	}
+
+	durations := meter.measurements[goidc.MetricRequestDuration]
+	if len(durations) != 1 {
+		t.Fatalf("request durations = %d, want 1", len(durations))
+	}
+
+	if durations[0][goidc.AttributeHTTPRoute] != "POST /token" {
+		t.Errorf("route = %v, want POST /token", durations[0][goidc.AttributeHTTPRoute])
+	}
+
+	if durations[0][goidc.AttributeHTTPStatusCode] != w.Code {
+		t.Errorf("status code = %v, want %d", durations[0][goidc.AttributeHTTPStatusCode], w.Code)
+	}
+}
+
+func TestTerminateGrantSession(t *testing.T) {
+	// Given.
+	jwk := oidctest.PrivatePS256JWK(t, "server_key", goidc.KeyUsageSignature)
+	grantManager := storage.NewGrantSessionManager()
+	var events []goidc.TokenEvent
+	op, err := provider.New(
+		goidc.ProfileOpenID,
+		"https://example.com",
+		jose.JSONWebKeySet{Keys: []jose.JSONWebKey{jwk}},
+		provider.WithGrantSessionStorage(grantManager),
+		provider.WithNotifyTokenEventFunc(func(_ *http.Request, event goidc.TokenEvent) {
+			events = append(events, event)
+		}),
+	)
+	if err != nil {
+		t.Fatalf("could not create the provider: %v", err)
+	}
+
+	grantManager.Sessions["random_grant_id"] = &goidc.GrantSession{
+		ID:                 "random_grant_id",
+		ExpiresAtTimestamp: timeutil.TimestampNow() + 60,
+		GrantInfo: goidc.GrantInfo{
+			Subject:  "random_subject",
+			ClientID: "random_client_id",
+		},
+	}
+
+	sessions, err := op.GrantSessions(context.Background(), goidc.SessionFilter{Subject: "random_subject"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(sessions) != 1 {
+		t.Fatalf("len(sessions) = %d, want 1", len(sessions))
+	}
+
+	// When.
+	err = op.TerminateGrantSession(context.Background(), sessions[0].ID)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(grantManager.Sessions) != 0 {
+		t.Error("the grant session must be deleted")
+	}
+
+	want := []goidc.TokenEvent{{
+		Type:     goidc.TokenEventRevocation,
+		GrantID:  "random_grant_id",
+		ClientID: "random_client_id",
+		Subject:  "random_subject",
+	}}
+	if diff := cmp.Diff(events, want); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestUserSessions_NotEnabled(t *testing.T) {
+	// Given.
+	jwk := oidctest.PrivatePS256JWK(t, "server_key", goidc.KeyUsageSignature)
+	op, err := provider.New(
+		goidc.ProfileOpenID,
+		"https://example.com",
+		jose.JSONWebKeySet{Keys: []jose.JSONWebKey{jwk}},
+	)
+	if err != nil {
+		t.Fatalf("could not create the provider: %v", err)
+	}
+
+	// When.
+	_, err = op.UserSessions(context.Background(), goidc.SessionFilter{})
+
+	// Then.
+	if err == nil {
+		t.Error("listing user sessions must fail when they are not enabled")
+	}
+}
+
+// recordingMeter keeps the attributes of every measurement by metric name.
+type recordingMeter struct {
+	measurements map[string][]map[string]any
+}
+
+func (m *recordingMeter) Meter(string) goidc.Meter {
+	m.measurements = map[string][]map[string]any{}
+	return m
+}
+
+func (m *recordingMeter) Counter(name, _ string) goidc.Counter {
+	return recordingInstrument{name: name, meter: m}
+}
+
+func (m *recordingMeter) UpDownCounter(name, _ string) goidc.Counter {
+	return recordingInstrument{name: name, meter: m}
+}
+
+func (m *recordingMeter) Histogram(name, _, _ string) goidc.Histogram {
+	return recordingInstrument{name: name, meter: m}
+}
+
+type recordingInstrument struct {
+	name  string
+	meter *recordingMeter
+}
+
+func (i recordingInstrument) Add(_ context.Context, _ int64, attrs ...goidc.Attribute) {
+	i.record(attrs)
+}
+
+func (i recordingInstrument) Record(_ context.Context, _ float64, attrs ...goidc.Attribute) {
+	i.record(attrs)
+}
+
+func (i recordingInstrument) record(attrs []goidc.Attribute) {
+	values := map[string]any{}
+	for _, attr := range attrs {
+		values[attr.Key] = attr.Value
+	}
+	i.meter.measurements[i.name] = append(i.meter.measurements[i.name], values)
+}
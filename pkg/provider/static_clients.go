@@ -0,0 +1,107 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/luikyv/go-oidc/internal/dcr"
+	"github.com/luikyv/go-oidc/internal/oidc"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+// loadStaticClients reads the clients from the static client paths, validates
+// them and adds them to the static clients.
+func (p Provider) loadStaticClients() error {
+	if len(p.config.StaticClientPaths) == 0 {
+		return nil
+	}
+
+	var clients []*goidc.Client
+	for _, path := range p.config.StaticClientPaths {
+		pathClients, err := readClients(path)
+		if err != nil {
+			return err
+		}
+		clients = append(clients, pathClients...)
+	}
+
+	ctx := oidc.NewContext(nil, nil, p.config)
+	ctx.SetContext(context.Background())
+	for _, client := range clients {
+		if client.ID == "" {
+			return errors.New("static clients must have an ID")
+		}
+
+		if slices.ContainsFunc(p.config.StaticClients, func(c *goidc.Client) bool {
+			return c.ID == client.ID
+		}) {
+			return fmt.Errorf("the static client %s is defined more than once", client.ID)
+		}
+
+		if err := dcr.Validate(ctx, &client.ClientMetaInfo); err != nil {
+			return fmt.Errorf("the static client %s is not valid: %w", client.ID, err)
+		}
+
+		p.config.StaticClients = append(p.config.StaticClients, client)
+	}
+
+	return nil
+}
+
+// readClients reads the clients from a file or from all the JSON files of a
+// directory, in lexical order.
+func readClients(path string) ([]*goidc.Client, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read the static clients: %w", err)
+	}
+
+	if !info.IsDir() {
+		return readClientsFile(path)
+	}
+
+	files, err := filepath.Glob(filepath.Join(path, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("could not list the static client files: %w", err)
+	}
+
+	var clients []*goidc.Client
+	for _, file := range files {
+		fileClients, err := readClientsFile(file)
+		if err != nil {
+			return nil, err
+		}
+		clients = append(clients, fileClients...)
+	}
+	return clients, nil
+}
+
+// readClientsFile reads a file holding either a single client or an array of
+// clients.
+func readClientsFile(file string) ([]*goidc.Client, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("could not read the static clients file %s: %w", file, err)
+	}
+
+	data = bytes.TrimSpace(data)
+	if bytes.HasPrefix(data, []byte("[")) {
+		var clients []*goidc.Client
+		if err := json.Unmarshal(data, &clients); err != nil {
+			return nil, fmt.Errorf("could not parse the static clients file %s: %w", file, err)
+		}
+		return clients, nil
+	}
+
+	var client goidc.Client
+	if err := json.Unmarshal(data, &client); err != nil {
+		return nil, fmt.Errorf("could not parse the static clients file %s: %w", file, err)
+	}
+	return []*goidc.Client{&client}, nil
+}
@@ -0,0 +1,122 @@
+package provider_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/luikyv/go-oidc/internal/oidctest"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+	"github.com/luikyv/go-oidc/pkg/provider"
+)
+
+func TestWithStaticClientsFromFile(t *testing.T) {
+	// Given.
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "clients.json"), `[{
+		"client_id": "client_one",
+		"hashed_secret": "$2a$10$random",
+		"token_endpoint_auth_method": "client_secret_post",
+		"redirect_uris": ["https://rp.example.com/callback"],
+		"grant_types": ["authorization_code"],
+		"response_types": ["code"],
+		"scope": "openid"
+	}]`)
+	writeFile(t, filepath.Join(dir, "client_two.json"), `{
+		"client_id": "client_two",
+		"token_endpoint_auth_method": "client_secret_post",
+		"grant_types": ["client_credentials"],
+		"response_types": [],
+		"scope": "openid"
+	}`)
+	writeFile(t, filepath.Join(dir, "README.md"), "not a client")
+
+	// When.
+	op, err := newStaticClientsProvider(t, dir)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("could not create the provider: %v", err)
+	}
+
+	for _, id := range []string{"client_one", "client_two"} {
+		client, err := op.Client(context.Background(), id)
+		if err != nil {
+			t.Fatalf("the static client %s was not loaded: %v", id, err)
+		}
+
+		if client.TokenAuthnMethod != goidc.ClientAuthnSecretPost {
+			t.Errorf("TokenAuthnMethod = %s, want %s", client.TokenAuthnMethod, goidc.ClientAuthnSecretPost)
+		}
+	}
+}
+
+func TestWithStaticClientsFromFile_InvalidClient(t *testing.T) {
+	// Given.
+	file := filepath.Join(t.TempDir(), "client.json")
+	writeFile(t, file, `{
+		"client_id": "random_client",
+		"token_endpoint_auth_method": "client_secret_post",
+		"grant_types": ["implicit"],
+		"response_types": ["id_token"],
+		"scope": "openid"
+	}`)
+
+	// When.
+	_, err := newStaticClientsProvider(t, file)
+
+	// Then.
+	if err == nil {
+		t.Fatal("clients with grant types not supported must be rejected")
+	}
+}
+
+func TestDeleteClient_StaticClient(t *testing.T) {
+	// Given.
+	file := filepath.Join(t.TempDir(), "client.json")
+	writeFile(t, file, `{
+		"client_id": "random_client",
+		"token_endpoint_auth_method": "client_secret_post",
+		"grant_types": ["client_credentials"],
+		"response_types": [],
+		"scope": "openid"
+	}`)
+	op, err := newStaticClientsProvider(t, file)
+	if err != nil {
+		t.Fatalf("could not create the provider: %v", err)
+	}
+
+	// When.
+	err = op.DeleteClient(context.Background(), "random_client")
+
+	// Then.
+	if err == nil {
+		t.Error("static clients must not be deleted")
+	}
+}
+
+func newStaticClientsProvider(t *testing.T, path string) (*provider.Provider, error) {
+	t.Helper()
+
+	jwk := oidctest.PrivatePS256JWK(t, "server_key", goidc.KeyUsageSignature)
+	op, err := provider.New(
+		goidc.ProfileOpenID,
+		"https://example.com",
+		jose.JSONWebKeySet{Keys: []jose.JSONWebKey{jwk}},
+		provider.WithAuthorizationCodeGrant(),
+		provider.WithClientCredentialsGrant(),
+		provider.WithTokenAuthnMethods(goidc.ClientAuthnSecretPost),
+		provider.WithStaticClientsFromFile(path),
+	)
+	return &op, err
+}
+
+func writeFile(t *testing.T, name, content string) {
+	t.Helper()
+
+	if err := os.WriteFile(name, []byte(content), 0o600); err != nil {
+		t.Fatalf("could not write the file %s: %v", name, err)
+	}
+}
@@ -0,0 +1,98 @@
+package provider
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+// TLSOptions defines how the provider serves requests over TLS.
+type TLSOptions struct {
+	// CertFile and KeyFile are the paths to the server's certificate and
+	// private key.
+	CertFile string
+	KeyFile  string
+	// MinVersion is the minimum TLS version accepted.
+	// If not informed, TLS 1.2 is used.
+	MinVersion uint16
+	// CipherSuites are the TLS 1.2 cipher suites accepted.
+	// For the FAPI 2.0 profile, it defaults to [goidc.FAPIAllowedCipherSuites]
+	// and only suites in that list can be informed.
+	CipherSuites []uint16
+	// CurvePreferences are the elliptic curves used in ECDHE handshakes in
+	// order of preference.
+	CurvePreferences []tls.CurveID
+	// NextProtos are the protocols advertised during ALPN, e.g. "h2".
+	NextProtos []string
+	// ClientCAs and ClientAuth configure how client certificates are requested
+	// and verified during the handshake.
+	ClientCAs  *x509.CertPool
+	ClientAuth tls.ClientAuthType
+}
+
+// RunTLS starts an HTTPS server listening on the address informed.
+// The TLS options are validated against the provider's profile before the
+// server starts.
+func (p Provider) RunTLS(
+	address string,
+	opts TLSOptions,
+	middlewares ...goidc.MiddlewareFunc,
+) error {
+	tlsConfig, err := p.tlsConfig(opts)
+	if err != nil {
+		return err
+	}
+
+	handler := p.Handler()
+	for _, middleware := range middlewares {
+		handler = middleware(handler)
+	}
+
+	server := &http.Server{
+		Addr:      address,
+		Handler:   handler,
+		TLSConfig: tlsConfig,
+	}
+	return server.ListenAndServeTLS(opts.CertFile, opts.KeyFile)
+}
+
+func (p Provider) tlsConfig(opts TLSOptions) (*tls.Config, error) {
+	config := &tls.Config{
+		MinVersion:       nonZeroOrDefault(opts.MinVersion, tls.VersionTLS12),
+		CipherSuites:     opts.CipherSuites,
+		CurvePreferences: opts.CurvePreferences,
+		NextProtos:       opts.NextProtos,
+		ClientCAs:        opts.ClientCAs,
+		ClientAuth:       opts.ClientAuth,
+	}
+
+	if config.MinVersion < tls.VersionTLS12 {
+		return nil, errors.New("the minimum TLS version must be at least TLS 1.2")
+	}
+
+	// The certificates are read from the requests forwarded by the proxy, so
+	// the ones presented during the handshake would be ignored.
+	if p.config.MTLSIsTerminatedByProxy && config.ClientAuth != tls.NoClientCert {
+		return nil, errors.New("client certificates must not be requested when mutual TLS is terminated by a proxy")
+	}
+
+	if p.config.Profile == goidc.ProfileFAPI2 {
+		if config.CipherSuites == nil {
+			config.CipherSuites = goidc.FAPIAllowedCipherSuites
+		}
+
+		for _, suite := range config.CipherSuites {
+			if !slices.Contains(goidc.FAPIAllowedCipherSuites, suite) {
+				return nil, fmt.Errorf("the cipher suite %s is not allowed by FAPI 2.0",
+					tls.CipherSuiteName(suite))
+			}
+		}
+	}
+
+	return config, nil
+}
@@ -0,0 +1,83 @@
+package provider
+
+import (
+	"crypto/tls"
+	"slices"
+	"testing"
+
+	"github.com/luikyv/go-oidc/internal/oidc"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+func TestTLSConfig_FAPI2(t *testing.T) {
+	// Given.
+	p := Provider{config: &oidc.Configuration{Profile: goidc.ProfileFAPI2}}
+
+	// When.
+	config, err := p.tlsConfig(TLSOptions{
+		CurvePreferences: []tls.CurveID{tls.X25519},
+		NextProtos:       []string{"h2"},
+	})
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if config.MinVersion != tls.VersionTLS12 {
+		t.Errorf("MinVersion = %d, want %d", config.MinVersion, tls.VersionTLS12)
+	}
+
+	if !slices.Equal(config.CipherSuites, goidc.FAPIAllowedCipherSuites) {
+		t.Errorf("CipherSuites = %v, want %v", config.CipherSuites, goidc.FAPIAllowedCipherSuites)
+	}
+
+	if !slices.Equal(config.NextProtos, []string{"h2"}) {
+		t.Errorf("NextProtos = %v, want [h2]", config.NextProtos)
+	}
+}
+
+func TestTLSConfig_FAPI2CipherSuiteNotAllowed(t *testing.T) {
+	// Given.
+	p := Provider{config: &oidc.Configuration{Profile: goidc.ProfileFAPI2}}
+
+	// When.
+	_, err := p.tlsConfig(TLSOptions{
+		CipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256},
+	})
+
+	// Then.
+	if err == nil {
+		t.Fatal("cipher suites not allowed by fapi must be rejected")
+	}
+}
+
+func TestTLSConfig_MinVersionTooLow(t *testing.T) {
+	// Given.
+	p := Provider{config: &oidc.Configuration{Profile: goidc.ProfileOpenID}}
+
+	// When.
+	_, err := p.tlsConfig(TLSOptions{MinVersion: tls.VersionTLS11})
+
+	// Then.
+	if err == nil {
+		t.Fatal("versions older than tls 1.2 must be rejected")
+	}
+}
+
+func TestTLSConfig_MTLSTerminatedByProxy(t *testing.T) {
+	// Given.
+	p := Provider{config: &oidc.Configuration{
+		Profile:                 goidc.ProfileOpenID,
+		MTLSIsEnabled:           true,
+		MTLSIsTerminatedByProxy: true,
+	}}
+
+	// When.
+	_, err := p.tlsConfig(TLSOptions{ClientAuth: tls.VerifyClientCertIfGiven})
+
+	// Then.
+	if err == nil {
+		t.Fatal("client certificates must not be requested when the proxy terminates mutual tls")
+	}
+}
@@ -0,0 +1,113 @@
+package provider
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/luikyv/go-oidc/internal/oidc"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+// instrumentedRouter wraps the handlers registered so each request to an
+// endpoint is traced with a span named after the endpoint's pattern and has
+// its duration recorded.
+type instrumentedRouter struct {
+	oidc.Router
+	tracer  goidc.Tracer
+	metrics *oidc.Metrics
+}
+
+func (r instrumentedRouter) HandleFunc(
+	pattern string,
+	handler func(http.ResponseWriter, *http.Request),
+) {
+	r.Router.HandleFunc(pattern, func(w http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		attrs := []goidc.Attribute{
+			{Key: goidc.AttributeHTTPMethod, Value: req.Method},
+			{Key: goidc.AttributeHTTPRoute, Value: pattern},
+		}
+
+		ctx := req.Context()
+		var span goidc.Span
+		if r.tracer != nil {
+			ctx, span = r.tracer.Start(ctx, pattern, append(attrs,
+				goidc.Attribute{Key: goidc.AttributeURLPath, Value: req.URL.Path})...)
+		}
+
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		handler(sw, req.WithContext(ctx))
+
+		statusAttr := goidc.Attribute{Key: goidc.AttributeHTTPStatusCode, Value: sw.status}
+		if span != nil {
+			span.SetAttributes(statusAttr)
+			span.End()
+		}
+		r.metrics.RecordRequestDuration(ctx, time.Since(start), append(attrs, statusAttr)...)
+	})
+}
+
+// hookedRouter wraps the handlers registered so the endpoint hooks run around
+// each request.
+type hookedRouter struct {
+	oidc.Router
+	config *oidc.Configuration
+}
+
+func (r hookedRouter) HandleFunc(
+	pattern string,
+	handler func(http.ResponseWriter, *http.Request),
+) {
+	r.Router.HandleFunc(pattern, func(w http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		ctx, info := oidc.WithEndpointInfo(req.Context())
+		req = req.WithContext(ctx)
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+
+		if r.config.BeforeRequestFunc != nil {
+			if err := r.config.BeforeRequestFunc(req, pattern); err != nil {
+				oidc.NewContext(sw, req, r.config).WriteError(err)
+				r.afterResponse(req, pattern, info, sw.status, start)
+				return
+			}
+		}
+
+		handler(sw, req)
+		r.afterResponse(req, pattern, info, sw.status, start)
+	})
+}
+
+func (r hookedRouter) afterResponse(
+	req *http.Request,
+	pattern string,
+	info *oidc.EndpointInfo,
+	status int,
+	start time.Time,
+) {
+	if r.config.AfterResponseFunc == nil {
+		return
+	}
+
+	r.config.AfterResponseFunc(req, goidc.EndpointOutcome{
+		Endpoint:   pattern,
+		Client:     info.Client,
+		StatusCode: status,
+		Err:        info.Err,
+		Duration:   time.Since(start),
+	})
+}
+
+// statusWriter records the status code written to the response.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
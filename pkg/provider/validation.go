@@ -24,6 +24,21 @@ func validateJWKS(config *oidc.Configuration) error {
 	return nil
 }
 
+func validateAuthzCode(config *oidc.Configuration) error {
+	if config.Profile == goidc.ProfileFAPI2 {
+		return validateFAPI2AuthzCodeLifetime(config)
+	}
+	return nil
+}
+
+func validateDCR(config *oidc.Configuration) error {
+	if config.DCROpenRegistrationIsEnabled &&
+		(config.ValidateInitialAccessTokenFunc != nil || config.DCRInitialAccessTokenIsRequired) {
+		return errors.New("open registration cannot be combined with the validation of initial access tokens")
+	}
+	return nil
+}
+
 func validateSigKeys(config *oidc.Configuration) error {
 
 	for _, keyAlg := range slices.Concat(
@@ -80,6 +95,23 @@ func validateJAREnc(config *oidc.Configuration) error {
 	return nil
 }
 
+func validateJAREncKeys(config *oidc.Configuration) error {
+	for _, keyID := range config.JAREncKeyIDs {
+		keys := config.PrivateJWKS.Key(keyID)
+		if len(keys) == 0 || keys[0].Use != string(goidc.KeyUsageEncryption) {
+			return fmt.Errorf("the request object encryption key %s has no corresponding encryption key in the JWKS", keyID)
+		}
+	}
+
+	for _, retiredKey := range config.JARRetiredEncKeys {
+		if retiredKey.Key.KeyID == "" || !retiredKey.Key.Valid() || retiredKey.Key.IsPublic() {
+			return errors.New("the retired request object encryption keys must be valid private keys with an ID")
+		}
+	}
+
+	return nil
+}
+
 func validateJARMEnc(config *oidc.Configuration) error {
 	if config.JARMEncIsEnabled && !config.JARMIsEnabled {
 		return errors.New("JARM must be enabled if JARM encryption is enabled")
@@ -98,6 +130,35 @@ func validateTokenBinding(config *oidc.Configuration) error {
 	return nil
 }
 
+func validateMTLSEndpointHosts(config *oidc.Configuration) error {
+	if len(config.MTLSEndpointHosts) != 0 && !config.MTLSIsEnabled {
+		return errors.New("mutual TLS must be enabled to define the hosts of the endpoints")
+	}
+
+	return nil
+}
+
+func validateTokenEncryptionKey(config *oidc.Configuration) error {
+	if config.TokenEncryptionKey == nil {
+		return nil
+	}
+
+	switch len(config.TokenEncryptionKey) {
+	case 16, 24, 32:
+		return nil
+	default:
+		return errors.New("the token encryption key must have 16, 24 or 32 bytes")
+	}
+}
+
+func validateClientTokenMetadata(config *oidc.Configuration) error {
+	if config.ClientTokenMetadataIsEnabled && config.ClientTokenMaxLifetimeSecs <= 0 {
+		return errors.New("the maximum client access token lifetime must be positive")
+	}
+
+	return nil
+}
+
 func runValidations(
 	config *oidc.Configuration,
 	validators ...func(*oidc.Configuration) error,
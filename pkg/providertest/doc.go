@@ -0,0 +1,35 @@
+// Package providertest runs an OpenID Provider in memory so relying parties
+// can be integration tested against a real server.
+//
+// [NewServer] starts an [httptest.Server] with generated keys, in memory
+// storage and a policy that authenticates every user as [Server.Subject] and
+// grants all the scopes requested. Clients, authorization codes and tokens can
+// then be minted with a couple of calls.
+//
+//	func TestLogin(t *testing.T) {
+//		server := providertest.NewServer(t)
+//		client := server.NewClient(t)
+//
+//		tokenResp := server.Tokens(t, client)
+//		// Use tokenResp.AccessToken against the code under test.
+//	}
+//
+// A [PolicySimulator] drives a single authentication policy through its
+// steps, posting forms to the callback endpoint as the pages rendered by the
+// policy would, and exposes the resulting redirect and tokens.
+//
+// The storage doubles, e.g. [NewGrantSessionManager], wrap the managers of
+// the provider so their operations can be programmed to fail, to be slow or to
+// silently drop writes.
+//
+//	grantManager := providertest.NewGrantSessionManager(nil)
+//	server := providertest.NewServer(t, provider.WithGrantSessionStorage(grantManager))
+//	grantManager.Inject("Save", providertest.Fault{Err: errors.New("unavailable")})
+//
+// [BenchmarkTokenEndpoint] benchmarks the token grants against a server, e.g.
+// with different storage backends, and [Load] generates concurrent load
+// reporting latency percentiles and throughput.
+//
+// The provider can be customized with the same options accepted by
+// [provider.New]. They are applied after the defaults of this package.
+package providertest
@@ -0,0 +1,163 @@
+package providertest
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/luikyv/go-oidc/pkg/provider"
+)
+
+// LoadOptions defines how much load [Load] generates.
+type LoadOptions struct {
+	// Concurrency is the number of workers calling the operation at the same
+	// time. It defaults to 1.
+	Concurrency int
+	// Requests is the total number of calls made. It defaults to 100.
+	Requests int
+}
+
+// LoadResult summarizes the calls made by [Load].
+type LoadResult struct {
+	Requests int
+	Errors   int
+	// FirstErr is the first error returned by the operation, if any.
+	FirstErr error
+	Elapsed  time.Duration
+	// Latencies are the durations of the calls in increasing order.
+	Latencies []time.Duration
+}
+
+// Percentile returns the latency below which p percent of the calls finished,
+// e.g. Percentile(99) for the p99 latency.
+func (r LoadResult) Percentile(p float64) time.Duration {
+	if len(r.Latencies) == 0 {
+		return 0
+	}
+
+	i := int(p / 100 * float64(len(r.Latencies)))
+	return r.Latencies[min(max(i, 0), len(r.Latencies)-1)]
+}
+
+// Throughput returns the number of calls completed per second.
+func (r LoadResult) Throughput() float64 {
+	if r.Elapsed == 0 {
+		return 0
+	}
+	return float64(r.Requests) / r.Elapsed.Seconds()
+}
+
+// Load calls op concurrently as defined by opts and records the latency of
+// each call.
+//
+//	server := providertest.NewServer(t)
+//	client := server.NewClient(t)
+//	result := providertest.Load(providertest.LoadOptions{Concurrency: 10, Requests: 1000},
+//		func(ctx context.Context) error {
+//			_, err := client.ClientCredentials(ctx)
+//			return err
+//		})
+//	t.Logf("p99: %s, %.0f req/s", result.Percentile(99), result.Throughput())
+func Load(opts LoadOptions, op func(context.Context) error) LoadResult {
+	concurrency := max(opts.Concurrency, 1)
+	requests := opts.Requests
+	if requests <= 0 {
+		requests = 100
+	}
+
+	calls := make(chan struct{}, requests)
+	for range requests {
+		calls <- struct{}{}
+	}
+	close(calls)
+
+	var mu sync.Mutex
+	result := LoadResult{Requests: requests}
+	var wg sync.WaitGroup
+	start := time.Now()
+	for range concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range calls {
+				callStart := time.Now()
+				err := op(context.Background())
+				latency := time.Since(callStart)
+
+				mu.Lock()
+				result.Latencies = append(result.Latencies, latency)
+				if err != nil {
+					result.Errors++
+					if result.FirstErr == nil {
+						result.FirstErr = err
+					}
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	result.Elapsed = time.Since(start)
+
+	slices.Sort(result.Latencies)
+	return result
+}
+
+// BenchmarkTokenEndpoint benchmarks the client credentials, authorization code
+// and refresh token grants against a server created with the options
+// informed. Storage backends can be compared by running it with different
+// storage options.
+//
+//	func BenchmarkTokenEndpoint(b *testing.B) {
+//		providertest.BenchmarkTokenEndpoint(b, provider.WithGrantSessionStorage(myStorage))
+//	}
+//
+// The authorization code benchmark includes the request to the authorization
+// endpoint, since each code can be exchanged only once, and does not run in
+// parallel.
+func BenchmarkTokenEndpoint(b *testing.B, opts ...provider.ProviderOption) {
+	server := NewServer(b, opts...)
+	client := server.NewClient(b)
+
+	b.Run("client_credentials", func(b *testing.B) {
+		b.ReportAllocs()
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				if _, err := client.ClientCredentials(context.Background()); err != nil {
+					b.Error(err)
+					return
+				}
+			}
+		})
+	})
+
+	b.Run("authorization_code", func(b *testing.B) {
+		b.ReportAllocs()
+		b.ResetTimer()
+		// The helpers of the server stop the benchmark on failures, which
+		// can only be done from its goroutine, so this one is sequential.
+		for range b.N {
+			authReq, code := server.AuthorizationCode(b, client)
+			if _, err := client.Exchange(context.Background(), authReq, code); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("refresh_token", func(b *testing.B) {
+		tokenResp := server.Tokens(b, client)
+		b.ReportAllocs()
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				if _, err := client.Refresh(context.Background(), tokenResp.RefreshToken); err != nil {
+					b.Error(err)
+					return
+				}
+			}
+		})
+	})
+}
@@ -0,0 +1,54 @@
+package providertest_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/luikyv/go-oidc/pkg/provider"
+	"github.com/luikyv/go-oidc/pkg/providertest"
+)
+
+func TestLoad(t *testing.T) {
+	// Given.
+	server := providertest.NewServer(t)
+	client := server.NewClient(t)
+
+	// When.
+	result := providertest.Load(providertest.LoadOptions{Concurrency: 4, Requests: 20},
+		func(ctx context.Context) error {
+			_, err := client.ClientCredentials(ctx)
+			return err
+		})
+
+	// Then.
+	if result.Errors != 0 {
+		t.Fatalf("unexpected error: %v", result.FirstErr)
+	}
+
+	if len(result.Latencies) != 20 {
+		t.Errorf("len(result.Latencies) = %d, want 20", len(result.Latencies))
+	}
+
+	if result.Percentile(50) > result.Percentile(99) {
+		t.Errorf("p50 = %s cannot be greater than p99 = %s", result.Percentile(50), result.Percentile(99))
+	}
+
+	if result.Throughput() <= 0 {
+		t.Errorf("Throughput() = %f, want a positive value", result.Throughput())
+	}
+}
+
+func BenchmarkTokenEndpoint(b *testing.B) {
+	providertest.BenchmarkTokenEndpoint(b)
+}
+
+func BenchmarkTokenEndpoint_AsyncGrantSessions(b *testing.B) {
+	providertest.BenchmarkTokenEndpoint(b, provider.WithAsyncGrantSessionStorage(1000, nil))
+}
+
+func BenchmarkTokenEndpoint_SlowStorage(b *testing.B) {
+	grantManager := providertest.NewGrantSessionManager(nil)
+	grantManager.Inject("Save", providertest.Fault{Latency: time.Millisecond})
+	providertest.BenchmarkTokenEndpoint(b, provider.WithGrantSessionStorage(grantManager))
+}
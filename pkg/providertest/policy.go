@@ -0,0 +1,209 @@
+package providertest
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/luikyv/go-oidc/pkg/goidc"
+	"github.com/luikyv/go-oidc/pkg/provider"
+	"github.com/luikyv/go-oidc/pkg/rp"
+)
+
+// PolicySimulator drives an authentication policy through its steps the way a
+// browser would, so the policy can be tested without rendering its pages.
+//
+//	sim := providertest.NewPolicySimulator(t, policy)
+//	sim.Start(t, nil)
+//	// The policy rendered a login form.
+//	sim.Submit(t, url.Values{"username": {"random_user"}, "password": {"random_password"}})
+//	tokenResp := sim.Tokens(t)
+type PolicySimulator struct {
+	Server *Server
+	Client rp.Client
+	// Response is the last response of the provider to the user agent. Its
+	// body was already read into Body.
+	Response *http.Response
+	Body     string
+
+	httpClient *http.Client
+	authReq    rp.AuthorizationRequest
+
+	mu      sync.Mutex
+	session *goidc.AuthnSession
+	status  goidc.AuthnStatus
+	err     error
+}
+
+// NewPolicySimulator starts a server whose only policy is the one informed and
+// registers a client for it. If the policy is not set up for a request, the
+// authorization fails.
+func NewPolicySimulator(
+	t testing.TB,
+	policy goidc.AuthnPolicy,
+	opts ...provider.ProviderOption,
+) *PolicySimulator {
+	t.Helper()
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatalf("could not create the cookie jar: %v", err)
+	}
+
+	sim := &PolicySimulator{}
+	sim.Server = newServer(t)
+	sim.Server.start(t, append(opts, provider.WithPolicy(sim.wrap(policy))))
+	sim.Client = sim.Server.NewClient(t)
+
+	// The user agent keeps cookies between steps, but the redirections must
+	// be inspected, so they are not followed.
+	sim.httpClient = &http.Client{
+		Transport: sim.Server.Client().Transport,
+		Jar:       jar,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	return sim
+}
+
+// Start sends the user agent to the authorization endpoint. params are added
+// to the authorization request, e.g. "prompt" or "acr_values".
+func (sim *PolicySimulator) Start(t testing.TB, params url.Values) {
+	t.Helper()
+
+	sim.authReq = rp.NewAuthorizationRequest()
+	for param, values := range params {
+		sim.authReq.Params[param] = values
+	}
+
+	authURL, err := sim.Client.AuthorizationURL(context.Background(), sim.authReq)
+	if err != nil {
+		t.Fatalf("could not build the authorization url: %v", err)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, authURL, nil)
+	sim.do(t, req)
+}
+
+// Submit posts the form to the callback endpoint of the authentication session
+// in progress, as a page rendered by the policy would.
+func (sim *PolicySimulator) Submit(t testing.TB, form url.Values) {
+	t.Helper()
+
+	session := sim.Session()
+	if session == nil {
+		t.Fatal("no authentication was started")
+	}
+
+	callbackURL := sim.Client.Provider.AuthorizationEndpoint + "/" + session.CallbackID
+	req, _ := http.NewRequest(http.MethodPost, callbackURL, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	sim.do(t, req)
+}
+
+// Session returns a copy of the authentication session as last seen by the
+// policy, or nil if the policy was not executed.
+func (sim *PolicySimulator) Session() *goidc.AuthnSession {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+
+	if sim.session == nil {
+		return nil
+	}
+	session := *sim.session
+	return &session
+}
+
+// Status returns the status and the error the policy returned when it was
+// last executed.
+func (sim *PolicySimulator) Status() (goidc.AuthnStatus, error) {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+
+	return sim.status, sim.err
+}
+
+// RedirectParams returns the parameters the provider sent to the client's
+// redirect URI in the last response.
+func (sim *PolicySimulator) RedirectParams(t testing.TB) url.Values {
+	t.Helper()
+
+	redirectURL, err := url.Parse(sim.Response.Header.Get("Location"))
+	if err != nil || !strings.HasPrefix(redirectURL.String(), RedirectURI) {
+		t.Fatalf("the provider did not redirect to the client, status %d: %s",
+			sim.Response.StatusCode, sim.Body)
+	}
+
+	return redirectURL.Query()
+}
+
+// Code returns the authorization code the provider redirected the user agent
+// with. If the provider redirected an error, it is reported as a failure.
+func (sim *PolicySimulator) Code(t testing.TB) string {
+	t.Helper()
+
+	code, err := sim.Client.ParseAuthorizationResponse(sim.authReq, sim.RedirectParams(t))
+	if err != nil {
+		t.Fatalf("the authorization failed: %v", err)
+	}
+	return code
+}
+
+// Tokens exchanges the authorization code issued at the end of the flow.
+func (sim *PolicySimulator) Tokens(t testing.TB) rp.TokenResponse {
+	t.Helper()
+
+	tokenResp, err := sim.Client.Exchange(context.Background(), sim.authReq, sim.Code(t))
+	if err != nil {
+		t.Fatalf("could not exchange the authorization code: %v", err)
+	}
+	return tokenResp
+}
+
+func (sim *PolicySimulator) do(t testing.TB, req *http.Request) {
+	t.Helper()
+
+	resp, err := sim.httpClient.Do(req)
+	if err != nil {
+		t.Fatalf("could not call the provider: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("could not read the response: %v", err)
+	}
+
+	sim.Response = resp
+	sim.Body = string(body)
+}
+
+// wrap records the session and the outcome every time the policy executes.
+func (sim *PolicySimulator) wrap(policy goidc.AuthnPolicy) goidc.AuthnPolicy {
+	authenticate := policy.Authenticate
+	policy.Authenticate = func(
+		w http.ResponseWriter,
+		r *http.Request,
+		session *goidc.AuthnSession,
+	) (
+		goidc.AuthnStatus,
+		error,
+	) {
+		status, err := authenticate(w, r, session)
+
+		sim.mu.Lock()
+		defer sim.mu.Unlock()
+		sim.session = session
+		sim.status = status
+		sim.err = err
+		return status, err
+	}
+	return policy
+}
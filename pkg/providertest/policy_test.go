@@ -0,0 +1,83 @@
+package providertest_test
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/luikyv/go-oidc/pkg/goidc"
+	"github.com/luikyv/go-oidc/pkg/providertest"
+)
+
+func TestPolicySimulator(t *testing.T) {
+	// Given.
+	sim := providertest.NewPolicySimulator(t, loginPolicy())
+
+	// When.
+	sim.Start(t, nil)
+
+	// Then.
+	if status, _ := sim.Status(); status != goidc.StatusInProgress {
+		t.Fatalf("status = %s, want %s", status, goidc.StatusInProgress)
+	}
+
+	if sim.Body != "login form" {
+		t.Errorf("body = %s, want login form", sim.Body)
+	}
+
+	// When.
+	sim.Submit(t, url.Values{"username": {"random_user"}})
+
+	// Then.
+	if status, _ := sim.Status(); status != goidc.StatusSuccess {
+		t.Fatalf("status = %s, want %s", status, goidc.StatusSuccess)
+	}
+
+	tokenResp := sim.Tokens(t)
+	if tokenResp.IDTokenClaims[goidc.ClaimSubject] != "random_user" {
+		t.Errorf("sub = %v, want random_user", tokenResp.IDTokenClaims[goidc.ClaimSubject])
+	}
+}
+
+func TestPolicySimulator_Failure(t *testing.T) {
+	// Given.
+	sim := providertest.NewPolicySimulator(t, loginPolicy())
+	sim.Start(t, nil)
+
+	// When.
+	sim.Submit(t, url.Values{"username": {"blocked_user"}})
+
+	// Then.
+	if status, _ := sim.Status(); status != goidc.StatusFailure {
+		t.Fatalf("status = %s, want %s", status, goidc.StatusFailure)
+	}
+
+	params := sim.RedirectParams(t)
+	if params.Get("error") != string(goidc.ErrorCodeAccessDenied) {
+		t.Errorf("error = %s, want %s", params.Get("error"), goidc.ErrorCodeAccessDenied)
+	}
+}
+
+func loginPolicy() goidc.AuthnPolicy {
+	return goidc.NewPolicy(
+		"login",
+		func(*http.Request, *goidc.Client, *goidc.AuthnSession) bool {
+			return true
+		},
+		func(w http.ResponseWriter, r *http.Request, as *goidc.AuthnSession) (goidc.AuthnStatus, error) {
+			username := r.PostFormValue("username")
+			if username == "" {
+				_, _ = w.Write([]byte("login form"))
+				return goidc.StatusInProgress, nil
+			}
+
+			if username == "blocked_user" {
+				return goidc.StatusFailure, goidc.NewError(goidc.ErrorCodeAccessDenied, "the user is blocked")
+			}
+
+			as.SetUserID(username)
+			as.GrantScopes(as.Scopes)
+			return goidc.StatusSuccess, nil
+		},
+	)
+}
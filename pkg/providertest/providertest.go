@@ -0,0 +1,243 @@
+package providertest
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/luikyv/go-oidc/internal/strutil"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+	"github.com/luikyv/go-oidc/pkg/provider"
+	"github.com/luikyv/go-oidc/pkg/rp"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// DefaultSubject is the user authenticated by the policy of the server
+	// unless [Server.Subject] is changed.
+	DefaultSubject = "random_subject"
+	// RedirectURI is the redirect URI registered for the clients created with
+	// [Server.NewClient]. The server never calls it.
+	RedirectURI = "https://rp.example.com/callback"
+
+	serverKeyID              = "providertest_key"
+	refreshTokenLifetimeSecs = 600
+	clientIDLength           = 24
+	clientSecretLength       = 32
+)
+
+// Server is an OpenID Provider running on an [httptest.Server].
+type Server struct {
+	*httptest.Server
+	Provider provider.Provider
+	// Subject is the user authenticated in every authorization request.
+	Subject string
+	jwk     jose.JSONWebKey
+}
+
+// NewServer starts a provider supporting the authorization code, refresh
+// token and client credentials grants which is closed when the test ends.
+// The options informed are applied after the defaults, so they can override
+// them. Policies informed take precedence over the auto approving one.
+func NewServer(t testing.TB, opts ...provider.ProviderOption) *Server {
+	t.Helper()
+
+	s := newServer(t)
+	s.start(t, append(opts, provider.WithPolicy(s.policy())))
+	return s
+}
+
+func newServer(t testing.TB) *Server {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("could not generate the server key: %v", err)
+	}
+
+	s := &Server{
+		Server:  httptest.NewUnstartedServer(nil),
+		Subject: DefaultSubject,
+		jwk: jose.JSONWebKey{
+			Key:       key,
+			KeyID:     serverKeyID,
+			Algorithm: string(jose.PS256),
+			Use:       string(goidc.KeyUsageSignature),
+		},
+	}
+	s.StartTLS()
+	t.Cleanup(s.Close)
+	return s
+}
+
+// start creates the provider and makes the server handle requests with it.
+func (s *Server) start(t testing.TB, opts []provider.ProviderOption) {
+	t.Helper()
+
+	defaultOpts := []provider.ProviderOption{
+		provider.WithAuthorizationCodeGrant(),
+		provider.WithClientCredentialsGrant(),
+		provider.WithRefreshTokenGrant(
+			func(*goidc.Client, goidc.GrantInfo) bool { return true },
+			refreshTokenLifetimeSecs,
+		),
+		provider.WithTokenAuthnMethods(
+			goidc.ClientAuthnSecretPost,
+			goidc.ClientAuthnSecretBasic,
+		),
+		provider.WithPKCE(goidc.CodeChallengeMethodSHA256),
+	}
+	opts = append(defaultOpts, opts...)
+
+	op, err := provider.New(
+		goidc.ProfileOpenID,
+		s.URL,
+		jose.JSONWebKeySet{Keys: []jose.JSONWebKey{s.jwk}},
+		opts...,
+	)
+	if err != nil {
+		t.Fatalf("could not create the provider: %v", err)
+	}
+	s.Provider = op
+	s.Config.Handler = op.Handler()
+	t.Cleanup(op.Close)
+}
+
+// JWKS returns the public keys of the provider.
+func (s *Server) JWKS() jose.JSONWebKeySet {
+	return jose.JSONWebKeySet{Keys: []jose.JSONWebKey{s.jwk.Public()}}
+}
+
+// NewClient registers a confidential client authenticating with
+// client_secret_post and returns it ready to be used against the server.
+// If no scopes are informed, only openid is requested.
+func (s *Server) NewClient(t testing.TB, scopes ...string) rp.Client {
+	t.Helper()
+
+	if len(scopes) == 0 {
+		scopes = []string{goidc.ScopeOpenID.ID}
+	}
+
+	id := strutil.Random(clientIDLength)
+	secret := strutil.Random(clientSecretLength)
+	// The minimum cost keeps client authentication from dominating the time
+	// spent by tests and benchmarks.
+	hashedSecret, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("could not hash the client secret: %v", err)
+	}
+
+	client := &goidc.Client{
+		ID:           id,
+		HashedSecret: string(hashedSecret),
+		ClientMetaInfo: goidc.ClientMetaInfo{
+			TokenAuthnMethod: goidc.ClientAuthnSecretPost,
+			RedirectURIs:     []string{RedirectURI},
+			ScopeIDs:         strings.Join(scopes, " "),
+			GrantTypes: []goidc.GrantType{
+				goidc.GrantAuthorizationCode,
+				goidc.GrantRefreshToken,
+				goidc.GrantClientCredentials,
+			},
+			ResponseTypes: []goidc.ResponseType{goidc.ResponseTypeCode},
+		},
+	}
+	if err := s.Provider.UpdateClient(context.Background(), client); err != nil {
+		t.Fatalf("could not save the client: %v", err)
+	}
+
+	metadata, err := rp.Discover(context.Background(), s.Client(), s.URL)
+	if err != nil {
+		t.Fatalf("could not fetch the provider metadata: %v", err)
+	}
+
+	jwks := s.JWKS()
+	return rp.Client{
+		ID:           id,
+		Secret:       secret,
+		RedirectURI:  RedirectURI,
+		Scopes:       scopes,
+		Provider:     metadata,
+		ProviderJWKS: &jwks,
+		HTTPClient:   s.Client(),
+	}
+}
+
+// AuthorizationCode runs an authorization request for the client and returns
+// the code issued along with the request, which is needed to exchange it.
+func (s *Server) AuthorizationCode(
+	t testing.TB,
+	client rp.Client,
+) (
+	rp.AuthorizationRequest,
+	string,
+) {
+	t.Helper()
+
+	authReq := rp.NewAuthorizationRequest()
+	authURL, err := client.AuthorizationURL(context.Background(), authReq)
+	if err != nil {
+		t.Fatalf("could not build the authorization url: %v", err)
+	}
+
+	// Stop at the first redirect, since the redirect URI is not served.
+	httpClient := *s.Client()
+	httpClient.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	resp, err := httpClient.Get(authURL)
+	if err != nil {
+		t.Fatalf("could not call the authorization endpoint: %v", err)
+	}
+	defer resp.Body.Close()
+
+	redirectURL, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatalf("invalid redirect url %q: %v", resp.Header.Get("Location"), err)
+	}
+
+	code, err := client.ParseAuthorizationResponse(authReq, redirectURL.Query())
+	if err != nil {
+		t.Fatalf("the authorization request failed: %v", err)
+	}
+
+	return authReq, code
+}
+
+// Tokens runs the authorization code flow for the client and returns the
+// tokens issued.
+func (s *Server) Tokens(t testing.TB, client rp.Client) rp.TokenResponse {
+	t.Helper()
+
+	authReq, code := s.AuthorizationCode(t, client)
+	tokenResp, err := client.Exchange(context.Background(), authReq, code)
+	if err != nil {
+		t.Fatalf("could not exchange the authorization code: %v", err)
+	}
+
+	return tokenResp
+}
+
+// policy authenticates every user as the server's subject and grants all the
+// scopes requested without interaction.
+func (s *Server) policy() goidc.AuthnPolicy {
+	return goidc.NewPolicy(
+		"providertest",
+		func(*http.Request, *goidc.Client, *goidc.AuthnSession) bool {
+			return true
+		},
+		func(_ http.ResponseWriter, _ *http.Request, as *goidc.AuthnSession) (goidc.AuthnStatus, error) {
+			as.SetUserID(s.Subject)
+			as.GrantScopes(as.Scopes)
+			as.GrantResources(as.Resources)
+			return goidc.StatusSuccess, nil
+		},
+	)
+}
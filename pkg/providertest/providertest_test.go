@@ -0,0 +1,77 @@
+package providertest_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/luikyv/go-oidc/pkg/goidc"
+	"github.com/luikyv/go-oidc/pkg/providertest"
+)
+
+func TestServer_Tokens(t *testing.T) {
+	// Given.
+	server := providertest.NewServer(t)
+	client := server.NewClient(t)
+
+	// When.
+	tokenResp := server.Tokens(t, client)
+
+	// Then.
+	if tokenResp.AccessToken == "" {
+		t.Fatal("the access token cannot be empty")
+	}
+
+	if tokenResp.IDTokenClaims[goidc.ClaimSubject] != providertest.DefaultSubject {
+		t.Errorf("sub = %v, want %s", tokenResp.IDTokenClaims[goidc.ClaimSubject],
+			providertest.DefaultSubject)
+	}
+
+	if tokenResp.RefreshToken == "" {
+		t.Error("the refresh token cannot be empty")
+	}
+
+	// When.
+	req, _ := http.NewRequest(http.MethodGet, client.Provider.UserInfoEndpoint, nil)
+	if err := client.AuthorizeRequest(req, tokenResp); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp, err := server.Client().Do(req)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status code = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+}
+
+func TestServer_Subject(t *testing.T) {
+	// Given.
+	server := providertest.NewServer(t)
+	server.Subject = "other_subject"
+	client := server.NewClient(t)
+
+	// When.
+	tokenResp := server.Tokens(t, client)
+
+	// Then.
+	if tokenResp.IDTokenClaims[goidc.ClaimSubject] != "other_subject" {
+		t.Errorf("sub = %v, want other_subject", tokenResp.IDTokenClaims[goidc.ClaimSubject])
+	}
+
+	// When.
+	refreshResp, err := client.Refresh(context.Background(), tokenResp.RefreshToken)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if refreshResp.AccessToken == "" {
+		t.Error("the access token cannot be empty")
+	}
+}
@@ -0,0 +1,312 @@
+package providertest
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/luikyv/go-oidc/internal/storage"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+// Fault defines how a storage operation misbehaves.
+type Fault struct {
+	// Err, if not nil, is returned by the operation instead of executing it.
+	Err error
+	// Latency is waited before the operation executes. If the request context
+	// is cancelled meanwhile, its error is returned.
+	Latency time.Duration
+	// DropWrite makes a write operation report success without persisting
+	// anything. It has no effect on read operations.
+	DropWrite bool
+	// Times limits how many calls the fault applies to. Zero means every call.
+	Times int
+}
+
+// Faults holds the faults injected into the operations of a storage double.
+// Operations are identified by the name of the manager method, e.g. "Save" or
+// "SessionByTokenID".
+type Faults struct {
+	mu     sync.Mutex
+	faults map[string]*Fault
+}
+
+// Inject makes the operation misbehave as defined by fault, replacing any
+// fault previously injected into it.
+func (f *Faults) Inject(op string, fault Fault) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.faults == nil {
+		f.faults = map[string]*Fault{}
+	}
+	f.faults[op] = &fault
+}
+
+// Reset removes all the faults injected.
+func (f *Faults) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.faults = nil
+}
+
+// apply executes the fault injected into the operation if any. It returns
+// whether a write must be dropped.
+func (f *Faults) apply(ctx context.Context, op string) (bool, error) {
+	f.mu.Lock()
+	fault, ok := f.faults[op]
+	if !ok {
+		f.mu.Unlock()
+		return false, nil
+	}
+
+	current := *fault
+	if fault.Times > 0 {
+		fault.Times--
+		if fault.Times == 0 {
+			delete(f.faults, op)
+		}
+	}
+	f.mu.Unlock()
+
+	if current.Latency > 0 {
+		select {
+		case <-time.After(current.Latency):
+		case <-ctx.Done():
+			return false, ctx.Err()
+		}
+	}
+
+	return current.DropWrite, current.Err
+}
+
+// ClientManager is a [goidc.ClientManager] whose operations can be programmed
+// to fail.
+type ClientManager struct {
+	Faults
+	manager goidc.ClientManager
+}
+
+// NewClientManager wraps the manager informed. If it is nil, the clients are
+// stored in memory.
+func NewClientManager(manager goidc.ClientManager) *ClientManager {
+	if manager == nil {
+		manager = storage.NewClientManager()
+	}
+	return &ClientManager{manager: manager}
+}
+
+func (m *ClientManager) Save(ctx context.Context, client *goidc.Client) error {
+	if drop, err := m.apply(ctx, "Save"); drop || err != nil {
+		return err
+	}
+	return m.manager.Save(ctx, client)
+}
+
+func (m *ClientManager) Client(ctx context.Context, id string) (*goidc.Client, error) {
+	if _, err := m.apply(ctx, "Client"); err != nil {
+		return nil, err
+	}
+	return m.manager.Client(ctx, id)
+}
+
+func (m *ClientManager) Delete(ctx context.Context, id string) error {
+	if drop, err := m.apply(ctx, "Delete"); drop || err != nil {
+		return err
+	}
+	return m.manager.Delete(ctx, id)
+}
+
+// List implements [goidc.ClientLister] if the manager wrapped does.
+func (m *ClientManager) List(ctx context.Context, filter goidc.ClientFilter) ([]*goidc.Client, error) {
+	lister, ok := m.manager.(goidc.ClientLister)
+	if !ok {
+		return nil, errors.New("the client manager does not support listing clients")
+	}
+
+	if _, err := m.apply(ctx, "List"); err != nil {
+		return nil, err
+	}
+	return lister.List(ctx, filter)
+}
+
+// AuthnSessionManager is a [goidc.AuthnSessionManager] whose operations can
+// be programmed to fail.
+type AuthnSessionManager struct {
+	Faults
+	manager goidc.AuthnSessionManager
+}
+
+// NewAuthnSessionManager wraps the manager informed. If it is nil, the
+// sessions are stored in memory.
+func NewAuthnSessionManager(manager goidc.AuthnSessionManager) *AuthnSessionManager {
+	if manager == nil {
+		manager = storage.NewAuthnSessionManager()
+	}
+	return &AuthnSessionManager{manager: manager}
+}
+
+func (m *AuthnSessionManager) Save(ctx context.Context, session *goidc.AuthnSession) error {
+	if drop, err := m.apply(ctx, "Save"); drop || err != nil {
+		return err
+	}
+	return m.manager.Save(ctx, session)
+}
+
+func (m *AuthnSessionManager) SessionByCallbackID(ctx context.Context, callbackID string) (*goidc.AuthnSession, error) {
+	if _, err := m.apply(ctx, "SessionByCallbackID"); err != nil {
+		return nil, err
+	}
+	return m.manager.SessionByCallbackID(ctx, callbackID)
+}
+
+func (m *AuthnSessionManager) SessionByAuthorizationCode(ctx context.Context, code string) (*goidc.AuthnSession, error) {
+	if _, err := m.apply(ctx, "SessionByAuthorizationCode"); err != nil {
+		return nil, err
+	}
+	return m.manager.SessionByAuthorizationCode(ctx, code)
+}
+
+func (m *AuthnSessionManager) SessionByReferenceID(ctx context.Context, id string) (*goidc.AuthnSession, error) {
+	if _, err := m.apply(ctx, "SessionByReferenceID"); err != nil {
+		return nil, err
+	}
+	return m.manager.SessionByReferenceID(ctx, id)
+}
+
+func (m *AuthnSessionManager) Delete(ctx context.Context, id string) error {
+	if drop, err := m.apply(ctx, "Delete"); drop || err != nil {
+		return err
+	}
+	return m.manager.Delete(ctx, id)
+}
+
+// List implements [goidc.AuthnSessionLister] if the manager wrapped does.
+func (m *AuthnSessionManager) List(ctx context.Context, filter goidc.SessionFilter) ([]*goidc.AuthnSession, error) {
+	lister, ok := m.manager.(goidc.AuthnSessionLister)
+	if !ok {
+		return nil, errors.New("the authn session manager does not support listing sessions")
+	}
+
+	if _, err := m.apply(ctx, "List"); err != nil {
+		return nil, err
+	}
+	return lister.List(ctx, filter)
+}
+
+// GrantSessionManager is a [goidc.GrantSessionManager] whose operations can
+// be programmed to fail.
+type GrantSessionManager struct {
+	Faults
+	manager goidc.GrantSessionManager
+}
+
+// NewGrantSessionManager wraps the manager informed. If it is nil, the
+// sessions are stored in memory.
+func NewGrantSessionManager(manager goidc.GrantSessionManager) *GrantSessionManager {
+	if manager == nil {
+		manager = storage.NewGrantSessionManager()
+	}
+	return &GrantSessionManager{manager: manager}
+}
+
+func (m *GrantSessionManager) Save(ctx context.Context, session *goidc.GrantSession) error {
+	if drop, err := m.apply(ctx, "Save"); drop || err != nil {
+		return err
+	}
+	return m.manager.Save(ctx, session)
+}
+
+func (m *GrantSessionManager) SessionByTokenID(ctx context.Context, id string) (*goidc.GrantSession, error) {
+	if _, err := m.apply(ctx, "SessionByTokenID"); err != nil {
+		return nil, err
+	}
+	return m.manager.SessionByTokenID(ctx, id)
+}
+
+func (m *GrantSessionManager) SessionByRefreshToken(ctx context.Context, token string) (*goidc.GrantSession, error) {
+	if _, err := m.apply(ctx, "SessionByRefreshToken"); err != nil {
+		return nil, err
+	}
+	return m.manager.SessionByRefreshToken(ctx, token)
+}
+
+func (m *GrantSessionManager) Delete(ctx context.Context, id string) error {
+	if drop, err := m.apply(ctx, "Delete"); drop || err != nil {
+		return err
+	}
+	return m.manager.Delete(ctx, id)
+}
+
+func (m *GrantSessionManager) DeleteByAuthorizationCode(ctx context.Context, code string) error {
+	if drop, err := m.apply(ctx, "DeleteByAuthorizationCode"); drop || err != nil {
+		return err
+	}
+	return m.manager.DeleteByAuthorizationCode(ctx, code)
+}
+
+// SessionByAuthorizationCode implements [goidc.GrantSessionCodeFinder] if the
+// manager wrapped does.
+func (m *GrantSessionManager) SessionByAuthorizationCode(ctx context.Context, code string) (*goidc.GrantSession, error) {
+	finder, ok := m.manager.(goidc.GrantSessionCodeFinder)
+	if !ok {
+		return nil, errors.New("the grant session manager does not support finding sessions by authorization code")
+	}
+
+	if _, err := m.apply(ctx, "SessionByAuthorizationCode"); err != nil {
+		return nil, err
+	}
+	return finder.SessionByAuthorizationCode(ctx, code)
+}
+
+// List implements [goidc.GrantSessionLister] if the manager wrapped does.
+func (m *GrantSessionManager) List(ctx context.Context, filter goidc.SessionFilter) ([]*goidc.GrantSession, error) {
+	lister, ok := m.manager.(goidc.GrantSessionLister)
+	if !ok {
+		return nil, errors.New("the grant session manager does not support listing sessions")
+	}
+
+	if _, err := m.apply(ctx, "List"); err != nil {
+		return nil, err
+	}
+	return lister.List(ctx, filter)
+}
+
+// ConsentManager is a [goidc.ConsentManager] whose operations can be
+// programmed to fail.
+type ConsentManager struct {
+	Faults
+	manager goidc.ConsentManager
+}
+
+// NewConsentManager wraps the manager informed. If it is nil, the consents
+// are stored in memory.
+func NewConsentManager(manager goidc.ConsentManager) *ConsentManager {
+	if manager == nil {
+		manager = storage.NewConsentManager()
+	}
+	return &ConsentManager{manager: manager}
+}
+
+func (m *ConsentManager) Save(ctx context.Context, consent *goidc.Consent) error {
+	if drop, err := m.apply(ctx, "Save"); drop || err != nil {
+		return err
+	}
+	return m.manager.Save(ctx, consent)
+}
+
+func (m *ConsentManager) Consent(ctx context.Context, subject, clientID string) (*goidc.Consent, error) {
+	if _, err := m.apply(ctx, "Consent"); err != nil {
+		return nil, err
+	}
+	return m.manager.Consent(ctx, subject, clientID)
+}
+
+func (m *ConsentManager) Delete(ctx context.Context, subject, clientID string) error {
+	if drop, err := m.apply(ctx, "Delete"); drop || err != nil {
+		return err
+	}
+	return m.manager.Delete(ctx, subject, clientID)
+}
@@ -0,0 +1,72 @@
+package providertest_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/luikyv/go-oidc/pkg/goidc"
+	"github.com/luikyv/go-oidc/pkg/provider"
+	"github.com/luikyv/go-oidc/pkg/providertest"
+)
+
+func TestGrantSessionManager_Err(t *testing.T) {
+	// Given.
+	grantManager := providertest.NewGrantSessionManager(nil)
+	server := providertest.NewServer(t, provider.WithGrantSessionStorage(grantManager))
+	client := server.NewClient(t)
+	authReq, code := server.AuthorizationCode(t, client)
+	grantManager.Inject("Save", providertest.Fault{Err: errors.New("storage unavailable")})
+
+	// When.
+	_, err := client.Exchange(context.Background(), authReq, code)
+
+	// Then.
+	if err == nil {
+		t.Fatal("the token request must fail when the grant cannot be saved")
+	}
+}
+
+func TestGrantSessionManager_DropWrite(t *testing.T) {
+	// Given.
+	grantManager := providertest.NewGrantSessionManager(nil)
+	grantManager.Inject("Save", providertest.Fault{DropWrite: true, Times: 1})
+	session := &goidc.GrantSession{ID: "random_id", TokenID: "random_token_id"}
+
+	// When.
+	err := grantManager.Save(context.Background(), session)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := grantManager.SessionByTokenID(context.Background(), "random_token_id"); err == nil {
+		t.Error("the dropped write must not be persisted")
+	}
+
+	if err := grantManager.Save(context.Background(), session); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := grantManager.SessionByTokenID(context.Background(), "random_token_id"); err != nil {
+		t.Errorf("the fault must apply only once: %v", err)
+	}
+}
+
+func TestClientManager_Latency(t *testing.T) {
+	// Given.
+	clientManager := providertest.NewClientManager(nil)
+	clientManager.Inject("Client", providertest.Fault{Latency: time.Minute})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	// When.
+	_, err := clientManager.Client(ctx, "random_client_id")
+
+	// Then.
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
@@ -0,0 +1,27 @@
+// Package resource contains helpers for resource servers protecting APIs with
+// access tokens issued by an OpenID Provider.
+//
+// A [Validator] verifies JWT access tokens with the provider's keys and
+// introspects opaque ones. It enforces the required scopes and audience and
+// checks that sender constrained tokens are presented with a valid DPoP proof
+// or client certificate.
+//
+//	validator := resource.Validator{
+//		Issuer:                "https://op.example.com",
+//		JWKSURI:               "https://op.example.com/jwks",
+//		IntrospectionEndpoint: "https://op.example.com/introspect",
+//		ClientID:              "random_resource_server",
+//		ClientSecret:          "random_secret",
+//		Audience:              "https://api.example.com",
+//	}
+//
+//	mux := http.NewServeMux()
+//	mux.Handle("GET /accounts", validator.Middleware("accounts")(accountsHandler))
+//
+// The token information is available to the handlers with [TokenInfo].
+//
+// gRPC services can validate tokens in their interceptors with
+// [Validator.GRPCAuthFunc] or [Validator.ValidateGRPC]. The interceptors
+// themselves are not provided, since that would make every user of this
+// module depend on gRPC.
+package resource
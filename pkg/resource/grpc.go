@@ -0,0 +1,217 @@
+package resource
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+// gRPC status codes as defined by the gRPC specification.
+// They are declared here so this package doesn't depend on the gRPC module.
+const (
+	grpcCodeInternal         = 13
+	grpcCodePermissionDenied = 7
+	grpcCodeUnauthenticated  = 16
+)
+
+// GRPCCall holds the information of an incoming gRPC call needed to validate
+// its access token.
+type GRPCCall struct {
+	// FullMethod is the full name of the method called, e.g.
+	// "/package.Service/Method".
+	FullMethod string
+	// Metadata is the incoming metadata of the call. The access token is read
+	// from the "authorization" key and the DPoP proof from the "dpop" key.
+	Metadata map[string][]string
+	// TLS is the state of the connection with the peer. It must be informed
+	// when tokens are bound to client certificates.
+	TLS *tls.ConnectionState
+}
+
+// ValidateGRPC validates the access token of a gRPC call the same way
+// [Validator.Validate] does for HTTP requests.
+// The context returned contains the token information which can be obtained
+// with [TokenInfo].
+//
+// Since gRPC calls are HTTP/2 POST requests to the method path, DPoP proofs
+// must be created with "POST" as htm and the URL of the method as htu.
+func (v *Validator) ValidateGRPC(
+	ctx context.Context,
+	call GRPCCall,
+	scopes ...string,
+) (
+	context.Context,
+	error,
+) {
+	header := http.Header{}
+	for key, values := range call.Metadata {
+		header[http.CanonicalHeaderKey(key)] = values
+	}
+
+	var host string
+	if authorities := call.Metadata[":authority"]; len(authorities) != 0 {
+		host = authorities[0]
+	}
+
+	r := &http.Request{
+		Method: http.MethodPost,
+		URL:    &url.URL{Path: call.FullMethod},
+		Host:   host,
+		Header: header,
+		TLS:    call.TLS,
+	}
+	r = r.WithContext(ctx)
+
+	info, err := v.Validate(r, scopes...)
+	if err != nil {
+		return ctx, err
+	}
+
+	return context.WithValue(ctx, tokenInfoKey{}, info), nil
+}
+
+// GRPCCallFunc extracts the information of the gRPC call served from its
+// context, e.g. from the metadata and the peer attached to it by gRPC.
+type GRPCCallFunc func(ctx context.Context) GRPCCall
+
+// GRPCErrorFunc converts an error returned by [Validator.ValidateGRPC] into the
+// error sent to the gRPC client. code is the one returned by [GRPCStatusCode].
+type GRPCErrorFunc func(code int, err error) error
+
+// GRPCAuthFunc returns a function that validates the access token of the gRPC
+// call served with ctx and returns a context with the token information.
+// The function has the signature expected by the auth interceptors of
+// github.com/grpc-ecosystem/go-grpc-middleware, so it can be used for both
+// unary and stream calls. [UnaryServerInterceptor] and
+// [StreamServerInterceptor] can be used instead of that module.
+//
+// This package doesn't depend on gRPC, so the call information and the status
+// errors are built by callFunc and errFunc. If errFunc is nil, the errors are
+// returned as they are.
+//
+//	callFunc := func(ctx context.Context) resource.GRPCCall {
+//		method, _ := grpc.Method(ctx)
+//		md, _ := metadata.FromIncomingContext(ctx)
+//		call := resource.GRPCCall{FullMethod: method, Metadata: md}
+//		if p, ok := peer.FromContext(ctx); ok {
+//			if tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+//				call.TLS = &tlsInfo.State
+//			}
+//		}
+//		return call
+//	}
+//	errFunc := func(code int, err error) error {
+//		return status.Error(codes.Code(code), err.Error())
+//	}
+//	authFunc := validator.GRPCAuthFunc(callFunc, errFunc, "accounts")
+//
+//	server := grpc.NewServer(
+//		grpc.ChainUnaryInterceptor(auth.UnaryServerInterceptor(authFunc)),
+//		grpc.ChainStreamInterceptor(auth.StreamServerInterceptor(authFunc)),
+//	)
+func (v *Validator) GRPCAuthFunc(
+	callFunc GRPCCallFunc,
+	errFunc GRPCErrorFunc,
+	scopes ...string,
+) func(context.Context) (context.Context, error) {
+	return func(ctx context.Context) (context.Context, error) {
+		ctx, err := v.ValidateGRPC(ctx, callFunc(ctx), scopes...)
+		if err != nil && errFunc != nil {
+			return ctx, errFunc(GRPCStatusCode(err), err)
+		}
+		return ctx, err
+	}
+}
+
+// UnaryServerInterceptor returns a unary interceptor that runs authFunc, e.g.
+// the one returned by [Validator.GRPCAuthFunc], before the call is handled.
+// The call is rejected with the error of authFunc if it fails. Otherwise, the
+// handler receives the context returned by authFunc.
+//
+// Since this package doesn't depend on gRPC, the types of the interceptor
+// arguments must be informed, which makes the function returned assignable
+// to grpc.UnaryServerInterceptor.
+//
+//	interceptor := resource.UnaryServerInterceptor[*grpc.UnaryServerInfo, grpc.UnaryHandler](authFunc)
+//	server := grpc.NewServer(grpc.ChainUnaryInterceptor(interceptor))
+func UnaryServerInterceptor[
+	Info any,
+	Handler ~func(ctx context.Context, req any) (any, error),
+](
+	authFunc func(context.Context) (context.Context, error),
+) func(ctx context.Context, req any, info Info, handler Handler) (any, error) {
+	return func(ctx context.Context, req any, _ Info, handler Handler) (any, error) {
+		ctx, err := authFunc(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// ServerStream is the part of grpc.ServerStream needed by
+// [StreamServerInterceptor].
+type ServerStream interface {
+	Context() context.Context
+}
+
+// StreamServerInterceptor returns a stream interceptor that runs authFunc,
+// e.g. the one returned by [Validator.GRPCAuthFunc], before the call is
+// handled. The call is rejected with the error of authFunc if it fails.
+// Otherwise, the handler receives the stream returned by wrapFunc, which must
+// return the context informed from its Context method.
+//
+// As with [UnaryServerInterceptor], the types of the interceptor arguments
+// must be informed, which makes the function returned assignable to
+// grpc.StreamServerInterceptor.
+//
+//	type authStream struct {
+//		grpc.ServerStream
+//		ctx context.Context
+//	}
+//
+//	func (s authStream) Context() context.Context { return s.ctx }
+//
+//	wrapFunc := func(ss grpc.ServerStream, ctx context.Context) grpc.ServerStream {
+//		return authStream{ServerStream: ss, ctx: ctx}
+//	}
+//	interceptor := resource.StreamServerInterceptor[*grpc.StreamServerInfo, grpc.StreamHandler](authFunc, wrapFunc)
+//	server := grpc.NewServer(grpc.ChainStreamInterceptor(interceptor))
+func StreamServerInterceptor[
+	Info any,
+	Handler ~func(srv any, stream Stream) error,
+	Stream ServerStream,
+](
+	authFunc func(context.Context) (context.Context, error),
+	wrapFunc func(stream Stream, ctx context.Context) Stream,
+) func(srv any, stream Stream, info Info, handler Handler) error {
+	return func(srv any, stream Stream, _ Info, handler Handler) error {
+		ctx, err := authFunc(stream.Context())
+		if err != nil {
+			return err
+		}
+		return handler(srv, wrapFunc(stream, ctx))
+	}
+}
+
+// GRPCStatusCode returns the gRPC status code that corresponds to an error
+// returned by [Validator.ValidateGRPC].
+func GRPCStatusCode(err error) int {
+	var oidcErr goidc.Error
+	if !errors.As(err, &oidcErr) {
+		return grpcCodeInternal
+	}
+
+	switch oidcErr.Code.StatusCode() {
+	case http.StatusUnauthorized:
+		return grpcCodeUnauthenticated
+	case http.StatusForbidden:
+		return grpcCodePermissionDenied
+	default:
+		return grpcCodeInternal
+	}
+}
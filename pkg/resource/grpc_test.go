@@ -0,0 +1,269 @@
+package resource_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/luikyv/go-oidc/pkg/resource"
+)
+
+func TestValidateGRPC(t *testing.T) {
+	// Given.
+	validator, jwk := setUpValidator(t)
+	token := accessToken(t, jwk, map[string]any{
+		"scope": "scope1",
+		"aud":   "https://api.example.com",
+	})
+	call := resource.GRPCCall{
+		FullMethod: "/random.Service/Method",
+		Metadata: map[string][]string{
+			":authority":    {"api.example.com"},
+			"authorization": {"Bearer " + token},
+		},
+	}
+
+	// When.
+	ctx, err := validator.ValidateGRPC(context.Background(), call, "scope1")
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	info, ok := resource.TokenInfo(ctx)
+	if !ok || info.Subject != "random_subject" {
+		t.Errorf("unexpected token info: %+v", info)
+	}
+}
+
+func TestValidateGRPC_StatusCode(t *testing.T) {
+	// Given.
+	validator, jwk := setUpValidator(t)
+	token := accessToken(t, jwk, map[string]any{
+		"scope": "scope2",
+		"aud":   "https://api.example.com",
+	})
+
+	testCases := []struct {
+		name     string
+		metadata map[string][]string
+		wantCode int
+	}{
+		{
+			name:     "missing token",
+			metadata: map[string][]string{},
+			wantCode: 16,
+		},
+		{
+			name:     "insufficient scope",
+			metadata: map[string][]string{"authorization": {"Bearer " + token}},
+			wantCode: 7,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// When.
+			_, err := validator.ValidateGRPC(context.Background(), resource.GRPCCall{
+				FullMethod: "/random.Service/Method",
+				Metadata:   testCase.metadata,
+			}, "scope1")
+
+			// Then.
+			if err == nil {
+				t.Fatal("an error was expected")
+			}
+
+			if code := resource.GRPCStatusCode(err); code != testCase.wantCode {
+				t.Errorf("GRPCStatusCode() = %d, want %d", code, testCase.wantCode)
+			}
+		})
+	}
+}
+
+func TestGRPCAuthFunc(t *testing.T) {
+	// Given.
+	validator, jwk := setUpValidator(t)
+	token := accessToken(t, jwk, map[string]any{
+		"scope": "scope1",
+		"aud":   "https://api.example.com",
+	})
+	authFunc := validator.GRPCAuthFunc(
+		func(context.Context) resource.GRPCCall {
+			return resource.GRPCCall{
+				FullMethod: "/random.Service/Method",
+				Metadata:   map[string][]string{"authorization": {"Bearer " + token}},
+			}
+		},
+		nil,
+		"scope1",
+	)
+
+	// When.
+	ctx, err := authFunc(context.Background())
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, ok := resource.TokenInfo(ctx); !ok {
+		t.Error("the token info should be in the context")
+	}
+}
+
+func TestGRPCAuthFunc_Error(t *testing.T) {
+	// Given.
+	validator, _ := setUpValidator(t)
+	errStatus := errors.New("random status error")
+	var code int
+	authFunc := validator.GRPCAuthFunc(
+		func(context.Context) resource.GRPCCall {
+			return resource.GRPCCall{FullMethod: "/random.Service/Method"}
+		},
+		func(c int, _ error) error {
+			code = c
+			return errStatus
+		},
+		"scope1",
+	)
+
+	// When.
+	_, err := authFunc(context.Background())
+
+	// Then.
+	if !errors.Is(err, errStatus) {
+		t.Fatalf("err = %v, want %v", err, errStatus)
+	}
+
+	if code != 16 {
+		t.Errorf("code = %d, want 16", code)
+	}
+}
+
+// The types below mirror the ones of the gRPC module.
+type (
+	unaryServerInfo        struct{}
+	unaryHandler           func(ctx context.Context, req any) (any, error)
+	unaryServerInterceptor func(ctx context.Context, req any, info *unaryServerInfo, handler unaryHandler) (any, error)
+
+	serverStream interface {
+		Context() context.Context
+		SendMsg(m any) error
+	}
+	streamServerInfo        struct{}
+	streamHandler           func(srv any, stream serverStream) error
+	streamServerInterceptor func(srv any, ss serverStream, info *streamServerInfo, handler streamHandler) error
+)
+
+type testStream struct {
+	serverStream
+	ctx context.Context
+}
+
+func (s testStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	// Given.
+	validator, jwk := setUpValidator(t)
+	token := accessToken(t, jwk, map[string]any{
+		"scope": "scope1",
+		"aud":   "https://api.example.com",
+	})
+	authFunc := validator.GRPCAuthFunc(
+		func(ctx context.Context) resource.GRPCCall {
+			return resource.GRPCCall{
+				FullMethod: "/random.Service/Method",
+				Metadata:   map[string][]string{"authorization": {ctx.Value(testTokenKey{}).(string)}},
+			}
+		},
+		nil,
+		"scope1",
+	)
+	var interceptor unaryServerInterceptor = resource.UnaryServerInterceptor[*unaryServerInfo, unaryHandler](authFunc)
+
+	handlerCalled := false
+	handler := func(ctx context.Context, _ any) (any, error) {
+		handlerCalled = true
+		if _, ok := resource.TokenInfo(ctx); !ok {
+			t.Error("the token info should be in the context")
+		}
+		return nil, nil
+	}
+
+	// When.
+	ctx := context.WithValue(context.Background(), testTokenKey{}, "Bearer "+token)
+	_, err := interceptor(ctx, nil, &unaryServerInfo{}, handler)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !handlerCalled {
+		t.Error("the handler should be called")
+	}
+
+	// When.
+	ctx = context.WithValue(context.Background(), testTokenKey{}, "Bearer invalid_token")
+	handlerCalled = false
+	_, err = interceptor(ctx, nil, &unaryServerInfo{}, handler)
+
+	// Then.
+	if err == nil {
+		t.Error("the call should be rejected")
+	}
+
+	if handlerCalled {
+		t.Error("the handler should not be called")
+	}
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	// Given.
+	validator, jwk := setUpValidator(t)
+	token := accessToken(t, jwk, map[string]any{
+		"scope": "scope1",
+		"aud":   "https://api.example.com",
+	})
+	authFunc := validator.GRPCAuthFunc(
+		func(context.Context) resource.GRPCCall {
+			return resource.GRPCCall{
+				FullMethod: "/random.Service/Method",
+				Metadata:   map[string][]string{"authorization": {"Bearer " + token}},
+			}
+		},
+		nil,
+		"scope1",
+	)
+	wrapFunc := func(ss serverStream, ctx context.Context) serverStream {
+		return testStream{serverStream: ss, ctx: ctx}
+	}
+	var interceptor streamServerInterceptor = resource.StreamServerInterceptor[*streamServerInfo, streamHandler](authFunc, wrapFunc)
+
+	handlerCalled := false
+	handler := func(_ any, stream serverStream) error {
+		handlerCalled = true
+		if _, ok := resource.TokenInfo(stream.Context()); !ok {
+			t.Error("the token info should be in the stream context")
+		}
+		return nil
+	}
+
+	// When.
+	err := interceptor(nil, testStream{ctx: context.Background()}, &streamServerInfo{}, handler)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !handlerCalled {
+		t.Error("the handler should be called")
+	}
+}
+
+type testTokenKey struct{}
@@ -0,0 +1,182 @@
+package resource
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+type dpopClaims struct {
+	HTTPMethod      string `json:"htm"`
+	HTTPURI         string `json:"htu"`
+	AccessTokenHash string `json:"ath"`
+}
+
+// validatePoP makes sure the request proves possession of the key or
+// certificate the token is bound to.
+func (v *Validator) validatePoP(
+	r *http.Request,
+	token string,
+	tokenType goidc.TokenType,
+	info goidc.TokenInfo,
+) error {
+	var cnf goidc.TokenConfirmation
+	if info.Confirmation != nil {
+		cnf = *info.Confirmation
+	}
+
+	if cnf.JWKThumbprint == "" && tokenType == goidc.TokenTypeDPoP {
+		return goidc.NewError(goidc.ErrorCodeInvalidToken, "the token is not bound with dpop")
+	}
+
+	if cnf.JWKThumbprint != "" {
+		if tokenType != goidc.TokenTypeDPoP {
+			return goidc.NewError(goidc.ErrorCodeInvalidToken,
+				"dpop bound tokens must use the dpop authorization scheme")
+		}
+
+		if err := v.validateDPoP(r, token, cnf.JWKThumbprint); err != nil {
+			return goidc.Errorf(goidc.ErrorCodeInvalidToken, "invalid dpop proof", err)
+		}
+	}
+
+	if cnf.ClientCertThumbprint != "" {
+		if err := v.validateClientCert(r, cnf.ClientCertThumbprint); err != nil {
+			return goidc.Errorf(goidc.ErrorCodeInvalidToken, "invalid client certificate", err)
+		}
+	}
+
+	return nil
+}
+
+func (v *Validator) validateDPoP(r *http.Request, token, jkt string) error {
+	// RFC 9449: "There is not more than one DPoP HTTP request header field."
+	proofs := r.Header[http.CanonicalHeaderKey(goidc.HeaderDPoP)]
+	if len(proofs) != 1 {
+		return errors.New("a single dpop header is required")
+	}
+
+	sigAlgs := v.DPoPSigAlgs
+	if len(sigAlgs) == 0 {
+		sigAlgs = []jose.SignatureAlgorithm{jose.RS256, jose.PS256, jose.ES256}
+	}
+
+	parsedProof, err := jwt.ParseSigned(proofs[0], sigAlgs)
+	if err != nil {
+		return err
+	}
+
+	if len(parsedProof.Headers) != 1 || parsedProof.Headers[0].ExtraHeaders["typ"] != "dpop+jwt" {
+		return errors.New("invalid typ header, it should be dpop+jwt")
+	}
+
+	jwk := parsedProof.Headers[0].JSONWebKey
+	if jwk == nil || !jwk.Valid() || !jwk.IsPublic() {
+		return errors.New("invalid jwk header")
+	}
+
+	var claims jwt.Claims
+	var proofClaims dpopClaims
+	if err := parsedProof.Claims(jwk.Key, &claims, &proofClaims); err != nil {
+		return err
+	}
+
+	lifetimeSecs := v.DPoPLifetimeSecs
+	if lifetimeSecs == 0 {
+		lifetimeSecs = defaultDPoPLifetimeSecs
+	}
+	if claims.IssuedAt == nil ||
+		time.Since(claims.IssuedAt.Time()) > time.Duration(lifetimeSecs)*time.Second ||
+		time.Until(claims.IssuedAt.Time()) > jwtLeeway {
+		return errors.New("invalid iat claim")
+	}
+
+	if claims.ID == "" {
+		return errors.New("invalid jti claim")
+	}
+
+	if v.CheckJTIFunc != nil {
+		if err := v.CheckJTIFunc(r.Context(), claims.ID); err != nil {
+			return err
+		}
+	}
+
+	if proofClaims.HTTPMethod != r.Method {
+		return errors.New("invalid htm claim")
+	}
+
+	// The query and fragment components of the htu must be ignored.
+	htu, _, _ := strings.Cut(proofClaims.HTTPURI, "?")
+	htu, _, _ = strings.Cut(htu, "#")
+	if !strings.EqualFold(htu, v.requestURL(r)) {
+		return errors.New("invalid htu claim")
+	}
+
+	if proofClaims.AccessTokenHash != hashBase64URLSHA256(token) {
+		return errors.New("invalid ath claim")
+	}
+
+	thumbprint, err := jwk.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return err
+	}
+	if base64.RawURLEncoding.EncodeToString(thumbprint) != jkt {
+		return errors.New("the dpop key doesn't match the token binding")
+	}
+
+	return nil
+}
+
+func (v *Validator) validateClientCert(r *http.Request, certThumbprint string) error {
+	clientCertFunc := v.ClientCertFunc
+	if clientCertFunc == nil {
+		clientCertFunc = tlsClientCert
+	}
+
+	cert, err := clientCertFunc(r)
+	if err != nil {
+		return err
+	}
+
+	thumbprint := hashBase64URLSHA256(string(cert.Raw))
+	if subtle.ConstantTimeCompare([]byte(thumbprint), []byte(certThumbprint)) != 1 {
+		return errors.New("the client certificate doesn't match the token binding")
+	}
+
+	return nil
+}
+
+// requestURL returns the URL of the request without query and fragment.
+func (v *Validator) requestURL(r *http.Request) string {
+	if v.BaseURL != "" {
+		return strings.TrimSuffix(v.BaseURL, "/") + r.URL.Path
+	}
+
+	scheme := "https"
+	if r.TLS == nil {
+		scheme = "http"
+	}
+	return scheme + "://" + r.Host + r.URL.Path
+}
+
+func tlsClientCert(r *http.Request) (*x509.Certificate, error) {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return nil, errors.New("the client certificate was not informed")
+	}
+	return r.TLS.PeerCertificates[0], nil
+}
+
+func hashBase64URLSHA256(s string) string {
+	hash := sha256.Sum256([]byte(s))
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
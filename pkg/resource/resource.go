@@ -0,0 +1,398 @@
+package resource
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/luikyv/go-oidc/internal/jwtutil"
+	"github.com/luikyv/go-oidc/internal/strutil"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+const (
+	// defaultDPoPLifetimeSecs is how old a DPoP proof can be to be accepted.
+	defaultDPoPLifetimeSecs = 300
+	// jwtLeeway is the clock skew tolerated when validating JWTs.
+	jwtLeeway = time.Minute
+	// jwksRefreshInterval is the minimum time between two fetches of the keys
+	// from JWKSURI, so tokens with unknown key IDs cannot make the validator
+	// request the provider on every call.
+	jwksRefreshInterval = 10 * time.Second
+)
+
+type tokenInfoKey struct{}
+
+// TokenInfo returns the information of the access token validated by
+// [Validator.Middleware].
+func TokenInfo(ctx context.Context) (goidc.TokenInfo, bool) {
+	info, ok := ctx.Value(tokenInfoKey{}).(goidc.TokenInfo)
+	return info, ok
+}
+
+// Validator validates access tokens issued by an OpenID Provider.
+// JWT access tokens are verified with the provider's keys. Other tokens are
+// sent to the provider's introspection endpoint.
+type Validator struct {
+	// Issuer is the issuer of the provider. It is required to validate JWT
+	// access tokens.
+	Issuer string
+	// JWKS contains the public keys of the provider used to verify JWT access
+	// tokens. If nil, the keys are fetched from JWKSURI.
+	JWKS    *jose.JSONWebKeySet
+	JWKSURI string
+	// SigAlgs are the algorithms accepted for JWT access tokens.
+	// If empty, RS256 and PS256 are accepted.
+	SigAlgs []jose.SignatureAlgorithm
+	// IntrospectionEndpoint is used to validate opaque tokens. If empty,
+	// only JWT access tokens are accepted.
+	IntrospectionEndpoint string
+	// ClientID and ClientSecret authenticate the resource server at the
+	// introspection endpoint with client_secret_post.
+	ClientID     string
+	ClientSecret string
+	// IntrospectionToken is an access token used as credential at the
+	// introspection endpoint instead of the client credentials.
+	IntrospectionToken string
+	// Audience, if informed, must be among the audiences of the tokens.
+	Audience string
+	// DPoPSigAlgs are the algorithms accepted for DPoP proofs.
+	// If empty, RS256, PS256 and ES256 are accepted.
+	DPoPSigAlgs      []jose.SignatureAlgorithm
+	DPoPLifetimeSecs int
+	// CheckJTIFunc, if informed, is used to prevent DPoP proofs from being
+	// replayed.
+	CheckJTIFunc goidc.CheckJTIFunc
+	// ClientCertFunc returns the certificate the client presented.
+	// If nil, the first TLS peer certificate of the request is used.
+	ClientCertFunc goidc.ClientCertFunc
+	// BaseURL is the external URL of the resource server used to validate the
+	// htu claim of DPoP proofs, e.g. "https://api.example.com". If empty, it is
+	// derived from the request.
+	BaseURL    string
+	HTTPClient *http.Client
+
+	// mu protects the keys cached from JWKSURI.
+	mu            sync.Mutex
+	cachedJWKS    jose.JSONWebKeySet
+	jwksFetchedAt time.Time
+}
+
+// Middleware returns a middleware that only lets requests carrying a valid
+// access token with all the scopes informed reach the next handler.
+// The token information is added to the request context and can be obtained
+// with [TokenInfo].
+func (v *Validator) Middleware(scopes ...string) goidc.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			info, err := v.Validate(r, scopes...)
+			if err != nil {
+				writeError(w, r, err)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), tokenInfoKey{}, info)))
+		})
+	}
+}
+
+// Validate validates the access token sent in the request and makes sure it
+// was granted all the scopes informed.
+func (v *Validator) Validate(r *http.Request, scopes ...string) (goidc.TokenInfo, error) {
+	token, tokenType, ok := authorizationToken(r)
+	if !ok {
+		return goidc.TokenInfo{}, goidc.NewError(goidc.ErrorCodeInvalidToken,
+			"the access token is missing")
+	}
+
+	info, err := v.TokenInfo(r.Context(), token)
+	if err != nil {
+		return goidc.TokenInfo{}, err
+	}
+
+	if !info.IsActive {
+		return info, goidc.NewError(goidc.ErrorCodeInvalidToken, "the token is not active")
+	}
+
+	if v.Audience != "" && !slices.Contains(info.ResourceAudiences, v.Audience) {
+		return info, goidc.NewError(goidc.ErrorCodeInvalidToken, "invalid audience")
+	}
+
+	if err := v.validatePoP(r, token, tokenType, info); err != nil {
+		info.IsActive = false
+		info.Reason = goidc.TokenInactiveReasonCnfMismatch
+		return info, err
+	}
+
+	grantedScopes := strutil.SplitWithSpaces(info.Scopes)
+	for _, scope := range scopes {
+		if !slices.Contains(grantedScopes, scope) {
+			return info, goidc.NewError(goidc.ErrorCodeInsufficientScope,
+				"the token doesn't have the scope "+scope)
+		}
+	}
+
+	return info, nil
+}
+
+// TokenInfo returns the information of an access token.
+// JWT tokens are validated locally, other tokens are introspected.
+func (v *Validator) TokenInfo(ctx context.Context, token string) (goidc.TokenInfo, error) {
+	if jwtutil.IsJWS(token) {
+		return v.jwtTokenInfo(ctx, token)
+	}
+
+	if v.IntrospectionEndpoint == "" {
+		return goidc.TokenInfo{}, goidc.NewError(goidc.ErrorCodeInvalidToken, "invalid token")
+	}
+
+	return v.introspect(ctx, token)
+}
+
+func (v *Validator) jwtTokenInfo(ctx context.Context, token string) (goidc.TokenInfo, error) {
+	// Without the issuer, tokens signed with the same keys for another
+	// purpose could be accepted.
+	if v.Issuer == "" {
+		return goidc.TokenInfo{}, errors.New("the issuer of the validator is required to validate jwt access tokens")
+	}
+
+	sigAlgs := v.SigAlgs
+	if len(sigAlgs) == 0 {
+		sigAlgs = []jose.SignatureAlgorithm{jose.RS256, jose.PS256}
+	}
+
+	parsedToken, err := jwt.ParseSigned(token, sigAlgs)
+	if err != nil || len(parsedToken.Headers) != 1 {
+		return goidc.TokenInfo{}, goidc.Errorf(goidc.ErrorCodeInvalidToken, "invalid token", err)
+	}
+
+	// RFC 9068. Other JWTs signed by the provider, e.g. ID tokens, must not be
+	// accepted as access tokens.
+	if !isAccessTokenType(parsedToken.Headers[0]) {
+		return goidc.TokenInfo{}, goidc.NewError(goidc.ErrorCodeInvalidToken, "invalid token type")
+	}
+
+	key, err := v.publicKey(ctx, parsedToken.Headers[0].KeyID)
+	if err != nil {
+		return goidc.TokenInfo{}, goidc.Errorf(goidc.ErrorCodeInvalidToken, "invalid token", err)
+	}
+
+	var claims jwt.Claims
+	var rawClaims map[string]any
+	if err := parsedToken.Claims(key.Key, &claims, &rawClaims); err != nil {
+		return goidc.TokenInfo{}, goidc.Errorf(goidc.ErrorCodeInvalidToken, "invalid token", err)
+	}
+
+	// The expiration is not required by the library, but access tokens
+	// without it would be valid forever.
+	if claims.Expiry == nil {
+		return goidc.TokenInfo{}, goidc.NewError(goidc.ErrorCodeInvalidToken, "the token has no expiration")
+	}
+
+	err = claims.ValidateWithLeeway(jwt.Expected{Issuer: v.Issuer}, jwtLeeway)
+	if errors.Is(err, jwt.ErrExpired) {
+		return goidc.TokenInfo{IsActive: false, Reason: goidc.TokenInactiveReasonExpired}, nil
+	}
+	if err != nil {
+		return goidc.TokenInfo{}, goidc.Errorf(goidc.ErrorCodeInvalidToken, "invalid token", err)
+	}
+
+	// Decode the claims again so they are mapped to the token information the
+	// same way an introspection response is.
+	claimsBytes, err := json.Marshal(rawClaims)
+	if err != nil {
+		return goidc.TokenInfo{}, err
+	}
+
+	info, err := decodeTokenInfo(claimsBytes)
+	if err != nil {
+		return goidc.TokenInfo{}, goidc.Errorf(goidc.ErrorCodeInvalidToken, "invalid token", err)
+	}
+	info.IsActive = true
+	return info, nil
+}
+
+// isAccessTokenType returns whether the typ header identifies a JWT access
+// token, i.e. "at+jwt" or its full media type "application/at+jwt".
+func isAccessTokenType(header jose.Header) bool {
+	typ, _ := header.ExtraHeaders[jose.HeaderType].(string)
+	typ = strings.TrimPrefix(strings.ToLower(typ), "application/")
+	return typ == "at+jwt"
+}
+
+func (v *Validator) introspect(ctx context.Context, token string) (goidc.TokenInfo, error) {
+	params := url.Values{}
+	params.Set("token", token)
+	if v.IntrospectionToken == "" {
+		params.Set("client_id", v.ClientID)
+		params.Set("client_secret", v.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, v.IntrospectionEndpoint,
+		strings.NewReader(params.Encode()))
+	if err != nil {
+		return goidc.TokenInfo{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if v.IntrospectionToken != "" {
+		req.Header.Set("Authorization", string(goidc.TokenTypeBearer)+" "+v.IntrospectionToken)
+	}
+
+	resp, err := v.httpClient().Do(req)
+	if err != nil {
+		return goidc.TokenInfo{}, fmt.Errorf("could not introspect the token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return goidc.TokenInfo{}, fmt.Errorf("the introspection endpoint returned status %d",
+			resp.StatusCode)
+	}
+
+	var body json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return goidc.TokenInfo{}, fmt.Errorf("could not decode the introspection response: %w", err)
+	}
+	return decodeTokenInfo(body)
+}
+
+// decodeTokenInfo maps the claims of a token or an introspection response into
+// [goidc.TokenInfo]. Non standard claims are kept in AdditionalTokenClaims.
+func decodeTokenInfo(data []byte) (goidc.TokenInfo, error) {
+	type tokenInfo goidc.TokenInfo
+	var info tokenInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return goidc.TokenInfo{}, err
+	}
+
+	var claims map[string]any
+	if err := json.Unmarshal(data, &claims); err != nil {
+		return goidc.TokenInfo{}, err
+	}
+
+	if reason, ok := claims[goidc.ClaimInactiveReason].(string); ok {
+		info.Reason = goidc.TokenInactiveReason(reason)
+	}
+
+	for _, claim := range []string{"active", "token_type", goidc.ClaimScope,
+		goidc.ClaimAuthDetails, goidc.ClaimAudience, goidc.ClaimClientID,
+		goidc.ClaimSubject, goidc.ClaimExpiry, "cnf", goidc.ClaimInactiveReason} {
+		delete(claims, claim)
+	}
+	if len(claims) != 0 {
+		info.AdditionalTokenClaims = claims
+	}
+
+	return goidc.TokenInfo(info), nil
+}
+
+func (v *Validator) publicKey(ctx context.Context, keyID string) (jose.JSONWebKey, error) {
+	if v.JWKS != nil {
+		keys := v.JWKS.Key(keyID)
+		if len(keys) == 0 {
+			return jose.JSONWebKey{}, fmt.Errorf("the key %s was not found", keyID)
+		}
+		return keys[0], nil
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if keys := v.cachedJWKS.Key(keyID); len(keys) != 0 {
+		return keys[0], nil
+	}
+
+	// The key might have been rotated, so fetch the keys again if they were
+	// not fetched recently.
+	now := time.Now()
+	if !v.jwksFetchedAt.IsZero() && now.Sub(v.jwksFetchedAt) < jwksRefreshInterval {
+		return jose.JSONWebKey{}, fmt.Errorf("the key %s was not found", keyID)
+	}
+	v.jwksFetchedAt = now
+
+	jwks, err := v.fetchJWKS(ctx)
+	if err != nil {
+		return jose.JSONWebKey{}, err
+	}
+	v.cachedJWKS = jwks
+
+	keys := jwks.Key(keyID)
+	if len(keys) == 0 {
+		return jose.JSONWebKey{}, fmt.Errorf("the key %s was not found", keyID)
+	}
+	return keys[0], nil
+}
+
+func (v *Validator) fetchJWKS(ctx context.Context) (jose.JSONWebKeySet, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.JWKSURI, nil)
+	if err != nil {
+		return jose.JSONWebKeySet{}, err
+	}
+
+	resp, err := v.httpClient().Do(req)
+	if err != nil {
+		return jose.JSONWebKeySet{}, fmt.Errorf("could not fetch the jwks: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return jose.JSONWebKeySet{}, fmt.Errorf("the jwks uri returned status %d", resp.StatusCode)
+	}
+
+	var jwks jose.JSONWebKeySet
+	if err := json.NewDecoder(resp.Body).Decode(&jwks); err != nil {
+		return jose.JSONWebKeySet{}, fmt.Errorf("could not decode the jwks: %w", err)
+	}
+	return jwks, nil
+}
+
+func (v *Validator) httpClient() *http.Client {
+	if v.HTTPClient == nil {
+		return http.DefaultClient
+	}
+	return v.HTTPClient
+}
+
+func authorizationToken(r *http.Request) (string, goidc.TokenType, bool) {
+	tokenType, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || token == "" {
+		return "", "", false
+	}
+
+	switch {
+	case strings.EqualFold(tokenType, string(goidc.TokenTypeBearer)):
+		return token, goidc.TokenTypeBearer, true
+	case strings.EqualFold(tokenType, string(goidc.TokenTypeDPoP)):
+		return token, goidc.TokenTypeDPoP, true
+	default:
+		return "", "", false
+	}
+}
+
+// writeError writes the error as described in RFC 6750.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	var oidcErr goidc.Error
+	if !errors.As(err, &oidcErr) {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	scheme := goidc.TokenTypeBearer
+	if _, tokenType, ok := authorizationToken(r); ok {
+		scheme = tokenType
+	}
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf(`%s error="%s", error_description="%s"`,
+		scheme, oidcErr.Code, oidcErr.Description))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(oidcErr.Code.StatusCode())
+	_ = json.NewEncoder(w).Encode(oidcErr)
+}
@@ -0,0 +1,320 @@
+package resource_test
+
+import (
+	"crypto"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/luikyv/go-oidc/internal/jwtutil"
+	"github.com/luikyv/go-oidc/internal/oidctest"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+	"github.com/luikyv/go-oidc/pkg/resource"
+	"github.com/luikyv/go-oidc/pkg/rp"
+)
+
+func TestMiddleware_JWTToken(t *testing.T) {
+	// Given.
+	validator, jwk := setUpValidator(t)
+	token := accessToken(t, jwk, map[string]any{
+		"scope": "scope1 scope2",
+		"aud":   "https://api.example.com",
+	})
+
+	var info goidc.TokenInfo
+	handler := validator.Middleware("scope1")(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			info, _ = resource.TokenInfo(r.Context())
+		},
+	))
+
+	req := httptest.NewRequest(http.MethodGet, "https://api.example.com/resource", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+
+	// When.
+	handler.ServeHTTP(w, req)
+
+	// Then.
+	if w.Code != http.StatusOK {
+		t.Fatalf("status code = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	if !info.IsActive || info.Subject != "random_subject" || info.Scopes != "scope1 scope2" {
+		t.Errorf("unexpected token info: %+v", info)
+	}
+}
+
+func TestMiddleware_InsufficientScope(t *testing.T) {
+	// Given.
+	validator, jwk := setUpValidator(t)
+	token := accessToken(t, jwk, map[string]any{
+		"scope": "scope2",
+		"aud":   "https://api.example.com",
+	})
+	handler := validator.Middleware("scope1")(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {},
+	))
+
+	req := httptest.NewRequest(http.MethodGet, "https://api.example.com/resource", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+
+	// When.
+	handler.ServeHTTP(w, req)
+
+	// Then.
+	if w.Code != http.StatusForbidden {
+		t.Errorf("status code = %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
+
+func TestValidate_InvalidAudience(t *testing.T) {
+	// Given.
+	validator, jwk := setUpValidator(t)
+	token := accessToken(t, jwk, map[string]any{
+		"aud": "https://other.example.com",
+	})
+	req := httptest.NewRequest(http.MethodGet, "https://api.example.com/resource", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	// When.
+	_, err := validator.Validate(req)
+
+	// Then.
+	if err == nil {
+		t.Fatal("the audience must be validated")
+	}
+}
+
+func TestValidate_IDTokenRejected(t *testing.T) {
+	// Given.
+	validator, jwk := setUpValidator(t)
+	now := time.Now().Unix()
+	idToken, err := jwtutil.Sign(map[string]any{
+		"iss": "https://op.example.com",
+		"sub": "random_subject",
+		"aud": "https://api.example.com",
+		"iat": now,
+		"exp": now + 60,
+	}, jwk, (&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", jwk.KeyID))
+	if err != nil {
+		t.Fatalf("could not sign the token: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "https://api.example.com/resource", nil)
+	req.Header.Set("Authorization", "Bearer "+idToken)
+
+	// When.
+	_, err = validator.Validate(req)
+
+	// Then.
+	if err == nil {
+		t.Fatal("tokens other than access tokens must be rejected")
+	}
+}
+
+func TestValidate_OpaqueToken(t *testing.T) {
+	// Given.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.PostFormValue("client_id") != "random_rs" || r.PostFormValue("token") != "opaque_token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"active":       true,
+			"scope":        "scope1",
+			"sub":          "random_subject",
+			"random_claim": "random_value",
+		})
+	}))
+	defer server.Close()
+
+	validator := &resource.Validator{
+		IntrospectionEndpoint: server.URL,
+		ClientID:              "random_rs",
+		ClientSecret:          "random_secret",
+	}
+	req := httptest.NewRequest(http.MethodGet, "https://api.example.com/resource", nil)
+	req.Header.Set("Authorization", "Bearer opaque_token")
+
+	// When.
+	info, err := validator.Validate(req, "scope1")
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if info.Subject != "random_subject" {
+		t.Errorf("Subject = %s, want random_subject", info.Subject)
+	}
+
+	if info.AdditionalTokenClaims["random_claim"] != "random_value" {
+		t.Errorf("AdditionalTokenClaims = %v, want random_claim", info.AdditionalTokenClaims)
+	}
+}
+
+func TestValidate_DPoP(t *testing.T) {
+	// Given.
+	validator, jwk := setUpValidator(t)
+	dpopJWK := oidctest.PrivatePS256JWK(t, "dpop_key", goidc.KeyUsageSignature)
+	dpopPublicJWK := dpopJWK.Public()
+	jkt, _ := dpopPublicJWK.Thumbprint(crypto.SHA256)
+	token := accessToken(t, jwk, map[string]any{
+		"aud": "https://api.example.com",
+		"cnf": map[string]any{"jkt": base64.RawURLEncoding.EncodeToString(jkt)},
+	})
+
+	client := rp.Client{DPoPJWK: &dpopJWK}
+	req := httptest.NewRequest(http.MethodGet, "https://api.example.com/resource", nil)
+	if err := client.AuthorizeRequest(req, rp.TokenResponse{
+		AccessToken: token,
+		TokenType:   goidc.TokenTypeDPoP,
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// When.
+	_, err := validator.Validate(req)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestValidate_DPoPProofMissing(t *testing.T) {
+	// Given.
+	validator, jwk := setUpValidator(t)
+	token := accessToken(t, jwk, map[string]any{
+		"aud": "https://api.example.com",
+		"cnf": map[string]any{"jkt": "random_thumbprint"},
+	})
+	req := httptest.NewRequest(http.MethodGet, "https://api.example.com/resource", nil)
+	req.Header.Set("Authorization", "DPoP "+token)
+
+	// When.
+	info, err := validator.Validate(req)
+
+	// Then.
+	if err == nil {
+		t.Fatal("the dpop proof must be required")
+	}
+
+	if info.Reason != goidc.TokenInactiveReasonCnfMismatch {
+		t.Errorf("Reason = %s, want %s", info.Reason, goidc.TokenInactiveReasonCnfMismatch)
+	}
+}
+
+func TestValidate_TokenWithoutExpiry(t *testing.T) {
+	// Given.
+	validator, jwk := setUpValidator(t)
+	token := accessToken(t, jwk, map[string]any{
+		"aud": "https://api.example.com",
+		"exp": nil,
+	})
+	req := httptest.NewRequest(http.MethodGet, "/accounts", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	// When.
+	_, err := validator.Validate(req)
+
+	// Then.
+	var oidcErr goidc.Error
+	if !errors.As(err, &oidcErr) || oidcErr.Code != goidc.ErrorCodeInvalidToken {
+		t.Fatalf("the token must be rejected with invalid_token, got %v", err)
+	}
+}
+
+func TestValidate_IssuerRequired(t *testing.T) {
+	// Given.
+	validator, jwk := setUpValidator(t)
+	validator.Issuer = ""
+	token := accessToken(t, jwk, map[string]any{"aud": "https://api.example.com"})
+	req := httptest.NewRequest(http.MethodGet, "/accounts", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	// When.
+	_, err := validator.Validate(req)
+
+	// Then.
+	if err == nil {
+		t.Fatal("jwt access tokens cannot be validated without the issuer")
+	}
+}
+
+func TestValidate_JWKSRefreshLimited(t *testing.T) {
+	// Given.
+	jwk := oidctest.PrivatePS256JWK(t, "server_key", goidc.KeyUsageSignature)
+	fetches := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fetches++
+		_ = json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{jwk.Public()}})
+	}))
+	defer server.Close()
+
+	validator := &resource.Validator{
+		Issuer:  "https://op.example.com",
+		JWKSURI: server.URL,
+	}
+	unknownJWK := oidctest.PrivatePS256JWK(t, "unknown_key", goidc.KeyUsageSignature)
+
+	// When.
+	for range 3 {
+		req := httptest.NewRequest(http.MethodGet, "/accounts", nil)
+		req.Header.Set("Authorization", "Bearer "+accessToken(t, unknownJWK, nil))
+		_, _ = validator.Validate(req)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/accounts", nil)
+	req.Header.Set("Authorization", "Bearer "+accessToken(t, jwk, nil))
+	_, err := validator.Validate(req)
+
+	// Then.
+	if fetches != 1 {
+		t.Errorf("fetches = %d, want 1", fetches)
+	}
+
+	if err != nil {
+		t.Errorf("the keys fetched must still be used: %v", err)
+	}
+}
+
+func setUpValidator(t *testing.T) (*resource.Validator, jose.JSONWebKey) {
+	t.Helper()
+
+	jwk := oidctest.PrivatePS256JWK(t, "server_key", goidc.KeyUsageSignature)
+	return &resource.Validator{
+		Issuer:   "https://op.example.com",
+		JWKS:     &jose.JSONWebKeySet{Keys: []jose.JSONWebKey{jwk.Public()}},
+		Audience: "https://api.example.com",
+	}, jwk
+}
+
+func accessToken(t *testing.T, jwk jose.JSONWebKey, claims map[string]any) string {
+	t.Helper()
+
+	now := time.Now().Unix()
+	tokenClaims := map[string]any{
+		"iss":       "https://op.example.com",
+		"sub":       "random_subject",
+		"client_id": "random_client_id",
+		"jti":       "random_jti",
+		"iat":       now,
+		"exp":       now + 60,
+	}
+	for k, v := range claims {
+		tokenClaims[k] = v
+	}
+
+	token, err := jwtutil.Sign(tokenClaims, jwk,
+		(&jose.SignerOptions{}).WithType("at+jwt").WithHeader("kid", jwk.KeyID))
+	if err != nil {
+		t.Fatalf("could not sign the token: %v", err)
+	}
+	return token
+}
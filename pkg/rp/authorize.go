@@ -0,0 +1,238 @@
+package rp
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/luikyv/go-oidc/internal/jwtutil"
+	"github.com/luikyv/go-oidc/internal/strutil"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+// AuthorizationRequest holds the values generated for an authorization request
+// which must be kept until the authorization response is received.
+type AuthorizationRequest struct {
+	State        string
+	Nonce        string
+	CodeVerifier string
+	// Params are additional parameters sent in the authorization request,
+	// e.g. "prompt" or "acr_values".
+	Params url.Values
+}
+
+// NewAuthorizationRequest generates a random state, nonce and PKCE code
+// verifier for a new authorization request.
+func NewAuthorizationRequest() AuthorizationRequest {
+	return AuthorizationRequest{
+		State:        strutil.Random(stateLength),
+		Nonce:        strutil.Random(nonceLength),
+		CodeVerifier: strutil.Random(codeVerifierLength),
+		Params:       url.Values{},
+	}
+}
+
+// CodeChallenge returns the S256 PKCE code challenge for the code verifier.
+func (r AuthorizationRequest) CodeChallenge() string {
+	hash := sha256.Sum256([]byte(r.CodeVerifier))
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
+
+// AuthorizationURL returns the URL the user must be redirected to in order to
+// authorize the client.
+// If PAR is enabled, the parameters are pushed to the provider first. If JAR is
+// enabled, they are sent inside a signed request object.
+func (c Client) AuthorizationURL(
+	ctx context.Context,
+	authReq AuthorizationRequest,
+) (
+	string,
+	error,
+) {
+	params := c.authorizationParams(authReq)
+
+	if c.JARIsEnabled {
+		requestObject, err := c.requestObject(params)
+		if err != nil {
+			return "", fmt.Errorf("could not create the request object: %w", err)
+		}
+		params = url.Values{}
+		params.Set("client_id", c.ID)
+		params.Set("request", requestObject)
+	}
+
+	if c.PARIsEnabled {
+		requestURI, err := c.pushAuthorizationRequest(ctx, params)
+		if err != nil {
+			return "", fmt.Errorf("could not push the authorization request: %w", err)
+		}
+		params = url.Values{}
+		params.Set("client_id", c.ID)
+		params.Set("request_uri", requestURI)
+	}
+
+	// OpenID Connect requires response_type and scope to be sent as query
+	// parameters even when they are informed inside a request object or pushed.
+	params.Set("response_type", string(goidc.ResponseTypeCode))
+	params.Set("scope", c.scopes())
+	return c.Provider.AuthorizationEndpoint + "?" + params.Encode(), nil
+}
+
+// ParseAuthorizationResponse validates the parameters sent by the provider to
+// the redirect URI and returns the authorization code.
+// If the provider returned an error, it is returned as a [goidc.Error].
+func (c Client) ParseAuthorizationResponse(
+	authReq AuthorizationRequest,
+	params url.Values,
+) (
+	string,
+	error,
+) {
+	if params.Get("state") != authReq.State {
+		return "", errors.New("invalid state")
+	}
+
+	// The issuer is only validated if the provider informs it, see RFC 9207.
+	if iss := params.Get("iss"); iss != "" && iss != c.Provider.Issuer {
+		return "", errors.New("invalid issuer")
+	}
+
+	if errCode := params.Get("error"); errCode != "" {
+		return "", goidc.NewError(goidc.ErrorCode(errCode), params.Get("error_description"))
+	}
+
+	code := params.Get("code")
+	if code == "" {
+		return "", errors.New("the authorization code is missing")
+	}
+
+	return code, nil
+}
+
+func (c Client) authorizationParams(authReq AuthorizationRequest) url.Values {
+	params := url.Values{}
+	for k, v := range authReq.Params {
+		params[k] = v
+	}
+
+	params.Set("response_type", string(goidc.ResponseTypeCode))
+	params.Set("client_id", c.ID)
+	params.Set("redirect_uri", c.RedirectURI)
+	params.Set("scope", c.scopes())
+	params.Set("state", authReq.State)
+	params.Set("nonce", authReq.Nonce)
+	params.Set("code_challenge", authReq.CodeChallenge())
+	params.Set("code_challenge_method", string(goidc.CodeChallengeMethodSHA256))
+	return params
+}
+
+// scopes returns the scopes requested by the client. If none are configured,
+// only openid is requested.
+func (c Client) scopes() string {
+	if len(c.Scopes) == 0 {
+		return goidc.ScopeOpenID.ID
+	}
+	return strings.Join(c.Scopes, " ")
+}
+
+func (c Client) requestObject(params url.Values) (string, error) {
+	if c.JWK == nil {
+		return "", errors.New("a jwk is required to sign request objects")
+	}
+
+	now := time.Now().Unix()
+	claims := map[string]any{
+		goidc.ClaimIssuer:   c.ID,
+		goidc.ClaimAudience: c.Provider.Issuer,
+		goidc.ClaimIssuedAt: now,
+		"nbf":               now,
+		goidc.ClaimExpiry:   now + assertionLifetimeSecs,
+		goidc.ClaimTokenID:  strutil.Random(jtiLength),
+	}
+	for k := range params {
+		claims[k] = params.Get(k)
+	}
+
+	return jwtutil.Sign(claims, *c.JWK,
+		(&jose.SignerOptions{}).WithType("oauth-authz-req+jwt").WithHeader("kid", c.JWK.KeyID))
+}
+
+func (c Client) pushAuthorizationRequest(
+	ctx context.Context,
+	params url.Values,
+) (
+	string,
+	error,
+) {
+	if err := c.setAuthnParams(params, c.Provider.Issuer); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.Provider.PAREndpoint,
+		strings.NewReader(params.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return "", responseError(resp)
+	}
+
+	var parResp struct {
+		RequestURI string `json:"request_uri"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&parResp); err != nil {
+		return "", err
+	}
+
+	return parResp.RequestURI, nil
+}
+
+// setAuthnParams adds the parameters needed to authenticate the client.
+// If a secret is informed, client_secret_post is used. Otherwise, if a jwk is
+// informed, private_key_jwt is used. If none of them are available, only the
+// client ID is sent.
+func (c Client) setAuthnParams(params url.Values, audience string) error {
+	params.Set("client_id", c.ID)
+
+	if c.Secret != "" {
+		params.Set("client_secret", c.Secret)
+		return nil
+	}
+
+	if c.JWK == nil {
+		return nil
+	}
+
+	now := time.Now().Unix()
+	assertion, err := jwtutil.Sign(map[string]any{
+		goidc.ClaimIssuer:   c.ID,
+		goidc.ClaimSubject:  c.ID,
+		goidc.ClaimAudience: audience,
+		goidc.ClaimIssuedAt: now,
+		goidc.ClaimExpiry:   now + assertionLifetimeSecs,
+		goidc.ClaimTokenID:  strutil.Random(jtiLength),
+	}, *c.JWK, (&jose.SignerOptions{}).WithHeader("kid", c.JWK.KeyID))
+	if err != nil {
+		return fmt.Errorf("could not sign the client assertion: %w", err)
+	}
+
+	params.Set("client_assertion_type", string(goidc.AssertionTypeJWTBearer))
+	params.Set("client_assertion", assertion)
+	return nil
+}
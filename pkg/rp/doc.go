@@ -0,0 +1,25 @@
+// Package rp implements the client side of OpenID Connect and OAuth 2.0.
+//
+// A [Client] holds the registration information of the relying party and
+// the metadata of the OpenID Provider, which can be obtained with [Discover].
+// It builds authorization requests using PKCE and optionally PAR and JAR,
+// exchanges authorization codes for tokens, validates ID tokens and binds
+// access tokens with DPoP.
+//
+//	metadata, err := rp.Discover(ctx, http.DefaultClient, "https://op.example.com")
+//	client := rp.Client{
+//		ID:          "random_client_id",
+//		Secret:      "random_secret",
+//		RedirectURI: "https://rp.example.com/callback",
+//		Scopes:      []string{"openid"},
+//		Provider:    metadata,
+//	}
+//
+//	authReq := rp.NewAuthorizationRequest()
+//	authURL, err := client.AuthorizationURL(ctx, authReq)
+//	// Keep authReq, e.g. in the user session, and redirect the user to authURL.
+//
+//	// At the redirect URI.
+//	code, err := client.ParseAuthorizationResponse(authReq, r.URL.Query())
+//	tokenResp, err := client.Exchange(ctx, authReq, code)
+package rp
@@ -0,0 +1,150 @@
+package rp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+const (
+	stateLength        = 32
+	nonceLength        = 32
+	codeVerifierLength = 64
+	jtiLength          = 32
+	// assertionLifetimeSecs is the lifetime of the client assertions and
+	// request objects created by the client.
+	assertionLifetimeSecs = 60
+	wellKnownPath         = "/.well-known/openid-configuration"
+)
+
+// ProviderMetadata contains the information published by an OpenID Provider
+// at its discovery endpoint that is needed by the client.
+type ProviderMetadata struct {
+	Issuer                string                    `json:"issuer"`
+	AuthorizationEndpoint string                    `json:"authorization_endpoint"`
+	TokenEndpoint         string                    `json:"token_endpoint"`
+	UserInfoEndpoint      string                    `json:"userinfo_endpoint,omitempty"`
+	JWKSURI               string                    `json:"jwks_uri"`
+	PAREndpoint           string                    `json:"pushed_authorization_request_endpoint,omitempty"`
+	IDTokenSigAlgs        []jose.SignatureAlgorithm `json:"id_token_signing_alg_values_supported,omitempty"`
+	DPoPSigAlgs           []jose.SignatureAlgorithm `json:"dpop_signing_alg_values_supported,omitempty"`
+}
+
+// Discover fetches the metadata of the OpenID Provider identified by issuer.
+// The issuer published by the provider must match the one informed.
+// If httpClient is nil, [http.DefaultClient] is used.
+func Discover(
+	ctx context.Context,
+	httpClient *http.Client,
+	issuer string,
+) (
+	ProviderMetadata,
+	error,
+) {
+	url := strings.TrimSuffix(issuer, "/") + wellKnownPath
+	var metadata ProviderMetadata
+	if err := getJSON(ctx, httpClient, url, &metadata); err != nil {
+		return ProviderMetadata{}, fmt.Errorf("could not fetch the provider metadata: %w", err)
+	}
+
+	if metadata.Issuer != issuer {
+		return ProviderMetadata{}, fmt.Errorf("the issuer %s doesn't match %s",
+			metadata.Issuer, issuer)
+	}
+
+	return metadata, nil
+}
+
+// Client holds the information of a relying party registered at an OpenID
+// Provider.
+type Client struct {
+	ID string
+	// Secret is used to authenticate with client_secret_post.
+	Secret string
+	// JWK is the private key used to sign request objects and, when no secret
+	// is informed, to authenticate with private_key_jwt.
+	JWK         *jose.JSONWebKey
+	RedirectURI string
+	Scopes      []string
+	// PARIsEnabled indicates that authorization requests are pushed to the
+	// provider before redirecting the user.
+	PARIsEnabled bool
+	// JARIsEnabled indicates that authorization parameters are sent inside a
+	// request object signed with JWK.
+	JARIsEnabled bool
+	// DPoPJWK is the private key used to generate DPoP proofs.
+	// If informed, access tokens are requested bound to this key.
+	DPoPJWK  *jose.JSONWebKey
+	Provider ProviderMetadata
+	// ProviderJWKS contains the public keys of the provider used to verify ID
+	// tokens. If nil, the keys are fetched from the provider's jwks_uri.
+	ProviderJWKS *jose.JSONWebKeySet
+	// IDTokenLeeway is the clock skew tolerated when validating ID tokens.
+	// If zero, one minute is used.
+	IDTokenLeeway time.Duration
+	HTTPClient    *http.Client
+}
+
+func (c Client) providerJWKS(ctx context.Context) (jose.JSONWebKeySet, error) {
+	if c.ProviderJWKS != nil {
+		return *c.ProviderJWKS, nil
+	}
+
+	var jwks jose.JSONWebKeySet
+	if err := getJSON(ctx, c.HTTPClient, c.Provider.JWKSURI, &jwks); err != nil {
+		return jose.JSONWebKeySet{}, fmt.Errorf("could not fetch the provider jwks: %w", err)
+	}
+	return jwks, nil
+}
+
+func (c Client) idTokenLeeway() time.Duration {
+	if c.IDTokenLeeway == 0 {
+		return defaultIDTokenLeeway
+	}
+	return c.IDTokenLeeway
+}
+
+func (c Client) httpClient() *http.Client {
+	if c.HTTPClient == nil {
+		return http.DefaultClient
+	}
+	return c.HTTPClient
+}
+
+func getJSON(ctx context.Context, httpClient *http.Client, url string, v any) error {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned status %d", url, resp.StatusCode)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// responseError converts an OAuth error response into a [goidc.Error].
+func responseError(resp *http.Response) error {
+	var errResp goidc.Error
+	if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil || errResp.Code == "" {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return errResp
+}
@@ -0,0 +1,287 @@
+package rp_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/luikyv/go-oidc/internal/jwtutil"
+	"github.com/luikyv/go-oidc/internal/oidctest"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+	"github.com/luikyv/go-oidc/pkg/provider"
+	"github.com/luikyv/go-oidc/pkg/rp"
+)
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	// Given.
+	clientJWK := oidctest.PrivatePS256JWK(t, "client_key", goidc.KeyUsageSignature)
+	dpopJWK := oidctest.PrivatePS256JWK(t, "dpop_key", goidc.KeyUsageSignature)
+	server := setUpProvider(t, clientJWK)
+	ctx := context.Background()
+
+	metadata, err := rp.Discover(ctx, server.Client(), server.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	client := rp.Client{
+		ID:           "random_client_id",
+		JWK:          &clientJWK,
+		RedirectURI:  "https://example.com/callback",
+		PARIsEnabled: true,
+		JARIsEnabled: true,
+		DPoPJWK:      &dpopJWK,
+		Provider:     metadata,
+		HTTPClient:   server.Client(),
+	}
+	authReq := rp.NewAuthorizationRequest()
+
+	// When.
+	authURL, err := client.AuthorizationURL(ctx, authReq)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// When.
+	redirectParams := authorize(t, server, authURL)
+	code, err := client.ParseAuthorizationResponse(authReq, redirectParams)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// When.
+	tokenResp, err := client.Exchange(ctx, authReq, code)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if tokenResp.TokenType != goidc.TokenTypeDPoP {
+		t.Errorf("TokenType = %s, want %s", tokenResp.TokenType, goidc.TokenTypeDPoP)
+	}
+
+	if tokenResp.IDTokenClaims[goidc.ClaimSubject] != "random_user" {
+		t.Errorf("sub = %v, want random_user", tokenResp.IDTokenClaims[goidc.ClaimSubject])
+	}
+
+	// When.
+	req, _ := http.NewRequest(http.MethodGet, metadata.UserInfoEndpoint, nil)
+	err = client.AuthorizeRequest(req, tokenResp)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status code = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+}
+
+func TestParseAuthorizationResponse(t *testing.T) {
+	// Given.
+	client := rp.Client{
+		Provider: rp.ProviderMetadata{Issuer: "https://example.com"},
+	}
+	authReq := rp.NewAuthorizationRequest()
+
+	testCases := []struct {
+		name    string
+		params  url.Values
+		wantErr bool
+	}{
+		{
+			name:   "valid response",
+			params: url.Values{"state": {authReq.State}, "code": {"random_code"}},
+		},
+		{
+			name:    "invalid state",
+			params:  url.Values{"state": {"invalid_state"}, "code": {"random_code"}},
+			wantErr: true,
+		},
+		{
+			name: "invalid issuer",
+			params: url.Values{
+				"state": {authReq.State},
+				"code":  {"random_code"},
+				"iss":   {"https://invalid.com"},
+			},
+			wantErr: true,
+		},
+		{
+			name:    "error response",
+			params:  url.Values{"state": {authReq.State}, "error": {"access_denied"}},
+			wantErr: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// When.
+			code, err := client.ParseAuthorizationResponse(authReq, testCase.params)
+
+			// Then.
+			if testCase.wantErr {
+				if err == nil {
+					t.Fatal("an error was expected")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if code != "random_code" {
+				t.Errorf("code = %s, want random_code", code)
+			}
+		})
+	}
+}
+
+func TestValidateIDToken_RequiredClaims(t *testing.T) {
+	serverJWK := oidctest.PrivatePS256JWK(t, "server_key", goidc.KeyUsageSignature)
+	client := rp.Client{
+		ID: "random_client_id",
+		Provider: rp.ProviderMetadata{
+			Issuer:         "https://op.example.com",
+			IDTokenSigAlgs: []jose.SignatureAlgorithm{jose.PS256},
+		},
+		ProviderJWKS: &jose.JSONWebKeySet{Keys: []jose.JSONWebKey{serverJWK.Public()}},
+	}
+
+	testCases := []struct {
+		name    string
+		claim   string
+		wantErr bool
+	}{
+		{"all_claims", "", false},
+		{"missing_exp", goidc.ClaimExpiry, true},
+		{"missing_iat", goidc.ClaimIssuedAt, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given.
+			now := time.Now().Unix()
+			claims := map[string]any{
+				goidc.ClaimIssuer:   "https://op.example.com",
+				goidc.ClaimSubject:  "random_user",
+				goidc.ClaimAudience: "random_client_id",
+				goidc.ClaimIssuedAt: now,
+				goidc.ClaimExpiry:   now + 60,
+			}
+			delete(claims, tc.claim)
+			idToken, err := jwtutil.Sign(claims, serverJWK,
+				(&jose.SignerOptions{}).WithHeader("kid", serverJWK.KeyID))
+			if err != nil {
+				t.Fatalf("could not sign the id token: %v", err)
+			}
+
+			// When.
+			_, err = client.ValidateIDToken(context.Background(), idToken, "")
+
+			// Then.
+			if (err != nil) != tc.wantErr {
+				t.Errorf("err = %v, want error %t", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func setUpProvider(t *testing.T, clientJWK jose.JSONWebKey) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewUnstartedServer(nil)
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	clientJWKS, _ := json.Marshal(jose.JSONWebKeySet{
+		Keys: []jose.JSONWebKey{clientJWK.Public()},
+	})
+	client := &goidc.Client{
+		ID: "random_client_id",
+		ClientMetaInfo: goidc.ClientMetaInfo{
+			TokenAuthnMethod: goidc.ClientAuthnPrivateKeyJWT,
+			RedirectURIs:     []string{"https://example.com/callback"},
+			ScopeIDs:         goidc.ScopeOpenID.ID,
+			GrantTypes:       []goidc.GrantType{goidc.GrantAuthorizationCode},
+			ResponseTypes:    []goidc.ResponseType{goidc.ResponseTypeCode},
+			PublicJWKS:       clientJWKS,
+		},
+	}
+
+	policy := goidc.NewPolicy(
+		"random_policy",
+		func(r *http.Request, c *goidc.Client, s *goidc.AuthnSession) bool {
+			return true
+		},
+		func(w http.ResponseWriter, r *http.Request, s *goidc.AuthnSession) (goidc.AuthnStatus, error) {
+			s.SetUserID("random_user")
+			s.GrantScopes(s.Scopes)
+			return goidc.StatusSuccess, nil
+		},
+	)
+
+	serverJWK := oidctest.PrivatePS256JWK(t, "server_key", goidc.KeyUsageSignature)
+	op, err := provider.New(
+		goidc.ProfileOpenID,
+		server.URL,
+		jose.JSONWebKeySet{Keys: []jose.JSONWebKey{serverJWK}},
+		provider.WithAuthorizationCodeGrant(),
+		provider.WithTokenAuthnMethods(goidc.ClientAuthnPrivateKeyJWT),
+		provider.WithPrivateKeyJWTSignatureAlgs(jose.PS256),
+		provider.WithPKCE(goidc.CodeChallengeMethodSHA256),
+		provider.WithPAR(60),
+		provider.WithJAR(jose.PS256),
+		provider.WithDPoP(jose.PS256),
+		provider.WithStaticClient(client),
+		provider.WithPolicy(policy),
+	)
+	if err != nil {
+		t.Fatalf("could not create the provider: %v", err)
+	}
+	server.Config.Handler = op.Handler()
+
+	return server
+}
+
+// authorize calls the authorization endpoint and returns the parameters the
+// provider redirected to the client with.
+func authorize(t *testing.T, server *httptest.Server, authURL string) url.Values {
+	t.Helper()
+
+	httpClient := *server.Client()
+	httpClient.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	resp, err := httpClient.Get(authURL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	redirectURL, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil || redirectURL.Host != "example.com" {
+		t.Fatalf("invalid redirect url %s, status %d", resp.Header.Get("Location"), resp.StatusCode)
+	}
+
+	return redirectURL.Query()
+}
@@ -0,0 +1,268 @@
+package rp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/luikyv/go-oidc/pkg/dpop"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+// defaultIDTokenLeeway is the clock skew tolerated when validating ID tokens
+// if none is informed.
+const defaultIDTokenLeeway = time.Minute
+
+// TokenResponse is the response of the provider's token endpoint.
+type TokenResponse struct {
+	AccessToken  string          `json:"access_token"`
+	TokenType    goidc.TokenType `json:"token_type"`
+	ExpiresIn    int             `json:"expires_in,omitempty"`
+	RefreshToken string          `json:"refresh_token,omitempty"`
+	IDToken      string          `json:"id_token,omitempty"`
+	Scopes       string          `json:"scope,omitempty"`
+	// IDTokenClaims are the claims of the ID token if one was issued and
+	// validated.
+	IDTokenClaims map[string]any `json:"-"`
+}
+
+// Exchange exchanges the authorization code for tokens.
+// If an ID token is returned, it is validated against the nonce of the
+// authorization request.
+func (c Client) Exchange(
+	ctx context.Context,
+	authReq AuthorizationRequest,
+	code string,
+) (
+	TokenResponse,
+	error,
+) {
+	params := url.Values{}
+	params.Set("grant_type", string(goidc.GrantAuthorizationCode))
+	params.Set("code", code)
+	params.Set("redirect_uri", c.RedirectURI)
+	params.Set("code_verifier", authReq.CodeVerifier)
+
+	tokenResp, err := c.requestToken(ctx, params)
+	if err != nil {
+		return TokenResponse{}, err
+	}
+
+	if tokenResp.IDToken != "" {
+		tokenResp.IDTokenClaims, err = c.ValidateIDToken(ctx, tokenResp.IDToken, authReq.Nonce)
+		if err != nil {
+			return TokenResponse{}, fmt.Errorf("invalid id token: %w", err)
+		}
+	}
+
+	return tokenResp, nil
+}
+
+// Refresh uses a refresh token to obtain new tokens.
+func (c Client) Refresh(
+	ctx context.Context,
+	refreshToken string,
+) (
+	TokenResponse,
+	error,
+) {
+	params := url.Values{}
+	params.Set("grant_type", string(goidc.GrantRefreshToken))
+	params.Set("refresh_token", refreshToken)
+
+	tokenResp, err := c.requestToken(ctx, params)
+	if err != nil {
+		return TokenResponse{}, err
+	}
+
+	// ID tokens returned during a refresh don't carry the nonce.
+	if tokenResp.IDToken != "" {
+		tokenResp.IDTokenClaims, err = c.ValidateIDToken(ctx, tokenResp.IDToken, "")
+		if err != nil {
+			return TokenResponse{}, fmt.Errorf("invalid id token: %w", err)
+		}
+	}
+
+	return tokenResp, nil
+}
+
+// ClientCredentials obtains an access token for the client itself.
+// If no scopes are informed, the client's scopes are requested.
+func (c Client) ClientCredentials(
+	ctx context.Context,
+	scopes ...string,
+) (
+	TokenResponse,
+	error,
+) {
+	params := url.Values{}
+	params.Set("grant_type", string(goidc.GrantClientCredentials))
+	if len(scopes) == 0 {
+		scopes = c.Scopes
+	}
+	if len(scopes) != 0 {
+		params.Set("scope", strings.Join(scopes, " "))
+	}
+
+	return c.requestToken(ctx, params)
+}
+
+// ValidateIDToken verifies the signature and the claims of an ID token issued
+// to the client and returns its claims.
+// If nonce is empty, the nonce claim is not validated.
+func (c Client) ValidateIDToken(
+	ctx context.Context,
+	idToken string,
+	nonce string,
+) (
+	map[string]any,
+	error,
+) {
+	sigAlgs := c.Provider.IDTokenSigAlgs
+	if len(sigAlgs) == 0 {
+		sigAlgs = []jose.SignatureAlgorithm{jose.RS256}
+	}
+
+	parsedIDToken, err := jwt.ParseSigned(idToken, sigAlgs)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(parsedIDToken.Headers) != 1 {
+		return nil, errors.New("invalid id token header")
+	}
+
+	jwks, err := c.providerJWKS(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := jwks.Key(parsedIDToken.Headers[0].KeyID)
+	if len(keys) == 0 {
+		return nil, errors.New("the id token signing key was not found")
+	}
+
+	var claims jwt.Claims
+	var rawClaims map[string]any
+	if err := parsedIDToken.Claims(keys[0].Key, &claims, &rawClaims); err != nil {
+		return nil, err
+	}
+
+	// The library only validates exp and iat when they are present, but both
+	// are required in ID tokens.
+	if claims.Expiry == nil {
+		return nil, errors.New("the id token expiration is missing")
+	}
+
+	if claims.IssuedAt == nil {
+		return nil, errors.New("the id token issued at is missing")
+	}
+
+	if err := claims.ValidateWithLeeway(jwt.Expected{
+		Issuer:      c.Provider.Issuer,
+		AnyAudience: []string{c.ID},
+	}, c.idTokenLeeway()); err != nil {
+		return nil, err
+	}
+
+	if claims.Subject == "" {
+		return nil, errors.New("the id token subject is missing")
+	}
+
+	// If the ID token has multiple audiences, the authorized party must be
+	// the client.
+	if len(claims.Audience) > 1 && rawClaims["azp"] != c.ID {
+		return nil, errors.New("invalid azp claim")
+	}
+
+	if nonce != "" && rawClaims[goidc.ClaimNonce] != nonce {
+		return nil, errors.New("invalid nonce")
+	}
+
+	return rawClaims, nil
+}
+
+func (c Client) requestToken(
+	ctx context.Context,
+	params url.Values,
+) (
+	TokenResponse,
+	error,
+) {
+	if err := c.setAuthnParams(params, c.Provider.Issuer); err != nil {
+		return TokenResponse{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.Provider.TokenEndpoint,
+		strings.NewReader(params.Encode()))
+	if err != nil {
+		return TokenResponse{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	if c.DPoPJWK != nil {
+		proof, err := dpop.NewProof(*c.DPoPJWK, dpop.ProofOptions{
+			Method: http.MethodPost,
+			URI:    c.Provider.TokenEndpoint,
+		})
+		if err != nil {
+			return TokenResponse{}, fmt.Errorf("could not create the dpop proof: %w", err)
+		}
+		req.Header.Set(goidc.HeaderDPoP, proof)
+	}
+
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return TokenResponse{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return TokenResponse{}, responseError(resp)
+	}
+
+	var tokenResp TokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+		return TokenResponse{}, err
+	}
+
+	if c.DPoPJWK != nil && !strings.EqualFold(string(tokenResp.TokenType),
+		string(goidc.TokenTypeDPoP)) {
+		return TokenResponse{}, errors.New("the access token was not bound with dpop")
+	}
+
+	return tokenResp, nil
+}
+
+// AuthorizeRequest sets the access token in the request's Authorization header.
+// If the token is bound with DPoP, a proof for the request is also added.
+func (c Client) AuthorizeRequest(req *http.Request, tokenResp TokenResponse) error {
+	if !strings.EqualFold(string(tokenResp.TokenType), string(goidc.TokenTypeDPoP)) {
+		req.Header.Set("Authorization", string(goidc.TokenTypeBearer)+" "+tokenResp.AccessToken)
+		return nil
+	}
+
+	if c.DPoPJWK == nil {
+		return errors.New("a dpop jwk is required to use dpop bound tokens")
+	}
+
+	proof, err := dpop.NewProof(*c.DPoPJWK, dpop.ProofOptions{
+		Method:      req.Method,
+		URI:         req.URL.String(),
+		AccessToken: tokenResp.AccessToken,
+	})
+	if err != nil {
+		return fmt.Errorf("could not create the dpop proof: %w", err)
+	}
+
+	req.Header.Set("Authorization", string(goidc.TokenTypeDPoP)+" "+tokenResp.AccessToken)
+	req.Header.Set(goidc.HeaderDPoP, proof)
+	return nil
+}
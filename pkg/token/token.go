@@ -0,0 +1,133 @@
+// Package token contains helpers for resource servers and tests verifying
+// tokens issued by the provider with the same rules the provider applies, e.g.
+// during introspection.
+//
+//	verifier := token.Verifier{Issuer: "https://op.example.com", JWKS: jwks}
+//	claims, err := verifier.ValidClaims(accessToken)
+package token
+
+import (
+	"slices"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/luikyv/go-oidc/internal/jwtutil"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+// Verifier validates JWTs signed by the provider.
+type Verifier struct {
+	Issuer string
+	// IssuerAliases are former issuers of the provider whose tokens are still
+	// accepted, e.g. during an issuer migration.
+	IssuerAliases []string
+	// JWKS contains the public keys of the provider. Only keys meant for
+	// signing, i.e. with "use" set to "sig", verify tokens.
+	JWKS jose.JSONWebKeySet
+	// SigAlgs are the signature algorithms accepted. If empty, the algorithms
+	// of the signing keys in JWKS are accepted.
+	SigAlgs []jose.SignatureAlgorithm
+	// Leeway is the clock skew tolerated when validating the time claims.
+	// The provider itself doesn't tolerate any.
+	Leeway time.Duration
+}
+
+// ValidClaims verifies the signature, the issuer and the time claims of a JWT
+// and returns its claims. If Issuer is empty, the issuer is not validated.
+// If the token is expired, the error returned wraps [jwt.ErrExpired].
+func (v Verifier) ValidClaims(token string) (map[string]any, error) {
+	parsedToken, err := jwt.ParseSigned(token, v.sigAlgs())
+	if err != nil {
+		return nil, goidc.Errorf(goidc.ErrorCodeInvalidRequest,
+			"could not parse the token", err)
+	}
+
+	if len(parsedToken.Headers) != 1 || parsedToken.Headers[0].KeyID == "" {
+		return nil, goidc.NewError(goidc.ErrorCodeInvalidRequest, "invalid header kid")
+	}
+
+	keys := v.JWKS.Key(parsedToken.Headers[0].KeyID)
+	if len(keys) == 0 || keys[0].Use != string(goidc.KeyUsageSignature) {
+		return nil, goidc.NewError(goidc.ErrorCodeAccessDenied, "invalid token")
+	}
+
+	var claims jwt.Claims
+	var rawClaims map[string]any
+	if err := parsedToken.Claims(keys[0].Key, &claims, &rawClaims); err != nil {
+		return nil, goidc.Errorf(goidc.ErrorCodeAccessDenied,
+			"invalid token", err)
+	}
+
+	if err := claims.ValidateWithLeeway(jwt.Expected{}, v.Leeway); err != nil {
+		return nil, goidc.Errorf(goidc.ErrorCodeAccessDenied, "invalid token", err)
+	}
+
+	if v.Issuer != "" && claims.Issuer != v.Issuer && !slices.Contains(v.IssuerAliases, claims.Issuer) {
+		return nil, goidc.Errorf(goidc.ErrorCodeAccessDenied, "invalid token", jwt.ErrInvalidIssuer)
+	}
+
+	return rawClaims, nil
+}
+
+// ExtractID returns the ID of a token.
+// If it's a JWT, the ID is the "jti" claim of the token after it is verified.
+// Otherwise, the token is considered opaque and its ID is the token itself.
+func (v Verifier) ExtractID(token string) (string, error) {
+	if !IsJWT(token) {
+		return token, nil
+	}
+
+	claims, err := v.ValidClaims(token)
+	if err != nil {
+		return "", err
+	}
+
+	tokenID, ok := claims[goidc.ClaimTokenID].(string)
+	if !ok {
+		return "", goidc.NewError(goidc.ErrorCodeAccessDenied, "invalid token")
+	}
+
+	return tokenID, nil
+}
+
+func (v Verifier) sigAlgs() []jose.SignatureAlgorithm {
+	if len(v.SigAlgs) != 0 {
+		return v.SigAlgs
+	}
+
+	var algs []jose.SignatureAlgorithm
+	for _, key := range v.JWKS.Keys {
+		if key.Use == string(goidc.KeyUsageSignature) {
+			algs = append(algs, jose.SignatureAlgorithm(key.Algorithm))
+		}
+	}
+	return algs
+}
+
+// IsJWT returns whether the token is a signed JWT, as opposed to an opaque
+// token.
+func IsJWT(token string) bool {
+	return jwtutil.IsJWS(token)
+}
+
+// AccessTokenHash computes the at_hash claim of an ID token signed with alg
+// and issued along with the access token informed.
+func AccessTokenHash(accessToken string, alg jose.SignatureAlgorithm) string {
+	return goidc.HalfHashClaim(accessToken, alg)
+}
+
+// VerifyAccessTokenHash reports whether the at_hash claim of the ID token
+// claims matches the access token informed. The algorithm used to sign the ID
+// token defines the hash function.
+func VerifyAccessTokenHash(
+	idTokenClaims map[string]any,
+	accessToken string,
+	alg jose.SignatureAlgorithm,
+) bool {
+	atHash, ok := idTokenClaims[goidc.ClaimAccessTokenHash].(string)
+	if !ok {
+		return false
+	}
+	return goidc.VerifyHalfHashClaim(atHash, accessToken, alg)
+}
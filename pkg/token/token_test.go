@@ -0,0 +1,211 @@
+package token_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/luikyv/go-oidc/internal/jwtutil"
+	"github.com/luikyv/go-oidc/internal/oidctest"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+	"github.com/luikyv/go-oidc/pkg/token"
+)
+
+func TestValidClaims(t *testing.T) {
+	// Given.
+	jwk := oidctest.PrivatePS256JWK(t, "random_key_id", goidc.KeyUsageSignature)
+	verifier := token.Verifier{
+		Issuer: "https://example.com",
+		JWKS:   jose.JSONWebKeySet{Keys: []jose.JSONWebKey{jwk.Public()}},
+	}
+	jws := signToken(t, jwk, map[string]any{
+		goidc.ClaimIssuer:  "https://example.com",
+		goidc.ClaimTokenID: "random_token_id",
+		goidc.ClaimExpiry:  time.Now().Unix() + 60,
+	})
+
+	// When.
+	claims, err := verifier.ValidClaims(jws)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if claims[goidc.ClaimTokenID] != "random_token_id" {
+		t.Errorf("jti = %v, want random_token_id", claims[goidc.ClaimTokenID])
+	}
+}
+
+func TestValidClaims_IssuerAlias(t *testing.T) {
+	// Given.
+	jwk := oidctest.PrivatePS256JWK(t, "random_key_id", goidc.KeyUsageSignature)
+	verifier := token.Verifier{
+		Issuer:        "https://example.com",
+		IssuerAliases: []string{"https://old.example.com"},
+		JWKS:          jose.JSONWebKeySet{Keys: []jose.JSONWebKey{jwk.Public()}},
+	}
+	jws := signToken(t, jwk, map[string]any{
+		goidc.ClaimIssuer: "https://old.example.com",
+		goidc.ClaimExpiry: time.Now().Unix() + 60,
+	})
+
+	// When.
+	_, err := verifier.ValidClaims(jws)
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestValidClaims_InvalidToken(t *testing.T) {
+	// Given.
+	jwk := oidctest.PrivatePS256JWK(t, "random_key_id", goidc.KeyUsageSignature)
+	encJWK := oidctest.PrivatePS256JWK(t, "random_key_id", goidc.KeyUsageEncryption)
+	otherJWK := oidctest.PrivatePS256JWK(t, "random_key_id", goidc.KeyUsageSignature)
+	verifier := token.Verifier{
+		Issuer: "https://example.com",
+		JWKS:   jose.JSONWebKeySet{Keys: []jose.JSONWebKey{jwk.Public()}},
+	}
+	now := time.Now().Unix()
+
+	testCases := []struct {
+		name string
+		jwk  jose.JSONWebKey
+		jws  string
+	}{
+		{"expired", jwk, signToken(t, jwk, map[string]any{
+			goidc.ClaimIssuer: "https://example.com",
+			goidc.ClaimExpiry: now - 10,
+		})},
+		{"invalid issuer", jwk, signToken(t, jwk, map[string]any{
+			goidc.ClaimIssuer: "https://other.example.com",
+			goidc.ClaimExpiry: now + 60,
+		})},
+		{"unknown key", jwk, signToken(t, otherJWK, map[string]any{
+			goidc.ClaimIssuer: "https://example.com",
+			goidc.ClaimExpiry: now + 60,
+		})},
+		{"encryption key", encJWK, signToken(t, jwk, map[string]any{
+			goidc.ClaimIssuer: "https://example.com",
+			goidc.ClaimExpiry: now + 60,
+		})},
+		{"opaque", jwk, "random_opaque_token"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// Given.
+			verifier.JWKS = jose.JSONWebKeySet{Keys: []jose.JSONWebKey{testCase.jwk.Public()}}
+
+			// When.
+			_, err := verifier.ValidClaims(testCase.jws)
+
+			// Then.
+			if err == nil {
+				t.Fatal("the token must be invalid")
+			}
+		})
+	}
+}
+
+func TestValidClaims_Expired(t *testing.T) {
+	// Given.
+	jwk := oidctest.PrivatePS256JWK(t, "random_key_id", goidc.KeyUsageSignature)
+	jws := signToken(t, jwk, map[string]any{
+		goidc.ClaimExpiry: time.Now().Unix() - 10,
+	})
+
+	testCases := []struct {
+		leeway  time.Duration
+		wantErr bool
+	}{
+		{0, true},
+		{time.Minute, false},
+	}
+
+	for _, testCase := range testCases {
+		verifier := token.Verifier{
+			JWKS:   jose.JSONWebKeySet{Keys: []jose.JSONWebKey{jwk.Public()}},
+			Leeway: testCase.leeway,
+		}
+
+		// When.
+		_, err := verifier.ValidClaims(jws)
+
+		// Then.
+		if testCase.wantErr && !errors.Is(err, jwt.ErrExpired) {
+			t.Errorf("ValidClaims() with leeway %v = %v, want %v", testCase.leeway, err, jwt.ErrExpired)
+		}
+		if !testCase.wantErr && err != nil {
+			t.Errorf("ValidClaims() with leeway %v = %v, want no error", testCase.leeway, err)
+		}
+	}
+}
+
+func TestExtractID(t *testing.T) {
+	// Given.
+	jwk := oidctest.PrivatePS256JWK(t, "random_key_id", goidc.KeyUsageSignature)
+	verifier := token.Verifier{
+		JWKS: jose.JSONWebKeySet{Keys: []jose.JSONWebKey{jwk.Public()}},
+	}
+	jws := signToken(t, jwk, map[string]any{
+		goidc.ClaimTokenID: "random_token_id",
+		goidc.ClaimExpiry:  time.Now().Unix() + 60,
+	})
+
+	testCases := []struct {
+		token string
+		want  string
+	}{
+		{jws, "random_token_id"},
+		{"random_opaque_token", "random_opaque_token"},
+	}
+
+	for _, testCase := range testCases {
+		// When.
+		id, err := verifier.ExtractID(testCase.token)
+
+		// Then.
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if id != testCase.want {
+			t.Errorf("ExtractID() = %s, want %s", id, testCase.want)
+		}
+	}
+}
+
+func TestVerifyAccessTokenHash(t *testing.T) {
+	// Given.
+	claims := map[string]any{
+		goidc.ClaimAccessTokenHash: token.AccessTokenHash("random_access_token", jose.PS256),
+	}
+
+	// Then.
+	if !token.VerifyAccessTokenHash(claims, "random_access_token", jose.PS256) {
+		t.Error("the at_hash must match the access token")
+	}
+
+	if token.VerifyAccessTokenHash(claims, "other_access_token", jose.PS256) {
+		t.Error("the at_hash must not match other access tokens")
+	}
+
+	if token.VerifyAccessTokenHash(map[string]any{}, "random_access_token", jose.PS256) {
+		t.Error("a missing at_hash must not match")
+	}
+}
+
+func signToken(t *testing.T, jwk jose.JSONWebKey, claims map[string]any) string {
+	t.Helper()
+
+	jws, err := jwtutil.Sign(claims, jwk, (&jose.SignerOptions{}).WithHeader("kid", jwk.KeyID))
+	if err != nil {
+		t.Fatalf("could not sign the token: %v", err)
+	}
+	return jws
+}
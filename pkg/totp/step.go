@@ -0,0 +1,297 @@
+package totp
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	_ "embed"
+	"encoding/base32"
+	"errors"
+	"html/template"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/luikyv/go-oidc/internal/timeutil"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+const (
+	codeFormParam = "totp_code"
+
+	// pendingSecretParam is the key of the session store where the secret
+	// being enrolled is kept until the user confirms it with a valid code.
+	pendingSecretParam = "totp_pending_secret"
+
+	// skewSteps is how many time steps before and after the current one are
+	// accepted to tolerate clock drift.
+	skewSteps = 1
+
+	defaultMaxFailures = 5
+	defaultLockSecs    = 300
+)
+
+//go:embed templates/totp.html
+var pageTemplate string
+
+var defaultTemplate = template.Must(template.New("totp").Parse(pageTemplate))
+
+// Config defines how the step enrolls and verifies the codes.
+type Config struct {
+	// Issuer identifies the provider in the authenticator apps.
+	Issuer string
+	Store  DeviceStore
+	// AllowEnrollment makes the step enroll a device for users who have none,
+	// instead of failing.
+	AllowEnrollment bool
+	// ACR, if set, is the acr claim of the ID token after the user is
+	// verified.
+	ACR goidc.ACR
+	// OnlyWhenRequested makes the step run only if the client requested the
+	// ACR, otherwise it is skipped.
+	OnlyWhenRequested bool
+	// MaxFailures is the number of consecutive invalid codes after which the
+	// user is locked for LockSecs. They default to 5 and 300.
+	// Failures are kept in memory, so each instance of the provider counts
+	// them separately.
+	MaxFailures int
+	LockSecs    int
+	// CallbackURL is the URL to which the codes are posted followed by
+	// "/{callback_id}". If not set, they are posted to the path of the
+	// authorization endpoint the request was received at.
+	CallbackURL string
+	// Template, if set, replaces the page where the codes are typed. It is
+	// executed with a [Page].
+	Template *template.Template
+}
+
+// Page is the data used to render the page where the codes are typed.
+type Page struct {
+	Action string
+	Error  string
+	// Enrollment is whether the user is enrolling a device, in which case
+	// Secret and URI are set.
+	Enrollment bool
+	Secret     string
+	// URI is the otpauth URI of the secret, which can also be rendered as a
+	// QR code.
+	URI template.URL
+}
+
+// Step verifies the user with a code generated by the device they enrolled.
+// On success, the amr "otp" is added to the ID token along with "mfa" if a
+// previous step already authenticated the user with another method, and the
+// acr claim is set if configured.
+func Step(config Config) goidc.AuthnStep {
+	if config.MaxFailures == 0 {
+		config.MaxFailures = defaultMaxFailures
+	}
+	if config.LockSecs == 0 {
+		config.LockSecs = defaultLockSecs
+	}
+	if config.Template == nil {
+		config.Template = defaultTemplate
+	}
+
+	s := &step{Config: config, failures: map[string]*failures{}}
+	return s.authenticate
+}
+
+type step struct {
+	Config
+	mu       sync.Mutex
+	failures map[string]*failures
+}
+
+type failures struct {
+	count                int
+	lockedUntilTimestamp int
+}
+
+func (s *step) authenticate(w http.ResponseWriter, r *http.Request, session *goidc.AuthnSession) (goidc.AuthnStatus, error) {
+	if s.OnlyWhenRequested && !slices.Contains(session.RequestedACRs(), s.ACR) {
+		return goidc.StatusSuccess, nil
+	}
+
+	if session.Subject == "" {
+		return goidc.StatusFailure, errors.New("the user must be identified before verifying a code")
+	}
+
+	if !session.IsInteractionAllowed() {
+		return goidc.StatusFailure, goidc.NewError(goidc.ErrorCodeInteractionRequired,
+			"the user must type a verification code")
+	}
+
+	if s.isLocked(session.Subject) {
+		return goidc.StatusFailure, goidc.NewError(goidc.ErrorCodeAccessDenied,
+			"too many invalid verification codes")
+	}
+
+	device, err := s.Store.Device(r.Context(), session.Subject)
+	enrolling := errors.Is(err, ErrDeviceNotFound)
+	if err != nil && !enrolling {
+		return goidc.StatusFailure, err
+	}
+
+	if enrolling {
+		if !s.AllowEnrollment {
+			return goidc.StatusFailure, goidc.NewError(goidc.ErrorCodeAccessDenied,
+				"the user has no device enrolled")
+		}
+
+		secret, err := pendingSecret(session)
+		if err != nil {
+			return goidc.StatusFailure, err
+		}
+		device = Device{Subject: session.Subject, Secret: secret}
+	}
+
+	code := r.PostFormValue(codeFormParam)
+	if code == "" {
+		return s.render(w, r, session, device, enrolling, "")
+	}
+
+	step, ok := verify(device, code)
+	s.recordAttempt(session.Subject, ok)
+	if !ok {
+		if s.isLocked(session.Subject) {
+			return goidc.StatusFailure, goidc.NewError(goidc.ErrorCodeAccessDenied,
+				"too many invalid verification codes")
+		}
+		return s.render(w, r, session, device, enrolling, "Invalid verification code, please try again.")
+	}
+
+	device.LastUsedStep = step
+	if err := s.Store.Save(r.Context(), device); err != nil {
+		return goidc.StatusFailure, err
+	}
+	delete(session.Store, pendingSecretParam)
+
+	amrs := []goidc.AMR{goidc.AMROneTimePassoword}
+	if slices.ContainsFunc(session.IDTokenClaimAMRs(), func(amr goidc.AMR) bool {
+		return amr != goidc.AMROneTimePassoword && amr != goidc.AMRMultipleFactor
+	}) {
+		amrs = append(amrs, goidc.AMRMultipleFactor)
+	}
+	session.AddIDTokenClaimAMR(amrs...)
+	if s.ACR != "" {
+		session.SetIDTokenClaimACR(s.ACR)
+	}
+	return goidc.StatusSuccess, nil
+}
+
+func (s *step) render(
+	w http.ResponseWriter,
+	r *http.Request,
+	session *goidc.AuthnSession,
+	device Device,
+	enrolling bool,
+	errMsg string,
+) (
+	goidc.AuthnStatus,
+	error,
+) {
+	page := Page{
+		Action:     s.action(r, session),
+		Error:      errMsg,
+		Enrollment: enrolling,
+	}
+	if enrolling {
+		page.Secret = EncodeSecret(device.Secret)
+		// The otpauth scheme is built by the step, so it is safe to render.
+		page.URI = template.URL(s.uri(device))
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := s.Template.Execute(w, page); err != nil {
+		return goidc.StatusFailure, err
+	}
+	return goidc.StatusInProgress, nil
+}
+
+// action returns the URL to which the code is posted.
+func (s *step) action(r *http.Request, session *goidc.AuthnSession) string {
+	callbackURL := strings.TrimSuffix(s.CallbackURL, "/")
+	if callbackURL == "" {
+		callbackURL = strings.TrimSuffix(r.URL.Path, "/"+session.CallbackID)
+	}
+	return callbackURL + "/" + session.CallbackID
+}
+
+// uri returns the otpauth URI understood by authenticator apps.
+func (s *step) uri(device Device) string {
+	label := device.Subject
+	if s.Issuer != "" {
+		label = s.Issuer + ":" + device.Subject
+	}
+
+	params := url.Values{}
+	params.Set("secret", EncodeSecret(device.Secret))
+	if s.Issuer != "" {
+		params.Set("issuer", s.Issuer)
+	}
+	return "otpauth://totp/" + url.PathEscape(label) + "?" + params.Encode()
+}
+
+func (s *step) isLocked(subject string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, ok := s.failures[subject]
+	return ok && f.lockedUntilTimestamp > timeutil.TimestampNow()
+}
+
+func (s *step) recordAttempt(subject string, success bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if success {
+		delete(s.failures, subject)
+		return
+	}
+
+	f, ok := s.failures[subject]
+	if !ok {
+		f = &failures{}
+		s.failures[subject] = f
+	}
+
+	f.count++
+	if f.count >= s.MaxFailures {
+		f.count = 0
+		f.lockedUntilTimestamp = timeutil.TimestampNow() + s.LockSecs
+	}
+}
+
+// pendingSecret returns the secret being enrolled in the session, generating
+// it if needed.
+func pendingSecret(session *goidc.AuthnSession) ([]byte, error) {
+	if encoded, ok := session.Parameter(pendingSecretParam).(string); ok {
+		return base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(encoded)
+	}
+
+	secret := make([]byte, secretLength)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	session.StoreParameter(pendingSecretParam, EncodeSecret(secret))
+	return secret, nil
+}
+
+// verify returns the time step of the code if it is valid for the device.
+// Codes of steps already used are rejected to prevent replays.
+func verify(device Device, userCode string) (int64, bool) {
+	current := time.Now().Unix() / Period
+	for step := current - skewSteps; step <= current+skewSteps; step++ {
+		if step <= device.LastUsedStep {
+			continue
+		}
+
+		if subtle.ConstantTimeCompare([]byte(userCode), []byte(code(device.Secret, step))) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,37 @@
+package totp
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryStore is a [DeviceStore] that keeps the devices in memory.
+// It is meant for tests and development, since the devices are lost when the
+// process exits.
+type MemoryStore struct {
+	mu      sync.RWMutex
+	devices map[string]Device
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{devices: map[string]Device{}}
+}
+
+func (s *MemoryStore) Save(_ context.Context, device Device) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.devices[device.Subject] = device
+	return nil
+}
+
+func (s *MemoryStore) Device(_ context.Context, subject string) (Device, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	device, ok := s.devices[subject]
+	if !ok {
+		return Device{}, ErrDeviceNotFound
+	}
+	return device, nil
+}
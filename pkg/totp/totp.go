@@ -0,0 +1,84 @@
+// Package totp provides an authentication step that enrolls and verifies time
+// based one time passwords as defined by RFC 6238, e.g. codes generated by
+// authenticator apps.
+//
+// The step is meant to run after the user is identified by a previous step,
+// either on every authentication or only when the client requests a given
+// acr, i.e. as a step up.
+//
+//	policy := goidc.NewSequentialPolicy(
+//		"mfa",
+//		func(*http.Request, *goidc.Client, *goidc.AuthnSession) bool { return true },
+//		pages.LoginStep(verifyPassword),
+//		totp.Step(totp.Config{
+//			Issuer:            "Example",
+//			Store:             store,
+//			AllowEnrollment:   true,
+//			ACR:               "urn:example:mfa",
+//			OnlyWhenRequested: true,
+//		}),
+//	)
+package totp
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// Period is the number of seconds each code is valid for.
+	Period = 30
+	// Digits is the number of digits of the codes.
+	Digits = 6
+
+	secretLength = 20
+)
+
+// ErrDeviceNotFound must be returned by a [DeviceStore] when the user has no
+// device enrolled.
+var ErrDeviceNotFound = errors.New("device not found")
+
+// Device is the authenticator enrolled by a user.
+type Device struct {
+	Subject string `json:"sub"`
+	Secret  []byte `json:"secret"`
+	// LastUsedStep is the time step of the last code accepted, which cannot
+	// be used again.
+	LastUsedStep int64 `json:"last_used_step"`
+}
+
+// DeviceStore persists the devices enrolled by the users.
+type DeviceStore interface {
+	// Save creates or updates the device of the user.
+	Save(ctx context.Context, device Device) error
+	// Device returns the device of the user or [ErrDeviceNotFound].
+	Device(ctx context.Context, subject string) (Device, error)
+}
+
+// Code returns the code generated by the secret at the time informed.
+func Code(secret []byte, t time.Time) string {
+	return code(secret, t.Unix()/Period)
+}
+
+func code(secret []byte, step int64) string {
+	mac := hmac.New(sha1.New, secret)
+	_ = binary.Write(mac, binary.BigEndian, step)
+	sum := mac.Sum(nil)
+
+	// Dynamic truncation as defined by RFC 4226.
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", Digits, value%1000000)
+}
+
+// EncodeSecret returns the secret in the base32 format accepted by
+// authenticator apps.
+func EncodeSecret(secret []byte) string {
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(secret)
+}
@@ -0,0 +1,163 @@
+package totp_test
+
+import (
+	"context"
+	"encoding/base32"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/luikyv/go-oidc/pkg/goidc"
+	"github.com/luikyv/go-oidc/pkg/providertest"
+	"github.com/luikyv/go-oidc/pkg/totp"
+)
+
+const acr goidc.ACR = "urn:example:mfa"
+
+func TestCode(t *testing.T) {
+	// Test vectors of RFC 6238 truncated to six digits.
+	secret := []byte("12345678901234567890")
+	testCases := []struct {
+		timestamp int64
+		want      string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1234567890, "005924"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.want, func(t *testing.T) {
+			if got := totp.Code(secret, time.Unix(testCase.timestamp, 0)); got != testCase.want {
+				t.Errorf("Code() = %s, want %s", got, testCase.want)
+			}
+		})
+	}
+}
+
+func TestStep_Enrollment(t *testing.T) {
+	// Given.
+	store := totp.NewMemoryStore()
+	sim := newSimulator(t, totp.Config{Issuer: "Example", Store: store, AllowEnrollment: true, ACR: acr})
+
+	// When.
+	sim.Start(t, nil)
+
+	// Then.
+	if !strings.Contains(sim.Body, "otpauth://totp/Example:random_subject") {
+		t.Fatalf("the enrollment page was not rendered: %s", sim.Body)
+	}
+
+	// When.
+	secret := pendingSecret(t, sim)
+	sim.Submit(t, url.Values{"totp_code": {totp.Code(secret, time.Now())}})
+
+	// Then.
+	sim.Tokens(t)
+	device, err := store.Device(context.Background(), providertest.DefaultSubject)
+	if err != nil {
+		t.Fatalf("the device was not enrolled: %v", err)
+	}
+
+	if device.LastUsedStep == 0 {
+		t.Error("the step of the code used should be recorded")
+	}
+
+	session := sim.Session()
+	want := []goidc.AMR{goidc.AMRPassword, goidc.AMROneTimePassoword, goidc.AMRMultipleFactor}
+	if amrs := session.IDTokenClaimAMRs(); !slices.Equal(amrs, want) {
+		t.Errorf("amr = %v, want %v", amrs, want)
+	}
+
+	if session.AdditionalIDTokenClaims[goidc.ClaimACR] != acr {
+		t.Errorf("acr = %v, want %s", session.AdditionalIDTokenClaims[goidc.ClaimACR], acr)
+	}
+}
+
+func TestStep_CodeReplayed(t *testing.T) {
+	// Given.
+	store := totp.NewMemoryStore()
+	secret := []byte("12345678901234567890")
+	_ = store.Save(context.Background(), totp.Device{
+		Subject:      providertest.DefaultSubject,
+		Secret:       secret,
+		LastUsedStep: time.Now().Unix()/totp.Period + 1,
+	})
+	sim := newSimulator(t, totp.Config{Store: store})
+	sim.Start(t, nil)
+
+	// When.
+	sim.Submit(t, url.Values{"totp_code": {totp.Code(secret, time.Now())}})
+
+	// Then.
+	if !strings.Contains(sim.Body, "Invalid verification code") {
+		t.Errorf("the code should be rejected: %s", sim.Body)
+	}
+}
+
+func TestStep_Lock(t *testing.T) {
+	// Given.
+	store := totp.NewMemoryStore()
+	_ = store.Save(context.Background(), totp.Device{
+		Subject: providertest.DefaultSubject,
+		Secret:  []byte("12345678901234567890"),
+	})
+	sim := newSimulator(t, totp.Config{Store: store, MaxFailures: 2})
+	sim.Start(t, nil)
+	sim.Submit(t, url.Values{"totp_code": {"invalid"}})
+
+	// When.
+	sim.Submit(t, url.Values{"totp_code": {"invalid"}})
+
+	// Then.
+	if got := sim.RedirectParams(t).Get("error"); got != string(goidc.ErrorCodeAccessDenied) {
+		t.Errorf("error = %s, want %s", got, goidc.ErrorCodeAccessDenied)
+	}
+}
+
+func TestStep_OnlyWhenRequested(t *testing.T) {
+	// Given.
+	sim := newSimulator(t, totp.Config{Store: totp.NewMemoryStore(), ACR: acr, OnlyWhenRequested: true})
+
+	// When.
+	sim.Start(t, nil)
+
+	// Then.
+	sim.Code(t)
+}
+
+func newSimulator(t *testing.T, config totp.Config) *providertest.PolicySimulator {
+	t.Helper()
+
+	login := func(_ http.ResponseWriter, _ *http.Request, session *goidc.AuthnSession) (goidc.AuthnStatus, error) {
+		session.SetUserID(providertest.DefaultSubject)
+		session.SetIDTokenClaimAMR(goidc.AMRPassword)
+		return goidc.StatusSuccess, nil
+	}
+	grant := func(_ http.ResponseWriter, _ *http.Request, session *goidc.AuthnSession) (goidc.AuthnStatus, error) {
+		session.GrantScopes(session.Scopes)
+		return goidc.StatusSuccess, nil
+	}
+
+	return providertest.NewPolicySimulator(t, goidc.NewSequentialPolicy(
+		"totp",
+		func(*http.Request, *goidc.Client, *goidc.AuthnSession) bool { return true },
+		login,
+		totp.Step(config),
+		grant,
+	))
+}
+
+func pendingSecret(t *testing.T, sim *providertest.PolicySimulator) []byte {
+	t.Helper()
+
+	encoded, _ := sim.Session().Store["totp_pending_secret"].(string)
+	secret, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(encoded)
+	if err != nil || len(secret) == 0 {
+		t.Fatalf("no secret is being enrolled: %v", err)
+	}
+	return secret
+}
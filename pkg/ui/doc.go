@@ -0,0 +1,36 @@
+// Package ui provides reference login, consent and error pages for an OpenID
+// Provider along with the authentication steps that render them, so a working
+// provider can be stood up before building a custom user interface.
+//
+//	pages, err := ui.New(ui.Config{
+//		Theme: ui.Theme{Title: "Example", PrimaryColor: "#0b7a75"},
+//	})
+//	...
+//	op, err := provider.New(
+//		goidc.ProfileOpenID,
+//		issuer,
+//		jwks,
+//		provider.WithPolicy(goidc.NewSequentialPolicy(
+//			"ui",
+//			func(*http.Request, *goidc.Client, *goidc.AuthnSession) bool { return true },
+//			pages.AccountSelectionStep(),
+//			pages.LoginStep(verifyPassword),
+//			pages.ConsentStep(),
+//		)),
+//		provider.WithRenderErrorFunc(pages.RenderError),
+//		provider.WithUserSessions(0),
+//	)
+//
+// The pages are embedded in the package and share the layout defined in
+// "layout.html". Any of them can be replaced with [Config.Templates] by
+// providing a file with the same name, e.g. "login.html", and the look of all
+// of them can be changed with a [Theme].
+//
+// The form_post and device code entry pages are made available through
+// [UI.RenderFormPost] and [UI.RenderDeviceCodeEntry] for handlers written by
+// the application, since the provider doesn't delegate their rendering.
+//
+// The steps are meant as a starting point. Users are only recognized in later
+// authorization requests if user sessions are enabled in the provider, and the
+// consents granted are not remembered.
+package ui
@@ -0,0 +1,222 @@
+package ui
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/luikyv/go-oidc/internal/timeutil"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+const (
+	stepFormParam     = "ui_step"
+	usernameFormParam = "username"
+	passwordFormParam = "password"
+	consentFormParam  = "consent"
+	accountFormParam  = "account"
+
+	stepLogin         = "login"
+	stepConsent       = "consent"
+	stepSelectAccount = "select_account"
+)
+
+// ErrInvalidCredentials must be returned by a [VerifyPasswordFunc] when the
+// username or the password is wrong, so the login page is shown again.
+var ErrInvalidCredentials = errors.New("invalid username or password")
+
+// VerifyPasswordFunc validates the credentials submitted in the login page and
+// returns the subject of the user they belong to.
+// If the credentials are wrong, it must return [ErrInvalidCredentials]; any
+// other error ends the authentication.
+type VerifyPasswordFunc func(r *http.Request, username, password string) (subject string, err error)
+
+// LoginStep authenticates the user with a username and a password.
+// If the subject of the session was already set by a previous step and the
+// client didn't request the user to authenticate again, the step is skipped.
+// On success, the auth_time and amr claims of the ID token are set.
+func (ui *UI) LoginStep(verify VerifyPasswordFunc) goidc.AuthnStep {
+	return func(w http.ResponseWriter, r *http.Request, session *goidc.AuthnSession) (goidc.AuthnStatus, error) {
+		if session.Subject != "" && !session.MustReauthenticate() && !isAuthnTooOld(session) {
+			return goidc.StatusSuccess, nil
+		}
+
+		if !session.IsInteractionAllowed() {
+			return goidc.StatusFailure, goidc.NewError(goidc.ErrorCodeLoginRequired,
+				"the user must authenticate")
+		}
+
+		if r.PostFormValue(stepFormParam) != stepLogin {
+			return ui.renderLogin(w, r, session, "", "")
+		}
+
+		username := r.PostFormValue(usernameFormParam)
+		subject, err := verify(r, username, r.PostFormValue(passwordFormParam))
+		if errors.Is(err, ErrInvalidCredentials) {
+			return ui.renderLogin(w, r, session, username, "Invalid username or password.")
+		}
+		if err != nil {
+			return goidc.StatusFailure, err
+		}
+
+		session.SetUserID(subject)
+		session.SetIDTokenClaimAuthTime(timeutil.TimestampNow())
+		session.SetIDTokenClaimAMR(goidc.AMRPassword)
+		return goidc.StatusSuccess, nil
+	}
+}
+
+func (ui *UI) renderLogin(
+	w http.ResponseWriter,
+	r *http.Request,
+	session *goidc.AuthnSession,
+	username string,
+	errMsg string,
+) (
+	goidc.AuthnStatus,
+	error,
+) {
+	if err := ui.render(w, http.StatusOK, loginTemplate, page{
+		Action:   ui.action(r, session),
+		Username: username,
+		Error:    errMsg,
+	}); err != nil {
+		return goidc.StatusFailure, err
+	}
+	return goidc.StatusInProgress, nil
+}
+
+// isAuthnTooOld returns whether the user identified by a previous step, e.g.
+// by selecting an account, authenticated longer ago than the max_age
+// requested by the client.
+func isAuthnTooOld(session *goidc.AuthnSession) bool {
+	authTime, ok := session.AdditionalIDTokenClaims[goidc.ClaimAuthTime].(int)
+	return ok && session.IsAuthnTooOld(authTime)
+}
+
+// AccountSelectionStep lets the user continue with one of the accounts already
+// authenticated in the user agent, which requires user sessions to be enabled
+// in the provider. It must be placed before the login step, which only
+// authenticates the user if no account is selected.
+// An account is selected without asking the user if it is the only one or if
+// it matches the id_token_hint or login_hint sent by the client, unless the
+// client requested prompt=select_account. If the user must choose, but the
+// client requested prompt=none, the step fails with
+// account_selection_required.
+func (ui *UI) AccountSelectionStep() goidc.AuthnStep {
+	return func(w http.ResponseWriter, r *http.Request, session *goidc.AuthnSession) (goidc.AuthnStatus, error) {
+		if r.PostFormValue(stepFormParam) == stepSelectAccount {
+			subject := r.PostFormValue(accountFormParam)
+			// No subject means the user chose to sign in with another account.
+			if subject != "" && !session.SelectAccount(subject) {
+				return goidc.StatusFailure, goidc.NewError(goidc.ErrorCodeInvalidRequest,
+					"the account selected is not signed in")
+			}
+			return goidc.StatusSuccess, nil
+		}
+
+		if len(session.Accounts) == 0 {
+			return goidc.StatusSuccess, nil
+		}
+
+		if !session.MustSelectAccount() {
+			if account, ok := session.HintedAccount(); ok {
+				session.SelectAccount(account.Subject)
+				return goidc.StatusSuccess, nil
+			}
+
+			_, hasIDTokenHint := session.IDTokenHintSubject()
+			if hasIDTokenHint || session.LoginHint != "" {
+				// The user hinted by the client is not signed in.
+				return goidc.StatusSuccess, nil
+			}
+
+			if len(session.Accounts) == 1 {
+				session.SelectAccount(session.Accounts[0].Subject)
+				return goidc.StatusSuccess, nil
+			}
+		}
+
+		if !session.IsInteractionAllowed() {
+			return goidc.StatusFailure, goidc.NewError(goidc.ErrorCodeAccountSelectionRequired,
+				"the user must choose an account")
+		}
+
+		accounts := make([]string, 0, len(session.Accounts))
+		for _, account := range session.Accounts {
+			accounts = append(accounts, account.Subject)
+		}
+		if err := ui.render(w, http.StatusOK, selectTemplate, page{
+			Action:   ui.action(r, session),
+			Accounts: accounts,
+		}); err != nil {
+			return goidc.StatusFailure, err
+		}
+		return goidc.StatusInProgress, nil
+	}
+}
+
+// ConsentStep asks the user to authorize the client to access the scopes
+// requested, which are described with the titles and descriptions of the
+// scopes in the language preferred by the user agent.
+// If the user allows it, all the scopes, resources and authorization details
+// requested are granted, otherwise the authentication fails with
+// access_denied.
+func (ui *UI) ConsentStep() goidc.AuthnStep {
+	return func(w http.ResponseWriter, r *http.Request, session *goidc.AuthnSession) (goidc.AuthnStatus, error) {
+		if r.PostFormValue(stepFormParam) != stepConsent {
+			if !session.IsInteractionAllowed() {
+				return goidc.StatusFailure, goidc.NewError(goidc.ErrorCodeConsentRequired,
+					"the user must grant consent")
+			}
+
+			return ui.renderConsent(w, r, session)
+		}
+
+		if r.PostFormValue(consentFormParam) != "true" {
+			return goidc.StatusFailure, goidc.NewError(goidc.ErrorCodeAccessDenied,
+				"the user denied access")
+		}
+
+		session.GrantScopes(session.Scopes)
+		session.GrantResources(session.Resources)
+		session.GrantAuthorizationDetails(session.AuthDetails)
+		return goidc.StatusSuccess, nil
+	}
+}
+
+func (ui *UI) renderConsent(
+	w http.ResponseWriter,
+	r *http.Request,
+	session *goidc.AuthnSession,
+) (
+	goidc.AuthnStatus,
+	error,
+) {
+	lang := preferredLanguage(r)
+	scopes := make([]scope, 0, len(session.ScopeInfos))
+	for _, info := range session.ScopeInfos {
+		scopes = append(scopes, scope{
+			Title:       info.LocalizedTitle(lang),
+			Description: info.LocalizedDescription(lang),
+		})
+	}
+
+	if err := ui.render(w, http.StatusOK, consentTemplate, page{
+		Action:   ui.action(r, session),
+		Subject:  session.Subject,
+		ClientID: session.ClientID,
+		Scopes:   scopes,
+	}); err != nil {
+		return goidc.StatusFailure, err
+	}
+	return goidc.StatusInProgress, nil
+}
+
+// preferredLanguage returns the first language tag of the Accept-Language
+// header, ignoring its quality value.
+func preferredLanguage(r *http.Request) string {
+	lang, _, _ := strings.Cut(r.Header.Get("Accept-Language"), ",")
+	lang, _, _ = strings.Cut(lang, ";")
+	return strings.TrimSpace(lang)
+}
@@ -0,0 +1,212 @@
+package ui
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"strings"
+
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+const (
+	defaultTitle        = "go-oidc"
+	defaultPrimaryColor = "#007bff"
+
+	layoutTemplate   = "layout.html"
+	loginTemplate    = "login.html"
+	consentTemplate  = "consent.html"
+	selectTemplate   = "select_account.html"
+	errorTemplate    = "error.html"
+	formPostTemplate = "form_post.html"
+	deviceTemplate   = "device.html"
+)
+
+//go:embed templates/*.html
+var embedded embed.FS
+
+// Theme customizes the look of the pages.
+type Theme struct {
+	// Title is shown as the page title and heading. It defaults to "go-oidc".
+	Title string
+	// LogoURL, if set, is the image shown above the title.
+	LogoURL string
+	// PrimaryColor is the CSS color of the buttons. It defaults to "#007bff".
+	PrimaryColor string
+	// CSS is appended to the style sheet of the layout.
+	CSS template.CSS
+}
+
+// Config defines how the pages are built.
+type Config struct {
+	// CallbackURL is the URL to which the login and consent forms are posted
+	// followed by "/{callback_id}", i.e. the issuer, the endpoint prefix and
+	// the authorization endpoint, e.g. "https://example.com/authorize".
+	// If not set, the forms are posted to the path of the authorization
+	// endpoint the request was received at, which doesn't work if a proxy
+	// rewrites the paths.
+	CallbackURL string
+	Theme       Theme
+	// Templates, if set, replaces the embedded templates with the files of
+	// the same name found at its root, e.g. "login.html" or "layout.html".
+	Templates fs.FS
+}
+
+// UI renders the pages of the package.
+type UI struct {
+	callbackURL string
+	theme       Theme
+	templates   map[string]*template.Template
+}
+
+// New parses the templates and returns the pages ready to be used.
+func New(config Config) (*UI, error) {
+	theme := config.Theme
+	if theme.Title == "" {
+		theme.Title = defaultTitle
+	}
+	if theme.PrimaryColor == "" {
+		theme.PrimaryColor = defaultPrimaryColor
+	}
+
+	templatesFS, err := fs.Sub(embedded, "templates")
+	if err != nil {
+		return nil, err
+	}
+
+	layout, err := readTemplate(templatesFS, config.Templates, layoutTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	ui := &UI{
+		callbackURL: strings.TrimSuffix(config.CallbackURL, "/"),
+		theme:       theme,
+		templates:   map[string]*template.Template{},
+	}
+	for _, name := range []string{
+		loginTemplate,
+		consentTemplate,
+		selectTemplate,
+		errorTemplate,
+		formPostTemplate,
+		deviceTemplate,
+	} {
+		page, err := readTemplate(templatesFS, config.Templates, name)
+		if err != nil {
+			return nil, err
+		}
+
+		tmpl, err := template.New(name).Parse(layout)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse %s: %w", layoutTemplate, err)
+		}
+		if _, err := tmpl.Parse(page); err != nil {
+			return nil, fmt.Errorf("could not parse %s: %w", name, err)
+		}
+		ui.templates[name] = tmpl
+	}
+
+	return ui, nil
+}
+
+// readTemplate returns the content of the template from the overrides if
+// present there, otherwise from the embedded templates.
+func readTemplate(embedded, overrides fs.FS, name string) (string, error) {
+	if overrides != nil {
+		content, err := fs.ReadFile(overrides, name)
+		if err == nil {
+			return string(content), nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", fmt.Errorf("could not read %s: %w", name, err)
+		}
+	}
+
+	content, err := fs.ReadFile(embedded, name)
+	if err != nil {
+		return "", fmt.Errorf("could not read %s: %w", name, err)
+	}
+	return string(content), nil
+}
+
+// page is the data available to the templates.
+type page struct {
+	Theme  Theme
+	Action string
+	Error  string
+	// ErrorDescription is only set for the error page.
+	ErrorDescription string
+	Username         string
+	Subject          string
+	ClientID         string
+	Scopes           []scope
+	UserCode         string
+	Params           map[string]string
+	// Accounts are the subjects the user can choose from.
+	Accounts []string
+}
+
+// scope is a scope requested by the client as presented in the consent page.
+type scope struct {
+	Title       string
+	Description string
+}
+
+func (ui *UI) render(w http.ResponseWriter, status int, name string, p page) error {
+	p.Theme = ui.theme
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	return ui.templates[name].Execute(w, p)
+}
+
+// action returns the URL to which the forms of the session's pages are posted.
+func (ui *UI) action(r *http.Request, session *goidc.AuthnSession) string {
+	callbackURL := ui.callbackURL
+	if callbackURL == "" {
+		// The request was received either at the authorization endpoint or at
+		// its callback path.
+		callbackURL = strings.TrimSuffix(r.URL.Path, "/"+session.CallbackID)
+	}
+	return callbackURL + "/" + session.CallbackID
+}
+
+// RenderError renders the error page. It can be used as the render error
+// function of the provider.
+func (ui *UI) RenderError(w http.ResponseWriter, _ *http.Request, err error) error {
+	p := page{Error: string(goidc.ErrorCodeInternalError)}
+	status := http.StatusInternalServerError
+
+	var oidcErr goidc.Error
+	if errors.As(err, &oidcErr) {
+		p.Error = string(oidcErr.Code)
+		p.ErrorDescription = oidcErr.Description
+		status = oidcErr.Code.StatusCode()
+	}
+
+	return ui.render(w, status, errorTemplate, p)
+}
+
+// RenderFormPost renders a page that posts the parameters to the redirect URI
+// as soon as it loads, as defined by the form_post response mode.
+func (ui *UI) RenderFormPost(w http.ResponseWriter, redirectURI string, params map[string]string) error {
+	return ui.render(w, http.StatusOK, formPostTemplate, page{
+		Action: redirectURI,
+		Params: params,
+	})
+}
+
+// RenderDeviceCodeEntry renders a page where the user types the code shown by
+// a device. The code is posted to action as the form parameter "user_code".
+// errMsg and userCode can be informed to render the page again after an
+// invalid code was submitted.
+func (ui *UI) RenderDeviceCodeEntry(w http.ResponseWriter, action, userCode, errMsg string) error {
+	return ui.render(w, http.StatusOK, deviceTemplate, page{
+		Action:   action,
+		UserCode: userCode,
+		Error:    errMsg,
+	})
+}
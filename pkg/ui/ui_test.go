@@ -0,0 +1,226 @@
+package ui_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/luikyv/go-oidc/pkg/goidc"
+	"github.com/luikyv/go-oidc/pkg/provider"
+	"github.com/luikyv/go-oidc/pkg/providertest"
+	"github.com/luikyv/go-oidc/pkg/ui"
+)
+
+func TestSteps(t *testing.T) {
+	// Given.
+	pages, err := ui.New(ui.Config{Theme: ui.Theme{Title: "Random Title"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sim := providertest.NewPolicySimulator(t, policy(pages))
+
+	// When.
+	sim.Start(t, nil)
+
+	// Then.
+	if !strings.Contains(sim.Body, "Random Title") || !strings.Contains(sim.Body, `name="password"`) {
+		t.Fatalf("the login page was not rendered: %s", sim.Body)
+	}
+
+	// When.
+	sim.Submit(t, url.Values{"ui_step": {"login"}, "username": {"random_user"}, "password": {"wrong"}})
+
+	// Then.
+	if !strings.Contains(sim.Body, "Invalid username or password.") {
+		t.Fatalf("the login page was not rendered with the error: %s", sim.Body)
+	}
+
+	// When.
+	sim.Submit(t, url.Values{"ui_step": {"login"}, "username": {"random_user"}, "password": {"random_password"}})
+
+	// Then.
+	if !strings.Contains(sim.Body, `name="consent"`) {
+		t.Fatalf("the consent page was not rendered: %s", sim.Body)
+	}
+
+	// When.
+	sim.Submit(t, url.Values{"ui_step": {"consent"}, "consent": {"true"}})
+
+	// Then.
+	tokenResp := sim.Tokens(t)
+	if tokenResp.IDToken == "" {
+		t.Error("an id token should be issued")
+	}
+
+	if session := sim.Session(); session.Subject != "random_subject" {
+		t.Errorf("Subject = %s, want random_subject", session.Subject)
+	}
+}
+
+func TestConsentStep_Denied(t *testing.T) {
+	// Given.
+	pages, err := ui.New(ui.Config{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sim := providertest.NewPolicySimulator(t, policy(pages))
+	sim.Start(t, nil)
+	sim.Submit(t, url.Values{"ui_step": {"login"}, "username": {"random_user"}, "password": {"random_password"}})
+
+	// When.
+	sim.Submit(t, url.Values{"ui_step": {"consent"}, "consent": {"false"}})
+
+	// Then.
+	if got := sim.RedirectParams(t).Get("error"); got != string(goidc.ErrorCodeAccessDenied) {
+		t.Errorf("error = %s, want %s", got, goidc.ErrorCodeAccessDenied)
+	}
+}
+
+func TestLoginStep_PromptNone(t *testing.T) {
+	// Given.
+	pages, err := ui.New(ui.Config{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sim := providertest.NewPolicySimulator(t, policy(pages))
+
+	// When.
+	sim.Start(t, url.Values{"prompt": {"none"}})
+
+	// Then.
+	if got := sim.RedirectParams(t).Get("error"); got != string(goidc.ErrorCodeLoginRequired) {
+		t.Errorf("error = %s, want %s", got, goidc.ErrorCodeLoginRequired)
+	}
+}
+
+func TestAccountSelectionStep(t *testing.T) {
+	// Given.
+	pages, err := ui.New(ui.Config{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sim := providertest.NewPolicySimulator(t, policy(pages), provider.WithUserSessions(0))
+	sim.Start(t, nil)
+	sim.Submit(t, url.Values{"ui_step": {"login"}, "username": {"random_user"}, "password": {"random_password"}})
+	sim.Submit(t, url.Values{"ui_step": {"consent"}, "consent": {"true"}})
+	sim.Code(t)
+
+	// When.
+	sim.Start(t, nil)
+
+	// Then.
+	if !strings.Contains(sim.Body, `name="consent"`) {
+		t.Fatalf("the only account should be selected without logging in: %s", sim.Body)
+	}
+
+	// When.
+	sim.Start(t, url.Values{"prompt": {"select_account"}})
+
+	// Then.
+	if !strings.Contains(sim.Body, `value="random_subject"`) {
+		t.Fatalf("the account selection page was not rendered: %s", sim.Body)
+	}
+
+	// When.
+	sim.Submit(t, url.Values{"ui_step": {"select_account"}, "account": {""}})
+
+	// Then.
+	if !strings.Contains(sim.Body, `name="password"`) {
+		t.Fatalf("the login page should be rendered to use another account: %s", sim.Body)
+	}
+}
+
+func TestRenderError(t *testing.T) {
+	// Given.
+	pages, err := ui.New(ui.Config{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/authorize", nil)
+
+	// When.
+	err = pages.RenderError(w, r, goidc.NewError(goidc.ErrorCodeInvalidRequest, "random <description>"))
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+
+	body := w.Body.String()
+	if !strings.Contains(body, "invalid_request") || !strings.Contains(body, "random &lt;description&gt;") {
+		t.Errorf("the error was not rendered escaped: %s", body)
+	}
+}
+
+func TestNew_TemplateOverride(t *testing.T) {
+	// Given.
+	templates := fstest.MapFS{
+		"error.html": {Data: []byte(`{{ define "content" }}custom {{ .Error }}{{ end }}{{ template "layout" . }}`)},
+	}
+
+	// When.
+	pages, err := ui.New(ui.Config{Templates: templates})
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	if err := pages.RenderError(w, nil, errors.New("random error")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !strings.Contains(w.Body.String(), "custom internal_error") {
+		t.Errorf("the custom template was not used: %s", w.Body.String())
+	}
+}
+
+func TestRenderFormPost(t *testing.T) {
+	// Given.
+	pages, err := ui.New(ui.Config{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	w := httptest.NewRecorder()
+
+	// When.
+	err = pages.RenderFormPost(w, "https://example.com/callback", map[string]string{"code": "random_code"})
+
+	// Then.
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	body := w.Body.String()
+	if !strings.Contains(body, `action="https://example.com/callback"`) ||
+		!strings.Contains(body, `name="code" value="random_code"`) {
+		t.Errorf("the form was not rendered: %s", body)
+	}
+}
+
+func policy(pages *ui.UI) goidc.AuthnPolicy {
+	verify := func(_ *http.Request, username, password string) (string, error) {
+		if username != "random_user" || password != "random_password" {
+			return "", ui.ErrInvalidCredentials
+		}
+		return "random_subject", nil
+	}
+
+	return goidc.NewSequentialPolicy(
+		"ui",
+		func(*http.Request, *goidc.Client, *goidc.AuthnSession) bool { return true },
+		pages.AccountSelectionStep(),
+		pages.LoginStep(verify),
+		pages.ConsentStep(),
+	)
+}
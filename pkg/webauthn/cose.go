@@ -0,0 +1,226 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"math/big"
+)
+
+// COSE key parameters as defined in RFC 9052 and RFC 9053.
+const (
+	coseKeyType  int64 = 1
+	coseKeyAlg   int64 = 3
+	coseKeyCurve int64 = -1
+	coseKeyX     int64 = -2
+	coseKeyY     int64 = -3
+	coseKeyN     int64 = -1
+	coseKeyE     int64 = -2
+
+	coseKeyTypeOKP int64 = 1
+	coseKeyTypeEC2 int64 = 2
+	coseKeyTypeRSA int64 = 3
+
+	coseAlgES256 int64 = -7
+	coseAlgEdDSA int64 = -8
+	coseAlgRS256 int64 = -257
+
+	coseCurveP256    int64 = 1
+	coseCurveEd25519 int64 = 6
+
+	rsaMinKeyBits = 2048
+)
+
+const (
+	cborUnsigned byte = 0
+	cborNegative byte = 1
+	cborBytes    byte = 2
+	cborText     byte = 3
+	cborArray    byte = 4
+	cborMap      byte = 5
+	cborTag      byte = 6
+)
+
+// parseCOSEKey parses the credential public key encoded as a COSE key in the
+// attested credential data. Only the algorithms requested by the page, i.e.
+// ES256, EdDSA and RS256, are accepted.
+func parseCOSEKey(data []byte) (crypto.PublicKey, error) {
+	r := cborReader{data: data}
+	major, n, err := r.head()
+	if err != nil || major != cborMap || n > uint64(len(r.data)) {
+		return nil, verificationError("invalid credential public key")
+	}
+
+	params := map[int64]any{}
+	for range n {
+		label, err := r.value()
+		if err != nil {
+			return nil, verificationError("invalid credential public key")
+		}
+		value, err := r.value()
+		if err != nil {
+			return nil, verificationError("invalid credential public key")
+		}
+		if label, ok := label.(int64); ok {
+			params[label] = value
+		}
+	}
+
+	kty, _ := params[coseKeyType].(int64)
+	alg, _ := params[coseKeyAlg].(int64)
+	switch {
+	case kty == coseKeyTypeEC2 && alg == coseAlgES256:
+		return parseCOSEEC2Key(params)
+	case kty == coseKeyTypeOKP && alg == coseAlgEdDSA:
+		return parseCOSEOKPKey(params)
+	case kty == coseKeyTypeRSA && alg == coseAlgRS256:
+		return parseCOSERSAKey(params)
+	default:
+		return nil, verificationError("unsupported credential public key")
+	}
+}
+
+func parseCOSEEC2Key(params map[int64]any) (crypto.PublicKey, error) {
+	crv, _ := params[coseKeyCurve].(int64)
+	x, _ := params[coseKeyX].([]byte)
+	y, _ := params[coseKeyY].([]byte)
+	if crv != coseCurveP256 || len(x) != 32 || len(y) != 32 {
+		return nil, verificationError("invalid credential public key")
+	}
+
+	// Make sure the point is on the curve.
+	point := append(append([]byte{0x04}, x...), y...)
+	if _, err := ecdh.P256().NewPublicKey(point); err != nil {
+		return nil, verificationError("invalid credential public key")
+	}
+
+	return &ecdsa.PublicKey{
+		Curve: elliptic.P256(),
+		X:     new(big.Int).SetBytes(x),
+		Y:     new(big.Int).SetBytes(y),
+	}, nil
+}
+
+func parseCOSEOKPKey(params map[int64]any) (crypto.PublicKey, error) {
+	crv, _ := params[coseKeyCurve].(int64)
+	x, _ := params[coseKeyX].([]byte)
+	if crv != coseCurveEd25519 || len(x) != ed25519.PublicKeySize {
+		return nil, verificationError("invalid credential public key")
+	}
+	return ed25519.PublicKey(x), nil
+}
+
+func parseCOSERSAKey(params map[int64]any) (crypto.PublicKey, error) {
+	n, _ := params[coseKeyN].([]byte)
+	e, _ := params[coseKeyE].([]byte)
+	if len(e) == 0 || len(e) > 4 {
+		return nil, verificationError("invalid credential public key")
+	}
+
+	key := &rsa.PublicKey{
+		N: new(big.Int).SetBytes(n),
+		E: int(new(big.Int).SetBytes(e).Int64()),
+	}
+	if key.N.BitLen() < rsaMinKeyBits || key.E < 3 || key.E%2 == 0 {
+		return nil, verificationError("invalid credential public key")
+	}
+	return key, nil
+}
+
+// cborReader decodes the subset of CBOR (RFC 8949) used by COSE keys.
+// Indefinite lengths are not supported, since authenticators must use the
+// canonical encoding.
+type cborReader struct {
+	data []byte
+}
+
+// head reads the major type and the argument of the next item.
+func (r *cborReader) head() (byte, uint64, error) {
+	if len(r.data) == 0 {
+		return 0, 0, verificationError("invalid cbor")
+	}
+
+	major, info := r.data[0]>>5, r.data[0]&0x1f
+	r.data = r.data[1:]
+	if info < 24 {
+		return major, uint64(info), nil
+	}
+
+	var size int
+	switch info {
+	case 24:
+		size = 1
+	case 25:
+		size = 2
+	case 26:
+		size = 4
+	case 27:
+		size = 8
+	default:
+		return 0, 0, verificationError("invalid cbor")
+	}
+	if len(r.data) < size {
+		return 0, 0, verificationError("invalid cbor")
+	}
+
+	var arg uint64
+	for _, b := range r.data[:size] {
+		arg = arg<<8 | uint64(b)
+	}
+	r.data = r.data[size:]
+	return major, arg, nil
+}
+
+// value reads the next item. Integers are returned as int64 and byte strings
+// as []byte. Any other item is skipped and returned as nil.
+func (r *cborReader) value() (any, error) {
+	major, arg, err := r.head()
+	if err != nil {
+		return nil, err
+	}
+
+	switch major {
+	case cborUnsigned, cborNegative:
+		if arg > 1<<63-1 {
+			return nil, verificationError("invalid cbor")
+		}
+		if major == cborNegative {
+			return -1 - int64(arg), nil
+		}
+		return int64(arg), nil
+	case cborBytes, cborText:
+		if arg > uint64(len(r.data)) {
+			return nil, verificationError("invalid cbor")
+		}
+		value := r.data[:arg]
+		r.data = r.data[arg:]
+		if major == cborText {
+			return nil, nil
+		}
+		return value, nil
+	case cborArray, cborMap:
+		// Each item takes at least one byte.
+		if arg > uint64(len(r.data)) {
+			return nil, verificationError("invalid cbor")
+		}
+		items := arg
+		if major == cborMap {
+			items *= 2
+		}
+		for range items {
+			if _, err := r.value(); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	case cborTag:
+		_, err := r.value()
+		return nil, err
+	default:
+		// Simple values and floats have no content besides the argument.
+		return nil, nil
+	}
+}
@@ -0,0 +1,160 @@
+package webauthn
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/luikyv/go-oidc/internal/strutil"
+	"github.com/luikyv/go-oidc/internal/timeutil"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+// Step authenticates the user with a WebAuthn credential.
+// If a previous step identified the user, only their credentials are
+// accepted and, if they have none and [Config.AllowRegistration] is set, a
+// credential is registered for them. Otherwise, the user is identified by the
+// discoverable credential they choose.
+// On success, the amr "hwk" or "swk" is added to the ID token along with the
+// auth_time and, if configured, the acr claims.
+func Step(config Config) goidc.AuthnStep {
+	if config.RPName == "" {
+		config.RPName = config.RPID
+	}
+	if config.UserVerification == "" {
+		config.UserVerification = UserVerificationPreferred
+	}
+	if config.Template == nil {
+		config.Template = defaultTemplate
+	}
+
+	return func(w http.ResponseWriter, r *http.Request, session *goidc.AuthnSession) (goidc.AuthnStatus, error) {
+		ceremony := r.PostFormValue(ceremonyFormParam)
+		if ceremony == "" {
+			return config.begin(w, r, session, "")
+		}
+
+		challenge, _ := session.Parameter(challengeParam).(string)
+		if challenge == "" {
+			return goidc.StatusFailure, goidc.NewError(goidc.ErrorCodeInvalidRequest,
+				"no webauthn ceremony is in progress")
+		}
+		// The challenge can only be answered once.
+		delete(session.Store, challengeParam)
+		expectedCeremony, _ := session.Parameter(ceremonyParam).(string)
+		delete(session.Store, ceremonyParam)
+
+		var cred Credential
+		var err error
+		switch {
+		case ceremony != expectedCeremony:
+			err = verificationError("the ceremony doesn't match the one offered")
+		case ceremony == ceremonyGet:
+			cred, err = config.verifyAssertion(r, session, challenge)
+		case ceremony == ceremonyCreate:
+			cred, err = config.register(r, session, challenge)
+		default:
+			err = verificationError("invalid ceremony")
+		}
+
+		var verr verificationError
+		if errors.As(err, &verr) {
+			return config.begin(w, r, session, "The security key could not be verified, please try again.")
+		}
+		if err != nil {
+			return goidc.StatusFailure, err
+		}
+
+		if session.Subject == "" {
+			session.SetUserID(cred.Subject)
+		}
+		amr := goidc.AMRSoftwareSecuredKey
+		if cred.HardwareBacked {
+			amr = goidc.AMRHardwareSecuredKey
+		}
+		session.AddIDTokenClaimAMR(amr)
+		session.SetIDTokenClaimAuthTime(timeutil.TimestampNow())
+		if config.ACR != "" {
+			session.SetIDTokenClaimACR(config.ACR)
+		}
+		return goidc.StatusSuccess, nil
+	}
+}
+
+// begin renders the page that starts a new ceremony.
+func (config Config) begin(
+	w http.ResponseWriter,
+	r *http.Request,
+	session *goidc.AuthnSession,
+	errMsg string,
+) (
+	goidc.AuthnStatus,
+	error,
+) {
+	if !session.IsInteractionAllowed() {
+		return goidc.StatusFailure, goidc.NewError(goidc.ErrorCodeLoginRequired,
+			"the user must authenticate with a security key")
+	}
+
+	challenge := strutil.Random(challengeLength)
+	session.StoreParameter(challengeParam, challenge)
+	opts := Options{
+		Ceremony:         ceremonyGet,
+		Challenge:        base64.RawURLEncoding.EncodeToString([]byte(challenge)),
+		RPID:             config.RPID,
+		UserVerification: config.UserVerification,
+		TimeoutMillis:    defaultTimeoutMillis,
+	}
+
+	if session.Subject != "" {
+		creds, err := config.Store.Credentials(r.Context(), session.Subject)
+		if err != nil {
+			return goidc.StatusFailure, err
+		}
+
+		for _, cred := range creds {
+			opts.CredentialIDs = append(opts.CredentialIDs, base64.RawURLEncoding.EncodeToString(cred.ID))
+		}
+
+		if len(creds) == 0 {
+			if !config.AllowRegistration {
+				return goidc.StatusFailure, goidc.NewError(goidc.ErrorCodeAccessDenied,
+					"the user has no security key registered")
+			}
+			opts.Ceremony = ceremonyCreate
+			opts.RPName = config.RPName
+			opts.UserID = base64.RawURLEncoding.EncodeToString(userHandle(session.Subject))
+			opts.UserName = session.Subject
+		}
+	}
+
+	session.StoreParameter(ceremonyParam, opts.Ceremony)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := config.Template.Execute(w, Page{
+		Action:   config.action(r, session),
+		Ceremony: opts.Ceremony,
+		Error:    errMsg,
+		Options:  opts,
+	}); err != nil {
+		return goidc.StatusFailure, err
+	}
+	return goidc.StatusInProgress, nil
+}
+
+// action returns the URL to which the result of the ceremony is posted.
+func (config Config) action(r *http.Request, session *goidc.AuthnSession) string {
+	callbackURL := strings.TrimSuffix(config.CallbackURL, "/")
+	if callbackURL == "" {
+		callbackURL = strings.TrimSuffix(r.URL.Path, "/"+session.CallbackID)
+	}
+	return callbackURL + "/" + session.CallbackID
+}
+
+// userHandle returns the opaque identifier of the user sent to authenticators,
+// so the subject itself is not stored in them.
+func userHandle(subject string) []byte {
+	handle := sha256.Sum256([]byte(subject))
+	return handle[:]
+}
@@ -0,0 +1,51 @@
+package webauthn
+
+import (
+	"context"
+	"encoding/base64"
+	"sync"
+)
+
+// MemoryStore is a [CredentialStore] that keeps the credentials in memory.
+// It is meant for tests and development, since the credentials are lost when
+// the process exits.
+type MemoryStore struct {
+	mu          sync.RWMutex
+	credentials map[string]Credential
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{credentials: map[string]Credential{}}
+}
+
+func (s *MemoryStore) Save(_ context.Context, cred Credential) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.credentials[base64.RawURLEncoding.EncodeToString(cred.ID)] = cred
+	return nil
+}
+
+func (s *MemoryStore) Credential(_ context.Context, id []byte) (Credential, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	cred, ok := s.credentials[base64.RawURLEncoding.EncodeToString(id)]
+	if !ok {
+		return Credential{}, ErrCredentialNotFound
+	}
+	return cred, nil
+}
+
+func (s *MemoryStore) Credentials(_ context.Context, subject string) ([]Credential, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var creds []Credential
+	for _, cred := range s.credentials {
+		if cred.Subject == subject {
+			creds = append(creds, cred)
+		}
+	}
+	return creds, nil
+}
@@ -0,0 +1,337 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+const (
+	clientDataTypeGet    = "webauthn.get"
+	clientDataTypeCreate = "webauthn.create"
+
+	flagUserPresent           byte = 0x01
+	flagUserVerified          byte = 0x04
+	flagBackupEligible        byte = 0x08
+	flagAttestedCredentalData byte = 0x40
+
+	// authDataMinLength is the length of the RP ID hash, the flags and the
+	// signature counter.
+	authDataMinLength = 37
+	aaguidLength      = 16
+)
+
+// verificationError indicates the response of the authenticator is invalid,
+// in which case the user can try again.
+type verificationError string
+
+func (err verificationError) Error() string {
+	return string(err)
+}
+
+type clientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+type authenticatorData struct {
+	raw       []byte
+	rpIDHash  []byte
+	flags     byte
+	signCount uint32
+	// credentialID and publicKey are only present for registrations.
+	credentialID []byte
+	publicKey    crypto.PublicKey
+}
+
+// verifyAssertion validates the authentication with an existing credential
+// and updates its signature counter.
+func (config Config) verifyAssertion(
+	r *http.Request,
+	session *goidc.AuthnSession,
+	challenge string,
+) (
+	Credential,
+	error,
+) {
+	id, err := formBytes(r, credentialIDFormParam)
+	if err != nil {
+		return Credential{}, err
+	}
+
+	cred, err := config.Store.Credential(r.Context(), id)
+	if errors.Is(err, ErrCredentialNotFound) {
+		return Credential{}, verificationError("unknown credential")
+	}
+	if err != nil {
+		return Credential{}, err
+	}
+
+	if session.Subject != "" && cred.Subject != session.Subject {
+		return Credential{}, verificationError("the credential belongs to another user")
+	}
+
+	rawClientData, err := config.verifyClientData(r, clientDataTypeGet, challenge)
+	if err != nil {
+		return Credential{}, err
+	}
+
+	authData, err := config.verifyAuthenticatorData(r)
+	if err != nil {
+		return Credential{}, err
+	}
+
+	sig, err := formBytes(r, signatureFormParam)
+	if err != nil {
+		return Credential{}, err
+	}
+
+	// The signature is over the authenticator data followed by the hash of the
+	// client data.
+	clientDataHash := sha256.Sum256(rawClientData)
+	signed := append(slices.Clone(authData.raw), clientDataHash[:]...)
+	if err := verifySignature(cred.PublicKey, signed, sig); err != nil {
+		return Credential{}, err
+	}
+
+	// The backup eligibility of a credential is set when it is created and
+	// must not change.
+	if cred.HardwareBacked && authData.flags&flagBackupEligible != 0 {
+		return Credential{}, verificationError("the credential became backup eligible")
+	}
+
+	// Authenticators that don't implement the counter always send zero. Any
+	// other value that doesn't increase may indicate a cloned authenticator.
+	if (authData.signCount != 0 || cred.SignCount != 0) && authData.signCount <= cred.SignCount {
+		return Credential{}, verificationError("the signature counter did not increase")
+	}
+
+	cred.SignCount = authData.signCount
+	if err := config.Store.Save(r.Context(), cred); err != nil {
+		return Credential{}, err
+	}
+	return cred, nil
+}
+
+// register validates the creation of a credential for the user of the
+// session and saves it.
+func (config Config) register(
+	r *http.Request,
+	session *goidc.AuthnSession,
+	challenge string,
+) (
+	Credential,
+	error,
+) {
+	if !config.AllowRegistration || session.Subject == "" {
+		return Credential{}, verificationError("registration is not allowed")
+	}
+
+	// Only users without credentials can register one, otherwise a user who
+	// passed a previous step could enroll a key as their second factor.
+	creds, err := config.Store.Credentials(r.Context(), session.Subject)
+	if err != nil {
+		return Credential{}, err
+	}
+	if len(creds) != 0 {
+		return Credential{}, verificationError("the user already has a credential registered")
+	}
+
+	id, err := formBytes(r, credentialIDFormParam)
+	if err != nil {
+		return Credential{}, err
+	}
+
+	if _, err := config.verifyClientData(r, clientDataTypeCreate, challenge); err != nil {
+		return Credential{}, err
+	}
+
+	authData, err := config.verifyAuthenticatorData(r)
+	if err != nil {
+		return Credential{}, err
+	}
+
+	if authData.credentialID == nil || !bytes.Equal(authData.credentialID, id) {
+		return Credential{}, verificationError("the credential id doesn't match the authenticator data")
+	}
+
+	publicKey, err := x509.MarshalPKIXPublicKey(authData.publicKey)
+	if err != nil {
+		return Credential{}, err
+	}
+
+	_, err = config.Store.Credential(r.Context(), id)
+	if err == nil {
+		return Credential{}, verificationError("the credential is already registered")
+	}
+	if !errors.Is(err, ErrCredentialNotFound) {
+		return Credential{}, err
+	}
+
+	cred := Credential{
+		ID:        id,
+		Subject:   session.Subject,
+		PublicKey: publicKey,
+		SignCount: authData.signCount,
+		// Credentials that can't be backed up, e.g. synced to other devices,
+		// are bound to the authenticator that created them.
+		HardwareBacked: authData.flags&flagBackupEligible == 0,
+	}
+	if err := config.Store.Save(r.Context(), cred); err != nil {
+		return Credential{}, err
+	}
+	return cred, nil
+}
+
+// verifyClientData validates the client data posted and returns it raw, since
+// its hash is part of the data signed in assertions.
+func (config Config) verifyClientData(r *http.Request, typ, challenge string) ([]byte, error) {
+	raw, err := formBytes(r, clientDataFormParam)
+	if err != nil {
+		return nil, err
+	}
+
+	var data clientData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, verificationError("invalid client data")
+	}
+
+	if data.Type != typ {
+		return nil, verificationError("invalid client data type")
+	}
+
+	if data.Challenge != base64.RawURLEncoding.EncodeToString([]byte(challenge)) {
+		return nil, verificationError("invalid challenge")
+	}
+
+	if !slices.Contains(config.Origins, data.Origin) || data.CrossOrigin {
+		return nil, verificationError("invalid origin")
+	}
+
+	return raw, nil
+}
+
+func (config Config) verifyAuthenticatorData(r *http.Request) (authenticatorData, error) {
+	raw, err := formBytes(r, authenticatorDataFormParam)
+	if err != nil {
+		return authenticatorData{}, err
+	}
+
+	authData, err := parseAuthenticatorData(raw)
+	if err != nil {
+		return authenticatorData{}, err
+	}
+
+	rpIDHash := sha256.Sum256([]byte(config.RPID))
+	if !bytes.Equal(authData.rpIDHash, rpIDHash[:]) {
+		return authenticatorData{}, verificationError("invalid rp id")
+	}
+
+	if authData.flags&flagUserPresent == 0 {
+		return authenticatorData{}, verificationError("the user is not present")
+	}
+
+	if config.UserVerification == UserVerificationRequired && authData.flags&flagUserVerified == 0 {
+		return authenticatorData{}, verificationError("the user was not verified")
+	}
+
+	return authData, nil
+}
+
+func parseAuthenticatorData(raw []byte) (authenticatorData, error) {
+	if len(raw) < authDataMinLength {
+		return authenticatorData{}, verificationError("invalid authenticator data")
+	}
+
+	authData := authenticatorData{
+		raw:       raw,
+		rpIDHash:  raw[:32],
+		flags:     raw[32],
+		signCount: binary.BigEndian.Uint32(raw[33:37]),
+	}
+
+	if authData.flags&flagAttestedCredentalData == 0 {
+		return authData, nil
+	}
+
+	// The attested credential data has the AAGUID followed by the length of
+	// the credential ID, the credential ID itself and the credential public
+	// key.
+	rest := raw[authDataMinLength:]
+	if len(rest) < aaguidLength+2 {
+		return authenticatorData{}, verificationError("invalid attested credential data")
+	}
+	idLength := int(binary.BigEndian.Uint16(rest[aaguidLength:]))
+	rest = rest[aaguidLength+2:]
+	if len(rest) < idLength {
+		return authenticatorData{}, verificationError("invalid attested credential data")
+	}
+	authData.credentialID = rest[:idLength]
+
+	publicKey, err := parseCOSEKey(rest[idLength:])
+	if err != nil {
+		return authenticatorData{}, err
+	}
+	authData.publicKey = publicKey
+
+	return authData, nil
+}
+
+func parsePublicKey(der []byte) (crypto.PublicKey, error) {
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, verificationError("invalid public key")
+	}
+
+	switch key.(type) {
+	case *ecdsa.PublicKey, ed25519.PublicKey, *rsa.PublicKey:
+		return key, nil
+	default:
+		return nil, verificationError("unsupported public key")
+	}
+}
+
+func verifySignature(der, data, sig []byte) error {
+	key, err := parsePublicKey(der)
+	if err != nil {
+		return err
+	}
+
+	valid := false
+	hash := sha256.Sum256(data)
+	switch key := key.(type) {
+	case *ecdsa.PublicKey:
+		valid = ecdsa.VerifyASN1(key, hash[:], sig)
+	case ed25519.PublicKey:
+		valid = ed25519.Verify(key, data, sig)
+	case *rsa.PublicKey:
+		valid = rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], sig) == nil
+	}
+
+	if !valid {
+		return verificationError("invalid signature")
+	}
+	return nil
+}
+
+func formBytes(r *http.Request, param string) ([]byte, error) {
+	value, err := base64.RawURLEncoding.DecodeString(r.PostFormValue(param))
+	if err != nil || len(value) == 0 {
+		return nil, verificationError("invalid " + param)
+	}
+	return value, nil
+}
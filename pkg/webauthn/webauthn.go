@@ -0,0 +1,147 @@
+// Package webauthn provides an authentication step that registers and
+// verifies WebAuthn credentials, e.g. security keys and passkeys.
+//
+//	policy := goidc.NewSequentialPolicy(
+//		"webauthn",
+//		func(*http.Request, *goidc.Client, *goidc.AuthnSession) bool { return true },
+//		pages.LoginStep(verifyPassword),
+//		webauthn.Step(webauthn.Config{
+//			RPID:              "example.com",
+//			Origins:           []string{"https://example.com"},
+//			Store:             store,
+//			AllowRegistration: true,
+//		}),
+//	)
+//
+// Only the "none" attestation is supported, i.e. the authenticator that
+// created a credential is not verified. The credential public key is read
+// from the attested credential data of the authenticator data and ES256,
+// EdDSA and RS256 keys are supported.
+package webauthn
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"html/template"
+
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+const (
+	ceremonyFormParam          = "webauthn_ceremony"
+	credentialIDFormParam      = "credential_id"
+	clientDataFormParam        = "client_data"
+	authenticatorDataFormParam = "authenticator_data"
+	signatureFormParam         = "signature"
+
+	ceremonyCreate = "create"
+	ceremonyGet    = "get"
+
+	// challengeParam is the key of the session store where the challenge of
+	// the ceremony in progress is kept.
+	challengeParam  = "webauthn_challenge"
+	challengeLength = 32
+	// ceremonyParam is the key of the session store where the ceremony
+	// offered to the user is kept, so they cannot answer with another one.
+	ceremonyParam = "webauthn_ceremony"
+
+	defaultTimeoutMillis = 60000
+)
+
+//go:embed templates/webauthn.html
+var pageTemplate string
+
+var defaultTemplate = template.Must(template.New("webauthn").Parse(pageTemplate))
+
+// ErrCredentialNotFound must be returned by a [CredentialStore] when no
+// credential matches the ID informed.
+var ErrCredentialNotFound = errors.New("credential not found")
+
+// Credential is a public key credential registered for a user.
+type Credential struct {
+	ID      []byte `json:"id"`
+	Subject string `json:"sub"`
+	// PublicKey is the public key in the DER encoded SubjectPublicKeyInfo
+	// format.
+	PublicKey []byte `json:"public_key"`
+	SignCount uint32 `json:"sign_count"`
+	// HardwareBacked is whether the credential is bound to the authenticator
+	// that created it, i.e. the authenticator data flags it as not backup
+	// eligible. It defines whether the amr "hwk" or "swk" is set.
+	// Since attestation is not verified, this relies on what the
+	// authenticator reports.
+	HardwareBacked bool `json:"hardware_backed"`
+}
+
+// CredentialStore persists the credentials of the users.
+type CredentialStore interface {
+	// Save creates or updates the credential, which is identified by its ID.
+	Save(ctx context.Context, credential Credential) error
+	// Credential returns the credential with the ID informed or
+	// [ErrCredentialNotFound].
+	Credential(ctx context.Context, id []byte) (Credential, error)
+	// Credentials returns the credentials registered for the user.
+	Credentials(ctx context.Context, subject string) ([]Credential, error)
+}
+
+// UserVerification defines whether the authenticator must verify the user,
+// e.g. with a PIN or biometrics, besides testing their presence.
+type UserVerification string
+
+const (
+	UserVerificationRequired    UserVerification = "required"
+	UserVerificationPreferred   UserVerification = "preferred"
+	UserVerificationDiscouraged UserVerification = "discouraged"
+)
+
+// Config defines how the step runs the WebAuthn ceremonies.
+type Config struct {
+	// RPID is the relying party identifier, usually the domain of the
+	// provider, e.g. "example.com".
+	RPID string
+	// RPName is shown by the browser when registering credentials. It
+	// defaults to RPID.
+	RPName string
+	// Origins are the origins allowed to run the ceremonies, e.g.
+	// "https://example.com".
+	Origins []string
+	Store   CredentialStore
+	// UserVerification defaults to [UserVerificationPreferred].
+	UserVerification UserVerification
+	// AllowRegistration makes the step register a credential for users
+	// already identified by a previous step who have none, instead of failing.
+	AllowRegistration bool
+	// ACR, if set, is the acr claim of the ID token after the user
+	// authenticates with a credential.
+	ACR goidc.ACR
+	// CallbackURL is the URL to which the results of the ceremonies are
+	// posted followed by "/{callback_id}". If not set, they are posted to the
+	// path of the authorization endpoint the request was received at.
+	CallbackURL string
+	// Template, if set, replaces the page that runs the ceremonies. It is
+	// executed with a [Page].
+	Template *template.Template
+}
+
+// Page is the data used to render the page that runs the ceremonies.
+type Page struct {
+	Action   string
+	Ceremony string
+	Error    string
+	Options  Options
+}
+
+// Options are the parameters the page passes to the WebAuthn API of the
+// browser. Binary values are base64url encoded.
+type Options struct {
+	Ceremony         string           `json:"ceremony"`
+	Challenge        string           `json:"challenge"`
+	RPID             string           `json:"rpID"`
+	RPName           string           `json:"rpName,omitempty"`
+	UserID           string           `json:"userID,omitempty"`
+	UserName         string           `json:"userName,omitempty"`
+	CredentialIDs    []string         `json:"credentialIDs,omitempty"`
+	UserVerification UserVerification `json:"userVerification"`
+	TimeoutMillis    int              `json:"timeout"`
+}
@@ -0,0 +1,314 @@
+package webauthn_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/luikyv/go-oidc/pkg/goidc"
+	"github.com/luikyv/go-oidc/pkg/providertest"
+	"github.com/luikyv/go-oidc/pkg/webauthn"
+)
+
+const rpID = "example.com"
+
+func TestStep(t *testing.T) {
+	// Given.
+	store := webauthn.NewMemoryStore()
+	sim := newSimulator(t, store)
+	key := newAuthenticator(t)
+
+	// When.
+	sim.Start(t, nil)
+
+	// Then.
+	if !strings.Contains(sim.Body, `value="create"`) {
+		t.Fatalf("the registration page was not rendered: %s", sim.Body)
+	}
+
+	// When.
+	sim.Submit(t, key.register(t, sim, 0x41))
+
+	// Then.
+	sim.Code(t)
+	creds, _ := store.Credentials(context.Background(), providertest.DefaultSubject)
+	if len(creds) != 1 || !creds[0].HardwareBacked {
+		t.Fatalf("the credential was not registered: %v", creds)
+	}
+
+	amrs := sim.Session().IDTokenClaimAMRs()
+	if !slices.Equal(amrs, []goidc.AMR{goidc.AMRHardwareSecuredKey}) {
+		t.Errorf("amr = %v, want [hwk]", amrs)
+	}
+
+	// When.
+	sim.Start(t, nil)
+
+	// Then.
+	if !strings.Contains(sim.Body, `value="get"`) {
+		t.Fatalf("the authentication page was not rendered: %s", sim.Body)
+	}
+
+	// When.
+	sim.Submit(t, key.assert(t, sim, 1))
+
+	// Then.
+	sim.Tokens(t)
+	if acr := sim.Session().AdditionalIDTokenClaims[goidc.ClaimACR]; acr != goidc.ACRMaceIncommonIAPSilver {
+		t.Errorf("acr = %v, want %s", acr, goidc.ACRMaceIncommonIAPSilver)
+	}
+}
+
+func TestStep_SignCountNotIncreased(t *testing.T) {
+	// Given.
+	store := webauthn.NewMemoryStore()
+	sim := newSimulator(t, store)
+	key := newAuthenticator(t)
+	sim.Start(t, nil)
+	sim.Submit(t, key.register(t, sim, 0x41))
+	sim.Start(t, nil)
+	sim.Submit(t, key.assert(t, sim, 5))
+	sim.Start(t, nil)
+
+	// When.
+	sim.Submit(t, key.assert(t, sim, 5))
+
+	// Then.
+	if !strings.Contains(sim.Body, "could not be verified") {
+		t.Fatalf("the assertion should be rejected: %s", sim.Body)
+	}
+
+	if status, _ := sim.Status(); status != goidc.StatusInProgress {
+		t.Errorf("status = %s, want %s", status, goidc.StatusInProgress)
+	}
+}
+
+func TestStep_InvalidSignature(t *testing.T) {
+	// Given.
+	store := webauthn.NewMemoryStore()
+	sim := newSimulator(t, store)
+	key := newAuthenticator(t)
+	sim.Start(t, nil)
+	sim.Submit(t, key.register(t, sim, 0x41))
+	sim.Start(t, nil)
+
+	// When.
+	form := key.assert(t, sim, 1)
+	form.Set("signature", base64.RawURLEncoding.EncodeToString([]byte("invalid")))
+	sim.Submit(t, form)
+
+	// Then.
+	if !strings.Contains(sim.Body, "could not be verified") {
+		t.Fatalf("the assertion should be rejected: %s", sim.Body)
+	}
+}
+
+func TestStep_BackupEligibleCredential(t *testing.T) {
+	// Given.
+	store := webauthn.NewMemoryStore()
+	sim := newSimulator(t, store)
+	key := newAuthenticator(t)
+	sim.Start(t, nil)
+
+	// When.
+	// Flags user present, backup eligible and attested credential data.
+	sim.Submit(t, key.register(t, sim, 0x49))
+
+	// Then.
+	sim.Code(t)
+	creds, _ := store.Credentials(context.Background(), providertest.DefaultSubject)
+	if len(creds) != 1 || creds[0].HardwareBacked {
+		t.Fatalf("the credential must not be hardware backed: %v", creds)
+	}
+
+	amrs := sim.Session().IDTokenClaimAMRs()
+	if !slices.Equal(amrs, []goidc.AMR{goidc.AMRSoftwareSecuredKey}) {
+		t.Errorf("amr = %v, want [swk]", amrs)
+	}
+}
+
+func TestStep_InvalidCredentialPublicKey(t *testing.T) {
+	// Given.
+	store := webauthn.NewMemoryStore()
+	sim := newSimulator(t, store)
+	key := newAuthenticator(t)
+	sim.Start(t, nil)
+
+	// When.
+	form := key.register(t, sim, 0x41)
+	authData, _ := base64.RawURLEncoding.DecodeString(form.Get("authenticator_data"))
+	// Truncate the y coordinate of the COSE key.
+	form.Set("authenticator_data", encode(authData[:len(authData)-1]))
+	sim.Submit(t, form)
+
+	// Then.
+	if !strings.Contains(sim.Body, "could not be verified") {
+		t.Fatalf("the registration should be rejected: %s", sim.Body)
+	}
+
+	creds, _ := store.Credentials(context.Background(), providertest.DefaultSubject)
+	if len(creds) != 0 {
+		t.Errorf("no credential should be registered: %v", creds)
+	}
+}
+
+func TestStep_RegistrationWithExistingCredential(t *testing.T) {
+	// Given.
+	store := webauthn.NewMemoryStore()
+	sim := newSimulator(t, store)
+	key := newAuthenticator(t)
+	sim.Start(t, nil)
+	sim.Submit(t, key.register(t, sim, 0x41))
+	sim.Start(t, nil)
+
+	otherKey := newAuthenticator(t)
+	otherKey.id = []byte("other_credential_id")
+
+	// When.
+	sim.Submit(t, otherKey.register(t, sim, 0x41))
+
+	// Then.
+	if !strings.Contains(sim.Body, "could not be verified") {
+		t.Fatalf("the registration should be rejected: %s", sim.Body)
+	}
+
+	creds, _ := store.Credentials(context.Background(), providertest.DefaultSubject)
+	if len(creds) != 1 {
+		t.Errorf("only the first credential should be registered: %v", creds)
+	}
+}
+
+func newSimulator(t *testing.T, store webauthn.CredentialStore) *providertest.PolicySimulator {
+	t.Helper()
+
+	identify := func(_ http.ResponseWriter, _ *http.Request, session *goidc.AuthnSession) (goidc.AuthnStatus, error) {
+		session.SetUserID(providertest.DefaultSubject)
+		return goidc.StatusSuccess, nil
+	}
+	grant := func(_ http.ResponseWriter, _ *http.Request, session *goidc.AuthnSession) (goidc.AuthnStatus, error) {
+		session.GrantScopes(session.Scopes)
+		return goidc.StatusSuccess, nil
+	}
+
+	var sim *providertest.PolicySimulator
+	origin := func() string { return sim.Server.URL }
+	step := func(w http.ResponseWriter, r *http.Request, session *goidc.AuthnSession) (goidc.AuthnStatus, error) {
+		// The origin is only known once the server starts.
+		return webauthn.Step(webauthn.Config{
+			RPID:              rpID,
+			Origins:           []string{origin()},
+			Store:             store,
+			AllowRegistration: true,
+			ACR:               goidc.ACRMaceIncommonIAPSilver,
+		})(w, r, session)
+	}
+
+	sim = providertest.NewPolicySimulator(t, goidc.NewSequentialPolicy(
+		"webauthn",
+		func(*http.Request, *goidc.Client, *goidc.AuthnSession) bool { return true },
+		identify,
+		step,
+		grant,
+	))
+	return sim
+}
+
+// authenticator simulates a security key holding a single credential.
+type authenticator struct {
+	id  []byte
+	key *ecdsa.PrivateKey
+}
+
+func newAuthenticator(t *testing.T) authenticator {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return authenticator{id: []byte("random_credential_id"), key: key}
+}
+
+func (a authenticator) register(t *testing.T, sim *providertest.PolicySimulator, flags byte) url.Values {
+	t.Helper()
+
+	authData := authenticatorData(flags, 0)
+	authData = append(authData, make([]byte, 16)...)
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(a.id)))
+	authData = append(authData, a.id...)
+	authData = append(authData, a.coseKey()...)
+
+	return url.Values{
+		"webauthn_ceremony":  {"create"},
+		"credential_id":      {encode(a.id)},
+		"client_data":        {encode(clientData(t, sim, "webauthn.create"))},
+		"authenticator_data": {encode(authData)},
+	}
+}
+
+// coseKey encodes the public key as the CBOR map
+// {1: 2, 3: -7, -1: 1, -2: x, -3: y}.
+func (a authenticator) coseKey() []byte {
+	key := []byte{0xa5, 0x01, 0x02, 0x03, 0x26, 0x20, 0x01}
+	key = append(key, 0x21, 0x58, 0x20)
+	key = append(key, a.key.X.FillBytes(make([]byte, 32))...)
+	key = append(key, 0x22, 0x58, 0x20)
+	key = append(key, a.key.Y.FillBytes(make([]byte, 32))...)
+	return key
+}
+
+func (a authenticator) assert(t *testing.T, sim *providertest.PolicySimulator, signCount uint32) url.Values {
+	t.Helper()
+
+	data := clientData(t, sim, "webauthn.get")
+	authData := authenticatorData(0x05, signCount)
+	dataHash := sha256.Sum256(data)
+	hash := sha256.Sum256(append(slices.Clone(authData), dataHash[:]...))
+	sig, err := ecdsa.SignASN1(rand.Reader, a.key, hash[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return url.Values{
+		"webauthn_ceremony":  {"get"},
+		"credential_id":      {encode(a.id)},
+		"client_data":        {encode(data)},
+		"authenticator_data": {encode(authData)},
+		"signature":          {encode(sig)},
+	}
+}
+
+func authenticatorData(flags byte, signCount uint32) []byte {
+	rpIDHash := sha256.Sum256([]byte(rpID))
+	data := append(rpIDHash[:], flags)
+	return binary.BigEndian.AppendUint32(data, signCount)
+}
+
+func clientData(t *testing.T, sim *providertest.PolicySimulator, typ string) []byte {
+	t.Helper()
+
+	challenge, _ := sim.Session().Store["webauthn_challenge"].(string)
+	data, err := json.Marshal(map[string]any{
+		"type":      typ,
+		"challenge": encode([]byte(challenge)),
+		"origin":    sim.Server.URL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}